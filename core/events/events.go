@@ -1,53 +1,51 @@
+// Package events gives the gamemode the server's event types. The server
+// defines and fires them (see source/server/events.go); this package only
+// re-exports them so gamemode code does not reach into the server for them.
 package events
 
+import "samp-server-go/source/server"
+
 // EventType represents different event types
-type EventType int
+type EventType = server.EventType
 
 const (
-	EventPlayerConnect EventType = iota
-	EventPlayerDisconnect
-	EventPlayerSpawn
-	EventPlayerDeath
-	EventPlayerCommand
-	EventPlayerText
-	EventPlayerUpdate
-	EventVehicleSpawn
-	EventVehicleDestroy
+	EventPlayerConnect       = server.EventPlayerConnect
+	EventPlayerDisconnect    = server.EventPlayerDisconnect
+	EventPlayerSpawn         = server.EventPlayerSpawn
+	EventPlayerDeath         = server.EventPlayerDeath
+	EventPlayerCommand       = server.EventPlayerCommand
+	EventPlayerText          = server.EventPlayerText
+	EventPlayerUpdate        = server.EventPlayerUpdate
+	EventVehicleSpawn        = server.EventVehicleSpawn
+	EventVehicleDestroy      = server.EventVehicleDestroy
+	EventPlayerVehicleChange = server.EventPlayerVehicleChange
+	EventPlayerAFKChange     = server.EventPlayerAFKChange
+	EventPlayerStreamIn      = server.EventPlayerStreamIn
+	EventPlayerStreamOut     = server.EventPlayerStreamOut
+	EventVehicleStreamIn     = server.EventVehicleStreamIn
+	EventVehicleStreamOut    = server.EventVehicleStreamOut
+)
+
+// Event.Data payloads
+type (
+	ConnectData       = server.ConnectData
+	DisconnectData    = server.DisconnectData
+	CommandData       = server.CommandData
+	TextData          = server.TextData
+	VehicleChangeData = server.VehicleChangeData
+	AFKData           = server.AFKData
 )
 
 // Event represents a game event
-type Event struct {
-	Type      EventType
-	PlayerID  uint16
-	Data      interface{}
-	Timestamp int64
-}
+type Event = server.Event
 
 // EventHandler is a function that handles events
-type EventHandler func(event Event)
+type EventHandler = server.EventHandler
 
 // EventManager manages game events
-type EventManager struct {
-	handlers map[EventType][]EventHandler
-}
+type EventManager = server.EventManager
 
 // NewEventManager creates a new event manager
 func NewEventManager() *EventManager {
-	return &EventManager{
-		handlers: make(map[EventType][]EventHandler),
-	}
-}
-
-// Register registers an event handler
-func (em *EventManager) Register(eventType EventType, handler EventHandler) {
-	em.handlers[eventType] = append(em.handlers[eventType], handler)
-}
-
-// Trigger triggers an event
-func (em *EventManager) Trigger(event Event) {
-	if handlers, exists := em.handlers[event.Type]; exists {
-		for _, handler := range handlers {
-			handler(event)
-		}
-	}
+	return server.NewEventManager()
 }
@@ -1,11 +1,21 @@
 package gamemode
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"math/rand"
+	"samp-server-go/core/events"
+	"samp-server-go/core/systems"
+	"samp-server-go/source/protocol"
+	"samp-server-go/source/server"
+	"sync"
 	"time"
 )
 
+// ErrNoSuchPlayer is returned when a player has already left the gamemode
+var ErrNoSuchPlayer = errors.New("no such player")
+
 // Player represents a connected player
 type Player struct {
 	ID       uint16
@@ -22,7 +32,10 @@ type Player struct {
 	Team     int
 	Wanted   int
 	IsAdmin  bool
+	AdminLevel int // gates admin commands by their MinLevel (see SetAdminLevel)
 	LastSeen time.Time
+	VehicleID uint16 // vehicle the player is in, 0 if on foot
+	Weapons  [WeaponSlots]WeaponSlot
 }
 
 // Vector3 represents 3D coordinates
@@ -32,11 +45,24 @@ type Vector3 struct {
 
 // FreeroamGamemode implements a complex freeroam gamemode
 type FreeroamGamemode struct {
+	mu            sync.RWMutex // guards players and rng
 	players       map[uint16]*Player
 	vehicles      map[uint16]*Vehicle
 	spawnPoints   []SpawnPoint
 	adminCommands map[string]AdminCommand
 	playerCommands map[string]PlayerCommand
+	vehicleSystem  *systems.VehicleSystem
+	rng            *rand.Rand
+	sendPlayerRPC  func(playerID uint16, rpcPayload []byte, reliability byte) // sends RPCs to one player (optional)
+	teleporter     func(playerID uint16, x, y, z, angle float32) error // moves a player on the server (optional)
+	positionOf     func(playerID uint16) (x, y, z, angle float32, err error) // reads a player's live position on the server (optional)
+	teleportFreeze time.Duration // how long a teleported player stays frozen while the map loads
+	kicker         func(playerID uint16, reason string) error        // disconnects a player (optional)
+	banner         func(playerID uint16, admin, reason string) error // bans and disconnects a player (optional)
+	renamer        func(playerID uint16, name string) int            // renames a player on the server (optional)
+	scorer         func(playerID uint16, score int) error             // sets a player's score on the server (optional)
+	adminPassword  string // "/login" password, empty = admin login disabled (see SetAdminPassword)
+	adminLoginLevel int   // admin level granted by "/login"
 }
 
 // SpawnPoint defines a spawn location
@@ -81,6 +107,8 @@ func NewFreeroamGamemode() *FreeroamGamemode {
 		spawnPoints:    make([]SpawnPoint, 0),
 		adminCommands:  make(map[string]AdminCommand),
 		playerCommands: make(map[string]PlayerCommand),
+		rng:            rand.New(rand.NewSource(time.Now().UnixNano())),
+		teleportFreeze: DefaultTeleportFreeze,
 	}
 	
 	gm.initializeSpawnPoints()
@@ -89,6 +117,26 @@ func NewFreeroamGamemode() *FreeroamGamemode {
 	return gm
 }
 
+// SetRand replaces the random source used for spawns and other gamemode randomness
+func (gm *FreeroamGamemode) SetRand(rng *rand.Rand) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	gm.rng = rng
+}
+
+// randIntn returns a random int in [0, n). *rand.Rand is not safe for
+// concurrent use, so every draw goes through gm.mu.
+func (gm *FreeroamGamemode) randIntn(n int) int {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	return gm.rng.Intn(n)
+}
+
+// SetVehicleSystem sets the vehicle system used by vehicle commands
+func (gm *FreeroamGamemode) SetVehicleSystem(vs *systems.VehicleSystem) {
+	gm.vehicleSystem = vs
+}
+
 // initializeSpawnPoints sets up spawn locations
 func (gm *FreeroamGamemode) initializeSpawnPoints() {
 	// Los Santos spawns
@@ -133,6 +181,18 @@ func (gm *FreeroamGamemode) registerCommands() {
 		Handler:     gm.cmdVehicle,
 	}
 	
+	gm.playerCommands["fix"] = PlayerCommand{
+		Name:        "fix",
+		Description: "Repair your vehicle",
+		Handler:     gm.cmdFix,
+	}
+	
+	gm.playerCommands["login"] = PlayerCommand{
+		Name:        "login",
+		Description: "Log in as an admin",
+		Handler:     gm.cmdLogin,
+	}
+	
 	// Admin commands
 	gm.adminCommands["kick"] = AdminCommand{
 		Name:        "kick",
@@ -152,7 +212,21 @@ func (gm *FreeroamGamemode) registerCommands() {
 		Name:        "tp",
 		Description: "Teleport to a player",
 		MinLevel:    1,
-		Handler:     gm.cmdTeleport,
+		Handler:     gm.cmdGoto,
+	}
+	
+	gm.adminCommands["goto"] = AdminCommand{
+		Name:        "goto",
+		Description: "Teleport to a player",
+		MinLevel:    1,
+		Handler:     gm.cmdGoto,
+	}
+	
+	gm.adminCommands["gethere"] = AdminCommand{
+		Name:        "gethere",
+		Description: "Teleport a player to you",
+		MinLevel:    1,
+		Handler:     gm.cmdGetHere,
 	}
 	
 	gm.adminCommands["heal"] = AdminCommand{
@@ -166,6 +240,46 @@ func (gm *FreeroamGamemode) registerCommands() {
 		len(gm.playerCommands), len(gm.adminCommands))
 }
 
+// RegisterEvents hooks the gamemode's callbacks up to the server's events
+func (gm *FreeroamGamemode) RegisterEvents(em *events.EventManager) {
+	em.Register(events.EventPlayerConnect, func(e events.Event) {
+		if data, ok := e.Data.(events.ConnectData); ok {
+			gm.OnPlayerConnect(e.PlayerID, data.Name)
+			gm.setScore(e.PlayerID, data.Score) // restored from saved stats
+		}
+	})
+	em.Register(events.EventPlayerDisconnect, func(e events.Event) {
+		if data, ok := e.Data.(events.DisconnectData); ok {
+			gm.OnPlayerDisconnect(e.PlayerID, data.Reason, data.Detail)
+		}
+	})
+	em.Register(events.EventPlayerSpawn, func(e events.Event) {
+		gm.OnPlayerSpawn(e.PlayerID)
+	})
+	em.Register(events.EventPlayerDeath, func(e events.Event) {
+		gm.OnPlayerDeath(e.PlayerID)
+	})
+	em.Register(events.EventPlayerUpdate, func(e events.Event) {
+		gm.OnPlayerUpdate(e.PlayerID)
+	})
+	em.Register(events.EventPlayerText, func(e events.Event) {
+		if data, ok := e.Data.(events.TextData); ok {
+			gm.OnPlayerText(e.PlayerID, data.Text)
+		}
+	})
+	em.Register(events.EventPlayerVehicleChange, func(e events.Event) {
+		if data, ok := e.Data.(events.VehicleChangeData); ok {
+			gm.OnPlayerVehicleChange(e.PlayerID, data.VehicleID)
+		}
+	})
+	em.Register(events.EventPlayerCommand, func(e events.Event) {
+		data, ok := e.Data.(events.CommandData)
+		if ok && !gm.OnPlayerCommand(e.PlayerID, data.Command, data.Args) {
+			gm.SendMessageToPlayer(e.PlayerID, protocol.ColorWhite, "SERVER: Unknown command.")
+		}
+	})
+}
+
 // OnPlayerConnect is called when a player connects
 func (gm *FreeroamGamemode) OnPlayerConnect(playerID uint16, name string) {
 	player := &Player{
@@ -184,51 +298,115 @@ func (gm *FreeroamGamemode) OnPlayerConnect(playerID uint16, name string) {
 		LastSeen: time.Now(),
 	}
 	
+	gm.mu.Lock()
 	gm.players[playerID] = player
+	gm.mu.Unlock()
 	
 	log.Printf("🎮 [Gamemode] Player %s (ID: %d) connected", name, playerID)
-	gm.SendMessageToAll(0xFFFF00AA, player.Name+" has joined the server")
+	gm.SendMessageToAll(protocol.ColorYellow, player.Name+" has joined the server")
 }
 
-// OnPlayerDisconnect is called when a player disconnects
-func (gm *FreeroamGamemode) OnPlayerDisconnect(playerID uint16, reason string) {
+// OnPlayerDisconnect is called when a player disconnects; detail may be empty
+func (gm *FreeroamGamemode) OnPlayerDisconnect(playerID uint16, reason server.DisconnectReason, detail string) {
+	gm.mu.Lock()
 	player, exists := gm.players[playerID]
+	delete(gm.players, playerID)
+	gm.mu.Unlock()
 	if !exists {
 		return
 	}
 	
-	log.Printf("🎮 [Gamemode] Player %s (ID: %d) disconnected: %s", player.Name, playerID, reason)
-	gm.SendMessageToAll(0xFF0000AA, player.Name+" has left the server ("+reason+")")
-	
-	delete(gm.players, playerID)
+	log.Printf("🎮 [Gamemode] Player %s (ID: %d) disconnected: %s %s", player.Name, playerID, reason, detail)
+	gm.SendMessageToAll(protocol.ColorRed, player.Name+" has left the server ("+reason.String()+")")
 }
 
 // OnPlayerSpawn is called when a player spawns
 func (gm *FreeroamGamemode) OnPlayerSpawn(playerID uint16) {
-	player, exists := gm.players[playerID]
+	player, exists := gm.GetPlayer(playerID)
 	if !exists {
 		return
 	}
 	
 	// Get random spawn point
-	spawn := gm.spawnPoints[rand.Intn(len(gm.spawnPoints))]
+	spawn := gm.spawnPoints[gm.randIntn(len(gm.spawnPoints))]
 	
+	gm.mu.Lock()
 	player.Position = spawn.Position
 	player.Rotation = spawn.Rotation
 	player.Skin = spawn.Skin
 	player.Health = 100.0
 	player.Armour = 0.0
+	name := player.Name
+	gm.mu.Unlock()
 	
 	log.Printf("🎮 [Gamemode] Player %s spawned at %.2f, %.2f, %.2f", 
-		player.Name, spawn.Position.X, spawn.Position.Y, spawn.Position.Z)
+		name, spawn.Position.X, spawn.Position.Y, spawn.Position.Z)
+}
+
+// OnPlayerDeath is called when a player's health drops to zero
+func (gm *FreeroamGamemode) OnPlayerDeath(playerID uint16) {
+	gm.mu.Lock()
+	player, exists := gm.players[playerID]
+	if !exists {
+		gm.mu.Unlock()
+		return
+	}
+	player.Health = 0.0
+	player.Weapons = [WeaponSlots]WeaponSlot{} // weapons are lost on death
+	name := player.Name
+	gm.mu.Unlock()
 	
-	gm.SendMessageToPlayer(playerID, 0x00FF00AA, "Welcome to SA-MP Freeroam Server!")
-	gm.SendMessageToPlayer(playerID, 0xFFFFFFAA, "Type /help to see available commands")
+	log.Printf("🎮 [Gamemode] Player %s died", name)
+}
+
+// OnPlayerUpdate is called periodically for every in-game player
+func (gm *FreeroamGamemode) OnPlayerUpdate(playerID uint16) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	if player, exists := gm.players[playerID]; exists {
+		player.LastSeen = time.Now()
+	}
+}
+
+// OnPlayerText is called when a player sends a chat line; it is shown to
+// every player
+func (gm *FreeroamGamemode) OnPlayerText(playerID uint16, text string) {
+	player, exists := gm.GetPlayer(playerID)
+	if !exists {
+		return
+	}
+	
+	log.Printf("💬 [Chat] %s: %s", player.Name, text)
+	if gm.sendPlayerRPC == nil {
+		return
+	}
+	
+	gm.mu.RLock()
+	ids := make([]uint16, 0, len(gm.players))
+	for id := range gm.players {
+		ids = append(ids, id)
+	}
+	gm.mu.RUnlock()
+	
+	rpc := protocol.BuildChatRPC(playerID, text)
+	for _, id := range ids {
+		gm.sendPlayerRPC(id, rpc, protocol.RELIABLE_ORDERED)
+	}
+}
+
+// OnPlayerVehicleChange is called when a player gets into, out of or
+// switches vehicles; vehicleID is 0 when they are back on foot
+func (gm *FreeroamGamemode) OnPlayerVehicleChange(playerID uint16, vehicleID uint16) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	if player, exists := gm.players[playerID]; exists {
+		player.VehicleID = vehicleID
+	}
 }
 
 // OnPlayerCommand is called when a player types a command
 func (gm *FreeroamGamemode) OnPlayerCommand(playerID uint16, command string, args []string) bool {
-	player, exists := gm.players[playerID]
+	player, exists := gm.GetPlayer(playerID)
 	if !exists {
 		return false
 	}
@@ -237,7 +415,7 @@ func (gm *FreeroamGamemode) OnPlayerCommand(playerID uint16, command string, arg
 	if cmd, found := gm.playerCommands[command]; found {
 		result := cmd.Handler(player, args)
 		if result != "" {
-			gm.SendMessageToPlayer(playerID, 0xFFFFFFAA, result)
+			gm.SendMessageToPlayer(playerID, protocol.ColorWhite, result)
 		}
 		return true
 	}
@@ -245,13 +423,17 @@ func (gm *FreeroamGamemode) OnPlayerCommand(playerID uint16, command string, arg
 	// Check admin commands
 	if cmd, found := gm.adminCommands[command]; found {
 		if !player.IsAdmin {
-			gm.SendMessageToPlayer(playerID, 0xFF0000AA, "You are not authorized to use this command")
+			gm.SendMessageToPlayer(playerID, protocol.ColorRed, "You are not authorized to use this command")
+			return true
+		}
+		if player.AdminLevel < cmd.MinLevel {
+			gm.SendMessageToPlayer(playerID, protocol.ColorRed, fmt.Sprintf("This command needs admin level %d", cmd.MinLevel))
 			return true
 		}
 		
 		result := cmd.Handler(player, args)
 		if result != "" {
-			gm.SendMessageToPlayer(playerID, 0xFFFFFFAA, result)
+			gm.SendMessageToPlayer(playerID, protocol.ColorWhite, result)
 		}
 		return true
 	}
@@ -261,7 +443,7 @@ func (gm *FreeroamGamemode) OnPlayerCommand(playerID uint16, command string, arg
 
 // Command handlers
 func (gm *FreeroamGamemode) cmdHelp(player *Player, args []string) string {
-	return "Available commands: /help, /stats, /kill, /v [vehicleid]"
+	return "Available commands: /help, /stats, /kill, /v [vehicleid], /fix, /login [password] | Admin: /goto [playerid], /gethere [playerid]"
 }
 
 func (gm *FreeroamGamemode) cmdStats(player *Player, args []string) string {
@@ -284,31 +466,15 @@ func (gm *FreeroamGamemode) cmdVehicle(player *Player, args []string) string {
 	return "Vehicle spawned (feature coming soon)"
 }
 
-func (gm *FreeroamGamemode) cmdKick(player *Player, args []string) string {
-	if len(args) < 1 {
-		return "Usage: /kick [playerid]"
-	}
-	
-	// TODO: Kick player
-	return "Player kicked (feature coming soon)"
-}
-
-func (gm *FreeroamGamemode) cmdBan(player *Player, args []string) string {
-	if len(args) < 1 {
-		return "Usage: /ban [playerid]"
+func (gm *FreeroamGamemode) cmdFix(player *Player, args []string) string {
+	if player.VehicleID == 0 {
+		return "You are not in a vehicle"
 	}
 	
-	// TODO: Ban player
-	return "Player banned (feature coming soon)"
-}
-
-func (gm *FreeroamGamemode) cmdTeleport(player *Player, args []string) string {
-	if len(args) < 1 {
-		return "Usage: /tp [playerid]"
+	if gm.vehicleSystem == nil || !gm.vehicleSystem.RepairVehicle(player.VehicleID) {
+		return "Your vehicle could not be repaired"
 	}
-	
-	// TODO: Teleport to player
-	return "Teleported (feature coming soon)"
+	return "Vehicle repaired"
 }
 
 func (gm *FreeroamGamemode) cmdHeal(player *Player, args []string) string {
@@ -320,25 +486,132 @@ func (gm *FreeroamGamemode) cmdHeal(player *Player, args []string) string {
 	return "Player healed (feature coming soon)"
 }
 
-// SendMessageToPlayer sends a message to a specific player
-func (gm *FreeroamGamemode) SendMessageToPlayer(playerID uint16, color uint32, message string) {
-	// TODO: Implement actual packet sending
+// SendMessageToPlayer sends a chat message to one player, reliable and in
+// order. It returns ErrNoSuchPlayer if the player has already left, e.g. when
+// called from a delayed handler.
+func (gm *FreeroamGamemode) SendMessageToPlayer(playerID uint16, color uint32, message string) error {
+	return gm.SendMessageToPlayerWithReliability(playerID, color, message, protocol.RELIABLE_ORDERED)
+}
+
+// SendMessageToPlayerWithReliability is SendMessageToPlayer with a chosen
+// reliability. Transient text (timers, combat numbers) can go UNRELIABLE so a
+// lost line doesn't hold up the ordered channel.
+func (gm *FreeroamGamemode) SendMessageToPlayerWithReliability(playerID uint16, color uint32, message string, reliability byte) error {
+	if _, exists := gm.GetPlayer(playerID); !exists {
+		return fmt.Errorf("send message to %d: %w", playerID, ErrNoSuchPlayer)
+	}
+	
 	log.Printf("📨 [To %d] %s", playerID, message)
+	if gm.sendPlayerRPC != nil {
+		gm.sendPlayerRPC(playerID, protocol.BuildSendClientMessageRPC(color, message), reliability)
+	}
+	return nil
 }
 
-// SendMessageToAll sends a message to all players
+// SendMessageToAll sends a chat line to every player in the gamemode
 func (gm *FreeroamGamemode) SendMessageToAll(color uint32, message string) {
-	// TODO: Implement actual packet sending
 	log.Printf("📢 [Broadcast] %s", message)
+	if gm.sendPlayerRPC == nil {
+		return
+	}
+	
+	gm.mu.RLock()
+	ids := make([]uint16, 0, len(gm.players))
+	for id := range gm.players {
+		ids = append(ids, id)
+	}
+	gm.mu.RUnlock()
+	
+	rpc := protocol.BuildSendClientMessageRPC(color, message)
+	for _, id := range ids {
+		gm.sendPlayerRPC(id, rpc, protocol.RELIABLE_ORDERED)
+	}
+}
+
+// SetAdminLevel makes a player an admin of the given level (0 = not an admin)
+func (gm *FreeroamGamemode) SetAdminLevel(playerID uint16, level int) bool {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	
+	player, exists := gm.players[playerID]
+	if !exists {
+		return false
+	}
+	player.AdminLevel = level
+	player.IsAdmin = level > 0
+	return true
+}
+
+// SetRenamer sets the function that renames a player on the server and
+// broadcasts the change, e.g. Server.SetPlayerName
+func (gm *FreeroamGamemode) SetRenamer(rename func(playerID uint16, name string) int) {
+	gm.renamer = rename
+}
+
+// SetPlayerName renames a player through the server and, if the server accepts
+// the name, updates the gamemode's copy. It returns the server's result:
+// server.NameChangeSuccess, NameChangeTaken or NameChangeInvalid.
+func (gm *FreeroamGamemode) SetPlayerName(playerID uint16, name string) int {
+	if _, exists := gm.GetPlayer(playerID); !exists || gm.renamer == nil {
+		return server.NameChangeInvalid
+	}
+	
+	result := gm.renamer(playerID, name)
+	if result != server.NameChangeSuccess {
+		return result
+	}
+	
+	gm.mu.Lock()
+	if player, exists := gm.players[playerID]; exists {
+		player.Name = name
+	}
+	gm.mu.Unlock()
+	return result
+}
+
+// SetScorer sets the function that sets a player's score on the server, e.g.
+// Server.SetPlayerScore, so it shows on the scoreboard and is saved
+func (gm *FreeroamGamemode) SetScorer(score func(playerID uint16, score int) error) {
+	gm.scorer = score
+}
+
+// SetPlayerScore sets a player's score in the gamemode and on the server
+func (gm *FreeroamGamemode) SetPlayerScore(playerID uint16, score int) error {
+	if !gm.setScore(playerID, score) {
+		return fmt.Errorf("player %d not found", playerID)
+	}
+	if gm.scorer == nil {
+		return nil
+	}
+	return gm.scorer(playerID, score)
+}
+
+// setScore updates the gamemode's copy of a player's score
+func (gm *FreeroamGamemode) setScore(playerID uint16, score int) bool {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	
+	player, exists := gm.players[playerID]
+	if !exists {
+		return false
+	}
+	player.Score = score
+	return true
 }
 
 // GetPlayer returns a player by ID
 func (gm *FreeroamGamemode) GetPlayer(playerID uint16) (*Player, bool) {
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+	
 	player, exists := gm.players[playerID]
 	return player, exists
 }
 
 // GetPlayerCount returns the number of connected players
 func (gm *FreeroamGamemode) GetPlayerCount() int {
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+	
 	return len(gm.players)
 }
@@ -0,0 +1,274 @@
+package gamemode
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"net"
+	"samp-server-go/core/events"
+	"samp-server-go/core/systems"
+	"samp-server-go/source/protocol"
+	"samp-server-go/source/server"
+	"sync"
+	"testing"
+)
+
+func spawnSequence(seed int64, count int) []Vector3 {
+	gm := NewFreeroamGamemode()
+	gm.SetRand(rand.New(rand.NewSource(seed)))
+	gm.OnPlayerConnect(0, "Tester")
+	
+	positions := make([]Vector3, 0, count)
+	for i := 0; i < count; i++ {
+		gm.OnPlayerSpawn(0)
+		player, _ := gm.GetPlayer(0)
+		positions = append(positions, player.Position)
+	}
+	return positions
+}
+
+func TestSpawnSequenceIsReproducible(t *testing.T) {
+	first := spawnSequence(42, 10)
+	second := spawnSequence(42, 10)
+	
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("Spawn %d differs between runs: %v vs %v", i, first[i], second[i])
+		}
+	}
+}
+
+func TestSendMessageToRemovedPlayer(t *testing.T) {
+	gm := NewFreeroamGamemode()
+	gm.OnPlayerConnect(3, "Leaver")
+	sent := 0
+	gm.SetPlayerRPCSender(func(playerID uint16, rpcPayload []byte, reliability byte) {
+		sent++
+	})
+	
+	if err := gm.SendMessageToPlayer(3, protocol.ColorWhite, "hello"); err != nil {
+		t.Fatalf("Expected send to a connected player to succeed, got %v", err)
+	}
+	if sent != 1 {
+		t.Fatalf("Expected 1 RPC sent, got %d", sent)
+	}
+	
+	gm.OnPlayerDisconnect(3, server.DisconnectQuit, "")
+	
+	err := gm.SendMessageToPlayer(3, protocol.ColorWhite, "too late")
+	if !errors.Is(err, ErrNoSuchPlayer) {
+		t.Errorf("Expected ErrNoSuchPlayer, got %v", err)
+	}
+	if sent != 1 {
+		t.Errorf("Expected nothing sent to a removed player, got %d RPCs", sent)
+	}
+}
+
+func TestSendMessageToPlayerReliability(t *testing.T) {
+	gm := NewFreeroamGamemode()
+	gm.OnPlayerConnect(0, "Tester")
+	reliabilities := make([]byte, 0)
+	gm.SetPlayerRPCSender(func(playerID uint16, rpcPayload []byte, reliability byte) {
+		reliabilities = append(reliabilities, reliability)
+	})
+	
+	gm.SendMessageToPlayer(0, protocol.ColorWhite, "hello")
+	gm.SendMessageToPlayerWithReliability(0, protocol.ColorWhite, "3...", protocol.UNRELIABLE)
+	
+	if len(reliabilities) != 2 || reliabilities[0] != protocol.RELIABLE_ORDERED || reliabilities[1] != protocol.UNRELIABLE {
+		t.Errorf("Expected RELIABLE_ORDERED then UNRELIABLE, got %v", reliabilities)
+	}
+}
+
+func TestRegisterEventsRoutesToGamemode(t *testing.T) {
+	gm := NewFreeroamGamemode()
+	var sent [][]byte
+	gm.SetPlayerRPCSender(func(playerID uint16, rpcPayload []byte, reliability byte) {
+		sent = append(sent, rpcPayload)
+	})
+	em := events.NewEventManager()
+	gm.RegisterEvents(em)
+	
+	em.Trigger(events.Event{Type: events.EventPlayerConnect, PlayerID: 5, Data: events.ConnectData{Name: "Eve"}})
+	if player, ok := gm.GetPlayer(5); !ok || player.Name != "Eve" {
+		t.Fatalf("Expected Eve added by the connect event")
+	}
+	
+	sent = nil
+	em.Trigger(events.Event{Type: events.EventPlayerCommand, PlayerID: 5, Data: events.CommandData{Command: "nosuchcommand"}})
+	unknown := protocol.BuildSendClientMessageRPC(protocol.ColorWhite, "SERVER: Unknown command.")
+	if len(sent) != 1 || !bytes.Equal(sent[0], unknown) {
+		t.Errorf("Expected the unknown command message, got %d RPCs", len(sent))
+	}
+	
+	em.Trigger(events.Event{Type: events.EventPlayerDisconnect, PlayerID: 5, Data: events.DisconnectData{Reason: server.DisconnectQuit}})
+	if _, ok := gm.GetPlayer(5); ok {
+		t.Error("Expected Eve removed by the disconnect event")
+	}
+}
+
+func TestSendMessageToAllReachesEveryPlayer(t *testing.T) {
+	gm := NewFreeroamGamemode()
+	gm.OnPlayerConnect(0, "First")
+	gm.OnPlayerConnect(7, "Second")
+	
+	received := make(map[uint16][]byte)
+	gm.SetPlayerRPCSender(func(playerID uint16, rpcPayload []byte, reliability byte) {
+		if reliability != protocol.RELIABLE_ORDERED {
+			t.Errorf("Expected RELIABLE_ORDERED, got %d", reliability)
+		}
+		received[playerID] = rpcPayload
+	})
+	
+	gm.SendMessageToAll(protocol.ColorYellow, "Welcome")
+	
+	expected := protocol.BuildSendClientMessageRPC(protocol.ColorYellow, "Welcome")
+	if len(received) != 2 || !bytes.Equal(received[0], expected) || !bytes.Equal(received[7], expected) {
+		t.Errorf("Expected the message sent to players 0 and 7, got %v", received)
+	}
+}
+
+func TestFixRepairsVehicleFromSync(t *testing.T) {
+	gm := NewFreeroamGamemode()
+	vs := systems.NewVehicleSystem()
+	gm.SetVehicleSystem(vs)
+	var sent [][]byte
+	gm.SetPlayerRPCSender(func(playerID uint16, rpcPayload []byte, reliability byte) {
+		sent = append(sent, rpcPayload)
+	})
+	em := events.NewEventManager()
+	gm.RegisterEvents(em)
+	em.Trigger(events.Event{Type: events.EventPlayerConnect, PlayerID: 2, Data: events.ConnectData{Name: "Driver"}})
+	
+	vehicleID := vs.SpawnVehicle(411, 0, 0, 3, 0, 1, 1, 2)
+	vs.SetVehicleHealth(vehicleID, 300)
+	
+	fix := func() []byte {
+		sent = nil
+		gm.OnPlayerCommand(2, "fix", nil)
+		if len(sent) != 1 {
+			t.Fatalf("Expected one reply to /fix, got %d RPCs", len(sent))
+		}
+		return sent[0]
+	}
+	
+	if reply := fix(); !bytes.Equal(reply, protocol.BuildSendClientMessageRPC(protocol.ColorWhite, "You are not in a vehicle")) {
+		t.Errorf("Expected /fix on foot to be refused")
+	}
+	
+	em.Trigger(events.Event{Type: events.EventPlayerVehicleChange, PlayerID: 2, Data: events.VehicleChangeData{VehicleID: vehicleID}})
+	if reply := fix(); !bytes.Equal(reply, protocol.BuildSendClientMessageRPC(protocol.ColorWhite, "Vehicle repaired")) {
+		t.Errorf("Expected /fix in a vehicle to repair it")
+	}
+	if vehicle, _ := vs.GetVehicle(vehicleID); vehicle.Health != systems.MaxVehicleHealth {
+		t.Errorf("Expected vehicle at full health, got %v", vehicle.Health)
+	}
+	
+	em.Trigger(events.Event{Type: events.EventPlayerVehicleChange, PlayerID: 2, Data: events.VehicleChangeData{}})
+	if reply := fix(); !bytes.Equal(reply, protocol.BuildSendClientMessageRPC(protocol.ColorWhite, "You are not in a vehicle")) {
+		t.Errorf("Expected /fix to be refused again after leaving the vehicle")
+	}
+}
+
+func TestChatShownToEveryPlayer(t *testing.T) {
+	gm := NewFreeroamGamemode()
+	got := make(map[uint16][]byte)
+	gm.SetPlayerRPCSender(func(playerID uint16, rpcPayload []byte, reliability byte) {
+		got[playerID] = rpcPayload
+	})
+	em := events.NewEventManager()
+	gm.RegisterEvents(em)
+	gm.OnPlayerConnect(1, "Talker")
+	gm.OnPlayerConnect(2, "Listener")
+	
+	em.Trigger(events.Event{Type: events.EventPlayerText, PlayerID: 1, Data: events.TextData{Text: "hi all"}})
+	
+	want := protocol.BuildChatRPC(1, "hi all")
+	for _, id := range []uint16{1, 2} {
+		if !bytes.Equal(got[id], want) {
+			t.Errorf("Expected player %d to get the chat line, got % X", id, got[id])
+		}
+	}
+}
+
+func TestConcurrentSpawnsShareRand(t *testing.T) {
+	gm := NewFreeroamGamemode()
+	for id := uint16(0); id < 4; id++ {
+		gm.OnPlayerConnect(id, "Spawner")
+	}
+	
+	// Run with -race: spawns arrive on different packet workers
+	var wg sync.WaitGroup
+	for id := uint16(0); id < 4; id++ {
+		wg.Add(1)
+		go func(id uint16) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				gm.OnPlayerSpawn(id)
+			}
+		}(id)
+	}
+	wg.Wait()
+}
+
+func TestSetPlayerNameUpdatesServerAndGamemode(t *testing.T) {
+	srv := server.NewServer("127.0.0.1", 0, 10)
+	gm := NewFreeroamGamemode()
+	gm.RegisterEvents(srv.Events)
+	gm.SetRenamer(srv.SetPlayerName)
+	
+	for i, name := range []string{"Alice", "Bob"} {
+		session := protocol.NewSession(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50000 + i}, 576)
+		session.Nickname = name
+		player := srv.AddPlayer(session)
+		srv.Events.Trigger(events.Event{Type: events.EventPlayerConnect, PlayerID: player.ID, Data: events.ConnectData{Name: name}})
+	}
+	
+	if result := gm.SetPlayerName(0, "Carol"); result != server.NameChangeSuccess {
+		t.Fatalf("Expected the rename to succeed, got %d", result)
+	}
+	if player, _ := gm.GetPlayer(0); player.Name != "Carol" {
+		t.Errorf("Expected the gamemode player renamed to Carol, got %s", player.Name)
+	}
+	if player, _ := srv.GetPlayer(0); player.Name != "Carol" {
+		t.Errorf("Expected the server player renamed to Carol, got %s", player.Name)
+	}
+	
+	if result := gm.SetPlayerName(0, "bob"); result != server.NameChangeTaken {
+		t.Errorf("Expected a taken name rejected, got %d", result)
+	}
+	if result := gm.SetPlayerName(0, "x"); result != server.NameChangeInvalid {
+		t.Errorf("Expected an invalid name rejected, got %d", result)
+	}
+	if player, _ := gm.GetPlayer(0); player.Name != "Carol" {
+		t.Errorf("Expected a rejected rename to keep Carol, got %s", player.Name)
+	}
+}
+
+func TestSetPlayerScoreUpdatesServerAndGamemode(t *testing.T) {
+	srv := server.NewServer("127.0.0.1", 0, 10)
+	gm := NewFreeroamGamemode()
+	gm.RegisterEvents(srv.Events)
+	gm.SetScorer(srv.SetPlayerScore)
+	
+	session := protocol.NewSession(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50000}, 576)
+	session.Nickname = "Alice"
+	player := srv.AddPlayer(session)
+	srv.Events.Trigger(events.Event{Type: events.EventPlayerConnect, PlayerID: player.ID, Data: events.ConnectData{Name: "Alice", Score: 5}})
+	if player, _ := gm.GetPlayer(0); player.Score != 5 {
+		t.Errorf("Expected the restored score 5 in the gamemode, got %d", player.Score)
+	}
+	
+	if err := gm.SetPlayerScore(0, 12); err != nil {
+		t.Fatalf("SetPlayerScore failed: %v", err)
+	}
+	if player, _ := gm.GetPlayer(0); player.Score != 12 {
+		t.Errorf("Expected the gamemode score 12, got %d", player.Score)
+	}
+	if player, _ := srv.GetPlayer(0); player.Score != 12 {
+		t.Errorf("Expected the server score 12, got %d", player.Score)
+	}
+	if err := gm.SetPlayerScore(7, 1); err == nil {
+		t.Error("Expected an error for an unknown player")
+	}
+}
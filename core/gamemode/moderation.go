@@ -0,0 +1,76 @@
+package gamemode
+
+import (
+	"crypto/subtle"
+	"log"
+	"strings"
+)
+
+// SetKicker sets the function that disconnects a player, e.g. Server.KickPlayer
+func (gm *FreeroamGamemode) SetKicker(kick func(playerID uint16, reason string) error) {
+	gm.kicker = kick
+}
+
+// SetBanner sets the function that bans and disconnects a player, e.g.
+// Server.BanPlayer
+func (gm *FreeroamGamemode) SetBanner(ban func(playerID uint16, admin, reason string) error) {
+	gm.banner = ban
+}
+
+// SetAdminPassword lets players become admins of the given level with
+// "/login <password>". An empty password disables it.
+func (gm *FreeroamGamemode) SetAdminPassword(password string, level int) {
+	gm.adminPassword = password
+	gm.adminLoginLevel = level
+}
+
+func (gm *FreeroamGamemode) cmdLogin(player *Player, args []string) string {
+	if gm.adminPassword == "" {
+		return "Admin login is disabled"
+	}
+	if len(args) != 1 {
+		return "Usage: /login [password]"
+	}
+	if subtle.ConstantTimeCompare([]byte(args[0]), []byte(gm.adminPassword)) != 1 {
+		log.Printf("⚠️ [Gamemode] Failed admin login by %s (ID: %d)", player.Name, player.ID)
+		return "Wrong password"
+	}
+	
+	gm.SetAdminLevel(player.ID, gm.adminLoginLevel)
+	log.Printf("🔑 [Gamemode] %s (ID: %d) logged in as level %d admin", player.Name, player.ID, gm.adminLoginLevel)
+	return "You are now logged in as an admin"
+}
+
+func (gm *FreeroamGamemode) cmdKick(player *Player, args []string) string {
+	target, msg := gm.adminTarget(player, args, "Usage: /kick [playerid] [reason]")
+	if target == nil {
+		return msg
+	}
+	if gm.kicker == nil {
+		return "Kicking is not available"
+	}
+	
+	reason := strings.Join(args[1:], " ")
+	if err := gm.kicker(target.ID, reason); err != nil {
+		log.Printf("⚠️ [Gamemode] /kick %d by %s failed: %v", target.ID, player.Name, err)
+		return "Kick failed"
+	}
+	return target.Name + " was kicked"
+}
+
+func (gm *FreeroamGamemode) cmdBan(player *Player, args []string) string {
+	target, msg := gm.adminTarget(player, args, "Usage: /ban [playerid] [reason]")
+	if target == nil {
+		return msg
+	}
+	if gm.banner == nil {
+		return "Banning is not available"
+	}
+	
+	reason := strings.Join(args[1:], " ")
+	if err := gm.banner(target.ID, player.Name, reason); err != nil {
+		log.Printf("⚠️ [Gamemode] /ban %d by %s failed: %v", target.ID, player.Name, err)
+		return "Ban failed"
+	}
+	return target.Name + " was banned"
+}
@@ -0,0 +1,126 @@
+package gamemode
+
+import (
+	"errors"
+	"net"
+	"samp-server-go/core/events"
+	"samp-server-go/source/protocol"
+	"samp-server-go/source/server"
+	"testing"
+)
+
+func TestBanCommandBansTargetAsAdmin(t *testing.T) {
+	gm := NewFreeroamGamemode()
+	gm.OnPlayerConnect(0, "Admin")
+	gm.OnPlayerConnect(1, "Cheater")
+	gm.SetAdminLevel(0, 2)
+	
+	var banned uint16
+	var admin, reason string
+	gm.SetBanner(func(playerID uint16, by, why string) error {
+		banned, admin, reason = playerID, by, why
+		return nil
+	})
+	
+	if !gm.OnPlayerCommand(0, "ban", []string{"1", "speed", "hack"}) {
+		t.Fatal("Expected /ban to be handled")
+	}
+	if banned != 1 || admin != "Admin" || reason != "speed hack" {
+		t.Errorf("Expected player 1 banned by Admin for \"speed hack\", got %d by %q for %q", banned, admin, reason)
+	}
+}
+
+func TestKickCommandValidatesTarget(t *testing.T) {
+	gm := NewFreeroamGamemode()
+	gm.OnPlayerConnect(0, "Admin")
+	gm.OnPlayerConnect(1, "Target")
+	
+	kicks := make([]uint16, 0)
+	gm.SetKicker(func(playerID uint16, reason string) error {
+		kicks = append(kicks, playerID)
+		return nil
+	})
+	admin, _ := gm.GetPlayer(0)
+	
+	if msg := gm.cmdKick(admin, nil); msg != "Usage: /kick [playerid] [reason]" {
+		t.Errorf("Expected usage without a target, got %q", msg)
+	}
+	if msg := gm.cmdKick(admin, []string{"0"}); msg != "You can't use this command on yourself" {
+		t.Errorf("Expected self-kick refused, got %q", msg)
+	}
+	if msg := gm.cmdKick(admin, []string{"1"}); msg != "Target was kicked" {
+		t.Errorf("Expected Target kicked, got %q", msg)
+	}
+	if len(kicks) != 1 || kicks[0] != 1 {
+		t.Errorf("Expected exactly player 1 kicked, got %v", kicks)
+	}
+	
+	gm.SetKicker(func(playerID uint16, reason string) error { return errors.New("gone") })
+	if msg := gm.cmdKick(admin, []string{"1"}); msg != "Kick failed" {
+		t.Errorf("Expected a failed kick reported, got %q", msg)
+	}
+}
+
+func TestKickRemovesPlayerFromGamemode(t *testing.T) {
+	srv := server.NewServer("127.0.0.1", 0, 10)
+	gm := NewFreeroamGamemode()
+	gm.RegisterEvents(srv.Events)
+	gm.SetKicker(srv.KickPlayer)
+	
+	for i, name := range []string{"Admin", "Target"} {
+		session := protocol.NewSession(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50000 + i}, 576)
+		session.Nickname = name
+		player := srv.AddPlayer(session)
+		srv.Events.Trigger(events.Event{Type: events.EventPlayerConnect, PlayerID: player.ID, Data: events.ConnectData{Name: name}})
+	}
+	gm.SetAdminLevel(0, 1)
+	
+	if !gm.OnPlayerCommand(0, "kick", []string{"1", "afk"}) {
+		t.Fatal("Expected /kick to be handled")
+	}
+	if _, exists := srv.GetPlayer(1); exists {
+		t.Error("Expected the kicked player removed from the server")
+	}
+	if _, exists := gm.GetPlayer(1); exists {
+		t.Error("Expected the kicked player removed from the gamemode")
+	}
+	if _, exists := gm.GetPlayer(0); !exists {
+		t.Error("Expected the admin to stay")
+	}
+}
+
+func TestLoginGrantsAdminLevel(t *testing.T) {
+	gm := NewFreeroamGamemode()
+	gm.OnPlayerConnect(0, "Admin")
+	gm.OnPlayerConnect(1, "Target")
+	kicks := make([]uint16, 0)
+	gm.SetKicker(func(playerID uint16, reason string) error {
+		kicks = append(kicks, playerID)
+		return nil
+	})
+	admin, _ := gm.GetPlayer(0)
+	
+	if msg := gm.cmdLogin(admin, []string{"secret"}); msg != "Admin login is disabled" {
+		t.Errorf("Expected login disabled without a password, got %q", msg)
+	}
+	
+	gm.SetAdminPassword("secret", 1)
+	if msg := gm.cmdLogin(admin, []string{"guess"}); msg != "Wrong password" || admin.IsAdmin {
+		t.Errorf("Expected a wrong password refused, got %q", msg)
+	}
+	gm.OnPlayerCommand(0, "kick", []string{"1"})
+	if len(kicks) != 0 {
+		t.Fatal("Expected /kick refused before logging in")
+	}
+	
+	if !gm.OnPlayerCommand(0, "login", []string{"secret"}) {
+		t.Fatal("Expected /login to be handled")
+	}
+	if admin.AdminLevel != 1 || !admin.IsAdmin {
+		t.Fatalf("Expected admin level 1 after logging in, got %d", admin.AdminLevel)
+	}
+	gm.OnPlayerCommand(0, "kick", []string{"1"})
+	if len(kicks) != 1 || kicks[0] != 1 {
+		t.Errorf("Expected /kick to work after logging in, got %v", kicks)
+	}
+}
@@ -0,0 +1,130 @@
+package gamemode
+
+import (
+	"fmt"
+	"log"
+	"samp-server-go/source/protocol"
+	"strconv"
+	"time"
+)
+
+// DefaultTeleportFreeze gives the client time to stream in the map collision
+// at the destination, so a teleported player doesn't fall through it
+const DefaultTeleportFreeze = 1500 * time.Millisecond
+
+// SetTeleporter sets the function that moves a player on the server, e.g.
+// Server.TeleportPlayer
+func (gm *FreeroamGamemode) SetTeleporter(teleport func(playerID uint16, x, y, z, angle float32) error) {
+	gm.teleporter = teleport
+}
+
+// SetPositionSource sets the function that reads a player's live position on
+// the server, e.g. Server.PlayerPosition. Without one the gamemode's copy,
+// which only spawns and teleports update, is used.
+func (gm *FreeroamGamemode) SetPositionSource(source func(playerID uint16) (x, y, z, angle float32, err error)) {
+	gm.positionOf = source
+}
+
+// SetTeleportFreeze sets how long teleported players stay frozen (0 = not frozen)
+func (gm *FreeroamGamemode) SetTeleportFreeze(d time.Duration) {
+	gm.teleportFreeze = d
+}
+
+// teleport moves player to pos facing angle. The player is frozen first and
+// made controllable again after teleportFreeze.
+func (gm *FreeroamGamemode) teleport(player *Player, pos Vector3, angle float32) error {
+	freeze := gm.teleportFreeze > 0 && gm.sendPlayerRPC != nil
+	if freeze {
+		gm.sendPlayerRPC(player.ID, protocol.BuildTogglePlayerControllableRPC(false), protocol.RELIABLE_ORDERED)
+	}
+	
+	if gm.teleporter != nil {
+		if err := gm.teleporter(player.ID, pos.X, pos.Y, pos.Z, angle); err != nil {
+			if freeze {
+				gm.sendPlayerRPC(player.ID, protocol.BuildTogglePlayerControllableRPC(true), protocol.RELIABLE_ORDERED)
+			}
+			return err
+		}
+	}
+	
+	gm.mu.Lock()
+	player.Position = pos
+	player.Rotation = angle
+	gm.mu.Unlock()
+	
+	if freeze {
+		playerID := player.ID
+		time.AfterFunc(gm.teleportFreeze, func() {
+			if _, exists := gm.GetPlayer(playerID); exists {
+				gm.sendPlayerRPC(playerID, protocol.BuildTogglePlayerControllableRPC(true), protocol.RELIABLE_ORDERED)
+			}
+		})
+	}
+	return nil
+}
+
+// position returns where player is now and the way they face
+func (gm *FreeroamGamemode) position(player *Player) (Vector3, float32) {
+	if gm.positionOf != nil {
+		if x, y, z, angle, err := gm.positionOf(player.ID); err == nil {
+			return Vector3{x, y, z}, angle
+		}
+	}
+	
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+	return player.Position, player.Rotation
+}
+
+// adminTarget resolves the [playerid] argument of an admin command. The
+// admin can't target themselves.
+func (gm *FreeroamGamemode) adminTarget(admin *Player, args []string, usage string) (*Player, string) {
+	if len(args) < 1 {
+		return nil, usage
+	}
+	
+	id, err := strconv.Atoi(args[0])
+	if err != nil || id < 0 || id > 0xFFFF {
+		return nil, usage
+	}
+	
+	target, exists := gm.GetPlayer(uint16(id))
+	if !exists {
+		return nil, fmt.Sprintf("Player %d is not connected", id)
+	}
+	if target == admin {
+		return nil, "You can't use this command on yourself"
+	}
+	return target, ""
+}
+
+func (gm *FreeroamGamemode) cmdGoto(player *Player, args []string) string {
+	target, msg := gm.adminTarget(player, args, "Usage: /goto [playerid]")
+	if target == nil {
+		return msg
+	}
+	
+	pos, angle := gm.position(target)
+	
+	if err := gm.teleport(player, pos, angle); err != nil {
+		log.Printf("⚠️ [Gamemode] /goto %d by %s failed: %v", target.ID, player.Name, err)
+		return "Teleport failed"
+	}
+	return "Teleported to " + target.Name
+}
+
+func (gm *FreeroamGamemode) cmdGetHere(player *Player, args []string) string {
+	target, msg := gm.adminTarget(player, args, "Usage: /gethere [playerid]")
+	if target == nil {
+		return msg
+	}
+	
+	pos, angle := gm.position(player)
+	
+	if err := gm.teleport(target, pos, angle); err != nil {
+		log.Printf("⚠️ [Gamemode] /gethere %d by %s failed: %v", target.ID, player.Name, err)
+		return "Teleport failed"
+	}
+	gm.SendMessageToPlayer(target.ID, protocol.ColorWhite, "You have been teleported to "+player.Name)
+	return target.Name + " teleported to you"
+}
@@ -0,0 +1,166 @@
+package gamemode
+
+import (
+	"samp-server-go/source/protocol"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type teleportCall struct {
+	playerID uint16
+	pos      Vector3
+	angle    float32
+}
+
+// newTeleportTest connects an admin (0) and a target (1) at different positions
+func newTeleportTest(t *testing.T) (*FreeroamGamemode, *[]teleportCall) {
+	t.Helper()
+	gm := NewFreeroamGamemode()
+	gm.SetTeleportFreeze(0)
+	
+	calls := make([]teleportCall, 0)
+	gm.SetTeleporter(func(playerID uint16, x, y, z, angle float32) error {
+		calls = append(calls, teleportCall{playerID, Vector3{x, y, z}, angle})
+		return nil
+	})
+	
+	gm.OnPlayerConnect(0, "Admin")
+	gm.OnPlayerConnect(1, "Target")
+	gm.SetAdminLevel(0, 1)
+	
+	admin, _ := gm.GetPlayer(0)
+	admin.Position, admin.Rotation = Vector3{100, 200, 10}, 90
+	target, _ := gm.GetPlayer(1)
+	target.Position, target.Rotation = Vector3{-50, 25, 15}, 180
+	return gm, &calls
+}
+
+func TestGotoMovesAdminToTarget(t *testing.T) {
+	gm, calls := newTeleportTest(t)
+	
+	if !gm.OnPlayerCommand(0, "goto", []string{"1"}) {
+		t.Fatal("Expected /goto to be handled")
+	}
+	
+	expected := teleportCall{0, Vector3{-50, 25, 15}, 180}
+	if len(*calls) != 1 || (*calls)[0] != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, *calls)
+	}
+	if admin, _ := gm.GetPlayer(0); admin.Position != expected.pos {
+		t.Errorf("Expected admin at %v, got %v", expected.pos, admin.Position)
+	}
+	if target, _ := gm.GetPlayer(1); target.Position != (Vector3{-50, 25, 15}) {
+		t.Errorf("Expected target to stay put, got %v", target.Position)
+	}
+}
+
+func TestGetHereMovesTargetToAdmin(t *testing.T) {
+	gm, calls := newTeleportTest(t)
+	
+	gm.OnPlayerCommand(0, "gethere", []string{"1"})
+	
+	expected := teleportCall{1, Vector3{100, 200, 10}, 90}
+	if len(*calls) != 1 || (*calls)[0] != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, *calls)
+	}
+	if target, _ := gm.GetPlayer(1); target.Position != expected.pos {
+		t.Errorf("Expected target at %v, got %v", expected.pos, target.Position)
+	}
+	if admin, _ := gm.GetPlayer(0); admin.Position != (Vector3{100, 200, 10}) {
+		t.Errorf("Expected admin to stay put, got %v", admin.Position)
+	}
+}
+
+func TestTeleportCommandsUseLivePositions(t *testing.T) {
+	gm, calls := newTeleportTest(t)
+	
+	// Both players moved since they spawned; the server has their synced positions
+	live := map[uint16]teleportCall{
+		0: {0, Vector3{300, 400, 20}, 45},
+		1: {1, Vector3{-700, 800, 30}, 270},
+	}
+	gm.SetPositionSource(func(playerID uint16) (x, y, z, angle float32, err error) {
+		pos := live[playerID]
+		return pos.pos.X, pos.pos.Y, pos.pos.Z, pos.angle, nil
+	})
+	
+	gm.OnPlayerCommand(0, "goto", []string{"1"})
+	gm.OnPlayerCommand(0, "gethere", []string{"1"})
+	
+	expected := []teleportCall{{0, live[1].pos, live[1].angle}, {1, live[0].pos, live[0].angle}}
+	if len(*calls) != 2 || (*calls)[0] != expected[0] || (*calls)[1] != expected[1] {
+		t.Errorf("Expected %+v, got %+v", expected, *calls)
+	}
+}
+
+func TestHelpListsTeleportAndLoginCommands(t *testing.T) {
+	gm, _ := newTeleportTest(t)
+	admin, _ := gm.GetPlayer(0)
+	
+	help := gm.cmdHelp(admin, nil)
+	for _, command := range []string{"/goto", "/gethere", "/login"} {
+		if !strings.Contains(help, command) {
+			t.Errorf("Expected /help to list %s, got %q", command, help)
+		}
+	}
+}
+
+func TestTeleportCommandsRejectBadTargets(t *testing.T) {
+	gm, calls := newTeleportTest(t)
+	
+	for _, args := range [][]string{nil, {"x"}, {"7"}, {"0"}} {
+		gm.OnPlayerCommand(0, "goto", args)
+		gm.OnPlayerCommand(0, "gethere", args)
+	}
+	
+	// Non-admins and admins below the command's level are refused
+	gm.OnPlayerCommand(1, "goto", []string{"0"})
+	gm.SetAdminLevel(1, 1)
+	gm.adminCommands["gethere"] = AdminCommand{Name: "gethere", MinLevel: 2, Handler: gm.cmdGetHere}
+	gm.OnPlayerCommand(1, "gethere", []string{"0"})
+	
+	if len(*calls) != 0 {
+		t.Errorf("Expected no teleports, got %+v", *calls)
+	}
+}
+
+func TestTeleportFreezesPlayer(t *testing.T) {
+	gm, _ := newTeleportTest(t)
+	gm.SetTeleportFreeze(DefaultTeleportFreeze)
+	
+	rpcs := make([][]byte, 0)
+	gm.SetPlayerRPCSender(func(playerID uint16, rpcPayload []byte, reliability byte) {
+		if playerID == 0 {
+			rpcs = append(rpcs, rpcPayload)
+		}
+	})
+	
+	gm.OnPlayerCommand(0, "goto", []string{"1"})
+	
+	if expected := protocol.BuildTogglePlayerControllableRPC(false); len(rpcs) == 0 || string(rpcs[0]) != string(expected) {
+		t.Errorf("Expected a freeze before the teleport, got %02X", rpcs)
+	}
+}
+
+func TestGotoWhileTargetSpawns(t *testing.T) {
+	gm, _ := newTeleportTest(t)
+	
+	// Run with -race: the target's spawn and the admin's /goto arrive on
+	// different packet workers
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			gm.OnPlayerSpawn(1)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			gm.OnPlayerCommand(0, "goto", []string{"1"})
+		}
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,104 @@
+package gamemode
+
+import (
+	"log"
+	"samp-server-go/source/protocol"
+)
+
+// WeaponSlots is the number of weapon slots a player has
+const WeaponSlots = 13
+
+// WeaponSlot is the weapon held in one slot and its ammo
+type WeaponSlot struct {
+	WeaponID int
+	Ammo     int
+}
+
+// weaponSlot returns the slot a weapon occupies (as GetWeaponSlot), or -1 for invalid IDs
+func weaponSlot(weaponID int) int {
+	switch {
+	case weaponID == 0 || weaponID == 1:
+		return 0 // fist, brass knuckles
+	case weaponID >= 2 && weaponID <= 9:
+		return 1 // melee
+	case weaponID >= 10 && weaponID <= 15:
+		return 10 // gifts
+	case weaponID >= 16 && weaponID <= 18, weaponID == 39:
+		return 8 // thrown
+	case weaponID >= 22 && weaponID <= 24:
+		return 2 // pistols
+	case weaponID >= 25 && weaponID <= 27:
+		return 3 // shotguns
+	case weaponID == 28 || weaponID == 29 || weaponID == 32:
+		return 4 // sub-machine guns
+	case weaponID == 30 || weaponID == 31:
+		return 5 // assault rifles
+	case weaponID == 33 || weaponID == 34:
+		return 6 // rifles
+	case weaponID >= 35 && weaponID <= 38:
+		return 7 // heavy weapons
+	case weaponID == 40:
+		return 12 // detonator
+	case weaponID >= 41 && weaponID <= 43:
+		return 9 // spraycan, extinguisher, camera
+	case weaponID >= 44 && weaponID <= 46:
+		return 11 // goggles, parachute
+	}
+	return -1
+}
+
+// SetPlayerRPCSender sets the function used to send RPCs to a single player
+// with the given reliability
+func (gm *FreeroamGamemode) SetPlayerRPCSender(sender func(playerID uint16, rpcPayload []byte, reliability byte)) {
+	gm.sendPlayerRPC = sender
+}
+
+// GiveWeapon gives a player a weapon like GivePlayerWeapon: ammo accumulates when
+// the slot already holds the same weapon, a different weapon in the slot is replaced
+func (gm *FreeroamGamemode) GiveWeapon(playerID uint16, weaponID int, ammo int) bool {
+	slot := weaponSlot(weaponID)
+	if slot < 0 || ammo < 0 {
+		log.Printf("⚠️ [Gamemode] Invalid weapon %d (ammo %d) for player %d", weaponID, ammo, playerID)
+		return false
+	}
+	
+	gm.mu.Lock()
+	player, exists := gm.players[playerID]
+	if !exists {
+		gm.mu.Unlock()
+		return false
+	}
+	current := &player.Weapons[slot]
+	if current.WeaponID == weaponID && current.Ammo > 0 {
+		current.Ammo += ammo
+	} else {
+		current.WeaponID = weaponID
+		current.Ammo = ammo
+	}
+	gm.mu.Unlock()
+	
+	// The client adds the given ammo to what it already has, so send the delta
+	if gm.sendPlayerRPC != nil {
+		gm.sendPlayerRPC(playerID, protocol.BuildGivePlayerWeaponRPC(uint32(weaponID), uint32(ammo)), protocol.RELIABLE_ORDERED)
+	}
+	return true
+}
+
+// GetPlayerWeapons returns a player's non-empty weapon slots in slot order
+func (gm *FreeroamGamemode) GetPlayerWeapons(playerID uint16) []WeaponSlot {
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+	
+	player, exists := gm.players[playerID]
+	if !exists {
+		return nil
+	}
+	
+	weapons := make([]WeaponSlot, 0, WeaponSlots)
+	for _, weapon := range player.Weapons {
+		if weapon.WeaponID != 0 || weapon.Ammo > 0 {
+			weapons = append(weapons, weapon)
+		}
+	}
+	return weapons
+}
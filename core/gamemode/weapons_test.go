@@ -0,0 +1,94 @@
+package gamemode
+
+import (
+	"samp-server-go/source/protocol"
+	"sync"
+	"testing"
+)
+
+func newWeaponsTestGamemode() (*FreeroamGamemode, *[][]byte) {
+	gm := NewFreeroamGamemode()
+	gm.OnPlayerConnect(0, "Tester")
+	
+	sent := make([][]byte, 0)
+	gm.SetPlayerRPCSender(func(playerID uint16, rpcPayload []byte, reliability byte) {
+		sent = append(sent, rpcPayload)
+	})
+	return gm, &sent
+}
+
+func TestGiveWeaponAccumulatesAmmo(t *testing.T) {
+	gm, sent := newWeaponsTestGamemode()
+	
+	gm.GiveWeapon(0, 24, 50) // Desert Eagle
+	gm.GiveWeapon(0, 24, 30)
+	
+	weapons := gm.GetPlayerWeapons(0)
+	if len(weapons) != 1 || weapons[0] != (WeaponSlot{WeaponID: 24, Ammo: 80}) {
+		t.Errorf("Expected Desert Eagle with 80 ammo, got %+v", weapons)
+	}
+	
+	if len(*sent) != 2 || string((*sent)[1]) != string(protocol.BuildGivePlayerWeaponRPC(24, 30)) {
+		t.Errorf("Expected GivePlayerWeapon RPCs for each give, got %02X", *sent)
+	}
+}
+
+func TestGiveWeaponReplacesWithinSlot(t *testing.T) {
+	gm, _ := newWeaponsTestGamemode()
+	
+	gm.GiveWeapon(0, 22, 100) // 9mm, slot 2
+	gm.GiveWeapon(0, 31, 200) // M4, slot 5
+	gm.GiveWeapon(0, 24, 7)   // Desert Eagle replaces the 9mm
+	
+	weapons := gm.GetPlayerWeapons(0)
+	expected := []WeaponSlot{{WeaponID: 24, Ammo: 7}, {WeaponID: 31, Ammo: 200}}
+	if len(weapons) != len(expected) {
+		t.Fatalf("Expected %d weapons, got %+v", len(expected), weapons)
+	}
+	for i := range expected {
+		if weapons[i] != expected[i] {
+			t.Errorf("Slot %d: expected %+v, got %+v", i, expected[i], weapons[i])
+		}
+	}
+}
+
+func TestGiveWeaponRejectsInvalid(t *testing.T) {
+	gm, sent := newWeaponsTestGamemode()
+	
+	if gm.GiveWeapon(0, 20, 10) {
+		t.Error("Expected weapon 20 to be rejected")
+	}
+	if gm.GiveWeapon(1, 24, 10) {
+		t.Error("Expected unknown player to be rejected")
+	}
+	if len(*sent) != 0 {
+		t.Errorf("Expected no RPCs, got %d", len(*sent))
+	}
+}
+
+func TestGiveWeaponAndDeathConcurrently(t *testing.T) {
+	gm := NewFreeroamGamemode()
+	gm.OnPlayerConnect(0, "Tester")
+	
+	// Run with -race: a command and a death arrive on different packet workers
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			gm.GiveWeapon(0, 24, 10)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			gm.OnPlayerDeath(0)
+		}
+	}()
+	wg.Wait()
+	
+	gm.OnPlayerDeath(0)
+	if weapons := gm.GetPlayerWeapons(0); len(weapons) != 0 {
+		t.Errorf("Expected no weapons after death, got %v", weapons)
+	}
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"math/rand"
 	"os"
 	"os/signal"
 	"samp-server-go/core/gamemode"
+	"samp-server-go/core/systems"
 	"samp-server-go/pkg/logger"
+	"samp-server-go/source/protocol"
 	"samp-server-go/source/server"
 	"syscall"
 	"time"
@@ -20,20 +23,80 @@ func main() {
 	
 	// Load configuration
 	config := loadConfig()
+	level, err := logger.ParseLevel(config.LogLevel)
+	if err != nil {
+		logger.Fatal("Invalid log level: %v", err)
+	}
+	logger.SetLevel(level)
 	
 	// Initialize gamemode
 	gm := gamemode.NewFreeroamGamemode()
+	if config.RandomSeed != 0 {
+		gm.SetRand(rand.New(rand.NewSource(config.RandomSeed)))
+		logger.Info("Gamemode random seed: %d", config.RandomSeed)
+	}
 	logger.Success("Gamemode initialized: Freeroam")
 	
 	// Create server instance
 	srv := server.NewServer(config.Host, config.Port, config.MaxPlayers)
+	srv.ListenAddrs = config.ListenAddrs
 	srv.ServerName = config.ServerName
 	srv.GameMode = config.GameMode
 	srv.Language = config.Language
 	srv.Weather = config.Weather
 	srv.WorldTime = config.WorldTime
+	srv.Gravity = config.Gravity
+	srv.TimeCycleEnabled = config.TimeCycle
+	srv.TimeCycleInterval = config.TimeCycleInterval
+	srv.WeatherInterval = config.WeatherInterval
+	srv.WeatherRandom = config.WeatherRandom
+	if config.RandomSeed != 0 {
+		srv.SetRand(rand.New(rand.NewSource(config.RandomSeed)))
+	}
+	if err := srv.SetWeatherRotation(config.WeatherRotation); err != nil {
+		logger.Fatal("Invalid weather rotation: %v", err)
+	}
+	srv.ArmourRegenRate = config.ArmourRegenRate
+	srv.HealthRegenRate = config.HealthRegenRate
+	srv.SpawnProtection = config.SpawnProtection
+	srv.AFKTimeout = config.AFKTimeout
+	srv.AFKMessage = config.AFKMessage
+	srv.SyncRate = config.SyncRate
+	srv.ShowNameTags = config.ShowNameTags
+	srv.NameTagDrawDistance = config.NameTagDrawDistance
+	srv.NameTagsIgnoreLOS = config.NameTagsIgnoreLOS
+	if err := srv.SetPlayerMarkers(config.PlayerMarkers); err != nil {
+		logger.Fatal("Invalid player markers: %v", err)
+	}
 	srv.MapName = config.MapName
 	srv.WebURL = config.WebURL
+	srv.SetFileConfig(config.liveConfig())
+	srv.Password = config.Password
+	srv.SupportedVersions = config.SupportedVersions
+	srv.MaxMTU = config.MaxMTU
+	srv.QueryCacheTTL = config.QueryCacheTTL
+	srv.QueryRateLimit = config.QueryRateLimit
+	srv.QueryBudget = config.QueryBudget
+	srv.MaxLimiterSources = config.MaxLimiterSources
+	srv.ConnectRateLimit = config.ConnectRateLimit
+	srv.ConnectRateWindow = config.ConnectRateWindow
+	srv.MaxHalfOpenSessions = config.MaxHalfOpenSessions
+	srv.HandshakeTimeout = config.HandshakeTimeout
+	srv.PacketWorkers = config.PacketWorkers
+	srv.MaxQueryResponseSize = config.MaxQueryResponseSize
+	srv.MOTD = config.MOTD
+	srv.PanicThrough = config.PanicThrough
+	srv.BanFile = config.BanFile
+	srv.StatsFile = config.StatsFile
+	srv.AutosaveInterval = config.AutosaveInterval
+	if config.AuditLogPath != "" {
+		auditFile, err := os.OpenFile(config.AuditLogPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			logger.Fatal("Failed to open audit log: %v", err)
+		}
+		srv.AuditLog = server.NewAuditLog(auditFile)
+		logger.Info("Audit log: %s", config.AuditLogPath)
+	}
 	
 	logger.Info("Server Version: %s", VERSION)
 	logger.Info("Starting server on %s:%d", srv.Host, srv.Port)
@@ -43,10 +106,63 @@ func main() {
 	logger.Info("Language: %s", srv.Language)
 	logger.Info("Weather: %d", srv.Weather)
 	logger.Info("World time: %d:00", srv.WorldTime)
+	logger.Info("Gravity: %.4f", srv.Gravity)
+	if srv.TimeCycleEnabled {
+		logger.Info("Time cycle: 1 hour every %s", srv.TimeCycleInterval)
+	}
+	if len(config.WeatherRotation) > 0 {
+		logger.Info("Weather rotation: %v every %s (random: %v)", config.WeatherRotation, srv.WeatherInterval, srv.WeatherRandom)
+	}
 	logger.Info("Map name: %s", srv.MapName)
 	logger.Info("Web URL: %s", srv.WebURL)
+	if srv.Password != "" {
+		logger.Info("Password protected: yes")
+	}
 	logger.Success("Configuration loaded successfully")
 	
+	// Vehicle system syncs colors/paintjobs to the players that see the vehicle
+	vehicles := systems.NewVehicleSystem()
+	vehicles.SetRPCSender(srv.SendVehicleRPC)
+	gm.SetVehicleSystem(vehicles)
+	srv.SetVehicleLookup(func(vehicleID uint16) bool {
+		_, exists := vehicles.GetVehicle(vehicleID)
+		return exists
+	})
+	srv.SetVehiclePositionSource(func() []server.VehiclePosition {
+		positions := make([]server.VehiclePosition, 0, vehicles.GetVehicleCount())
+		vehicles.ForEachVehicle(func(vehicle *systems.VehicleData) {
+			positions = append(positions, server.VehiclePosition{ID: vehicle.ID, X: vehicle.X, Y: vehicle.Y, Z: vehicle.Z})
+		})
+		return positions
+	})
+	vehicles.SetSpawnHandler(srv.AddVehicle)
+	vehicles.SetDestroyHandler(srv.RemoveVehicle)
+	srv.SetVehicleCreator(func(vehicleID uint16) []byte {
+		rpc, _ := vehicles.CreateVehicleRPC(vehicleID)
+		return rpc
+	})
+	if config.VehicleFile != "" {
+		if err := vehicles.LoadFromFile(config.VehicleFile); err != nil {
+			logger.Fatal("Failed to load static vehicles: %v", err)
+		}
+		logger.Info("Static vehicles: %d from %s", vehicles.GetVehicleCount(), config.VehicleFile)
+	}
+	gm.SetPlayerRPCSender(func(playerID uint16, rpcPayload []byte, reliability byte) {
+		if err := srv.SendRPCToPlayer(playerID, rpcPayload, reliability); err != nil {
+			logger.Warn("RPC to player %d failed: %v", playerID, err)
+		}
+	})
+	gm.SetTeleporter(srv.TeleportPlayer)
+	gm.SetPositionSource(srv.PlayerPosition)
+	gm.SetKicker(srv.KickPlayer)
+	gm.SetBanner(srv.BanPlayer)
+	gm.SetRenamer(srv.SetPlayerName)
+	gm.SetScorer(srv.SetPlayerScore)
+	gm.SetAdminPassword(config.AdminPassword, config.AdminLevel)
+	if config.AdminPassword == "" {
+		logger.Warn("No admin password set; admin commands are unavailable (set SAMP_ADMIN_PASSWORD)")
+	}
+	
 	// Setup event handlers
 	setupGamemodeEvents(srv, gm)
 	
@@ -54,6 +170,15 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 	
+	// SIGUSR1 logs runtime stats
+	statsChan := make(chan os.Signal, 1)
+	signal.Notify(statsChan, syscall.SIGUSR1)
+	go func() {
+		for range statsChan {
+			logger.Info("Stats: %s", srv.Stats())
+		}
+	}()
+	
 	// Start server in goroutine
 	errChan := make(chan error, 1)
 	go func() {
@@ -70,12 +195,9 @@ func main() {
 		logger.Warn("Received signal: %v", sig)
 		logger.Info("Shutting down gracefully...")
 		
-		// Stop server
+		// Stop server (waits for its loops to exit)
 		srv.Stop()
 		
-		// Wait a bit for cleanup
-		time.Sleep(1 * time.Second)
-		
 		logger.Success("Server stopped")
 		os.Exit(0)
 	}
@@ -84,35 +206,135 @@ func main() {
 type Config struct {
 	Host       string
 	Port       int
+	ListenAddrs []string // e.g. ["0.0.0.0:7777", "[::]:7777"], empty = Host:Port
+	MaxMTU     uint16 // handshake MTU ceiling, at least 576 (raise for LAN jumbo frames)
+	QueryCacheTTL time.Duration // reuse server browser info/rules responses this long, 0 = off
+	QueryRateLimit       int // queries/sec answered per source IP, 0 = unlimited
+	QueryBudget          int // queries/sec answered from all IPs together, 0 = unlimited
+	MaxLimiterSources    int // IPs tracked by the query and connect limiters, 0 = no cap
+	MaxQueryResponseSize int // bytes, larger query responses are not sent, 0 = no cap
+	ConnectRateLimit     int // new connection attempts per ConnectRateWindow from one IP, 0 = unlimited
+	ConnectRateWindow    time.Duration
+	MaxHalfOpenSessions  int // handshakes in flight before new attempts are dropped, 0 = unlimited
+	HandshakeTimeout     time.Duration // half-open handshakes idle this long are dropped
+	PacketWorkers        int // goroutines handling inbound packets
 	MaxPlayers int
 	ServerName string
 	GameMode   string
 	Language   string
 	Weather    int
 	WorldTime  int
+	Gravity    float32
+	TimeCycle  bool
+	TimeCycleInterval time.Duration
+	WeatherRotation []int
+	WeatherInterval time.Duration
+	WeatherRandom   bool
+	ArmourRegenRate float32 // points per second, 0 = off
+	HealthRegenRate float32 // points per second, 0 = off
+	SpawnProtection time.Duration
+	AFKTimeout      time.Duration // mark players AFK after this long without input, 0 = off
+	AFKMessage      string        // sent to players going AFK, empty = none
+	SyncRate        int // relayed sync updates/sec per observed player, 0 = unlimited
+	ShowNameTags        bool
+	NameTagDrawDistance float32 // meters
+	NameTagsIgnoreLOS   bool    // show name tags through walls
+	PlayerMarkers       int     // radar markers: 0 = off, 1 = global, 2 = streamed
 	MapName    string
 	WebURL     string
+	Password   string
+	AdminPassword string // "/login <password>" makes a player an admin of AdminLevel, empty = disabled (env SAMP_ADMIN_PASSWORD overrides)
+	AdminLevel    int
+	SupportedVersions []string // client versions allowed to join, empty = any
+	MOTD       []string // sent line by line after first spawn, "{RRGGBB}" color codes allowed, empty = "Welcome to <ServerName>!"
+	RandomSeed int64 // gamemode and random weather, 0 = seed from current time
+	AuditLogPath string // JSON-lines connection audit log, empty = disabled
+	LogLevel     string // debug, info, warn or error (env SAMP_LOG_LEVEL overrides)
+	PanicThrough bool   // let gamemode callback panics crash the server, for debugging
+	BanFile      string // ban list, loaded on start and saved on autosave/shutdown, empty = not persisted
+	StatsFile    string // player stats, restored on join and saved on autosave/shutdown, empty = not persisted
+	VehicleFile  string // JSON layout of static vehicles spawned on start, empty = none
+	AutosaveInterval time.Duration // 0 = save only on shutdown
 }
 
 func loadConfig() Config {
 	// Default configuration
 	// You can modify these values or load from environment variables
-	return Config{
+	config := Config{
 		Host:       "0.0.0.0",
 		Port:       7777,
 		MaxPlayers: 100,
+		MaxMTU:     protocol.MAX_MTU_SIZE,
+		QueryCacheTTL: server.DefaultQueryCacheTTL,
+		QueryRateLimit: server.DefaultQueryRateLimit,
+		QueryBudget:    server.DefaultQueryBudget,
+		MaxLimiterSources: server.DefaultMaxLimiterSources,
+		ConnectRateLimit:    server.DefaultConnectRateLimit,
+		ConnectRateWindow:   server.DefaultConnectRateWindow,
+		MaxHalfOpenSessions: server.DefaultMaxHalfOpenSessions,
+		HandshakeTimeout:    server.DefaultHandshakeTimeout,
+		PacketWorkers:       server.DefaultPacketWorkers,
 		ServerName: "RakNet Server [GO]",
 		GameMode:   "Freeroam v1.0",
 		Language:   "English",
 		Weather:    10,
 		WorldTime:  12,
+		Gravity:    0.008,
+		TimeCycle:  false,
+		TimeCycleInterval: 1 * time.Minute,
+		WeatherRotation:   nil,
+		WeatherInterval:   10 * time.Minute,
+		WeatherRandom:     false,
+		ArmourRegenRate:   0,
+		HealthRegenRate:   0,
+		SpawnProtection:   3 * time.Second,
+		AFKTimeout:        2 * time.Minute,
+		AFKMessage:        "You are now AFK",
+		SyncRate:          server.DefaultSyncRate,
+		ShowNameTags:        true,
+		NameTagDrawDistance: server.DefaultNameTagDrawDistance,
+		NameTagsIgnoreLOS:   false,
+		PlayerMarkers:       protocol.PlayerMarkersGlobal,
 		MapName:    "San Andreas",
 		WebURL:     "github.com/yourusername/raknet-go",
+		Password:   "",
+		AdminPassword: "",
+		AdminLevel:    2,
+		SupportedVersions: server.DefaultSupportedVersions,
+		MOTD:       nil,
+		RandomSeed: 0,
+		AuditLogPath: "",
+		LogLevel:     "info",
+		BanFile:      "bans.json",
+		StatsFile:    "player_stats.json",
+		VehicleFile:  "",
+		AutosaveInterval: server.DefaultAutosaveInterval,
+	}
+	
+	if level := os.Getenv("SAMP_LOG_LEVEL"); level != "" {
+		config.LogLevel = level
+	}
+	if password := os.Getenv("SAMP_ADMIN_PASSWORD"); password != "" {
+		config.AdminPassword = password
+	}
+	return config
+}
+
+// liveConfig returns the settings that can be applied with Server.Reload
+func (c Config) liveConfig() server.Config {
+	return server.Config{
+		ServerName: c.ServerName,
+		GameMode:   c.GameMode,
+		Language:   c.Language,
+		MapName:    c.MapName,
+		WebURL:     c.WebURL,
+		Weather:    c.Weather,
+		WorldTime:  c.WorldTime,
+		Gravity:    c.Gravity,
 	}
 }
 
 func setupGamemodeEvents(srv *server.Server, gm *gamemode.FreeroamGamemode) {
-	// TODO: Wire up gamemode events to server events
-	// This will be implemented when server event system is ready
+	gm.RegisterEvents(srv.Events)
 	logger.Success("Gamemode events configured")
 }
@@ -1,11 +1,34 @@
 package systems
 
-import "log"
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"samp-server-go/source/protocol"
+	"sync"
+)
 
-// VehicleSystem manages vehicle spawning and management
+// Valid SA-MP vehicle model range
+const (
+	MinVehicleModel = 400
+	MaxVehicleModel = 611
+)
+
+// San Andreas map limit used to reject bogus coordinates
+const maxWorldCoord = 20000.0
+
+// VehicleSystem manages vehicle spawning and management. Commands change
+// vehicles from packet workers while the update loop streams them, so every
+// method holds mu; RPCs and the destroy handler run after it is released.
 type VehicleSystem struct {
+	mu       sync.RWMutex
 	vehicles map[uint16]*VehicleData
 	nextID   uint16
+	sendRPC  func(vehicleID uint16, rpc []byte) // sends a vehicle's RPCs to the players that see it (optional)
+	onSpawn   func(vehicleID uint16, x, y, z float32) // creates new vehicles on nearby clients (optional)
+	onDestroy func(vehicleID uint16) // removes destroyed vehicles from clients (optional)
 }
 
 // VehicleData represents vehicle information
@@ -16,9 +39,20 @@ type VehicleData struct {
 	Rotation float32
 	Color1   int
 	Color2   int
+	Paintjob int
 	Owner    uint16
+	
+	// Damage state
+	Health   float32
+	Panels   uint32
+	Doors    uint32
+	Lights   uint8
+	Tires    uint8
 }
 
+// Full health of an undamaged vehicle
+const MaxVehicleHealth = 1000.0
+
 // NewVehicleSystem creates a new vehicle system
 func NewVehicleSystem() *VehicleSystem {
 	return &VehicleSystem{
@@ -27,8 +61,32 @@ func NewVehicleSystem() *VehicleSystem {
 	}
 }
 
-// SpawnVehicle spawns a new vehicle
+// SetRPCSender sets the function that sends a vehicle's RPCs to the players
+// that have it streamed in
+func (vs *VehicleSystem) SetRPCSender(sender func(vehicleID uint16, rpc []byte)) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	vs.sendRPC = sender
+}
+
+// SetSpawnHandler sets the function run after a vehicle is spawned
+func (vs *VehicleSystem) SetSpawnHandler(handler func(vehicleID uint16, x, y, z float32)) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	vs.onSpawn = handler
+}
+
+// SetDestroyHandler sets the function run after a vehicle is destroyed
+func (vs *VehicleSystem) SetDestroyHandler(handler func(vehicleID uint16)) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	vs.onDestroy = handler
+}
+
+// SpawnVehicle spawns a new vehicle and runs the spawn handler, which creates
+// it on nearby clients with its colors.
 func (vs *VehicleSystem) SpawnVehicle(modelID int, x, y, z, rotation float32, color1, color2 int, owner uint16) uint16 {
+	vs.mu.Lock()
 	vehicleID := vs.nextID
 	vs.nextID++
 	
@@ -41,33 +99,250 @@ func (vs *VehicleSystem) SpawnVehicle(modelID int, x, y, z, rotation float32, co
 		Rotation: rotation,
 		Color1:   color1,
 		Color2:   color2,
+		Paintjob: -1,
 		Owner:    owner,
+		Health:   MaxVehicleHealth,
 	}
 	
 	vs.vehicles[vehicleID] = vehicle
+	onSpawn := vs.onSpawn
+	vs.mu.Unlock()
 	
 	log.Printf("🚗 Vehicle %d (model %d) spawned at %.2f, %.2f, %.2f", vehicleID, modelID, x, y, z)
+	if onSpawn != nil {
+		onSpawn(vehicleID, x, y, z)
+	}
 	
 	return vehicleID
 }
 
 // DestroyVehicle destroys a vehicle
 func (vs *VehicleSystem) DestroyVehicle(vehicleID uint16) bool {
-	if _, exists := vs.vehicles[vehicleID]; exists {
-		delete(vs.vehicles, vehicleID)
-		log.Printf("🚗 Vehicle %d destroyed", vehicleID)
-		return true
+	vs.mu.Lock()
+	_, exists := vs.vehicles[vehicleID]
+	delete(vs.vehicles, vehicleID)
+	onDestroy := vs.onDestroy
+	vs.mu.Unlock()
+	
+	if !exists {
+		return false
+	}
+	log.Printf("🚗 Vehicle %d destroyed", vehicleID)
+	if onDestroy != nil {
+		onDestroy(vehicleID)
+	}
+	return true
+}
+
+// ChangeVehicleColor changes a vehicle's colors and syncs them to players
+func (vs *VehicleSystem) ChangeVehicleColor(vehicleID uint16, color1, color2 int) bool {
+	vs.mu.Lock()
+	vehicle, exists := vs.vehicles[vehicleID]
+	if !exists {
+		vs.mu.Unlock()
+		return false
+	}
+	
+	vehicle.Color1 = color1
+	vehicle.Color2 = color2
+	rpc, sendRPC := vs.colorRPC(vehicle), vs.sendRPC
+	vs.mu.Unlock()
+	
+	if rpc != nil && sendRPC != nil {
+		sendRPC(vehicleID, rpc)
+	}
+	return true
+}
+
+// ChangeVehiclePaintjob applies a paintjob (0-2) and syncs it to players
+func (vs *VehicleSystem) ChangeVehiclePaintjob(vehicleID uint16, paintjob int) bool {
+	vs.mu.Lock()
+	vehicle, exists := vs.vehicles[vehicleID]
+	if !exists || paintjob < 0 || paintjob > 2 {
+		vs.mu.Unlock()
+		return false
+	}
+	
+	vehicle.Paintjob = paintjob
+	sendRPC := vs.sendRPC
+	vs.mu.Unlock()
+	
+	if sendRPC != nil {
+		sendRPC(vehicleID, protocol.BuildSetVehiclePaintjobRPC(vehicleID, uint8(paintjob)))
+	}
+	return true
+}
+
+// SetVehicleHealth sets a vehicle's health and syncs it to players
+func (vs *VehicleSystem) SetVehicleHealth(vehicleID uint16, health float32) bool {
+	vs.mu.Lock()
+	vehicle, exists := vs.vehicles[vehicleID]
+	if !exists {
+		vs.mu.Unlock()
+		return false
+	}
+	
+	vehicle.Health = health
+	sendRPC := vs.sendRPC
+	vs.mu.Unlock()
+	
+	if sendRPC != nil {
+		sendRPC(vehicleID, protocol.BuildSetVehicleHealthRPC(vehicleID, health))
+	}
+	return true
+}
+
+// RepairVehicle restores full health and clears all visual damage
+func (vs *VehicleSystem) RepairVehicle(vehicleID uint16) bool {
+	vs.mu.Lock()
+	vehicle, exists := vs.vehicles[vehicleID]
+	if !exists {
+		vs.mu.Unlock()
+		return false
+	}
+	
+	vehicle.Health = MaxVehicleHealth
+	vehicle.Panels = 0
+	vehicle.Doors = 0
+	vehicle.Lights = 0
+	vehicle.Tires = 0
+	sendRPC := vs.sendRPC
+	vs.mu.Unlock()
+	
+	if sendRPC != nil {
+		sendRPC(vehicleID, protocol.BuildSetVehicleHealthRPC(vehicleID, MaxVehicleHealth))
+		sendRPC(vehicleID, protocol.BuildUpdateVehicleDamageStatusRPC(vehicleID, 0, 0, 0, 0))
+	}
+	
+	log.Printf("🔧 Vehicle %d repaired", vehicleID)
+	return true
+}
+
+// colorRPC builds the RPC that syncs a vehicle's colors, or nil for -1
+// (random) colors, which are left to the client. Caller must hold vs.mu.
+func (vs *VehicleSystem) colorRPC(vehicle *VehicleData) []byte {
+	if vehicle.Color1 < 0 || vehicle.Color2 < 0 {
+		return nil
 	}
-	return false
+	return protocol.BuildSetVehicleColorRPC(vehicle.ID, uint8(vehicle.Color1), uint8(vehicle.Color2))
 }
 
-// GetVehicle returns vehicle data
+// GetVehicle returns a copy of a vehicle's data
 func (vs *VehicleSystem) GetVehicle(vehicleID uint16) (*VehicleData, bool) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
 	vehicle, exists := vs.vehicles[vehicleID]
-	return vehicle, exists
+	if !exists {
+		return nil, false
+	}
+	copied := *vehicle
+	return &copied, true
+}
+
+// ForEachVehicle calls fn with a copy of every spawned vehicle. The list is
+// copied first, so fn may call back into the vehicle system.
+func (vs *VehicleSystem) ForEachVehicle(fn func(*VehicleData)) {
+	vs.mu.RLock()
+	vehicles := make([]VehicleData, 0, len(vs.vehicles))
+	for _, vehicle := range vs.vehicles {
+		vehicles = append(vehicles, *vehicle)
+	}
+	vs.mu.RUnlock()
+	
+	for i := range vehicles {
+		fn(&vehicles[i])
+	}
+}
+
+// CreateVehicleRPC builds the RPC that creates a vehicle on a client
+func (vs *VehicleSystem) CreateVehicleRPC(vehicleID uint16) ([]byte, bool) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	vehicle, exists := vs.vehicles[vehicleID]
+	if !exists {
+		return nil, false
+	}
+	// Random (-1) colors are not resolved here; they wrap to 0xFF, as does
+	// no paintjob (-1)
+	return protocol.BuildCreateVehicleRPCFromParams(protocol.CreateVehicleParams{
+		VehicleID: vehicle.ID,
+		ModelID:   int32(vehicle.ModelID),
+		X:         vehicle.X,
+		Y:         vehicle.Y,
+		Z:         vehicle.Z,
+		Angle:     vehicle.Rotation,
+		Color1:    uint8(vehicle.Color1),
+		Color2:    uint8(vehicle.Color2),
+		Health:    vehicle.Health,
+		Doors:     vehicle.Doors,
+		Panels:    vehicle.Panels,
+		Lights:    vehicle.Lights,
+		Tires:     vehicle.Tires,
+		Paintjob:  uint8(vehicle.Paintjob),
+	}), true
 }
 
 // GetVehicleCount returns the number of spawned vehicles
 func (vs *VehicleSystem) GetVehicleCount() int {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
 	return len(vs.vehicles)
 }
+
+// StaticVehicle is one entry of a vehicle layout file
+type StaticVehicle struct {
+	Model    int     `json:"model"`
+	X        float32 `json:"x"`
+	Y        float32 `json:"y"`
+	Z        float32 `json:"z"`
+	Rotation float32 `json:"rotation"`
+	Color1   int     `json:"color1"`
+	Color2   int     `json:"color2"`
+}
+
+// LoadFromFile spawns the static vehicles listed in a JSON layout file,
+// like AddStaticVehicle in SA-MP. Nothing is spawned if any entry is invalid.
+func (vs *VehicleSystem) LoadFromFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read vehicle layout: %w", err)
+	}
+	
+	var layout []StaticVehicle
+	if err := json.Unmarshal(data, &layout); err != nil {
+		return fmt.Errorf("failed to parse vehicle layout %s: %w", path, err)
+	}
+	
+	for i, v := range layout {
+		if err := v.validate(); err != nil {
+			return fmt.Errorf("vehicle layout %s entry %d: %w", path, i, err)
+		}
+	}
+	
+	for _, v := range layout {
+		vs.SpawnVehicle(v.Model, v.X, v.Y, v.Z, v.Rotation, v.Color1, v.Color2, 0)
+	}
+	
+	log.Printf("✅ Loaded %d static vehicles from %s", len(layout), path)
+	return nil
+}
+
+func (v StaticVehicle) validate() error {
+	if v.Model < MinVehicleModel || v.Model > MaxVehicleModel {
+		return fmt.Errorf("invalid model id %d (must be %d-%d)", v.Model, MinVehicleModel, MaxVehicleModel)
+	}
+	
+	for _, c := range []float32{v.X, v.Y, v.Z} {
+		f := float64(c)
+		if math.IsNaN(f) || math.Abs(f) > maxWorldCoord {
+			return fmt.Errorf("invalid position %.2f, %.2f, %.2f", v.X, v.Y, v.Z)
+		}
+	}
+	
+	for _, c := range []int{v.Color1, v.Color2} {
+		if c < -1 || c > 255 {
+			return fmt.Errorf("invalid color %d (must be -1 or 0-255)", c)
+		}
+	}
+	return nil
+}
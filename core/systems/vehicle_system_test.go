@@ -0,0 +1,247 @@
+package systems
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"samp-server-go/source/protocol"
+	"sync"
+	"testing"
+)
+
+func writeLayout(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "vehicles.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write layout: %v", err)
+	}
+	return path
+}
+
+func TestLoadFromFile(t *testing.T) {
+	path := writeLayout(t, `[
+		{"model": 411, "x": 2040.5, "y": 1340.2, "z": 10.6, "rotation": 90, "color1": 1, "color2": 3},
+		{"model": 522, "x": -1950.0, "y": 250.0, "z": 35.0, "rotation": 180, "color1": 6, "color2": 6}
+	]`)
+	
+	vs := NewVehicleSystem()
+	if err := vs.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+	
+	if vs.GetVehicleCount() != 2 {
+		t.Fatalf("Expected 2 vehicles, got %d", vs.GetVehicleCount())
+	}
+	
+	v, ok := vs.GetVehicle(1)
+	if !ok {
+		t.Fatal("Vehicle 1 not found")
+	}
+	if v.ModelID != 411 || v.X != 2040.5 || v.Y != 1340.2 || v.Z != 10.6 || v.Rotation != 90 || v.Color1 != 1 || v.Color2 != 3 {
+		t.Errorf("Vehicle 1 has wrong attributes: %+v", v)
+	}
+	
+	v, _ = vs.GetVehicle(2)
+	if v.ModelID != 522 || v.X != -1950 || v.Color1 != 6 {
+		t.Errorf("Vehicle 2 has wrong attributes: %+v", v)
+	}
+}
+
+func TestLoadFromFileRejectsInvalidEntries(t *testing.T) {
+	tests := []string{
+		`[{"model": 399, "x": 0, "y": 0, "z": 0}]`,
+		`[{"model": 612, "x": 0, "y": 0, "z": 0}]`,
+		`[{"model": 411, "x": 0, "y": 0, "z": 0}, {"model": 411, "x": 50000, "y": 0, "z": 0}]`,
+		`[{"model": 411, "x": 0, "y": 0, "z": 0, "color1": 256, "color2": 1}]`,
+		`[{"model": 411, "x": 0, "y": 0, "z": 0, "color1": 1, "color2": -2}]`,
+		`not json`,
+	}
+	
+	for _, content := range tests {
+		vs := NewVehicleSystem()
+		if err := vs.LoadFromFile(writeLayout(t, content)); err == nil {
+			t.Errorf("Expected error for layout %s", content)
+		}
+		if vs.GetVehicleCount() != 0 {
+			t.Errorf("Expected no vehicles spawned for invalid layout, got %d", vs.GetVehicleCount())
+		}
+	}
+}
+
+func TestLoadFromFileMissing(t *testing.T) {
+	vs := NewVehicleSystem()
+	if err := vs.LoadFromFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestVehicleColorChangesSent(t *testing.T) {
+	vs := NewVehicleSystem()
+	sent := make([][]byte, 0)
+	vs.SetRPCSender(func(vehicleID uint16, rpc []byte) {
+		sent = append(sent, rpc)
+	})
+	
+	// The colors of a new vehicle go out in CreateVehicle when it streams in
+	vehicleID := vs.SpawnVehicle(411, 0, 0, 0, 0, 126, 1, 0)
+	if len(sent) != 0 {
+		t.Fatalf("Expected nothing sent on spawn, got %02X", sent)
+	}
+	
+	vs.ChangeVehicleColor(vehicleID, 126, 1)
+	vs.ChangeVehiclePaintjob(vehicleID, 2)
+	if len(sent) != 2 {
+		t.Fatalf("Expected color and paintjob RPCs, got %d", len(sent))
+	}
+	
+	color := sent[0]
+	if color[0] != protocol.RPC_ScmEvent || binary.LittleEndian.Uint32(color[3:7]) != protocol.SCM_EVENT_COLOR {
+		t.Fatalf("Expected color ScmEvent, got %02X", color)
+	}
+	if binary.LittleEndian.Uint32(color[7:11]) != uint32(vehicleID) {
+		t.Errorf("Expected vehicle id %d, got %d", vehicleID, binary.LittleEndian.Uint32(color[7:11]))
+	}
+	if binary.LittleEndian.Uint32(color[11:15]) != 126 || binary.LittleEndian.Uint32(color[15:19]) != 1 {
+		t.Errorf("Expected colors 126/1, got %02X", color[11:19])
+	}
+	
+	paintjob := sent[1]
+	if binary.LittleEndian.Uint32(paintjob[3:7]) != protocol.SCM_EVENT_PAINTJOB || paintjob[11] != 2 {
+		t.Errorf("Expected paintjob 2 ScmEvent, got %02X", paintjob)
+	}
+}
+
+func TestRandomColorChangeNotSent(t *testing.T) {
+	vs := NewVehicleSystem()
+	sent := 0
+	vs.SetRPCSender(func(vehicleID uint16, rpc []byte) {
+		sent++
+	})
+	
+	vehicleID := vs.SpawnVehicle(411, 0, 0, 0, 0, 1, 1, 0)
+	vs.ChangeVehicleColor(vehicleID, -1, -1)
+	if sent != 0 {
+		t.Errorf("Expected no color RPC for random colors, got %d", sent)
+	}
+}
+
+func TestRepairVehicle(t *testing.T) {
+	vs := NewVehicleSystem()
+	vehicleID := vs.SpawnVehicle(411, 0, 0, 0, 0, -1, -1, 0)
+	
+	sent := make([][]byte, 0)
+	vs.SetRPCSender(func(vehicleID uint16, rpc []byte) {
+		sent = append(sent, rpc)
+	})
+	
+	// GetVehicle returns a copy; damage the stored vehicle
+	damaged := vs.vehicles[vehicleID]
+	damaged.Health = 300
+	damaged.Panels = 0x11
+	damaged.Tires = 0x0F
+	
+	if !vs.RepairVehicle(vehicleID) {
+		t.Fatal("RepairVehicle returned false")
+	}
+	v, _ := vs.GetVehicle(vehicleID)
+	if v.Health != MaxVehicleHealth || v.Panels != 0 || v.Tires != 0 {
+		t.Errorf("Expected repaired vehicle, got %+v", v)
+	}
+	if len(sent) != 2 || sent[0][0] != protocol.RPC_SetVehicleHealth || sent[1][0] != protocol.RPC_UpdateVehicleDamageStatus {
+		t.Errorf("Expected health and damage status RPCs, got %02X", sent)
+	}
+}
+
+func TestCreateVehicleRPCFromSpawn(t *testing.T) {
+	vs := NewVehicleSystem()
+	vehicleID := vs.SpawnVehicle(411, 2040.5, 1340.2, 10.6, 90, 1, 3, 0)
+	
+	rpc, ok := vs.CreateVehicleRPC(vehicleID)
+	if !ok {
+		t.Fatal("Expected an RPC for a spawned vehicle")
+	}
+	expected := protocol.BuildCreateVehicleRPC(vehicleID, 411, 2040.5, 1340.2, 10.6, 90, 1, 3, MaxVehicleHealth, 0)
+	if string(rpc) != string(expected) {
+		t.Errorf("Expected % X, got % X", expected, rpc)
+	}
+	
+	// A paintjob and damage applied since spawning are part of the RPC
+	vs.ChangeVehiclePaintjob(vehicleID, 1)
+	vs.vehicles[vehicleID].Panels = 0x11
+	vs.vehicles[vehicleID].Tires = 0x0F
+	rpc, _ = vs.CreateVehicleRPC(vehicleID)
+	if rpc[55] != 1 || binary.LittleEndian.Uint32(rpc[34:38]) != 0x11 || rpc[39] != 0x0F {
+		t.Errorf("Expected paintjob 1, panels 0x11 and tires 0x0F, got % X", rpc)
+	}
+	
+	if _, ok := vs.CreateVehicleRPC(vehicleID + 1); ok {
+		t.Error("Expected no RPC for an unknown vehicle")
+	}
+}
+
+func TestSpawnVehicleRunsSpawnHandler(t *testing.T) {
+	vs := NewVehicleSystem()
+	var spawned uint16
+	var x, y, z float32
+	vs.SetSpawnHandler(func(vehicleID uint16, posX, posY, posZ float32) {
+		spawned, x, y, z = vehicleID, posX, posY, posZ
+		if _, ok := vs.CreateVehicleRPC(vehicleID); !ok {
+			t.Error("Expected the vehicle stored before the spawn handler runs")
+		}
+	})
+	
+	vehicleID := vs.SpawnVehicle(411, 2040.5, 1340.2, 10.6, 90, 1, 3, 0)
+	if spawned != vehicleID || x != 2040.5 || y != 1340.2 || z != 10.6 {
+		t.Errorf("Expected the spawn handler run for vehicle %d at its position, got %d at %v, %v, %v", vehicleID, spawned, x, y, z)
+	}
+}
+
+func TestDestroyVehicleRunsDestroyHandler(t *testing.T) {
+	vs := NewVehicleSystem()
+	vehicleID := vs.SpawnVehicle(411, 0, 0, 0, 0, 1, 1, 0)
+	
+	destroyed := make([]uint16, 0)
+	vs.SetDestroyHandler(func(id uint16) { destroyed = append(destroyed, id) })
+	
+	vs.DestroyVehicle(vehicleID)
+	vs.DestroyVehicle(vehicleID)
+	
+	if len(destroyed) != 1 || destroyed[0] != vehicleID {
+		t.Errorf("Expected one destroy for vehicle %d, got %v", vehicleID, destroyed)
+	}
+}
+
+func TestVehicleSystemConcurrentAccess(t *testing.T) {
+	vs := NewVehicleSystem()
+	vs.SetRPCSender(func(uint16, []byte) {})
+	vehicleID := vs.SpawnVehicle(411, 0, 0, 0, 0, 1, 1, 0)
+	
+	// Commands change vehicles from packet workers while the update loop streams them
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			vs.SetVehicleHealth(vehicleID, float32(i))
+			vs.RepairVehicle(vehicleID)
+			vs.ChangeVehicleColor(vehicleID, i%256, 1)
+			vs.SpawnVehicle(411, 0, 0, 0, 0, 1, 1, 0)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			vs.ForEachVehicle(func(vehicle *VehicleData) {
+				_ = vehicle.Health
+				vs.GetVehicle(vehicle.ID) // callbacks may use the system
+			})
+			vs.CreateVehicleRPC(vehicleID)
+			vs.GetVehicleCount()
+		}
+	}()
+	wg.Wait()
+	
+	if count := vs.GetVehicleCount(); count != 101 {
+		t.Errorf("Expected 101 vehicles, got %d", count)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -49,6 +50,21 @@ func SetLevel(level int) {
 	defaultLogger.level = level
 }
 
+// ParseLevel maps a level name (debug, info, warn, error) to its level constant
+func ParseLevel(name string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", name)
+}
+
 // SetTimeFormat sets the time format for logs
 func SetTimeFormat(format string) {
 	defaultLogger.timeFormat = format
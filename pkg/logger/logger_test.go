@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	levels := map[string]int{"debug": LevelDebug, "info": LevelInfo, "WARN": LevelWarn, "error": LevelError}
+	for name, expected := range levels {
+		level, err := ParseLevel(name)
+		if err != nil || level != expected {
+			t.Errorf("ParseLevel(%q) = %d, %v; want %d", name, level, err, expected)
+		}
+	}
+	
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("Expected error for unknown level")
+	}
+}
+
+func TestWarnLevelSuppressesInfo(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	previous := defaultLogger.level
+	defer func() {
+		log.SetOutput(os.Stderr)
+		defaultLogger.level = previous
+	}()
+	
+	level, err := ParseLevel("warn")
+	if err != nil {
+		t.Fatalf("ParseLevel failed: %v", err)
+	}
+	SetLevel(level)
+	
+	Info("hidden")
+	Warn("shown")
+	
+	output := buf.String()
+	if strings.Contains(output, "hidden") {
+		t.Error("Expected Info to be suppressed at warn level")
+	}
+	if !strings.Contains(output, "shown") {
+		t.Error("Expected Warn to be logged at warn level")
+	}
+}
@@ -0,0 +1,243 @@
+package raknet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"samp-server-go/source/protocol"
+	"time"
+)
+
+// Client defaults
+const (
+	DefaultClientTimeout = 5 * time.Second
+	clientResendInterval = 500 * time.Millisecond // offline requests are resent until answered
+)
+
+// Client is a minimal RakNet client for checking the offline handshake and
+// ping against a server (ours or the reference one)
+type Client struct {
+	GUID    uint64
+	MTU     uint16
+	Timeout time.Duration // per request, including resends
+	
+	conn       *net.UDPConn
+	serverGUID uint64
+}
+
+// Pong is a decoded ID_UNCONNECTED_PONG
+type Pong struct {
+	ServerTime uint64
+	ServerGUID uint64
+	Info       string // server info string, if the server sent one
+	RTT        time.Duration
+}
+
+// NewClient creates a client with a random GUID
+func NewClient() *Client {
+	var guid [8]byte
+	rand.Read(guid[:])
+	return &Client{
+		GUID:    binary.BigEndian.Uint64(guid[:]),
+		MTU:     DEFAULT_MTU_SIZE,
+		Timeout: DefaultClientTimeout,
+	}
+}
+
+// Connect runs OPEN_CONNECTION_REQUEST_1/2 against addr ("host:port")
+func (c *Client) Connect(addr string) error {
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", addr, err)
+	}
+	conn, err := net.DialUDP("udp", nil, raddr)
+	if err != nil {
+		return fmt.Errorf("dial %s: %w", addr, err)
+	}
+	c.conn = conn
+	
+	reply1, err := c.request(c.openConnectionRequest1(), protocol.ID_OPEN_CONNECTION_REPLY_1)
+	if err != nil {
+		c.Close()
+		return fmt.Errorf("open connection request 1: %w", err)
+	}
+	serverGUID, mtu, err := decodeOpenConnectionReply1(reply1)
+	if err != nil {
+		c.Close()
+		return err
+	}
+	c.serverGUID = serverGUID
+	if mtu < c.MTU {
+		c.MTU = mtu
+	}
+	
+	reply2, err := c.request(c.openConnectionRequest2(raddr), protocol.ID_OPEN_CONNECTION_REPLY_2)
+	if err != nil {
+		c.Close()
+		return fmt.Errorf("open connection request 2: %w", err)
+	}
+	if err := checkMagic(reply2, 1); err != nil {
+		c.Close()
+		return fmt.Errorf("open connection reply 2: %w", err)
+	}
+	return nil
+}
+
+// Ping sends an unconnected ping and waits for the pong
+func (c *Client) Ping() (*Pong, error) {
+	if c.conn == nil {
+		return nil, errors.New("not connected")
+	}
+	
+	ping := protocol.NewEmptyBitStream()
+	ping.WriteByte(protocol.ID_UNCONNECTED_PING)
+	ping.WriteUint64(uint64(time.Now().UnixMilli()))
+	ping.WriteBytes(protocol.OfflineMessageDataID)
+	ping.WriteUint64(c.GUID)
+	
+	sent := time.Now()
+	data, err := c.request(ping.GetData(), protocol.ID_UNCONNECTED_PONG)
+	if err != nil {
+		return nil, fmt.Errorf("ping: %w", err)
+	}
+	pong, err := decodePong(data)
+	if err != nil {
+		return nil, err
+	}
+	pong.RTT = time.Since(sent)
+	return pong, nil
+}
+
+// ServerGUID returns the GUID the server announced during Connect
+func (c *Client) ServerGUID() uint64 {
+	return c.serverGUID
+}
+
+// Close closes the client's socket
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// request sends payload until a packet starting with replyID arrives or the
+// timeout passes. Other packets are ignored.
+func (c *Client) request(payload []byte, replyID byte) ([]byte, error) {
+	deadline := time.Now().Add(c.Timeout)
+	buf := make([]byte, 2048)
+	
+	for time.Now().Before(deadline) {
+		if _, err := c.conn.Write(payload); err != nil {
+			return nil, err
+		}
+		
+		resend := time.Now().Add(clientResendInterval)
+		if resend.After(deadline) {
+			resend = deadline
+		}
+		c.conn.SetReadDeadline(resend)
+		for {
+			n, err := c.conn.Read(buf)
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return nil, err
+				}
+				// Timeout, or ICMP port unreachable while the server is
+				// still starting: resend
+				var netErr net.Error
+				if !errors.As(err, &netErr) || !netErr.Timeout() {
+					time.Sleep(10 * time.Millisecond)
+				}
+				break
+			}
+			if n > 0 && buf[0] == replyID {
+				return append([]byte(nil), buf[:n]...), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no reply 0x%02X within %v", replyID, c.Timeout)
+}
+
+// openConnectionRequest1 is padded to the MTU being probed
+func (c *Client) openConnectionRequest1() []byte {
+	bs := protocol.NewEmptyBitStream()
+	bs.WriteByte(protocol.ID_OPEN_CONNECTION_REQUEST_1)
+	bs.WriteBytes(protocol.OfflineMessageDataID)
+	bs.WriteByte(protocol.RAKNET_PROTOCOL_VERSION)
+	data := bs.GetData()
+	
+	// MTU covers the IP (20) and UDP (8) headers too
+	if size := int(c.MTU) - 28; size > len(data) {
+		data = append(data, make([]byte, size-len(data))...)
+	}
+	return data
+}
+
+func (c *Client) openConnectionRequest2(server *net.UDPAddr) []byte {
+	bs := protocol.NewEmptyBitStream()
+	bs.WriteByte(protocol.ID_OPEN_CONNECTION_REQUEST_2)
+	bs.WriteBytes(protocol.OfflineMessageDataID)
+	bs.WriteAddress(server)
+	bs.WriteUint16(c.MTU)
+	bs.WriteUint64(c.GUID)
+	return bs.GetData()
+}
+
+// decodeOpenConnectionReply1 reads [ID][magic][server GUID][security][MTU]
+func decodeOpenConnectionReply1(data []byte) (uint64, uint16, error) {
+	if err := checkMagic(data, 1); err != nil {
+		return 0, 0, fmt.Errorf("open connection reply 1: %w", err)
+	}
+	bs := protocol.NewBitStream(data[17:])
+	guid, err := bs.ReadUint64()
+	if err != nil {
+		return 0, 0, fmt.Errorf("open connection reply 1: %w", err)
+	}
+	bs.ReadByte() // security
+	mtu, err := bs.ReadUint16()
+	if err != nil {
+		return 0, 0, fmt.Errorf("open connection reply 1: %w", err)
+	}
+	return guid, mtu, nil
+}
+
+// decodePong reads [ID][time][server GUID][magic] and an optional
+// length-prefixed server info string
+func decodePong(data []byte) (*Pong, error) {
+	bs := protocol.NewBitStream(data[1:])
+	serverTime, err := bs.ReadUint64()
+	if err != nil {
+		return nil, fmt.Errorf("pong: %w", err)
+	}
+	guid, err := bs.ReadUint64()
+	if err != nil {
+		return nil, fmt.Errorf("pong: %w", err)
+	}
+	if err := checkMagic(data, 17); err != nil {
+		return nil, fmt.Errorf("pong: %w", err)
+	}
+	
+	pong := &Pong{ServerTime: serverTime, ServerGUID: guid}
+	bs.ReadBytes(len(protocol.OfflineMessageDataID))
+	if length, err := bs.ReadUint16(); err == nil {
+		if info, err := bs.ReadBytes(int(length)); err == nil {
+			pong.Info = string(info)
+		}
+	}
+	return pong, nil
+}
+
+// checkMagic verifies the offline message magic at offset
+func checkMagic(data []byte, offset int) error {
+	end := offset + len(protocol.OfflineMessageDataID)
+	if len(data) < end || !bytes.Equal(data[offset:end], protocol.OfflineMessageDataID) {
+		return errors.New("missing offline message magic")
+	}
+	return nil
+}
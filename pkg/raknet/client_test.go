@@ -0,0 +1,61 @@
+package raknet
+
+import (
+	"net"
+	"samp-server-go/source/server"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// freeUDPPort returns a loopback port nothing is listening on
+func freeUDPPort(t *testing.T) int {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestClientConnectsAndPingsInProcessServer(t *testing.T) {
+	port := freeUDPPort(t)
+	srv := server.NewServer("127.0.0.1", port, 10)
+	started := make(chan error, 1)
+	go func() { started <- srv.Start() }()
+	t.Cleanup(func() {
+		srv.Stop()
+		if err := <-started; err != nil {
+			t.Errorf("Server stopped with error: %v", err)
+		}
+	})
+	
+	client := NewClient()
+	client.Timeout = 3 * time.Second
+	defer client.Close()
+	
+	if err := client.Connect(net.JoinHostPort("127.0.0.1", strconv.Itoa(port))); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if client.ServerGUID() == 0 {
+		t.Errorf("Expected the server GUID from OPEN_CONNECTION_REPLY_1")
+	}
+	
+	pong, err := client.Ping()
+	if err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+	if pong.ServerGUID != client.ServerGUID() {
+		t.Errorf("Pong GUID %d does not match handshake GUID %d", pong.ServerGUID, client.ServerGUID())
+	}
+	if pong.Info == "" {
+		t.Errorf("Expected server info in the pong")
+	}
+}
+
+func TestClientPingRequiresConnect(t *testing.T) {
+	if _, err := NewClient().Ping(); err == nil {
+		t.Error("Expected Ping before Connect to fail")
+	}
+}
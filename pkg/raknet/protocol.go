@@ -3,34 +3,12 @@ package raknet
 import (
 	"encoding/binary"
 	"math"
+	"samp-server-go/source/protocol"
 	"sync"
 	"time"
 )
 
-// RakNet packet IDs
-const (
-	// Connection packets
-	ID_OPEN_CONNECTION_REQUEST_1  = 0x08
-	ID_OPEN_CONNECTION_REPLY_1    = 0x1A
-	ID_OPEN_CONNECTION_REQUEST_2  = 0xA2
-	ID_OPEN_CONNECTION_REPLY_2    = 0x19
-	
-	// Data packets
-	ID_DATA_PACKET_0 = 0x84
-	ID_DATA_PACKET_1 = 0x85
-	ID_DATA_PACKET_2 = 0x86
-	ID_DATA_PACKET_3 = 0x87
-	ID_DATA_PACKET_4 = 0x88
-	ID_DATA_PACKET_5 = 0x89
-	ID_DATA_PACKET_6 = 0x8A
-	ID_DATA_PACKET_7 = 0x8B
-	ID_DATA_PACKET_8 = 0x8C
-	ID_DATA_PACKET_9 = 0x8D
-	
-	// ACK/NACK
-	ID_ACK  = 0xC0
-	ID_NACK = 0xA0
-)
+// Packet IDs come from source/protocol, the single source of truth
 
 // Reliability types
 const (
@@ -147,7 +125,7 @@ func EncodeDatagram(seq uint32, packets []EncapsulatedPacket) []byte {
 	buf := make([]byte, 0, 1500)
 	
 	// Packet ID (0x84 for reliable ordered)
-	buf = append(buf, ID_DATA_PACKET_0)
+	buf = append(buf, protocol.ID_DATA_PACKET)
 	
 	// Sequence number (24-bit LE)
 	buf = append(buf, WriteUint24LE(seq)...)
@@ -188,7 +166,7 @@ func EncodeDatagram(seq uint32, packets []EncapsulatedPacket) []byte {
 // EncodeACK encodes an ACK packet
 func EncodeACK(sequences []uint32) []byte {
 	buf := make([]byte, 0, 100)
-	buf = append(buf, ID_ACK)
+	buf = append(buf, protocol.ID_ACK)
 	
 	// Record count (16-bit LE)
 	count := uint16(len(sequences))
@@ -207,7 +185,7 @@ func EncodeACK(sequences []uint32) []byte {
 // EncodeNACK encodes a NACK packet
 func EncodeNACK(sequences []uint32) []byte {
 	buf := make([]byte, 0, 100)
-	buf = append(buf, ID_NACK)
+	buf = append(buf, protocol.ID_NACK)
 	
 	// Record count (16-bit LE)
 	count := uint16(len(sequences))
@@ -0,0 +1,46 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// AimSyncSize is the size of the aim sync payload after the packet ID
+const AimSyncSize = 31
+
+// AimSync is a decoded ID_AIM_SYNC (0xC9) payload
+type AimSync struct {
+	CamMode     uint8
+	CamFront    [3]float32 // camera look direction
+	CamPos      [3]float32 // camera position
+	AimZ        float32
+	CamZoom     uint8 // 6 bits
+	WeaponState uint8 // 2 bits
+	AspectRatio uint8
+}
+
+// DecodeAimSync decodes an aim sync payload (without the packet ID):
+// [camMode 1][camFront 12][camPos 12][aimZ 4][zoom:6|weaponState:2 1][aspect 1], little-endian
+func DecodeAimSync(payload []byte) (*AimSync, error) {
+	if len(payload) < AimSyncSize {
+		return nil, fmt.Errorf("aim sync: %d bytes, want %d", len(payload), AimSyncSize)
+	}
+	
+	readFloat := func(offset int) float32 {
+		return math.Float32frombits(binary.LittleEndian.Uint32(payload[offset:]))
+	}
+	
+	as := &AimSync{
+		CamMode:     payload[0],
+		AimZ:        readFloat(25),
+		CamZoom:     payload[29] & 0x3F,
+		WeaponState: payload[29] >> 6,
+		AspectRatio: payload[30],
+	}
+	for i := 0; i < 3; i++ {
+		as.CamFront[i] = readFloat(1 + i*4)
+		as.CamPos[i] = readFloat(13 + i*4)
+	}
+	return as, nil
+}
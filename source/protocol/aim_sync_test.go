@@ -0,0 +1,45 @@
+package protocol
+
+import "testing"
+
+// capturedAimSync is a player aiming (camera mode 53) while reloading
+var capturedAimSync = []byte{
+	0x35,
+	0x00, 0x00, 0x00, 0x3F, 0x00, 0x00, 0x80, 0xBE, 0x00, 0x00, 0x00, 0x3E,
+	0x00, 0xD0, 0xF4, 0x44, 0x00, 0xE8, 0xA7, 0x44, 0x00, 0x00, 0x78, 0x41,
+	0x00, 0x00, 0x00, 0x3F,
+	0x8A,
+	0x55,
+}
+
+func TestDecodeAimSync(t *testing.T) {
+	aim, err := DecodeAimSync(capturedAimSync)
+	if err != nil {
+		t.Fatalf("DecodeAimSync failed: %v", err)
+	}
+	
+	if aim.CamMode != 53 {
+		t.Errorf("Expected camera mode 53, got %d", aim.CamMode)
+	}
+	if aim.CamFront != [3]float32{0.5, -0.25, 0.125} {
+		t.Errorf("Unexpected camera front vector: %v", aim.CamFront)
+	}
+	if aim.CamPos != [3]float32{1958.5, 1343.25, 15.5} {
+		t.Errorf("Unexpected camera position: %v", aim.CamPos)
+	}
+	if aim.AimZ != 0.5 {
+		t.Errorf("Expected aim z 0.5, got %f", aim.AimZ)
+	}
+	if aim.WeaponState != 2 || aim.CamZoom != 10 {
+		t.Errorf("Expected weapon state 2 and zoom 10, got %d and %d", aim.WeaponState, aim.CamZoom)
+	}
+	if aim.AspectRatio != 0x55 {
+		t.Errorf("Expected aspect ratio 0x55, got 0x%02X", aim.AspectRatio)
+	}
+}
+
+func TestDecodeAimSyncRejectsShortPayload(t *testing.T) {
+	if _, err := DecodeAimSync(capturedAimSync[:AimSyncSize-1]); err == nil {
+		t.Error("Expected error for a short payload")
+	}
+}
@@ -0,0 +1,49 @@
+package protocol
+
+import "sync"
+
+// PooledBufferSize fits any datagram up to MAX_MTU_SIZE with room to spare
+const PooledBufferSize = 2048
+
+// bufferPool holds *[]byte so putting a buffer back does not allocate
+var bufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, PooledBufferSize)
+		return &buf
+	},
+}
+
+// GetBuffer takes a PooledBufferSize byte buffer from the pool. Hand it back
+// with PutBuffer once nothing refers to it any more.
+func GetBuffer() *[]byte {
+	return bufferPool.Get().(*[]byte)
+}
+
+// PutBuffer returns a buffer to the pool. Buffers that did not come from
+// GetBuffer are left to the garbage collector.
+func PutBuffer(buf *[]byte) {
+	if buf == nil || cap(*buf) != PooledBufferSize {
+		return
+	}
+	*buf = (*buf)[:PooledBufferSize]
+	bufferPool.Put(buf)
+}
+
+// NewPooledBitStream returns an empty BitStream that writes into a pooled
+// buffer. Call Release once its data is no longer used.
+func NewPooledBitStream() *BitStream {
+	buf := GetBuffer()
+	return &BitStream{data: (*buf)[:0], pooled: buf}
+}
+
+// Release returns a pooled stream's buffer. The stream and any slice from
+// GetData must not be used afterwards. It is a no-op on other streams.
+func (bs *BitStream) Release() {
+	if bs.pooled == nil {
+		return
+	}
+	PutBuffer(bs.pooled)
+	bs.pooled = nil
+	bs.data = nil
+	bs.offset = 0
+}
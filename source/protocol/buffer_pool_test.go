@@ -0,0 +1,62 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPooledBitStreamRelease(t *testing.T) {
+	bs := NewPooledBitStream()
+	bs.WriteByte(0x84)
+	bs.WriteUint24(7)
+	if data := bs.GetData(); len(data) != 4 || cap(data) != PooledBufferSize {
+		t.Fatalf("Expected 4 bytes in a %d byte pooled buffer, got len %d cap %d", PooledBufferSize, len(data), cap(data))
+	}
+	
+	bs.Release()
+	if bs.GetData() != nil {
+		t.Error("Expected a released stream to drop its buffer")
+	}
+	bs.Release() // second call is a no-op
+	
+	plain := NewBitStream([]byte{0x01})
+	plain.Release()
+	if !bytes.Equal(plain.GetData(), []byte{0x01}) {
+		t.Error("Expected Release to leave unpooled streams alone")
+	}
+}
+
+func TestPutBufferIgnoresForeignBuffers(t *testing.T) {
+	PutBuffer(nil)
+	
+	small := make([]byte, 16)
+	PutBuffer(&small)
+	
+	buf := GetBuffer()
+	if len(*buf) != PooledBufferSize {
+		t.Errorf("Expected a %d byte buffer from the pool, got %d", PooledBufferSize, len(*buf))
+	}
+	PutBuffer(buf)
+}
+
+func TestEncodeToMatchesEncode(t *testing.T) {
+	dp := NewDataPacket()
+	dp.SequenceNumber = 42
+	dp.Packets = []*EncapsulatedPacket{
+		{Reliability: RELIABLE_ORDERED, MessageIndex: 1, OrderIndex: 2, OrderChannel: 3, Payload: []byte{0x10, 0x20}},
+		{Reliability: RELIABLE, Split: true, SplitCount: 2, SplitID: 5, SplitIndex: 1, Payload: []byte{0x30}},
+		{Reliability: UNRELIABLE, Payload: []byte{0x40}},
+	}
+	
+	encoded := dp.Encode()
+	if len(encoded) != cap(encoded) {
+		t.Errorf("Expected Encode to size its buffer exactly, got len %d cap %d", len(encoded), cap(encoded))
+	}
+	
+	bs := NewPooledBitStream()
+	defer bs.Release()
+	dp.EncodeTo(bs)
+	if !bytes.Equal(bs.GetData(), encoded) {
+		t.Errorf("Expected EncodeTo to match Encode:\n% X\n% X", bs.GetData(), encoded)
+	}
+}
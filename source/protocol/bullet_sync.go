@@ -0,0 +1,58 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Bullet sync hit types
+const (
+	BulletHitNone         = 0
+	BulletHitPlayer       = 1
+	BulletHitVehicle      = 2
+	BulletHitObject       = 3
+	BulletHitPlayerObject = 4
+)
+
+// BulletSyncSize is the size of the bullet sync payload after the packet ID
+const BulletSyncSize = 40
+
+// BulletSync is a decoded ID_BULLET_SYNC (0xCE) payload
+type BulletSync struct {
+	HitType  uint8
+	HitID    uint16
+	Origin   [3]float32 // where the shot was fired from
+	Target   [3]float32 // where the bullet hit
+	Center   [3]float32 // hit offset from the center of the hit entity
+	WeaponID uint8
+}
+
+// DecodeBulletSync decodes a bullet sync payload (without the packet ID):
+// [hitType 1][hitID 2][origin 12][target 12][center 12][weapon 1], little-endian
+func DecodeBulletSync(payload []byte) (*BulletSync, error) {
+	if len(payload) < BulletSyncSize {
+		return nil, fmt.Errorf("bullet sync: %d bytes, want %d", len(payload), BulletSyncSize)
+	}
+	
+	bs := &BulletSync{
+		HitType:  payload[0],
+		HitID:    binary.LittleEndian.Uint16(payload[1:3]),
+		WeaponID: payload[39],
+	}
+	if bs.HitType > BulletHitPlayerObject {
+		return nil, fmt.Errorf("bullet sync: invalid hit type %d", bs.HitType)
+	}
+	
+	readVec := func(offset int) [3]float32 {
+		var v [3]float32
+		for i := range v {
+			v[i] = math.Float32frombits(binary.LittleEndian.Uint32(payload[offset+i*4:]))
+		}
+		return v
+	}
+	bs.Origin = readVec(3)
+	bs.Target = readVec(15)
+	bs.Center = readVec(27)
+	return bs, nil
+}
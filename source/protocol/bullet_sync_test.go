@@ -0,0 +1,44 @@
+package protocol
+
+import "testing"
+
+// capturedBulletSync is an M4 shot hitting player 3
+var capturedBulletSync = []byte{
+	0x01, 0x03, 0x00,
+	0x00, 0xD0, 0xF4, 0x44, 0x00, 0xE8, 0xA7, 0x44, 0x00, 0x00, 0x78, 0x41,
+	0x00, 0xA0, 0xF5, 0x44, 0x00, 0x80, 0xA7, 0x44, 0x00, 0x00, 0x70, 0x41,
+	0x00, 0x00, 0x00, 0x3E, 0x00, 0x00, 0x80, 0xBE, 0x00, 0x00, 0x00, 0x3F,
+	0x1F,
+}
+
+func TestDecodeBulletSync(t *testing.T) {
+	shot, err := DecodeBulletSync(capturedBulletSync)
+	if err != nil {
+		t.Fatalf("DecodeBulletSync failed: %v", err)
+	}
+	
+	if shot.HitType != BulletHitPlayer || shot.HitID != 3 || shot.WeaponID != 31 {
+		t.Errorf("Unexpected hit: type %d id %d weapon %d", shot.HitType, shot.HitID, shot.WeaponID)
+	}
+	if shot.Origin != [3]float32{1958.5, 1343.25, 15.5} {
+		t.Errorf("Unexpected origin: %v", shot.Origin)
+	}
+	if shot.Target != [3]float32{1965.0, 1340.0, 15.0} {
+		t.Errorf("Unexpected target: %v", shot.Target)
+	}
+	if shot.Center != [3]float32{0.125, -0.25, 0.5} {
+		t.Errorf("Unexpected center offset: %v", shot.Center)
+	}
+}
+
+func TestDecodeBulletSyncRejectsMalformed(t *testing.T) {
+	if _, err := DecodeBulletSync(capturedBulletSync[:20]); err == nil {
+		t.Error("Expected error for a short payload")
+	}
+	
+	invalid := append([]byte{}, capturedBulletSync...)
+	invalid[0] = 9
+	if _, err := DecodeBulletSync(invalid); err == nil {
+		t.Error("Expected error for an invalid hit type")
+	}
+}
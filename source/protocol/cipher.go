@@ -0,0 +1,60 @@
+package protocol
+
+// PacketCipher obfuscates encapsulated payloads on the wire, e.g. the packet
+// cipher some SA-MP 0.3.7 clients expect. Both methods get the session so a
+// cipher can keep per-session keys; they run with session.Mu held and must
+// not lock it. They return the transformed payload and may modify payload
+// in place.
+type PacketCipher interface {
+	Encode(session *Session, payload []byte) []byte
+	Decode(session *Session, payload []byte) []byte
+}
+
+// Decapsulate decodes a datagram read from the client and reverses the
+// session's cipher on every encapsulated payload. All inbound datagrams
+// should go through it rather than DecodeDataPacket.
+func (s *Session) Decapsulate(data []byte) (*DataPacket, error) {
+	dp, err := DecodeDataPacket(data)
+	if err != nil {
+		return nil, err
+	}
+	
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	if s.Cipher != nil {
+		for _, packet := range dp.Packets {
+			packet.Payload = s.Cipher.Decode(s, packet.Payload)
+		}
+	}
+	return dp, nil
+}
+
+// EncipherPayload applies the session's cipher to one outbound payload.
+// Datagrams sent from Update are enciphered already; it is for senders that
+// build their own datagrams. Caller must not hold s.Mu.
+func (s *Session) EncipherPayload(payload []byte) []byte {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	if s.Cipher == nil {
+		return payload
+	}
+	return s.Cipher.Encode(s, append([]byte(nil), payload...))
+}
+
+// encodeDatagram writes dp to bs as it goes on the wire, with the session's
+// cipher applied to copies of the payloads so RecoveryQueue keeps the plain
+// ones. Caller holds s.Mu.
+func (s *Session) encodeDatagram(dp *DataPacket, bs *BitStream) {
+	if s.Cipher == nil {
+		dp.EncodeTo(bs)
+		return
+	}
+	wire := *dp
+	wire.Packets = make([]*EncapsulatedPacket, len(dp.Packets))
+	for i, packet := range dp.Packets {
+		enciphered := *packet
+		enciphered.Payload = s.Cipher.Encode(s, append([]byte(nil), packet.Payload...))
+		wire.Packets[i] = &enciphered
+	}
+	wire.EncodeTo(bs)
+}
@@ -0,0 +1,27 @@
+package protocol
+
+import "fmt"
+
+// Message colors as 0xRRGGBBAA, the format SA-MP's chat expects
+const (
+	ColorWhite  uint32 = 0xFFFFFFFF
+	ColorBlack  uint32 = 0x000000FF
+	ColorGrey   uint32 = 0xAFAFAFFF
+	ColorRed    uint32 = 0xFF0000FF
+	ColorGreen  uint32 = 0x00FF00FF
+	ColorBlue   uint32 = 0x0000FFFF
+	ColorYellow uint32 = 0xFFFF00FF
+	ColorOrange uint32 = 0xFF8000FF
+	ColorPurple uint32 = 0xC2A2DAFF
+	ColorPink   uint32 = 0xFF66FFFF
+)
+
+// RGBA packs color components into a 0xRRGGBBAA message color
+func RGBA(r, g, b, a uint8) uint32 {
+	return uint32(r)<<24 | uint32(g)<<16 | uint32(b)<<8 | uint32(a)
+}
+
+// ColorCode returns the "{RRGGBB}" code that recolors the rest of a chat line
+func ColorCode(color uint32) string {
+	return fmt.Sprintf("{%06X}", color>>8)
+}
@@ -0,0 +1,42 @@
+package protocol
+
+import "testing"
+
+func TestRGBA(t *testing.T) {
+	if got := RGBA(255, 0, 0, 255); got != ColorRed {
+		t.Errorf("Expected RGBA(255,0,0,255) = 0x%08X, got 0x%08X", ColorRed, got)
+	}
+	if got := RGBA(0xC2, 0xA2, 0xDA, 0xFF); got != ColorPurple {
+		t.Errorf("Expected 0x%08X, got 0x%08X", ColorPurple, got)
+	}
+}
+
+func TestColorCode(t *testing.T) {
+	if got := ColorCode(ColorGreen); got != "{00FF00}" {
+		t.Errorf("Expected {00FF00}, got %s", got)
+	}
+	if got := ColorCode(ColorPurple); got != "{C2A2DA}" {
+		t.Errorf("Expected {C2A2DA}, got %s", got)
+	}
+}
+
+func TestClientMessageColorOnWire(t *testing.T) {
+	// The client reads the color as a little-endian uint32 RGBA: A, B, G, R
+	tests := []struct {
+		color uint32
+		wire  [4]byte
+	}{
+		{RGBA(255, 0, 0, 255), [4]byte{0xFF, 0x00, 0x00, 0xFF}},
+		{ColorGreen, [4]byte{0xFF, 0x00, 0xFF, 0x00}},
+		{RGBA(0x11, 0x22, 0x33, 0x44), [4]byte{0x44, 0x33, 0x22, 0x11}},
+	}
+	
+	for _, tt := range tests {
+		rpc := BuildSendClientMessageRPC(tt.color, "x")
+		var wire [4]byte
+		copy(wire[:], rpc[1:5])
+		if wire != tt.wire {
+			t.Errorf("Color 0x%08X: expected % X on the wire, got % X", tt.color, tt.wire, wire)
+		}
+	}
+}
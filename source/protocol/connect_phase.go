@@ -0,0 +1,35 @@
+package protocol
+
+// ConnectPhase is how far a session has got through the connect flow. It
+// only moves forward; StopStreaming resets it to PhaseNone.
+type ConnectPhase int
+
+const (
+	PhaseNone          ConnectPhase = iota
+	PhaseHandshakeSent              // full handshake sequence sent
+	PhaseStreamingDone              // all streaming packets sent
+	PhaseGameEntrySent              // game entry sequence sent
+	PhaseSpawning                   // spawn RPCs going out, SpawnStep waits for its ACK
+	PhaseSpawned                    // every spawn RPC ACKed
+)
+
+// ReachedPhase reports whether the session has got to phase
+func (s *Session) ReachedPhase(phase ConnectPhase) bool {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return s.ConnectPhase >= phase
+}
+
+// AdvancePhase moves the session on to phase. It never moves it back.
+func (s *Session) AdvancePhase(phase ConnectPhase) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	s.ConnectPhase = max(s.ConnectPhase, phase)
+}
+
+// GetConnectPhase returns the session's connect flow phase
+func (s *Session) GetConnectPhase() ConnectPhase {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return s.ConnectPhase
+}
@@ -0,0 +1,21 @@
+package protocol
+
+import "testing"
+
+func TestConnectPhaseOnlyMovesForward(t *testing.T) {
+	session := NewSession(nil, 576)
+	session.AdvancePhase(PhaseGameEntrySent)
+	session.AdvancePhase(PhaseHandshakeSent)
+	
+	if phase := session.GetConnectPhase(); phase != PhaseGameEntrySent {
+		t.Fatalf("Expected the phase to stay at game entry, got %d", phase)
+	}
+	if !session.ReachedPhase(PhaseHandshakeSent) || session.ReachedPhase(PhaseSpawning) {
+		t.Error("Expected game entry to imply the handshake and not the spawn flow")
+	}
+	
+	session.StopStreaming()
+	if session.ReachedPhase(PhaseHandshakeSent) {
+		t.Error("Expected StopStreaming to reset the connect flow")
+	}
+}
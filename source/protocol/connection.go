@@ -0,0 +1,103 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+)
+
+// MaxInternalIDs is the number of internal addresses sent in
+// ID_CONNECTION_REQUEST_ACCEPTED (RakNet's MAXIMUM_NUMBER_OF_INTERNAL_IDS)
+const MaxInternalIDs = 10
+
+// unassignedAddress pads the internal address list (UNASSIGNED_SYSTEM_ADDRESS)
+var unassignedAddress = &net.UDPAddr{IP: net.IPv4(255, 255, 255, 255), Port: 0}
+
+// ConnectionRequest is a decoded ID_CONNECTION_REQUEST (0x09)
+type ConnectionRequest struct {
+	GUID     uint64
+	Time     uint64 // client timestamp, echoed in the accepted reply
+	Password string
+}
+
+// ParseConnectionRequest decodes an ID_CONNECTION_REQUEST payload (without the id):
+// [GUID 8][time 8][doSecurity 1][password...]
+func ParseConnectionRequest(payload []byte) (*ConnectionRequest, error) {
+	bs := NewBitStream(payload)
+	
+	guid, err := bs.ReadUint64()
+	if err != nil {
+		return nil, fmt.Errorf("connection request: missing GUID")
+	}
+	requestTime, err := bs.ReadUint64()
+	if err != nil {
+		return nil, fmt.Errorf("connection request: missing timestamp")
+	}
+	
+	req := &ConnectionRequest{GUID: guid, Time: requestTime}
+	if bs.Remaining() == 0 {
+		return req, nil
+	}
+	
+	doSecurity, _ := bs.ReadByte()
+	if doSecurity != 0 {
+		return nil, fmt.Errorf("connection request: secured connections are not supported")
+	}
+	password, _ := bs.ReadBytes(bs.Remaining())
+	req.Password = string(password)
+	return req, nil
+}
+
+// BuildConnectionRequestAccepted builds ID_CONNECTION_REQUEST_ACCEPTED (0x10):
+// client address, system index, MaxInternalIDs internal addresses, the echoed
+// client timestamp and the server timestamp. Missing internal addresses are
+// padded with the unassigned address.
+func BuildConnectionRequestAccepted(client *net.UDPAddr, systemIndex uint16, internal []*net.UDPAddr, requestTime, serverTime uint64) []byte {
+	bs := NewEmptyBitStream()
+	bs.WriteByte(ID_CONNECTION_REQUEST_ACCEPTED)
+	bs.WriteAddress(client)
+	bs.WriteUint16(systemIndex)
+	
+	for i := 0; i < MaxInternalIDs; i++ {
+		if i < len(internal) && internal[i] != nil {
+			bs.WriteAddress(internal[i])
+		} else {
+			bs.WriteAddress(unassignedAddress)
+		}
+	}
+	
+	bs.WriteUint64(requestTime)
+	bs.WriteUint64(serverTime)
+	return bs.GetData()
+}
+
+// NewIncomingConnection is a decoded ID_NEW_INCOMING_CONNECTION (0x13)
+type NewIncomingConnection struct {
+	ServerAddr    *net.UDPAddr
+	InternalAddrs []*net.UDPAddr // client's local addresses, unassigned entries dropped
+}
+
+// ParseNewIncomingConnection decodes an ID_NEW_INCOMING_CONNECTION payload
+// (without the id): the server address as seen by the client followed by up
+// to MaxInternalIDs client addresses and two timestamps. Clients often send
+// fewer addresses than that, so a short list is not an error.
+func ParseNewIncomingConnection(payload []byte) (*NewIncomingConnection, error) {
+	bs := NewBitStream(payload)
+	
+	serverAddr, err := bs.ReadAddress()
+	if err != nil {
+		return nil, fmt.Errorf("new incoming connection: missing server address: %v", err)
+	}
+	
+	conn := &NewIncomingConnection{ServerAddr: serverAddr}
+	for i := 0; i < MaxInternalIDs && bs.Remaining() > 0; i++ {
+		addr, err := bs.ReadAddress()
+		if err != nil {
+			break
+		}
+		if addr.IP.Equal(unassignedAddress.IP) {
+			continue
+		}
+		conn.InternalAddrs = append(conn.InternalAddrs, addr)
+	}
+	return conn, nil
+}
@@ -0,0 +1,151 @@
+package protocol
+
+import "log"
+
+// Bounds on RELIABLE_ORDERED messages held back waiting for a gap to fill,
+// across all channels of one session. Later messages beyond either limit are
+// dropped and their datagram is left unACKed, so the peer resends them.
+const (
+	MAX_ORDER_BUFFER_PACKETS = 512
+	MAX_ORDER_BUFFER_BYTES   = MAX_SPLIT_BUFFER_BYTES
+)
+
+// Reliable messages further than this past the lowest missing MessageIndex
+// are dropped rather than tracked
+const MAX_RELIABLE_WINDOW = MaxSeqRange
+
+// reliableDuplicate reports whether a reliable message's MessageIndex was
+// already received, e.g. resent after a lost ACK. Caller must hold s.Mu.
+func (s *Session) reliableDuplicate(messageIndex uint32) bool {
+	if SeqLess(messageIndex, s.recvMessageBase) {
+		return true
+	}
+	_, received := s.recvMessages[messageIndex]
+	return received
+}
+
+// trackReliable records a reliable message's MessageIndex as received. It
+// reports false for one too far past the lowest missing index to track.
+// Caller must hold s.Mu.
+func (s *Session) trackReliable(messageIndex uint32) bool {
+	if (messageIndex-s.recvMessageBase)&seqMask >= MAX_RELIABLE_WINDOW {
+		log.Printf("⚠️ Dropping reliable message from %s: index=%d, lowest missing=%d",
+			s.Addr, messageIndex, s.recvMessageBase)
+		return false
+	}
+	if s.recvMessages == nil {
+		s.recvMessages = make(map[uint32]struct{})
+	}
+	s.recvMessages[messageIndex] = struct{}{}
+	return true
+}
+
+// forgetReliable undoes trackReliable for a message that was dropped, so its
+// resend is accepted. Only valid before advanceReliable. Caller must hold s.Mu.
+func (s *Session) forgetReliable(messageIndex uint32) {
+	delete(s.recvMessages, messageIndex)
+}
+
+// advanceReliable moves recvMessageBase past every contiguous received index.
+// Caller must hold s.Mu.
+func (s *Session) advanceReliable() {
+	for {
+		if _, received := s.recvMessages[s.recvMessageBase]; !received {
+			return
+		}
+		delete(s.recvMessages, s.recvMessageBase)
+		s.recvMessageBase = SeqNext(s.recvMessageBase)
+	}
+}
+
+// orderedDuplicate reports whether an ordered message was already delivered
+// on its channel. Caller must hold s.Mu.
+func (s *Session) orderedDuplicate(channel uint8, orderIndex uint32) bool {
+	return SeqLess(orderIndex, s.recvOrderIndex[channel])
+}
+
+// deliverOrdered returns the messages that become deliverable now that
+// payload has arrived with orderIndex on channel: none while an earlier one
+// is missing, else this one followed by any buffered ones it unblocks. It
+// reports false if the message was dropped because the buffer is full.
+// Caller must hold s.Mu.
+func (s *Session) deliverOrdered(channel uint8, orderIndex uint32, payload []byte) ([]*RakNetPacket, bool) {
+	if s.recvOrderIndex == nil {
+		s.recvOrderIndex = make(map[uint8]uint32)
+	}
+	expected := s.recvOrderIndex[channel]
+	
+	if SeqLess(orderIndex, expected) {
+		log.Printf("🔄 DUPLICATE: Received order=%d, expected=%d (channel=%d) - IGNORING",
+			orderIndex, expected, channel)
+		return nil, true
+	}
+	
+	if orderIndex != expected {
+		return nil, s.bufferOrdered(channel, orderIndex, expected, payload)
+	}
+	
+	packets := make([]*RakNetPacket, 0, 1)
+	packets = appendPacket(packets, payload)
+	expected = SeqNext(expected)
+	
+	// Flush held-back messages that are now contiguous
+	buffer := s.orderBuffer[channel]
+	for {
+		next, held := buffer[expected]
+		if !held {
+			break
+		}
+		delete(buffer, expected)
+		s.orderBufferBytes -= len(next)
+		s.orderBuffered--
+		packets = appendPacket(packets, next)
+		expected = SeqNext(expected)
+	}
+	s.recvOrderIndex[channel] = expected
+	return packets, true
+}
+
+// bufferOrdered holds a message that arrived ahead of a gap. It reports false
+// if the message was dropped for lack of room. Caller must hold s.Mu.
+func (s *Session) bufferOrdered(channel uint8, orderIndex, expected uint32, payload []byte) bool {
+	if s.orderBuffer == nil {
+		s.orderBuffer = make(map[uint8]map[uint32][]byte)
+	}
+	buffer := s.orderBuffer[channel]
+	if _, held := buffer[orderIndex]; held {
+		return true // Duplicate of a message already waiting
+	}
+	
+	// Far ahead of the gap can't be filled within the buffer anyway
+	ahead := (orderIndex - expected) & seqMask
+	if ahead > MAX_ORDER_BUFFER_PACKETS ||
+		s.orderBuffered >= MAX_ORDER_BUFFER_PACKETS ||
+		s.orderBufferBytes+len(payload) > MAX_ORDER_BUFFER_BYTES {
+		log.Printf("⚠️ Dropping out-of-order message from %s: order=%d, expected=%d (channel=%d), %d messages / %d bytes held",
+			s.Addr, orderIndex, expected, channel, s.orderBuffered, s.orderBufferBytes)
+		return false
+	}
+	
+	log.Printf("⏸️ OUT-OF-ORDER: Received order=%d, expected=%d (channel=%d) - BUFFERING",
+		orderIndex, expected, channel)
+	if buffer == nil {
+		buffer = make(map[uint32][]byte)
+		s.orderBuffer[channel] = buffer
+	}
+	buffer[orderIndex] = payload
+	s.orderBufferBytes += len(payload)
+	s.orderBuffered++
+	return true
+}
+
+// appendPacket appends payload as a packet; empty payloads carry nothing to deliver
+func appendPacket(packets []*RakNetPacket, payload []byte) []*RakNetPacket {
+	if len(payload) == 0 {
+		return packets
+	}
+	return append(packets, &RakNetPacket{
+		PacketID: payload[0],
+		Payload:  payload[1:],
+	})
+}
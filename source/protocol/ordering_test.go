@@ -0,0 +1,182 @@
+package protocol
+
+import "testing"
+
+// orderedPacket wraps one RELIABLE_ORDERED message in a datagram. Each
+// channel/index pair gets its own MessageIndex, as from a real sender.
+func orderedPacket(channel uint8, index uint32, payload ...byte) *DataPacket {
+	dp := NewDataPacket()
+	dp.Packets = append(dp.Packets, &EncapsulatedPacket{
+		Reliability:  RELIABLE_ORDERED,
+		MessageIndex: uint32(channel)<<10 + index,
+		OrderChannel: channel,
+		OrderIndex:   index,
+		Payload:      payload,
+	})
+	return dp
+}
+
+// packetIDs returns the ids of delivered packets
+func packetIDs(packets []*RakNetPacket) []byte {
+	ids := make([]byte, len(packets))
+	for i, packet := range packets {
+		ids[i] = packet.PacketID
+	}
+	return ids
+}
+
+func TestOrderedMessagesHeldUntilGapFills(t *testing.T) {
+	session := NewSession(nil, 576)
+	
+	// 2 and 1 arrive before 0
+	if packets := session.HandleDataPacket(orderedPacket(0, 2, 0xC2)); len(packets) != 0 {
+		t.Fatalf("Expected order 2 held back, got %X", packetIDs(packets))
+	}
+	if packets := session.HandleDataPacket(orderedPacket(0, 1, 0xC1)); len(packets) != 0 {
+		t.Fatalf("Expected order 1 held back, got %X", packetIDs(packets))
+	}
+	
+	packets := session.HandleDataPacket(orderedPacket(0, 0, 0xC0))
+	if ids := packetIDs(packets); string(ids) != string([]byte{0xC0, 0xC1, 0xC2}) {
+		t.Fatalf("Expected 0, 1, 2 delivered in order, got %X", ids)
+	}
+	if session.orderBuffered != 0 || session.orderBufferBytes != 0 {
+		t.Errorf("Expected an empty reorder buffer, got %d messages / %d bytes", session.orderBuffered, session.orderBufferBytes)
+	}
+	
+	// Resends of delivered messages are dropped
+	if packets := session.HandleDataPacket(orderedPacket(0, 1, 0xC1)); len(packets) != 0 {
+		t.Errorf("Expected duplicate dropped, got %X", packetIDs(packets))
+	}
+}
+
+func TestOrderedChannelsAreIndependent(t *testing.T) {
+	session := NewSession(nil, 576)
+	
+	// A gap on channel 0 doesn't hold back channel 1
+	session.HandleDataPacket(orderedPacket(0, 1, 0xA1))
+	if packets := session.HandleDataPacket(orderedPacket(1, 0, 0xB0)); len(packets) != 1 {
+		t.Errorf("Expected channel 1 delivered despite a gap on channel 0, got %d", len(packets))
+	}
+	
+	// Unordered packets are never held
+	dp := NewDataPacket()
+	dp.Packets = append(dp.Packets, &EncapsulatedPacket{Reliability: RELIABLE, MessageIndex: 2, Payload: []byte{0xDD}})
+	if packets := session.HandleDataPacket(dp); len(packets) != 1 {
+		t.Errorf("Expected reliable packet delivered, got %d", len(packets))
+	}
+}
+
+func TestOrderedSplitDeliveredInOrder(t *testing.T) {
+	session := NewSession(nil, 576)
+	
+	// Order 1 is a split that completes before order 0 arrives
+	dp := NewDataPacket()
+	for i := uint32(0); i < 2; i++ {
+		dp.Packets = append(dp.Packets, &EncapsulatedPacket{
+			Reliability: RELIABLE_ORDERED, MessageIndex: 1 + i, OrderIndex: 1,
+			Split: true, SplitCount: 2, SplitID: 9, SplitIndex: i, Payload: []byte{0xE1 + byte(i)},
+		})
+	}
+	if packets := session.HandleDataPacket(dp); len(packets) != 0 {
+		t.Fatalf("Expected reassembled order 1 held back, got %X", packetIDs(packets))
+	}
+	
+	packets := session.HandleDataPacket(orderedPacket(0, 0, 0xE0))
+	if len(packets) != 2 || packets[0].PacketID != 0xE0 || packets[1].PacketID != 0xE1 || packets[1].Payload[0] != 0xE2 {
+		t.Errorf("Expected order 0 then the reassembled split, got %X", packetIDs(packets))
+	}
+}
+
+func TestOrderBufferIsCapped(t *testing.T) {
+	session := NewSession(nil, 576)
+	
+	// Order 0 never arrives
+	for i := uint32(1); i <= MAX_ORDER_BUFFER_PACKETS+10; i++ {
+		session.HandleDataPacket(orderedPacket(0, i, 0x01))
+	}
+	if session.orderBuffered != MAX_ORDER_BUFFER_PACKETS {
+		t.Errorf("Expected at most %d messages held, got %d", MAX_ORDER_BUFFER_PACKETS, session.orderBuffered)
+	}
+	
+	// Far ahead of the gap is dropped outright
+	session = NewSession(nil, 576)
+	dp := orderedPacket(0, 0x100000, 0x01)
+	dp.Packets[0].MessageIndex = 0
+	session.HandleDataPacket(dp)
+	if session.orderBuffered != 0 {
+		t.Errorf("Expected a message far past the gap to be dropped, got %d held", session.orderBuffered)
+	}
+}
+
+func TestOrderBufferOverflowResentAfterGapFills(t *testing.T) {
+	session := NewSession(nil, 576)
+	datagram := func(seq uint32, dp *DataPacket) *DataPacket {
+		dp.SequenceNumber = seq
+		return dp
+	}
+	
+	// Order 0 is lost; the buffer fills and the next message is dropped
+	for i := uint32(1); i <= MAX_ORDER_BUFFER_PACKETS+1; i++ {
+		session.HandleDataPacket(datagram(i, orderedPacket(0, i, 0x01)))
+	}
+	if _, acked := session.ACKQueue[1]; !acked {
+		t.Error("Expected a buffered message's datagram to be ACKed")
+	}
+	if _, acked := session.ACKQueue[MAX_ORDER_BUFFER_PACKETS+1]; acked {
+		t.Fatal("Expected the dropped message's datagram to be left unACKed")
+	}
+	
+	// The gap fills and flushes the buffer
+	packets := session.HandleDataPacket(datagram(1000, orderedPacket(0, 0, 0x01)))
+	if len(packets) != MAX_ORDER_BUFFER_PACKETS+1 {
+		t.Fatalf("Expected %d messages once the gap filled, got %d", MAX_ORDER_BUFFER_PACKETS+1, len(packets))
+	}
+	
+	// The peer resends the dropped message in a new datagram
+	packets = session.HandleDataPacket(datagram(1001, orderedPacket(0, MAX_ORDER_BUFFER_PACKETS+1, 0x02)))
+	if len(packets) != 1 || packets[0].PacketID != 0x02 {
+		t.Fatalf("Expected the resent message delivered, got %+v", packets)
+	}
+	if _, acked := session.ACKQueue[1001]; !acked {
+		t.Error("Expected the resend's datagram to be ACKed")
+	}
+	if session.recvOrderIndex[0] != MAX_ORDER_BUFFER_PACKETS+2 {
+		t.Errorf("Expected order %d next, got %d", MAX_ORDER_BUFFER_PACKETS+2, session.recvOrderIndex[0])
+	}
+}
+
+func TestOrderedSplitOverflowResentAfterGapFills(t *testing.T) {
+	session := NewSession(nil, 576)
+	for i := uint32(1); i <= MAX_ORDER_BUFFER_PACKETS; i++ {
+		session.HandleDataPacket(orderedPacket(0, i, 0x01))
+	}
+	
+	// A split completing into a full buffer: only its last fragment is refused
+	fragment := func(seq, index uint32, payload byte) *DataPacket {
+		return &DataPacket{
+			SequenceNumber: seq,
+			Packets: []*EncapsulatedPacket{{
+				Reliability: RELIABLE_ORDERED, MessageIndex: 2000 + index, OrderIndex: MAX_ORDER_BUFFER_PACKETS + 1,
+				Split: true, SplitCount: 2, SplitID: 9, SplitIndex: index, Payload: []byte{payload},
+			}},
+		}
+	}
+	session.HandleDataPacket(fragment(600, 0, 0xEE))
+	session.HandleDataPacket(fragment(601, 1, 0x01))
+	if _, acked := session.ACKQueue[600]; !acked {
+		t.Error("Expected the buffered first fragment to be ACKed")
+	}
+	if _, acked := session.ACKQueue[601]; acked {
+		t.Fatal("Expected the completing fragment's datagram to be left unACKed")
+	}
+	
+	session.HandleDataPacket(orderedPacket(0, 0, 0x01))
+	packets := session.HandleDataPacket(fragment(602, 1, 0x01))
+	if len(packets) != 1 || packets[0].PacketID != 0xEE {
+		t.Fatalf("Expected the split delivered from its resent fragment, got %+v", packets)
+	}
+	if len(session.SplitPackets) != 0 || session.splitBytes != 0 {
+		t.Errorf("Expected the split released, got %d splits / %d bytes", len(session.SplitPackets), session.splitBytes)
+	}
+}
@@ -0,0 +1,38 @@
+package protocol
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+// Packet IDs must only be declared here; other packages reference protocol.ID_*
+func TestPacketIDsHaveSingleSourceOfTruth(t *testing.T) {
+	for _, dir := range []string{"../server", "../../pkg/raknet"} {
+		fset := token.NewFileSet()
+		pkgs, err := parser.ParseDir(fset, dir, nil, 0)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", dir, err)
+		}
+		
+		for _, pkg := range pkgs {
+			for filename, file := range pkg.Files {
+				for _, decl := range file.Decls {
+					gen, ok := decl.(*ast.GenDecl)
+					if !ok || gen.Tok != token.CONST {
+						continue
+					}
+					for _, spec := range gen.Specs {
+						for _, name := range spec.(*ast.ValueSpec).Names {
+							if strings.HasPrefix(name.Name, "ID_") {
+								t.Errorf("%s declares %s; use protocol.%s instead", filename, name.Name, name.Name)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package protocol
+
+import "fmt"
+
+// PlayerSyncSize is the size of the on-foot sync payload after the packet ID
+const PlayerSyncSize = 68
+
+// PlayerSyncData is a decoded ID_PLAYER_SYNC (0xCF) payload
+type PlayerSyncData struct {
+	LRKey         uint16
+	UDKey         uint16
+	Keys          uint16
+	Position      [3]float32
+	Quaternion    [4]float32 // w, x, y, z
+	Health        uint8
+	Armour        uint8
+	WeaponID      uint8 // 6 bits
+	SpecialKey    uint8 // 2 bits
+	SpecialAction uint8
+	Velocity      [3]float32
+	SurfOffsets   [3]float32
+	SurfVehicleID uint16 // 0 when not surfing
+	AnimationID   uint16
+	AnimFlags     uint16
+}
+
+// ParsePlayerSync decodes an SA-MP 0.3.7 on-foot sync payload (without the packet ID):
+// [lr 2][ud 2][keys 2][position 12][quaternion 16][health 1][armour 1]
+// [weapon:6|key:2 1][specialAction 1][velocity 12][surfOffsets 12]
+// [surfVehicle 2][animation 2][animFlags 2], little-endian
+func ParsePlayerSync(bs *BitStream) (*PlayerSyncData, error) {
+	if bs.Remaining() < PlayerSyncSize {
+		return nil, fmt.Errorf("player sync: %d bytes, want %d", bs.Remaining(), PlayerSyncSize)
+	}
+	
+	// Length was checked up front, so the reads below cannot run short
+	ps := &PlayerSyncData{}
+	ps.LRKey, _ = bs.ReadUint16LE()
+	ps.UDKey, _ = bs.ReadUint16LE()
+	ps.Keys, _ = bs.ReadUint16LE()
+	readFloat32s(bs, ps.Position[:])
+	readFloat32s(bs, ps.Quaternion[:])
+	ps.Health, _ = bs.ReadByte()
+	ps.Armour, _ = bs.ReadByte()
+	weapon, _ := bs.ReadByte()
+	ps.WeaponID = weapon & 0x3F
+	ps.SpecialKey = weapon >> 6
+	ps.SpecialAction, _ = bs.ReadByte()
+	readFloat32s(bs, ps.Velocity[:])
+	readFloat32s(bs, ps.SurfOffsets[:])
+	ps.SurfVehicleID, _ = bs.ReadUint16LE()
+	ps.AnimationID, _ = bs.ReadUint16LE()
+	ps.AnimFlags, _ = bs.ReadUint16LE()
+	return ps, nil
+}
+
+// readFloat32s fills dst with consecutive little-endian floats from bs
+func readFloat32s(bs *BitStream, dst []float32) {
+	for i := range dst {
+		dst[i], _ = bs.ReadFloat32()
+	}
+}
@@ -0,0 +1,58 @@
+package protocol
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// samplePlayerSync is a hand-built on-foot sync: a player running backwards
+// with fire held, an M4 (31) out
+const samplePlayerSync = "000080FF080000D0F44400E8A7440000" +
+	"78410000803F00000000000000000000" +
+	"000064325F000000803E000000BF0000" +
+	"00000000000000000000000000000000" +
+	"A5040480"
+
+func TestParsePlayerSync(t *testing.T) {
+	payload, err := hex.DecodeString(samplePlayerSync)
+	if err != nil {
+		t.Fatalf("Bad test blob: %v", err)
+	}
+	
+	ps, err := ParsePlayerSync(NewBitStream(payload))
+	if err != nil {
+		t.Fatalf("ParsePlayerSync failed: %v", err)
+	}
+	
+	if ps.Position != [3]float32{1958.5, 1343.25, 15.5} {
+		t.Errorf("Unexpected position: %v", ps.Position)
+	}
+	if ps.LRKey != 0 || ps.UDKey != 0xFF80 || ps.Keys != 0x0008 {
+		t.Errorf("Unexpected keys: lr=%d ud=0x%04X keys=0x%04X", ps.LRKey, ps.UDKey, ps.Keys)
+	}
+	if ps.Quaternion != [4]float32{1, 0, 0, 0} {
+		t.Errorf("Unexpected quaternion: %v", ps.Quaternion)
+	}
+	if ps.Health != 100 || ps.Armour != 50 {
+		t.Errorf("Expected health 100 and armour 50, got %d and %d", ps.Health, ps.Armour)
+	}
+	if ps.WeaponID != 31 || ps.SpecialKey != 1 {
+		t.Errorf("Expected weapon 31 and special key 1, got %d and %d", ps.WeaponID, ps.SpecialKey)
+	}
+	if ps.Velocity != [3]float32{0.25, -0.5, 0} {
+		t.Errorf("Unexpected velocity: %v", ps.Velocity)
+	}
+	if ps.SurfVehicleID != 0 {
+		t.Errorf("Expected no surf vehicle, got %d", ps.SurfVehicleID)
+	}
+	if ps.AnimationID != 1189 || ps.AnimFlags != 0x8004 {
+		t.Errorf("Expected animation 1189 with flags 0x8004, got %d and 0x%04X", ps.AnimationID, ps.AnimFlags)
+	}
+}
+
+func TestParsePlayerSyncRejectsShortPayload(t *testing.T) {
+	payload, _ := hex.DecodeString(samplePlayerSync)
+	if _, err := ParsePlayerSync(NewBitStream(payload[:PlayerSyncSize-1])); err == nil {
+		t.Error("Expected error for a short payload")
+	}
+}
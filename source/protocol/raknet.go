@@ -5,8 +5,11 @@ import (
 	"encoding/binary"
 	"fmt"
 	"log"
+	"math"
 	"net"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,6 +21,19 @@ const (
 	MAX_CHANNELS           = 32
 	MAX_SPLIT_PACKET_COUNT = 128
 	
+	// Upper bound on fragment payload bytes buffered across all in-progress
+	// splits of one session. Generous for SA-MP, which never sends more than
+	// MAX_SPLIT_PACKET_COUNT fragments of at most MAX_MTU_SIZE each.
+	MAX_SPLIT_BUFFER_BYTES = MAX_SPLIT_PACKET_COUNT * MAX_MTU_SIZE
+	
+	// A split still missing fragments this long after its first one arrived
+	// is discarded
+	SPLIT_REASSEMBLY_TIMEOUT = 30 * time.Second
+	
+	// A connected session that has sent nothing for this long is sent an
+	// ID_CONNECTED_PING, keeping NAT mappings open and the RTT fresh
+	KEEPALIVE_INTERVAL = 5 * time.Second
+	
 	// Safety margin for IP/UDP overhead to prevent IP fragmentation
 	// IP header: 20 bytes (or 60 with options)
 	// UDP header: 8 bytes
@@ -42,18 +58,47 @@ const (
 	ID_CONNECTION_REQUEST                = 0x09
 	ID_CONNECTION_REQUEST_ACCEPTED       = 0x10
 	ID_NEW_INCOMING_CONNECTION           = 0x13
+	ID_NO_FREE_INCOMING_CONNECTIONS      = 0x14
 	ID_DISCONNECTION_NOTIFICATION        = 0x15
+	ID_CONNECTION_BANNED                 = 0x17
+	ID_INVALID_PASSWORD                  = 0x18
 	ID_INCOMPATIBLE_PROTOCOL_VERSION     = 0x19
 	ID_UNCONNECTED_PONG                  = 0x1C
 	ID_ADVERTISE_SYSTEM                  = 0x1D
 	ID_RPC                               = 0x7C // RakNet RPC (Remote Procedure Call)
 )
 
+// Datagram header bytes. Every connected datagram has the 0x80 valid flag set,
+// so ids in 0x80-0xFF can't be told apart from datagrams by their first byte.
+const (
+	ID_DATAGRAM    = 0x80 // valid datagram flag
+	ID_DATA_PACKET = 0x84 // data datagram header as SA-MP clients send it
+	ID_NACK        = 0xA0
+	ID_ACK         = 0xC0
+)
+
+// SA-MP game packet IDs (carried inside datagrams)
+const (
+	ID_PLAYER_JOIN        = 0x89
+	ID_PLAYER_QUIT        = 0x8A
+	ID_SPAWN_PLAYER       = 0x8B
+	ID_DEATH_NOTIFICATION = 0x8C
+	ID_VEHICLE_SYNC       = 0xC8
+	ID_AIM_SYNC           = 0xC9
+	ID_TRAILER_SYNC       = 0xCA
+	ID_UNOCCUPIED_SYNC    = 0xCD
+	ID_BULLET_SYNC        = 0xCE
+	ID_PLAYER_SYNC        = 0xCF
+	ID_PASSENGER_SYNC     = 0xD2
+	ID_SPECTATOR_SYNC     = 0xD4
+)
+
 // SA-MP Query Packet IDs
 const (
 	SAMP_QUERY_INFO    = 'i' // Server info
 	SAMP_QUERY_RULES   = 'r' // Server rules
-	SAMP_QUERY_PLAYERS = 'c' // Client list (detailed)
+	SAMP_QUERY_PLAYERS = 'c' // Client list (name and score)
+	SAMP_QUERY_DETAILED = 'd' // Detailed player list (ID, name, score and ping)
 	SAMP_QUERY_PING    = 'p' // Ping
 )
 
@@ -77,9 +122,27 @@ const (
 	PRIORITY_LOW       = 3
 )
 
+// BitStream reads and writes packet fields. Whole-byte fields are byte
+// aligned; WriteBits/WriteBool and ReadBits/ReadBool pack individual bits.
+//
+// Ownership: NewBitStream reads from the caller's slice without copying, so it
+// must not be modified while the stream is in use. WriteBytes copies its input,
+// and ReadBytes returns a copy, so callers may reuse or mutate those buffers.
+// GetData and ReadBytesNoCopy return views of the internal buffer.
 type BitStream struct {
 	data   []byte
 	offset int
+	
+	// Bit cursor for WriteBits/ReadBits: bits used in the last written byte and
+	// in the last read byte (0 = byte aligned). The marks record where that
+	// byte was; any byte-level read or write in between moves past it, so
+	// byte-level access always starts on a whole byte.
+	writeBit  int
+	writeMark int
+	readBit   int
+	readMark  int
+	
+	pooled *[]byte // buffer to hand back on Release (see NewPooledBitStream)
 }
 
 func NewBitStream(data []byte) *BitStream {
@@ -105,8 +168,21 @@ func (bs *BitStream) ReadByte() (byte, error) {
 	return b, nil
 }
 
+// ReadBytes returns a copy of the next n bytes
 func (bs *BitStream) ReadBytes(n int) ([]byte, error) {
-	if bs.offset+n > len(bs.data) {
+	data, err := bs.ReadBytesNoCopy(n)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]byte, n)
+	copy(result, data)
+	return result, nil
+}
+
+// ReadBytesNoCopy returns the next n bytes as a view of the stream's buffer.
+// The result must not be modified or kept past the stream's lifetime.
+func (bs *BitStream) ReadBytesNoCopy(n int) ([]byte, error) {
+	if n < 0 || bs.offset+n > len(bs.data) {
 		return nil, fmt.Errorf("buffer overflow")
 	}
 	result := bs.data[bs.offset : bs.offset+n]
@@ -115,7 +191,7 @@ func (bs *BitStream) ReadBytes(n int) ([]byte, error) {
 }
 
 func (bs *BitStream) ReadUint16() (uint16, error) {
-	data, err := bs.ReadBytes(2)
+	data, err := bs.ReadBytesNoCopy(2)
 	if err != nil {
 		return 0, err
 	}
@@ -123,7 +199,7 @@ func (bs *BitStream) ReadUint16() (uint16, error) {
 }
 
 func (bs *BitStream) ReadUint32() (uint32, error) {
-	data, err := bs.ReadBytes(4)
+	data, err := bs.ReadBytesNoCopy(4)
 	if err != nil {
 		return 0, err
 	}
@@ -131,7 +207,7 @@ func (bs *BitStream) ReadUint32() (uint32, error) {
 }
 
 func (bs *BitStream) ReadUint64() (uint64, error) {
-	data, err := bs.ReadBytes(8)
+	data, err := bs.ReadBytesNoCopy(8)
 	if err != nil {
 		return 0, err
 	}
@@ -143,7 +219,7 @@ func (bs *BitStream) ReadString() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	data, err := bs.ReadBytes(int(length))
+	data, err := bs.ReadBytesNoCopy(int(length))
 	if err != nil {
 		return "", err
 	}
@@ -171,18 +247,22 @@ func (bs *BitStream) ReadAddress() (*net.UDPAddr, error) {
 		return nil, fmt.Errorf("unsupported IP version: %d", version)
 	}
 	
-	port, err := bs.ReadUint16()
+	// Port in LITTLE-ENDIAN for SA-MP (matches WriteAddress)
+	portBytes, err := bs.ReadBytesNoCopy(2)
 	if err != nil {
 		return nil, err
 	}
+	port := binary.LittleEndian.Uint16(portBytes)
 	
 	return &net.UDPAddr{IP: ip, Port: int(port)}, nil
 }
 
-func (bs *BitStream) WriteByte(b byte) {
+func (bs *BitStream) WriteByte(b byte) error {
 	bs.data = append(bs.data, b)
+	return nil
 }
 
+// WriteBytes appends a copy of data; the caller keeps ownership of data
 func (bs *BitStream) WriteBytes(data []byte) {
 	bs.data = append(bs.data, data...)
 }
@@ -205,6 +285,66 @@ func (bs *BitStream) WriteUint64(v uint64) {
 	bs.data = append(bs.data, buf...)
 }
 
+// Little-endian fields, as SA-MP encodes RPC and sync payloads
+
+func (bs *BitStream) WriteUint16LE(v uint16) {
+	bs.data = binary.LittleEndian.AppendUint16(bs.data, v)
+}
+
+func (bs *BitStream) WriteUint32LE(v uint32) {
+	bs.data = binary.LittleEndian.AppendUint32(bs.data, v)
+}
+
+func (bs *BitStream) WriteInt32LE(v int32) {
+	bs.WriteUint32LE(uint32(v))
+}
+
+// WriteFloat32 appends v as a little-endian IEEE 754 single
+func (bs *BitStream) WriteFloat32(v float32) {
+	bs.WriteUint32LE(math.Float32bits(v))
+}
+
+// WriteFloat64 appends v as a little-endian IEEE 754 double
+func (bs *BitStream) WriteFloat64(v float64) {
+	bs.data = binary.LittleEndian.AppendUint64(bs.data, math.Float64bits(v))
+}
+
+func (bs *BitStream) ReadUint16LE() (uint16, error) {
+	data, err := bs.ReadBytesNoCopy(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(data), nil
+}
+
+func (bs *BitStream) ReadUint32LE() (uint32, error) {
+	data, err := bs.ReadBytesNoCopy(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(data), nil
+}
+
+func (bs *BitStream) ReadInt32LE() (int32, error) {
+	v, err := bs.ReadUint32LE()
+	return int32(v), err
+}
+
+// ReadFloat32 reads a little-endian IEEE 754 single
+func (bs *BitStream) ReadFloat32() (float32, error) {
+	v, err := bs.ReadUint32LE()
+	return math.Float32frombits(v), err
+}
+
+// ReadFloat64 reads a little-endian IEEE 754 double
+func (bs *BitStream) ReadFloat64() (float64, error) {
+	data, err := bs.ReadBytesNoCopy(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+}
+
 func (bs *BitStream) WriteString(s string) {
 	bs.WriteUint16(uint16(len(s)))
 	bs.data = append(bs.data, []byte(s)...)
@@ -232,12 +372,85 @@ func (bs *BitStream) GetData() []byte {
 func (bs *BitStream) Reset() {
 	bs.data = make([]byte, 0)
 	bs.offset = 0
+	bs.writeBit, bs.writeMark = 0, 0
+	bs.readBit, bs.readMark = 0, 0
 }
 
 func (bs *BitStream) Remaining() int {
 	return len(bs.data) - bs.offset
 }
 
+// WriteBits appends the low numBits bits of value, most significant first
+// (RakNet bit order). Consecutive calls pack into the same byte.
+func (bs *BitStream) WriteBits(value uint64, numBits int) error {
+	if numBits < 1 || numBits > 64 {
+		return fmt.Errorf("invalid bit count %d", numBits)
+	}
+	
+	for i := numBits - 1; i >= 0; i-- {
+		if bs.writeBit == 0 || len(bs.data) != bs.writeMark {
+			bs.data = append(bs.data, 0)
+			bs.writeBit = 0
+		}
+		if (value>>uint(i))&1 != 0 {
+			bs.data[len(bs.data)-1] |= 0x80 >> uint(bs.writeBit)
+		}
+		bs.writeBit = (bs.writeBit + 1) % 8
+		bs.writeMark = len(bs.data)
+	}
+	return nil
+}
+
+// WriteBool appends a single bit
+func (bs *BitStream) WriteBool(v bool) {
+	var bit uint64
+	if v {
+		bit = 1
+	}
+	bs.WriteBits(bit, 1)
+}
+
+// ReadBool reads a single bit
+func (bs *BitStream) ReadBool() (bool, error) {
+	bit, err := bs.ReadBits(1)
+	return bit == 1, err
+}
+
+// ReadBits reads numBits bits, most significant first, into the low bits of
+// the result. Consecutive calls continue within the same byte.
+func (bs *BitStream) ReadBits(numBits int) (uint64, error) {
+	if numBits < 1 || numBits > 64 {
+		return 0, fmt.Errorf("invalid bit count %d", numBits)
+	}
+	
+	partial := bs.readBit != 0 && bs.offset == bs.readMark
+	available := (len(bs.data) - bs.offset) * 8
+	if partial {
+		available += 8 - bs.readBit
+	}
+	if numBits > available {
+		return 0, fmt.Errorf("buffer overflow")
+	}
+	
+	var value uint64
+	for i := 0; i < numBits; i++ {
+		if !partial {
+			bs.offset++
+			bs.readBit = 0
+			partial = true
+		}
+		bit := (bs.data[bs.offset-1] >> uint(7-bs.readBit)) & 1
+		value = value<<1 | uint64(bit)
+		bs.readBit++
+		if bs.readBit == 8 {
+			bs.readBit = 0
+			partial = false
+		}
+	}
+	bs.readMark = bs.offset
+	return value, nil
+}
+
 type RakNetPacket struct {
 	PacketID     byte
 	Reliability  byte
@@ -271,6 +484,16 @@ type EncapsulatedPacket struct {
 	SplitID      uint16
 	SplitIndex   uint32
 	Payload      []byte
+	
+	// OnAck is called once the datagram carrying this packet is ACKed (optional, not encoded)
+	OnAck        func()
+}
+
+// IsReliable reports whether the packet is resent until the peer ACKs it
+func (ep *EncapsulatedPacket) IsReliable() bool {
+	return ep.Reliability == RELIABLE || ep.Reliability == RELIABLE_ORDERED ||
+		ep.Reliability == RELIABLE_SEQUENCED || ep.Reliability == RELIABLE_WITH_ACK ||
+		ep.Reliability == RELIABLE_ORDERED_WITH_ACK
 }
 
 func (ep *EncapsulatedPacket) GetSize() int {
@@ -304,9 +527,20 @@ func NewDataPacket() *DataPacket {
 	}
 }
 
+// Encode returns the datagram's wire bytes in a buffer of exactly that size
 func (dp *DataPacket) Encode() []byte {
-	bs := NewEmptyBitStream()
-	bs.WriteByte(0x80) // Data packet flag
+	size := datagramHeaderSize
+	for _, packet := range dp.Packets {
+		size += packet.GetSize()
+	}
+	bs := NewBitStream(make([]byte, 0, size))
+	dp.EncodeTo(bs)
+	return bs.GetData()
+}
+
+// EncodeTo writes the datagram to bs, e.g. a stream from NewPooledBitStream
+func (dp *DataPacket) EncodeTo(bs *BitStream) {
+	bs.WriteByte(ID_DATAGRAM) // Data packet flag
 	bs.WriteUint24(dp.SequenceNumber)
 	
 	for _, packet := range dp.Packets {
@@ -342,8 +576,6 @@ func (dp *DataPacket) Encode() []byte {
 		
 		bs.WriteBytes(packet.Payload)
 	}
-	
-	return bs.GetData()
 }
 
 func DecodeDataPacket(data []byte) (*DataPacket, error) {
@@ -352,7 +584,7 @@ func DecodeDataPacket(data []byte) (*DataPacket, error) {
 	}
 
 	flags := data[0]
-	if (flags & 0x80) == 0 {
+	if (flags & ID_DATAGRAM) == 0 {
 		return nil, fmt.Errorf("not a data packet")
 	}
 
@@ -464,6 +696,18 @@ func DecodeDataPacket(data []byte) (*DataPacket, error) {
 	return dp, nil
 }
 
+// ACK/NACK record flags
+const (
+	ACK_RECORD_RANGE  = 0x00 // start and end sequence follow
+	ACK_RECORD_SINGLE = 0x01 // one sequence follows
+)
+
+// AckRange is an inclusive run of sequence numbers in an ACK or NACK
+type AckRange struct {
+	Start uint32
+	End   uint32
+}
+
 type ACK struct {
 	Packets []uint32
 }
@@ -474,29 +718,11 @@ func NewACK() *ACK {
 	}
 }
 
+// Encode returns 0xC0, the record count (2 bytes LE) and one record per run of
+// consecutive sequences: 0x01 + seq, or 0x00 + start + end (3 bytes LE each).
+// Example single ACK: C0 01 00 01 XX XX XX
 func (ack *ACK) Encode() []byte {
-	// CRITICAL: RakNet ACK format (NO extra bytes!)
-	// Format: 0xC0 + count(2 bytes LE) + sequences(3 bytes LE each)
-	// Example single ACK: C0 01 00 XX XX XX (6 bytes total)
-	
-	buf := make([]byte, 0, 3+len(ack.Packets)*3)
-	
-	// Byte 0: ACK ID
-	buf = append(buf, 0xC0)
-	
-	// Bytes 1-2: Record count (little-endian)
-	count := uint16(len(ack.Packets))
-	buf = append(buf, byte(count))
-	buf = append(buf, byte(count>>8))
-	
-	// Bytes 3+: Sequences (3 bytes little-endian each, NO flag byte!)
-	for _, seq := range ack.Packets {
-		buf = append(buf, byte(seq))
-		buf = append(buf, byte(seq>>8))
-		buf = append(buf, byte(seq>>16))
-	}
-	
-	return buf
+	return encodeAckRecords(ID_ACK, ack.Packets)
 }
 
 type NACK struct {
@@ -509,31 +735,86 @@ func NewNACK() *NACK {
 	}
 }
 
+// Encode returns 0xA0 followed by records in the same layout as ACK.Encode
 func (nack *NACK) Encode() []byte {
-	// CRITICAL: RakNet NACK format (NO extra bytes!)
-	// Format: 0xA0 + count(2 bytes LE) + sequences(3 bytes LE each)
-	// Example single NACK: A0 01 00 XX XX XX (6 bytes total)
-	
-	buf := make([]byte, 0, 3+len(nack.Packets)*3)
-	
-	// Byte 0: NACK ID
-	buf = append(buf, 0xA0)
-	
-	// Bytes 1-2: Record count (little-endian)
-	count := uint16(len(nack.Packets))
-	buf = append(buf, byte(count))
-	buf = append(buf, byte(count>>8))
+	return encodeAckRecords(ID_NACK, nack.Packets)
+}
+
+// AckRanges groups sequences into sorted runs of consecutive numbers,
+// ignoring duplicates
+func AckRanges(sequences []uint32) []AckRange {
+	sorted := make([]uint32, len(sequences))
+	for i, seq := range sequences {
+		sorted[i] = seq & 0xFFFFFF
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
 	
-	// Bytes 3+: Sequences (3 bytes little-endian each, NO flag byte!)
-	for _, seq := range nack.Packets {
-		buf = append(buf, byte(seq))
-		buf = append(buf, byte(seq>>8))
-		buf = append(buf, byte(seq>>16))
+	ranges := make([]AckRange, 0)
+	for _, seq := range sorted {
+		if n := len(ranges); n > 0 {
+			last := &ranges[n-1]
+			if seq == last.End {
+				continue // duplicate
+			}
+			if seq == last.End+1 {
+				last.End = seq
+				continue
+			}
+		}
+		ranges = append(ranges, AckRange{Start: seq, End: seq})
 	}
+	return ranges
+}
+
+func encodeAckRecords(id byte, sequences []uint32) []byte {
+	ranges := AckRanges(sequences)
 	
+	buf := make([]byte, 0, 3+len(ranges)*7)
+	buf = append(buf, id)
+	buf = append(buf, byte(len(ranges)), byte(len(ranges)>>8))
+	for _, r := range ranges {
+		if r.Start == r.End {
+			buf = append(buf, ACK_RECORD_SINGLE)
+			buf = append(buf, WriteUint24LE(r.Start)...)
+			continue
+		}
+		buf = append(buf, ACK_RECORD_RANGE)
+		buf = append(buf, WriteUint24LE(r.Start)...)
+		buf = append(buf, WriteUint24LE(r.End)...)
+	}
 	return buf
 }
 
+// DecodeAckRanges parses an ACK or NACK as written by Encode, accepting both
+// single and range records
+func DecodeAckRanges(data []byte) ([]AckRange, error) {
+	if len(data) < 3 {
+		return nil, fmt.Errorf("ack too short: %d bytes", len(data))
+	}
+	count := int(binary.LittleEndian.Uint16(data[1:3]))
+	
+	bs := NewBitStream(data[3:])
+	ranges := make([]AckRange, 0, count)
+	for i := 0; i < count; i++ {
+		flag, err := bs.ReadByte()
+		if err != nil {
+			return ranges, fmt.Errorf("ack record %d of %d: %w", i, count, err)
+		}
+		start, err := bs.ReadUint24()
+		if err != nil {
+			return ranges, fmt.Errorf("ack record %d of %d: %w", i, count, err)
+		}
+		end := start
+		if flag == ACK_RECORD_RANGE {
+			if end, err = bs.ReadUint24(); err != nil {
+				return ranges, fmt.Errorf("ack record %d of %d: %w", i, count, err)
+			}
+		}
+		ranges = append(ranges, AckRange{Start: start, End: end})
+	}
+	return ranges, nil
+}
+
 // Helper functions for uint24
 func (bs *BitStream) WriteUint24(v uint32) {
 	// RakNet uses 24-bit LITTLE-endian for sequences
@@ -580,11 +861,11 @@ func WriteUint24LE(v uint32) []byte {
 // Returns the maximum payload size that won't cause IP fragmentation
 func GetSafePayloadSize(mtu uint16, isOrdered bool) int {
 	// Datagram header: 4 bytes (0x84 + 3 byte seq)
-	// Encapsulation header for RELIABLE_ORDERED: 11 bytes
+	// Ordered: worst-case encapsulation header (sequenced/ordered + channel)
 	// Encapsulation header for RELIABLE: 7 bytes
-	headerSize := 4
+	headerSize := datagramHeaderSize
 	if isOrdered {
-		headerSize += 11
+		headerSize += maxEncapHeaderSize
 	} else {
 		headerSize += 7
 	}
@@ -602,10 +883,29 @@ func GetSafePayloadSize(mtu uint16, isOrdered bool) int {
 	return maxPayload
 }
 
+// GetSafeSplitPayloadSize - Like GetSafePayloadSize, but leaves room for the
+// 10-byte split header (count, ID, index) every fragment carries
+func GetSafeSplitPayloadSize(mtu uint16, isOrdered bool) int {
+	maxPayload := GetSafePayloadSize(mtu, isOrdered) - splitHeaderSize
+	if maxPayload < 0 {
+		return 0
+	}
+	
+	return maxPayload
+}
+
 type Session struct {
 	Addr                 *net.UDPAddr
+	Conn                 *net.UDPConn      // Local socket the client reached; all writes use it
 	MTU                  uint16
 	GUID                 uint64            // Client GUID for session migration
+	Clock                Clock             // Time source for LastReceiveTime/LastSendTime
+	Counters             *ReliabilityCounters // Per-reliability packet counts (nil = not counted)
+	Cipher               PacketCipher      // Applied to encapsulated payloads on the wire (nil = none)
+	DatagramsSent        *atomic.Uint64    // Datagrams Update writes, may be shared (nil = not counted)
+	HalfOpen             *atomic.Int64     // Sessions below STATE_CONNECTED, may be shared; kept by SetState (nil = not counted)
+	halfOpen             atomic.Bool       // this session is counted in HalfOpen
+	released             atomic.Bool       // ReleaseHalfOpen was called, never count again
 	
 	// Protected by Mu - accessed from multiple goroutines
 	State                int
@@ -613,28 +913,46 @@ type Session struct {
 	SequenceNumber       uint32
 	OrderIndex           uint32  // DEPRECATED - use ChannelOrderIndex instead
 	ChannelOrderIndex    map[uint8]uint32  // Per-channel ordering index (CRITICAL for RakNet)
+	recvOrderIndex       map[uint8]uint32  // Next OrderIndex expected from the peer per channel
+	recvMessageBase      uint32            // Lowest reliable MessageIndex not yet received from the peer
+	recvMessages         map[uint32]struct{} // Reliable MessageIndexes received past recvMessageBase (see ordering.go)
+	orderBuffer          map[uint8]map[uint32][]byte // Ordered messages held back by a gap (see ordering.go)
+	orderBuffered        int               // Messages in orderBuffer
+	orderBufferBytes     int               // Payload bytes in orderBuffer
 	SplitID              uint16
-	SplitInProgress      bool              // Lock MTU during split packet transmission
+	SplitInProgress      bool              // Lock MTU during a direct split send (see MTULocked)
+	queuedFragments      int               // Split fragments from AddToQueue not yet sent
 	SendQueue            []*EncapsulatedPacket
 	RecoveryQueue        map[uint32]*DataPacket
+	nackResent           map[uint32]time.Time // When each sequence was last resent for a NACK
+	retransmit           map[uint32]*retransmitTimer // Resend deadline of each unACKed datagram
+	resendsExhausted     bool              // a datagram went unACKed after MaxResends resends
+	RetransmitTimeout    time.Duration     // First resend deadline for unACKed datagrams (0 = RTO())
 	ACKQueue             map[uint32]struct{}  // Dedup set for ACK sequences
 	NACKQueue            []uint32
 	SplitPackets         map[uint16]map[uint32]*EncapsulatedPacket
+	splitBytes           int               // Payload bytes buffered in SplitPackets
+	splitStarted         map[uint16]time.Time // When the first fragment of each split arrived
 	LastReceiveTime      time.Time
 	LastSendTime         time.Time
 	LastTenSent          time.Time         // Last time 0x10 was sent (for cooldown)
 	Cookie               []byte // SA-MP cookie for session identification
 	ReceivedJoinRequest  bool
-	HandshakeSent        bool              // Full handshake sequence sent flag
-	StreamingDone        bool              // All streaming packets sent flag
-	GameEntrySent        bool              // Game entry sequence sent flag
-	PostStreamingSent    bool              // Post-streaming sequence sent flag
-	JoinResponseSent     bool              // Join response sequence sent flag
+	ConnectPhase         ConnectPhase      // Connect flow progress (see connect_phase.go)
+	SpawnStep            int               // Spawn RPC waiting for its ACK while in PhaseSpawning
 	PendingAuth          bool              // Auth packet received, waiting for 0x0B ACK
 	AuthSequence         []byte            // Sequence from 0x88
 	AuthPayload          []byte            // Payload from 0x88
 	PlayerID             uint16            // SA-MP player ID
 	Nickname             string            // SA-MP player nickname
+	ClientVersion        string            // Client build from the join request, "" if not sent
+	RTT                  time.Duration     // Last round trip sample (ConnectedPing/Pong or ACK)
+	SRTT                 time.Duration     // Smoothed RTT (Jacobson/Karels), 0 until the first sample
+	RTTVar               time.Duration     // RTT variance estimate
+	LastPingSent         time.Time         // Last time the server sent ID_CONNECTED_PING
+	CreatedAt            time.Time         // Start of the connection timeline
+	InternalAddrs        []*net.UDPAddr    // Client's local addresses from ID_NEW_INCOMING_CONNECTION
+	milestones           [milestoneCount]time.Time // see MarkMilestone
 	
 	// FIX #5: Sent guards to prevent duplicate packets
 	SentE3Phase0         bool              // E3:00 challenge sent
@@ -664,7 +982,13 @@ const (
 )
 
 func NewSession(addr *net.UDPAddr, mtu uint16) *Session {
+	return NewSessionWithClock(addr, mtu, RealClock)
+}
+
+// NewSessionWithClock creates a session that reads time from clock
+func NewSessionWithClock(addr *net.UDPAddr, mtu uint16, clock Clock) *Session {
 	s := &Session{
+		Clock:             clock,
 		Addr:              addr,
 		MTU:               mtu,
 		State:             STATE_UNCONNECTED,
@@ -675,12 +999,16 @@ func NewSession(addr *net.UDPAddr, mtu uint16) *Session {
 		SplitID:           0,
 		SendQueue:         make([]*EncapsulatedPacket, 0),
 		RecoveryQueue:     make(map[uint32]*DataPacket),
+		nackResent:        make(map[uint32]time.Time),
+		retransmit:        make(map[uint32]*retransmitTimer),
 		ACKQueue:          make(map[uint32]struct{}), // Dedup set
 		NACKQueue:         make([]uint32, 0),
 		SplitPackets:      make(map[uint16]map[uint32]*EncapsulatedPacket),
+		splitStarted:      make(map[uint16]time.Time),
 		PendingACK:        make(map[uint32][]byte),
-		LastReceiveTime:   time.Now(),
-		LastSendTime:      time.Now(),
+		LastReceiveTime:   clock.Now(),
+		LastSendTime:      clock.Now(),
+		CreatedAt:         clock.Now(),
 	}
 	
 	// Log safe payload sizes for this MTU
@@ -709,77 +1037,117 @@ func (s *Session) GetPendingACK(seq uint32) ([]byte, bool) {
 	return data, exists
 }
 
-func (s *Session) DeletePendingACK(seq uint32) {
+// DeletePendingACKs forgets the PendingACK entries in every range of one ACK
+func (s *Session) DeletePendingACKs(ranges []AckRange) {
 	s.pendingMu.Lock()
 	defer s.pendingMu.Unlock()
-	delete(s.PendingACK, seq)
-}
-
-// Thread-safe methods for state flags
-func (s *Session) SetHandshakeSent(value bool) {
-	s.Mu.Lock()
-	defer s.Mu.Unlock()
-	s.HandshakeSent = value
-}
-
-func (s *Session) GetHandshakeSent() bool {
-	s.Mu.RLock()
-	defer s.Mu.RUnlock()
-	return s.HandshakeSent
-}
-
-func (s *Session) SetStreamingDone(value bool) {
-	s.Mu.Lock()
-	defer s.Mu.Unlock()
-	s.StreamingDone = value
-}
-
-func (s *Session) GetStreamingDone() bool {
-	s.Mu.RLock()
-	defer s.Mu.RUnlock()
-	return s.StreamingDone
+	for _, seq := range s.pendingSeqs(ranges) {
+		delete(s.PendingACK, seq)
+	}
 }
 
-func (s *Session) SetGameEntrySent(value bool) {
+// PendingResends returns the PendingACK datagrams in the NACKed ranges that
+// are due for a resend, in range order. A sequence resent within one RTO is
+// skipped.
+func (s *Session) PendingResends(ranges []AckRange) [][]byte {
+	s.pendingMu.RLock()
+	seqs := s.pendingSeqs(ranges)
+	datagrams := make(map[uint32][]byte, len(seqs))
+	for _, seq := range seqs {
+		datagrams[seq] = s.PendingACK[seq]
+	}
+	s.pendingMu.RUnlock()
+	
 	s.Mu.Lock()
 	defer s.Mu.Unlock()
-	s.GameEntrySent = value
+	resends := make([][]byte, 0, len(seqs))
+	for _, seq := range seqs {
+		if s.retransmitDue(seq) {
+			resends = append(resends, datagrams[seq])
+		}
+	}
+	return resends
+}
+
+// pendingSeqs returns the sequences in ranges that are in PendingACK, in range
+// order. A range longer than the map is matched against its keys, and the
+// lookups stop at MaxAckLookups. Caller must hold s.pendingMu.
+func (s *Session) pendingSeqs(ranges []AckRange) []uint32 {
+	seqs := make([]uint32, 0)
+	lookups := 0
+	for _, r := range ranges {
+		if int(SeqRangeLen(r.Start, r.End)) > len(s.PendingACK) {
+			if lookups += len(s.PendingACK); lookups > MaxAckLookups {
+				break
+			}
+			found := make([]uint32, 0)
+			for seq := range s.PendingACK {
+				if SeqInRange(seq, r.Start, r.End) {
+					found = append(found, seq)
+				}
+			}
+			start := r.Start
+			sort.Slice(found, func(i, j int) bool {
+				return (found[i]-start)&seqMask < (found[j]-start)&seqMask
+			})
+			seqs = append(seqs, found...)
+			continue
+		}
+		
+		rangeSeqs := SeqRange(r.Start, r.End)
+		if lookups += len(rangeSeqs); lookups > MaxAckLookups {
+			break
+		}
+		for _, seq := range rangeSeqs {
+			if _, exists := s.PendingACK[seq]; exists {
+				seqs = append(seqs, seq)
+			}
+		}
+	}
+	return seqs
 }
 
-func (s *Session) GetGameEntrySent() bool {
-	s.Mu.RLock()
-	defer s.Mu.RUnlock()
-	return s.GameEntrySent
+func (s *Session) DeletePendingACK(seq uint32) {
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+	delete(s.PendingACK, seq)
 }
 
-func (s *Session) SetPostStreamingSent(value bool) {
+// SetRTT records a measured round trip time
+func (s *Session) SetRTT(rtt time.Duration) {
 	s.Mu.Lock()
 	defer s.Mu.Unlock()
-	s.PostStreamingSent = value
+	s.addRTTSample(rtt)
 }
 
-func (s *Session) GetPostStreamingSent() bool {
+// GetRTT returns the smoothed round trip time (0 until one is measured)
+func (s *Session) GetRTT() time.Duration {
 	s.Mu.RLock()
 	defer s.Mu.RUnlock()
-	return s.PostStreamingSent
+	return s.SRTT
 }
 
-func (s *Session) SetJoinResponseSent(value bool) {
-	s.Mu.Lock()
-	defer s.Mu.Unlock()
-	s.JoinResponseSent = value
-}
-
-func (s *Session) GetJoinResponseSent() bool {
-	s.Mu.RLock()
-	defer s.Mu.RUnlock()
-	return s.JoinResponseSent
+// addRTTSample updates SRTT and RTTVar with the Jacobson/Karels estimator
+// (RFC 6298). Caller must hold s.Mu.
+func (s *Session) addRTTSample(rtt time.Duration) {
+	s.RTT = rtt
+	if s.SRTT == 0 {
+		s.SRTT = rtt
+		s.RTTVar = rtt / 2
+		return
+	}
+	diff := s.SRTT - rtt
+	if diff < 0 {
+		diff = -diff
+	}
+	s.RTTVar = (3*s.RTTVar + diff) / 4
+	s.SRTT = (7*s.SRTT + rtt) / 8
 }
 
 func (s *Session) UpdateLastReceiveTime() {
 	s.Mu.Lock()
 	defer s.Mu.Unlock()
-	s.LastReceiveTime = time.Now()
+	s.LastReceiveTime = s.Clock.Now()
 }
 
 func (s *Session) GetLastReceiveTime() time.Time {
@@ -788,29 +1156,155 @@ func (s *Session) GetLastReceiveTime() time.Time {
 	return s.LastReceiveTime
 }
 
-func (s *Session) AddToQueue(packet *EncapsulatedPacket) {
+// Worst-case encapsulation overhead (flags, length, message/order index, channel) and split header
+const (
+	datagramHeaderSize = 4
+	maxEncapHeaderSize = 13
+	splitHeaderSize    = 10
+)
+
+// AddToQueue queues a packet for the next Update. Payloads that do not fit in a
+// single datagram are split automatically; it returns an error if even the
+// split would exceed MAX_SPLIT_PACKET_COUNT, or for an oversized packet that is
+// already a split fragment.
+func (s *Session) AddToQueue(packet *EncapsulatedPacket) error {
 	s.Mu.Lock()
 	defer s.Mu.Unlock()
 	
+	maxPayload := GetSafePayloadSize(s.MTU, true)
+	if len(packet.Payload) <= maxPayload {
+		s.enqueue(packet)
+		return nil
+	}
+	
+	if packet.Split {
+		return fmt.Errorf("split fragment of %d bytes exceeds datagram limit %d", len(packet.Payload), maxPayload)
+	}
+	
+	fragmentSize := GetSafeSplitPayloadSize(s.MTU, true)
+	if fragmentSize <= 0 {
+		return fmt.Errorf("MTU %d too small for split packets", s.MTU)
+	}
+	count := (len(packet.Payload) + fragmentSize - 1) / fragmentSize
+	if count > MAX_SPLIT_PACKET_COUNT {
+		return fmt.Errorf("payload of %d bytes needs %d fragments (max %d)", len(packet.Payload), count, MAX_SPLIT_PACKET_COUNT)
+	}
+	
+	// Fragments must be reliable so the client can reassemble them
+	reliability := packet.Reliability
+	if reliability == UNRELIABLE || reliability == UNRELIABLE_SEQUENCED || reliability == UNRELIABLE_WITH_ACK {
+		reliability = RELIABLE
+	}
+	
+	splitID := s.SplitID
+	s.SplitID++
+	
+	// Hold the MTU until Update has flushed every fragment; a renegotiated
+	// MTU mid-split would leave fragments sized for the old one
+	s.queuedFragments += count
+	
+	// Ordered fragments share one order index
+	orderIndex := uint32(0)
+	if reliability == RELIABLE_ORDERED || reliability == RELIABLE_ORDERED_WITH_ACK {
+		if s.ChannelOrderIndex == nil {
+			s.ChannelOrderIndex = make(map[uint8]uint32)
+		}
+		orderIndex = s.ChannelOrderIndex[packet.OrderChannel]
+		s.ChannelOrderIndex[packet.OrderChannel] = SeqNext(s.ChannelOrderIndex[packet.OrderChannel])
+	}
+	
+	for i := 0; i < count; i++ {
+		end := (i + 1) * fragmentSize
+		if end > len(packet.Payload) {
+			end = len(packet.Payload)
+		}
+		
+		fragment := &EncapsulatedPacket{
+			Reliability:  reliability,
+			MessageIndex: s.MessageIndex,
+			OrderIndex:   orderIndex,
+			OrderChannel: packet.OrderChannel,
+			Split:        true,
+			SplitCount:   uint32(count),
+			SplitID:      splitID,
+			SplitIndex:   uint32(i),
+			Payload:      packet.Payload[i*fragmentSize : end],
+		}
+		s.MessageIndex = SeqNext(s.MessageIndex)
+		
+		// The whole packet is delivered once the last fragment is ACKed
+		if i == count-1 {
+			fragment.OnAck = packet.OnAck
+		}
+		s.SendQueue = append(s.SendQueue, fragment)
+	}
+	
+	return nil
+}
+
+// enqueue assigns message/order indices and appends to SendQueue. Caller holds s.Mu.
+func (s *Session) enqueue(packet *EncapsulatedPacket) {
 	if packet.Reliability == RELIABLE || packet.Reliability == RELIABLE_ORDERED || 
 	   packet.Reliability == RELIABLE_SEQUENCED || packet.Reliability == RELIABLE_WITH_ACK || 
 	   packet.Reliability == RELIABLE_ORDERED_WITH_ACK {
 		packet.MessageIndex = s.MessageIndex
-		s.MessageIndex++
+		s.MessageIndex = SeqNext(s.MessageIndex)
 	}
 	
+	// Share the per-channel counter with the direct datagram path so both stay in order
 	if packet.Reliability == RELIABLE_ORDERED || packet.Reliability == RELIABLE_ORDERED_WITH_ACK {
-		packet.OrderIndex = s.OrderIndex
-		s.OrderIndex++
+		if s.ChannelOrderIndex == nil {
+			s.ChannelOrderIndex = make(map[uint8]uint32)
+		}
+		packet.OrderIndex = s.ChannelOrderIndex[packet.OrderChannel]
+		s.ChannelOrderIndex[packet.OrderChannel] = SeqNext(s.ChannelOrderIndex[packet.OrderChannel])
 	}
 	
+	if packet.Split {
+		s.queuedFragments++
+	}
 	s.SendQueue = append(s.SendQueue, packet)
 }
 
+// MTULocked reports whether the MTU must not change: a split packet is being
+// sent directly (SplitInProgress) or AddToQueue fragments are still queued.
+// Caller holds s.Mu.
+func (s *Session) MTULocked() bool {
+	return s.SplitInProgress || s.queuedFragments > 0
+}
+
+// takeDatagramPackets removes as many queued packets as fit in one datagram
+// (always at least one). Caller holds s.Mu.
+func (s *Session) takeDatagramPackets() []*EncapsulatedPacket {
+	maxDatagram := int(s.MTU) - MTU_SAFETY_MARGIN
+	size := datagramHeaderSize
+	
+	packets := make([]*EncapsulatedPacket, 0)
+	for len(s.SendQueue) > 0 && len(packets) < 120 {
+		packet := s.SendQueue[0]
+		if len(packets) > 0 && size+packet.GetSize() > maxDatagram {
+			break
+		}
+		size += packet.GetSize()
+		s.SendQueue = s.SendQueue[1:]
+		packets = append(packets, packet)
+		if packet.Split {
+			s.queuedFragments--
+		}
+	}
+	return packets
+}
+
+// Update flushes queued ACKs, NACKs and packets. They are written on the
+// session's own Conn when set, otherwise on conn.
 func (s *Session) Update(conn *net.UDPConn) error {
 	s.Mu.Lock()
 	defer s.Mu.Unlock()
 	
+	if s.Conn != nil {
+		conn = s.Conn
+	}
+	
 	// FIXED: ACKQueue is now a map (dedup set), convert to slice for sending
 	if len(s.ACKQueue) > 0 {
 		// Convert map to slice
@@ -824,30 +1318,12 @@ func (s *Session) Update(conn *net.UDPConn) error {
 			ack.Packets = ackSeqs
 			ackData := ack.Encode()
 			
-			// CRITICAL: Verify ACK format
-			expectedLen := 3 + len(ackSeqs)*3
-			if len(ackData) != expectedLen {
-				log.Printf("❌ ERROR: ACK length mismatch! Expected %d, got %d", expectedLen, len(ackData))
-			}
-			
-			n, err := conn.WriteToUDP(ackData, s.Addr)
+			n, err := s.write(conn, ackData)
 			if err != nil {
 				log.Printf("❌ Failed to send ACK: %v", err)
 			} else {
 				log.Printf("✅ Sent ACK to %s: %d bytes, %d sequences (deduped)", s.Addr.String(), n, len(ackSeqs))
-				log.Printf("   ACK sequences: %v", ackSeqs)
 				log.Printf("   ACK hex: %02X", ackData)
-				
-				// Verify format for single ACK
-				if len(ackSeqs) == 1 {
-					if len(ackData) == 6 {
-						log.Printf("   ✅ ACK format CORRECT: 6 bytes for single record")
-						log.Printf("   Format: [0]=0xC0 [1-2]=count(LE)=0x%02X%02X [3-5]=seq(LE)=0x%02X%02X%02X", 
-							ackData[1], ackData[2], ackData[3], ackData[4], ackData[5])
-					} else {
-						log.Printf("   ❌ ACK format WRONG: %d bytes (expected 6 for single record)", len(ackData))
-					}
-				}
 			}
 		}
 		
@@ -859,24 +1335,24 @@ func (s *Session) Update(conn *net.UDPConn) error {
 	if len(s.NACKQueue) > 0 {
 		nack := NewNACK()
 		nack.Packets = s.NACKQueue
-		conn.WriteToUDP(nack.Encode(), s.Addr)
+		s.write(conn, nack.Encode())
 		s.NACKQueue = make([]uint32, 0)
 	}
 	
-	// Send queued packets
-	if len(s.SendQueue) > 0 {
+	// Send queued packets, one MTU-sized datagram at a time
+	for len(s.SendQueue) > 0 {
 		dp := NewDataPacket()
 		dp.SequenceNumber = s.SequenceNumber
-		s.SequenceNumber++
-		
-		for len(s.SendQueue) > 0 && len(dp.Packets) < 120 {
-			packet := s.SendQueue[0]
-			s.SendQueue = s.SendQueue[1:]
-			dp.Packets = append(dp.Packets, packet)
+		s.SequenceNumber = SeqNext(s.SequenceNumber)
+		dp.Packets = s.takeDatagramPackets()
+		for _, packet := range dp.Packets {
+			s.Counters.AddSent(packet.Reliability)
 		}
 		
-		data := dp.Encode()
-		n, err := conn.WriteToUDP(data, s.Addr)
+		bs := NewPooledBitStream()
+		s.encodeDatagram(dp, bs)
+		data := bs.GetData()
+		n, err := s.write(conn, data)
 		if err != nil {
 			log.Printf("❌ Failed to send data packet: %v", err)
 		} else {
@@ -884,13 +1360,110 @@ func (s *Session) Update(conn *net.UDPConn) error {
 				s.Addr.String(), n, dp.SequenceNumber, len(dp.Packets))
 			log.Printf("   Data packet hex (first 64 bytes): %x", data[:min(64, len(data))])
 		}
-		s.RecoveryQueue[dp.SequenceNumber] = dp
-		s.LastSendTime = time.Now()
+		bs.Release()
+		s.LastSendTime = s.Clock.Now()
+		
+		// Only reliable packets are resent; a datagram without any is forgotten
+		if recovery := reliablePackets(dp); recovery != nil {
+			s.RecoveryQueue[dp.SequenceNumber] = recovery
+			s.startRetransmitTimer(dp.SequenceNumber, s.LastSendTime)
+		}
 	}
 	
+	s.resendExpired(conn, s.Clock.Now())
 	return nil
 }
 
+// reliablePackets returns dp with only its reliable packets and packets with
+// an OnAck callback, or nil if there are none
+func reliablePackets(dp *DataPacket) *DataPacket {
+	recovery := &DataPacket{SequenceNumber: dp.SequenceNumber}
+	for _, packet := range dp.Packets {
+		if packet.IsReliable() || packet.OnAck != nil {
+			recovery.Packets = append(recovery.Packets, packet)
+		}
+	}
+	if len(recovery.Packets) == 0 {
+		return nil
+	}
+	return recovery
+}
+
+// write sends one datagram to the client, counting it in DatagramsSent
+func (s *Session) write(conn *net.UDPConn, data []byte) (int, error) {
+	n, err := conn.WriteToUDP(data, s.Addr)
+	if err == nil && s.DatagramsSent != nil {
+		s.DatagramsSent.Add(1)
+	}
+	return n, err
+}
+
+// retransmitTimer tracks when an unACKed datagram is resent and the timeout
+// that applied, doubled after every resend up to MaxRTO
+type retransmitTimer struct {
+	sentAt  time.Time
+	resent  bool // an ACK can't be matched to one send, so it gives no RTT sample
+	resends int
+	due     time.Time
+	rto     time.Duration
+}
+
+// startRetransmitTimer arms the resend deadline of a datagram just sent.
+// Caller must hold s.Mu.
+func (s *Session) startRetransmitTimer(seq uint32, now time.Time) {
+	rto := s.RetransmitTimeout
+	if rto <= 0 {
+		rto = s.rto()
+	}
+	if s.retransmit == nil {
+		s.retransmit = make(map[uint32]*retransmitTimer)
+	}
+	s.retransmit[seq] = &retransmitTimer{sentAt: now, due: now.Add(rto), rto: rto}
+}
+
+// resendExpired resends every datagram in RecoveryQueue whose deadline has
+// passed without an ACK, backing its timeout off exponentially. Clients on
+// lossy links may drop a datagram without ever NACKing it. A datagram keeps
+// its sequence for every resend, NACKed or timed out, so an ACK of any send
+// matches it. After MaxResends the session is marked as ResendsExhausted.
+// Caller must hold s.Mu.
+func (s *Session) resendExpired(conn *net.UDPConn, now time.Time) {
+	for seq, timer := range s.retransmit {
+		if now.Before(timer.due) {
+			continue
+		}
+		dp, exists := s.RecoveryQueue[seq]
+		if !exists {
+			delete(s.retransmit, seq)
+			continue
+		}
+		if timer.resends >= MaxResends {
+			if !s.resendsExhausted {
+				log.Printf("⚠️ Data packet seq=%d to %s unACKed after %d resends, giving up", seq, s.Addr, timer.resends)
+			}
+			s.resendsExhausted = true
+			continue
+		}
+		
+		bs := NewPooledBitStream()
+		s.encodeDatagram(dp, bs)
+		_, err := s.write(conn, bs.GetData())
+		bs.Release()
+		if err != nil {
+			log.Printf("❌ Failed to resend data packet seq=%d: %v", seq, err)
+		} else {
+			log.Printf("🔁 Resent unACKed data packet seq=%d to %s after %s", seq, s.Addr, timer.rto)
+		}
+		timer.resent = true
+		timer.resends++
+		timer.rto *= 2
+		if timer.rto > MaxRTO {
+			timer.rto = MaxRTO
+		}
+		timer.due = now.Add(timer.rto)
+	}
+}
+
 func (s *Session) HandleDataPacket(dp *DataPacket) []*RakNetPacket {
 	s.Mu.Lock()
 	defer s.Mu.Unlock()
@@ -899,122 +1472,356 @@ func (s *Session) HandleDataPacket(dp *DataPacket) []*RakNetPacket {
 	if len(dp.Packets) > 0 {
 		s.ACKQueue[dp.SequenceNumber] = struct{}{} // Dedup set
 	}
-	s.LastReceiveTime = time.Now()
+	s.LastReceiveTime = s.Clock.Now()
+	s.pruneSplits(s.LastReceiveTime)
 	
 	packets := make([]*RakNetPacket, 0)
+	refused := false // A message was dropped that the peer must resend
 	
 	for _, encap := range dp.Packets {
-		// CRITICAL: Process reliable ordered state machine
-		if encap.Reliability == RELIABLE_ORDERED || encap.Reliability == RELIABLE_ORDERED_WITH_ACK {
-			// Check if this is a duplicate or out-of-order message
-			channel := encap.OrderChannel
-			
-			// Initialize expected ordering index for this channel if needed
-			if s.ChannelOrderIndex == nil {
-				s.ChannelOrderIndex = make(map[uint8]uint32)
-			}
-			
-			expectedOrderIndex := s.ChannelOrderIndex[channel]
-			
-			// DUPLICATE DETECTION: If order index < expected, this is a duplicate
-			if encap.OrderIndex < expectedOrderIndex {
-				log.Printf("🔄 DUPLICATE: Received order=%d, expected=%d (channel=%d) - IGNORING", 
-					encap.OrderIndex, expectedOrderIndex, channel)
-				continue // Skip duplicate
-			}
-			
-			// OUT-OF-ORDER DETECTION: If order index > expected, buffer it
-			if encap.OrderIndex > expectedOrderIndex {
-				log.Printf("⏸️ OUT-OF-ORDER: Received order=%d, expected=%d (channel=%d) - BUFFERING", 
-					encap.OrderIndex, expectedOrderIndex, channel)
-				// TODO: Implement out-of-order buffering if needed
-				// For now, we'll process it anyway (SA-MP might not need strict ordering)
+		s.Counters.AddReceived(encap.Reliability)
+		
+		if encap.IsReliable() {
+			if s.reliableDuplicate(encap.MessageIndex) {
+				continue // A resend of a message we already have
 			}
-			
-			// IN-ORDER: Process this message and update expected index
-			if encap.OrderIndex == expectedOrderIndex {
-				log.Printf("✅ IN-ORDER: Received order=%d (channel=%d) - PROCESSING", 
-					encap.OrderIndex, channel)
-				s.ChannelOrderIndex[channel] = expectedOrderIndex + 1
+			if !s.trackReliable(encap.MessageIndex) {
+				refused = true
+				continue
 			}
 		}
 		
-		// Process split packets
+		ordered := encap.Reliability == RELIABLE_ORDERED || encap.Reliability == RELIABLE_ORDERED_WITH_ACK
+		if ordered && s.orderedDuplicate(encap.OrderChannel, encap.OrderIndex) {
+			continue // Already delivered; don't buffer it again
+		}
+		
+		payload := encap.Payload
 		if encap.Split {
-			if _, exists := s.SplitPackets[encap.SplitID]; !exists {
-				s.SplitPackets[encap.SplitID] = make(map[uint32]*EncapsulatedPacket)
+			if !s.bufferSplit(encap) {
+				continue
 			}
-			s.SplitPackets[encap.SplitID][encap.SplitIndex] = encap
-			
-			if uint32(len(s.SplitPackets[encap.SplitID])) == encap.SplitCount {
-				var buffer bytes.Buffer
-				for i := uint32(0); i < encap.SplitCount; i++ {
-					buffer.Write(s.SplitPackets[encap.SplitID][i].Payload)
-				}
-				delete(s.SplitPackets, encap.SplitID)
-				
-				if len(buffer.Bytes()) > 0 {
-					packet := &RakNetPacket{
-						PacketID: buffer.Bytes()[0],
-						Payload:  buffer.Bytes()[1:],
-					}
-					packets = append(packets, packet)
-				}
+			complete := false
+			if payload, complete = s.reassembleSplit(encap.SplitID, encap.SplitCount); !complete {
+				continue
 			}
-		} else {
-			if len(encap.Payload) > 0 {
-				packet := &RakNetPacket{
-					PacketID: encap.Payload[0],
-					Payload:  encap.Payload[1:],
+		}
+		
+		// Ordered messages are delivered strictly in OrderIndex order per channel
+		if ordered {
+			delivered, held := s.deliverOrdered(encap.OrderChannel, encap.OrderIndex, payload)
+			if !held {
+				// The earlier fragments stay buffered; only this one is resent
+				s.forgetReliable(encap.MessageIndex)
+				if encap.Split {
+					s.dropFragment(encap)
 				}
-				packets = append(packets, packet)
+				refused = true
+				continue
 			}
+			packets = append(packets, delivered...)
+		} else {
+			packets = appendPacket(packets, payload)
+		}
+		if encap.Split {
+			s.dropSplit(encap.SplitID)
 		}
 	}
+	s.advanceReliable()
+	
+	// Leave the datagram unACKed so the peer resends its reliable messages;
+	// the ones we did take are then dropped as duplicates
+	if refused {
+		delete(s.ACKQueue, dp.SequenceNumber)
+	}
 	
 	return packets
 }
 
-func (s *Session) HandleACK(data []byte) {
-	s.Mu.Lock()
-	defer s.Mu.Unlock()
+// bufferSplit stores a fragment for reassembly. Fragments with a SplitCount
+// above MAX_SPLIT_PACKET_COUNT or disagreeing with earlier fragments of the same
+// split, an index outside the split, or that would push
+// the session past MAX_SPLIT_BUFFER_BYTES are dropped before anything is
+// allocated for them. Caller must hold s.Mu.
+func (s *Session) bufferSplit(encap *EncapsulatedPacket) bool {
+	if encap.SplitCount == 0 || encap.SplitCount > MAX_SPLIT_PACKET_COUNT || encap.SplitIndex >= encap.SplitCount {
+		log.Printf("⚠️ Dropping split fragment from %s: id=%d index=%d count=%d (max %d)",
+			s.Addr, encap.SplitID, encap.SplitIndex, encap.SplitCount, MAX_SPLIT_PACKET_COUNT)
+		return false
+	}
+	
+	fragments := s.SplitPackets[encap.SplitID]
+	for _, other := range fragments {
+		if other.SplitCount != encap.SplitCount {
+			log.Printf("⚠️ Dropping split fragment from %s: id=%d claims count=%d, earlier fragments said %d",
+				s.Addr, encap.SplitID, encap.SplitCount, other.SplitCount)
+			return false
+		}
+		break
+	}
 	
-	bs := NewBitStream(data)
-	bs.ReadByte() // Skip flag
+	size := len(encap.Payload)
+	if old, exists := fragments[encap.SplitIndex]; exists {
+		size -= len(old.Payload)
+	}
+	if s.splitBytes+size > MAX_SPLIT_BUFFER_BYTES {
+		log.Printf("⚠️ Dropping split fragment from %s: %d bytes already buffered (max %d)",
+			s.Addr, s.splitBytes, MAX_SPLIT_BUFFER_BYTES)
+		return false
+	}
 	
-	count, _ := bs.ReadUint16()
-	for i := uint16(0); i < count; i++ {
-		bs.ReadByte() // Skip single/range flag
-		start, _ := bs.ReadUint24()
-		end, _ := bs.ReadUint24()
-		
-		for seq := start; seq <= end; seq++ {
-			delete(s.RecoveryQueue, seq)
+	if fragments == nil {
+		fragments = make(map[uint32]*EncapsulatedPacket)
+		s.SplitPackets[encap.SplitID] = fragments
+		if s.splitStarted == nil {
+			s.splitStarted = make(map[uint16]time.Time)
+		}
+		s.splitStarted[encap.SplitID] = s.Clock.Now()
+	}
+	fragments[encap.SplitIndex] = encap
+	s.splitBytes += size
+	return true
+}
+
+// dropSplit forgets a split and releases its share of the buffer budget.
+// Caller must hold s.Mu.
+func (s *Session) dropSplit(id uint16) {
+	for _, fragment := range s.SplitPackets[id] {
+		s.splitBytes -= len(fragment.Payload)
+	}
+	delete(s.SplitPackets, id)
+	delete(s.splitStarted, id)
+}
+
+// dropFragment forgets one buffered fragment of a split, so that its resend
+// completes the split again. Caller must hold s.Mu.
+func (s *Session) dropFragment(encap *EncapsulatedPacket) {
+	fragments := s.SplitPackets[encap.SplitID]
+	if fragment, exists := fragments[encap.SplitIndex]; exists {
+		s.splitBytes -= len(fragment.Payload)
+		delete(fragments, encap.SplitIndex)
+	}
+}
+
+// reassembleSplit joins a split's fragments in index order. It reports false
+// until every index 0..count-1 has arrived. Caller must hold s.Mu.
+func (s *Session) reassembleSplit(id uint16, count uint32) ([]byte, bool) {
+	fragments := s.SplitPackets[id]
+	if uint32(len(fragments)) != count {
+		return nil, false
+	}
+	
+	var buffer bytes.Buffer
+	for i := uint32(0); i < count; i++ {
+		fragment, ok := fragments[i]
+		if !ok || fragment == nil {
+			return nil, false
 		}
+		buffer.Write(fragment.Payload)
+	}
+	return buffer.Bytes(), true
+}
+
+// pruneSplits discards splits that have waited SPLIT_REASSEMBLY_TIMEOUT for a
+// missing fragment. Caller must hold s.Mu.
+func (s *Session) pruneSplits(now time.Time) {
+	for id, started := range s.splitStarted {
+		if now.Sub(started) < SPLIT_REASSEMBLY_TIMEOUT {
+			continue
+		}
+		log.Printf("⚠️ Discarding incomplete split %d from %s: %d/%d fragments after %s",
+			id, s.Addr, len(s.SplitPackets[id]), s.splitCount(id), SPLIT_REASSEMBLY_TIMEOUT)
+		s.dropSplit(id)
+	}
+}
+
+// splitCount returns the fragment count a buffered split announced
+func (s *Session) splitCount(id uint16) uint32 {
+	for _, fragment := range s.SplitPackets[id] {
+		return fragment.SplitCount
 	}
+	return 0
 }
 
-func (s *Session) HandleNACK(data []byte) {
+// HandleACK forgets the ACKed handshake datagrams and acknowledges the ACKed
+// datagrams in the recovery queue
+func (s *Session) HandleACK(data []byte) {
+	ranges, err := DecodeAckRanges(data)
+	if err != nil {
+		log.Printf("⚠️ Malformed ACK from %s: %v", s.Addr, err)
+	}
+	s.DeletePendingACKs(ranges)
+	s.AcknowledgeRanges(ranges)
+}
+
+// AcknowledgeRange removes ACKed datagrams from the recovery queue and runs the
+// OnAck callbacks of the packets they carried. Each callback runs at most once.
+// Datagrams that were sent only once give an RTT sample.
+func (s *Session) AcknowledgeRange(start, end uint32) {
+	s.AcknowledgeRanges([]AckRange{{Start: start, End: end}})
+}
+
+// AcknowledgeRanges is AcknowledgeRange for every range of one ACK
+func (s *Session) AcknowledgeRanges(ranges []AckRange) {
 	s.Mu.Lock()
-	defer s.Mu.Unlock()
+	now := s.Clock.Now()
+	callbacks := make([]func(), 0)
+	for _, seq := range s.recoverySeqs(ranges) {
+		dp, exists := s.RecoveryQueue[seq]
+		if !exists {
+			continue // overlapping ranges
+		}
+		if timer, armed := s.retransmit[seq]; armed && !timer.resent {
+			s.addRTTSample(now.Sub(timer.sentAt))
+		}
+		for _, packet := range dp.Packets {
+			if packet.OnAck != nil {
+				callbacks = append(callbacks, packet.OnAck)
+				packet.OnAck = nil // NACK resends share the packet, don't fire again
+			}
+		}
+		delete(s.RecoveryQueue, seq)
+		delete(s.nackResent, seq)
+		delete(s.retransmit, seq)
+	}
+	s.Mu.Unlock()
+	
+	// Run callbacks without holding the lock so they can use the session
+	for _, callback := range callbacks {
+		callback()
+	}
+}
+
+// ResendsExhausted reports whether a datagram went unACKed after MaxResends
+// resends. The peer is unreachable and the session should be closed.
+func (s *Session) ResendsExhausted() bool {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return s.resendsExhausted
+}
+
+// HandleNACK makes the NACKed datagrams in the recovery queue due, so the next
+// Update resends them under the same sequence, and returns the NACKed
+// handshake datagrams due for a resend, which the caller writes out as they are.
+// A datagram NACKed again within one RTO is not resent twice.
+func (s *Session) HandleNACK(data []byte) [][]byte {
+	ranges, err := DecodeAckRanges(data)
+	if err != nil {
+		log.Printf("⚠️ Malformed NACK from %s: %v", s.Addr, err)
+	}
 	
-	bs := NewBitStream(data)
-	bs.ReadByte() // Skip flag
+	s.Mu.Lock()
+	now := s.Clock.Now()
+	for _, seq := range s.recoverySeqs(ranges) {
+		if !s.retransmitDue(seq) {
+			continue
+		}
+		timer, armed := s.retransmit[seq]
+		if !armed {
+			s.startRetransmitTimer(seq, now)
+			timer = s.retransmit[seq]
+		}
+		timer.due = now
+	}
+	s.Mu.Unlock()
 	
-	count, _ := bs.ReadUint16()
-	for i := uint16(0); i < count; i++ {
-		bs.ReadByte() // Skip single/range flag
-		start, _ := bs.ReadUint24()
-		end, _ := bs.ReadUint24()
+	return s.PendingResends(ranges)
+}
+
+// recoverySeqs returns the sequences in ranges that are still in
+// RecoveryQueue, in range order. A range longer than the queue is matched
+// against the queue's keys instead of being expanded, and the lookups for one
+// ACK/NACK stop at MaxAckLookups. Caller must hold s.Mu.
+func (s *Session) recoverySeqs(ranges []AckRange) []uint32 {
+	seqs := make([]uint32, 0)
+	lookups := 0
+	for _, r := range ranges {
+		length := int(SeqRangeLen(r.Start, r.End))
+		scan := length > len(s.RecoveryQueue)
+		if scan {
+			length = len(s.RecoveryQueue)
+		}
+		if lookups+length > MaxAckLookups {
+			log.Printf("⚠️ ACK/NACK from %s covers too many sequences, ignoring the rest of its %d ranges", s.Addr, len(ranges))
+			break
+		}
+		lookups += length
 		
-		for seq := start; seq <= end; seq++ {
-			if dp, exists := s.RecoveryQueue[seq]; exists {
-				for _, packet := range dp.Packets {
-					s.SendQueue = append(s.SendQueue, packet)
+		if !scan {
+			for _, seq := range SeqRange(r.Start, r.End) {
+				if _, exists := s.RecoveryQueue[seq]; exists {
+					seqs = append(seqs, seq)
 				}
 			}
+			continue
+		}
+		
+		found := make([]uint32, 0)
+		for seq := range s.RecoveryQueue {
+			if SeqInRange(seq, r.Start, r.End) {
+				found = append(found, seq)
+			}
 		}
+		start := r.Start
+		sort.Slice(found, func(i, j int) bool {
+			return (found[i]-start)&seqMask < (found[j]-start)&seqMask
+		})
+		seqs = append(seqs, found...)
+	}
+	return seqs
+}
+
+// Retransmission timeout for NACK and timer resends. Repeated NACKs for a
+// sequence within one RTO are ignored: the resend is already on its way.
+const (
+	MinRTO     = 100 * time.Millisecond
+	DefaultRTO = 500 * time.Millisecond // until an RTT has been measured
+	MaxRTO     = 8 * time.Second        // backoff cap for unACKed datagrams
+	MaxResends = 10                     // resends of one datagram before the peer is given up on
+)
+
+// RTO returns the current retransmission timeout: SRTT + 4*RTTVar, within
+// MinRTO..MaxRTO, or DefaultRTO before any RTT sample
+func (s *Session) RTO() time.Duration {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return s.rto()
+}
+
+func (s *Session) rto() time.Duration {
+	if s.SRTT == 0 {
+		return DefaultRTO
+	}
+	rto := s.SRTT + 4*s.RTTVar
+	if rto < MinRTO {
+		return MinRTO
+	}
+	if rto > MaxRTO {
+		return MaxRTO
+	}
+	return rto
+}
+
+// AllowRetransmit reports whether a NACKed sequence may be resent now, and if
+// so records the resend. It returns false for a sequence resent less than an
+// RTO ago.
+func (s *Session) AllowRetransmit(seq uint32) bool {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	return s.retransmitDue(seq)
+}
+
+// retransmitDue is AllowRetransmit for callers holding s.Mu
+func (s *Session) retransmitDue(seq uint32) bool {
+	now := s.Clock.Now()
+	if last, resent := s.nackResent[seq]; resent && now.Sub(last) < s.rto() {
+		return false
+	}
+	if s.nackResent == nil {
+		s.nackResent = make(map[uint32]time.Time)
 	}
+	s.nackResent[seq] = now
+	return true
 }
 
 func min(a, b int) int {
@@ -1036,21 +1843,19 @@ func (s *Session) StopStreaming() {
 	s.Mu.Lock()
 	defer s.Mu.Unlock()
 	
-	s.HandshakeSent = false
-	s.StreamingDone = false
-	s.GameEntrySent = false
-	s.PostStreamingSent = false
-	s.JoinResponseSent = false
+	s.ConnectPhase = PhaseNone
+	s.SpawnStep = 0
 	
 	// Clear send queue to stop pending transmissions
 	s.SendQueue = nil
+	s.queuedFragments = 0
 }
 
 // NextSeq increments and returns the next E3 packet sequence (3 bytes, little-endian)
 func (s *Session) NextSeq() []byte {
 	s.Mu.Lock()
 	defer s.Mu.Unlock()
-	s.SendSeq++
+	s.SendSeq = SeqNext(s.SendSeq)
 	return []byte{
 		byte(s.SendSeq),
 		byte(s.SendSeq >> 8),
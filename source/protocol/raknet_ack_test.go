@@ -1,7 +1,10 @@
 package protocol
 
 import (
+	"bytes"
+	"net"
 	"testing"
+	"time"
 )
 
 func TestACKEncodeSingleRecord(t *testing.T) {
@@ -10,68 +13,49 @@ func TestACKEncodeSingleRecord(t *testing.T) {
 	
 	data := ack.Encode()
 	
-	// Verify length: 3 bytes header + 3 bytes sequence = 6 bytes total
-	if len(data) != 6 {
-		t.Errorf("ACK length = %d, want 6", len(data))
-	}
-	
-	// Verify format
-	if data[0] != 0xC0 {
-		t.Errorf("ACK ID = 0x%02X, want 0xC0", data[0])
+	// 0xC0, count 1 (LE), single flag, sequence (24-bit LE)
+	expected := []byte{0xC0, 0x01, 0x00, ACK_RECORD_SINGLE, 0x56, 0x34, 0x12}
+	if !bytes.Equal(data, expected) {
+		t.Errorf("ACK = %02X, want %02X", data, expected)
 	}
+}
+
+func TestACKEncodeGroupsConsecutiveSequences(t *testing.T) {
+	ack := NewACK()
+	ack.Packets = []uint32{7, 3, 1, 2, 3, 5}
 	
-	// Verify count (little-endian)
-	count := uint16(data[1]) | uint16(data[2])<<8
-	if count != 1 {
-		t.Errorf("ACK count = %d, want 1", count)
-	}
+	data := ack.Encode()
 	
-	// Verify sequence (little-endian 24-bit)
-	seq := uint32(data[3]) | uint32(data[4])<<8 | uint32(data[5])<<16
-	if seq != 0x123456 {
-		t.Errorf("ACK sequence = 0x%06X, want 0x123456", seq)
+	// 1-3 as a range, then 5 and 7 as singles
+	expected := []byte{
+		0xC0, 0x03, 0x00,
+		ACK_RECORD_RANGE, 0x01, 0x00, 0x00, 0x03, 0x00, 0x00,
+		ACK_RECORD_SINGLE, 0x05, 0x00, 0x00,
+		ACK_RECORD_SINGLE, 0x07, 0x00, 0x00,
 	}
-	
-	// Verify exact hex format
-	expected := []byte{0xC0, 0x01, 0x00, 0x56, 0x34, 0x12}
-	for i := 0; i < len(expected); i++ {
-		if data[i] != expected[i] {
-			t.Errorf("ACK[%d] = 0x%02X, want 0x%02X", i, data[i], expected[i])
-		}
+	if !bytes.Equal(data, expected) {
+		t.Errorf("ACK = %02X, want %02X", data, expected)
 	}
 }
 
-func TestACKEncodeMultipleRecords(t *testing.T) {
+func TestACKEncodeCollapsesConsecutiveRun(t *testing.T) {
 	ack := NewACK()
-	ack.Packets = []uint32{0x000001, 0x000002, 0x000003}
+	for seq := uint32(1000); seq < 1100; seq++ {
+		ack.Packets = append(ack.Packets, seq)
+	}
 	
 	data := ack.Encode()
 	
-	// Verify length: 3 bytes header + 3*3 bytes sequences = 12 bytes total
-	expectedLen := 3 + 3*3
-	if len(data) != expectedLen {
-		t.Errorf("ACK length = %d, want %d", len(data), expectedLen)
+	// One range record instead of 100 sequences
+	if len(data) != 3+7 {
+		t.Fatalf("ACK length = %d, want 10", len(data))
 	}
-	
-	// Verify ACK ID
-	if data[0] != 0xC0 {
-		t.Errorf("ACK ID = 0x%02X, want 0xC0", data[0])
+	ranges, err := DecodeAckRanges(data)
+	if err != nil {
+		t.Fatalf("DecodeAckRanges failed: %v", err)
 	}
-	
-	// Verify count
-	count := uint16(data[1]) | uint16(data[2])<<8
-	if count != 3 {
-		t.Errorf("ACK count = %d, want 3", count)
-	}
-	
-	// Verify sequences
-	for i := 0; i < 3; i++ {
-		offset := 3 + i*3
-		seq := uint32(data[offset]) | uint32(data[offset+1])<<8 | uint32(data[offset+2])<<16
-		expected := uint32(i + 1)
-		if seq != expected {
-			t.Errorf("ACK sequence[%d] = %d, want %d", i, seq, expected)
-		}
+	if len(ranges) != 1 || ranges[0] != (AckRange{Start: 1000, End: 1099}) {
+		t.Errorf("Expected one range 1000-1099, got %+v", ranges)
 	}
 }
 
@@ -81,34 +65,35 @@ func TestNACKEncodeSingleRecord(t *testing.T) {
 	
 	data := nack.Encode()
 	
-	// Verify length: 3 bytes header + 3 bytes sequence = 6 bytes total
-	if len(data) != 6 {
-		t.Errorf("NACK length = %d, want 6", len(data))
+	// 0xABCDEF in little-endian = EF CD AB
+	expected := []byte{0xA0, 0x01, 0x00, ACK_RECORD_SINGLE, 0xEF, 0xCD, 0xAB}
+	if !bytes.Equal(data, expected) {
+		t.Errorf("NACK = %02X, want %02X", data, expected)
 	}
+}
+
+func TestDecodeAckRangesRoundTrip(t *testing.T) {
+	nack := NewNACK()
+	nack.Packets = []uint32{10, 11, 12, 20, 30, 31}
 	
-	// Verify format
-	if data[0] != 0xA0 {
-		t.Errorf("NACK ID = 0x%02X, want 0xA0", data[0])
+	ranges, err := DecodeAckRanges(nack.Encode())
+	if err != nil {
+		t.Fatalf("DecodeAckRanges failed: %v", err)
 	}
-	
-	// Verify count (little-endian)
-	count := uint16(data[1]) | uint16(data[2])<<8
-	if count != 1 {
-		t.Errorf("NACK count = %d, want 1", count)
+	expected := []AckRange{{10, 12}, {20, 20}, {30, 31}}
+	if len(ranges) != len(expected) {
+		t.Fatalf("Expected %d ranges, got %+v", len(expected), ranges)
 	}
-	
-	// Verify sequence (little-endian 24-bit)
-	seq := uint32(data[3]) | uint32(data[4])<<8 | uint32(data[5])<<16
-	if seq != 0xABCDEF {
-		t.Errorf("NACK sequence = 0x%06X, want 0xABCDEF", seq)
+	for i := range expected {
+		if ranges[i] != expected[i] {
+			t.Errorf("Range %d = %+v, want %+v", i, ranges[i], expected[i])
+		}
 	}
 	
-	// Verify exact hex format (0xABCDEF in little-endian = EF CD AB)
-	expected := []byte{0xA0, 0x01, 0x00, 0xEF, 0xCD, 0xAB}
-	for i := 0; i < len(expected); i++ {
-		if data[i] != expected[i] {
-			t.Errorf("NACK[%d] = 0x%02X, want 0x%02X", i, data[i], expected[i])
-		}
+	// A truncated record is an error; complete records before it are kept
+	data := nack.Encode()
+	if ranges, err := DecodeAckRanges(data[:len(data)-2]); err == nil || len(ranges) != 2 {
+		t.Errorf("Expected 2 ranges and an error for a truncated NACK, got %+v, %v", ranges, err)
 	}
 }
 
@@ -134,3 +119,444 @@ func TestACKEncodeEmpty(t *testing.T) {
 		t.Errorf("Empty ACK count = %d, want 0", count)
 	}
 }
+
+func TestACKInvokesDeliveryCallbackOnce(t *testing.T) {
+	session := NewSession(nil, 576)
+	
+	calls := 0
+	encap := &EncapsulatedPacket{
+		Reliability: RELIABLE_ORDERED,
+		Payload:     []byte{0x7C, RPC_InitGame},
+		OnAck:       func() { calls++ },
+	}
+	
+	dp := NewDataPacket()
+	dp.SequenceNumber = 5
+	dp.Packets = append(dp.Packets, encap)
+	session.RecoveryQueue[5] = dp
+	
+	ack := NewACK()
+	ack.Packets = []uint32{5}
+	
+	session.HandleACK(ack.Encode())
+	session.HandleACK(ack.Encode())
+	
+	if calls != 1 {
+		t.Errorf("Expected callback to fire once, got %d", calls)
+	}
+	if _, exists := session.RecoveryQueue[5]; exists {
+		t.Error("ACKed datagram should be removed from the recovery queue")
+	}
+}
+
+func TestACKCallbackNotFiredForOtherSequence(t *testing.T) {
+	session := NewSession(nil, 576)
+	
+	called := false
+	dp := NewDataPacket()
+	dp.Packets = append(dp.Packets, &EncapsulatedPacket{
+		Reliability: RELIABLE,
+		OnAck:       func() { called = true },
+	})
+	session.RecoveryQueue[7] = dp
+	
+	session.AcknowledgeRange(1, 6)
+	
+	if called {
+		t.Error("Callback fired for an unacknowledged sequence")
+	}
+}
+
+func TestACKRangeLongerThanQueue(t *testing.T) {
+	session := NewSession(nil, 576)
+	session.RecoveryQueue[5] = NewDataPacket()
+	session.RecoveryQueue[0xFFFFF0] = NewDataPacket()
+	
+	// Far longer than MaxSeqRange, so it is matched against the queue
+	session.AcknowledgeRange(0, 0x7FFFFF)
+	
+	if _, exists := session.RecoveryQueue[5]; exists {
+		t.Error("Expected seq 5 to be ACKed by the wide range")
+	}
+	if _, exists := session.RecoveryQueue[0xFFFFF0]; !exists {
+		t.Error("Expected seq 0xFFFFF0 outside the range to stay queued")
+	}
+}
+
+func TestACKLookupsAreCapped(t *testing.T) {
+	session := NewSession(nil, 576)
+	session.RecoveryQueue[5] = NewDataPacket()
+	session.RecoveryQueue[6] = NewDataPacket()
+	
+	// Each wide range costs a scan of the queue; the budget runs out before
+	// the last range is looked at
+	ranges := make([]AckRange, 0, MaxAckLookups/2+1)
+	for i := 0; i < MaxAckLookups/2; i++ {
+		ranges = append(ranges, AckRange{Start: 100, End: 0x7FFFFF})
+	}
+	ranges = append(ranges, AckRange{Start: 5, End: 5})
+	session.AcknowledgeRanges(ranges)
+	
+	if _, exists := session.RecoveryQueue[5]; !exists {
+		t.Error("Expected ranges past the lookup budget to be ignored")
+	}
+}
+
+// nackFor builds a NACK for a single sequence
+func nackFor(seq uint32) []byte {
+	nack := NewNACK()
+	nack.Packets = []uint32{seq}
+	return nack.Encode()
+}
+
+func TestRepeatedNACKResendsOnce(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open socket: %v", err)
+	}
+	defer conn.Close()
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open client socket: %v", err)
+	}
+	defer client.Close()
+	
+	clock := NewFakeClock(time.Now())
+	s := NewSessionWithClock(client.LocalAddr().(*net.UDPAddr), 576, clock)
+	s.RetransmitTimeout = time.Minute
+	s.AddToQueue(&EncapsulatedPacket{Reliability: RELIABLE, Payload: []byte{0x42}})
+	s.Update(conn)
+	sent := readDatagram(t, client)
+	
+	for i := 0; i < 3; i++ {
+		s.HandleNACK(nackFor(0))
+		clock.Advance(10 * time.Millisecond)
+	}
+	s.Update(conn)
+	if got := readDatagram(t, client); !bytes.Equal(got, sent) {
+		t.Fatalf("Expected the NACKed datagram resent under its sequence, got %X", got)
+	}
+	s.Update(conn)
+	if got := readDatagram(t, client); got != nil {
+		t.Fatalf("Expected 3 rapid NACKs to resend once, got a second resend %X", got)
+	}
+	if len(s.SendQueue) != 0 {
+		t.Errorf("Expected nothing re-queued under a new sequence, got %d", len(s.SendQueue))
+	}
+	
+	// Lost again: a NACK after the RTO resends it again, and an ACK of the
+	// same sequence still matches it
+	clock.Advance(DefaultRTO)
+	s.HandleNACK(nackFor(0))
+	s.Update(conn)
+	if got := readDatagram(t, client); !bytes.Equal(got, sent) {
+		t.Fatalf("Expected a later NACK to resend the datagram again, got %X", got)
+	}
+	ack := NewACK()
+	ack.Packets = []uint32{0}
+	s.HandleACK(ack.Encode())
+	if len(s.RecoveryQueue) != 0 {
+		t.Errorf("Expected the ACK to clear the resent datagram, got %+v", s.RecoveryQueue)
+	}
+}
+
+func TestSessionGivesUpAfterMaxResends(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open socket: %v", err)
+	}
+	defer conn.Close()
+	
+	clock := NewFakeClock(time.Now())
+	s := NewSessionWithClock(conn.LocalAddr().(*net.UDPAddr), 576, clock)
+	s.AddToQueue(&EncapsulatedPacket{Reliability: RELIABLE, Payload: []byte{0x42}})
+	s.Update(conn)
+	
+	for i := 0; i < MaxResends; i++ {
+		clock.Advance(MaxRTO)
+		s.Update(conn)
+		if s.ResendsExhausted() {
+			t.Fatalf("Expected the session kept through %d resends, gave up after %d", MaxResends, i+1)
+		}
+	}
+	if resends := s.retransmit[0].resends; resends != MaxResends {
+		t.Fatalf("Expected %d resends, got %d", MaxResends, resends)
+	}
+	
+	clock.Advance(MaxRTO)
+	s.Update(conn)
+	if !s.ResendsExhausted() {
+		t.Error("Expected the session given up on after MaxResends")
+	}
+}
+
+func TestUnreliableDatagramNotKeptForResend(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open socket: %v", err)
+	}
+	defer conn.Close()
+	
+	s := NewSession(conn.LocalAddr().(*net.UDPAddr), 576)
+	s.AddToQueue(&EncapsulatedPacket{Reliability: UNRELIABLE, Payload: []byte{0x01}})
+	s.Update(conn)
+	if len(s.RecoveryQueue) != 0 || len(s.retransmit) != 0 {
+		t.Fatalf("Expected an unreliable-only datagram not to be kept, got %d queued", len(s.RecoveryQueue))
+	}
+	
+	// A mixed datagram keeps only its reliable packet
+	s.AddToQueue(&EncapsulatedPacket{Reliability: UNRELIABLE, Payload: []byte{0x02}})
+	s.AddToQueue(&EncapsulatedPacket{Reliability: RELIABLE, Payload: []byte{0x03}})
+	s.Update(conn)
+	dp, exists := s.RecoveryQueue[1]
+	if !exists || len(dp.Packets) != 1 || dp.Packets[0].Payload[0] != 0x03 {
+		t.Errorf("Expected only the reliable packet kept for resend, got %+v", dp)
+	}
+}
+
+func TestReliableResendDeliveredOnce(t *testing.T) {
+	session := NewSession(nil, 576)
+	reliable := func(seq, messageIndex uint32) *DataPacket {
+		return &DataPacket{
+			SequenceNumber: seq,
+			Packets:        []*EncapsulatedPacket{{Reliability: RELIABLE, MessageIndex: messageIndex, Payload: []byte{0x42}}},
+		}
+	}
+	
+	if packets := session.HandleDataPacket(reliable(0, 0)); len(packets) != 1 {
+		t.Fatalf("Expected the message delivered, got %d", len(packets))
+	}
+	
+	// Our ACK was lost and the peer resends it in a new datagram
+	if packets := session.HandleDataPacket(reliable(1, 0)); len(packets) != 0 {
+		t.Errorf("Expected the resend dropped, got %d packets", len(packets))
+	}
+	if _, acked := session.ACKQueue[1]; !acked {
+		t.Error("Expected the resend's datagram to be ACKed so the peer stops")
+	}
+	
+	// Out of order past a gap, then the gap: each delivered once
+	if packets := session.HandleDataPacket(reliable(2, 2)); len(packets) != 1 {
+		t.Errorf("Expected index 2 delivered, got %d", len(packets))
+	}
+	if packets := session.HandleDataPacket(reliable(3, 1)); len(packets) != 1 {
+		t.Errorf("Expected index 1 delivered, got %d", len(packets))
+	}
+	if packets := session.HandleDataPacket(reliable(4, 2)); len(packets) != 0 {
+		t.Errorf("Expected the resend of index 2 dropped, got %d packets", len(packets))
+	}
+	if session.recvMessageBase != 3 || len(session.recvMessages) != 0 {
+		t.Errorf("Expected every index below 3 received, got base %d with %d held", session.recvMessageBase, len(session.recvMessages))
+	}
+}
+
+func TestRTOFollowsRTT(t *testing.T) {
+	s := NewSession(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50000}, 576)
+	if rto := s.RTO(); rto != DefaultRTO {
+		t.Errorf("Expected default RTO %v before any RTT, got %v", DefaultRTO, rto)
+	}
+	
+	// First sample: SRTT = RTT, RTTVar = RTT/2, RTO = SRTT + 4*RTTVar
+	s.SetRTT(150 * time.Millisecond)
+	if rto := s.RTO(); rto != 450*time.Millisecond {
+		t.Errorf("Expected RTO of 3x the first RTT, got %v", rto)
+	}
+	for i := 0; i < 50; i++ {
+		s.SetRTT(10 * time.Millisecond)
+	}
+	if rto := s.RTO(); rto != MinRTO {
+		t.Errorf("Expected RTO floor %v, got %v", MinRTO, rto)
+	}
+}
+
+// readDatagram returns the next datagram on conn, or nil if none arrives soon
+func readDatagram(t *testing.T, conn *net.UDPConn) []byte {
+	t.Helper()
+	buf := make([]byte, MAX_MTU_SIZE)
+	conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	n, _, err := conn.ReadFromUDP(buf)
+	if err != nil {
+		return nil
+	}
+	return buf[:n]
+}
+
+func TestUnACKedDatagramResentAfterRTO(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open server socket: %v", err)
+	}
+	defer server.Close()
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open client socket: %v", err)
+	}
+	defer client.Close()
+	
+	clock := NewFakeClock(time.Now())
+	s := NewSessionWithClock(client.LocalAddr().(*net.UDPAddr), 576, clock)
+	s.RetransmitTimeout = 20 * time.Millisecond
+	s.AddToQueue(&EncapsulatedPacket{Reliability: RELIABLE, Payload: []byte{0x42}})
+	
+	s.Update(server)
+	sent := readDatagram(t, client)
+	if sent == nil {
+		t.Fatal("Expected the datagram to be sent")
+	}
+	
+	// Not yet due
+	clock.Advance(19 * time.Millisecond)
+	s.Update(server)
+	if got := readDatagram(t, client); got != nil {
+		t.Fatalf("Expected no resend before the RTO, got %X", got)
+	}
+	
+	// Lost without a NACK: resent unchanged once the RTO passes
+	clock.Advance(time.Millisecond)
+	s.Update(server)
+	if got := readDatagram(t, client); !bytes.Equal(got, sent) {
+		t.Fatalf("Expected the datagram to be resent after the RTO, got %X", got)
+	}
+	
+	// The timeout doubled: nothing after another 20ms, a resend at 40ms
+	clock.Advance(20 * time.Millisecond)
+	s.Update(server)
+	if got := readDatagram(t, client); got != nil {
+		t.Fatalf("Expected the RTO to back off, got a resend after 20ms")
+	}
+	clock.Advance(20 * time.Millisecond)
+	s.Update(server)
+	if got := readDatagram(t, client); !bytes.Equal(got, sent) {
+		t.Fatalf("Expected a resend after the backed-off RTO, got %X", got)
+	}
+	
+	// Once ACKed it is never resent
+	ack := NewACK()
+	ack.Packets = []uint32{0}
+	s.HandleACK(ack.Encode())
+	clock.Advance(MaxRTO)
+	s.Update(server)
+	if got := readDatagram(t, client); got != nil {
+		t.Errorf("Expected no resend after the ACK, got %X", got)
+	}
+}
+
+func TestRetransmitBackoffIsCapped(t *testing.T) {
+	s := NewSession(nil, 576)
+	s.RetransmitTimeout = 5 * time.Second
+	now := time.Now()
+	s.startRetransmitTimer(3, now)
+	s.RecoveryQueue[3] = NewDataPacket()
+	
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open socket: %v", err)
+	}
+	defer conn.Close()
+	s.Addr = conn.LocalAddr().(*net.UDPAddr)
+	
+	s.resendExpired(conn, now.Add(5*time.Second))
+	if rto := s.retransmit[3].rto; rto != MaxRTO {
+		t.Errorf("Expected backoff capped at %v, got %v", MaxRTO, rto)
+	}
+}
+
+func TestACKFeedsSmoothedRTT(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open socket: %v", err)
+	}
+	defer conn.Close()
+	
+	clock := NewFakeClock(time.Now())
+	s := NewSessionWithClock(conn.LocalAddr().(*net.UDPAddr), 576, clock)
+	s.RetransmitTimeout = time.Second
+	ackAfter := func(seq uint32, elapsed time.Duration) {
+		s.AddToQueue(&EncapsulatedPacket{Reliability: RELIABLE, Payload: []byte{0x42}})
+		s.Update(conn)
+		clock.Advance(elapsed)
+		ack := NewACK()
+		ack.Packets = []uint32{seq}
+		s.HandleACK(ack.Encode())
+	}
+	
+	ackAfter(0, 120*time.Millisecond)
+	if rtt := s.GetRTT(); rtt != 120*time.Millisecond {
+		t.Fatalf("Expected the first sample to set the RTT, got %v", rtt)
+	}
+	
+	ackAfter(1, 200*time.Millisecond)
+	s.Mu.RLock()
+	srtt, rttVar := s.SRTT, s.RTTVar
+	s.Mu.RUnlock()
+	if srtt != 130*time.Millisecond || rttVar != 65*time.Millisecond {
+		t.Errorf("Expected SRTT 130ms and RTTVar 65ms, got %v and %v", srtt, rttVar)
+	}
+	
+	// A resent datagram's ACK is ambiguous and gives no sample
+	s.AddToQueue(&EncapsulatedPacket{Reliability: RELIABLE, Payload: []byte{0x42}})
+	s.Update(conn)
+	clock.Advance(time.Second)
+	s.Update(conn)
+	clock.Advance(5 * time.Second)
+	ack := NewACK()
+	ack.Packets = []uint32{2}
+	s.HandleACK(ack.Encode())
+	if rtt := s.GetRTT(); rtt != 130*time.Millisecond {
+		t.Errorf("Expected a resent datagram not to change the RTT, got %v", rtt)
+	}
+}
+
+func TestSplitFlushReleasesMTULock(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open server socket: %v", err)
+	}
+	defer server.Close()
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open client socket: %v", err)
+	}
+	defer client.Close()
+	
+	s := NewSession(client.LocalAddr().(*net.UDPAddr), 576)
+	s.AddToQueue(&EncapsulatedPacket{Reliability: RELIABLE_ORDERED, Payload: make([]byte, 4096)})
+	s.Update(server)
+	
+	for i := 0; i < 9; i++ {
+		if datagram := readDatagram(t, client); len(datagram) == 0 || len(datagram) > 576-MTU_SAFETY_MARGIN {
+			t.Fatalf("Expected fragment datagram %d within the MTU, got %d bytes", i, len(datagram))
+		}
+	}
+	if s.MTULocked() {
+		t.Errorf("Expected MTU lock released once every fragment was sent")
+	}
+}
+
+func TestUpdateKeepsDirectSplitLock(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open server socket: %v", err)
+	}
+	defer server.Close()
+	
+	// A direct split send holds SplitInProgress across several ticks
+	s := NewSession(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50000}, 576)
+	s.SplitInProgress = true
+	s.AddToQueue(&EncapsulatedPacket{Reliability: RELIABLE_ORDERED, Payload: []byte{0x01}})
+	s.Update(server)
+	if !s.MTULocked() {
+		t.Error("Expected Update to leave a direct split's MTU lock alone")
+	}
+	s.SplitInProgress = false
+	
+	// Queued fragments hold the lock until sent
+	s.AddToQueue(&EncapsulatedPacket{Reliability: RELIABLE_ORDERED, Payload: make([]byte, 1024)})
+	if !s.MTULocked() {
+		t.Error("Expected queued fragments to lock the MTU")
+	}
+	s.Update(server)
+	if s.MTULocked() {
+		t.Fatal("Expected the lock released once every fragment was sent")
+	}
+}
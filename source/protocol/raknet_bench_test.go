@@ -52,6 +52,7 @@ func BenchmarkDataPacketEncode(b *testing.B) {
 		dp.Packets = append(dp.Packets, encap)
 	}
 	
+	b.ReportAllocs()
 	b.ResetTimer()
 	
 	for i := 0; i < b.N; i++ {
@@ -59,6 +60,30 @@ func BenchmarkDataPacketEncode(b *testing.B) {
 	}
 }
 
+// BenchmarkDataPacketEncodePooled is the Session.Update send path
+func BenchmarkDataPacketEncodePooled(b *testing.B) {
+	dp := NewDataPacket()
+	dp.SequenceNumber = 100
+	
+	for i := 0; i < 10; i++ {
+		dp.Packets = append(dp.Packets, &EncapsulatedPacket{
+			Reliability:  RELIABLE_ORDERED,
+			MessageIndex: uint32(i),
+			OrderIndex:   uint32(i),
+			Payload:      make([]byte, 100),
+		})
+	}
+	
+	b.ReportAllocs()
+	b.ResetTimer()
+	
+	for i := 0; i < b.N; i++ {
+		bs := NewPooledBitStream()
+		dp.EncodeTo(bs)
+		bs.Release()
+	}
+}
+
 func BenchmarkDataPacketDecode(b *testing.B) {
 	dp := NewDataPacket()
 	dp.SequenceNumber = 100
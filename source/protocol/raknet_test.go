@@ -1,8 +1,12 @@
 package protocol
 
 import (
+	"bytes"
+	"io"
+	"math"
 	"net"
 	"testing"
+	"time"
 )
 
 func TestBitStreamWriteRead(t *testing.T) {
@@ -38,6 +42,28 @@ func TestBitStreamWriteRead(t *testing.T) {
 	}
 }
 
+func TestBitStreamIsByteWriter(t *testing.T) {
+	var w io.ByteWriter = NewEmptyBitStream()
+	if err := w.WriteByte(0x7F); err != nil {
+		t.Fatalf("WriteByte failed: %v", err)
+	}
+	if data := w.(*BitStream).GetData(); !bytes.Equal(data, []byte{0x7F}) {
+		t.Errorf("Expected [7F], got % X", data)
+	}
+}
+
+func TestReadAddressPortLittleEndian(t *testing.T) {
+	// 127.0.0.1:7777 as the client sends it: version, inverted IP, port 0x1E61 low byte first
+	bs := NewBitStream([]byte{4, 0x80, 0xFF, 0xFF, 0xFE, 0x61, 0x1E})
+	addr, err := bs.ReadAddress()
+	if err != nil {
+		t.Fatalf("Failed to read address: %v", err)
+	}
+	if addr.String() != "127.0.0.1:7777" {
+		t.Errorf("Expected 127.0.0.1:7777, got %s", addr)
+	}
+}
+
 func TestEncapsulatedPacket(t *testing.T) {
 	packet := &EncapsulatedPacket{
 		Reliability:  RELIABLE_ORDERED,
@@ -145,3 +171,512 @@ func TestAddressWriteRead(t *testing.T) {
 		t.Errorf("Expected port %d, got %d", addr.Port, readAddr.Port)
 	}
 }
+
+func TestAddToQueueSplitsOversizedPayload(t *testing.T) {
+	session := NewSession(nil, 576)
+	
+	payload := make([]byte, 10000)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	
+	acked := false
+	err := session.AddToQueue(&EncapsulatedPacket{
+		Reliability: RELIABLE_ORDERED,
+		Payload:     payload,
+		OnAck:       func() { acked = true },
+	})
+	if err != nil {
+		t.Fatalf("Expected payload to be split, got error: %v", err)
+	}
+	
+	if len(session.SendQueue) < 2 {
+		t.Fatalf("Expected multiple fragments, got %d", len(session.SendQueue))
+	}
+	
+	var joined []byte
+	for i, fragment := range session.SendQueue {
+		if !fragment.Split || fragment.SplitIndex != uint32(i) || fragment.SplitCount != uint32(len(session.SendQueue)) {
+			t.Errorf("Fragment %d has bad split header: %+v", i, fragment)
+		}
+		if fragment.OrderIndex != 0 {
+			t.Errorf("Expected fragments to share order index 0, got %d", fragment.OrderIndex)
+		}
+		if 4+fragment.GetSize() > int(session.MTU)-MTU_SAFETY_MARGIN {
+			t.Errorf("Fragment %d does not fit in a datagram", i)
+		}
+		joined = append(joined, fragment.Payload...)
+	}
+	
+	if string(joined) != string(payload) {
+		t.Errorf("Expected fragments to reassemble to the original payload")
+	}
+	
+	last := session.SendQueue[len(session.SendQueue)-1]
+	if last.OnAck == nil || session.SendQueue[0].OnAck != nil {
+		t.Errorf("Expected delivery callback only on the last fragment")
+	}
+	last.OnAck()
+	if !acked {
+		t.Errorf("Expected callback to be preserved")
+	}
+}
+
+func TestAddToQueueRejectsTooLargePayload(t *testing.T) {
+	session := NewSession(nil, 576)
+	
+	err := session.AddToQueue(&EncapsulatedPacket{
+		Reliability: RELIABLE_ORDERED,
+		Payload:     make([]byte, 64*1024),
+	})
+	if err == nil {
+		t.Errorf("Expected error for 64KB payload")
+	}
+	if len(session.SendQueue) != 0 {
+		t.Errorf("Expected nothing queued, got %d packets", len(session.SendQueue))
+	}
+}
+
+func TestHandleDataPacketRejectsOversizedSplitCount(t *testing.T) {
+	session := NewSession(nil, 576)
+	
+	dp := NewDataPacket()
+	dp.Packets = append(dp.Packets, &EncapsulatedPacket{
+		Reliability: RELIABLE,
+		Split:       true,
+		SplitCount:  1000,
+		SplitID:     1,
+		SplitIndex:  0,
+		Payload:     []byte{0x01},
+	})
+	
+	if packets := session.HandleDataPacket(dp); len(packets) != 0 {
+		t.Errorf("Expected no packets, got %d", len(packets))
+	}
+	if _, exists := session.SplitPackets[1]; exists {
+		t.Errorf("Expected no reassembly map for a rejected split")
+	}
+	if session.splitBytes != 0 {
+		t.Errorf("Expected nothing buffered, got %d bytes", session.splitBytes)
+	}
+}
+
+func TestHandleDataPacketCapsBufferedSplitBytes(t *testing.T) {
+	session := NewSession(nil, 576)
+	
+	dp := NewDataPacket()
+	for id := uint16(0); id < 3; id++ {
+		dp.Packets = append(dp.Packets, &EncapsulatedPacket{
+			Reliability:  RELIABLE,
+			MessageIndex: uint32(id),
+			Split:        true,
+			SplitCount:   2,
+			SplitID:      id,
+			Payload:     make([]byte, MAX_SPLIT_BUFFER_BYTES/2-1),
+		})
+	}
+	session.HandleDataPacket(dp)
+	
+	if len(session.SplitPackets) != 2 || session.splitBytes != MAX_SPLIT_BUFFER_BYTES-2 {
+		t.Fatalf("Expected 2 splits filling the budget, got %d splits with %d bytes", len(session.SplitPackets), session.splitBytes)
+	}
+	
+	// Completing a split frees its share of the budget
+	dp = NewDataPacket()
+	dp.Packets = append(dp.Packets, &EncapsulatedPacket{
+		Reliability:  RELIABLE,
+		MessageIndex: 3,
+		Split:        true,
+		SplitCount:   2,
+		SplitID:      0,
+		SplitIndex:   1,
+		Payload:     []byte{0x02},
+	})
+	if packets := session.HandleDataPacket(dp); len(packets) != 1 {
+		t.Fatalf("Expected reassembled packet, got %d", len(packets))
+	}
+	if session.splitBytes != MAX_SPLIT_BUFFER_BYTES/2-1 {
+		t.Errorf("Expected %d bytes buffered, got %d", MAX_SPLIT_BUFFER_BYTES/2-1, session.splitBytes)
+	}
+}
+
+func TestHandleDataPacketDropsMismatchedSplitCount(t *testing.T) {
+	session := NewSession(nil, 576)
+	
+	dp := NewDataPacket()
+	dp.Packets = append(dp.Packets,
+		&EncapsulatedPacket{Reliability: RELIABLE, MessageIndex: 0, Split: true, SplitCount: 3, SplitID: 7, SplitIndex: 0, Payload: []byte{0x01}},
+		&EncapsulatedPacket{Reliability: RELIABLE, MessageIndex: 1, Split: true, SplitCount: 2, SplitID: 7, SplitIndex: 1, Payload: []byte{0x02}},
+	)
+	
+	if packets := session.HandleDataPacket(dp); len(packets) != 0 {
+		t.Errorf("Expected no packets, got %d", len(packets))
+	}
+	if len(session.SplitPackets[7]) != 1 {
+		t.Errorf("Expected only the first fragment buffered, got %d", len(session.SplitPackets[7]))
+	}
+}
+
+// splitFragment returns fragment index of a 3-part split with a one-byte payload
+func splitFragment(id uint16, index uint32, payload byte) *EncapsulatedPacket {
+	return &EncapsulatedPacket{Reliability: RELIABLE, MessageIndex: uint32(id)<<8 + index, Split: true, SplitCount: 3, SplitID: id, SplitIndex: index, Payload: []byte{payload}}
+}
+
+func TestHandleDataPacketReassemblesOutOfOrderWithGap(t *testing.T) {
+	session := NewSession(nil, 576)
+	
+	// Fragments 2 and 0 arrive first; 1 is late
+	dp := NewDataPacket()
+	dp.Packets = append(dp.Packets, splitFragment(4, 2, 0xCC), splitFragment(4, 0, 0xAA))
+	if packets := session.HandleDataPacket(dp); len(packets) != 0 {
+		t.Fatalf("Expected no packets with fragment 1 missing, got %d", len(packets))
+	}
+	
+	dp = NewDataPacket()
+	dp.SequenceNumber = 1
+	dp.Packets = append(dp.Packets, splitFragment(4, 1, 0xBB))
+	packets := session.HandleDataPacket(dp)
+	if len(packets) != 1 {
+		t.Fatalf("Expected the split to reassemble, got %d packets", len(packets))
+	}
+	if packets[0].PacketID != 0xAA || !bytes.Equal(packets[0].Payload, []byte{0xBB, 0xCC}) {
+		t.Errorf("Expected fragments joined in index order, got 0x%02X %X", packets[0].PacketID, packets[0].Payload)
+	}
+	if len(session.SplitPackets) != 0 || session.splitBytes != 0 {
+		t.Errorf("Expected the split buffer to be released")
+	}
+}
+
+func TestHandleDataPacketDiscardsStaleSplits(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	session := NewSessionWithClock(nil, 576, clock)
+	
+	dp := NewDataPacket()
+	dp.Packets = append(dp.Packets, splitFragment(5, 0, 0xAA), splitFragment(5, 2, 0xCC))
+	session.HandleDataPacket(dp)
+	
+	// Fragment 1 never arrives
+	clock.Advance(SPLIT_REASSEMBLY_TIMEOUT)
+	dp = NewDataPacket()
+	dp.SequenceNumber = 1
+	dp.Packets = append(dp.Packets, &EncapsulatedPacket{Reliability: RELIABLE, Payload: []byte{0x01}})
+	session.HandleDataPacket(dp)
+	
+	if _, exists := session.SplitPackets[5]; exists {
+		t.Errorf("Expected the incomplete split to be discarded after %s", SPLIT_REASSEMBLY_TIMEOUT)
+	}
+	if session.splitBytes != 0 || len(session.splitStarted) != 0 {
+		t.Errorf("Expected no split state left, got %d bytes and %d timers", session.splitBytes, len(session.splitStarted))
+	}
+	
+	// A late fragment starts a fresh split rather than completing the old one
+	dp = NewDataPacket()
+	dp.SequenceNumber = 2
+	dp.Packets = append(dp.Packets, splitFragment(5, 1, 0xBB))
+	if packets := session.HandleDataPacket(dp); len(packets) != 0 {
+		t.Errorf("Expected no packet from a discarded split, got %d", len(packets))
+	}
+}
+
+func TestGetSafeSplitPayloadSizeFitsMTU(t *testing.T) {
+	plain := GetSafePayloadSize(576, true)
+	split := GetSafeSplitPayloadSize(576, true)
+	if plain-split != 10 {
+		t.Errorf("Expected split size to be 10 bytes below %d, got %d", plain, split)
+	}
+	
+	fragment := &EncapsulatedPacket{
+		Reliability: RELIABLE_ORDERED,
+		Split:       true,
+		Payload:     make([]byte, split),
+	}
+	if size := 4 + fragment.GetSize(); size > 576-MTU_SAFETY_MARGIN {
+		t.Errorf("Split fragment needs %d bytes, limit is %d", size, 576-MTU_SAFETY_MARGIN)
+	}
+	
+	if got := GetSafeSplitPayloadSize(MTU_SAFETY_MARGIN, true); got != 0 {
+		t.Errorf("Expected 0 for a tiny MTU, got %d", got)
+	}
+}
+
+func TestTakeDatagramPacketsRespectsMTU(t *testing.T) {
+	session := NewSession(nil, 576)
+	
+	for i := 0; i < 10; i++ {
+		session.AddToQueue(&EncapsulatedPacket{Reliability: RELIABLE, Payload: make([]byte, 200)})
+	}
+	
+	datagrams := 0
+	for len(session.SendQueue) > 0 {
+		size := 4
+		for _, packet := range session.takeDatagramPackets() {
+			size += packet.GetSize()
+		}
+		if size > int(session.MTU)-MTU_SAFETY_MARGIN {
+			t.Errorf("Datagram of %d bytes exceeds MTU", size)
+		}
+		datagrams++
+	}
+	
+	if datagrams < 2 {
+		t.Errorf("Expected queue to span several datagrams, got %d", datagrams)
+	}
+}
+
+func TestReadBytesReturnsCopy(t *testing.T) {
+	bs := NewBitStream([]byte{0x01, 0x02, 0x03, 0x04})
+	
+	data, err := bs.ReadBytes(2)
+	if err != nil {
+		t.Fatalf("ReadBytes failed: %v", err)
+	}
+	data[0] = 0xFF
+	
+	if got := bs.GetData()[0]; got != 0x01 {
+		t.Errorf("Expected stream byte 0x01 after mutating ReadBytes result, got 0x%02X", got)
+	}
+}
+
+func TestWriteBytesCopiesInput(t *testing.T) {
+	bs := NewEmptyBitStream()
+	buf := []byte{0x0A, 0x0B}
+	bs.WriteBytes(buf)
+	buf[0] = 0xFF
+	
+	if got := bs.GetData()[0]; got != 0x0A {
+		t.Errorf("Expected stream byte 0x0A after reusing the input buffer, got 0x%02X", got)
+	}
+}
+
+func TestBitStreamWriteReadBits(t *testing.T) {
+	bs := NewEmptyBitStream()
+	fields := []struct {
+		value   uint64
+		numBits int
+	}{
+		{0x5, 3},    // 101
+		{0x6B, 7},   // 1101011
+		{0xABC, 12}, // 101010111100
+	}
+	for _, f := range fields {
+		if err := bs.WriteBits(f.value, f.numBits); err != nil {
+			t.Fatalf("WriteBits(%#x, %d) failed: %v", f.value, f.numBits, err)
+		}
+	}
+	
+	// 22 bits packed MSB-first: 10111010 11101010 111100xx
+	want := []byte{0xBA, 0xEA, 0xF0}
+	if !bytes.Equal(bs.GetData(), want) {
+		t.Fatalf("Expected packed bytes %X, got %X", want, bs.GetData())
+	}
+	
+	rs := NewBitStream(bs.GetData())
+	for _, f := range fields {
+		got, err := rs.ReadBits(f.numBits)
+		if err != nil {
+			t.Fatalf("ReadBits(%d) failed: %v", f.numBits, err)
+		}
+		if got != f.value {
+			t.Errorf("ReadBits(%d): expected %#x, got %#x", f.numBits, f.value, got)
+		}
+	}
+	
+	// Only the 2 padding bits are left
+	if _, err := rs.ReadBits(3); err == nil {
+		t.Error("Expected an error reading past the end")
+	}
+}
+
+func TestBitStreamBitsValidateCount(t *testing.T) {
+	bs := NewEmptyBitStream()
+	for _, n := range []int{0, -1, 65} {
+		if err := bs.WriteBits(1, n); err == nil {
+			t.Errorf("Expected WriteBits to reject %d bits", n)
+		}
+		if _, err := bs.ReadBits(n); err == nil {
+			t.Errorf("Expected ReadBits to reject %d bits", n)
+		}
+	}
+	
+	// 64 bits round-trip, starting mid-byte
+	bs.WriteBits(1, 1)
+	bs.WriteBits(0xFEDCBA9876543210, 64)
+	rs := NewBitStream(bs.GetData())
+	rs.ReadBits(1)
+	if got, _ := rs.ReadBits(64); got != 0xFEDCBA9876543210 {
+		t.Errorf("Expected 64-bit value to round-trip, got %#x", got)
+	}
+}
+
+func TestBitStreamBytesAfterBitsAreAligned(t *testing.T) {
+	bs := NewEmptyBitStream()
+	bs.WriteBits(1, 1)
+	bs.WriteByte(0x7F)
+	bs.WriteBits(1, 1)
+	
+	want := []byte{0x80, 0x7F, 0x80}
+	if !bytes.Equal(bs.GetData(), want) {
+		t.Fatalf("Expected %X, got %X", want, bs.GetData())
+	}
+	
+	rs := NewBitStream(bs.GetData())
+	rs.ReadBits(1)
+	if b, _ := rs.ReadByte(); b != 0x7F {
+		t.Errorf("Expected the byte read to start on the next whole byte, got 0x%02X", b)
+	}
+	if bit, _ := rs.ReadBits(1); bit != 1 {
+		t.Errorf("Expected trailing bit 1, got %d", bit)
+	}
+}
+
+func TestBitStreamBoolsInterleavedWithBytes(t *testing.T) {
+	bs := NewEmptyBitStream()
+	bs.WriteBool(true)
+	bs.WriteBool(false)
+	bs.WriteBool(true)
+	bs.WriteUint16(0x1234)
+	bs.WriteBool(false)
+	bs.WriteBool(true)
+	bs.WriteByte(0xAB)
+	
+	// 101xxxxx 12 34 01xxxxxx AB
+	want := []byte{0xA0, 0x12, 0x34, 0x40, 0xAB}
+	if !bytes.Equal(bs.GetData(), want) {
+		t.Fatalf("Expected %X, got %X", want, bs.GetData())
+	}
+	
+	rs := NewBitStream(bs.GetData())
+	for i, expected := range []bool{true, false, true} {
+		if v, err := rs.ReadBool(); err != nil || v != expected {
+			t.Errorf("Bool %d: expected %v, got %v (%v)", i, expected, v, err)
+		}
+	}
+	if v, _ := rs.ReadUint16(); v != 0x1234 {
+		t.Errorf("Expected 0x1234 after the bools, got 0x%04X", v)
+	}
+	for i, expected := range []bool{false, true} {
+		if v, err := rs.ReadBool(); err != nil || v != expected {
+			t.Errorf("Bool %d after uint16: expected %v, got %v (%v)", i, expected, v, err)
+		}
+	}
+	if b, _ := rs.ReadByte(); b != 0xAB {
+		t.Errorf("Expected 0xAB, got 0x%02X", b)
+	}
+	if _, err := rs.ReadBool(); err == nil {
+		t.Error("Expected an error reading a bool past the end")
+	}
+}
+
+func TestBitStreamFloatsRoundTrip(t *testing.T) {
+	floats32 := []float32{float32(math.NaN()), float32(math.Inf(1)), float32(math.Inf(-1)), -1234.5, 0.008}
+	floats64 := []float64{math.NaN(), math.Inf(1), -0.000001}
+	
+	bs := NewEmptyBitStream()
+	for _, f := range floats32 {
+		bs.WriteFloat32(f)
+	}
+	for _, f := range floats64 {
+		bs.WriteFloat64(f)
+	}
+	
+	rs := NewBitStream(bs.GetData())
+	for _, want := range floats32 {
+		got, err := rs.ReadFloat32()
+		if err != nil {
+			t.Fatalf("ReadFloat32 failed: %v", err)
+		}
+		if math.Float32bits(got) != math.Float32bits(want) {
+			t.Errorf("Expected %v to round-trip, got %v", want, got)
+		}
+	}
+	for _, want := range floats64 {
+		got, err := rs.ReadFloat64()
+		if err != nil {
+			t.Fatalf("ReadFloat64 failed: %v", err)
+		}
+		if math.Float64bits(got) != math.Float64bits(want) {
+			t.Errorf("Expected %v to round-trip, got %v", want, got)
+		}
+	}
+	if _, err := rs.ReadFloat32(); err == nil {
+		t.Error("Expected an error reading past the end")
+	}
+}
+
+func TestBitStreamLittleEndianMatchesRPCEncoding(t *testing.T) {
+	bs := NewEmptyBitStream()
+	bs.WriteUint16LE(0x1234)
+	bs.WriteUint32LE(0xDEADBEEF)
+	bs.WriteInt32LE(-2)
+	bs.WriteFloat32(-1.5)
+	
+	// Same bytes as the rpc.go builders produce
+	var want []byte
+	want = append(want, 0x34, 0x12)
+	writeUint32LE(&want, 0xDEADBEEF)
+	writeInt32LE(&want, -2)
+	writeFloat32LE(&want, -1.5)
+	if !bytes.Equal(bs.GetData(), want) {
+		t.Fatalf("Expected %X, got %X", want, bs.GetData())
+	}
+	
+	rs := NewBitStream(want)
+	if v, _ := rs.ReadUint16LE(); v != 0x1234 {
+		t.Errorf("ReadUint16LE = 0x%04X", v)
+	}
+	if v, _ := rs.ReadUint32LE(); v != 0xDEADBEEF {
+		t.Errorf("ReadUint32LE = 0x%08X", v)
+	}
+	if v, _ := rs.ReadInt32LE(); v != -2 {
+		t.Errorf("ReadInt32LE = %d", v)
+	}
+	if v, _ := rs.ReadFloat32(); v != -1.5 {
+		t.Errorf("ReadFloat32 = %v", v)
+	}
+}
+
+func TestParseConnectionRequest(t *testing.T) {
+	payload := []byte{0, 0, 0, 0, 0, 0, 0, 7, 0, 0, 0, 0, 0, 0, 0x30, 0x39, 0}
+	
+	req, err := ParseConnectionRequest(append(payload, "secret"...))
+	if err != nil {
+		t.Fatalf("ParseConnectionRequest failed: %v", err)
+	}
+	if req.GUID != 7 || req.Time != 12345 || req.Password != "secret" {
+		t.Errorf("Unexpected request: %+v", req)
+	}
+	
+	secured := append([]byte{}, payload...)
+	secured[16] = 1
+	if _, err := ParseConnectionRequest(secured); err == nil {
+		t.Error("Expected error for a secured connection request")
+	}
+	if _, err := ParseConnectionRequest(payload[:12]); err == nil {
+		t.Error("Expected error for a truncated connection request")
+	}
+}
+
+func TestAddToQueueSplits4KBAt576MTU(t *testing.T) {
+	session := NewSession(nil, 576)
+	
+	for send := 0; send < 2; send++ {
+		session.SendQueue = nil
+		if err := session.AddToQueue(&EncapsulatedPacket{Reliability: RELIABLE_ORDERED, Payload: make([]byte, 4096)}); err != nil {
+			t.Fatalf("Expected 4KB payload to be split, got error: %v", err)
+		}
+		
+		// 576 - 60 safety - 4 datagram - 13 encapsulation - 10 split header = 489 bytes per fragment
+		if len(session.SendQueue) != 9 {
+			t.Fatalf("Expected 9 fragments, got %d", len(session.SendQueue))
+		}
+		for i, fragment := range session.SendQueue {
+			if !fragment.Split || fragment.SplitCount != 9 || fragment.SplitIndex != uint32(i) || fragment.SplitID != uint16(send) {
+				t.Errorf("Send %d fragment %d has bad split header: %+v", send, i, fragment)
+			}
+		}
+	}
+	
+	if !session.MTULocked() {
+		t.Errorf("Expected MTU to be locked while fragments are queued")
+	}
+}
@@ -0,0 +1,45 @@
+package protocol
+
+import "sync/atomic"
+
+// ReliabilityCounters counts encapsulated packets by reliability type. One
+// instance can be shared by many sessions; a nil *ReliabilityCounters counts
+// nothing.
+type ReliabilityCounters struct {
+	sent     [8]atomic.Uint64
+	received [8]atomic.Uint64
+}
+
+// ReliabilityCounts is a snapshot of ReliabilityCounters, indexed by the
+// reliability constants (UNRELIABLE .. RELIABLE_ORDERED_WITH_ACK)
+type ReliabilityCounts struct {
+	Sent     [8]uint64
+	Received [8]uint64
+}
+
+// AddSent counts one outgoing packet with the given reliability
+func (c *ReliabilityCounters) AddSent(reliability byte) {
+	if c != nil {
+		c.sent[reliability&0x07].Add(1)
+	}
+}
+
+// AddReceived counts one incoming packet with the given reliability
+func (c *ReliabilityCounters) AddReceived(reliability byte) {
+	if c != nil {
+		c.received[reliability&0x07].Add(1)
+	}
+}
+
+// Snapshot returns the current counts
+func (c *ReliabilityCounters) Snapshot() ReliabilityCounts {
+	var counts ReliabilityCounts
+	if c == nil {
+		return counts
+	}
+	for i := range c.sent {
+		counts.Sent[i] = c.sent[i].Load()
+		counts.Received[i] = c.received[i].Load()
+	}
+	return counts
+}
@@ -2,28 +2,53 @@ package protocol
 
 import (
 	"encoding/binary"
+	"fmt"
 	"math"
 )
 
 // RPC IDs for SA-MP 0.3.7
 const (
 	RPC_InitGame                 = 0x2B // CRITICAL: Must be sent before SetSpawnInfo
-	RPC_SetSpawnInfo             = 0x2C
+	RPC_SetSpawnInfo             = 0x44
 	RPC_SpawnPlayer              = 0x34
 	RPC_TogglePlayerControllable = 0x15
 	RPC_SetPlayerPos             = 0x0C
 	RPC_SetPlayerFacingAngle     = 0x13
+	RPC_SetPlayerVelocity        = 0x5A // ScrSetPlayerVelocity
+	RPC_SetCameraBehindPlayer    = 0xA2 // ScrSetCameraBehindPlayer
 	RPC_SetPlayerHealth          = 0x0E
 	RPC_SetPlayerArmour          = 0x42
 	RPC_GivePlayerWeapon         = 0x16
 	RPC_SetPlayerSkin            = 0x99
 	RPC_SetGameModeText          = 0x3E // Set gamemode text
-	RPC_SetWeather               = 0x0B // Set weather
+	RPC_SetWeather               = 0x98 // Set weather
 	RPC_SetWorldTime             = 0x29 // Set world time
 	RPC_SetGravity               = 0x92 // Set gravity
+	RPC_SetWorldBounds           = 0x11 // ScrSetWorldBounds
+	RPC_ClientMessage            = 0x5D // ScrClientMessage (chat line)
+	RPC_SetPlayerName            = 0x0B // ScrSetPlayerName
+	RPC_CreateObject             = 0x2C // ScrCreateObject
+	RPC_DestroyObject            = 0x2F // ScrDestroyObject
+	RPC_ScmEvent                 = 0x60 // ScmEvent (vehicle color, paintjob, mods)
+	RPC_UpdateVehicleDamageStatus = 0x6A // Panels, doors, lights and tires
+	RPC_SetVehicleHealth         = 0x93
+	RPC_ServerJoin               = 0x89 // another player joined (adds them to the player list)
+	RPC_ServerQuit               = 0x8A // another player left
+	RPC_WorldVehicleAdd          = 0xA4 // create a vehicle on the client
+	RPC_WorldVehicleRemove       = 0xA5
+	RPC_RemovePlayerFromVehicle  = 0x47 // ScrRemovePlayerFromVehicle
+	RPC_SetPlayerColor           = 0x48 // ScrSetPlayerColor (name tag and radar marker)
+	RPC_ShowPlayerNameTagForPlayer = 0x50 // ScrShowPlayerNameTagForPlayer
+	RPC_SetPlayerSpecialAction   = 0x58 // ScrSetPlayerSpecialAction
+	RPC_SetPlayerFightingStyle   = 0x59 // ScrSetPlayerFightingStyle
+	
+	// Sent by the client
+	RPC_ServerCommand            = 0x32 // a line starting with '/'
+	RPC_Chat                     = 0x65 // a chat line
 )
 
-// Helper functions for little-endian encoding (SA-MP uses little-endian for RPCs)
+// Helper functions for little-endian encoding (SA-MP uses little-endian for RPCs).
+// BitStream's ReadUint32LE, ReadInt32LE and ReadFloat32 read these back.
 
 func writeUint8(buf *[]byte, v uint8) {
 	*buf = append(*buf, v)
@@ -52,9 +77,128 @@ func writeFloat32LE(buf *[]byte, f float32) {
 	writeUint32LE(buf, bits)
 }
 
+// Player marker modes for InitGame
+const (
+	PlayerMarkersOff      = 0
+	PlayerMarkersGlobal   = 1
+	PlayerMarkersStreamed = 2
+)
+
+// InitGameParams describes an InitGame RPC. The zero value is usable: numeric
+// fields left at 0 are filled from SA-MP's defaults by BuildInitGameRPCFromParams,
+// and options that default to on are named so that false keeps the default.
+// Weather, WorldTimeHour and PlayerID are sent as-is since 0 is a valid value.
+type InitGameParams struct {
+	ZoneNames                    bool
+	UseCJWalk                    bool
+	AllowWeapons                 bool // weapons inside interiors
+	LimitGlobalChatRadius        bool
+	GlobalChatRadius             float32
+	DisableStuntBonus            bool
+	NameTagDrawDistance          float32 // default 70
+	DisableEnterExits            bool
+	NameTagsIgnoreLOS            bool
+	ManualVehicleEngineAndLights bool
+	SpawnsAvailable              uint32 // default 1
+	PlayerID                     uint16
+	HideNameTags                 bool
+	PlayerMarkers                uint32 // default PlayerMarkersGlobal
+	HidePlayerMarkers            bool   // send PlayerMarkersOff
+	WorldTimeHour                uint8
+	Weather                      uint8
+	Gravity                      float32 // default 0.008
+	LanMode                      bool
+	DeathDropMoney               int32
+	Instagib                     bool
+	OnFootRate                   uint32 // default 40
+	InCarRate                    uint32 // default 40
+	WeaponRate                   uint32 // default 40
+	Multiplier                   uint32 // default 1000
+	LagCompensation              uint32 // default 1
+	DisableLagCompensation       bool   // send 0
+	Hostname                     string
+	VehicleFriendlyFire          bool
+	UsePlayerPedAnims            bool
+	WorldBoundsMinX              float32 // all four 0 = default +/-20000
+	WorldBoundsMinY              float32
+	WorldBoundsMaxX              float32
+	WorldBoundsMaxY              float32
+	GamemodeText                 string
+	MapName                      string
+}
+
+// withDefaults fills unset numeric fields with SA-MP's defaults
+func (p InitGameParams) withDefaults() InitGameParams {
+	if p.NameTagDrawDistance == 0 {
+		p.NameTagDrawDistance = 70.0
+	}
+	if p.SpawnsAvailable == 0 {
+		p.SpawnsAvailable = 1
+	}
+	if p.PlayerMarkers == 0 {
+		p.PlayerMarkers = PlayerMarkersGlobal
+	}
+	if p.Gravity == 0 {
+		p.Gravity = 0.008
+	}
+	if p.OnFootRate == 0 {
+		p.OnFootRate = 40
+	}
+	if p.InCarRate == 0 {
+		p.InCarRate = 40
+	}
+	if p.WeaponRate == 0 {
+		p.WeaponRate = 40
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = 1000
+	}
+	if p.LagCompensation == 0 {
+		p.LagCompensation = 1
+	}
+	if p.WorldBoundsMinX == 0 && p.WorldBoundsMinY == 0 && p.WorldBoundsMaxX == 0 && p.WorldBoundsMaxY == 0 {
+		p.WorldBoundsMinX, p.WorldBoundsMinY = -20000.0, -20000.0
+		p.WorldBoundsMaxX, p.WorldBoundsMaxY = 20000.0, 20000.0
+	}
+	return p
+}
+
+// InitGame value ranges the SA-MP client accepts
+const (
+	MaxWeatherID = 45
+	MinGravity   = -50.0
+	MaxGravity   = 50.0
+)
+
+// BuildInitGameRPCChecked is BuildInitGameRPCFromParams with range checks on
+// the fields a client silently rejects. maxPlayers bounds PlayerID.
+func BuildInitGameRPCChecked(p InitGameParams, maxPlayers int) ([]byte, error) {
+	p = p.withDefaults()
+	
+	if p.Weather > MaxWeatherID {
+		return nil, fmt.Errorf("InitGame: weather %d out of range (0-%d)", p.Weather, MaxWeatherID)
+	}
+	if p.WorldTimeHour > 23 {
+		return nil, fmt.Errorf("InitGame: world time hour %d out of range (0-23)", p.WorldTimeHour)
+	}
+	if g := float64(p.Gravity); math.IsNaN(g) || g < MinGravity || g > MaxGravity {
+		return nil, fmt.Errorf("InitGame: gravity %f out of range (%.0f to %.0f)", p.Gravity, MinGravity, MaxGravity)
+	}
+	if int(p.PlayerID) >= maxPlayers {
+		return nil, fmt.Errorf("InitGame: player id %d out of range (max players %d)", p.PlayerID, maxPlayers)
+	}
+	
+	return encodeInitGame(p), nil
+}
+
+// BuildInitGameRPCFromParams builds InitGame from params, filling unset fields from defaults
+func BuildInitGameRPCFromParams(p InitGameParams) []byte {
+	return encodeInitGame(p.withDefaults())
+}
+
 // BuildInitGameRPC builds InitGame RPC payload (0x2B) for SA-MP 0.3.7-R2
 // CRITICAL: This MUST be sent before SetSpawnInfo for SA-MP 0.3.7 client
-// Structure based on official SA-MP 0.3.7-R2 protocol
+// Every field is sent exactly as passed; prefer BuildInitGameRPCFromParams.
 func BuildInitGameRPC(
 	zoneNames bool,
 	useCJWalk bool,
@@ -91,161 +235,123 @@ func BuildInitGameRPC(
 	gamemodeText string,
 	mapName string,
 ) []byte {
+	return encodeInitGame(InitGameParams{
+		ZoneNames:                    zoneNames,
+		UseCJWalk:                    useCJWalk,
+		AllowWeapons:                 allowWeapons,
+		LimitGlobalChatRadius:        limitGlobalChatRadius,
+		GlobalChatRadius:             globalChatRadius,
+		DisableStuntBonus:            !stuntBonus,
+		NameTagDrawDistance:          nameTagDrawDistance,
+		DisableEnterExits:            disableEnterExits,
+		NameTagsIgnoreLOS:            !nameTagLOS,
+		ManualVehicleEngineAndLights: manualVehicleEngineAndLights,
+		SpawnsAvailable:              spawnsAvailable,
+		PlayerID:                     playerID,
+		HideNameTags:                 !showNameTags,
+		PlayerMarkers:                showPlayerMarkers,
+		HidePlayerMarkers:            showPlayerMarkers == PlayerMarkersOff,
+		WorldTimeHour:                worldTimeHour,
+		Weather:                      weather,
+		Gravity:                      gravity,
+		LanMode:                      lanMode,
+		DeathDropMoney:               deathDropMoney,
+		Instagib:                     instagib,
+		OnFootRate:                   onFootRate,
+		InCarRate:                    inCarRate,
+		WeaponRate:                   weaponRate,
+		Multiplier:                   multiplier,
+		LagCompensation:              lagCompensation,
+		DisableLagCompensation:       lagCompensation == 0,
+		Hostname:                     hostname,
+		VehicleFriendlyFire:          vehicleFriendlyFire,
+		UsePlayerPedAnims:            usePlayerPedAnims,
+		WorldBoundsMinX:              worldBoundsMinX,
+		WorldBoundsMinY:              worldBoundsMinY,
+		WorldBoundsMaxX:              worldBoundsMaxX,
+		WorldBoundsMaxY:              worldBoundsMaxY,
+		GamemodeText:                 gamemodeText,
+		MapName:                      mapName,
+	})
+}
+
+func writeBool(buf *[]byte, v bool) {
+	if v {
+		writeUint8(buf, 1)
+	} else {
+		writeUint8(buf, 0)
+	}
+}
+
+// encodeInitGame writes the InitGame payload. Structure based on official SA-MP 0.3.7-R2 protocol.
+func encodeInitGame(p InitGameParams) []byte {
 	buf := make([]byte, 0, 512)
 	
 	// RPC ID
 	writeUint8(&buf, RPC_InitGame)
 	
-	// Zone names enabled
-	if zoneNames {
-		writeUint8(&buf, 1)
-	} else {
-		writeUint8(&buf, 0)
-	}
-	
-	// Use CJ walk
-	if useCJWalk {
-		writeUint8(&buf, 1)
-	} else {
-		writeUint8(&buf, 0)
-	}
-	
-	// Allow weapons
-	if allowWeapons {
-		writeUint8(&buf, 1)
-	} else {
-		writeUint8(&buf, 0)
-	}
-	
-	// Limit global chat radius
-	if limitGlobalChatRadius {
-		writeUint8(&buf, 1)
-	} else {
-		writeUint8(&buf, 0)
-	}
-	
-	// Global chat radius
-	writeFloat32LE(&buf, globalChatRadius)
-	
-	// Stunt bonus
-	if stuntBonus {
-		writeUint8(&buf, 1)
-	} else {
-		writeUint8(&buf, 0)
-	}
-	
-	// Name tag draw distance
-	writeFloat32LE(&buf, nameTagDrawDistance)
-	
-	// Disable enter/exit markers
-	if disableEnterExits {
-		writeUint8(&buf, 1)
-	} else {
-		writeUint8(&buf, 0)
-	}
-	
-	// Name tag LOS
-	if nameTagLOS {
-		writeUint8(&buf, 1)
-	} else {
-		writeUint8(&buf, 0)
-	}
-	
-	// Manual vehicle engine and lights
-	if manualVehicleEngineAndLights {
-		writeUint8(&buf, 1)
-	} else {
-		writeUint8(&buf, 0)
-	}
-	
-	// Spawns available
-	writeUint32LE(&buf, spawnsAvailable)
+	writeBool(&buf, p.ZoneNames)
+	writeBool(&buf, p.UseCJWalk)
+	writeBool(&buf, p.AllowWeapons)
+	writeBool(&buf, p.LimitGlobalChatRadius)
+	writeFloat32LE(&buf, p.GlobalChatRadius)
+	writeBool(&buf, !p.DisableStuntBonus)
+	writeFloat32LE(&buf, p.NameTagDrawDistance)
+	writeBool(&buf, p.DisableEnterExits)
+	writeBool(&buf, !p.NameTagsIgnoreLOS)
+	writeBool(&buf, p.ManualVehicleEngineAndLights)
+	writeUint32LE(&buf, p.SpawnsAvailable)
 	
 	// Player ID (2 bytes little endian)
-	buf = append(buf, byte(playerID), byte(playerID>>8))
-	
-	// Show name tags
-	if showNameTags {
-		writeUint8(&buf, 1)
-	} else {
-		writeUint8(&buf, 0)
-	}
-	
-	// Show player markers
-	writeUint32LE(&buf, showPlayerMarkers)
-	
-	// World time (hour)
-	writeUint8(&buf, worldTimeHour)
-	
-	// Weather
-	writeUint8(&buf, weather)
+	buf = append(buf, byte(p.PlayerID), byte(p.PlayerID>>8))
 	
-	// Gravity
-	writeFloat32LE(&buf, gravity)
-	
-	// LAN mode
-	if lanMode {
-		writeUint8(&buf, 1)
+	writeBool(&buf, !p.HideNameTags)
+	if p.HidePlayerMarkers {
+		writeUint32LE(&buf, PlayerMarkersOff)
 	} else {
-		writeUint8(&buf, 0)
+		writeUint32LE(&buf, p.PlayerMarkers)
 	}
+	writeUint8(&buf, p.WorldTimeHour)
+	writeUint8(&buf, p.Weather)
+	writeFloat32LE(&buf, p.Gravity)
+	writeBool(&buf, p.LanMode)
+	writeInt32LE(&buf, p.DeathDropMoney)
+	writeBool(&buf, p.Instagib)
+	
+	// Sync rates
+	writeUint32LE(&buf, p.OnFootRate)
+	writeUint32LE(&buf, p.InCarRate)
+	writeUint32LE(&buf, p.WeaponRate)
+	writeUint32LE(&buf, p.Multiplier)
 	
-	// Death drop money
-	writeInt32LE(&buf, deathDropMoney)
-	
-	// Instagib
-	if instagib {
-		writeUint8(&buf, 1)
+	// Lag compensation
+	if p.DisableLagCompensation {
+		writeUint32LE(&buf, 0)
 	} else {
-		writeUint8(&buf, 0)
+		writeUint32LE(&buf, p.LagCompensation)
 	}
 	
-	// OnFoot rate
-	writeUint32LE(&buf, onFootRate)
-	
-	// InCar rate
-	writeUint32LE(&buf, inCarRate)
-	
-	// Weapon rate
-	writeUint32LE(&buf, weaponRate)
-	
-	// Multiplier
-	writeUint32LE(&buf, multiplier)
-	
-	// Lag compensation
-	writeUint32LE(&buf, lagCompensation)
-	
 	// SA-MP 0.3.7-R2: Hostname (string with uint32 length prefix)
-	writeUint32LE(&buf, uint32(len(hostname)))
-	buf = append(buf, []byte(hostname)...)
+	writeUint32LE(&buf, uint32(len(p.Hostname)))
+	buf = append(buf, []byte(p.Hostname)...)
 	
-	// SA-MP 0.3.7-R2: Vehicle friendly fire
-	if vehicleFriendlyFire {
-		writeUint8(&buf, 1)
-	} else {
-		writeUint8(&buf, 0)
-	}
-	
-	// SA-MP 0.3.7-R2: Use player ped anims
-	if usePlayerPedAnims {
-		writeUint8(&buf, 1)
-	} else {
-		writeUint8(&buf, 0)
-	}
+	// SA-MP 0.3.7-R2: Vehicle friendly fire, player ped anims
+	writeBool(&buf, p.VehicleFriendlyFire)
+	writeBool(&buf, p.UsePlayerPedAnims)
 	
 	// SA-MP 0.3.7-R2: World bounds (4 floats)
-	writeFloat32LE(&buf, worldBoundsMinX)
-	writeFloat32LE(&buf, worldBoundsMinY)
-	writeFloat32LE(&buf, worldBoundsMaxX)
-	writeFloat32LE(&buf, worldBoundsMaxY)
+	writeFloat32LE(&buf, p.WorldBoundsMinX)
+	writeFloat32LE(&buf, p.WorldBoundsMinY)
+	writeFloat32LE(&buf, p.WorldBoundsMaxX)
+	writeFloat32LE(&buf, p.WorldBoundsMaxY)
 	
 	// SA-MP 0.3.7-R2: Gamemode text (string with uint32 length prefix)
-	writeUint32LE(&buf, uint32(len(gamemodeText)))
-	buf = append(buf, []byte(gamemodeText)...)
+	writeUint32LE(&buf, uint32(len(p.GamemodeText)))
+	buf = append(buf, []byte(p.GamemodeText)...)
 	
 	// SA-MP 0.3.7-R2: Map name (string with uint32 length prefix)
-	writeUint32LE(&buf, uint32(len(mapName)))
-	buf = append(buf, []byte(mapName)...)
+	writeUint32LE(&buf, uint32(len(p.MapName)))
+	buf = append(buf, []byte(p.MapName)...)
 	
 	return buf
 }
@@ -315,6 +421,148 @@ func BuildSetPlayerFacingAngleRPC(angle float32) []byte {
 	return buf
 }
 
+// BuildSetPlayerVelocityRPC builds SetPlayerVelocity RPC payload (0x5A)
+func BuildSetPlayerVelocityRPC(x, y, z float32) []byte {
+	buf := make([]byte, 0, 13)
+	writeUint8(&buf, RPC_SetPlayerVelocity)
+	writeFloat32LE(&buf, x)
+	writeFloat32LE(&buf, y)
+	writeFloat32LE(&buf, z)
+	return buf
+}
+
+// BuildSetCameraBehindPlayerRPC builds SetCameraBehindPlayer RPC payload (0xA2)
+func BuildSetCameraBehindPlayerRPC() []byte {
+	return []byte{RPC_SetCameraBehindPlayer}
+}
+
+// BuildSetPlayerHealthRPC builds SetPlayerHealth RPC payload (0x0E)
+func BuildSetPlayerHealthRPC(health float32) []byte {
+	buf := make([]byte, 0, 5)
+	writeUint8(&buf, RPC_SetPlayerHealth)
+	writeFloat32LE(&buf, health)
+	return buf
+}
+
+// BuildSetPlayerArmourRPC builds SetPlayerArmour RPC payload (0x42)
+func BuildSetPlayerArmourRPC(armour float32) []byte {
+	buf := make([]byte, 0, 5)
+	writeUint8(&buf, RPC_SetPlayerArmour)
+	writeFloat32LE(&buf, armour)
+	return buf
+}
+
+// BuildGivePlayerWeaponRPC builds GivePlayerWeapon RPC payload (0x16)
+func BuildGivePlayerWeaponRPC(weaponID uint32, ammo uint32) []byte {
+	buf := make([]byte, 0, 9)
+	writeUint8(&buf, RPC_GivePlayerWeapon)
+	writeUint32LE(&buf, weaponID)
+	writeUint32LE(&buf, ammo)
+	return buf
+}
+
+// BuildSetPlayerNameRPC builds SetPlayerName RPC payload (0x0B)
+func BuildSetPlayerNameRPC(playerID uint16, name string) []byte {
+	buf := make([]byte, 0, len(name)+5)
+	writeUint8(&buf, RPC_SetPlayerName)
+	
+	// Player ID (2 bytes little endian)
+	buf = append(buf, byte(playerID), byte(playerID>>8))
+	
+	// Name with uint8 length prefix
+	writeUint8(&buf, uint8(len(name)))
+	buf = append(buf, []byte(name)...)
+	
+	// Success flag
+	writeUint8(&buf, 1)
+	
+	return buf
+}
+
+// BuildCreateObjectRPC builds CreateObject RPC payload (0x2C)
+func BuildCreateObjectRPC(objectID uint16, modelID int32, x, y, z, rotX, rotY, rotZ, drawDistance float32) []byte {
+	buf := make([]byte, 0, 40)
+	writeUint8(&buf, RPC_CreateObject)
+	buf = append(buf, byte(objectID), byte(objectID>>8))
+	writeInt32LE(&buf, modelID)
+	writeFloat32LE(&buf, x)
+	writeFloat32LE(&buf, y)
+	writeFloat32LE(&buf, z)
+	writeFloat32LE(&buf, rotX)
+	writeFloat32LE(&buf, rotY)
+	writeFloat32LE(&buf, rotZ)
+	writeFloat32LE(&buf, drawDistance)
+	
+	// No camera collision off, not attached to an object or vehicle
+	writeUint8(&buf, 0)
+	buf = append(buf, 0xFF, 0xFF)
+	buf = append(buf, 0xFF, 0xFF)
+	
+	return buf
+}
+
+// BuildDestroyObjectRPC builds DestroyObject RPC payload (0x2F)
+func BuildDestroyObjectRPC(objectID uint16) []byte {
+	buf := make([]byte, 0, 3)
+	writeUint8(&buf, RPC_DestroyObject)
+	buf = append(buf, byte(objectID), byte(objectID>>8))
+	return buf
+}
+
+// ScmEvent types
+const (
+	SCM_EVENT_PAINTJOB = 1
+	SCM_EVENT_MOD      = 2
+	SCM_EVENT_COLOR    = 3
+)
+
+// buildScmEventRPC builds a vehicle ScmEvent RPC payload (0x60)
+func buildScmEventRPC(event uint32, vehicleID uint16, param1, param2 uint32) []byte {
+	buf := make([]byte, 0, 19)
+	writeUint8(&buf, RPC_ScmEvent)
+	
+	// Player ID (server-originated)
+	buf = append(buf, 0xFF, 0xFF)
+	
+	writeUint32LE(&buf, event)
+	writeUint32LE(&buf, uint32(vehicleID))
+	writeUint32LE(&buf, param1)
+	writeUint32LE(&buf, param2)
+	return buf
+}
+
+// BuildSetVehicleColorRPC builds a ScmEvent RPC that changes a vehicle's colors
+func BuildSetVehicleColorRPC(vehicleID uint16, color1, color2 uint8) []byte {
+	return buildScmEventRPC(SCM_EVENT_COLOR, vehicleID, uint32(color1), uint32(color2))
+}
+
+// BuildSetVehiclePaintjobRPC builds a ScmEvent RPC that applies a vehicle paintjob
+func BuildSetVehiclePaintjobRPC(vehicleID uint16, paintjob uint8) []byte {
+	return buildScmEventRPC(SCM_EVENT_PAINTJOB, vehicleID, uint32(paintjob), 0)
+}
+
+// BuildSetVehicleHealthRPC builds SetVehicleHealth RPC payload (0x93)
+func BuildSetVehicleHealthRPC(vehicleID uint16, health float32) []byte {
+	buf := make([]byte, 0, 7)
+	writeUint8(&buf, RPC_SetVehicleHealth)
+	buf = append(buf, byte(vehicleID), byte(vehicleID>>8))
+	writeFloat32LE(&buf, health)
+	return buf
+}
+
+// BuildUpdateVehicleDamageStatusRPC builds UpdateVehicleDamageStatus RPC payload (0x6A).
+// panels, doors, lights and tires are the SA-MP damage bitfields.
+func BuildUpdateVehicleDamageStatusRPC(vehicleID uint16, panels, doors uint32, lights, tires uint8) []byte {
+	buf := make([]byte, 0, 13)
+	writeUint8(&buf, RPC_UpdateVehicleDamageStatus)
+	buf = append(buf, byte(vehicleID), byte(vehicleID>>8))
+	writeUint32LE(&buf, panels)
+	writeUint32LE(&buf, doors)
+	writeUint8(&buf, lights)
+	writeUint8(&buf, tires)
+	return buf
+}
+
 // EncodeRPCPacket wraps RPC payload with RakNet RPC ID
 func EncodeRPCPacket(rpcPayload []byte) []byte {
 	// CRITICAL: SA-MP RPC packets start with 0x7C (ID_RPC), NOT 0x19!
@@ -326,6 +574,43 @@ func EncodeRPCPacket(rpcPayload []byte) []byte {
 	return packet
 }
 
+// DecodeChatRPC decodes the parameters of a client's Chat RPC (0x65):
+// [len u8][text]
+func DecodeChatRPC(params []byte) (string, error) {
+	if len(params) < 1 || len(params) < 1+int(params[0]) {
+		return "", fmt.Errorf("chat rpc: %d bytes, truncated text", len(params))
+	}
+	return string(params[1 : 1+int(params[0])]), nil
+}
+
+// BuildChatRPC builds the Chat RPC payload (0x65) that shows a player's chat
+// line above the chat box: [player id u16][len u8][text]. Text is cut to 255
+// bytes.
+func BuildChatRPC(playerID uint16, text string) []byte {
+	if len(text) > 255 {
+		text = text[:255]
+	}
+	buf := make([]byte, 0, 4+len(text))
+	writeUint8(&buf, RPC_Chat)
+	buf = append(buf, byte(playerID), byte(playerID>>8))
+	writeUint8(&buf, uint8(len(text)))
+	buf = append(buf, text...)
+	return buf
+}
+
+// DecodeServerCommandRPC decodes the parameters of a client's ServerCommand
+// RPC (0x32): [len u32][text], little-endian
+func DecodeServerCommandRPC(params []byte) (string, error) {
+	if len(params) < 4 {
+		return "", fmt.Errorf("command rpc: %d bytes, want at least 4", len(params))
+	}
+	n := binary.LittleEndian.Uint32(params[0:4])
+	if uint64(n) > uint64(len(params)-4) {
+		return "", fmt.Errorf("command rpc: length %d exceeds %d bytes", n, len(params)-4)
+	}
+	return string(params[4 : 4+n]), nil
+}
+
 // Helper to convert uint32 to bytes (little endian)
 func Uint32ToBytes(v uint32) []byte {
 	b := make([]byte, 4)
@@ -376,3 +661,221 @@ func BuildSetGravityRPC(gravity float32) []byte {
 	writeFloat32LE(&buf, gravity)
 	return buf
 }
+
+// BuildSendClientMessageRPC builds ClientMessage RPC payload (0x5D)
+// color is 0xRRGGBBAA (see RGBA and the Color constants), sent little-endian
+// as the client reads it.
+func BuildSendClientMessageRPC(color uint32, message string) []byte {
+	buf := make([]byte, 0, 9+len(message))
+	writeUint8(&buf, RPC_ClientMessage)
+	writeUint32LE(&buf, color)
+	writeUint32LE(&buf, uint32(len(message)))
+	buf = append(buf, message...)
+	return buf
+}
+
+// BuildSetWorldBoundsRPC builds SetWorldBounds RPC payload (0x11)
+// The client expects maxX, minX, maxY, minY on the wire.
+func BuildSetWorldBoundsRPC(minX, minY, maxX, maxY float32) []byte {
+	buf := make([]byte, 0, 17)
+	writeUint8(&buf, RPC_SetWorldBounds)
+	writeFloat32LE(&buf, maxX)
+	writeFloat32LE(&buf, minX)
+	writeFloat32LE(&buf, maxY)
+	writeFloat32LE(&buf, minY)
+	return buf
+}
+
+// BuildServerJoinRPC builds ServerJoin RPC payload (0x89), telling a client
+// about another player: [id u16][color u32][isNPC u8][name len u8][name]
+func BuildServerJoinRPC(playerID uint16, color uint32, isNPC bool, name string) []byte {
+	buf := make([]byte, 0, 9+len(name))
+	writeUint8(&buf, RPC_ServerJoin)
+	buf = append(buf, byte(playerID), byte(playerID>>8))
+	writeUint32LE(&buf, color)
+	if isNPC {
+		writeUint8(&buf, 1)
+	} else {
+		writeUint8(&buf, 0)
+	}
+	writeUint8(&buf, uint8(len(name)))
+	buf = append(buf, name...)
+	return buf
+}
+
+// BuildServerQuitRPC builds ServerQuit RPC payload (0x8A). reason is 0
+// (timeout), 1 (quit) or 2 (kicked/banned).
+func BuildServerQuitRPC(playerID uint16, reason uint8) []byte {
+	buf := make([]byte, 0, 4)
+	writeUint8(&buf, RPC_ServerQuit)
+	buf = append(buf, byte(playerID), byte(playerID>>8))
+	writeUint8(&buf, reason)
+	return buf
+}
+
+// CreateVehicleParams describes a WorldVehicleAdd RPC. Paintjob is 0-2, or
+// 0xFF for none; the damage fields use the UpdateVehicleDamageStatus layout.
+type CreateVehicleParams struct {
+	VehicleID      uint16
+	ModelID        int32
+	X, Y, Z, Angle float32
+	Color1, Color2 uint8
+	Health         float32
+	Interior       uint8
+	Doors, Panels  uint32
+	Lights, Tires  uint8
+	Paintjob       uint8
+}
+
+// BuildCreateVehicleRPC builds WorldVehicleAdd RPC payload (0xA4) for an
+// undamaged vehicle without a paintjob (see BuildCreateVehicleRPCFromParams)
+func BuildCreateVehicleRPC(vehicleID uint16, modelID int32, x, y, z, angle float32, color1, color2 uint8, health float32, interior uint8) []byte {
+	return BuildCreateVehicleRPCFromParams(CreateVehicleParams{
+		VehicleID: vehicleID,
+		ModelID:   modelID,
+		X:         x,
+		Y:         y,
+		Z:         z,
+		Angle:     angle,
+		Color1:    color1,
+		Color2:    color2,
+		Health:    health,
+		Interior:  interior,
+		Paintjob:  0xFF,
+	})
+}
+
+// BuildCreateVehicleRPCFromParams builds WorldVehicleAdd RPC payload (0xA4):
+// [id u16][model i32][x y z angle f32][color1 u8][color2 u8][health f32][interior u8]
+// [doors u32][panels u32][lights u8][tires u8][siren u8][mods 14][paintjob u8]
+// [body color1 i32][body color2 i32]. Mods are sent clear.
+// The virtual world is not part of the RPC; only send it to players in the same world.
+func BuildCreateVehicleRPCFromParams(p CreateVehicleParams) []byte {
+	buf := make([]byte, 0, 64)
+	writeUint8(&buf, RPC_WorldVehicleAdd)
+	buf = append(buf, byte(p.VehicleID), byte(p.VehicleID>>8))
+	writeInt32LE(&buf, p.ModelID)
+	writeFloat32LE(&buf, p.X)
+	writeFloat32LE(&buf, p.Y)
+	writeFloat32LE(&buf, p.Z)
+	writeFloat32LE(&buf, p.Angle)
+	writeUint8(&buf, p.Color1)
+	writeUint8(&buf, p.Color2)
+	writeFloat32LE(&buf, p.Health)
+	writeUint8(&buf, p.Interior)
+	writeUint32LE(&buf, p.Doors)
+	writeUint32LE(&buf, p.Panels)
+	writeUint8(&buf, p.Lights)
+	writeUint8(&buf, p.Tires)
+	writeUint8(&buf, 0)                    // siren
+	buf = append(buf, make([]byte, 14)...) // component mods
+	writeUint8(&buf, p.Paintjob)
+	writeInt32LE(&buf, int32(p.Color1))
+	writeInt32LE(&buf, int32(p.Color2))
+	return buf
+}
+
+// BuildRemovePlayerFromVehicleRPC builds RemovePlayerFromVehicle RPC payload (0x47)
+func BuildRemovePlayerFromVehicleRPC() []byte {
+	return []byte{RPC_RemovePlayerFromVehicle}
+}
+
+// BuildDestroyVehicleRPC builds WorldVehicleRemove RPC payload (0xA5)
+func BuildDestroyVehicleRPC(vehicleID uint16) []byte {
+	return []byte{RPC_WorldVehicleRemove, byte(vehicleID), byte(vehicleID >> 8)}
+}
+
+// BuildSetPlayerColorRPC builds SetPlayerColor RPC payload (0x48):
+// [id u16][color u32 RGBA]. An alpha of 0 hides the player's radar marker.
+func BuildSetPlayerColorRPC(playerID uint16, color uint32) []byte {
+	buf := make([]byte, 0, 7)
+	writeUint8(&buf, RPC_SetPlayerColor)
+	buf = append(buf, byte(playerID), byte(playerID>>8))
+	writeUint32LE(&buf, color)
+	return buf
+}
+
+// Fighting styles for SetPlayerFightingStyle
+const (
+	FightingStyleNormal   = 4
+	FightingStyleBoxing   = 5
+	FightingStyleKungFu   = 6
+	FightingStyleKneeHead = 7
+	FightingStyleGrabKick = 15
+	FightingStyleElbow    = 16
+)
+
+// Special actions for SetPlayerSpecialAction
+const (
+	SpecialActionNone             = 0
+	SpecialActionDuck             = 1
+	SpecialActionUseJetpack       = 2
+	SpecialActionEnterVehicle     = 3
+	SpecialActionExitVehicle      = 4
+	SpecialActionDance1           = 5
+	SpecialActionDance2           = 6
+	SpecialActionDance3           = 7
+	SpecialActionDance4           = 8
+	SpecialActionHandsUp          = 10
+	SpecialActionUseCellphone     = 11
+	SpecialActionSitting          = 12
+	SpecialActionStopUseCellphone = 13
+	SpecialActionDrinkBeer        = 20
+	SpecialActionSmokeCiggy       = 21
+	SpecialActionDrinkWine        = 22
+	SpecialActionDrinkSprunk      = 23
+	SpecialActionCuffed           = 24
+	SpecialActionCarry            = 25
+	SpecialActionPissing          = 68
+)
+
+// IsValidFightingStyle reports whether the client knows the fighting style
+func IsValidFightingStyle(style uint8) bool {
+	switch style {
+	case FightingStyleNormal, FightingStyleBoxing, FightingStyleKungFu,
+		FightingStyleKneeHead, FightingStyleGrabKick, FightingStyleElbow:
+		return true
+	}
+	return false
+}
+
+// IsValidSpecialAction reports whether the client knows the special action
+func IsValidSpecialAction(action uint8) bool {
+	switch {
+	case action <= SpecialActionDance4:
+		return true
+	case action >= SpecialActionHandsUp && action <= SpecialActionStopUseCellphone:
+		return true
+	case action >= SpecialActionDrinkBeer && action <= SpecialActionCarry:
+		return true
+	}
+	return action == SpecialActionPissing
+}
+
+// BuildSetPlayerFightingStyleRPC builds SetPlayerFightingStyle RPC payload
+// (0x59): [id u16][style u8]
+func BuildSetPlayerFightingStyleRPC(playerID uint16, style uint8) ([]byte, error) {
+	if !IsValidFightingStyle(style) {
+		return nil, fmt.Errorf("SetPlayerFightingStyle: unknown style %d", style)
+	}
+	return []byte{RPC_SetPlayerFightingStyle, byte(playerID), byte(playerID >> 8), style}, nil
+}
+
+// BuildSetPlayerSpecialActionRPC builds SetPlayerSpecialAction RPC payload
+// (0x58): [id u16][action u8]
+func BuildSetPlayerSpecialActionRPC(playerID uint16, action uint8) ([]byte, error) {
+	if !IsValidSpecialAction(action) {
+		return nil, fmt.Errorf("SetPlayerSpecialAction: unknown action %d", action)
+	}
+	return []byte{RPC_SetPlayerSpecialAction, byte(playerID), byte(playerID >> 8), action}, nil
+}
+
+// BuildShowPlayerNameTagForPlayerRPC builds ShowPlayerNameTagForPlayer RPC
+// payload (0x50): [id u16][show u8]
+func BuildShowPlayerNameTagForPlayerRPC(playerID uint16, show bool) []byte {
+	buf := make([]byte, 0, 4)
+	writeUint8(&buf, RPC_ShowPlayerNameTagForPlayer)
+	buf = append(buf, byte(playerID), byte(playerID>>8))
+	writeBool(&buf, show)
+	return buf
+}
@@ -0,0 +1,329 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSetVehicleHealthRPC(t *testing.T) {
+	rpc := BuildSetVehicleHealthRPC(0x1234, 750.5)
+	
+	if len(rpc) != 7 {
+		t.Fatalf("Expected 7 bytes, got %d", len(rpc))
+	}
+	if rpc[0] != RPC_SetVehicleHealth {
+		t.Errorf("Expected RPC ID 0x%02X, got 0x%02X", RPC_SetVehicleHealth, rpc[0])
+	}
+	if binary.LittleEndian.Uint16(rpc[1:3]) != 0x1234 {
+		t.Errorf("Expected vehicle ID 0x1234, got 0x%04X", binary.LittleEndian.Uint16(rpc[1:3]))
+	}
+	if health := math.Float32frombits(binary.LittleEndian.Uint32(rpc[3:7])); health != 750.5 {
+		t.Errorf("Expected health 750.5, got %f", health)
+	}
+}
+
+func TestUpdateVehicleDamageStatusRPC(t *testing.T) {
+	rpc := BuildUpdateVehicleDamageStatusRPC(7, 0x33221100, 0x04030201, 0x05, 0x0F)
+	
+	expected := []byte{
+		RPC_UpdateVehicleDamageStatus,
+		0x07, 0x00, // vehicle ID
+		0x00, 0x11, 0x22, 0x33, // panels
+		0x01, 0x02, 0x03, 0x04, // doors
+		0x05, // lights
+		0x0F, // tires
+	}
+	
+	if string(rpc) != string(expected) {
+		t.Errorf("Expected %02X, got %02X", expected, rpc)
+	}
+}
+
+func TestSetWorldBoundsRPC(t *testing.T) {
+	rpc := BuildSetWorldBoundsRPC(-100, -200, 300, 400)
+	
+	if len(rpc) != 17 {
+		t.Fatalf("Expected 17 bytes, got %d", len(rpc))
+	}
+	if rpc[0] != RPC_SetWorldBounds {
+		t.Errorf("Expected RPC ID 0x%02X, got 0x%02X", RPC_SetWorldBounds, rpc[0])
+	}
+	
+	// Wire order is maxX, minX, maxY, minY
+	expected := []float32{300, -100, 400, -200}
+	for i, want := range expected {
+		got := math.Float32frombits(binary.LittleEndian.Uint32(rpc[1+i*4:]))
+		if got != want {
+			t.Errorf("Field %d: expected %f, got %f", i, want, got)
+		}
+	}
+}
+
+func TestSendClientMessageRPC(t *testing.T) {
+	rpc := BuildSendClientMessageRPC(0xAABBCCDD, "Hi")
+	
+	expected := []byte{
+		RPC_ClientMessage,
+		0xDD, 0xCC, 0xBB, 0xAA, // color
+		0x02, 0x00, 0x00, 0x00, // length
+		'H', 'i',
+	}
+	if string(rpc) != string(expected) {
+		t.Errorf("Expected %02X, got %02X", expected, rpc)
+	}
+	
+	// 0xFFFF00AA is yellow: the client reads RR GG BB AA from a little-endian uint32
+	yellow := BuildSendClientMessageRPC(0xFFFF00AA, "")
+	if color := yellow[1:5]; string(color) != string([]byte{0xAA, 0x00, 0xFF, 0xFF}) {
+		t.Errorf("Expected yellow sent as AA 00 FF FF, got %02X", color)
+	}
+}
+
+func TestInitGameParamsDefaults(t *testing.T) {
+	rpc := BuildInitGameRPCFromParams(InitGameParams{
+		ZoneNames:    true,
+		Weather:      10,
+		Hostname:     "Test",
+		GamemodeText: "Freeroam",
+	})
+	
+	// Same packet with every default spelled out
+	expected := BuildInitGameRPC(
+		true, false, false, false, 0.0, // zoneNames, cjWalk, weapons, limitChat, chatRadius
+		true, 70.0, false, true, false, // stuntBonus, nameTagDistance, enterExits, nameTagLOS, manualEngine
+		1, 0, true, PlayerMarkersGlobal, // spawns, playerID, showNameTags, markers
+		0, 10, 0.008, false, 0, false, // hour, weather, gravity, lanMode, dropMoney, instagib
+		40, 40, 40, 1000, 1, // rates, multiplier, lagcomp
+		"Test", false, false,
+		-20000.0, -20000.0, 20000.0, 20000.0,
+		"Freeroam", "",
+	)
+	
+	if string(rpc) != string(expected) {
+		t.Errorf("Expected defaults to fill unset fields\nexpected %02X\ngot      %02X", expected, rpc)
+	}
+}
+
+func TestInitGameParamsOverrideDefaults(t *testing.T) {
+	rpc := BuildInitGameRPCFromParams(InitGameParams{Gravity: 0.004, HidePlayerMarkers: true, DisableLagCompensation: true})
+	
+	expected := BuildInitGameRPC(
+		false, false, false, false, 0.0,
+		true, 70.0, false, true, false,
+		1, 0, true, PlayerMarkersOff,
+		0, 0, 0.004, false, 0, false,
+		40, 40, 40, 1000, 0,
+		"", false, false,
+		-20000.0, -20000.0, 20000.0, 20000.0,
+		"", "",
+	)
+	
+	if string(rpc) != string(expected) {
+		t.Errorf("Expected explicit fields to override defaults\nexpected %02X\ngot      %02X", expected, rpc)
+	}
+}
+
+func TestInitGameCheckedRejectsOutOfRange(t *testing.T) {
+	tests := []struct {
+		name   string
+		params InitGameParams
+		want   string
+	}{
+		{"weather", InitGameParams{Weather: 46}, "weather 46"},
+		{"hour", InitGameParams{WorldTimeHour: 24}, "world time hour 24"},
+		{"gravity too high", InitGameParams{Gravity: 51}, "gravity"},
+		{"gravity too low", InitGameParams{Gravity: -51}, "gravity"},
+		{"gravity NaN", InitGameParams{Gravity: float32(math.NaN())}, "gravity"},
+		{"player id", InitGameParams{PlayerID: 100}, "player id 100"},
+	}
+	
+	for _, tt := range tests {
+		_, err := BuildInitGameRPCChecked(tt.params, 100)
+		if err == nil {
+			t.Errorf("%s: expected error", tt.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error mentioning %q, got %q", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestInitGameCheckedAcceptsValidParams(t *testing.T) {
+	params := InitGameParams{Weather: 45, WorldTimeHour: 23, PlayerID: 99}
+	
+	rpc, err := BuildInitGameRPCChecked(params, 100)
+	if err != nil {
+		t.Fatalf("Expected valid params to pass, got %v", err)
+	}
+	if string(rpc) != string(BuildInitGameRPCFromParams(params)) {
+		t.Errorf("Expected checked builder to match the unchecked one")
+	}
+}
+
+func TestServerJoinAndQuitRPC(t *testing.T) {
+	join := BuildServerJoinRPC(0x0102, ColorRed, false, "Alice")
+	expected := append([]byte{RPC_ServerJoin, 0x02, 0x01, 0xFF, 0x00, 0x00, 0xFF, 0, 5}, "Alice"...)
+	if string(join) != string(expected) {
+		t.Errorf("Expected ServerJoin % X, got % X", expected, join)
+	}
+	
+	quit := BuildServerQuitRPC(0x0102, 2)
+	if string(quit) != string([]byte{RPC_ServerQuit, 0x02, 0x01, 2}) {
+		t.Errorf("Unexpected ServerQuit % X", quit)
+	}
+}
+
+func TestCreateVehicleRPCLayout(t *testing.T) {
+	rpc := BuildCreateVehicleRPC(42, 411, 1.5, -2.5, 10.25, 90, 3, 6, 875, 1)
+	
+	if len(rpc) != 64 || rpc[0] != RPC_WorldVehicleAdd {
+		t.Fatalf("Expected 64-byte WorldVehicleAdd, got %d bytes (0x%02X)", len(rpc), rpc[0])
+	}
+	floatAt := func(offset int) float32 {
+		return math.Float32frombits(binary.LittleEndian.Uint32(rpc[offset:]))
+	}
+	
+	if id := binary.LittleEndian.Uint16(rpc[1:3]); id != 42 {
+		t.Errorf("Expected vehicle ID 42, got %d", id)
+	}
+	if model := int32(binary.LittleEndian.Uint32(rpc[3:7])); model != 411 {
+		t.Errorf("Expected model 411, got %d", model)
+	}
+	if x, y, z, angle := floatAt(7), floatAt(11), floatAt(15), floatAt(19); x != 1.5 || y != -2.5 || z != 10.25 || angle != 90 {
+		t.Errorf("Expected position (1.5, -2.5, 10.25) angle 90, got (%v, %v, %v) %v", x, y, z, angle)
+	}
+	if rpc[23] != 3 || rpc[24] != 6 {
+		t.Errorf("Expected colors 3/6, got %d/%d", rpc[23], rpc[24])
+	}
+	if health := floatAt(25); health != 875 {
+		t.Errorf("Expected health 875, got %v", health)
+	}
+	if rpc[29] != 1 {
+		t.Errorf("Expected interior 1, got %d", rpc[29])
+	}
+	if body1, body2 := binary.LittleEndian.Uint32(rpc[56:60]), binary.LittleEndian.Uint32(rpc[60:64]); body1 != 3 || body2 != 6 {
+		t.Errorf("Expected body colors 3/6, got %d/%d", body1, body2)
+	}
+	if rpc[55] != 0xFF {
+		t.Errorf("Expected no paintjob, got %d", rpc[55])
+	}
+}
+
+func TestCreateVehicleRPCCarriesDamageAndPaintjob(t *testing.T) {
+	rpc := BuildCreateVehicleRPCFromParams(CreateVehicleParams{
+		VehicleID: 42,
+		ModelID:   411,
+		Health:    300,
+		Doors:     0x02020202,
+		Panels:    0x11,
+		Lights:    0x05,
+		Tires:     0x0F,
+		Paintjob:  2,
+	})
+	
+	if doors, panels := binary.LittleEndian.Uint32(rpc[30:34]), binary.LittleEndian.Uint32(rpc[34:38]); doors != 0x02020202 || panels != 0x11 {
+		t.Errorf("Expected doors 0x02020202 and panels 0x11, got 0x%X and 0x%X", doors, panels)
+	}
+	if rpc[38] != 0x05 || rpc[39] != 0x0F {
+		t.Errorf("Expected lights 0x05 and tires 0x0F, got 0x%02X and 0x%02X", rpc[38], rpc[39])
+	}
+	if rpc[55] != 2 {
+		t.Errorf("Expected paintjob 2, got %d", rpc[55])
+	}
+}
+
+func TestSetPlayerFightingStyleRPC(t *testing.T) {
+	for _, style := range []uint8{FightingStyleBoxing, FightingStyleElbow} {
+		rpc, err := BuildSetPlayerFightingStyleRPC(0x0102, style)
+		if err != nil {
+			t.Fatalf("Style %d: unexpected error %v", style, err)
+		}
+		if expected := []byte{RPC_SetPlayerFightingStyle, 0x02, 0x01, style}; string(rpc) != string(expected) {
+			t.Errorf("Style %d: expected %02X, got %02X", style, expected, rpc)
+		}
+	}
+	
+	for _, style := range []uint8{0, 8, 17} {
+		if _, err := BuildSetPlayerFightingStyleRPC(0, style); err == nil {
+			t.Errorf("Expected error for fighting style %d", style)
+		}
+	}
+}
+
+func TestSetPlayerSpecialActionRPC(t *testing.T) {
+	for _, action := range []uint8{SpecialActionCuffed, SpecialActionCarry, SpecialActionDrinkBeer} {
+		rpc, err := BuildSetPlayerSpecialActionRPC(7, action)
+		if err != nil {
+			t.Fatalf("Action %d: unexpected error %v", action, err)
+		}
+		if expected := []byte{RPC_SetPlayerSpecialAction, 0x07, 0x00, action}; string(rpc) != string(expected) {
+			t.Errorf("Action %d: expected %02X, got %02X", action, expected, rpc)
+		}
+	}
+	
+	for _, action := range []uint8{9, 14, 26, 255} {
+		if _, err := BuildSetPlayerSpecialActionRPC(7, action); err == nil {
+			t.Errorf("Expected error for special action %d", action)
+		}
+	}
+}
+
+func TestRPCIDsAreUnique(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "rpc.go", nil, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse rpc.go: %v", err)
+	}
+	
+	seen := make(map[int64]string)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			value := spec.(*ast.ValueSpec)
+			for i, name := range value.Names {
+				if !strings.HasPrefix(name.Name, "RPC_") || i >= len(value.Values) {
+					continue
+				}
+				lit, ok := value.Values[i].(*ast.BasicLit)
+				if !ok {
+					continue
+				}
+				id, err := strconv.ParseInt(lit.Value, 0, 64)
+				if err != nil {
+					t.Fatalf("Bad value for %s: %v", name.Name, err)
+				}
+				if other, dup := seen[id]; dup {
+					t.Errorf("%s and %s share RPC ID 0x%02X", other, name.Name, id)
+				}
+				seen[id] = name.Name
+			}
+		}
+	}
+	if len(seen) == 0 {
+		t.Fatal("Expected to find RPC_ constants in rpc.go")
+	}
+}
+
+func TestBuildChatRPC(t *testing.T) {
+	rpc := BuildChatRPC(0x0102, "hello")
+	if rpc[0] != RPC_Chat || binary.LittleEndian.Uint16(rpc[1:3]) != 0x0102 {
+		t.Fatalf("Unexpected header % X", rpc[:3])
+	}
+	if text, err := DecodeChatRPC(rpc[3:]); err != nil || text != "hello" {
+		t.Errorf("Expected text hello, got %q (%v)", text, err)
+	}
+	
+	long := BuildChatRPC(0, strings.Repeat("x", 300))
+	if long[3] != 255 || len(long) != 4+255 {
+		t.Errorf("Expected text cut to 255 bytes, got length byte %d and %d bytes", long[3], len(long))
+	}
+}
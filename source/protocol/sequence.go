@@ -0,0 +1,58 @@
+package protocol
+
+// Datagram sequence numbers, message indices and order indices are 24-bit on
+// the wire. Counters wrap at 2^24 and are compared with half-range modular
+// arithmetic so ordering still works across the rollover.
+const (
+	seqMask = 0xFFFFFF
+	seqHalf = 0x800000
+)
+
+// MaxSeqRange bounds how many sequence numbers one ACK/NACK range may expand
+// to, so a bogus range can't make us walk millions of entries
+const MaxSeqRange = 1 << 16
+
+// MaxAckLookups bounds the sequence numbers one whole ACK/NACK datagram may
+// make us look up, across all of its ranges
+const MaxAckLookups = 1 << 16
+
+// SeqNext returns the 24-bit value following a
+func SeqNext(a uint32) uint32 {
+	return (a + 1) & seqMask
+}
+
+// SeqLess reports whether a comes before b, allowing for wraparound
+func SeqLess(a, b uint32) bool {
+	diff := (b - a) & seqMask
+	return diff != 0 && diff < seqHalf
+}
+
+// SeqRangeLen returns how many sequence numbers start to end inclusive spans,
+// wrapping at 2^24
+func SeqRangeLen(start, end uint32) uint32 {
+	return (end-start)&seqMask + 1
+}
+
+// SeqInRange reports whether seq lies in start to end inclusive, allowing for
+// wraparound
+func SeqInRange(seq, start, end uint32) bool {
+	return (seq-start)&seqMask <= (end-start)&seqMask
+}
+
+// SeqRange returns the sequence numbers from start to end inclusive, wrapping
+// at 2^24. Ranges longer than MaxSeqRange are rejected (nil).
+func SeqRange(start, end uint32) []uint32 {
+	start &= seqMask
+	end &= seqMask
+	
+	length := SeqRangeLen(start, end)
+	if length > MaxSeqRange {
+		return nil
+	}
+	
+	seqs := make([]uint32, length)
+	for i := range seqs {
+		seqs[i] = (start + uint32(i)) & seqMask
+	}
+	return seqs
+}
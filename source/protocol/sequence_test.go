@@ -0,0 +1,102 @@
+package protocol
+
+import (
+	"testing"
+)
+
+func TestSeqLessAcrossWrap(t *testing.T) {
+	tests := []struct {
+		a, b uint32
+		less bool
+	}{
+		{1, 2, true},
+		{2, 1, false},
+		{5, 5, false},
+		{0xFFFFFE, 0xFFFFFF, true},
+		{0xFFFFFF, 0, true}, // rollover
+		{0xFFFFF0, 3, true},
+		{3, 0xFFFFF0, false},
+	}
+	
+	for _, tt := range tests {
+		if got := SeqLess(tt.a, tt.b); got != tt.less {
+			t.Errorf("SeqLess(0x%06X, 0x%06X) = %v, want %v", tt.a, tt.b, got, tt.less)
+		}
+	}
+	
+	if next := SeqNext(0xFFFFFF); next != 0 {
+		t.Errorf("Expected SeqNext to wrap to 0, got 0x%06X", next)
+	}
+}
+
+func TestSeqRangeWraps(t *testing.T) {
+	seqs := SeqRange(0xFFFFFE, 1)
+	expected := []uint32{0xFFFFFE, 0xFFFFFF, 0, 1}
+	if len(seqs) != len(expected) {
+		t.Fatalf("Expected %d sequences, got %v", len(expected), seqs)
+	}
+	for i := range expected {
+		if seqs[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, seqs)
+			break
+		}
+	}
+	
+	if seqs := SeqRange(0, 0xFFFFF0); seqs != nil {
+		t.Errorf("Expected oversized range to be rejected, got %d entries", len(seqs))
+	}
+}
+
+func TestSeqInRangeWraps(t *testing.T) {
+	tests := []struct {
+		seq, start, end uint32
+		in              bool
+	}{
+		{5, 1, 10, true},
+		{11, 1, 10, false},
+		{0, 0xFFFFFE, 1, true}, // rollover
+		{0xFFFFFD, 0xFFFFFE, 1, false},
+		{0xFFFFF0, 0, 0x7FFFFF, false},
+	}
+	
+	for _, tt := range tests {
+		if got := SeqInRange(tt.seq, tt.start, tt.end); got != tt.in {
+			t.Errorf("SeqInRange(0x%06X, 0x%06X, 0x%06X) = %v, want %v", tt.seq, tt.start, tt.end, got, tt.in)
+		}
+	}
+}
+
+func TestOrderedDeliveryAcrossWrap(t *testing.T) {
+	session := NewSession(nil, 576)
+	session.recvOrderIndex = map[uint8]uint32{0: 0xFFFFFF}
+	
+	messageIndex := uint32(0)
+	ordered := func(index uint32, payload byte) *DataPacket {
+		dp := NewDataPacket()
+		dp.Packets = append(dp.Packets, &EncapsulatedPacket{
+			Reliability:  RELIABLE_ORDERED,
+			MessageIndex: messageIndex,
+			OrderIndex:   index,
+			Payload:      []byte{payload},
+		})
+		messageIndex++
+		return dp
+	}
+	
+	if packets := session.HandleDataPacket(ordered(0xFFFFFF, 0x01)); len(packets) != 1 {
+		t.Fatalf("Expected last index before the wrap to be delivered, got %d packets", len(packets))
+	}
+	if idx := session.recvOrderIndex[0]; idx != 0 {
+		t.Fatalf("Expected expected order index to wrap to 0, got 0x%06X", idx)
+	}
+	
+	// Index 0 after the rollover is new, not a duplicate of an old index
+	if packets := session.HandleDataPacket(ordered(0, 0x02)); len(packets) != 1 {
+		t.Errorf("Expected index 0 after the wrap to be delivered, got %d packets", len(packets))
+	}
+	
+	// A resend of the pre-wrap index is a duplicate
+	if packets := session.HandleDataPacket(ordered(0xFFFFFF, 0x01)); len(packets) != 0 {
+		t.Errorf("Expected pre-wrap resend to be dropped as duplicate, got %d packets", len(packets))
+	}
+}
@@ -0,0 +1,29 @@
+package protocol
+
+// SetState moves the session to state, keeping HalfOpen in step. Callers
+// hold s.Mu or own the session, as for any write to State.
+func (s *Session) SetState(state int) {
+	s.State = state
+	s.countHalfOpen(state < STATE_CONNECTED)
+}
+
+// ReleaseHalfOpen takes the session out of HalfOpen for good. Call it when
+// the session is dropped, whatever state it was left in.
+func (s *Session) ReleaseHalfOpen() {
+	s.released.Store(true)
+	s.countHalfOpen(false)
+}
+
+func (s *Session) countHalfOpen(halfOpen bool) {
+	if s.HalfOpen == nil || (halfOpen && s.released.Load()) {
+		return
+	}
+	if s.halfOpen.Swap(halfOpen) == halfOpen {
+		return
+	}
+	if halfOpen {
+		s.HalfOpen.Add(1)
+	} else {
+		s.HalfOpen.Add(-1)
+	}
+}
@@ -0,0 +1,103 @@
+package protocol
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Milestone is a step of the connection sequence recorded on a session
+type Milestone int
+
+const (
+	MilestoneHandshakeReply Milestone = iota // open connection reply sent
+	MilestoneConnectionRequest               // ID_CONNECTION_REQUEST received
+	MilestoneAuth                            // auth key received
+	MilestoneInitGame                        // InitGame RPC sent
+	MilestoneFirstSpawn                      // first SpawnPlayer sent
+	milestoneCount
+)
+
+var milestoneNames = [milestoneCount]string{
+	"handshake reply",
+	"connection request",
+	"auth",
+	"init game",
+	"first spawn",
+}
+
+func (m Milestone) String() string {
+	if m < 0 || m >= milestoneCount {
+		return fmt.Sprintf("milestone(%d)", int(m))
+	}
+	return milestoneNames[m]
+}
+
+// TimelineStep is one recorded milestone and the time since the previous one
+// (or since the session was created, for the first)
+type TimelineStep struct {
+	Milestone Milestone
+	At        time.Time
+	Delta     time.Duration
+}
+
+// MarkMilestone records when a milestone was reached. Only the first time counts,
+// so retransmitted handshake packets do not move it. It reports whether it was new.
+func (s *Session) MarkMilestone(m Milestone) bool {
+	if m < 0 || m >= milestoneCount {
+		return false
+	}
+	
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	
+	if !s.milestones[m].IsZero() {
+		return false
+	}
+	s.milestones[m] = s.Clock.Now()
+	return true
+}
+
+// ReachedMilestone reports whether a milestone has been recorded
+func (s *Session) ReachedMilestone(m Milestone) bool {
+	if m < 0 || m >= milestoneCount {
+		return false
+	}
+	
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	return !s.milestones[m].IsZero()
+}
+
+// ConnectionTimeline returns the milestones reached so far in the order they happened
+func (s *Session) ConnectionTimeline() []TimelineStep {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+	
+	steps := make([]TimelineStep, 0, milestoneCount)
+	for m, at := range s.milestones {
+		if !at.IsZero() {
+			steps = append(steps, TimelineStep{Milestone: Milestone(m), At: at})
+		}
+	}
+	
+	// Milestones usually arrive in declaration order, but sort by time to be sure
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].At.Before(steps[j].At) })
+	
+	previous := s.CreatedAt
+	for i := range steps {
+		steps[i].Delta = steps[i].At.Sub(previous)
+		previous = steps[i].At
+	}
+	return steps
+}
+
+// FormatTimeline renders a timeline as "auth +12ms, init game +3ms, ..."
+func FormatTimeline(steps []TimelineStep) string {
+	parts := make([]string, len(steps))
+	for i, step := range steps {
+		parts[i] = fmt.Sprintf("%s +%s", step.Milestone, step.Delta)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,84 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// InvalidVehicleID is SA-MP's INVALID_VEHICLE_ID
+const InvalidVehicleID = 0xFFFF
+
+// Sizes of the passenger and trailer sync payloads after the packet ID
+const (
+	PassengerSyncSize = 24
+	TrailerSyncSize   = 54
+)
+
+// PassengerSync is a decoded ID_PASSENGER_SYNC (0xD2) payload
+type PassengerSync struct {
+	VehicleID uint16
+	Seat      uint8 // 7 bits
+	DriveBy   bool
+	WeaponID  uint8 // 6 bits
+	Health    uint8
+	Armour    uint8
+	LRKey     uint16
+	UDKey     uint16
+	Keys      uint16
+	Position  [3]float32
+}
+
+// TrailerSync is a decoded ID_TRAILER_SYNC (0xCA) payload
+type TrailerSync struct {
+	TrailerID       uint16
+	Position        [3]float32
+	Quaternion      [4]float32 // w, x, y, z
+	Velocity        [3]float32
+	AngularVelocity [3]float32
+}
+
+// DecodePassengerSync decodes a passenger sync payload (without the packet ID):
+// [vehicle 2][seat:7|driveBy:1 1][weapon:6|key:2 1][health 1][armour 1]
+// [lr 2][ud 2][keys 2][position 12], little-endian
+func DecodePassengerSync(payload []byte) (*PassengerSync, error) {
+	if len(payload) < PassengerSyncSize {
+		return nil, fmt.Errorf("passenger sync: %d bytes, want %d", len(payload), PassengerSyncSize)
+	}
+	
+	ps := &PassengerSync{
+		VehicleID: binary.LittleEndian.Uint16(payload[0:2]),
+		Seat:      payload[2] & 0x7F,
+		DriveBy:   payload[2]&0x80 != 0,
+		WeaponID:  payload[3] & 0x3F,
+		Health:    payload[4],
+		Armour:    payload[5],
+		LRKey:     binary.LittleEndian.Uint16(payload[6:8]),
+		UDKey:     binary.LittleEndian.Uint16(payload[8:10]),
+		Keys:      binary.LittleEndian.Uint16(payload[10:12]),
+	}
+	readFloats(payload[12:], ps.Position[:])
+	return ps, nil
+}
+
+// DecodeTrailerSync decodes a trailer sync payload (without the packet ID):
+// [trailer 2][position 12][quaternion 16][velocity 12][angular velocity 12], little-endian
+func DecodeTrailerSync(payload []byte) (*TrailerSync, error) {
+	if len(payload) < TrailerSyncSize {
+		return nil, fmt.Errorf("trailer sync: %d bytes, want %d", len(payload), TrailerSyncSize)
+	}
+	
+	ts := &TrailerSync{TrailerID: binary.LittleEndian.Uint16(payload[0:2])}
+	readFloats(payload[2:], ts.Position[:])
+	readFloats(payload[14:], ts.Quaternion[:])
+	readFloats(payload[30:], ts.Velocity[:])
+	readFloats(payload[42:], ts.AngularVelocity[:])
+	return ts, nil
+}
+
+// readFloats fills dst with consecutive little-endian floats from src
+func readFloats(src []byte, dst []float32) {
+	for i := range dst {
+		dst[i] = math.Float32frombits(binary.LittleEndian.Uint32(src[i*4:]))
+	}
+}
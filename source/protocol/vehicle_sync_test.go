@@ -0,0 +1,67 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// putFloats writes consecutive little-endian floats into dst
+func putFloats(dst []byte, values ...float32) {
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(dst[i*4:], math.Float32bits(v))
+	}
+}
+
+func TestDecodePassengerSync(t *testing.T) {
+	payload := make([]byte, PassengerSyncSize)
+	binary.LittleEndian.PutUint16(payload[0:2], 42)
+	payload[2] = 0x80 | 2 // seat 2, drive-by
+	payload[3] = 0x40 | 29 // MP5 plus an additional key bit
+	payload[4] = 100
+	payload[5] = 50
+	binary.LittleEndian.PutUint16(payload[10:12], 4)
+	putFloats(payload[12:], 1958.5, 1343.25, 15.5)
+	
+	ps, err := DecodePassengerSync(payload)
+	if err != nil {
+		t.Fatalf("DecodePassengerSync failed: %v", err)
+	}
+	if ps.VehicleID != 42 || ps.Seat != 2 || !ps.DriveBy {
+		t.Errorf("Expected vehicle 42 seat 2 drive-by, got vehicle %d seat %d drive-by %v", ps.VehicleID, ps.Seat, ps.DriveBy)
+	}
+	if ps.WeaponID != 29 || ps.Health != 100 || ps.Armour != 50 || ps.Keys != 4 {
+		t.Errorf("Unexpected weapon/health/armour/keys: %d/%d/%d/%d", ps.WeaponID, ps.Health, ps.Armour, ps.Keys)
+	}
+	if ps.Position != [3]float32{1958.5, 1343.25, 15.5} {
+		t.Errorf("Unexpected position: %v", ps.Position)
+	}
+	
+	if _, err := DecodePassengerSync(payload[:PassengerSyncSize-1]); err == nil {
+		t.Error("Expected error for a short payload")
+	}
+}
+
+func TestDecodeTrailerSync(t *testing.T) {
+	payload := make([]byte, TrailerSyncSize)
+	binary.LittleEndian.PutUint16(payload[0:2], 7)
+	putFloats(payload[2:], 100, 200, 10, 1, 0, 0, 0, 0.5, -0.5, 0, 0, 0, 0.25)
+	
+	ts, err := DecodeTrailerSync(payload)
+	if err != nil {
+		t.Fatalf("DecodeTrailerSync failed: %v", err)
+	}
+	if ts.TrailerID != 7 {
+		t.Errorf("Expected trailer 7, got %d", ts.TrailerID)
+	}
+	if ts.Position != [3]float32{100, 200, 10} || ts.Quaternion != [4]float32{1, 0, 0, 0} {
+		t.Errorf("Unexpected position/quaternion: %v %v", ts.Position, ts.Quaternion)
+	}
+	if ts.Velocity != [3]float32{0.5, -0.5, 0} || ts.AngularVelocity != [3]float32{0, 0, 0.25} {
+		t.Errorf("Unexpected velocities: %v %v", ts.Velocity, ts.AngularVelocity)
+	}
+	
+	if _, err := DecodeTrailerSync(payload[:TrailerSyncSize-1]); err == nil {
+		t.Error("Expected error for a short payload")
+	}
+}
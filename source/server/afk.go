@@ -0,0 +1,65 @@
+package server
+
+import (
+	"bytes"
+	"log"
+	"time"
+)
+
+// syncInputSize covers the on-foot sync keys (lrKey, udKey, keys) and position
+const syncInputSize = 18
+
+// notePlayerInput records on-foot sync input. Any change in keys or position
+// counts as activity and clears the player's AFK state.
+func (s *Server) notePlayerInput(player *Player, payload []byte, now time.Time) {
+	if len(payload) < syncInputSize {
+		return
+	}
+	input := payload[:syncInputSize]
+	
+	s.mu.Lock()
+	if player.lastSyncInput != nil && bytes.Equal(player.lastSyncInput, input) {
+		s.mu.Unlock()
+		return
+	}
+	player.lastSyncInput = append(player.lastSyncInput[:0], input...)
+	player.lastInput = now
+	wasAFK := player.AFK
+	player.AFK = false
+	s.mu.Unlock()
+	
+	if wasAFK {
+		log.Printf("💤 Player %d (%s) is no longer AFK", player.ID, player.Name)
+		s.trigger(EventPlayerAFKChange, player.ID, AFKData{AFK: false})
+	}
+}
+
+// updateAFK marks in-game players AFK once they have sent no input change for
+// AFKTimeout. Players that never sent sync are not tracked.
+func (s *Server) updateAFK(now time.Time) {
+	s.mu.Lock()
+	if s.AFKTimeout <= 0 {
+		s.mu.Unlock()
+		return
+	}
+	idle := make([]*Player, 0)
+	for _, player := range s.Players {
+		if !player.Connected || !player.IsInGame() || player.AFK || player.lastInput.IsZero() {
+			continue
+		}
+		if now.Sub(player.lastInput) >= s.AFKTimeout {
+			player.AFK = true
+			idle = append(idle, player)
+		}
+	}
+	message := s.AFKMessage
+	s.mu.Unlock()
+	
+	for _, player := range idle {
+		log.Printf("💤 Player %d (%s) is AFK", player.ID, player.Name)
+		if message != "" && player.Session != nil {
+			s.sendServerMessage(player.Session, message)
+		}
+		s.trigger(EventPlayerAFKChange, player.ID, AFKData{AFK: true})
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"samp-server-go/source/protocol"
+	"testing"
+	"time"
+)
+
+func TestPlayerAFKTransitions(t *testing.T) {
+	srv := newTestServer()
+	srv.AFKTimeout = 30 * time.Second
+	srv.AFKMessage = "You are now AFK"
+	player := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	
+	var events []bool
+	srv.Events.Register(EventPlayerAFKChange, func(e Event) {
+		if e.PlayerID != player.ID {
+			t.Errorf("Expected AFK event for player 0, got %d", e.PlayerID)
+		}
+		events = append(events, e.Data.(AFKData).AFK)
+	})
+	
+	sync := make([]byte, syncInputSize)
+	start := time.Now()
+	
+	// Identical syncs every 5 seconds carry no input change
+	for i := 0; i <= 6; i++ {
+		now := start.Add(time.Duration(i) * 5 * time.Second)
+		srv.notePlayerInput(player, sync, now)
+		srv.updateAFK(now)
+		if i < 6 && player.AFK {
+			t.Fatalf("Player marked AFK after %v", now.Sub(start))
+		}
+	}
+	if !player.AFK {
+		t.Fatalf("Expected player AFK after %v of identical syncs", srv.AFKTimeout)
+	}
+	if len(queuedRPCs(player.Session)) != 1 {
+		t.Errorf("Expected the AFK message to be sent once, got %d RPCs", len(queuedRPCs(player.Session)))
+	}
+	
+	// Staying idle doesn't fire again
+	srv.updateAFK(start.Add(time.Minute))
+	
+	// A key press clears AFK
+	changed := make([]byte, syncInputSize)
+	changed[4] = 0x08
+	srv.notePlayerInput(player, changed, start.Add(61*time.Second))
+	if player.AFK {
+		t.Errorf("Expected AFK cleared by input change")
+	}
+	if len(events) != 2 || !events[0] || events[1] {
+		t.Errorf("Expected events [true false], got %v", events)
+	}
+}
+
+func TestPlayerAFKDisabled(t *testing.T) {
+	srv := newTestServer()
+	player := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	
+	start := time.Now()
+	srv.notePlayerInput(player, make([]byte, syncInputSize), start)
+	srv.updateAFK(start.Add(time.Hour))
+	if player.AFK {
+		t.Errorf("Expected no AFK tracking with AFKTimeout 0")
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"samp-server-go/source/protocol"
+	"sync"
+	"time"
+)
+
+// Audit event types
+const (
+	AuditAttempt    = "attempt"
+	AuditAccepted   = "accepted"
+	AuditRejected   = "rejected"
+	AuditDisconnect = "disconnect"
+)
+
+// AuditEntry is one line of the connection audit log
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"`
+	IP       string    `json:"ip"`
+	Port     int       `json:"port"`
+	PlayerID int       `json:"player_id"` // -1 if no id was assigned
+	Nickname string    `json:"nickname,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	Detail   string    `json:"detail,omitempty"`
+}
+
+// AuditLog writes connection events as JSON lines to a separate sink
+type AuditLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewAuditLog creates an audit log writing to w
+func NewAuditLog(w io.Writer) *AuditLog {
+	return &AuditLog{
+		enc: json.NewEncoder(w),
+	}
+}
+
+// Record writes an entry, stamping the time if it is not set
+func (a *AuditLog) Record(entry AuditEntry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	
+	if err := a.enc.Encode(entry); err != nil {
+		log.Printf("❌ Failed to write audit entry: %v", err)
+	}
+}
+
+// audit records a connection event if auditing is enabled
+func (s *Server) audit(event string, addr *net.UDPAddr, playerID int, nickname, reason string) {
+	s.recordAudit(AuditEntry{
+		Event:    event,
+		PlayerID: playerID,
+		Nickname: nickname,
+		Reason:   reason,
+	}, addr)
+}
+
+// auditDisconnect records a session going away
+func (s *Server) auditDisconnect(session *protocol.Session, reason DisconnectReason, detail string) {
+	session.Mu.RLock()
+	entry := AuditEntry{
+		Event:    AuditDisconnect,
+		PlayerID: int(session.PlayerID),
+		Nickname: session.Nickname,
+		Reason:   reason.String(),
+		Detail:   detail,
+	}
+	session.Mu.RUnlock()
+	s.recordAudit(entry, session.Addr)
+}
+
+func (s *Server) recordAudit(entry AuditEntry, addr *net.UDPAddr) {
+	if s == nil || s.AuditLog == nil {
+		return
+	}
+	if addr != nil {
+		entry.IP = addr.IP.String()
+		entry.Port = addr.Port
+	}
+	s.AuditLog.Record(entry)
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"samp-server-go/source/protocol"
+	"testing"
+)
+
+func readAuditEntries(t *testing.T, buf *bytes.Buffer) []AuditEntry {
+	t.Helper()
+	
+	entries := make([]AuditEntry, 0)
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var entry AuditEntry
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatalf("Invalid audit entry: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestAuditAcceptedConnection(t *testing.T) {
+	var buf bytes.Buffer
+	srv := newTestServer()
+	srv.AuditLog = NewAuditLog(&buf)
+	
+	session := addTestSession(srv, 50001, protocol.STATE_CONNECTED)
+	session.Nickname = "Tester"
+	srv.handlePlayerJoin(session, &protocol.RakNetPacket{PacketID: protocol.ID_PLAYER_JOIN})
+	srv.raknet.sendConnectionAccepted(session)
+	
+	// One entry per join, however many steps of it run
+	entries := readAuditEntries(t, &buf)
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 audit entry, got %d", len(entries))
+	}
+	
+	entry := entries[0]
+	player, _ := srv.playerForSession(session)
+	if entry.Event != AuditAccepted || entry.IP != "127.0.0.1" || entry.Port != 50001 ||
+		player == nil || entry.PlayerID != int(player.ID) || entry.Nickname != "Tester" {
+		t.Errorf("Unexpected audit entry: %+v", entry)
+	}
+	if entry.Time.IsZero() {
+		t.Error("Audit entry has no timestamp")
+	}
+}
+
+func TestAuditRejectedConnection(t *testing.T) {
+	var buf bytes.Buffer
+	srv := newTestServerWithConn(t)
+	srv.AuditLog = NewAuditLog(&buf)
+	srv.MaxPlayers = 0
+	
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50002}
+	srv.raknet.HandlePacket([]byte{0x08, 0x01, 0x02, 0x03}, addr)
+	
+	entries := readAuditEntries(t, &buf)
+	if len(entries) != 2 {
+		t.Fatalf("Expected attempt and rejection entries, got %d", len(entries))
+	}
+	if entries[0].Event != AuditAttempt {
+		t.Errorf("Expected attempt entry first, got %+v", entries[0])
+	}
+	
+	entry := entries[1]
+	if entry.Event != AuditRejected || entry.Reason != "server full" || entry.IP != "127.0.0.1" ||
+		entry.Port != 50002 || entry.PlayerID != -1 {
+		t.Errorf("Unexpected audit entry: %+v", entry)
+	}
+}
+
+func TestAuditDisabledByDefault(t *testing.T) {
+	srv := newTestServer()
+	session := addTestSession(srv, 50001, protocol.STATE_CONNECTED)
+	
+	// Must not panic without an audit sink
+	srv.raknet.sendConnectionAccepted(session)
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"samp-server-go/source/protocol"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ban is one banned IP address. The player name it was issued for is refused
+// at join too, from any address.
+type Ban struct {
+	IP     string    `json:"ip"`
+	Name   string    `json:"name,omitempty"` // player name when banned
+	Reason string    `json:"reason,omitempty"`
+	Admin  string    `json:"admin,omitempty"` // who issued the ban, empty for the console
+	Time   time.Time `json:"time"`
+}
+
+// BanList is a set of banned IPs and names, safe for concurrent use
+type BanList struct {
+	mu   sync.RWMutex
+	bans map[string]Ban
+}
+
+func NewBanList() *BanList {
+	return &BanList{bans: make(map[string]Ban)}
+}
+
+// Add bans ban.IP, replacing any earlier ban for it
+func (b *BanList) Add(ban Ban) {
+	if ban.Time.IsZero() {
+		ban.Time = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bans[ban.IP] = ban
+}
+
+// Remove lifts the ban on ip and reports whether there was one
+func (b *BanList) Remove(ip string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, banned := b.bans[ip]
+	delete(b.bans, ip)
+	return banned
+}
+
+func (b *BanList) IsBanned(ip string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	_, banned := b.bans[ip]
+	return banned
+}
+
+// IsNameBanned reports whether a ban was issued for name, ignoring case
+func (b *BanList) IsNameBanned(name string) bool {
+	if name == "" {
+		return false
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, ban := range b.bans {
+		if strings.EqualFold(ban.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the bans sorted by IP
+func (b *BanList) List() []Ban {
+	b.mu.RLock()
+	bans := make([]Ban, 0, len(b.bans))
+	for _, ban := range b.bans {
+		bans = append(bans, ban)
+	}
+	b.mu.RUnlock()
+	
+	sort.Slice(bans, func(i, j int) bool { return bans[i].IP < bans[j].IP })
+	return bans
+}
+
+// Save writes the ban list to path as JSON
+func (b *BanList) Save(path string) error {
+	data, err := json.MarshalIndent(b.List(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// Load replaces the ban list with the one saved at path. A missing file is an
+// empty list.
+func (b *BanList) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	
+	var bans []Ban
+	if err := json.Unmarshal(data, &bans); err != nil {
+		return fmt.Errorf("invalid ban list %s: %w", path, err)
+	}
+	
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bans = make(map[string]Ban, len(bans))
+	for _, ban := range bans {
+		b.bans[ban.IP] = ban
+	}
+	return nil
+}
+
+// BanPlayer bans a player's IP on behalf of admin and disconnects them with
+// DisconnectBanned
+func (s *Server) BanPlayer(playerID uint16, admin, reason string) error {
+	player, exists := s.GetPlayer(playerID)
+	if !exists {
+		return fmt.Errorf("player %d not found", playerID)
+	}
+	if player.Addr == nil {
+		return fmt.Errorf("player %d has no address", playerID)
+	}
+	
+	s.Bans.Add(Ban{IP: player.Addr.IP.String(), Name: player.Name, Reason: reason, Admin: admin})
+	log.Printf("🔨 %s banned %s (%s): %s", admin, player.Name, player.Addr.IP, reason)
+	return s.DisconnectPlayer(playerID, DisconnectBanned, reason)
+}
+
+// isBanned reports whether connections from addr are refused
+func (s *Server) isBanned(addr *net.UDPAddr) bool {
+	return s != nil && s.Bans != nil && addr != nil && s.Bans.IsBanned(addr.IP.String())
+}
+
+// isNameBanned reports whether joins under nickname are refused
+func (s *Server) isNameBanned(nickname string) bool {
+	return s != nil && s.Bans != nil && s.Bans.IsNameBanned(nickname)
+}
+
+// rejectBannedName answers a join under a banned nickname with
+// ID_CONNECTION_BANNED, and reports whether it did
+func (rh *RakNetHandler) rejectBannedName(session *protocol.Session) bool {
+	if !rh.server.isNameBanned(session.Nickname) {
+		return false
+	}
+	
+	log.Printf("🚫 Banned name %s from %s, rejecting", session.Nickname, session.Addr)
+	rh.server.audit(AuditRejected, session.Addr, -1, session.Nickname, "banned name")
+	rh.rejectConnection(session, protocol.ID_CONNECTION_BANNED)
+	return true
+}
+
+// rejectBanned answers a banned address with ID_CONNECTION_BANNED before any
+// per-client state is created, and reports whether it did
+func (rh *RakNetHandler) rejectBanned(addr *net.UDPAddr) bool {
+	if !rh.server.isBanned(addr) {
+		return false
+	}
+	
+	log.Printf("🚫 Banned address %s, rejecting", addr)
+	rh.server.audit(AuditRejected, addr, -1, "", "banned")
+	if rh.conn != nil {
+		rh.conn.WriteToUDP([]byte{protocol.ID_CONNECTION_BANNED}, addr)
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"net"
+	"path/filepath"
+	"samp-server-go/source/protocol"
+	"testing"
+	"time"
+)
+
+func TestBanListAddRemoveLookup(t *testing.T) {
+	bans := NewBanList()
+	bans.Add(Ban{IP: "10.0.0.1", Name: "Cheater", Reason: "aimbot", Admin: "Admin"})
+	
+	if !bans.IsBanned("10.0.0.1") || bans.IsBanned("10.0.0.2") {
+		t.Fatal("Expected only 10.0.0.1 to be banned")
+	}
+	if list := bans.List(); len(list) != 1 || list[0].Time.IsZero() {
+		t.Errorf("Expected one timestamped ban, got %+v", list)
+	}
+	
+	if !bans.Remove("10.0.0.1") || bans.IsBanned("10.0.0.1") {
+		t.Error("Expected the ban to be lifted")
+	}
+	if bans.Remove("10.0.0.1") {
+		t.Error("Expected removing a missing ban to report false")
+	}
+}
+
+func TestBanListFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+	when := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	
+	saved := NewBanList()
+	saved.Add(Ban{IP: "10.0.0.2", Name: "B", Reason: "spam", Admin: "Mod", Time: when})
+	saved.Add(Ban{IP: "10.0.0.1", Name: "A", Reason: "aimbot", Admin: "Admin", Time: when})
+	if err := saved.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	
+	loaded := NewBanList()
+	if err := loaded.Load(path); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	got, want := loaded.List(), saved.List()
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d bans, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Ban %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+	
+	// A missing file is an empty list, not an error
+	if err := NewBanList().Load(filepath.Join(t.TempDir(), "none.json")); err != nil {
+		t.Errorf("Expected no error for a missing file, got %v", err)
+	}
+}
+
+func TestBanPlayerRecordsAdmin(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	player := addTestPlayer(srv, 3, protocol.STATE_IN_GAME)
+	player.Name = "Cheater"
+	clock := protocol.NewFakeClock(time.Now())
+	srv.raknet.SetClock(clock)
+	
+	if err := srv.BanPlayer(3, "Admin", "aimbot"); err != nil {
+		t.Fatalf("BanPlayer failed: %v", err)
+	}
+	clock.Advance(srv.DisconnectAckTimeout)
+	srv.raknet.Update()
+	list := srv.Bans.List()
+	if len(list) != 1 || list[0].IP != "127.0.0.1" || list[0].Admin != "Admin" || list[0].Name != "Cheater" {
+		t.Errorf("Unexpected ban recorded: %+v", list)
+	}
+	if _, exists := srv.GetPlayer(3); exists {
+		t.Error("Expected the banned player disconnected")
+	}
+}
+
+func TestBannedAddressRejectedAtOpenConnectionRequest(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open client socket: %v", err)
+	}
+	defer client.Close()
+	clientAddr := client.LocalAddr().(*net.UDPAddr)
+	srv.Bans.Add(Ban{IP: "127.0.0.1"})
+	
+	srv.raknet.HandlePacket(openConnectionRequest1(1492), clientAddr)
+	
+	buf := make([]byte, 64)
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := client.ReadFromUDP(buf)
+	if err != nil || n != 1 || buf[0] != protocol.ID_CONNECTION_BANNED {
+		t.Fatalf("Expected ID_CONNECTION_BANNED, got % X (%v)", buf[:n], err)
+	}
+	if _, probed := srv.raknet.mtuProbes[clientAddr.String()]; probed {
+		t.Error("Expected no handshake state for a banned address")
+	}
+}
+
+func TestBannedNameRejectedAtJoin(t *testing.T) {
+	srv := newTestServer()
+	srv.Bans.Add(Ban{IP: "10.0.0.9", Name: "Cheater"})
+	if srv.Bans.IsNameBanned("") || !srv.Bans.IsNameBanned("cheater") {
+		t.Fatal("Expected the name ban to match regardless of case")
+	}
+	
+	// Same name from an address that was never banned
+	session := addTestSession(srv, 50001, protocol.STATE_CONNECTED)
+	srv.raknet.handleInternalPacket(session, joinRequest("CHEATER", ""))
+	if ids := queuedPacketIDs(session); len(ids) != 1 || ids[0] != protocol.ID_CONNECTION_BANNED {
+		t.Fatalf("Expected ID_CONNECTION_BANNED only, got %02X", ids)
+	}
+	
+	srv.handlePlayerJoin(session, &protocol.RakNetPacket{PacketID: protocol.ID_PLAYER_JOIN})
+	if _, joined := srv.playerForSession(session); joined {
+		t.Error("Expected a banned name not to join")
+	}
+	
+	// Other names from the same address are unaffected
+	other := addTestSession(srv, 50002, protocol.STATE_CONNECTED)
+	srv.raknet.handleInternalPacket(other, joinRequest("Tester", ""))
+	srv.handlePlayerJoin(other, &protocol.RakNetPacket{PacketID: protocol.ID_PLAYER_JOIN})
+	if _, joined := srv.playerForSession(other); !joined {
+		t.Error("Expected an unbanned name to join")
+	}
+}
@@ -0,0 +1,22 @@
+package server
+
+import (
+	"samp-server-go/source/protocol"
+)
+
+// PacketCipher obfuscates encapsulated payloads on the wire (see protocol.PacketCipher)
+type PacketCipher = protocol.PacketCipher
+
+// SetCipher sets the cipher every session applies to encapsulated payloads:
+// decoded in Session.Decapsulate, encoded when a datagram is written. nil
+// (the default) leaves them unchanged.
+func (rh *RakNetHandler) SetCipher(cipher PacketCipher) {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+	rh.cipher = cipher
+	for _, session := range rh.sessions {
+		session.Mu.Lock()
+		session.Cipher = cipher
+		session.Mu.Unlock()
+	}
+}
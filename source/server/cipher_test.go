@@ -0,0 +1,187 @@
+package server
+
+import (
+	"bytes"
+	"net"
+	"samp-server-go/source/protocol"
+	"testing"
+	"time"
+)
+
+// xorCipher is a trivial stand-in for the SA-MP packet cipher
+type xorCipher struct{ key byte }
+
+func (c xorCipher) apply(payload []byte) []byte {
+	out := make([]byte, len(payload))
+	for i, b := range payload {
+		out[i] = b ^ c.key
+	}
+	return out
+}
+
+func (c xorCipher) Encode(session *protocol.Session, payload []byte) []byte { return c.apply(payload) }
+func (c xorCipher) Decode(session *protocol.Session, payload []byte) []byte { return c.apply(payload) }
+
+// readDataPayloads reads datagrams from the client socket until one carries
+// encapsulated packets and returns their payloads as they were on the wire
+func readDataPayloads(t *testing.T, client *net.UDPConn) [][]byte {
+	t.Helper()
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := client.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatalf("Did not receive a data packet: %v", err)
+		}
+		received, err := protocol.DecodeDataPacket(buf[:n])
+		if err != nil || buf[0] == protocol.ID_ACK || buf[0] == protocol.ID_NACK || len(received.Packets) == 0 {
+			continue // ACKs and raw handshake packets
+		}
+		
+		payloads := make([][]byte, len(received.Packets))
+		for i, packet := range received.Packets {
+			payloads[i] = packet.Payload
+		}
+		return payloads
+	}
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	cipher := xorCipher{key: 0x5A}
+	srv.raknet.SetCipher(cipher)
+	
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open client socket: %v", err)
+	}
+	defer client.Close()
+	
+	addr := client.LocalAddr().(*net.UDPAddr)
+	session := srv.raknet.newSession(addr, 576)
+	session.State = protocol.STATE_IN_GAME
+	session.ConnectPhase = protocol.PhaseGameEntrySent
+	srv.raknet.sessions[addr.String()] = session
+	
+	// Inbound: an obfuscated 84-byte 0x88 join request (0x8A auth key)
+	joinPayload := append([]byte{0x8A}, bytes.Repeat([]byte{0x01}, 76)...)
+	dp := protocol.NewDataPacket()
+	dp.Packets = append(dp.Packets, &protocol.EncapsulatedPacket{
+		Reliability: protocol.UNRELIABLE,
+		Payload:     cipher.Encode(session, joinPayload),
+	})
+	datagram := dp.Encode()
+	datagram[0] = 0x88
+	if len(datagram) != 84 {
+		t.Fatalf("Test datagram is %d bytes, want 84", len(datagram))
+	}
+	srv.raknet.HandlePacket(datagram, addr)
+	
+	// The decoded join request starts the connect flow; InitGame waits in the queue in the clear
+	rpcs := queuedRPCs(session)
+	if len(rpcs) != 1 || rpcs[0][0] != protocol.RPC_InitGame {
+		t.Fatalf("Expected the join request to queue InitGame, got %d RPCs", len(rpcs))
+	}
+	
+	// Outbound: it is enciphered on the wire and the client decodes it back
+	session.Update(srv.conn)
+	wire := readDataPayloads(t, client)[0]
+	if wire[0] == protocol.ID_RPC {
+		t.Error("Expected the InitGame payload to be obfuscated on the wire")
+	}
+	payload := cipher.Decode(session, wire)
+	if payload[0] != protocol.ID_RPC || payload[1] != protocol.RPC_InitGame {
+		t.Errorf("Expected decoded InitGame RPC, got %02X", payload[:2])
+	}
+}
+
+func TestCipherCoversDirectQueueSendersAndResends(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	cipher := xorCipher{key: 0x33}
+	player, client := addClientPlayer(t, srv, 4)
+	srv.raknet.SetCipher(cipher) // existing sessions pick it up too
+	session := player.Session
+	session.RetransmitTimeout = time.Millisecond
+	
+	// sendConnectedPing queues straight onto the session, bypassing SendPacket
+	srv.raknet.sendConnectedPing(session, time.Now().Add(time.Hour))
+	session.Update(srv.conn)
+	wire := readDataPayloads(t, client)[0]
+	if wire[0] == protocol.ID_CONNECTED_PING {
+		t.Fatal("Expected the ping to be obfuscated on the wire")
+	}
+	if got := cipher.Decode(session, wire); got[0] != protocol.ID_CONNECTED_PING {
+		t.Errorf("Expected decoded ID_CONNECTED_PING, got %02X", got[0])
+	}
+	
+	// A timer resend of a reliable packet goes through the same encode; the
+	// unreliable ping is not kept for resends
+	session.AddToQueue(&protocol.EncapsulatedPacket{
+		Reliability: protocol.RELIABLE,
+		Payload:     []byte{protocol.ID_CONNECTED_PING, 0x01},
+	})
+	session.Update(srv.conn)
+	wire = readDataPayloads(t, client)[0]
+	time.Sleep(5 * time.Millisecond)
+	session.Update(srv.conn)
+	resent := readDataPayloads(t, client)[0]
+	if !bytes.Equal(resent, wire) {
+		t.Errorf("Expected the resend to carry the same obfuscated payload, got %02X", resent)
+	}
+	
+	// The queued copy kept for resends stays plain
+	session.Mu.RLock()
+	for _, dp := range session.RecoveryQueue {
+		if dp.Packets[0].Payload[0] != protocol.ID_CONNECTED_PING {
+			t.Error("Expected RecoveryQueue to hold the plain payload")
+		}
+	}
+	session.Mu.RUnlock()
+}
+
+func TestCipherCoversDirectDatagrams(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	cipher := xorCipher{key: 0x77}
+	srv.raknet.SetCipher(cipher)
+	player, client := addClientPlayer(t, srv, 5)
+	
+	// Streaming packets skip the send queue and build their own datagrams
+	srv.raknet.sendRakNetDatagram(player.Session, []byte{protocol.ID_RPC, protocol.RPC_InitGame, 0x01})
+	wire := readDataPayloads(t, client)[0]
+	if wire[0] == protocol.ID_RPC {
+		t.Fatal("Expected the direct datagram to be obfuscated on the wire")
+	}
+	if got := cipher.Decode(player.Session, wire); !bytes.Equal(got, []byte{protocol.ID_RPC, protocol.RPC_InitGame, 0x01}) {
+		t.Errorf("Expected the direct payload to decode back, got %02X", got)
+	}
+}
+
+func TestCipherAppliedToEveryInboundDatagram(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	cipher := xorCipher{key: 0x21}
+	srv.raknet.SetCipher(cipher)
+	
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open client socket: %v", err)
+	}
+	defer client.Close()
+	addr := client.LocalAddr().(*net.UDPAddr)
+	session := addTestSession(srv, addr.Port, protocol.STATE_CONNECTING)
+	
+	// An obfuscated connection request in an ordinary datagram
+	request := append([]byte{protocol.ID_CONNECTION_REQUEST}, connectionRequest(0xABCD, "")...)
+	srv.raknet.HandlePacket(clientDatagram(0, &protocol.EncapsulatedPacket{
+		Reliability: protocol.RELIABLE_ORDERED,
+		Payload:     cipher.Encode(session, request),
+	}), addr)
+	
+	session.Update(srv.conn)
+	wire := readDataPayloads(t, client)[0]
+	if wire[0] == protocol.ID_CONNECTION_REQUEST_ACCEPTED {
+		t.Error("Expected the reply to be obfuscated on the wire")
+	}
+	if reply := cipher.Decode(session, wire); reply[0] != protocol.ID_CONNECTION_REQUEST_ACCEPTED {
+		t.Errorf("Expected a decoded ID_CONNECTION_REQUEST_ACCEPTED, got %02X", reply[0])
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"samp-server-go/source/protocol"
+)
+
+// DefaultSupportedVersions are the client builds whose InitGame layout we send
+var DefaultSupportedVersions = []string{"0.3.7", "0.3.7-R2", "0.3.7-R3", "0.3.7-R4", "0.3.7-R5"}
+
+// joinRequestVersion extracts the client version string that follows the
+// nickname in the SA-MP join request: [?][nameLen][name][verLen][version].
+// Older clients omit it, in which case "" is returned.
+func joinRequestVersion(payload []byte) string {
+	if len(payload) < 2 {
+		return ""
+	}
+	offset := 2 + int(payload[1])
+	if len(payload) <= offset {
+		return ""
+	}
+	verLen := int(payload[offset])
+	if len(payload) < offset+1+verLen {
+		return ""
+	}
+	return string(payload[offset+1 : offset+1+verLen])
+}
+
+// isSupportedVersion reports whether a client version may join. An empty
+// SupportedVersions list or a client that did not send its version is allowed.
+func (s *Server) isSupportedVersion(version string) bool {
+	if version == "" {
+		return true
+	}
+	
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	if len(s.SupportedVersions) == 0 {
+		return true
+	}
+	for _, supported := range s.SupportedVersions {
+		if version == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// rejectClientVersion tells the client why it cannot play and disconnects it.
+// It runs once the game-entry sequence is done, since a client drops chat
+// lines that arrive before it is in game; the reason is flushed ahead of the
+// disconnection notification.
+func (rh *RakNetHandler) rejectClientVersion(session *protocol.Session) {
+	reason := fmt.Sprintf("Unsupported client version %s", session.ClientVersion)
+	log.Printf("🚫 Rejected %s: %s", session.Addr.String(), reason)
+	playerID := -1
+	if player, ok := rh.server.playerForSession(session); ok {
+		playerID = int(player.ID)
+	}
+	rh.server.audit(AuditRejected, session.Addr, playerID, session.Nickname, reason)
+	
+	rh.server.sendServerMessage(session, fmt.Sprintf("%s. Supported: %v", reason, rh.server.SupportedVersions))
+	rh.DisconnectSession(session, DisconnectKicked, reason)
+}
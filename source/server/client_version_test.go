@@ -0,0 +1,94 @@
+package server
+
+import (
+	"samp-server-go/source/protocol"
+	"strings"
+	"testing"
+	"time"
+)
+
+// joinRequest builds a 0x06 join request packet with an optional client version
+func joinRequest(nickname, version string) *protocol.RakNetPacket {
+	payload := []byte{0x00, byte(len(nickname))}
+	payload = append(payload, nickname...)
+	if version != "" {
+		payload = append(payload, byte(len(version)))
+		payload = append(payload, version...)
+	}
+	return &protocol.RakNetPacket{PacketID: 0x06, Payload: payload}
+}
+
+func TestJoinRequestVersion(t *testing.T) {
+	if v := joinRequestVersion(joinRequest("Tester", "0.3.DL-R1").Payload); v != "0.3.DL-R1" {
+		t.Errorf("Expected 0.3.DL-R1, got %q", v)
+	}
+	if v := joinRequestVersion(joinRequest("Tester", "").Payload); v != "" {
+		t.Errorf("Expected no version, got %q", v)
+	}
+	if v := joinRequestVersion([]byte{0x00, 0x02, 'a', 'b', 0x09, '0'}); v != "" {
+		t.Errorf("Expected truncated version to be ignored, got %q", v)
+	}
+}
+
+func TestUnsupportedClientVersionRejectedAfterGameEntry(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	player, client := addClientPlayer(t, srv, 1)
+	session := player.Session
+	session.SetState(protocol.STATE_CONNECTED)
+	
+	srv.raknet.handleInternalPacket(session, joinRequest("Tester", "0.3.DL-R1"))
+	
+	// The reason is not sent at join time, where the client would drop it
+	if ids := queuedPacketIDs(session); len(ids) != 1 || ids[0] != 0x14 {
+		t.Fatalf("Expected connection accepted (0x14) only, got %02X", ids)
+	}
+	if session.ClientVersion != "0.3.DL-R1" {
+		t.Errorf("Expected client version 0.3.DL-R1, got %q", session.ClientVersion)
+	}
+	
+	// The client confirms the last game-entry step
+	steps := srv.raknet.connectSteps()
+	session.Mu.Lock()
+	session.SendQueue = nil
+	session.ConnectPhase = protocol.PhaseSpawning
+	session.SpawnStep = len(steps) - 1
+	session.Mu.Unlock()
+	srv.raknet.advanceConnectFlow(session, steps, len(steps)-1)
+	
+	buf := make([]byte, protocol.MAX_MTU_SIZE)
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := client.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("Expected a datagram to the client, got %v", err)
+	}
+	dp, err := protocol.DecodeDataPacket(buf[:n])
+	if err != nil || len(dp.Packets) != 2 {
+		t.Fatalf("Expected the reason and the notification in one datagram, got %v (%v)", dp, err)
+	}
+	reason := dp.Packets[0].Payload
+	if reason[0] != protocol.ID_RPC || reason[1] != protocol.RPC_ClientMessage {
+		t.Fatalf("Expected a client message first, got %02X", reason)
+	}
+	if !strings.Contains(string(reason), "Unsupported client version 0.3.DL-R1") {
+		t.Errorf("Client message does not contain the reason: %q", reason)
+	}
+	if dp.Packets[1].Payload[0] != protocol.ID_DISCONNECTION_NOTIFICATION {
+		t.Errorf("Expected disconnection after the reason, got 0x%02X", dp.Packets[1].Payload[0])
+	}
+}
+
+func TestSupportedClientVersionProceeds(t *testing.T) {
+	srv := newTestServer()
+	srv.SupportedVersions = []string{"0.3.7-R2"}
+	session := addTestSession(srv, 50001, protocol.STATE_CONNECTED)
+	
+	srv.raknet.handleInternalPacket(session, joinRequest("Tester", "0.3.7-R2"))
+	
+	ids := queuedPacketIDs(session)
+	if len(ids) != 1 || ids[0] != 0x14 {
+		t.Errorf("Expected connection accepted (0x14), got %02X", ids)
+	}
+	if session.Nickname != "Tester" {
+		t.Errorf("Expected nickname Tester, got %q", session.Nickname)
+	}
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"log"
+	"samp-server-go/pkg/logger"
+	"samp-server-go/source/protocol"
+)
+
+// connectStep is one RPC of the post-connect spawn flow
+type connectStep struct {
+	name  string
+	build func() []byte
+}
+
+// connectSteps returns the SA-MP 0.3.7 spawn flow in the order the client expects.
+// Payloads are built when the step is sent so they reflect the current world config.
+func (rh *RakNetHandler) connectSteps() []connectStep {
+	return []connectStep{
+		{"InitGame", rh.buildInitGameRPC}, // CRITICAL: Must be sent FIRST
+		{"SetGameModeText", func() []byte { return protocol.BuildSetGameModeTextRPC(rh.server.configSnapshot().GameMode) }},
+		{"SetWorldTime", func() []byte { return protocol.BuildSetWorldTimeRPC(uint8(rh.server.configSnapshot().WorldTime)) }},
+		{"SetWeather", func() []byte { return protocol.BuildSetWeatherRPC(uint8(rh.server.configSnapshot().Weather)) }},
+		{"SetGravity", func() []byte { return protocol.BuildSetGravityRPC(rh.server.configSnapshot().Gravity) }},
+		{"SetSpawnInfo", func() []byte {
+			return protocol.BuildSetSpawnInfoRPC(
+				0,        // team
+				0,        // skin (CJ)
+				1958.0,   // X
+				1343.0,   // Y
+				15.0,     // Z
+				270.0,    // rotation
+				24, 200,  // weapon 1: Desert Eagle + 200 ammo
+				31, 300,  // weapon 2: M4 + 300 ammo
+				34, 50,   // weapon 3: Sniper Rifle + 50 ammo
+			)
+		}},
+		{"SpawnPlayer", protocol.BuildSpawnPlayerRPC},
+		{"TogglePlayerControllable", func() []byte { return protocol.BuildTogglePlayerControllableRPC(true) }},
+	}
+}
+
+// buildInitGameRPC builds InitGame from the server config
+func (rh *RakNetHandler) buildInitGameRPC() []byte {
+	minX, minY, maxX, maxY := rh.server.GetWorldBounds()
+	
+	rh.server.mu.RLock()
+	cfg := rh.server.currentConfig()
+	showNameTags := rh.server.ShowNameTags
+	markers := rh.server.PlayerMarkers
+	rh.server.mu.RUnlock()
+	
+	return protocol.BuildInitGameRPCFromParams(protocol.InitGameParams{
+		ZoneNames:           true,
+		AllowWeapons:        true,
+		LanMode:             true,
+		WorldTimeHour:       uint8(cfg.WorldTime),
+		Weather:             uint8(cfg.Weather),
+		Gravity:             cfg.Gravity,
+		HideNameTags:        !showNameTags,
+		NameTagDrawDistance: rh.server.NameTagDrawDistance,
+		NameTagsIgnoreLOS:   rh.server.NameTagsIgnoreLOS,
+		PlayerMarkers:       uint32(markers),
+		HidePlayerMarkers:   markers == protocol.PlayerMarkersOff,
+		Hostname:            cfg.ServerName,
+		WorldBoundsMinX:     minX,
+		WorldBoundsMinY:     minY,
+		WorldBoundsMaxX:     maxX,
+		WorldBoundsMaxY:     maxY,
+		GamemodeText:        cfg.GameMode,
+		MapName:             cfg.MapName,
+	})
+}
+
+// startConnectFlow sends the first spawn step. Later steps are sent from the
+// ACK callback of the previous one, so a step never goes out before the client
+// confirmed the one before it. Calling it again while a flow is running is a no-op.
+func (rh *RakNetHandler) startConnectFlow(session *protocol.Session) {
+	session.Mu.Lock()
+	if session.ConnectPhase >= protocol.PhaseSpawning {
+		session.Mu.Unlock()
+		log.Printf("⏩ Spawn flow already running for %s (step %d)", session.Addr, session.SpawnStep)
+		return
+	}
+	session.ConnectPhase = protocol.PhaseSpawning
+	session.SpawnStep = 0
+	session.Mu.Unlock()
+	
+	log.Printf("🎮 Starting spawn flow for %s", session.Addr)
+	rh.sendConnectStep(session, rh.connectSteps(), 0)
+}
+
+// sendConnectStep queues step i and arranges for the next one to follow its ACK
+func (rh *RakNetHandler) sendConnectStep(session *protocol.Session, steps []connectStep, i int) {
+	step := steps[i]
+	data := protocol.EncodeRPCPacket(step.build())
+	packet := &protocol.RakNetPacket{
+		PacketID: data[0],
+		Payload:  data[1:],
+	}
+	
+	rh.SendPacketWithAck(session, packet, protocol.RELIABLE_ORDERED, func() {
+		rh.advanceConnectFlow(session, steps, i)
+	})
+	log.Printf("📤 Spawn step %d/%d: %s (%d bytes)", i+1, len(steps), step.name, len(data))
+	
+	switch step.name {
+	case "InitGame":
+		rh.markMilestone(session, protocol.MilestoneInitGame)
+	case "SpawnPlayer":
+		rh.markMilestone(session, protocol.MilestoneFirstSpawn)
+	}
+}
+
+// advanceConnectFlow runs when step i is ACKed
+func (rh *RakNetHandler) advanceConnectFlow(session *protocol.Session, steps []connectStep, i int) {
+	session.Mu.Lock()
+	if session.ConnectPhase != protocol.PhaseSpawning || session.SpawnStep != i {
+		// Flow was reset (e.g. StopStreaming) or this step was already confirmed
+		session.Mu.Unlock()
+		return
+	}
+	
+	if i+1 >= len(steps) {
+		session.ConnectPhase = protocol.PhaseSpawned
+		session.Mu.Unlock()
+		log.Printf("✅ Spawn flow complete for %s", session.Addr)
+		
+		// The client only shows chat once it is in game, so an unsupported
+		// version is told why it cannot stay here rather than at join time
+		if rh.server != nil && !rh.server.isSupportedVersion(session.ClientVersion) {
+			rh.rejectClientVersion(session)
+		}
+		return
+	}
+	session.SpawnStep = i + 1
+	session.Mu.Unlock()
+	
+	rh.sendConnectStep(session, steps, i+1)
+}
+
+// markMilestone records a connection milestone and logs the whole timeline
+// (at debug level) once the client reaches its first spawn
+func (rh *RakNetHandler) markMilestone(session *protocol.Session, m protocol.Milestone) {
+	if session == nil || !session.MarkMilestone(m) {
+		return
+	}
+	if m == protocol.MilestoneFirstSpawn {
+		logger.Debug("Connection timeline for %s: %s", session.Addr, protocol.FormatTimeline(session.ConnectionTimeline()))
+	}
+}
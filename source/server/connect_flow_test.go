@@ -0,0 +1,145 @@
+package server
+
+import (
+	"samp-server-go/source/protocol"
+	"testing"
+	"time"
+)
+
+// flushDatagram sends the session's queue and returns the sequence number and RPC ids of the datagram
+func flushDatagram(t *testing.T, srv *Server, session *protocol.Session) (uint32, []byte) {
+	t.Helper()
+	
+	rpcs := queuedRPCs(session)
+	ids := make([]byte, 0, len(rpcs))
+	for _, rpc := range rpcs {
+		ids = append(ids, rpc[0])
+	}
+	
+	session.Mu.RLock()
+	seq := session.SequenceNumber
+	session.Mu.RUnlock()
+	
+	session.Update(srv.conn)
+	return seq, ids
+}
+
+func TestConnectFlowAdvancesOnlyAfterACK(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	session := addTestSession(srv, 50001, protocol.STATE_IN_GAME)
+	
+	srv.raknet.startConnectFlow(session)
+	
+	expected := []byte{
+		protocol.RPC_InitGame,
+		protocol.RPC_SetGameModeText,
+		protocol.RPC_SetWorldTime,
+		protocol.RPC_SetWeather,
+		protocol.RPC_SetGravity,
+		protocol.RPC_SetSpawnInfo,
+		protocol.RPC_SpawnPlayer,
+		protocol.RPC_TogglePlayerControllable,
+	}
+	
+	for i, want := range expected {
+		seq, ids := flushDatagram(t, srv, session)
+		if len(ids) != 1 || ids[0] != want {
+			t.Fatalf("Step %d: expected only RPC 0x%02X, got %02X", i+1, want, ids)
+		}
+		
+		// Nothing else goes out until the client confirms this step
+		if _, ids := flushDatagram(t, srv, session); len(ids) != 0 {
+			t.Fatalf("Step %d: next step sent before ACK: %02X", i+1, ids)
+		}
+		
+		session.AcknowledgeRange(seq, seq)
+	}
+	
+	if phase := session.GetConnectPhase(); phase != protocol.PhaseSpawned {
+		t.Errorf("Expected flow to be complete, got phase %d", phase)
+	}
+	if _, ids := flushDatagram(t, srv, session); len(ids) != 0 {
+		t.Errorf("Expected nothing after the last step, got %02X", ids)
+	}
+}
+
+func TestConnectFlowStartsOnce(t *testing.T) {
+	srv := newTestServer()
+	session := addTestSession(srv, 50001, protocol.STATE_IN_GAME)
+	
+	srv.raknet.startConnectFlow(session)
+	srv.raknet.startConnectFlow(session)
+	
+	if rpcs := queuedRPCs(session); len(rpcs) != 1 {
+		t.Errorf("Expected a single InitGame, got %d RPCs", len(rpcs))
+	}
+}
+
+func TestConnectFlowSendsConfiguredGravity(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	srv.Gravity = 0.004
+	session := addTestSession(srv, 50001, protocol.STATE_IN_GAME)
+	
+	srv.raknet.startConnectFlow(session)
+	for {
+		rpcs := queuedRPCs(session)
+		if len(rpcs) == 0 {
+			t.Fatal("Expected SetGravity in the spawn flow")
+		}
+		if rpcs[0][0] == protocol.RPC_SetGravity {
+			if expected := protocol.BuildSetGravityRPC(0.004); string(rpcs[0]) != string(expected) {
+				t.Errorf("Expected gravity payload %02X, got %02X", expected, rpcs[0])
+			}
+			return
+		}
+		seq, _ := flushDatagram(t, srv, session)
+		session.AcknowledgeRange(seq, seq)
+	}
+}
+
+func TestConnectionTimelineRecordsMilestones(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	clock := protocol.NewFakeClock(time.Unix(1700000000, 0))
+	srv.raknet.SetClock(clock)
+	session := addTestSession(srv, 50001, protocol.STATE_HANDSHAKE_SENT)
+	
+	clock.Advance(10 * time.Millisecond)
+	srv.raknet.send0x1A(session.Addr, session)
+	
+	clock.Advance(20 * time.Millisecond)
+	srv.raknet.handleConnectionRequest(session, &protocol.RakNetPacket{PacketID: protocol.ID_CONNECTION_REQUEST, Payload: make([]byte, 17)})
+	
+	clock.Advance(30 * time.Millisecond)
+	srv.handleAuthKey(session, &protocol.RakNetPacket{PacketID: 0x25})
+	
+	clock.Advance(40 * time.Millisecond)
+	srv.raknet.startConnectFlow(session)
+	
+	// A retransmitted handshake reply must not move the milestone
+	srv.raknet.send0x1A(session.Addr, session)
+	
+	clock.Advance(50 * time.Millisecond)
+	srv.raknet.sendPlayerSpawn(session)
+	
+	expected := []struct {
+		milestone protocol.Milestone
+		delta     time.Duration
+	}{
+		{protocol.MilestoneHandshakeReply, 10 * time.Millisecond},
+		{protocol.MilestoneConnectionRequest, 20 * time.Millisecond},
+		{protocol.MilestoneAuth, 30 * time.Millisecond},
+		{protocol.MilestoneInitGame, 40 * time.Millisecond},
+		{protocol.MilestoneFirstSpawn, 50 * time.Millisecond},
+	}
+	
+	timeline := session.ConnectionTimeline()
+	if len(timeline) != len(expected) {
+		t.Fatalf("Expected %d milestones, got %s", len(expected), protocol.FormatTimeline(timeline))
+	}
+	for i, step := range timeline {
+		if step.Milestone != expected[i].milestone || step.Delta != expected[i].delta {
+			t.Errorf("Step %d: expected %s +%s, got %s +%s",
+				i, expected[i].milestone, expected[i].delta, step.Milestone, step.Delta)
+		}
+	}
+}
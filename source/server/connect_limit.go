@@ -0,0 +1,155 @@
+package server
+
+import (
+	"log"
+	"math"
+	"net"
+	"samp-server-go/source/protocol"
+	"sync"
+	"time"
+)
+
+// Defaults for limiting connection attempts. A real client needs one or two
+// packets before it has a session, so 5 per 10 seconds allows a few retries.
+const (
+	DefaultConnectRateLimit    = 5
+	DefaultConnectRateWindow   = 10 * time.Second
+	DefaultMaxHalfOpenSessions = 256
+	DefaultHandshakeTimeout    = 10 * time.Second
+)
+
+// connectLimiter is a token bucket per source IP for packets that may open a
+// session. Each bucket holds ConnectRateLimit tokens and refills at
+// ConnectRateLimit per ConnectRateWindow.
+type connectLimiter struct {
+	mu      sync.Mutex
+	sources map[string]*connectBucket // key: IP only, a flood can vary the port
+	swept   time.Time                 // last time a full sources table was swept
+}
+
+type connectBucket struct {
+	tokens  float64
+	updated time.Time
+	dropped int // attempts dropped since the bucket last had a token
+}
+
+// allowConnectAttempt reports whether a packet from addr, which has no
+// session yet, may be handled. Over-limit packets are dropped silently; the
+// first drop in a run is logged.
+func (rh *RakNetHandler) allowConnectAttempt(addr *net.UDPAddr) bool {
+	if !rh.withinConnectRate(addr) {
+		return false
+	}
+	
+	if max := rh.server.MaxHalfOpenSessions; max > 0 && rh.halfOpen.Load() >= int64(max) {
+		log.Printf("🛡️ %d half-open sessions, dropping connection attempt from %s", max, addr)
+		return false
+	}
+	return true
+}
+
+func (rh *RakNetHandler) withinConnectRate(addr *net.UDPAddr) bool {
+	limit, window := rh.server.ConnectRateLimit, rh.server.ConnectRateWindow
+	if limit <= 0 || window <= 0 {
+		return true
+	}
+	
+	now := rh.clock.Now()
+	ip := addr.IP.String()
+	
+	rh.connectLimiter.mu.Lock()
+	defer rh.connectLimiter.mu.Unlock()
+	
+	if rh.connectLimiter.sources == nil {
+		rh.connectLimiter.sources = make(map[string]*connectBucket)
+	}
+	bucket, exists := rh.connectLimiter.sources[ip]
+	if !exists {
+		if !rh.roomForConnectSource(now, window) {
+			return false
+		}
+		bucket = &connectBucket{tokens: float64(limit), updated: now}
+		rh.connectLimiter.sources[ip] = bucket
+	}
+	
+	refill := now.Sub(bucket.updated).Seconds() * float64(limit) / window.Seconds()
+	bucket.tokens = math.Min(bucket.tokens+refill, float64(limit))
+	bucket.updated = now
+	
+	if bucket.tokens >= 1 {
+		if bucket.dropped > 0 {
+			log.Printf("🛡️ Connection flood from %s: dropped %d attempts over the limit of %d per %s", ip, bucket.dropped, limit, window)
+			bucket.dropped = 0
+		}
+		bucket.tokens--
+		return true
+	}
+	
+	bucket.dropped++
+	if bucket.dropped == 1 {
+		log.Printf("🛡️ Suspected connection flood from %s: over %d attempts per %s, dropping", ip, limit, window)
+	}
+	return false
+}
+
+// roomForConnectSource reports whether another IP can be tracked. A full
+// table is swept of buckets that have refilled at most once per window; if it
+// is still full the attempt is dropped. Caller holds connectLimiter.mu.
+func (rh *RakNetHandler) roomForConnectSource(now time.Time, window time.Duration) bool {
+	max := rh.server.MaxLimiterSources
+	if max <= 0 || len(rh.connectLimiter.sources) < max {
+		return true
+	}
+	if now.Sub(rh.connectLimiter.swept) >= window {
+		rh.connectLimiter.swept = now
+		for ip, bucket := range rh.connectLimiter.sources {
+			if now.Sub(bucket.updated) >= window {
+				delete(rh.connectLimiter.sources, ip)
+			}
+		}
+	}
+	if len(rh.connectLimiter.sources) < max {
+		return true
+	}
+	log.Printf("🛡️ Tracking %d connecting IPs, dropping attempt from a new one", max)
+	return false
+}
+
+// isStrayDatagram reports whether a packet from an address without a session
+// is a data or ACK datagram (0x80-0xC0) that cannot open one. These are mostly
+// stragglers from a session that just closed and must not spend the IP's
+// connect tokens. Only the 4-byte connection response opens a session.
+func isStrayDatagram(data []byte) bool {
+	return data[0] >= 0x80 && data[0] <= 0xC0 && len(data) != 4
+}
+
+// recountHalfOpen resets the half-open count from the sessions table. The
+// count follows state transitions (see Session.SetState); this corrects drift
+// from sessions replaced in the table without being released.
+func (rh *RakNetHandler) recountHalfOpen() {
+	rh.mu.RLock()
+	defer rh.mu.RUnlock()
+	
+	count := 0
+	for _, session := range rh.sessions {
+		session.Mu.RLock()
+		if session.State < protocol.STATE_CONNECTED {
+			count++
+		}
+		session.Mu.RUnlock()
+	}
+	rh.halfOpen.Store(int64(count))
+}
+
+// pruneConnectLimiter forgets sources not seen since cutoff; their buckets
+// would have refilled anyway
+func (rh *RakNetHandler) pruneConnectLimiter(cutoff time.Time) {
+	rh.connectLimiter.mu.Lock()
+	defer rh.connectLimiter.mu.Unlock()
+	
+	for ip, bucket := range rh.connectLimiter.sources {
+		if bucket.updated.Before(cutoff) {
+			delete(rh.connectLimiter.sources, ip)
+		}
+	}
+}
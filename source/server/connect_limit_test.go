@@ -0,0 +1,125 @@
+package server
+
+import (
+	"net"
+	"samp-server-go/source/protocol"
+	"testing"
+	"time"
+)
+
+// cookieRequestFrom sends a 0x08 cookie request, which opens a session, from port
+func cookieRequestFrom(srv *Server, port int) {
+	srv.raknet.HandlePacket([]byte{0x08, 0x01, 0x02, 0x03}, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+}
+
+func TestConnectFloodLimitedPerIP(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	clock := protocol.NewFakeClock(time.Unix(1700000000, 0))
+	srv.raknet.SetClock(clock)
+	
+	for i := 0; i < 20; i++ {
+		cookieRequestFrom(srv, 40000+i)
+	}
+	if n := len(srv.raknet.GetSessions()); n != DefaultConnectRateLimit {
+		t.Fatalf("Expected %d sessions from 20 attempts, got %d", DefaultConnectRateLimit, n)
+	}
+	
+	// The bucket refills at ConnectRateLimit per ConnectRateWindow
+	clock.Advance(DefaultConnectRateWindow / DefaultConnectRateLimit)
+	cookieRequestFrom(srv, 40100)
+	cookieRequestFrom(srv, 40101)
+	if n := len(srv.raknet.GetSessions()); n != DefaultConnectRateLimit+1 {
+		t.Errorf("Expected one more session after a refill, got %d", n)
+	}
+	
+	// Packets on an existing session are not attempts
+	cookieRequestFrom(srv, 40000)
+	if n := len(srv.raknet.GetSessions()); n != DefaultConnectRateLimit+1 {
+		t.Errorf("Expected no new session for a known address, got %d", n)
+	}
+}
+
+func TestHalfOpenSessionsCapped(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	srv.ConnectRateLimit = 0
+	srv.MaxHalfOpenSessions = 3
+	
+	for i := 0; i < 5; i++ {
+		cookieRequestFrom(srv, 41000+i)
+	}
+	if n := len(srv.raknet.GetSessions()); n != 3 {
+		t.Fatalf("Expected 3 half-open sessions, got %d", n)
+	}
+	
+	// Finished handshakes don't count towards the cap
+	for _, session := range srv.raknet.GetSessions() {
+		session.SetState(protocol.STATE_CONNECTED)
+	}
+	cookieRequestFrom(srv, 41010)
+	if n := len(srv.raknet.GetSessions()); n != 4 {
+		t.Errorf("Expected a new attempt accepted once handshakes completed, got %d sessions", n)
+	}
+}
+
+func TestConnectLimiterTracksBoundedSources(t *testing.T) {
+	srv := newTestServer()
+	clock := protocol.NewFakeClock(time.Unix(1700000000, 0))
+	srv.raknet.SetClock(clock)
+	srv.MaxLimiterSources = 4
+	
+	for i := 0; i < 10; i++ {
+		srv.raknet.withinConnectRate(&net.UDPAddr{IP: net.IPv4(203, 0, 113, byte(i)), Port: 40000})
+	}
+	if n := len(srv.raknet.connectLimiter.sources); n != 4 {
+		t.Fatalf("Expected 4 tracked sources, got %d", n)
+	}
+	
+	// Buckets that have refilled are forgotten to make room
+	clock.Advance(DefaultConnectRateWindow)
+	if !srv.raknet.withinConnectRate(&net.UDPAddr{IP: net.IPv4(198, 51, 100, 2), Port: 40000}) {
+		t.Error("Expected a new source allowed once old buckets refilled")
+	}
+}
+
+func TestHalfOpenCountFollowsSessions(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	srv.ConnectRateLimit = 0
+	
+	for i := 0; i < 3; i++ {
+		cookieRequestFrom(srv, 42000+i)
+	}
+	if n := srv.raknet.halfOpen.Load(); n != 3 {
+		t.Fatalf("Expected 3 half-open sessions counted, got %d", n)
+	}
+	
+	sessions := srv.raknet.GetSessions()
+	sessions[0].SetState(protocol.STATE_CONNECTED)
+	srv.raknet.closeSession(sessions[1], DisconnectKicked, "")
+	if n := srv.raknet.halfOpen.Load(); n != 1 {
+		t.Errorf("Expected 1 half-open session after a handshake and a close, got %d", n)
+	}
+	
+	// A closed session moving state again is not counted back in
+	sessions[1].SetState(protocol.STATE_UNCONNECTED)
+	if n := srv.raknet.halfOpen.Load(); n != 1 {
+		t.Errorf("Expected a released session to stay uncounted, got %d", n)
+	}
+}
+
+func TestStrayDatagramsDontSpendConnectTokens(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	clock := protocol.NewFakeClock(time.Unix(1700000000, 0))
+	srv.raknet.SetClock(clock)
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 43000}
+	
+	// Trailing data and ACK datagrams from a session that just closed
+	for i := 0; i < 20; i++ {
+		srv.raknet.HandlePacket([]byte{0x84, byte(i), 0, 0, 0x00, 0x00, 0x08, 0x00}, addr)
+		srv.raknet.HandlePacket([]byte{0xC0, 0x00, 0x01, 0x01, byte(i), 0, 0}, addr)
+	}
+	
+	cookieRequestFrom(srv, 43000)
+	if n := len(srv.raknet.GetSessions()); n != 1 {
+		t.Errorf("Expected the reconnect to open a session, got %d sessions", n)
+	}
+}
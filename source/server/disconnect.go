@@ -0,0 +1,223 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"samp-server-go/source/protocol"
+	"sync"
+	"time"
+)
+
+// DefaultDisconnectAckTimeout is how long a disconnected session is kept for
+// the client to ACK its disconnection notification
+const DefaultDisconnectAckTimeout = 500 * time.Millisecond
+
+// DisconnectReason says why a player left the server
+type DisconnectReason int
+
+// Disconnect reasons; the first three match SA-MP's OnPlayerDisconnect values
+const (
+	DisconnectTimeout DisconnectReason = iota
+	DisconnectQuit
+	DisconnectKicked
+	DisconnectBanned
+	DisconnectShutdown
+)
+
+var disconnectReasonNames = [...]string{
+	DisconnectTimeout:  "timeout",
+	DisconnectQuit:     "quit",
+	DisconnectKicked:   "kicked",
+	DisconnectBanned:   "banned",
+	DisconnectShutdown: "shutdown",
+}
+
+func (r DisconnectReason) String() string {
+	if r < 0 || int(r) >= len(disconnectReasonNames) {
+		return fmt.Sprintf("DisconnectReason(%d)", int(r))
+	}
+	return disconnectReasonNames[r]
+}
+
+// SetPlayerDisconnectHandler sets the callback run when a player leaves.
+// detail is optional context, e.g. why a player was kicked.
+func (s *Server) SetPlayerDisconnectHandler(handler func(player *Player, reason DisconnectReason, detail string)) {
+	s.onPlayerDisconnect = handler
+}
+
+// KickPlayer disconnects a player with DisconnectKicked
+func (s *Server) KickPlayer(playerID uint16, detail string) error {
+	return s.DisconnectPlayer(playerID, DisconnectKicked, detail)
+}
+
+// DisconnectPlayer closes a player's connection, telling their client, and
+// reports reason to the disconnect handler and audit log
+func (s *Server) DisconnectPlayer(playerID uint16, reason DisconnectReason, detail string) error {
+	player, exists := s.GetPlayer(playerID)
+	if !exists {
+		return fmt.Errorf("player %d not found", playerID)
+	}
+	
+	log.Printf("👢 Player %d disconnected (%s) %s", playerID, reason, detail)
+	if session := player.Session; session != nil && s.raknet != nil {
+		s.raknet.DisconnectSession(session, reason, detail)
+		return nil
+	}
+	s.disconnectSession(player.Session, reason, detail)
+	return nil
+}
+
+// DisconnectSession closes a connection gracefully so the client sees
+// "Server closed the connection" instead of timing out. Queued packets are
+// flushed ahead of ID_DISCONNECTION_NOTIFICATION; the session is dropped, and
+// its player disconnected with reason, by the update loop once the client
+// ACKs it or DisconnectAckTimeout passes. It does not block, so kicks from a
+// packet worker don't stall the other clients on that worker.
+func (rh *RakNetHandler) DisconnectSession(session *protocol.Session, reason DisconnectReason, detail string) {
+	rh.mu.Lock()
+	_, pending := rh.pendingDisconnects[session]
+	rh.mu.Unlock()
+	if pending {
+		return
+	}
+	
+	acked := rh.notifyDisconnect(session)
+	timeout := rh.server.DisconnectAckTimeout
+	if acked == nil || timeout <= 0 {
+		rh.closeSession(session, reason, detail)
+		return
+	}
+	
+	rh.mu.Lock()
+	rh.pendingDisconnects[session] = pendingDisconnect{
+		reason:   reason,
+		detail:   detail,
+		acked:    acked,
+		deadline: rh.clock.Now().Add(timeout),
+	}
+	rh.mu.Unlock()
+}
+
+// pendingDisconnect is a session waiting for its disconnection notification
+// to be ACKed (see DisconnectSession)
+type pendingDisconnect struct {
+	reason   DisconnectReason
+	detail   string
+	acked    <-chan struct{}
+	deadline time.Time
+}
+
+// closeFinishedDisconnects closes every pending disconnect whose notification
+// was ACKed or whose deadline has passed. Update calls it every tick.
+func (rh *RakNetHandler) closeFinishedDisconnects(now time.Time) {
+	rh.mu.Lock()
+	finished := make(map[*protocol.Session]pendingDisconnect)
+	for session, pending := range rh.pendingDisconnects {
+		select {
+		case <-pending.acked:
+		default:
+			if now.Before(pending.deadline) {
+				continue
+			}
+			log.Printf("⚠️ Disconnection notification to %v not ACKed in time", session.Addr)
+		}
+		finished[session] = pending
+		delete(rh.pendingDisconnects, session)
+	}
+	rh.mu.Unlock()
+	
+	for session, pending := range finished {
+		rh.closeSession(session, pending.reason, pending.detail)
+	}
+}
+
+// DisconnectAll gracefully disconnects every session, as DisconnectSession
+// does, sharing one DisconnectAckTimeout between them. Unlike DisconnectSession
+// it blocks until they are closed, for Stop.
+func (rh *RakNetHandler) DisconnectAll(reason DisconnectReason, detail string) {
+	sessions := rh.GetSessions()
+	acks := make([]<-chan struct{}, 0, len(sessions))
+	for _, session := range sessions {
+		acks = append(acks, rh.notifyDisconnect(session))
+	}
+	rh.awaitDisconnectAcks(acks)
+	
+	// Disconnect handlers run one at a time, like everywhere else
+	for _, session := range sessions {
+		rh.closeSession(session, reason, detail)
+	}
+}
+
+// notifyDisconnect flushes a session's queue with ID_DISCONNECTION_NOTIFICATION
+// last and returns a channel closed once the client ACKs it. Half-open
+// handshakes have nothing to flush and would never ACK, so they get nil.
+func (rh *RakNetHandler) notifyDisconnect(session *protocol.Session) <-chan struct{} {
+	session.Mu.RLock()
+	connected := session.State >= protocol.STATE_CONNECTED
+	session.Mu.RUnlock()
+	if !connected {
+		return nil
+	}
+	
+	acked := make(chan struct{})
+	var once sync.Once
+	session.AddToQueue(&protocol.EncapsulatedPacket{
+		Reliability: protocol.RELIABLE,
+		Payload:     []byte{protocol.ID_DISCONNECTION_NOTIFICATION},
+		OnAck:       func() { once.Do(func() { close(acked) }) },
+	})
+	session.Update(rh.sessionConn(session))
+	return acked
+}
+
+// awaitDisconnectAcks waits until every non-nil channel is closed or
+// DisconnectAckTimeout passes
+func (rh *RakNetHandler) awaitDisconnectAcks(acks []<-chan struct{}) {
+	timeout := rh.server.DisconnectAckTimeout
+	if timeout <= 0 {
+		return
+	}
+	
+	deadline := time.After(timeout)
+	for _, acked := range acks {
+		if acked == nil {
+			continue
+		}
+		select {
+		case <-acked:
+		case <-deadline:
+			log.Printf("⚠️ Disconnection notification not ACKed within %v", timeout)
+			return
+		}
+	}
+}
+
+// closeSession drops a session from the handler and disconnects its player
+func (rh *RakNetHandler) closeSession(session *protocol.Session, reason DisconnectReason, detail string) {
+	session.Mu.Lock()
+	session.SetState(protocol.STATE_UNCONNECTED)
+	session.Mu.Unlock()
+	
+	rh.forgetSession(session)
+	rh.server.disconnectSession(session, reason, detail)
+}
+
+// disconnectSession audits a session going away and removes its player,
+// running the disconnect handler if it had one
+func (s *Server) disconnectSession(session *protocol.Session, reason DisconnectReason, detail string) {
+	if s == nil || session == nil {
+		return
+	}
+	
+	s.auditDisconnect(session, reason, detail)
+	
+	player, exists := s.playerForSession(session)
+	if !exists || !s.RemovePlayer(player.ID) {
+		return
+	}
+	s.announcePlayerQuit(player, reason)
+	if s.onPlayerDisconnect != nil {
+		s.callback("player disconnect", func() { s.onPlayerDisconnect(player, reason, detail) })
+	}
+	s.trigger(EventPlayerDisconnect, player.ID, DisconnectData{Reason: reason, Detail: detail})
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"bytes"
+	"net"
+	"samp-server-go/source/protocol"
+	"testing"
+	"time"
+)
+
+func TestDisconnectReasonString(t *testing.T) {
+	tests := map[DisconnectReason]string{
+		DisconnectTimeout:    "timeout",
+		DisconnectQuit:       "quit",
+		DisconnectKicked:     "kicked",
+		DisconnectBanned:     "banned",
+		DisconnectShutdown:   "shutdown",
+		DisconnectReason(42): "DisconnectReason(42)",
+	}
+	for reason, want := range tests {
+		if got := reason.String(); got != want {
+			t.Errorf("DisconnectReason(%d).String() = %q, want %q", int(reason), got, want)
+		}
+	}
+}
+
+// disconnectRecorder captures disconnect handler calls
+type disconnectRecorder struct {
+	players []uint16
+	reasons []DisconnectReason
+	details []string
+}
+
+func (r *disconnectRecorder) handle(player *Player, reason DisconnectReason, detail string) {
+	r.players = append(r.players, player.ID)
+	r.reasons = append(r.reasons, reason)
+	r.details = append(r.details, detail)
+}
+
+func TestTimeoutSweepReportsTimeoutReason(t *testing.T) {
+	var buf bytes.Buffer
+	srv := newTestServerWithConn(t)
+	srv.AuditLog = NewAuditLog(&buf)
+	clock := protocol.NewFakeClock(time.Unix(1700000000, 0))
+	srv.raknet.SetClock(clock)
+	
+	var recorder disconnectRecorder
+	srv.SetPlayerDisconnectHandler(recorder.handle)
+	addTestPlayer(srv, 4, protocol.STATE_CONNECTED)
+	
+	clock.Advance(sessionTimeout + time.Second)
+	srv.raknet.CleanupStaleSessions()
+	
+	if len(recorder.reasons) != 1 || recorder.players[0] != 4 || recorder.reasons[0] != DisconnectTimeout {
+		t.Fatalf("Expected player 4 to leave with timeout, got players %v reasons %v", recorder.players, recorder.reasons)
+	}
+	if _, exists := srv.GetPlayer(4); exists {
+		t.Errorf("Expected the timed out player to be removed")
+	}
+	
+	entries := readAuditEntries(t, &buf)
+	if len(entries) != 1 || entries[0].Event != AuditDisconnect || entries[0].Reason != "timeout" {
+		t.Errorf("Expected one timeout disconnect audit entry, got %+v", entries)
+	}
+}
+
+func TestKickPlayerReportsReasonAndDetail(t *testing.T) {
+	var buf bytes.Buffer
+	srv := newTestServerWithConn(t)
+	srv.AuditLog = NewAuditLog(&buf)
+	
+	var recorder disconnectRecorder
+	srv.SetPlayerDisconnectHandler(recorder.handle)
+	player := addTestPlayer(srv, 2, protocol.STATE_IN_GAME)
+	clock := protocol.NewFakeClock(time.Now())
+	srv.raknet.SetClock(clock)
+	
+	if err := srv.KickPlayer(2, "spamming"); err != nil {
+		t.Fatalf("KickPlayer failed: %v", err)
+	}
+	srv.raknet.Update()
+	if len(recorder.reasons) != 0 {
+		t.Fatalf("Expected the player kept while the notification is unACKed, got reasons %v", recorder.reasons)
+	}
+	
+	// The test client never ACKs, so the session goes when the timeout passes
+	clock.Advance(srv.DisconnectAckTimeout)
+	srv.raknet.Update()
+	if len(recorder.reasons) != 1 || recorder.reasons[0] != DisconnectKicked || recorder.details[0] != "spamming" {
+		t.Fatalf("Expected a kicked disconnect with detail, got reasons %v details %v", recorder.reasons, recorder.details)
+	}
+	if sessions := srv.raknet.GetSessions(); len(sessions) != 0 || player.Session.State != protocol.STATE_UNCONNECTED {
+		t.Errorf("Expected the kicked player's session to be dropped")
+	}
+	
+	entries := readAuditEntries(t, &buf)
+	if len(entries) != 1 || entries[0].Reason != "kicked" || entries[0].Detail != "spamming" {
+		t.Errorf("Expected a kicked audit entry with detail, got %+v", entries)
+	}
+	
+	if err := srv.KickPlayer(2, ""); err == nil {
+		t.Errorf("Expected kicking an unknown player to fail")
+	}
+}
+
+func TestKickSendsDisconnectionNotification(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open client socket: %v", err)
+	}
+	defer client.Close()
+	
+	player := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	srv.raknet.forgetSession(player.Session)
+	player.Session.Addr = client.LocalAddr().(*net.UDPAddr)
+	player.Addr = player.Session.Addr
+	srv.raknet.sessions[player.Session.Addr.String()] = player.Session
+	
+	var reasons []DisconnectReason
+	srv.Events.Register(EventPlayerDisconnect, func(e Event) {
+		reasons = append(reasons, e.Data.(DisconnectData).Reason)
+	})
+	
+	if err := srv.KickPlayer(1, "afk"); err != nil {
+		t.Fatalf("KickPlayer failed: %v", err)
+	}
+	
+	buf := make([]byte, protocol.MAX_MTU_SIZE)
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := client.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("Expected a datagram to the kicked client, got %v", err)
+	}
+	dp, err := protocol.DecodeDataPacket(buf[:n])
+	if err != nil || len(dp.Packets) != 1 {
+		t.Fatalf("Expected one encapsulated packet, got %v (%v)", dp, err)
+	}
+	if p := dp.Packets[0]; p.Payload[0] != protocol.ID_DISCONNECTION_NOTIFICATION || p.Reliability != protocol.RELIABLE {
+		t.Errorf("Expected a RELIABLE ID_DISCONNECTION_NOTIFICATION, got 0x%02X reliability %d", p.Payload[0], p.Reliability)
+	}
+	player.Session.AcknowledgeRange(dp.SequenceNumber, dp.SequenceNumber)
+	srv.raknet.Update()
+	
+	if _, exists := srv.GetPlayer(1); exists {
+		t.Error("Expected the kicked player removed from the server")
+	}
+	if len(srv.raknet.GetSessions()) != 0 {
+		t.Error("Expected the kicked session torn down")
+	}
+	if len(reasons) != 1 || reasons[0] != DisconnectKicked {
+		t.Errorf("Expected one kicked disconnect event, got %v", reasons)
+	}
+}
+
+// addClientPlayer adds an in-game player whose session talks to a real
+// loopback client socket
+func addClientPlayer(t *testing.T, srv *Server, id uint16) (*Player, *net.UDPConn) {
+	t.Helper()
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open client socket: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	
+	player := addTestPlayer(srv, id, protocol.STATE_IN_GAME)
+	srv.raknet.forgetSession(player.Session)
+	player.Session.Addr = client.LocalAddr().(*net.UDPAddr)
+	player.Addr = player.Session.Addr
+	srv.raknet.sessions[player.Session.Addr.String()] = player.Session
+	return player, client
+}
+
+func TestDisconnectSessionFlushesQueueAndClosesOnAck(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	srv.DisconnectAckTimeout = 5 * time.Second
+	player, client := addClientPlayer(t, srv, 3)
+	srv.sendServerMessage(player.Session, "bye")
+	
+	// Returns straight away; the update loop closes the session later
+	srv.raknet.DisconnectSession(player.Session, DisconnectKicked, "")
+	
+	buf := make([]byte, protocol.MAX_MTU_SIZE)
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := client.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("Expected a datagram to the client, got %v", err)
+	}
+	dp, err := protocol.DecodeDataPacket(buf[:n])
+	if err != nil || len(dp.Packets) != 2 {
+		t.Fatalf("Expected the queued message and the notification in one datagram, got %v (%v)", dp, err)
+	}
+	if dp.Packets[0].Payload[0] != protocol.ID_RPC || dp.Packets[1].Payload[0] != protocol.ID_DISCONNECTION_NOTIFICATION {
+		t.Errorf("Expected the pending RPC flushed ahead of the notification, got 0x%02X then 0x%02X",
+			dp.Packets[0].Payload[0], dp.Packets[1].Payload[0])
+	}
+	
+	srv.raknet.Update()
+	if _, exists := srv.GetPlayer(3); !exists {
+		t.Error("Expected the player kept until the notification is ACKed")
+	}
+	
+	// A second disconnect while waiting doesn't notify again
+	srv.raknet.DisconnectSession(player.Session, DisconnectKicked, "")
+	player.Session.Mu.RLock()
+	queued := len(player.Session.SendQueue) + len(player.Session.RecoveryQueue)
+	player.Session.Mu.RUnlock()
+	if queued != 1 {
+		t.Errorf("Expected only the first notification outstanding, got %d", queued)
+	}
+	
+	player.Session.AcknowledgeRange(dp.SequenceNumber, dp.SequenceNumber)
+	srv.raknet.Update()
+	if _, exists := srv.GetPlayer(3); exists {
+		t.Error("Expected the player removed after the ACK")
+	}
+	if len(srv.raknet.GetSessions()) != 0 {
+		t.Error("Expected the session removed after the ACK")
+	}
+}
+
+func TestStopDisconnectsEveryPlayer(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	srv.DisconnectAckTimeout = 10 * time.Millisecond
+	_, first := addClientPlayer(t, srv, 1)
+	_, second := addClientPlayer(t, srv, 2)
+	
+	var recorder disconnectRecorder
+	srv.SetPlayerDisconnectHandler(recorder.handle)
+	
+	srv.Stop()
+	
+	for i, client := range []*net.UDPConn{first, second} {
+		buf := make([]byte, protocol.MAX_MTU_SIZE)
+		client.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := client.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatalf("Expected client %d to be notified, got %v", i, err)
+		}
+		dp, err := protocol.DecodeDataPacket(buf[:n])
+		if err != nil || len(dp.Packets) != 1 || dp.Packets[0].Payload[0] != protocol.ID_DISCONNECTION_NOTIFICATION {
+			t.Errorf("Expected client %d to get ID_DISCONNECTION_NOTIFICATION, got %v (%v)", i, dp, err)
+		}
+	}
+	
+	if len(recorder.reasons) != 2 || recorder.reasons[0] != DisconnectShutdown || recorder.reasons[1] != DisconnectShutdown {
+		t.Errorf("Expected both players to leave with shutdown, got %v", recorder.reasons)
+	}
+	if srv.GetPlayerCount() != 0 || len(srv.raknet.GetSessions()) != 0 {
+		t.Error("Expected no players or sessions left after Stop")
+	}
+}
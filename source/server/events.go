@@ -0,0 +1,173 @@
+package server
+
+import (
+	"log"
+	"samp-server-go/source/protocol"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventType represents different event types
+type EventType int
+
+const (
+	EventPlayerConnect EventType = iota
+	EventPlayerDisconnect
+	EventPlayerSpawn
+	EventPlayerDeath
+	EventPlayerCommand
+	EventPlayerText
+	EventPlayerUpdate
+	EventVehicleSpawn
+	EventVehicleDestroy
+	EventPlayerVehicleChange // player got into, out of or switched vehicles
+	EventPlayerAFKChange     // player went AFK or became active again (see AFKTimeout)
+	
+	// Stream events: PlayerID is the observer, Data the player or vehicle ID
+	// that came into (or left) their stream range
+	EventPlayerStreamIn
+	EventPlayerStreamOut
+	EventVehicleStreamIn
+	EventVehicleStreamOut
+)
+
+// Event.Data payloads. Spawn, death and update events carry no data.
+type (
+	// ConnectData is carried by EventPlayerConnect; Score is the one restored
+	// from the player's saved stats
+	ConnectData struct {
+		Name  string
+		Addr  string
+		Score int
+	}
+	
+	// DisconnectData is carried by EventPlayerDisconnect; Detail may be empty
+	DisconnectData struct {
+		Reason DisconnectReason
+		Detail string
+	}
+	
+	// CommandData is carried by EventPlayerCommand: "/goto 1" gives
+	// Command "goto" and Args ["1"]
+	CommandData struct {
+		Command string
+		Args    []string
+	}
+	
+	// TextData is carried by EventPlayerText
+	TextData struct {
+		Text string
+	}
+	
+	// VehicleChangeData is carried by EventPlayerVehicleChange; VehicleID is
+	// 0 once the player is back on foot
+	VehicleChangeData struct {
+		VehicleID uint16
+		Seat      uint8
+	}
+	
+	// AFKData is carried by EventPlayerAFKChange; AFK is false once the
+	// player is active again
+	AFKData struct {
+		AFK bool
+	}
+)
+
+// Event represents a game event
+type Event struct {
+	Type      EventType
+	PlayerID  uint16
+	Data      interface{}
+	Timestamp int64
+}
+
+// EventHandler is a function that handles events
+type EventHandler func(event Event)
+
+// EventManager manages game events
+type EventManager struct {
+	mu       sync.RWMutex
+	handlers map[EventType][]EventHandler
+}
+
+// NewEventManager creates a new event manager
+func NewEventManager() *EventManager {
+	return &EventManager{
+		handlers: make(map[EventType][]EventHandler),
+	}
+}
+
+// Register registers an event handler. Handlers for one event type run in
+// registration order.
+func (em *EventManager) Register(eventType EventType, handler EventHandler) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	em.handlers[eventType] = append(em.handlers[eventType], handler)
+}
+
+// Trigger triggers an event. Handlers run without the lock held, so they may
+// register further handlers.
+func (em *EventManager) Trigger(event Event) {
+	em.mu.RLock()
+	handlers := em.handlers[event.Type]
+	em.mu.RUnlock()
+	
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+// trigger fires a gamemode event for a player. Like the Set*Handler
+// callbacks it must be called without s.mu held.
+func (s *Server) trigger(eventType EventType, playerID uint16, data interface{}) {
+	if s.Events == nil {
+		return
+	}
+	event := Event{
+		Type:      eventType,
+		PlayerID:  playerID,
+		Data:      data,
+		Timestamp: time.Now().UnixMilli(),
+	}
+	s.callback("event", func() { s.Events.Trigger(event) })
+}
+
+// handleRPC dispatches RPCs sent by a client: [rpc id 1][params]
+func (s *Server) handleRPC(session *protocol.Session, packet *protocol.RakNetPacket) {
+	player, ok := s.playerForSession(session)
+	if !ok || len(packet.Payload) < 1 {
+		return
+	}
+	
+	rpcID, params := packet.Payload[0], packet.Payload[1:]
+	switch rpcID {
+	case protocol.RPC_Chat:
+		text, err := protocol.DecodeChatRPC(params)
+		if err != nil {
+			log.Printf("⚠️ Dropped chat from player %d: %v", player.ID, err)
+			return
+		}
+		s.trigger(EventPlayerText, player.ID, TextData{Text: text})
+	case protocol.RPC_ServerCommand:
+		line, err := protocol.DecodeServerCommandRPC(params)
+		if err != nil {
+			log.Printf("⚠️ Dropped command from player %d: %v", player.ID, err)
+			return
+		}
+		if command, ok := parseCommand(line); ok {
+			s.trigger(EventPlayerCommand, player.ID, command)
+		}
+	default:
+		log.Printf("Unhandled RPC 0x%02X from player %d", rpcID, player.ID)
+	}
+}
+
+// parseCommand splits "/Goto 1" into command "goto" and args ["1"]
+func parseCommand(line string) (CommandData, bool) {
+	fields := strings.Fields(strings.TrimPrefix(line, "/"))
+	if len(fields) == 0 {
+		return CommandData{}, false
+	}
+	return CommandData{Command: strings.ToLower(fields[0]), Args: fields[1:]}, true
+}
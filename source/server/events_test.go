@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/binary"
+	"samp-server-go/source/protocol"
+	"testing"
+)
+
+func TestPlayerConnectFiresEveryHandler(t *testing.T) {
+	srv := newTestServer()
+	
+	var got []ConnectData
+	for i := 0; i < 2; i++ {
+		srv.Events.Register(EventPlayerConnect, func(e Event) {
+			got = append(got, e.Data.(ConnectData))
+		})
+	}
+	
+	session := addTestSession(srv, 51000, protocol.STATE_CONNECTED)
+	session.Nickname = "Carol"
+	srv.handlePlayerJoin(session, &protocol.RakNetPacket{PacketID: protocol.ID_PLAYER_JOIN})
+	
+	if len(got) != 2 {
+		t.Fatalf("Expected both connect handlers to fire, got %d calls", len(got))
+	}
+	for _, data := range got {
+		if data.Name != "Carol" || data.Addr != session.Addr.String() {
+			t.Errorf("Expected ConnectData{Carol %s}, got %+v", session.Addr, data)
+		}
+	}
+}
+
+func TestClientRPCsTriggerEvents(t *testing.T) {
+	srv := newTestServer()
+	player := addTestPlayer(srv, 4, protocol.STATE_IN_GAME)
+	
+	var command CommandData
+	var text TextData
+	srv.Events.Register(EventPlayerCommand, func(e Event) {
+		if e.PlayerID == player.ID {
+			command = e.Data.(CommandData)
+		}
+	})
+	srv.Events.Register(EventPlayerText, func(e Event) {
+		text = e.Data.(TextData)
+	})
+	
+	line := "/Goto 1 2"
+	cmd := []byte{protocol.RPC_ServerCommand, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(cmd[1:], uint32(len(line)))
+	srv.handleGamePacket(player.Session, &protocol.RakNetPacket{PacketID: protocol.ID_RPC, Payload: append(cmd, line...)})
+	
+	if command.Command != "goto" || len(command.Args) != 2 || command.Args[0] != "1" || command.Args[1] != "2" {
+		t.Errorf("Expected command goto [1 2], got %+v", command)
+	}
+	
+	chat := append([]byte{protocol.RPC_Chat, 5}, "hello"...)
+	srv.handleGamePacket(player.Session, &protocol.RakNetPacket{PacketID: protocol.ID_RPC, Payload: chat})
+	if text.Text != "hello" {
+		t.Errorf("Expected chat text hello, got %q", text.Text)
+	}
+	
+	// A length running past the packet is dropped
+	text = TextData{}
+	srv.handleGamePacket(player.Session, &protocol.RakNetPacket{PacketID: protocol.ID_RPC, Payload: chat[:4]})
+	if text.Text != "" {
+		t.Errorf("Expected truncated chat to be dropped, got %q", text.Text)
+	}
+}
+
+func TestDisconnectEventCarriesReason(t *testing.T) {
+	srv := newTestServer()
+	player := addTestPlayer(srv, 2, protocol.STATE_IN_GAME)
+	
+	var data DisconnectData
+	srv.Events.Register(EventPlayerDisconnect, func(e Event) {
+		data = e.Data.(DisconnectData)
+	})
+	
+	srv.disconnectSession(player.Session, DisconnectKicked, "spam")
+	if DisconnectReason(data.Reason) != DisconnectKicked || data.Detail != "spam" {
+		t.Errorf("Expected kicked/spam, got %+v", data)
+	}
+}
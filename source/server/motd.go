@@ -0,0 +1,30 @@
+package server
+
+import "fmt"
+
+// motdLines returns the configured MOTD, or the default welcome line
+func (s *Server) motdLines() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	if len(s.MOTD) > 0 {
+		return append([]string(nil), s.MOTD...)
+	}
+	return []string{fmt.Sprintf("Welcome to %s!", s.ServerName)}
+}
+
+// sendMOTD sends the MOTD line by line after a player's first spawn. Lines
+// may carry "{RRGGBB}" color codes; the client renders them itself.
+func (s *Server) sendMOTD(player *Player) {
+	s.mu.Lock()
+	alreadySent := player.motdSent
+	player.motdSent = true
+	s.mu.Unlock()
+	if alreadySent || player.Session == nil {
+		return
+	}
+	
+	for _, line := range s.motdLines() {
+		s.sendServerMessage(player.Session, line)
+	}
+}
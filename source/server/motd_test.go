@@ -0,0 +1,47 @@
+package server
+
+import (
+	"bytes"
+	"samp-server-go/source/protocol"
+	"testing"
+)
+
+func TestMOTDSentAfterFirstSpawn(t *testing.T) {
+	srv := newTestServer()
+	srv.MOTD = []string{"{00FF00}Welcome!", "Type /help to see available commands"}
+	player := addTestPlayer(srv, 0, protocol.STATE_CONNECTED)
+	spawn := &protocol.RakNetPacket{PacketID: protocol.ID_SPAWN_PLAYER}
+	
+	srv.handleSpawnPlayer(player.Session, spawn)
+	
+	rpcs := queuedRPCs(player.Session)
+	if len(rpcs) != len(srv.MOTD) {
+		t.Fatalf("Expected %d MOTD lines queued, got %d RPCs", len(srv.MOTD), len(rpcs))
+	}
+	for i, line := range srv.MOTD {
+		expected := protocol.BuildSendClientMessageRPC(ServerMessageColor, line)
+		if !bytes.Equal(rpcs[i], expected) {
+			t.Errorf("Line %d: expected %q, got % X", i, line, rpcs[i])
+		}
+	}
+	
+	// Respawning must not repeat it
+	srv.handleSpawnPlayer(player.Session, spawn)
+	if n := len(queuedRPCs(player.Session)); n != len(srv.MOTD) {
+		t.Errorf("Expected MOTD to be sent once, got %d RPCs after respawn", n)
+	}
+}
+
+func TestDefaultMOTDWelcomesToServer(t *testing.T) {
+	srv := newTestServer()
+	srv.ServerName = "Test Server"
+	player := addTestPlayer(srv, 0, protocol.STATE_CONNECTED)
+	
+	srv.handleSpawnPlayer(player.Session, &protocol.RakNetPacket{PacketID: protocol.ID_SPAWN_PLAYER})
+	
+	rpcs := queuedRPCs(player.Session)
+	expected := protocol.BuildSendClientMessageRPC(ServerMessageColor, "Welcome to Test Server!")
+	if len(rpcs) != 1 || !bytes.Equal(rpcs[0], expected) {
+		t.Errorf("Expected the default welcome line, got %d RPCs", len(rpcs))
+	}
+}
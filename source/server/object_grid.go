@@ -0,0 +1,64 @@
+package server
+
+import "math"
+
+// objectGridCellSize is the side of an objectGrid cell in meters
+const objectGridCellSize = 250.0
+
+type gridCell struct {
+	X, Y int32
+}
+
+func cellOf(x, y float32) gridCell {
+	return gridCell{
+		X: int32(math.Floor(float64(x) / objectGridCellSize)),
+		Y: int32(math.Floor(float64(y) / objectGridCellSize)),
+	}
+}
+
+// objectGrid indexes objects by the 2D cells their draw distance reaches, so
+// streaming only looks at objects that can be visible from a player's cell.
+// The caller holds s.mu.
+type objectGrid struct {
+	cells map[gridCell]map[uint16]*Object
+}
+
+func newObjectGrid() *objectGrid {
+	return &objectGrid{cells: make(map[gridCell]map[uint16]*Object)}
+}
+
+// footprint calls fn for every cell within an object's draw distance
+func (g *objectGrid) footprint(object *Object, fn func(gridCell)) {
+	lo := cellOf(object.X-object.DrawDistance, object.Y-object.DrawDistance)
+	hi := cellOf(object.X+object.DrawDistance, object.Y+object.DrawDistance)
+	for x := lo.X; x <= hi.X; x++ {
+		for y := lo.Y; y <= hi.Y; y++ {
+			fn(gridCell{x, y})
+		}
+	}
+}
+
+func (g *objectGrid) add(object *Object) {
+	g.footprint(object, func(cell gridCell) {
+		objects, exists := g.cells[cell]
+		if !exists {
+			objects = make(map[uint16]*Object)
+			g.cells[cell] = objects
+		}
+		objects[object.ID] = object
+	})
+}
+
+func (g *objectGrid) remove(object *Object) {
+	g.footprint(object, func(cell gridCell) {
+		delete(g.cells[cell], object.ID)
+		if len(g.cells[cell]) == 0 {
+			delete(g.cells, cell)
+		}
+	})
+}
+
+// near returns the objects whose draw distance may reach (x, y)
+func (g *objectGrid) near(x, y float32) map[uint16]*Object {
+	return g.cells[cellOf(x, y)]
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"samp-server-go/source/protocol"
+	"sort"
+)
+
+// DefaultMaxStreamedObjects is the SA-MP client's object limit
+const DefaultMaxStreamedObjects = 1000
+
+// Object is a static map object that is streamed to nearby players
+type Object struct {
+	ID           uint16
+	ModelID      int
+	X, Y, Z      float32
+	RotX         float32
+	RotY         float32
+	RotZ         float32
+	DrawDistance float32
+}
+
+// MaxObjectDrawDistance bounds an object's draw distance, and with it the
+// grid cells the object is indexed in
+const MaxObjectDrawDistance = 3000.0
+
+// CreateObject adds an object to the world. It is sent to players on the
+// next player tick once they are within drawDistance of it. IDs of destroyed
+// objects are reused once the counter wraps; an error is returned when every
+// ID is taken or drawDistance is not in (0, MaxObjectDrawDistance].
+func (s *Server) CreateObject(modelID int, x, y, z, rotX, rotY, rotZ, drawDistance float32) (uint16, error) {
+	if !(drawDistance > 0 && drawDistance <= MaxObjectDrawDistance) {
+		return 0, fmt.Errorf("invalid draw distance %v (must be above 0 and at most %.0f)", drawDistance, MaxObjectDrawDistance)
+	}
+	
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	id, ok := s.nextFreeObjectID()
+	if !ok {
+		return 0, fmt.Errorf("no free object ID (%d objects)", len(s.objects))
+	}
+	s.nextObjectID = id
+	object := &Object{
+		ID:           id,
+		ModelID:      modelID,
+		X:            x,
+		Y:            y,
+		Z:            z,
+		RotX:         rotX,
+		RotY:         rotY,
+		RotZ:         rotZ,
+		DrawDistance: drawDistance,
+	}
+	s.objects[object.ID] = object
+	s.objectGrid.add(object)
+	
+	log.Printf("🧱 Object %d (model %d) created at %.2f, %.2f, %.2f", object.ID, modelID, x, y, z)
+	
+	return object.ID, nil
+}
+
+// nextFreeObjectID returns the first unused ID after the last one handed
+// out, wrapping past 0xFFFF and skipping 0. Caller holds s.mu.
+func (s *Server) nextFreeObjectID() (uint16, bool) {
+	id := s.nextObjectID
+	for i := 0; i < 0xFFFF; i++ {
+		id++
+		if id == 0 {
+			id = 1
+		}
+		if _, used := s.objects[id]; !used {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// DestroyObject removes an object and destroys it on every client that streamed it in
+func (s *Server) DestroyObject(objectID uint16) bool {
+	s.mu.Lock()
+	object, exists := s.objects[objectID]
+	if !exists {
+		s.mu.Unlock()
+		return false
+	}
+	delete(s.objects, objectID)
+	s.objectGrid.remove(object)
+	
+	sessions := make([]*protocol.Session, 0)
+	for _, player := range s.Players {
+		if player.StreamedObjects[objectID] {
+			delete(player.StreamedObjects, objectID)
+			sessions = append(sessions, player.Session)
+		}
+	}
+	s.mu.Unlock()
+	
+	for _, session := range sessions {
+		s.sendRPC(session, protocol.BuildDestroyObjectRPC(objectID))
+	}
+	
+	log.Printf("🧱 Object %d destroyed", objectID)
+	return true
+}
+
+// streamObjects creates objects that came into range of the player and
+// destroys those that left it. The closest MaxStreamedObjects win.
+func (s *Server) streamObjects(player *Player) {
+	type candidate struct {
+		object *Object
+		distSq float32
+	}
+	
+	s.mu.Lock()
+	
+	inRange := make([]candidate, 0)
+	for _, object := range s.objectGrid.near(player.PosX, player.PosY) {
+		dx := object.X - player.PosX
+		dy := object.Y - player.PosY
+		dz := object.Z - player.PosZ
+		distSq := dx*dx + dy*dy + dz*dz
+		if distSq <= object.DrawDistance*object.DrawDistance {
+			inRange = append(inRange, candidate{object, distSq})
+		}
+	}
+	
+	if s.MaxStreamedObjects > 0 && len(inRange) > s.MaxStreamedObjects {
+		sort.Slice(inRange, func(i, j int) bool {
+			return inRange[i].distSq < inRange[j].distSq
+		})
+		inRange = inRange[:s.MaxStreamedObjects]
+	}
+	
+	wanted := make(map[uint16]bool, len(inRange))
+	created := make([]*Object, 0)
+	for _, c := range inRange {
+		wanted[c.object.ID] = true
+		if !player.StreamedObjects[c.object.ID] {
+			player.StreamedObjects[c.object.ID] = true
+			created = append(created, c.object)
+		}
+	}
+	
+	destroyed := make([]uint16, 0)
+	for objectID := range player.StreamedObjects {
+		if !wanted[objectID] {
+			delete(player.StreamedObjects, objectID)
+			destroyed = append(destroyed, objectID)
+		}
+	}
+	
+	s.mu.Unlock()
+	
+	// Destroy first so the client has room for the new objects
+	for _, objectID := range destroyed {
+		s.sendRPC(player.Session, protocol.BuildDestroyObjectRPC(objectID))
+	}
+	for _, object := range created {
+		s.sendRPC(player.Session, protocol.BuildCreateObjectRPC(object.ID, int32(object.ModelID),
+			object.X, object.Y, object.Z, object.RotX, object.RotY, object.RotZ, object.DrawDistance))
+	}
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"math"
+	"samp-server-go/source/protocol"
+	"testing"
+)
+
+func TestObjectStreamsInAtDrawDistance(t *testing.T) {
+	srv := newTestServer()
+	player := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	objectID, _ := srv.CreateObject(1337, 0, 0, 0, 0, 0, 0, 100)
+	
+	player.SetPosition(101, 0, 0)
+	srv.streamObjects(player)
+	if len(queuedRPCs(player.Session)) != 0 {
+		t.Fatal("Object should not stream in outside its draw distance")
+	}
+	
+	player.SetPosition(100, 0, 0)
+	srv.streamObjects(player)
+	rpcs := queuedRPCs(player.Session)
+	if len(rpcs) != 1 {
+		t.Fatalf("Expected 1 create RPC at the boundary, got %d", len(rpcs))
+	}
+	if rpcs[0][0] != protocol.RPC_CreateObject || rpcs[0][1] != byte(objectID) || rpcs[0][2] != byte(objectID>>8) {
+		t.Errorf("Expected CreateObject(%d), got %02X", objectID, rpcs[0][:3])
+	}
+	
+	// Staying in range must not resend the object
+	srv.streamObjects(player)
+	if len(queuedRPCs(player.Session)) != 1 {
+		t.Error("Object was created twice")
+	}
+	
+	player.SetPosition(200, 0, 0)
+	srv.streamObjects(player)
+	rpcs = queuedRPCs(player.Session)
+	if len(rpcs) != 2 || rpcs[1][0] != protocol.RPC_DestroyObject {
+		t.Errorf("Expected a DestroyObject RPC after leaving range, got %v", rpcs)
+	}
+}
+
+func TestObjectStreamingRespectsCap(t *testing.T) {
+	srv := newTestServer()
+	srv.MaxStreamedObjects = 2
+	player := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	
+	far, _ := srv.CreateObject(1000, 50, 0, 0, 0, 0, 0, 300)
+	near1, _ := srv.CreateObject(1000, 10, 0, 0, 0, 0, 0, 300)
+	near2, _ := srv.CreateObject(1000, 20, 0, 0, 0, 0, 0, 300)
+	
+	srv.streamObjects(player)
+	
+	if len(player.StreamedObjects) != 2 {
+		t.Fatalf("Expected 2 streamed objects, got %d", len(player.StreamedObjects))
+	}
+	if !player.StreamedObjects[near1] || !player.StreamedObjects[near2] || player.StreamedObjects[far] {
+		t.Errorf("Expected the two closest objects to be streamed, got %v", player.StreamedObjects)
+	}
+}
+
+func TestDestroyObjectRemovesFromClients(t *testing.T) {
+	srv := newTestServer()
+	player := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	objectID, _ := srv.CreateObject(1337, 0, 0, 0, 0, 0, 0, 100)
+	srv.streamObjects(player)
+	
+	if !srv.DestroyObject(objectID) {
+		t.Fatal("DestroyObject returned false")
+	}
+	if player.StreamedObjects[objectID] {
+		t.Error("Destroyed object is still marked as streamed")
+	}
+	
+	rpcs := queuedRPCs(player.Session)
+	if len(rpcs) != 2 || rpcs[1][0] != protocol.RPC_DestroyObject {
+		t.Errorf("Expected a DestroyObject RPC, got %v", rpcs)
+	}
+}
+
+func TestObjectGridLooksUpOnlyNearbyCells(t *testing.T) {
+	srv := newTestServer()
+	near, _ := srv.CreateObject(1337, 240, 0, 0, 0, 0, 0, 50) // reaches into the next cell
+	far, _ := srv.CreateObject(1337, 3000, 3000, 0, 0, 0, 0, 50)
+	
+	candidates := srv.objectGrid.near(260, 10)
+	if candidates[near] == nil {
+		t.Errorf("Expected object %d across the cell edge to be a candidate", near)
+	}
+	if candidates[far] != nil || len(candidates) != 1 {
+		t.Errorf("Expected only object %d as a candidate, got %d", near, len(candidates))
+	}
+	
+	srv.DestroyObject(near)
+	if _, exists := srv.objectGrid.cells[cellOf(260, 10)]; exists {
+		t.Error("Expected the destroyed object's cells to be dropped")
+	}
+}
+
+func TestCreateObjectReusesFreeIDsAfterWrap(t *testing.T) {
+	srv := newTestServer()
+	first, _ := srv.CreateObject(1337, 0, 0, 0, 0, 0, 0, 100)
+	second, _ := srv.CreateObject(1337, 0, 0, 0, 0, 0, 0, 100)
+	srv.DestroyObject(first)
+	
+	// The counter wraps past 0xFFFF, skips 0 and the ID still in use
+	srv.nextObjectID = 0xFFFF
+	srv.objects[0xFFFF] = &Object{ID: 0xFFFF}
+	objectID, err := srv.CreateObject(1337, 0, 0, 0, 0, 0, 0, 100)
+	if err != nil || objectID != first {
+		t.Fatalf("Expected the freed ID %d, got %d (%v)", first, objectID, err)
+	}
+	objectID, err = srv.CreateObject(1337, 0, 0, 0, 0, 0, 0, 100)
+	if err != nil || objectID == second || objectID == 0 {
+		t.Fatalf("Expected an unused ID, got %d (%v)", objectID, err)
+	}
+	
+	for id := 1; id <= 0xFFFF; id++ {
+		srv.objects[uint16(id)] = &Object{ID: uint16(id)}
+	}
+	if _, err := srv.CreateObject(1337, 0, 0, 0, 0, 0, 0, 100); err == nil {
+		t.Error("Expected an error once every object ID is in use")
+	}
+}
+
+func TestCreateObjectRejectsInvalidDrawDistance(t *testing.T) {
+	srv := newTestServer()
+	for _, drawDistance := range []float32{0, -10, float32(math.NaN()), float32(math.Inf(1)), MaxObjectDrawDistance + 1} {
+		if _, err := srv.CreateObject(1337, 0, 0, 0, 0, 0, 0, drawDistance); err == nil {
+			t.Errorf("Expected draw distance %v to be rejected", drawDistance)
+		}
+	}
+	if len(srv.objects) != 0 {
+		t.Errorf("Expected no objects created, got %d", len(srv.objects))
+	}
+}
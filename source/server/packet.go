@@ -1,32 +1,6 @@
 package server
 
-// SA-MP Packet IDs
-const (
-	// RakNet packets
-	ID_OPEN_CONNECTION_REQUEST  = 0x05
-	ID_OPEN_CONNECTION_REPLY    = 0x06
-	ID_CONNECTION_REQUEST       = 0x07
-	ID_CONNECTION_ACCEPTED      = 0x08
-	ID_UNCONNECTED_PING         = 0x1C
-	ID_UNCONNECTED_PONG         = 0x1D
-	
-	// SA-MP specific packets
-	ID_PLAYER_SYNC              = 0xCF
-	ID_VEHICLE_SYNC             = 0xC8
-	ID_PASSENGER_SYNC           = 0xD2
-	ID_SPECTATOR_SYNC           = 0xD4
-	ID_AIM_SYNC                 = 0xC9
-	ID_TRAILER_SYNC             = 0xCA
-	ID_UNOCCUPIED_SYNC          = 0xCD
-	ID_BULLET_SYNC              = 0xCE
-	
-	// Server packets
-	ID_PLAYER_JOIN              = 0x89
-	ID_PLAYER_QUIT              = 0x8A
-	ID_SPAWN_PLAYER             = 0x8B
-	ID_DEATH_NOTIFICATION       = 0x8C
-	ID_SERVER_MESSAGE           = 0x8D
-)
+// Packet IDs live in source/protocol (protocol.ID_*)
 
 type Packet struct {
 	ID   byte
@@ -46,3 +20,27 @@ func (p *Packet) Serialize() []byte {
 	copy(result[1:], p.Data)
 	return result
 }
+
+// Kinds of datagram arriving on the game port (see classifyPacket)
+type packetKind int
+
+const (
+	packetEmpty packetKind = iota
+	packetQuery            // SA-MP query, starts with the "SAMP" magic
+	packetGame             // RakNet traffic
+)
+
+// sampQueryMagic starts every SA-MP query datagram
+const sampQueryMagic = "SAMP"
+
+// classifyPacket tells queries from game traffic by the full "SAMP" magic.
+// A lone 'S' (0x53) first byte is not enough: game packets can start with it.
+func classifyPacket(data []byte) packetKind {
+	if len(data) == 0 {
+		return packetEmpty
+	}
+	if len(data) >= len(sampQueryMagic) && string(data[:len(sampQueryMagic)]) == sampQueryMagic {
+		return packetQuery
+	}
+	return packetGame
+}
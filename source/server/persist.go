@@ -0,0 +1,166 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultAutosaveInterval bounds how much runtime state a crash can lose
+const DefaultAutosaveInterval = 5 * time.Minute
+
+// PlayerStats is the part of a player saved across restarts
+type PlayerStats struct {
+	Name    string    `json:"name"`
+	Score   int       `json:"score"`
+	Skin    int       `json:"skin"`
+	Color   uint32    `json:"color"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// Save writes the ban list to BanFile and player stats to StatsFile. Either
+// is skipped if its path is empty.
+func (s *Server) Save() error {
+	s.mu.RLock()
+	banFile, statsFile := s.BanFile, s.StatsFile
+	s.mu.RUnlock()
+	
+	var errs []error
+	if banFile != "" {
+		if err := s.Bans.Save(banFile); err != nil {
+			errs = append(errs, fmt.Errorf("saving ban list: %w", err))
+		}
+	}
+	if statsFile != "" {
+		if err := s.saveStats(statsFile, time.Now()); err != nil {
+			errs = append(errs, fmt.Errorf("saving player stats: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// saveStats writes every known player's stats to path, keyed by name:
+// connected players' current ones and the last ones of players who left
+func (s *Server) saveStats(path string, now time.Time) error {
+	s.mu.Lock()
+	for _, player := range s.Players {
+		if player.Connected {
+			s.rememberStats(player, now)
+		}
+	}
+	data, err := json.MarshalIndent(s.stats, "", "  ")
+	s.mu.Unlock()
+	
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// rememberStats records a player's stats for the next save. The caller holds
+// s.mu.
+func (s *Server) rememberStats(player *Player, now time.Time) {
+	if player.Name == "" {
+		return
+	}
+	s.stats[player.Name] = PlayerStats{
+		Name:    player.Name,
+		Score:   player.Score,
+		Skin:    player.Skin,
+		Color:   player.Color,
+		SavedAt: now,
+	}
+}
+
+// restoreStats applies the stats saved under a joining player's name, if
+// any. The caller holds s.mu.
+func (s *Server) restoreStats(player *Player) {
+	saved, ok := s.stats[player.Name]
+	if !ok {
+		return
+	}
+	player.Score = saved.Score
+	player.Skin = saved.Skin
+	player.Color = saved.Color
+	log.Printf("💾 Restored stats for %s (score %d)", player.Name, saved.Score)
+}
+
+// LoadPlayerStats reads a stats file written by Save, keyed by player name.
+// A missing file has no stats.
+func LoadPlayerStats(path string) (map[string]PlayerStats, error) {
+	stats := make(map[string]PlayerStats)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return stats, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, fmt.Errorf("invalid stats file %s: %w", path, err)
+	}
+	return stats, nil
+}
+
+// loadStats replaces the known player stats with the ones saved at path
+func (s *Server) loadStats(path string) error {
+	stats, err := LoadPlayerStats(path)
+	if err != nil {
+		return err
+	}
+	
+	s.mu.Lock()
+	s.stats = stats
+	s.mu.Unlock()
+	return nil
+}
+
+// autosaveLoop saves state every AutosaveInterval so a crash loses at most
+// one interval; Stop does the final save
+func (s *Server) autosaveLoop() {
+	if s.AutosaveInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.AutosaveInterval)
+	defer ticker.Stop()
+	
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+		
+		if err := s.Save(); err != nil {
+			log.Printf("❌ Autosave failed: %v", err)
+		}
+	}
+}
+
+// writeFileAtomic replaces path with data via a temporary file, so a crash
+// mid-write leaves the previous save intact
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
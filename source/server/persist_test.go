@@ -0,0 +1,142 @@
+package server
+
+import (
+	"net"
+	"path/filepath"
+	"samp-server-go/source/protocol"
+	"testing"
+	"time"
+)
+
+func TestStopSavesBansAndPlayerStats(t *testing.T) {
+	dir := t.TempDir()
+	srv := newTestServerWithConn(t)
+	srv.BanFile = filepath.Join(dir, "bans.json")
+	srv.StatsFile = filepath.Join(dir, "player_stats.json")
+	
+	srv.Bans.Add(Ban{IP: "10.0.0.1", Name: "Cheater", Reason: "aimbot"})
+	player := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	player.Name = "Alice"
+	srv.SetPlayerScore(player.ID, 42)
+	
+	srv.Stop()
+	
+	bans := NewBanList()
+	if err := bans.Load(srv.BanFile); err != nil {
+		t.Fatalf("Failed to load saved ban list: %v", err)
+	}
+	if list := bans.List(); len(list) != 1 || list[0].IP != "10.0.0.1" || list[0].Reason != "aimbot" {
+		t.Errorf("Expected the runtime ban to be saved, got %+v", list)
+	}
+	
+	stats, err := LoadPlayerStats(srv.StatsFile)
+	if err != nil {
+		t.Fatalf("Failed to load saved stats: %v", err)
+	}
+	if stats["Alice"].Score != 42 {
+		t.Errorf("Expected Alice's score 42 to be saved, got %+v", stats["Alice"])
+	}
+}
+
+func TestSaveStatsKeepsOfflinePlayers(t *testing.T) {
+	srv := newTestServer()
+	srv.StatsFile = filepath.Join(t.TempDir(), "player_stats.json")
+	
+	bob := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	bob.Name = "Bob"
+	srv.SetPlayerScore(bob.ID, 7)
+	if err := srv.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	
+	// Bob leaves; the next save still has his last stats
+	delete(srv.Players, 1)
+	alice := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	alice.Name = "Alice"
+	if err := srv.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	
+	stats, _ := LoadPlayerStats(srv.StatsFile)
+	if len(stats) != 2 || stats["Bob"].Score != 7 {
+		t.Errorf("Expected stats for Alice and Bob, got %+v", stats)
+	}
+}
+
+func TestStatsRestoredOnRejoin(t *testing.T) {
+	srv := newTestServer()
+	srv.StatsFile = filepath.Join(t.TempDir(), "player_stats.json")
+	
+	session := protocol.NewSession(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50001}, 576)
+	session.Nickname = "Alice"
+	alice := srv.AddPlayer(session)
+	srv.SetPlayerScore(alice.ID, 42)
+	
+	// Alice leaves before the save; her score is still written
+	srv.RemovePlayer(alice.ID)
+	if err := srv.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	
+	// After a restart the saved score comes back when she joins
+	restarted := newTestServer()
+	if err := restarted.loadStats(srv.StatsFile); err != nil {
+		t.Fatalf("Failed to load stats: %v", err)
+	}
+	
+	var connect ConnectData
+	restarted.Events.Register(EventPlayerConnect, func(e Event) {
+		connect = e.Data.(ConnectData)
+	})
+	rejoin := protocol.NewSession(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50002}, 576)
+	rejoin.Nickname = "Alice"
+	restarted.handlePlayerJoin(rejoin, &protocol.RakNetPacket{PacketID: protocol.ID_PLAYER_JOIN})
+	
+	player, ok := restarted.playerForSession(rejoin)
+	if !ok || player.Score != 42 {
+		t.Fatalf("Expected Alice to rejoin with score 42, got %+v", player)
+	}
+	if connect.Score != 42 {
+		t.Errorf("Expected the connect event to carry score 42, got %d", connect.Score)
+	}
+}
+
+func TestAutosaveLoopSavesPeriodically(t *testing.T) {
+	srv := newTestServer()
+	srv.BanFile = filepath.Join(t.TempDir(), "bans.json")
+	srv.AutosaveInterval = 10 * time.Millisecond
+	srv.Bans.Add(Ban{IP: "10.0.0.2"})
+	
+	srv.goLoop(srv.autosaveLoop)
+	defer func() {
+		close(srv.done)
+		srv.loops.Wait()
+	}()
+	
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		bans := NewBanList()
+		if bans.Load(srv.BanFile) == nil && bans.IsBanned("10.0.0.2") {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("Expected the autosave to write the ban list")
+}
+
+func TestBannedAddressRejectedAtHandshake(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50001}
+	srv.Bans.Add(Ban{IP: "127.0.0.1"})
+	
+	srv.raknet.HandlePacket([]byte{0x08, 0x01, 0x02, 0x03}, addr)
+	if _, ok := srv.raknet.sessions[addr.String()]; ok {
+		t.Error("Expected no session for a banned address")
+	}
+	
+	srv.Bans.Remove("127.0.0.1")
+	srv.raknet.HandlePacket([]byte{0x08, 0x01, 0x02, 0x03}, addr)
+	if _, ok := srv.raknet.sessions[addr.String()]; !ok {
+		t.Error("Expected the handshake to proceed once unbanned")
+	}
+}
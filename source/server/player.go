@@ -1,16 +1,36 @@
 package server
 
 import (
+	"math"
 	"net"
+	"samp-server-go/source/protocol"
+	"strings"
 	"time"
 )
 
+// SetPlayerName results (same values SA-MP's SetPlayerName returns)
+const (
+	NameChangeInvalid = -1
+	NameChangeTaken   = 0
+	NameChangeSuccess = 1
+)
+
+// Player name limits enforced by the SA-MP client
+const (
+	MinPlayerNameLength = 3
+	MaxPlayerNameLength = 24
+)
+
+// MaxPlayerID is the highest player ID the SA-MP client accepts (MAX_PLAYERS - 1)
+const MaxPlayerID = 999
+
 type Player struct {
-	ID       int
+	ID       uint16
 	Name     string
 	Addr     *net.UDPAddr
 	Connected bool
 	LastPing time.Time
+	Session  *protocol.Session
 	
 	// Game state
 	PosX     float32
@@ -22,9 +42,48 @@ type Player struct {
 	Skin     int
 	Interior int
 	VirtualWorld int
+	Score    int
+	Color    uint32 // name tag and radar color, 0xRRGGBBAA
+	
+	// Damage is ignored until this time (zero = not protected). The first
+	// on-foot sync after the spawn sets spawnOrigin; moving away from it ends
+	// the protection early.
+	SpawnProtectedUntil time.Time
+	spawnOrigin         [3]float32
+	spawnOriginSet      bool
+	
+	// Set while the client's on-foot sync reports health or armour the
+	// server didn't give it; the server's values are kept either way
+	StatsMismatch bool
+	
+	// Latest aim sync, nil until the player aims
+	Aim *protocol.AimSync
+	
+	// Vehicle the player is in (0 = on foot), their seat (0 = driver) and
+	// the trailer it is towing (0 = none)
+	VehicleID uint16
+	Seat      uint8
+	TrailerID uint16
+	
+	// Frozen players can't move; re-applied after every respawn
+	Frozen bool
+	
+	// AFK is set after Server.AFKTimeout without an input change (see afk.go)
+	AFK           bool
+	lastInput     time.Time
+	lastSyncInput []byte
+	
+	// Objects currently created on this player's client
+	StreamedObjects map[uint16]bool
+	
+	// Players and vehicles currently in this player's stream range
+	StreamedPlayers  map[uint16]bool
+	StreamedVehicles map[uint16]bool
+	
+	motdSent bool
 }
 
-func NewPlayer(id int, addr *net.UDPAddr) *Player {
+func NewPlayer(id uint16, addr *net.UDPAddr) *Player {
 	return &Player{
 		ID:        id,
 		Addr:      addr,
@@ -35,6 +94,10 @@ func NewPlayer(id int, addr *net.UDPAddr) *Player {
 		Skin:      0,
 		Interior:  0,
 		VirtualWorld: 0,
+		Color:     protocol.ColorWhite,
+		StreamedObjects: make(map[uint16]bool),
+		StreamedPlayers:  make(map[uint16]bool),
+		StreamedVehicles: make(map[uint16]bool),
 	}
 }
 
@@ -61,3 +124,104 @@ func (p *Player) SetHealth(health float32) {
 func (p *Player) IsAlive() bool {
 	return p.Health > 0
 }
+
+// IsInGame reports whether the player's session has finished joining
+func (p *Player) IsInGame() bool {
+	return p.Session != nil && p.Session.CanStream()
+}
+
+// Ping returns the last measured round trip in milliseconds, clamped to uint16
+func (p *Player) Ping() uint16 {
+	if p.Session == nil {
+		return 0
+	}
+	ms := p.Session.GetRTT().Milliseconds()
+	if ms > math.MaxUint16 {
+		return math.MaxUint16
+	}
+	return uint16(ms)
+}
+
+// Regenerate restores armour first and only starts on health once armour is full.
+// It reports which values changed.
+func (p *Player) Regenerate(armourAmount, healthAmount float32) (armourChanged, healthChanged bool) {
+	if armourAmount > 0 && p.Armour < 100 {
+		p.Armour += armourAmount
+		if p.Armour > 100 {
+			p.Armour = 100
+		}
+		return true, false
+	}
+	
+	if healthAmount > 0 && p.Health < 100 {
+		p.SetHealth(p.Health + healthAmount)
+		return false, true
+	}
+	
+	return false, false
+}
+
+// IsSpawnProtected reports whether the player is still inside the spawn protection window
+func (p *Player) IsSpawnProtected(now time.Time) bool {
+	return now.Before(p.SpawnProtectedUntil)
+}
+
+// ClearSpawnProtection ends spawn protection early
+func (p *Player) ClearSpawnProtection() {
+	p.SpawnProtectedUntil = time.Time{}
+	p.spawnOriginSet = false
+}
+
+// SpawnProtectionMoveDistance is how far a spawn-protected player may move
+// from where they spawned before the protection ends
+const SpawnProtectionMoveDistance = 2.0
+
+// movedFromSpawn reports whether (x, y, z) is further than
+// SpawnProtectionMoveDistance from the player's spawn position. The first
+// position seen after a spawn becomes that spawn position.
+func (p *Player) movedFromSpawn(x, y, z float32) bool {
+	if !p.spawnOriginSet {
+		p.spawnOrigin = [3]float32{x, y, z}
+		p.spawnOriginSet = true
+		return false
+	}
+	dx := float64(x - p.spawnOrigin[0])
+	dy := float64(y - p.spawnOrigin[1])
+	dz := float64(z - p.spawnOrigin[2])
+	return math.Sqrt(dx*dx+dy*dy+dz*dz) > SpawnProtectionMoveDistance
+}
+
+// TakeDamage removes armour first, then health
+func (p *Player) TakeDamage(amount float32) {
+	if amount <= 0 {
+		return
+	}
+	
+	if p.Armour > 0 {
+		absorbed := amount
+		if absorbed > p.Armour {
+			absorbed = p.Armour
+		}
+		p.Armour -= absorbed
+		amount -= absorbed
+	}
+	
+	p.SetHealth(p.Health - amount)
+}
+
+// IsValidPlayerName checks length and the character set the SA-MP client accepts
+func IsValidPlayerName(name string) bool {
+	if len(name) < MinPlayerNameLength || len(name) > MaxPlayerNameLength {
+		return false
+	}
+	
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("[]()$@._=", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
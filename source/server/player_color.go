@@ -0,0 +1,61 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"samp-server-go/source/protocol"
+)
+
+// DefaultPlayerColors is the palette joining players are colored from, picked
+// by player ID so players next to each other in the list look different
+var DefaultPlayerColors = []uint32{
+	0xFF8C13FF, 0xC715FFFF, 0x20B2AAFF, 0xDC143CFF,
+	0x6495EDFF, 0xF0E68CFF, 0x778899FF, 0xFF1493FF,
+	0xF4A460FF, 0xEE82EEFF, 0xFFD720FF, 0x8B4513FF,
+	0x4949A0FF, 0x148B8BFF, 0x14FF7FFF, 0x556B2FFF,
+}
+
+// defaultPlayerColor returns the palette color for a player ID
+func defaultPlayerColor(playerID uint16) uint32 {
+	if len(DefaultPlayerColors) == 0 {
+		return protocol.ColorWhite
+	}
+	return DefaultPlayerColors[int(playerID)%len(DefaultPlayerColors)]
+}
+
+// markerColor is the color clients are sent for a player: with player markers
+// off the alpha is cleared so no radar blip shows. The caller holds s.mu.
+func (s *Server) markerColor(color uint32) uint32 {
+	if s.PlayerMarkers == protocol.PlayerMarkersOff {
+		return color &^ 0xFF
+	}
+	return color
+}
+
+// SetPlayerColor changes a player's name tag and radar color and sends it to
+// everyone connected, the player included. Gamemodes can call it from the
+// connect handler to replace the color assigned on join.
+func (s *Server) SetPlayerColor(playerID uint16, color uint32) error {
+	s.mu.Lock()
+	player, exists := s.Players[playerID]
+	if !exists {
+		s.mu.Unlock()
+		return fmt.Errorf("player %d not found", playerID)
+	}
+	player.Color = color
+	rpc := protocol.BuildSetPlayerColorRPC(playerID, s.markerColor(color))
+	s.mu.Unlock()
+	
+	log.Printf("🎨 Player %d color set to 0x%08X", playerID, color)
+	s.broadcastToConnected(rpc)
+	return nil
+}
+
+// broadcastToConnected queues an RPC to every connected player, in game or not
+func (s *Server) broadcastToConnected(rpc []byte) {
+	s.ForEachPlayer(func(player *Player) {
+		if player.Connected && player.Session != nil {
+			s.sendRPC(player.Session, rpc)
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package server
+
+import "samp-server-go/source/protocol"
+
+// announcePlayerJoin adds player to every other player's list and sends the
+// newcomer the players already connected. The newcomer's own entry is
+// skipped; their client learns its own ID from the connection accept. The
+// newcomer's color then goes to everyone, so their own client colors them too.
+func (s *Server) announcePlayerJoin(player *Player) {
+	s.mu.RLock()
+	join := protocol.BuildServerJoinRPC(player.ID, s.markerColor(player.Color), false, player.Name)
+	color := protocol.BuildSetPlayerColorRPC(player.ID, s.markerColor(player.Color))
+	s.mu.RUnlock()
+	
+	s.ForEachPlayer(func(other *Player) {
+		if other == player || !other.Connected || other.Session == nil {
+			return
+		}
+		
+		s.mu.RLock()
+		existing := protocol.BuildServerJoinRPC(other.ID, s.markerColor(other.Color), false, other.Name)
+		s.mu.RUnlock()
+		
+		s.sendRPC(other.Session, join)
+		s.sendRPC(player.Session, existing)
+	})
+	
+	s.broadcastToConnected(color)
+}
+
+// announcePlayerQuit removes a player who left from everyone else's list
+func (s *Server) announcePlayerQuit(player *Player, reason DisconnectReason) {
+	quit := protocol.BuildServerQuitRPC(player.ID, quitReason(reason))
+	
+	s.ForEachPlayer(func(other *Player) {
+		if other != player && other.Connected && other.Session != nil {
+			s.sendRPC(other.Session, quit)
+		}
+	})
+}
+
+// quitReason maps a DisconnectReason to ServerQuit's reason byte, which has
+// no separate value for bans
+func quitReason(reason DisconnectReason) uint8 {
+	if reason == DisconnectBanned {
+		return uint8(DisconnectKicked)
+	}
+	return uint8(reason)
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"bytes"
+	"samp-server-go/source/protocol"
+	"testing"
+)
+
+// serverJoins returns the ServerJoin RPCs queued on a session
+func serverJoins(session *protocol.Session) [][]byte {
+	joins := make([][]byte, 0)
+	for _, rpc := range queuedRPCs(session) {
+		if rpc[0] == protocol.RPC_ServerJoin {
+			joins = append(joins, rpc)
+		}
+	}
+	return joins
+}
+
+func TestPlayerJoinAnnouncedToExistingPlayers(t *testing.T) {
+	srv := newTestServer()
+	alice := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	alice.Name = "Alice"
+	bob := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	bob.Name = "Bob"
+	
+	session := addTestSession(srv, 51000, protocol.STATE_CONNECTED)
+	session.Nickname = "Carol"
+	srv.handlePlayerJoin(session, &protocol.RakNetPacket{PacketID: protocol.ID_PLAYER_JOIN})
+	
+	carol, ok := srv.playerForSession(session)
+	if !ok {
+		t.Fatal("Expected Carol to join")
+	}
+	expected := protocol.BuildServerJoinRPC(carol.ID, carol.Color, false, "Carol")
+	for _, existing := range []*Player{alice, bob} {
+		joins := serverJoins(existing.Session)
+		if len(joins) != 1 || !bytes.Equal(joins[0], expected) {
+			t.Errorf("Expected %s to get one ServerJoin for Carol, got %d", existing.Name, len(joins))
+		}
+	}
+	
+	// The newcomer gets everyone else, but not themselves
+	joins := serverJoins(session)
+	if len(joins) != 2 ||
+		!bytes.Equal(joins[0], protocol.BuildServerJoinRPC(alice.ID, alice.Color, false, "Alice")) ||
+		!bytes.Equal(joins[1], protocol.BuildServerJoinRPC(bob.ID, bob.Color, false, "Bob")) {
+		t.Errorf("Expected Carol to get ServerJoin for Alice and Bob, got %d", len(joins))
+	}
+}
+
+func TestPlayerQuitAnnouncedToRemainingPlayers(t *testing.T) {
+	srv := newTestServer()
+	stayer := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	leaver := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	
+	srv.disconnectSession(leaver.Session, DisconnectBanned, "")
+	
+	expected := protocol.BuildServerQuitRPC(1, 2)
+	found := false
+	for _, rpc := range queuedRPCs(stayer.Session) {
+		found = found || bytes.Equal(rpc, expected)
+	}
+	if !found {
+		t.Errorf("Expected ServerQuit % X for the remaining player", expected)
+	}
+	if n := len(queuedRPCs(leaver.Session)); n != 0 {
+		t.Errorf("Expected nothing sent to the leaving player, got %d RPCs", n)
+	}
+}
+
+func TestJoiningPlayersGetDistinctColorsBroadcast(t *testing.T) {
+	srv := newTestServer()
+	watcher := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	
+	joined := make([]*Player, 0, 2)
+	for i, name := range []string{"Alice", "Bob"} {
+		session := addTestSession(srv, 51000+i, protocol.STATE_CONNECTED)
+		session.Nickname = name
+		srv.handlePlayerJoin(session, &protocol.RakNetPacket{PacketID: protocol.ID_PLAYER_JOIN})
+		player, ok := srv.playerForSession(session)
+		if !ok {
+			t.Fatalf("Expected %s to join", name)
+		}
+		joined = append(joined, player)
+	}
+	
+	alice, bob := joined[0], joined[1]
+	if alice.Color == bob.Color {
+		t.Errorf("Expected distinct colors, both got 0x%08X", alice.Color)
+	}
+	
+	// Everyone, the newcomer included, is sent the newcomer's color
+	expected := protocol.BuildSetPlayerColorRPC(bob.ID, bob.Color)
+	for _, player := range []*Player{watcher, alice, bob} {
+		found := false
+		for _, rpc := range queuedRPCs(player.Session) {
+			found = found || bytes.Equal(rpc, expected)
+		}
+		if !found {
+			t.Errorf("Expected player %d to get Bob's color", player.ID)
+		}
+	}
+}
+
+func TestSetPlayerColorOverridesAndBroadcasts(t *testing.T) {
+	srv := newTestServer()
+	alice := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	bob := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	
+	if err := srv.SetPlayerColor(bob.ID, protocol.ColorRed); err != nil {
+		t.Fatalf("SetPlayerColor failed: %v", err)
+	}
+	if bob.Color != protocol.ColorRed {
+		t.Errorf("Expected stored color red, got 0x%08X", bob.Color)
+	}
+	
+	expected := protocol.BuildSetPlayerColorRPC(bob.ID, protocol.ColorRed)
+	for _, player := range []*Player{alice, bob} {
+		if rpcs := queuedRPCs(player.Session); len(rpcs) != 1 || !bytes.Equal(rpcs[0], expected) {
+			t.Errorf("Expected player %d to get the new color, got %02X", player.ID, rpcs)
+		}
+	}
+	
+	if err := srv.SetPlayerColor(9, protocol.ColorRed); err == nil {
+		t.Error("Expected error for unknown player")
+	}
+}
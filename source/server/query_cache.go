@@ -0,0 +1,65 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultQueryCacheTTL lets server list scans reuse query responses instead
+// of rebuilding them for every packet
+const DefaultQueryCacheTTL = 250 * time.Millisecond
+
+// queryHeaderSize is "SAMP" + IP + port + opcode, echoed from each request
+const queryHeaderSize = 11
+
+// queryCache holds the last response body per query opcode
+type queryCache struct {
+	mu      sync.Mutex
+	entries map[byte]queryCacheEntry
+}
+
+type queryCacheEntry struct {
+	body    []byte // response without the request header
+	builtAt time.Time
+}
+
+// cachedQueryResponse answers a query from the cache, rebuilding the body with
+// build at most once per QueryCacheTTL. The header is always taken from data.
+func (rh *RakNetHandler) cachedQueryResponse(data []byte, build func([]byte) []byte) []byte {
+	ttl := rh.server.QueryCacheTTL
+	if ttl <= 0 {
+		return build(data)
+	}
+	
+	opcode := data[queryHeaderSize-1]
+	now := rh.clock.Now()
+	
+	rh.queryCache.mu.Lock()
+	entry, cached := rh.queryCache.entries[opcode]
+	rh.queryCache.mu.Unlock()
+	
+	// Build without the cache lock; build takes server locks of its own
+	if !cached || now.Sub(entry.builtAt) >= ttl {
+		entry = queryCacheEntry{body: build(data)[queryHeaderSize:], builtAt: now}
+		rh.queryCache.mu.Lock()
+		if rh.queryCache.entries == nil {
+			rh.queryCache.entries = make(map[byte]queryCacheEntry)
+		}
+		rh.queryCache.entries[opcode] = entry
+		rh.queryCache.mu.Unlock()
+	}
+	
+	response := make([]byte, 0, queryHeaderSize+len(entry.body))
+	response = append(response, data[:queryHeaderSize]...)
+	return append(response, entry.body...)
+}
+
+// invalidateQueryCache drops cached responses after a change players would see
+func (s *Server) invalidateQueryCache() {
+	if s.raknet == nil {
+		return
+	}
+	s.raknet.queryCache.mu.Lock()
+	s.raknet.queryCache.entries = nil
+	s.raknet.queryCache.mu.Unlock()
+}
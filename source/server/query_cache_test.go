@@ -0,0 +1,63 @@
+package server
+
+import (
+	"bytes"
+	"samp-server-go/source/protocol"
+	"testing"
+	"time"
+)
+
+func TestInfoQueryReusesCachedResponse(t *testing.T) {
+	srv := newTestServer()
+	clock := protocol.NewFakeClock(time.Unix(1700000000, 0))
+	srv.raknet.SetClock(clock)
+	srv.QueryCacheTTL = 500 * time.Millisecond
+	srv.ServerName = "First"
+	
+	builds := 0
+	build := func(data []byte) []byte {
+		builds++
+		return srv.raknet.buildSAMPInfoResponse(data)
+	}
+	
+	first := srv.raknet.cachedQueryResponse(sampQuery('i'), build)
+	srv.ServerName = "Second"
+	clock.Advance(100 * time.Millisecond)
+	second := srv.raknet.cachedQueryResponse(sampQuery('i'), build)
+	
+	if builds != 1 || !bytes.Equal(first, second) {
+		t.Fatalf("Expected the second query to reuse the cached bytes, got %d builds", builds)
+	}
+	
+	// The header still comes from each request
+	query := sampQuery('i')
+	query[4] = 10
+	if response := srv.raknet.cachedQueryResponse(query, build); !bytes.Equal(response[:11], query) {
+		t.Errorf("Expected the request header echoed, got % X", response[:11])
+	}
+	
+	clock.Advance(500 * time.Millisecond)
+	third := srv.raknet.cachedQueryResponse(sampQuery('i'), build)
+	if builds != 2 || !bytes.Contains(third, []byte("Second")) {
+		t.Errorf("Expected a rebuild after the TTL, got %d builds", builds)
+	}
+}
+
+func TestQueryCacheInvalidatedOnJoin(t *testing.T) {
+	srv := newTestServer()
+	srv.raknet.SetClock(protocol.NewFakeClock(time.Unix(1700000000, 0)))
+	
+	builds := 0
+	build := func(data []byte) []byte {
+		builds++
+		return srv.raknet.buildSAMPRulesResponse(data)
+	}
+	
+	srv.raknet.cachedQueryResponse(sampQuery('r'), build)
+	srv.AddPlayer(addTestSession(srv, 54000, protocol.STATE_CONNECTED))
+	srv.raknet.cachedQueryResponse(sampQuery('r'), build)
+	
+	if builds != 2 {
+		t.Errorf("Expected a join to invalidate the cache, got %d builds", builds)
+	}
+}
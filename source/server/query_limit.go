@@ -0,0 +1,147 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultQueryRateLimit is how many queries one IP may send per second. A
+// server browser refresh sends one each of 'i', 'r', 'c' and 'p', so this
+// leaves room for several refreshes a second from the same address.
+const DefaultQueryRateLimit = 20
+
+// DefaultQueryBudget is how many queries per second are answered across all
+// IPs, so a reflection spread over many addresses still gets little out of us
+const DefaultQueryBudget = 500
+
+// DefaultMaxLimiterSources is how many IPs the query and connect limiters
+// track at once. A spoofed flood could otherwise grow their tables without
+// bound between prunes.
+const DefaultMaxLimiterSources = 4096
+
+// queryLimiter counts queries per source IP, and in total, in one-second
+// windows. Responses are bigger than requests, so without it a spoofed-source
+// flood would have us amplify traffic towards the victim.
+type queryLimiter struct {
+	mu      sync.Mutex
+	sources map[string]*querySource // key: IP only, spoofed floods vary the port
+	swept   time.Time               // last time a full sources table was swept
+	total   querySource             // all queries, against QueryBudget
+}
+
+type querySource struct {
+	windowStart time.Time
+	count       int
+	dropped     int // over-limit queries in this window
+}
+
+// allowQuery reports whether addr is within QueryRateLimit. The first query
+// dropped in a window is logged as suspected abuse.
+func (rh *RakNetHandler) allowQuery(addr *net.UDPAddr) bool {
+	limit := rh.server.QueryRateLimit
+	if limit <= 0 {
+		return true
+	}
+	
+	now := rh.clock.Now()
+	ip := addr.IP.String()
+	
+	rh.queryLimiter.mu.Lock()
+	defer rh.queryLimiter.mu.Unlock()
+	
+	if rh.queryLimiter.sources == nil {
+		rh.queryLimiter.sources = make(map[string]*querySource)
+	}
+	source, exists := rh.queryLimiter.sources[ip]
+	if !exists && !rh.roomForQuerySource(now) {
+		return false
+	}
+	if !exists || now.Sub(source.windowStart) >= time.Second {
+		if exists && source.dropped > 0 {
+			log.Printf("🛡️ Query flood from %s: dropped %d queries over the limit of %d/s", ip, source.dropped, limit)
+		}
+		source = &querySource{windowStart: now}
+		rh.queryLimiter.sources[ip] = source
+	}
+	
+	source.count++
+	if source.count > limit {
+		source.dropped++
+		if source.dropped == 1 {
+			log.Printf("🛡️ Suspected query amplification abuse from %s: over %d queries/s, dropping", ip, limit)
+		}
+		return false
+	}
+	return rh.withinQueryBudget(now)
+}
+
+// roomForQuerySource reports whether another IP can be tracked. A full table
+// is swept of finished windows at most once a second; if it is still full the
+// query is dropped. Caller holds queryLimiter.mu.
+func (rh *RakNetHandler) roomForQuerySource(now time.Time) bool {
+	max := rh.server.MaxLimiterSources
+	if max <= 0 || len(rh.queryLimiter.sources) < max {
+		return true
+	}
+	if now.Sub(rh.queryLimiter.swept) >= time.Second {
+		rh.queryLimiter.swept = now
+		for ip, source := range rh.queryLimiter.sources {
+			if now.Sub(source.windowStart) >= time.Second {
+				delete(rh.queryLimiter.sources, ip)
+			}
+		}
+	}
+	return len(rh.queryLimiter.sources) < max
+}
+
+// withinQueryBudget counts a query against QueryBudget. Caller holds
+// queryLimiter.mu.
+func (rh *RakNetHandler) withinQueryBudget(now time.Time) bool {
+	budget := rh.server.QueryBudget
+	if budget <= 0 {
+		return true
+	}
+	
+	total := &rh.queryLimiter.total
+	if now.Sub(total.windowStart) >= time.Second {
+		if total.dropped > 0 {
+			log.Printf("🛡️ Query flood: dropped %d queries over the budget of %d/s", total.dropped, budget)
+		}
+		*total = querySource{windowStart: now}
+	}
+	
+	total.count++
+	if total.count <= budget {
+		return true
+	}
+	
+	total.dropped++
+	if total.dropped == 1 {
+		log.Printf("🛡️ Over %d queries/s from all sources, dropping", budget)
+	}
+	return false
+}
+
+// pruneQueryLimiter forgets sources whose window ended before cutoff
+func (rh *RakNetHandler) pruneQueryLimiter(cutoff time.Time) {
+	rh.queryLimiter.mu.Lock()
+	defer rh.queryLimiter.mu.Unlock()
+	
+	for ip, source := range rh.queryLimiter.sources {
+		if source.windowStart.Before(cutoff) {
+			delete(rh.queryLimiter.sources, ip)
+		}
+	}
+}
+
+// writeQueryResponse sends a query response unless it is larger than
+// MaxQueryResponseSize
+func (rh *RakNetHandler) writeQueryResponse(response []byte, addr *net.UDPAddr) (int, error) {
+	if max := rh.server.MaxQueryResponseSize; max > 0 && len(response) > max {
+		return 0, fmt.Errorf("response of %d bytes exceeds cap of %d", len(response), max)
+	}
+	return rh.conn.WriteToUDP(response, addr)
+}
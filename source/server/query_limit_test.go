@@ -0,0 +1,108 @@
+package server
+
+import (
+	"net"
+	"samp-server-go/source/protocol"
+	"testing"
+	"time"
+)
+
+func TestQueryRateLimitThrottlesFlood(t *testing.T) {
+	srv := newTestServer()
+	clock := protocol.NewFakeClock(time.Unix(1700000000, 0))
+	srv.raknet.SetClock(clock)
+	srv.QueryRateLimit = 10
+	
+	flooder := &net.UDPAddr{IP: net.IPv4(203, 0, 113, 7), Port: 40000}
+	browser := &net.UDPAddr{IP: net.IPv4(198, 51, 100, 2), Port: 40000}
+	
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		// Spoofed floods vary the source port; the limit is per IP
+		flooder.Port = 40000 + i
+		if srv.raknet.allowQuery(flooder) {
+			allowed++
+		}
+	}
+	if allowed != 10 {
+		t.Errorf("Expected 10 of 50 flood queries allowed, got %d", allowed)
+	}
+	
+	// A browser refreshing (i, r, c, p) once a second is never throttled
+	for refresh := 0; refresh < 5; refresh++ {
+		for q := 0; q < 4; q++ {
+			if !srv.raknet.allowQuery(browser) {
+				t.Fatalf("Refresh %d: browser query %d throttled", refresh, q)
+			}
+		}
+		clock.Advance(time.Second)
+	}
+	
+	// The flooder gets a fresh allowance in the next window
+	if !srv.raknet.allowQuery(flooder) {
+		t.Error("Expected the flooder's next window to allow queries again")
+	}
+}
+
+func TestQueryResponseSizeCap(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	srv.MaxQueryResponseSize = 16
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 40000}
+	
+	if _, err := srv.raknet.writeQueryResponse(make([]byte, 17), addr); err == nil {
+		t.Error("Expected a response over the cap to be refused")
+	}
+	if _, err := srv.raknet.writeQueryResponse(make([]byte, 16), addr); err != nil {
+		t.Errorf("Expected a response at the cap to be sent, got %v", err)
+	}
+}
+
+func TestQueryBudgetCapsDistributedFlood(t *testing.T) {
+	srv := newTestServer()
+	clock := protocol.NewFakeClock(time.Unix(1700000000, 0))
+	srv.raknet.SetClock(clock)
+	srv.QueryBudget = 30
+	
+	// Every source stays under its own limit
+	allowed := 0
+	for i := 0; i < 100; i++ {
+		if srv.raknet.allowQuery(&net.UDPAddr{IP: net.IPv4(203, 0, 113, byte(i)), Port: 40000}) {
+			allowed++
+		}
+	}
+	if allowed != 30 {
+		t.Errorf("Expected 30 of 100 queries within the budget, got %d", allowed)
+	}
+	
+	clock.Advance(time.Second)
+	if !srv.raknet.allowQuery(&net.UDPAddr{IP: net.IPv4(198, 51, 100, 2), Port: 40000}) {
+		t.Error("Expected the budget to refill in the next window")
+	}
+}
+
+func TestQueryLimiterTracksBoundedSources(t *testing.T) {
+	srv := newTestServer()
+	clock := protocol.NewFakeClock(time.Unix(1700000000, 0))
+	srv.raknet.SetClock(clock)
+	srv.QueryBudget = 0
+	srv.MaxLimiterSources = 8
+	
+	for i := 0; i < 50; i++ {
+		srv.raknet.allowQuery(&net.UDPAddr{IP: net.IPv4(203, 0, 113, byte(i)), Port: 40000})
+	}
+	if n := len(srv.raknet.queryLimiter.sources); n != 8 {
+		t.Fatalf("Expected 8 tracked sources, got %d", n)
+	}
+	if srv.raknet.allowQuery(&net.UDPAddr{IP: net.IPv4(198, 51, 100, 2), Port: 40000}) {
+		t.Error("Expected a new source dropped while the table is full")
+	}
+	
+	// Finished windows make room again
+	clock.Advance(time.Second)
+	if !srv.raknet.allowQuery(&net.UDPAddr{IP: net.IPv4(198, 51, 100, 2), Port: 40000}) {
+		t.Error("Expected a new source allowed once old windows ended")
+	}
+	if n := len(srv.raknet.queryLimiter.sources); n != 1 {
+		t.Errorf("Expected the sweep to leave only the new source, got %d", n)
+	}
+}
@@ -3,13 +3,16 @@ package server
 import (
 	"bytes"
 	crypto_rand "crypto/rand"
+	"crypto/subtle"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"log"
 	"net"
 	"samp-server-go/source/protocol"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -1640,13 +1643,23 @@ type RakNetHandler struct {
 	sessions      map[string]*protocol.Session // key: "ip:port"
 	sessionsByIP  map[string]*protocol.Session // key: "ip" only (for port migration)
 	sessionsByGUID map[uint64]*protocol.Session // key: client GUID (for session migration)
-	conn          *net.UDPConn
+	conn          *socketSet                    // local sockets; replies follow the arrival socket
 	server        *Server                       // Reference to server for config access
 	mu            sync.RWMutex
 	serverGUID    uint64
 	cookieTable   map[string]uint32 // key: "ip:port", value: cookie
+	mtuProbes     map[string]mtuProbe // key: "ip:port", OPEN_CONNECTION_REQUEST_1 probes before OCR2
 	onPacket      func(*protocol.Session, *protocol.RakNetPacket)
 	running       bool
+	clock         protocol.Clock // time source for timeouts and cooldowns (see SetClock)
+	cipher        PacketCipher   // optional payload obfuscation (see SetCipher)
+	queryCache    queryCache     // recent 'i' and 'r' query responses
+	queryLimiter  queryLimiter   // per-IP query rate (see allowQuery)
+	connectLimiter connectLimiter // per-IP connection attempts (see allowConnectAttempt)
+	reliability   protocol.ReliabilityCounters // shared by every session (see Stats)
+	sessionDatagrams atomic.Uint64               // datagrams sessions wrote themselves (see Stats)
+	halfOpen      atomic.Int64                 // sessions that have not finished the handshake (see allowConnectAttempt)
+	pendingDisconnects map[*protocol.Session]pendingDisconnect // notified, waiting for the ACK (see DisconnectSession)
 }
 
 func NewRakNetHandler(conn *net.UDPConn, server *Server) *RakNetHandler {
@@ -1654,11 +1667,89 @@ func NewRakNetHandler(conn *net.UDPConn, server *Server) *RakNetHandler {
 		sessions:       make(map[string]*protocol.Session),
 		sessionsByIP:   make(map[string]*protocol.Session),
 		sessionsByGUID: make(map[uint64]*protocol.Session),
-		conn:           conn,
+		conn:           newSocketSet(conn),
 		server:         server,
 		serverGUID:     serverGUID, // Use package-level GUID
 		cookieTable:    make(map[string]uint32),
+		mtuProbes:      make(map[string]mtuProbe),
+		pendingDisconnects: make(map[*protocol.Session]pendingDisconnect),
 		running:        true,
+		clock:          protocol.RealClock,
+	}
+}
+
+// SetClock replaces the time source. Sessions created afterwards share it.
+func (rh *RakNetHandler) SetClock(clock protocol.Clock) {
+	rh.clock = clock
+}
+
+// newSession creates a session on the handler's clock, bound to the local
+// socket the client's packet arrived on
+func (rh *RakNetHandler) newSession(addr *net.UDPAddr, mtu uint16) *protocol.Session {
+	session := protocol.NewSessionWithClock(addr, mtu, rh.clock)
+	session.Conn = rh.conn.connFor(addr)
+	session.Counters = &rh.reliability
+	session.DatagramsSent = &rh.sessionDatagrams
+	session.Cipher = rh.cipher
+	session.HalfOpen = &rh.halfOpen
+	session.SetState(session.State)
+	return session
+}
+
+// addSession creates a session for addr in state and phase and registers it
+func (rh *RakNetHandler) addSession(addr *net.UDPAddr, state int, phase protocol.ConnectPhase) *protocol.Session {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+	
+	session := rh.newSession(addr, protocol.DEFAULT_MTU_SIZE)
+	session.SetState(state)
+	session.ConnectPhase = phase
+	rh.sessions[addr.String()] = session
+	return session
+}
+
+// registerSession makes session reachable under another address key
+func (rh *RakNetHandler) registerSession(key string, session *protocol.Session) {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+	rh.sessions[key] = session
+}
+
+// rebindSession moves session to the port its client switched to
+func (rh *RakNetHandler) rebindSession(session *protocol.Session, addr *net.UDPAddr) {
+	rh.registerSession(addr.String(), session)
+	
+	session.Mu.Lock()
+	defer session.Mu.Unlock()
+	session.Addr = addr
+	session.Conn = rh.conn.connFor(addr)
+}
+
+// gameEntrySessionFor returns a session from ip that was already sent game
+// entry, or nil
+func (rh *RakNetHandler) gameEntrySessionFor(ip string) *protocol.Session {
+	rh.mu.RLock()
+	defer rh.mu.RUnlock()
+	for _, sess := range rh.sessions {
+		if sess.Addr.IP.String() == ip && sess.ReachedPhase(protocol.PhaseGameEntrySent) {
+			return sess
+		}
+	}
+	return nil
+}
+
+// sessionConn returns the local socket writes to a session go out on
+func (rh *RakNetHandler) sessionConn(session *protocol.Session) *net.UDPConn {
+	if session.Conn != nil {
+		return session.Conn
+	}
+	return rh.conn.connFor(session.Addr)
+}
+
+// writeToSession writes a raw datagram to a session on its own socket
+func (rh *RakNetHandler) writeToSession(session *protocol.Session, data []byte) {
+	if conn := rh.sessionConn(session); conn != nil {
+		rh.conn.writeOn(conn, data, session.Addr)
 	}
 }
 
@@ -1667,12 +1758,11 @@ func (rh *RakNetHandler) SetPacketHandler(handler func(*protocol.Session, *proto
 }
 
 func (rh *RakNetHandler) HandlePacket(data []byte, addr *net.UDPAddr) {
-	if len(data) == 0 {
+	switch classifyPacket(data) {
+	case packetEmpty:
 		return
-	}
-	
-	// Check for SA-MP query packets (starts with "SAMP")
-	if len(data) >= 11 && string(data[0:4]) == "SAMP" {
+	case packetQuery:
+		// Malformed queries are dropped there
 		rh.handleSAMPQuery(data, addr)
 		return
 	}
@@ -1680,14 +1770,23 @@ func (rh *RakNetHandler) HandlePacket(data []byte, addr *net.UDPAddr) {
 	packetID := data[0]
 	sessionKey := addr.String()
 	
-	// Check if this is a RakNet data packet (bit 7 set)
-	isDataPacket := (packetID & 0x80) != 0
+	// Check if this is a RakNet data packet (0x80-0x8F); 0xA0-0xAF and
+	// 0xC0-0xCF are NACKs and ACKs
+	isDataPacket := packetID&0xF0 == protocol.ID_DATAGRAM
 	
 	// Get session
 	rh.mu.RLock()
 	session, sessionExists := rh.sessions[sessionKey]
 	rh.mu.RUnlock()
 	
+	// Anything but a ping or a stray datagram from an address without a
+	// session may open one
+	if !sessionExists && packetID != protocol.ID_UNCONNECTED_PING &&
+		packetID != protocol.ID_UNCONNECTED_PING_OPEN_CONNECTIONS &&
+		!isStrayDatagram(data) && !rh.allowConnectAttempt(addr) {
+		return
+	}
+	
 	// ============================================================
 	// SIMPLIFIED PACKET DISPATCHER (v5 - Complete Refactor)
 	// ============================================================
@@ -1746,7 +1845,7 @@ func (rh *RakNetHandler) HandlePacket(data []byte, addr *net.UDPAddr) {
 		ack := protocol.NewACK()
 		ack.Packets = append(ack.Packets, 0)
 		ackData := ack.Encode()
-		rh.conn.WriteToUDP(ackData, session.Addr)
+		rh.writeToSession(session, ackData)
 		return
 	}
 	
@@ -1758,7 +1857,7 @@ func (rh *RakNetHandler) HandlePacket(data []byte, addr *net.UDPAddr) {
 	if sessionExists {
 		session.Mu.RLock()
 		state := session.State
-		gameEntrySent := session.GameEntrySent
+		gameEntrySent := session.ConnectPhase >= protocol.PhaseGameEntrySent
 		session.Mu.RUnlock()
 		
 		if state == protocol.STATE_IN_GAME || gameEntrySent {
@@ -1794,8 +1893,8 @@ func (rh *RakNetHandler) HandlePacket(data []byte, addr *net.UDPAddr) {
 				
 				// Update session state
 				session.Mu.Lock()
-				session.State = protocol.STATE_CONNECTING
-				session.LastReceiveTime = time.Now()
+				session.SetState(protocol.STATE_CONNECTING)
+				session.LastReceiveTime = rh.clock.Now()
 				session.Mu.Unlock()
 				
 				return
@@ -1826,15 +1925,8 @@ func (rh *RakNetHandler) HandlePacket(data []byte, addr *net.UDPAddr) {
 			if existingSession != nil {
 				log.Printf("🔁 Rebinding session from %s to %s", oldSessionKey, addr)
 				
-				// Update session address to new port
-				existingSession.Mu.Lock()
-				existingSession.Addr = addr
-				existingSession.Mu.Unlock()
-				
-				// Register new port in sessions map
-				rh.mu.Lock()
-				rh.sessions[sessionKey] = existingSession
-				rh.mu.Unlock()
+				// Update session address to new port and register it
+				rh.rebindSession(existingSession, addr)
 				
 				log.Printf("✅ Session rebound to new port - NO 0x1A sent, NO new session created")
 				log.Printf("🎉 Client port switch handled - session stable")
@@ -1854,11 +1946,7 @@ func (rh *RakNetHandler) HandlePacket(data []byte, addr *net.UDPAddr) {
 			rh.conn.WriteToUDP(cookieResponse, addr)
 			
 			// Create session for this port
-			rh.mu.Lock()
-			newSession := protocol.NewSession(addr, protocol.DEFAULT_MTU_SIZE)
-			newSession.State = protocol.STATE_HANDSHAKE_SENT
-			rh.sessions[sessionKey] = newSession
-			rh.mu.Unlock()
+			rh.addSession(addr, protocol.STATE_HANDSHAKE_SENT, protocol.PhaseNone)
 			
 			log.Printf("✅ Created new session and sent 0x1A to %s", addr)
 			return
@@ -1879,34 +1967,17 @@ func (rh *RakNetHandler) HandlePacket(data []byte, addr *net.UDPAddr) {
 			
 			// Cari session berdasarkan IP saja (ignore port)
 			ip := addr.IP.String()
-			var existingSession *protocol.Session
-			
-			rh.mu.RLock()
-			for _, sess := range rh.sessions {
-				if sess.Addr.IP.String() == ip && sess.GetGameEntrySent() {
-					existingSession = sess
-					log.Printf("✅ Found existing session for IP %s (original port: %d)", ip, sess.Addr.Port)
-					break
-				}
-			}
-			rh.mu.RUnlock()
-			
+			existingSession := rh.gameEntrySessionFor(ip)
 			if existingSession == nil {
 				log.Printf("⚠️ 0x28 from %s but no active session found for IP %s", addr, ip)
 				return
 			}
+			log.Printf("✅ Found existing session for IP %s (original port: %d)", ip, existingSession.Addr.Port)
 			
 			// Register port baru ke session yang ada
-			rh.mu.Lock()
-			rh.sessions[addr.String()] = existingSession
-			rh.mu.Unlock()
+			rh.rebindSession(existingSession, addr)
 			log.Printf("✅ Associated new port %d to session for IP %s", addr.Port, ip)
 			
-			// Update session address to new port
-			existingSession.Mu.Lock()
-			existingSession.Addr = addr
-			existingSession.Mu.Unlock()
-			
 			// Send ACK for the 0x28 packet to new port
 			ack := protocol.NewACK()
 			ack.Packets = append(ack.Packets, 0) // ACK with dummy sequence
@@ -1926,73 +1997,54 @@ func (rh *RakNetHandler) HandlePacket(data []byte, addr *net.UDPAddr) {
 		// Update last receive time
 		session.UpdateLastReceiveTime()
 		
-		// Check if join response already sent
-		if session.GetJoinResponseSent() {
-			// Client is resending 0x28 - likely didn't receive all packets
-			// Resend e3:21 as spawn trigger
-			log.Printf("⏩ Received 0x28 resend from %s (%d bytes) - sending e3:21 spawn trigger", addr, len(data))
+		// 0x28 diterima pertama kali.
+		// Setelah fix 0x8A: game entry harusnya SUDAH dikirim.
+		// 0x28 dari client = konfirmasi + bisa dari port baru.
+		
+		session.Mu.RLock()
+		gameEntrySent := session.ConnectPhase >= protocol.PhaseGameEntrySent
+		currentState := session.State
+		session.Mu.RUnlock()
+		
+		// ACK dulu
+		ack := protocol.NewACK()
+		ack.Packets = append(ack.Packets, 0)
+		rh.conn.WriteToUDP(ack.Encode(), addr)
+		log.Printf("✅ Sent ACK for 0x28")
+		
+		// CRITICAL: Don't resend anything if already in game
+		if currentState >= protocol.STATE_IN_GAME && gameEntrySent {
+			log.Printf("✅ [0x28] Already in game (state=%d) - normal keepalive, no resend", currentState)
+			log.Printf("🎉 Player session stable for %s", addr)
+			return
+		}
+		
+		if gameEntrySent {
+			// Game entry sent but not yet in final state - this is the FIRST 0x28
+			// This means client received spawn sequence and is syncing
+			log.Printf("✅ [0x28] First confirmation after spawn sequence - client syncing")
+			log.Printf("🎉 Client sync received! Waiting for stable state before world streaming...")
 			
-			// Send ACK for the 0x28 packet
-			ack := protocol.NewACK()
-			ack.Packets = append(ack.Packets, 0) // ACK with dummy sequence
-			ackData := ack.Encode()
-			rh.conn.WriteToUDP(ackData, addr)
-			log.Printf("✅ Sent ACK for 0x28 resend")
+			// Update state to IN_GAME
+			session.Mu.Lock()
+			session.SetState(protocol.STATE_IN_GAME)
+			session.Mu.Unlock()
 			
-			// Resend e3:21 spawn trigger
-			e321 := []byte{0xe3, 0x21, 0x00}
-			rh.conn.WriteToUDP(e321, addr)
-			log.Printf("✅ Resent e3:21 spawn trigger")
+			// Start world streaming after a small delay to let client stabilize
+			go func() {
+				time.Sleep(300 * time.Millisecond)
+				log.Printf("📤 Starting world streaming for %s after client sync...", addr)
+				rh.sendWorldStreamingPackets(session)
+			}()
 		} else {
-			// 0x28 diterima pertama kali.
-			// Setelah fix 0x8A: game entry harusnya SUDAH dikirim.
-			// 0x28 dari client = konfirmasi + bisa dari port baru.
-			
-			session.Mu.RLock()
-			gameEntrySent := session.GameEntrySent
-			currentState := session.State
-			session.Mu.RUnlock()
-			
-			// ACK dulu
-			ack := protocol.NewACK()
-			ack.Packets = append(ack.Packets, 0)
-			rh.conn.WriteToUDP(ack.Encode(), addr)
-			log.Printf("✅ Sent ACK for 0x28")
-			
-			// CRITICAL: Don't resend anything if already in game
-			if currentState >= protocol.STATE_IN_GAME && gameEntrySent {
-				log.Printf("✅ [0x28] Already in game (state=%d) - normal keepalive, no resend", currentState)
-				log.Printf("🎉 Player session stable for %s", addr)
-				return
-			}
-			
-			if gameEntrySent {
-				// Game entry sent but not yet in final state - this is the FIRST 0x28
-				// This means client received spawn sequence and is syncing
-				log.Printf("✅ [0x28] First confirmation after spawn sequence - client syncing")
-				log.Printf("🎉 Client sync received! Waiting for stable state before world streaming...")
-				
-				// Update state to IN_GAME
-				session.Mu.Lock()
-				session.State = protocol.STATE_IN_GAME
-				session.Mu.Unlock()
-				
-				// Start world streaming after a small delay to let client stabilize
-				go func() {
-					time.Sleep(300 * time.Millisecond)
-					log.Printf("📤 Starting world streaming for %s after client sync...", addr)
-					rh.sendWorldStreamingPackets(session)
-				}()
-			} else {
-				// Game entry not sent yet - this should not happen in normal flow
-				// 0x28 should only come AFTER 0x8A triggers game entry
-				log.Printf("⚠️ [0x28] Received before game entry sent - ignoring (waiting for 0x8A)")
-			}
+			// Game entry not sent yet - this should not happen in normal flow
+			// 0x28 should only come AFTER 0x8A triggers game entry
+			log.Printf("⚠️ [0x28] Received before game entry sent - ignoring (waiting for 0x8A)")
 		}
 		return
 	}
 	
-	// Check if it's a data packet (bit 7 set = 0x80-0x8F)
+	// Check if it's a data packet (0x80-0x8F)
 	// 0x80, 0x82, 0x84, 0x86, 0x88, 0x8A, 0x8C, 0x8E are all RakNet data packets
 	// Note: isDataPacket already declared above, reuse it here
 	
@@ -2000,10 +2052,10 @@ func (rh *RakNetHandler) HandlePacket(data []byte, addr *net.UDPAddr) {
 		// Update session last receive time
 		rh.mu.RLock()
 		if sess, exists := rh.sessions[addr.String()]; exists {
-			sess.LastReceiveTime = time.Now()
+			sess.LastReceiveTime = rh.clock.Now()
 			// Upgrade state if receiving data packets
 			if sess.State == protocol.STATE_HANDSHAKE_SENT {
-				sess.State = protocol.STATE_CONNECTING
+				sess.SetState(protocol.STATE_CONNECTING)
 				log.Printf("Session %s upgraded to CONNECTING (received data packet)", addr.String())
 			}
 		}
@@ -2030,31 +2082,13 @@ func (rh *RakNetHandler) HandlePacket(data []byte, addr *net.UDPAddr) {
 		if !exists {
 			// Check if this IP has an active session with game entry sent
 			clientIP := addr.IP.String()
-			var hasActiveSession bool
-			rh.mu.RLock()
-			for _, sess := range rh.sessions {
-				if sess.Addr.IP.String() == clientIP {
-					sess.Mu.RLock()
-					gameEntrySent := sess.GameEntrySent
-					sess.Mu.RUnlock()
-					if gameEntrySent {
-						hasActiveSession = true
-						break
-					}
-				}
-			}
-			rh.mu.RUnlock()
+			hasActiveSession := rh.gameEntrySessionFor(clientIP) != nil
 			
 			if hasActiveSession {
 				log.Printf("✅ [0x00] Active session for IP %s — sending 0x1A to new port %d", clientIP, addr.Port)
 				
 				// Create session for new port
-				rh.mu.Lock()
-				newSession := protocol.NewSession(addr, protocol.DEFAULT_MTU_SIZE)
-				newSession.State = protocol.STATE_HANDSHAKE_SENT
-				newSession.GameEntrySent = true // Inherit state
-				rh.sessions[addr.String()] = newSession
-				rh.mu.Unlock()
+				rh.addSession(addr, protocol.STATE_HANDSHAKE_SENT, protocol.PhaseGameEntrySent) // Inherit state
 				
 				// Send 0x1A Open Connection Reply 2
 				reply := []byte{
@@ -2093,8 +2127,8 @@ func (rh *RakNetHandler) HandlePacket(data []byte, addr *net.UDPAddr) {
 			
 			// Update session state
 			session.Mu.Lock()
-			session.State = protocol.STATE_CONNECTING
-			session.LastReceiveTime = time.Now()
+			session.SetState(protocol.STATE_CONNECTING)
+			session.LastReceiveTime = rh.clock.Now()
 			session.Mu.Unlock()
 			
 			log.Printf("✅ Session %s upgraded to CONNECTING", addr)
@@ -2123,7 +2157,7 @@ func (rh *RakNetHandler) HandlePacket(data []byte, addr *net.UDPAddr) {
 		// CRITICAL: Trigger streaming if state=READY and not yet sent
 		session.Mu.RLock()
 		state := session.State
-		gameEntrySent := session.GameEntrySent
+		gameEntrySent := session.ConnectPhase >= protocol.PhaseGameEntrySent
 		session.Mu.RUnlock()
 		
 		if state == protocol.STATE_READY && !gameEntrySent {
@@ -2131,7 +2165,7 @@ func (rh *RakNetHandler) HandlePacket(data []byte, addr *net.UDPAddr) {
 			
 			// Set to IN_GAME state before streaming
 			session.Mu.Lock()
-			session.State = protocol.STATE_IN_GAME
+			session.SetState(protocol.STATE_IN_GAME)
 			session.Mu.Unlock()
 			
 			// All packets now sent in sendPostStreamingSequence
@@ -2151,7 +2185,7 @@ func (rh *RakNetHandler) HandlePacket(data []byte, addr *net.UDPAddr) {
 			}
 			// Use channel 255 for keepalive counter
 			keepaliveCounter := session.ChannelOrderIndex[255]
-			session.ChannelOrderIndex[255]++
+			session.ChannelOrderIndex[255] = protocol.SeqNext(session.ChannelOrderIndex[255])
 			session.Mu.Unlock()
 			
 			// Send e3 keepalive with counter
@@ -2238,21 +2272,6 @@ func (rh *RakNetHandler) HandlePacket(data []byte, addr *net.UDPAddr) {
 	case 0xA2, 0xA8, 0xAA:
 		// RakNet ACK variants
 		log.Printf("📥 Received 0x%02X (ACK variant) from %s", data[0], addr)
-		if len(data) >= 4 {
-			count := binary.BigEndian.Uint16(data[1:3])
-			offset := 3
-			for i := 0; i < int(count); i++ {
-				if offset+6 > len(data) {
-					break
-				}
-				// Read sequence numbers (3 bytes LITTLE-endian each)
-				minSeq := uint32(data[offset]) | uint32(data[offset+1])<<8 | uint32(data[offset+2])<<16
-				maxSeq := uint32(data[offset+3]) | uint32(data[offset+4])<<8 | uint32(data[offset+5])<<16
-				log.Printf("✅ ACK 0x%02x seq %d-%d from %s", data[0], minSeq, maxSeq, addr)
-				offset += 6
-			}
-		}
-		// Also call handleACK for session tracking
 		rh.handleACK(data, addr)
 	case 0x2A:
 		// ACK untuk 0x0B Open Connection Reply 2
@@ -2268,31 +2287,13 @@ func (rh *RakNetHandler) HandlePacket(data []byte, addr *net.UDPAddr) {
 			// Check if this is 4-byte 0x2A from new port with active session
 			if len(data) == 4 {
 				clientIP := addr.IP.String()
-				var hasActiveSession bool
-				rh.mu.RLock()
-				for _, sess := range rh.sessions {
-					if sess.Addr.IP.String() == clientIP {
-						sess.Mu.RLock()
-						gameEntrySent := sess.GameEntrySent
-						sess.Mu.RUnlock()
-						if gameEntrySent {
-							hasActiveSession = true
-							break
-						}
-					}
-				}
-				rh.mu.RUnlock()
+				hasActiveSession := rh.gameEntrySessionFor(clientIP) != nil
 				
 				if hasActiveSession {
 					log.Printf("✅ [0x2A] 4-byte from new port — sending 0x1A to %s", addr)
 					
 					// Create session for new port
-					rh.mu.Lock()
-					newSession := protocol.NewSession(addr, protocol.DEFAULT_MTU_SIZE)
-					newSession.State = protocol.STATE_HANDSHAKE_SENT
-					newSession.GameEntrySent = true // Inherit state
-					rh.sessions[addr.String()] = newSession
-					rh.mu.Unlock()
+					rh.addSession(addr, protocol.STATE_HANDSHAKE_SENT, protocol.PhaseGameEntrySent) // Inherit state
 					
 					// Send 0x1A Open Connection Reply 2
 					reply := []byte{
@@ -2313,7 +2314,7 @@ func (rh *RakNetHandler) HandlePacket(data []byte, addr *net.UDPAddr) {
 		if exists {
 			session.Mu.Lock()
 			if session.State == protocol.STATE_CONNECTING {
-				session.State = protocol.STATE_READY
+				session.SetState(protocol.STATE_READY)
 				log.Printf("✅ Session %s upgraded to READY after 0x2A ACK", addr)
 			}
 			session.Mu.Unlock()
@@ -2348,32 +2349,14 @@ func (rh *RakNetHandler) HandlePacket(data []byte, addr *net.UDPAddr) {
 		if len(data) == 4 && !exists {
 			// Check if this IP has an active session with game entry sent
 			clientIP := addr.IP.String()
-			var activeSession *protocol.Session
-			rh.mu.RLock()
-			for _, sess := range rh.sessions {
-				if sess.Addr.IP.String() == clientIP {
-					sess.Mu.RLock()
-					gameEntrySent := sess.GameEntrySent
-					sess.Mu.RUnlock()
-					if gameEntrySent {
-						activeSession = sess
-						break
-					}
-				}
-			}
-			rh.mu.RUnlock()
+			activeSession := rh.gameEntrySessionFor(clientIP)
 			
 			if activeSession != nil {
 				// Client opening new port after E3:21 - this is expected
 				log.Printf("✅ [0x0A] New port %d from IP %s with active session - creating session and sending 0x06", addr.Port, clientIP)
 				
 				// Create session for new port
-				rh.mu.Lock()
-				newSession := protocol.NewSession(addr, protocol.DEFAULT_MTU_SIZE)
-				newSession.State = protocol.STATE_UNCONNECTED
-				newSession.GameEntrySent = true // Inherit state
-				rh.sessions[addr.String()] = newSession
-				rh.mu.Unlock()
+				rh.addSession(addr, protocol.STATE_UNCONNECTED, protocol.PhaseGameEntrySent) // Inherit state
 				
 				// Send 0x06 Open Connection Reply 1
 				reply := []byte{
@@ -2412,7 +2395,7 @@ func (rh *RakNetHandler) HandlePacket(data []byte, addr *net.UDPAddr) {
 				session.ChannelOrderIndex = make(map[uint8]uint32)
 			}
 			keepaliveCounter := session.ChannelOrderIndex[255]
-			session.ChannelOrderIndex[255]++
+			session.ChannelOrderIndex[255] = protocol.SeqNext(session.ChannelOrderIndex[255])
 			session.Mu.Unlock()
 			
 			e3Keepalive := []byte{
@@ -2494,7 +2477,7 @@ func (rh *RakNetHandler) handleUnconnectedPing(data []byte, addr *net.UDPAddr) {
 	if exists {
 		session.Mu.RLock()
 		state := session.State
-		gameEntrySent := session.GameEntrySent
+		gameEntrySent := session.ConnectPhase >= protocol.PhaseGameEntrySent
 		session.Mu.RUnlock()
 		
 		// CRITICAL: Jangan ganggu session yang sudah connecting/connected ATAU sudah kirim streaming
@@ -2526,7 +2509,7 @@ sendPong:
 	// Send 0x1C unconnected pong
 	response := protocol.NewEmptyBitStream()
 	response.WriteByte(protocol.ID_UNCONNECTED_PONG)
-	response.WriteUint64(uint64(time.Now().UnixMilli()))
+	response.WriteUint64(uint64(rh.clock.Now().UnixMilli()))
 	response.WriteUint64(serverGUID)
 	response.WriteBytes(protocol.OfflineMessageDataID)
 	
@@ -2551,15 +2534,11 @@ func (rh *RakNetHandler) handleSAMPQuery(data []byte, addr *net.UDPAddr) {
 	log.Printf("Received SA-MP query: %d bytes from %s", len(data), addr.String())
 	log.Printf("Query packet hex: %s", hex.EncodeToString(data))
 	
-	// SA-MP query format: "SAMP" + IP (4 bytes) + Port (2 bytes) + Opcode (1 byte)
-	if len(data) < 11 {
-		log.Printf("Invalid SA-MP query packet (too short)")
+	if err := rh.validateSAMPQuery(data); err != nil {
+		log.Printf("⚠️ Dropping SA-MP query from %s: %v", addr.String(), err)
 		return
 	}
-	
-	// Verify header
-	if string(data[0:4]) != "SAMP" {
-		log.Printf("Invalid SA-MP query header")
+	if !rh.allowQuery(addr) {
 		return
 	}
 	
@@ -2573,6 +2552,8 @@ func (rh *RakNetHandler) handleSAMPQuery(data []byte, addr *net.UDPAddr) {
 		rh.handleSAMPQueryRules(data, addr)
 	case protocol.SAMP_QUERY_PLAYERS:
 		rh.handleSAMPQueryPlayers(data, addr)
+	case protocol.SAMP_QUERY_DETAILED:
+		rh.handleSAMPQueryDetailed(data, addr)
 	case protocol.SAMP_QUERY_PING:
 		rh.handleSAMPQueryPing(data, addr)
 	default:
@@ -2580,11 +2561,60 @@ func (rh *RakNetHandler) handleSAMPQuery(data []byte, addr *net.UDPAddr) {
 	}
 }
 
+// validateSAMPQuery checks the "SAMP" + IP (4 bytes) + Port (2 bytes) + Opcode (1 byte)
+// header. The IP must be the one we are bound to unless we listen on all interfaces.
+func (rh *RakNetHandler) validateSAMPQuery(data []byte) error {
+	if len(data) < 11 {
+		return fmt.Errorf("truncated header (%d bytes)", len(data))
+	}
+	if string(data[0:4]) != sampQueryMagic {
+		return fmt.Errorf("bad magic %q", data[0:4])
+	}
+	
+	if bound := net.ParseIP(rh.server.Host).To4(); bound != nil && !bound.IsUnspecified() {
+		if !net.IP(data[4:8]).Equal(bound) {
+			return fmt.Errorf("address %s does not match %s", net.IP(data[4:8]), bound)
+		}
+	}
+	
+	// 'p' carries 4 extra bytes to echo back; the rest are header only
+	switch data[10] {
+	case protocol.SAMP_QUERY_INFO, protocol.SAMP_QUERY_RULES, protocol.SAMP_QUERY_PLAYERS, protocol.SAMP_QUERY_DETAILED:
+		if len(data) != 11 {
+			return fmt.Errorf("unexpected length %d for '%c'", len(data), data[10])
+		}
+	case protocol.SAMP_QUERY_PING:
+		if len(data) != 15 {
+			return fmt.Errorf("unexpected length %d for 'p'", len(data))
+		}
+	default:
+		return fmt.Errorf("unknown opcode 0x%02X", data[10])
+	}
+	return nil
+}
+
 func (rh *RakNetHandler) handleSAMPQueryInfo(data []byte, addr *net.UDPAddr) {
 	log.Printf("Handling SA-MP info query")
 	
+	response := rh.cachedQueryResponse(data, rh.buildSAMPInfoResponse)
+	
+	n, err := rh.writeQueryResponse(response, addr)
+	if err != nil {
+		log.Printf("Failed to send SA-MP info response: %v", err)
+		return
+	}
+	
+	log.Printf("Sent SA-MP info response: %d bytes", n)
+	log.Printf("Response hex: %s", hex.EncodeToString(response))
+	cfg := rh.server.configSnapshot()
+	log.Printf("📊 INFO QUERY → hostname='%s', gamemode='%s', language='%s', maxplayers=%d", 
+		cfg.ServerName, cfg.GameMode, cfg.Language, rh.server.MaxPlayers)
+}
+
+func (rh *RakNetHandler) buildSAMPInfoResponse(data []byte) []byte {
 	// Response format: "SAMP" + IP + Port + 'i' + password(1) + players(2) + maxplayers(2) + hostname_len(4) + hostname + gamemode_len(4) + gamemode + language_len(4) + language
 	response := make([]byte, 0, 256)
+	cfg := rh.server.configSnapshot()
 	
 	// Header
 	response = append(response, []byte("SAMP")...)
@@ -2592,10 +2622,14 @@ func (rh *RakNetHandler) handleSAMPQueryInfo(data []byte, addr *net.UDPAddr) {
 	response = append(response, 'i')
 	
 	// Password (0 = no password)
-	response = append(response, 0)
+	if rh.server.Password != "" {
+		response = append(response, 1)
+	} else {
+		response = append(response, 0)
+	}
 	
 	// Players (2 bytes, little endian) - current player count
-	playerCount := uint16(0) // TODO: Get actual player count from server
+	playerCount := uint16(rh.server.GetPlayerCount())
 	response = append(response, byte(playerCount), byte(playerCount>>8))
 	
 	// Max players (2 bytes, little endian) - from server config
@@ -2603,46 +2637,50 @@ func (rh *RakNetHandler) handleSAMPQueryInfo(data []byte, addr *net.UDPAddr) {
 	response = append(response, byte(maxPlayers), byte(maxPlayers>>8))
 	
 	// Hostname - from server config
-	hostname := rh.server.ServerName
-	response = append(response, byte(len(hostname)), 0, 0, 0) // 4 bytes length, little endian
-	response = append(response, []byte(hostname)...)
+	hostname := cfg.ServerName
+	response = appendQueryString(response, hostname)
 	
 	// Gamemode - from server config
-	gamemode := rh.server.GameMode
-	response = append(response, byte(len(gamemode)), 0, 0, 0)
-	response = append(response, []byte(gamemode)...)
+	gamemode := cfg.GameMode
+	response = appendQueryString(response, gamemode)
 	
 	// Language - from server config
-	language := rh.server.Language
-	response = append(response, byte(len(language)), 0, 0, 0)
-	response = append(response, []byte(language)...)
+	language := cfg.Language
+	response = appendQueryString(response, language)
+	
+	return response
+}
+
+func (rh *RakNetHandler) handleSAMPQueryRules(data []byte, addr *net.UDPAddr) {
+	log.Printf("Handling SA-MP rules query")
 	
-	n, err := rh.conn.WriteToUDP(response, addr)
+	response := rh.cachedQueryResponse(data, rh.buildSAMPRulesResponse)
+	
+	n, err := rh.writeQueryResponse(response, addr)
 	if err != nil {
-		log.Printf("Failed to send SA-MP info response: %v", err)
+		log.Printf("Failed to send SA-MP rules response: %v", err)
 		return
 	}
 	
-	log.Printf("Sent SA-MP info response: %d bytes", n)
-	log.Printf("Response hex: %s", hex.EncodeToString(response))
-	log.Printf("📊 INFO QUERY → hostname='%s', gamemode='%s', language='%s', maxplayers=%d", 
-		hostname, gamemode, language, maxPlayers)
+	log.Printf("Sent SA-MP rules response: %d bytes", n)
+	cfg := rh.server.configSnapshot()
+	log.Printf("📊 RULES QUERY → mapname=%s, weather=%d, weburl=%s, worldtime=%d:00",
+		cfg.MapName, cfg.Weather, cfg.WebURL, cfg.WorldTime)
 }
 
-func (rh *RakNetHandler) handleSAMPQueryRules(data []byte, addr *net.UDPAddr) {
-	log.Printf("Handling SA-MP rules query")
-	
+func (rh *RakNetHandler) buildSAMPRulesResponse(data []byte) []byte {
 	// Get config from server
-	weather := fmt.Sprintf("%d", rh.server.Weather)
-	worldtime := fmt.Sprintf("%d:00", rh.server.WorldTime)
+	cfg := rh.server.configSnapshot()
+	weather := fmt.Sprintf("%d", cfg.Weather)
+	worldtime := fmt.Sprintf("%d:00", cfg.WorldTime)
 	
 	// Rules map - CRITICAL: version must be "0.3.7-R2" for 0.3.7-R5 client compatibility
 	rules := map[string]string{
 		"lagcomp":   "On",
-		"mapname":   rh.server.MapName,
+		"mapname":   cfg.MapName,
 		"version":   "0.3.7-R2",
 		"weather":   weather,
-		"weburl":    rh.server.WebURL,
+		"weburl":    cfg.WebURL,
 		"worldtime": worldtime,
 	}
 	
@@ -2656,32 +2694,53 @@ func (rh *RakNetHandler) handleSAMPQueryRules(data []byte, addr *net.UDPAddr) {
 	count := uint16(len(rules))
 	response = append(response, byte(count), byte(count>>8))
 	
-	// Add each rule with length-prefixed key and value
-	for key, value := range rules {
-		// Key length + key
-		response = append(response, byte(len(key)))
-		response = append(response, []byte(key)...)
-		
-		// Value length + value
-		response = append(response, byte(len(value)))
-		response = append(response, []byte(value)...)
+	// Add each rule with length-prefixed key and value, sorted like the
+	// SA-MP server so repeated responses are byte-identical
+	keys := make([]string, 0, len(rules))
+	for key := range rules {
+		keys = append(keys, key)
 	}
-	
-	n, err := rh.conn.WriteToUDP(response, addr)
-	if err != nil {
-		log.Printf("Failed to send SA-MP rules response: %v", err)
-		return
+	sort.Strings(keys)
+	for _, key := range keys {
+		response = appendRuleString(response, key)
+		response = appendRuleString(response, rules[key])
 	}
 	
-	log.Printf("Sent SA-MP rules response: %d bytes", n)
-	log.Printf("📊 RULES QUERY → version=%s, mapname=%s, lagcomp=%s, weather=%s, weburl=%s, worldtime=%s",
-		rules["version"], rules["mapname"], rules["lagcomp"], rules["weather"], rules["weburl"], rules["worldtime"])
-	log.Printf("   ⚠️ CRITICAL → Rules weather=%s MUST match InitGame weather=%d", rules["weather"], rh.server.Weather)
+	return response
+}
+
+// appendQueryString appends s with the 4-byte little-endian length prefix
+// used by the info response
+func appendQueryString(response []byte, s string) []byte {
+	response = binary.LittleEndian.AppendUint32(response, uint32(len(s)))
+	return append(response, s...)
+}
+
+// appendRuleString appends s with a 1-byte length prefix, truncating it to
+// 255 bytes so the prefix can't wrap and desync the rest of the response
+func appendRuleString(response []byte, s string) []byte {
+	if len(s) > 255 {
+		s = s[:255]
+	}
+	response = append(response, byte(len(s)))
+	return append(response, s...)
 }
 
 func (rh *RakNetHandler) handleSAMPQueryPlayers(data []byte, addr *net.UDPAddr) {
 	log.Printf("Handling SA-MP players query")
 	
+	response := rh.buildSAMPPlayersResponse(data)
+	
+	n, err := rh.writeQueryResponse(response, addr)
+	if err != nil {
+		log.Printf("Failed to send SA-MP players response: %v", err)
+		return
+	}
+	
+	log.Printf("Sent SA-MP players response: %d bytes", n)
+}
+
+func (rh *RakNetHandler) buildSAMPPlayersResponse(data []byte) []byte {
 	// Response format: "SAMP" + IP + Port + 'c' + players_count(2) + (player_name_len(1) + player_name + score(4))*
 	response := make([]byte, 0, 256)
 	
@@ -2690,16 +2749,60 @@ func (rh *RakNetHandler) handleSAMPQueryPlayers(data []byte, addr *net.UDPAddr)
 	response = append(response, data[4:10]...)
 	response = append(response, 'c')
 	
+	players := rh.server.inGamePlayers()
+	
 	// Players count (2 bytes, little endian)
-	response = append(response, 0, 0) // 0 players
+	count := uint16(len(players))
+	response = append(response, byte(count), byte(count>>8))
+	
+	for _, player := range players {
+		response = append(response, byte(len(player.Name)))
+		response = append(response, []byte(player.Name)...)
+		
+		score := uint32(int32(player.Score))
+		response = append(response, byte(score), byte(score>>8), byte(score>>16), byte(score>>24))
+	}
+	
+	return response
+}
+
+func (rh *RakNetHandler) handleSAMPQueryDetailed(data []byte, addr *net.UDPAddr) {
+	log.Printf("Handling SA-MP detailed players query")
+	
+	response := rh.buildSAMPDetailedResponse(data)
 	
-	n, err := rh.conn.WriteToUDP(response, addr)
+	n, err := rh.writeQueryResponse(response, addr)
 	if err != nil {
-		log.Printf("Failed to send SA-MP players response: %v", err)
+		log.Printf("Failed to send SA-MP detailed players response: %v", err)
 		return
 	}
 	
-	log.Printf("Sent SA-MP players response: %d bytes", n)
+	log.Printf("Sent SA-MP detailed players response: %d bytes", n)
+}
+
+func (rh *RakNetHandler) buildSAMPDetailedResponse(data []byte) []byte {
+	// Response format: "SAMP" + IP + Port + 'd' + players_count(2) + (player_id(1) + player_name_len(1) + player_name + score(4) + ping(4))*
+	response := make([]byte, 0, 256)
+	
+	// Header
+	response = append(response, []byte("SAMP")...)
+	response = append(response, data[4:10]...)
+	response = append(response, 'd')
+	
+	players := rh.server.inGamePlayers()
+	
+	count := uint16(len(players))
+	response = append(response, byte(count), byte(count>>8))
+	
+	for _, player := range players {
+		response = append(response, byte(player.ID))
+		response = append(response, byte(len(player.Name)))
+		response = append(response, []byte(player.Name)...)
+		response = binary.LittleEndian.AppendUint32(response, uint32(int32(player.Score)))
+		response = binary.LittleEndian.AppendUint32(response, uint32(player.Ping()))
+	}
+	
+	return response
 }
 
 func (rh *RakNetHandler) handleSAMPQueryPing(data []byte, addr *net.UDPAddr) {
@@ -2709,7 +2812,7 @@ func (rh *RakNetHandler) handleSAMPQueryPing(data []byte, addr *net.UDPAddr) {
 	response := make([]byte, 0, len(data))
 	response = append(response, data...)
 	
-	n, err := rh.conn.WriteToUDP(response, addr)
+	n, err := rh.writeQueryResponse(response, addr)
 	if err != nil {
 		log.Printf("Failed to send SA-MP ping response: %v", err)
 		return
@@ -2749,10 +2852,9 @@ func (rh *RakNetHandler) handleSAMPConnectionRequest(data []byte, addr *net.UDPA
 	// Fix race condition: Lock before checking and creating session
 	sessionKey := addr.String()
 	rh.mu.Lock()
-	session, exists := rh.sessions[sessionKey]
-	if !exists {
-		session = protocol.NewSession(addr, protocol.DEFAULT_MTU_SIZE)
-		rh.sessions[sessionKey] = session
+	defer rh.mu.Unlock()
+	if _, exists := rh.sessions[sessionKey]; !exists {
+		rh.sessions[sessionKey] = rh.newSession(addr, protocol.DEFAULT_MTU_SIZE)
 		log.Printf("✅ Created session: %s", sessionKey)
 		
 		// Send 0x19 0x00 only once when session is created
@@ -2760,7 +2862,6 @@ func (rh *RakNetHandler) handleSAMPConnectionRequest(data []byte, addr *net.UDPA
 		rh.conn.WriteToUDP(response, addr)
 		log.Printf("✅ Sent 0x19 to %s", addr)
 	}
-	rh.mu.Unlock()
 }
 
 
@@ -2776,8 +2877,52 @@ func (rh *RakNetHandler) handleSAMPConnectionCookie(data []byte, addr *net.UDPAd
 	
 	sessionKey := addr.String()
 	
-	// FIX RACE CONDITION: Lock for session check/create, then unlock before I/O
+	// Check/create the session under rh.mu, then send outside it
+	cookie, ok := rh.storeConnectionCookie(data, addr)
+	if !ok {
+		return
+	}
+	
+	// FIX #7: Encode 0x1A with CLIENT PORT using XOR formula
+	// Formula discovered from Wireshark analysis:
+	//   clientPort 52935 = 0xCEC7
+	//   0xCE XOR 0x82 = 0x4C (official byte[1])
+	//   0xC7 XOR 0x93 = 0x54 (official byte[2])
+	// 
+	// The encoding uses CLIENT port (from addr), not server port!
+	clientPort := uint16(addr.Port)
+	hi := byte(clientPort >> 8)   // High byte
+	lo := byte(clientPort & 0xFF)  // Low byte
+	
+	// Apply XOR encoding with SA-MP keys
+	encoded0 := hi ^ 0x82
+	encoded1 := lo ^ 0x93
+	
+	cookieResponse := []byte{0x1A, encoded0, encoded1}
+	
+	n, err := rh.conn.WriteToUDP(cookieResponse, addr)
+	if err != nil {
+		log.Printf("Failed to send cookie response: %v", err)
+		return
+	}
+	
+	log.Printf("✅ Sent 0x1A cookie response: %d bytes", n)
+	log.Printf("   Response hex: %s", hex.EncodeToString(cookieResponse))
+	log.Printf("   Client port: %d (0x%04X) → hi=0x%02X lo=0x%02X", clientPort, clientPort, hi, lo)
+	log.Printf("   Encoded: [0x%02X, 0x%02X] (hi^0x82, lo^0x93)", encoded0, encoded1)
+	log.Printf("   Cookie stored: %02X (will expect in 0x00 response)", cookie)
+	
+	log.Printf("Session %s: Sent 0x1A, waiting for 0x00 OpenConnectionRequest2", sessionKey)
+}
+
+// storeConnectionCookie finds or creates the session for a 0x08 and stores
+// its cookie. It reports false for a duplicate from a session already past
+// the handshake.
+func (rh *RakNetHandler) storeConnectionCookie(data []byte, addr *net.UDPAddr) ([]byte, bool) {
+	sessionKey := addr.String()
+	
 	rh.mu.Lock()
+	defer rh.mu.Unlock()
 	
 	// Check if this is from a known IP (different port)
 	var existingSession *protocol.Session
@@ -2797,31 +2942,30 @@ func (rh *RakNetHandler) handleSAMPConnectionCookie(data []byte, addr *net.UDPAd
 		// Session already exists - check state
 		if session.State >= protocol.STATE_CONNECTING {
 			// Already past handshake phase - ignore duplicate 0x08
-			rh.mu.Unlock()
 			log.Printf("⏩ [FIX #12] Duplicate 0x08 from %s (state=%d), ignoring to prevent reset", addr, session.State)
-			return
+			return nil, false
 		}
 		
 		// Still in early phase - can resend 0x1A
 		log.Printf("🔄 0x08 from %s in state=%d, will resend 0x1A", addr, session.State)
-	} else if existingSession != nil && existingSession.GameEntrySent {
+	} else if existingSession != nil && existingSession.ConnectPhase >= protocol.PhaseGameEntrySent {
 		// New port from IP that already has game entry sent
 		// Create new session for this port and link to existing session data
-		session = protocol.NewSession(addr, protocol.DEFAULT_MTU_SIZE)
-		session.State = protocol.STATE_UNCONNECTED
-		session.GameEntrySent = true // Inherit game entry state
+		session = rh.newSession(addr, protocol.DEFAULT_MTU_SIZE)
+		session.SetState(protocol.STATE_UNCONNECTED)
+		session.ConnectPhase = protocol.PhaseGameEntrySent // Inherit game entry state
 		rh.sessions[sessionKey] = session
 		log.Printf("✅ Created linked session for new port %s (game entry already sent)", sessionKey)
 	} else {
 		// Create new session
-		session = protocol.NewSession(addr, protocol.DEFAULT_MTU_SIZE)
-		session.State = protocol.STATE_UNCONNECTED
+		session = rh.newSession(addr, protocol.DEFAULT_MTU_SIZE)
+		session.SetState(protocol.STATE_UNCONNECTED)
 		rh.sessions[sessionKey] = session
 		log.Printf("✅ Created new SA-MP session for %s", sessionKey)
 	}
 	
-	// Extract and store cookie
-	cookie := data[1:4]
+	// Extract and store cookie (copied: data is a pooled read buffer)
+	cookie := append([]byte(nil), data[1:4]...)
 	session.Cookie = cookie
 	
 	cookieValue := binary.BigEndian.Uint32(append([]byte{0}, cookie...))
@@ -2829,49 +2973,19 @@ func (rh *RakNetHandler) handleSAMPConnectionCookie(data []byte, addr *net.UDPAd
 	log.Printf("✅ Stored cookie for %s: 0x%08X", sessionKey, cookieValue)
 	
 	// Update session state
-	session.State = protocol.STATE_HANDSHAKE_SENT
-	session.LastReceiveTime = time.Now()
-	
-	// Unlock before I/O operation (sending packet)
-	rh.mu.Unlock()
-	
-	// FIX #7: Encode 0x1A with CLIENT PORT using XOR formula
-	// Formula discovered from Wireshark analysis:
-	//   clientPort 52935 = 0xCEC7
-	//   0xCE XOR 0x82 = 0x4C (official byte[1])
-	//   0xC7 XOR 0x93 = 0x54 (official byte[2])
-	// 
-	// The encoding uses CLIENT port (from addr), not server port!
-	clientPort := uint16(addr.Port)
-	hi := byte(clientPort >> 8)   // High byte
-	lo := byte(clientPort & 0xFF)  // Low byte
-	
-	// Apply XOR encoding with SA-MP keys
-	encoded0 := hi ^ 0x82
-	encoded1 := lo ^ 0x93
-	
-	cookieResponse := []byte{0x1A, encoded0, encoded1}
-	
-	n, err := rh.conn.WriteToUDP(cookieResponse, addr)
-	if err != nil {
-		log.Printf("Failed to send cookie response: %v", err)
-		return
-	}
-	
-	log.Printf("✅ Sent 0x1A cookie response: %d bytes", n)
-	log.Printf("   Response hex: %s", hex.EncodeToString(cookieResponse))
-	log.Printf("   Client port: %d (0x%04X) → hi=0x%02X lo=0x%02X", clientPort, clientPort, hi, lo)
-	log.Printf("   Encoded: [0x%02X, 0x%02X] (hi^0x82, lo^0x93)", encoded0, encoded1)
-	log.Printf("   Cookie stored: %02X (will expect in 0x00 response)", cookie)
+	session.SetState(protocol.STATE_HANDSHAKE_SENT)
+	session.LastReceiveTime = rh.clock.Now()
 	
-	log.Printf("Session %s: Sent 0x1A, waiting for 0x00 OpenConnectionRequest2", sessionKey)
+	return cookie, true
 }
 
 func (rh *RakNetHandler) handleOpenConnectionRequest1(data []byte, addr *net.UDPAddr) {
 	log.Printf("Received Open Connection Request 1 (0x05): %d bytes from %s", len(data), addr.String())
 	
-	if len(data) < 18 {
-		log.Printf("❌ Packet too short for OpenConnectionRequest1")
+	// OCR1 is padded to the MTU being probed; anything shorter than the
+	// smallest MTU RakNet probes is not a real request
+	if len(data)+udpIPHeaderSize < protocol.DEFAULT_MTU_SIZE {
+		log.Printf("❌ OpenConnectionRequest1 of %d bytes is shorter than a %d-byte MTU probe", len(data), protocol.DEFAULT_MTU_SIZE)
 		return
 	}
 	
@@ -2883,6 +2997,9 @@ func (rh *RakNetHandler) handleOpenConnectionRequest1(data []byte, addr *net.UDP
 		log.Printf("❌ Invalid magic in Open Connection Request 1")
 		return
 	}
+	if rh.rejectBanned(addr) {
+		return
+	}
 	
 	protocolVersion, err := bs.ReadByte()
 	if err != nil {
@@ -2905,20 +3022,14 @@ func (rh *RakNetHandler) handleOpenConnectionRequest1(data []byte, addr *net.UDP
 		return
 	}
 	
-	// FIX #1: Calculate MTU correctly from packet length
 	// Client sends packet padded to their supported MTU
-	mtuSize := uint16(len(data)) + 28 // 28 = IP(20) + UDP(8)
-	
-	// Clamp to valid range
-	if mtuSize > protocol.MAX_MTU_SIZE {
-		mtuSize = protocol.MAX_MTU_SIZE
-	}
-	if mtuSize < 576 {
-		mtuSize = 576
-	}
+	mtuSize := rh.clampMTU(len(data) + udpIPHeaderSize)
 	
 	log.Printf("Calculated MTU: %d (from packet length %d)", mtuSize, len(data))
 	
+	// Repeated OCR1 from one address are MTU probes, not a new handshake
+	mtuSize = rh.recordMTUProbe(addr, mtuSize)
+	
 	// FIX #1: Build proper RakNet OpenConnectionReply1 (0x06)
 	// Format: [ID][Magic][ServerGUID][HasSecurity][MTU]
 	response := protocol.NewEmptyBitStream()
@@ -2937,6 +3048,48 @@ func (rh *RakNetHandler) handleOpenConnectionRequest1(data []byte, addr *net.UDP
 	log.Printf("✅ Sent 0x06 OpenConnectionReply1: %d bytes, MTU=%d to %s", n, mtuSize, addr.String())
 }
 
+// mtuProbe is the MTU an address has probed with OPEN_CONNECTION_REQUEST_1
+type mtuProbe struct {
+	mtu uint16
+	at  time.Time
+}
+
+// maxMTU returns the server's MTU ceiling
+func (rh *RakNetHandler) maxMTU() uint16 {
+	if rh.server == nil || rh.server.MaxMTU < protocol.DEFAULT_MTU_SIZE {
+		return protocol.MAX_MTU_SIZE
+	}
+	return rh.server.MaxMTU
+}
+
+// udpIPHeaderSize is the IPv4 (20) and UDP (8) header overhead on a datagram
+const udpIPHeaderSize = 28
+
+// clampMTU limits a proposed MTU to DEFAULT_MTU_SIZE..maxMTU
+func (rh *RakNetHandler) clampMTU(mtu int) uint16 {
+	if mtu < protocol.DEFAULT_MTU_SIZE {
+		return protocol.DEFAULT_MTU_SIZE
+	}
+	if maxMTU := rh.maxMTU(); mtu > int(maxMTU) {
+		return maxMTU
+	}
+	return uint16(mtu)
+}
+
+// recordMTUProbe keeps the lowest MTU an address has probed and returns it,
+// so every OCR1 reply and the final session agree on one value
+func (rh *RakNetHandler) recordMTUProbe(addr *net.UDPAddr, mtu uint16) uint16 {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+	
+	if probe, probed := rh.mtuProbes[addr.String()]; probed && probe.mtu < mtu {
+		mtu = probe.mtu
+		log.Printf("🔁 Repeated OCR1 from %s, keeping probed MTU %d", addr, mtu)
+	}
+	rh.mtuProbes[addr.String()] = mtuProbe{mtu: mtu, at: rh.clock.Now()}
+	return mtu
+}
+
 func (rh *RakNetHandler) handleOpenConnectionRequest2(data []byte, addr *net.UDPAddr) {
 	log.Printf("Received Open Connection Request 2: %d bytes from %s", len(data), addr.String())
 	log.Printf("Packet hex: %s", hex.EncodeToString(data))
@@ -2963,34 +3116,24 @@ func (rh *RakNetHandler) handleOpenConnectionRequest2(data []byte, addr *net.UDP
 		return
 	}
 	
-	// Validate MTU size - must be between 400 and 1500
-	if mtuSize < 400 || mtuSize > 1500 {
-		log.Printf("⚠️ Invalid MTU size %d, using default %d", mtuSize, protocol.DEFAULT_MTU_SIZE)
-		mtuSize = protocol.DEFAULT_MTU_SIZE
-	}
-	
 	clientGUID, err := bs.ReadUint64()
 	if err != nil {
 		log.Printf("Failed to read client GUID: %v", err)
 		return
 	}
+	if bs.Remaining() != 0 {
+		log.Printf("Ignoring Open Connection Request 2 with %d trailing bytes", bs.Remaining())
+		return
+	}
+	
+	if clamped := rh.clampMTU(int(mtuSize)); clamped != mtuSize {
+		log.Printf("⚠️ MTU size %d outside %d..%d, using %d", mtuSize, protocol.DEFAULT_MTU_SIZE, rh.maxMTU(), clamped)
+		mtuSize = clamped
+	}
 	
 	log.Printf("Server Address: %s, MTU: %d, Client GUID: %d", serverAddr.String(), mtuSize, clientGUID)
 	
-	// Create session
-	rh.mu.Lock()
-	session, exists := rh.sessions[addr.String()]
-	if !exists {
-		session = protocol.NewSession(addr, mtuSize)
-		session.State = protocol.STATE_CONNECTING
-		rh.sessions[addr.String()] = session
-		log.Printf("Created new session for %s", addr.String())
-	} else {
-		session.MTU = mtuSize
-		session.State = protocol.STATE_CONNECTING
-		log.Printf("Updated existing session for %s", addr.String())
-	}
-	rh.mu.Unlock()
+	mtuSize = rh.settleSession(addr, mtuSize)
 	
 	// Send reply
 	response := protocol.NewEmptyBitStream()
@@ -3010,6 +3153,43 @@ func (rh *RakNetHandler) handleOpenConnectionRequest2(data []byte, addr *net.UDP
 	log.Printf("Sent Open Connection Reply 2: %d bytes to %s", n, addr.String())
 }
 
+// settleSession settles on the MTU the OCR1 probes agreed on and creates or
+// updates the session for an OCR2. It returns the settled MTU.
+func (rh *RakNetHandler) settleSession(addr *net.UDPAddr, mtuSize uint16) uint16 {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+	
+	if probe, probed := rh.mtuProbes[addr.String()]; probed && probe.mtu < mtuSize {
+		mtuSize = probe.mtu
+	}
+	delete(rh.mtuProbes, addr.String())
+	
+	session, exists := rh.sessions[addr.String()]
+	if !exists {
+		session = rh.newSession(addr, mtuSize)
+		session.SetState(protocol.STATE_CONNECTING)
+		rh.sessions[addr.String()] = session
+		log.Printf("Created new session for %s", addr.String())
+		return mtuSize
+	}
+	
+	// A resent OCR2 must not move the handshake backwards
+	session.Mu.Lock()
+	defer session.Mu.Unlock()
+	if !session.MTULocked() {
+		session.MTU = mtuSize
+	}
+	if session.State < protocol.STATE_CONNECTING {
+		session.SetState(protocol.STATE_CONNECTING)
+	}
+	log.Printf("Updated existing session for %s", addr.String())
+	return mtuSize
+}
+
+// handleDataPacket decodes a datagram from a session's client and reverses
+// the packet cipher. HandleDataPacket queues its ACK and releases the messages
+// it completes (duplicates dropped, splits reassembled, ordered channels in
+// order), which go to handleInternalPacket.
 func (rh *RakNetHandler) handleDataPacket(data []byte, addr *net.UDPAddr) {
 	// CRITICAL FIX: SA-MP uses IP+Port as session key
 	sessionKey := addr.String()
@@ -3020,414 +3200,91 @@ func (rh *RakNetHandler) handleDataPacket(data []byte, addr *net.UDPAddr) {
 	
 	if !exists {
 		log.Printf("⚠️ Data packet from unknown session: %s", addr.String())
-		log.Printf("Available sessions:")
-		rh.mu.RLock()
-		for sessAddr := range rh.sessions {
-			log.Printf("  - %s", sessAddr)
-		}
-		rh.mu.RUnlock()
 		return
 	}
 	
-	log.Printf("📦 Processing data packet 0x%02X from %s (session exists)", data[0], addr.String())
-	log.Printf("Packet hex: %s", hex.EncodeToString(data))
-	
-	// CRITICAL FIX: Detect SA-MP auth packet (0x88)
-	// Multiple types based on length and session state:
-	// 1. 6 bytes + !HandshakeSent = First auth signal after 0x19 → trigger full handshake
-	// 2. 6 bytes + HandshakeSent + !StreamingDone = Keepalive during streaming → ACK only
-	// 3. 84 bytes + GameEntrySent = JOIN REQUEST! Decode payload and process as 0x28
-	// 4. 13 bytes + StreamingDone = Streaming complete signal → trigger post-streaming (NOT USED - we trigger automatically)
-	if data[0] == 0x88 {
-		// Read sequence number (3 bytes LITTLE-endian)
-		seqNum := protocol.ReadUint24LE(data[1:4])
-		
-		if len(data) == 84 {
-			session.Mu.Lock()
-			session.ACKQueue[seqNum] = struct{}{} // Dedup set
-			session.LastReceiveTime = time.Now()
-			gameEntrySent := session.GameEntrySent
-			session.Mu.Unlock()
-			
-			// Send ACK immediately
-			session.Update(rh.conn)
-			
-			// CRITICAL FIX: 0x88 84 bytes after streaming IS the join request!
-			if gameEntrySent {
-				log.Printf("🎮 Received JOIN REQUEST (0x88, 84 bytes) - decoding payload...")
-				
-				// Decode RakNet datagram to extract encapsulated payload
-				dp, err := protocol.DecodeDataPacket(data)
-				if err != nil {
-					log.Printf("❌ Failed to decode 0x88 datagram: %v", err)
-					return
-				}
-				
-				if len(dp.Packets) > 0 {
-					// Extract first encapsulated packet payload
-					payload := dp.Packets[0].Payload
-					log.Printf("✅ Decoded join request payload: %d bytes", len(payload))
-					log.Printf("   Payload hex (first 32 bytes): %02X", payload[:min(32, len(payload))])
-					
-					// Check if this is 0x8A (SA-MP auth key)
-					if len(payload) > 0 && payload[0] == 0x8A {
-						log.Printf("🎯 Detected 0x8A auth key in join request - processing...")
-						
-						// Process as internal packet
-						packet := &protocol.RakNetPacket{
-							PacketID: payload[0],
-							Payload:  payload[1:],
-						}
-						rh.handleInternalPacket(session, packet)
-						
-						// CRITICAL: After processing 0x8A, send join response sequence
-						log.Printf("✅ Join request processed - sending join response sequence")
-						rh.sendJoinResponseSequence(addr)
-					} else {
-						log.Printf("⚠️ Unknown payload type in 0x88: 0x%02X", payload[0])
-					}
-				} else {
-					log.Printf("⚠️ 0x88 84 bytes has no encapsulated packets")
-				}
-			} else {
-				log.Printf("✅ 0x88 84 bytes ACKed - waiting for streaming to complete")
-			}
-			return
-		}
-		
-		if len(data) == 6 && !session.GetHandshakeSent() {
-			// FIX #3: First 0x88 after 0x19 - send E3:00 immediately WITHOUT ACK
-			log.Printf("🎯 Detected first 0x88 auth (6 bytes) - sending E3:00 challenge")
-			
-			session.Mu.Lock()
-			if session.AuthHandled {
-				log.Printf("⚠️ 0x88 RETRY detected! E3:00 was already sent but client is retrying")
-				log.Printf("   This means client rejected E3:00 - likely wrong length (26 instead of 25)")
-				session.Mu.Unlock()
-				return
-			}
-			session.AuthHandled = true
-			session.SetHandshakeSent(true)
-			
-			// Queue ACK but DON'T send it yet - let it be sent later with other packets
-			session.ACKQueue[seqNum] = struct{}{} // Dedup set
-			session.LastReceiveTime = time.Now()
-			session.Mu.Unlock()
-			
-			// FIX #3: Send E3:00 IMMEDIATELY without ACK (matches official behavior)
-			// Official server: 0x88 → E3:00 (no ACK in between)
-			rh.sendFullHandshakeSequence(addr)
-			return
-		}
-		
-		if len(data) == 6 && session.GetHandshakeSent() && !session.GetStreamingDone() {
-			// State 2: Keepalive during streaming - just ACK
-			log.Printf("⏩ 0x88 keepalive (6 bytes) during streaming from %s", addr)
-			
-			session.Mu.Lock()
-			session.ACKQueue[seqNum] = struct{}{} // Dedup set
-			session.LastReceiveTime = time.Now()
-			session.Mu.Unlock()
-			return
-		}
-		
-		// NOTE: 0x88 13 bytes handler removed - we trigger post-streaming automatically
-		// after streaming completes, not waiting for client signal
-		
-		// Fallback: just ACK any other 0x88
-		log.Printf("⏩ 0x88 (%d bytes) - ACK only", len(data))
-		
-		session.Mu.Lock()
-		session.ACKQueue[seqNum] = struct{}{} // Dedup set
-		session.LastReceiveTime = time.Now()
-		session.Mu.Unlock()
+	dp, err := session.Decapsulate(data)
+	if err != nil {
+		log.Printf("⚠️ Dropped malformed datagram 0x%02X (%d bytes) from %s: %v", data[0], len(data), addr, err)
 		return
 	}
 	
-	// CRITICAL FIX: Detect SA-MP join request (0x22 with 48 bytes)
-	// From Wireshark: Client sends 0x22, server responds with 3 exact packets
-	if data[0] == 0x22 && len(data) == 48 {
-		log.Printf("✅ Received 0x22 auth data from %s", addr)
-		
-		session.LastReceiveTime = time.Now()
-		
-		// 1. Short e3
-		rh.conn.WriteToUDP([]byte{0xe3, 0x01, 0x00}, addr)
-		log.Printf("✅ Sent short e3 (3 bytes)")
-		
-		// 2. 0x00 internal address packet dengan client IP:port
-		clientIP := addr.IP.To4()
-		if clientIP == nil {
-			clientIP = []byte{127, 0, 0, 1}
-		}
-		port := uint16(addr.Port)
-		pkt00 := []byte{
-			0x00, 0x80, 0x42, 0x68, 0x22,
-			clientIP[0], clientIP[1], clientIP[2], clientIP[3],
-			byte(port), byte(port >> 8), // little-endian
-			0x00, 0x00, 0x27, 0x4c, 0x00, 0x00,
-		}
-		rh.conn.WriteToUDP(pkt00, addr)
-		log.Printf("✅ Sent 0x00 internal address packet (17 bytes)")
-		
-		// 3. MTU e5
-		rh.conn.WriteToUDP([]byte{0xe5, 0x02, 0x00, 0x02, 0x00, 0x02, 0x80, 0x00}, addr)
-		log.Printf("✅ Sent MTU e5 (8 bytes)")
-		
-		// FIX 1: Upgrade ke CONNECTED setelah 0x22 (bukan di keepalive)
-		if session.State == protocol.STATE_CONNECTING {
-			session.State = protocol.STATE_CONNECTED
-			session.PlayerID = 0
-			log.Printf("✅ Session upgraded to CONNECTED after 0x22")
-		}
-		
-		session.State = protocol.STATE_READY
-		
-		return
-	}
+	// The first datagram after the handshake starts streaming, unless one of
+	// its messages moves the connection on first
+	session.Mu.RLock()
+	handshakeDone := session.State == protocol.STATE_READY && session.ConnectPhase < protocol.PhaseGameEntrySent
+	session.Mu.RUnlock()
 	
-	// Check if 0x02 - ignore unconnected ping from connected session
-	if data[0] == 0x02 {
-		return
+	for _, packet := range session.HandleDataPacket(dp) {
+		rh.handleInternalPacket(session, packet)
 	}
 	
-	// Check if 0x80 with 6 bytes - keepalive, ACK only
-	if data[0] == 0x80 && len(data) == 6 {
-		// Read sequence number (3 bytes LITTLE-endian)
-		seq := protocol.ReadUint24LE(data[1:4])
-		session.Mu.Lock()
-		session.ACKQueue[seq] = struct{}{} // Dedup set
-		session.Mu.Unlock()
-		log.Printf("⏩ 0x80 keepalive (6 bytes), added seq=%d to ACK queue", seq)
-		return
+	session.Mu.Lock()
+	startStreaming := handshakeDone && session.State == protocol.STATE_READY && session.ConnectPhase < protocol.PhaseGameEntrySent
+	if startStreaming {
+		session.SetState(protocol.STATE_IN_GAME)
+	}
+	session.Mu.Unlock()
+	if startStreaming {
+		log.Printf("🎯 First keepalive after handshake - triggering 0x04 streaming data!")
+		rh.sendPostStreamingSequence(addr)
 	}
+}
+
+// handleJoinAuth handles the 0x8A join/auth request: the first one sends the
+// full game entry sequence, a later one (the client joining once streaming is
+// done) the join response
+func (rh *RakNetHandler) handleJoinAuth(session *protocol.Session) {
+	session = rh.handshakeSessionFor(session)
 	
-	// Check if 0x8A with > 6 bytes is join/auth request (not keepalive!)
-	if data[0] == 0x8A && len(data) > 6 {
-		log.Printf("✅ Detected 0x8A join/auth request (%d bytes) from %s", len(data), addr)
-		
-		// CRITICAL FIX: Find the REAL session used for handshake by IP
-		// The session at addr.String() might be a NEW session created for port change
-		// We need to find the session that has non-zero counters (the handshake session)
-		clientIP := addr.IP.String()
-		var realSession *protocol.Session
-		var realSessionKey string
-		
-		rh.mu.RLock()
-		for key, sess := range rh.sessions {
-			if sess.Addr.IP.String() == clientIP {
-				sess.Mu.RLock()
-				seq := sess.SequenceNumber
-				msg := sess.MessageIndex
-				sess.Mu.RUnlock()
-				
-				// Find session with non-zero counters (the one used for handshake)
-				if seq > 0 || msg > 0 {
-					realSession = sess
-					realSessionKey = key
-					log.Printf("✅ Found handshake session: %s (seq=%d msg=%d)", key, seq, msg)
-					break
-				}
-			}
-		}
-		rh.mu.RUnlock()
-		
-		// If no session with counters found, use current session
-		if realSession == nil {
-			realSession = session
-			realSessionKey = addr.String()
-			log.Printf("⚠️ No handshake session found, using current session: %s", realSessionKey)
-		}
-		
-		// If port changed, rebind the real session to new port
-		if realSessionKey != addr.String() {
-			log.Printf("🔁 Port changed from %s to %s - rebinding session", realSessionKey, addr.String())
-			
-			realSession.Mu.Lock()
-			realSession.Addr = addr
-			realSession.Mu.Unlock()
-			
-			// Update session map
-			rh.mu.Lock()
-			rh.sessions[addr.String()] = realSession
-			// Don't delete old key yet - might still receive packets
-			rh.mu.Unlock()
-			
-			// Update local session variable to use real session
-			session = realSession
-		}
-		
-		// Log session pointer and counters
-		log.Printf("📍 Session pointer: %p", session)
-		
-		// CRITICAL: Log session counters IMMEDIATELY when 0x8A is received
-		session.Mu.RLock()
-		currentSeq := session.SequenceNumber
-		currentMsg := session.MessageIndex
-		currentOrder := session.ChannelOrderIndex[0]
-		currentState := session.State
-		gameEntrySent := session.GameEntrySent
-		session.Mu.RUnlock()
-		
-		log.Printf("📊 [0x8A RECEIVED] Session counters → seq=%d msg=%d order[ch0]=%d state=%d gameEntrySent=%v", 
-			currentSeq, currentMsg, currentOrder, currentState, gameEntrySent)
-		
-		if currentSeq == 0 && currentMsg == 0 && currentOrder == 0 {
-			log.Printf("❌❌❌ CRITICAL BUG: Session counters are at 0! This session was just created or reset!")
-			log.Printf("❌ This means handshake packets were sent to a DIFFERENT session!")
-			log.Printf("❌ Session key: %s", addr.String())
-		}
-		
-		// CRITICAL: Check if already in game - don't reprocess
-		if currentState >= protocol.STATE_IN_GAME || gameEntrySent {
-			log.Printf("⏩ [0x8A] Already in game (state=%d, gameEntrySent=%v) - ignoring", currentState, gameEntrySent)
-			// Still ACK it
-			seq := protocol.ReadUint24LE(data[1:4])
-			session.Mu.Lock()
-			session.ACKQueue[seq] = struct{}{}
-			session.LastReceiveTime = time.Now()
-			session.Mu.Unlock()
-			session.Update(rh.conn)
-			return
-		}
-		
-		// Update last receive time
-		session.Mu.Lock()
-		session.LastReceiveTime = time.Now()
-		session.Mu.Unlock()
-		
-		// ACK dulu
-		seq := protocol.ReadUint24LE(data[1:4])
-		session.Mu.Lock()
-		session.ACKQueue[seq] = struct{}{}
-		session.Mu.Unlock()
-		session.Update(rh.conn)
-		
-		// Gunakan satu Lock — eliminasi race condition sepenuhnya
-		session.Mu.Lock()
-		if session.GameEntrySent {
-			session.Mu.Unlock()
-			log.Printf("⏩ [0x8A] Game entry already sent, ignoring from %s", addr)
-			return
-		}
-		session.GameEntrySent = true
-		session.State = protocol.STATE_IN_GAME
+	session.Mu.Lock()
+	if session.ConnectPhase >= protocol.PhaseGameEntrySent {
 		session.Mu.Unlock()
-		
-		log.Printf("🎯 [0x8A] Sending FULL game entry sequence immediately!")
-		
-		// CRITICAL: Log counters again BEFORE spawn to confirm they haven't changed
-		session.Mu.RLock()
-		seqBeforeSpawn := session.SequenceNumber
-		msgBeforeSpawn := session.MessageIndex
-		orderBeforeSpawn := session.ChannelOrderIndex[0]
-		session.Mu.RUnlock()
-		
-		log.Printf("📊 RakNet counters before spawn → seq=%d msg=%d order[ch0]=%d", 
-			seqBeforeSpawn, msgBeforeSpawn, orderBeforeSpawn)
-		log.Printf("📍 Session pointer before spawn: %p", session)
-		
-		if seqBeforeSpawn == 0 && msgBeforeSpawn == 0 && orderBeforeSpawn == 0 {
-			log.Printf("❌❌❌ FATAL: Counters still at 0 before spawn! Session was never used for handshake!")
-			log.Printf("❌ Checking all sessions to find the real handshake session...")
-			
-			rh.mu.RLock()
-			for key, sess := range rh.sessions {
-				sess.Mu.RLock()
-				log.Printf("   Session %s: seq=%d msg=%d order[ch0]=%d state=%d pointer=%p", 
-					key, sess.SequenceNumber, sess.MessageIndex, sess.ChannelOrderIndex[0], sess.State, sess)
-				sess.Mu.RUnlock()
-			}
-			rh.mu.RUnlock()
-		}
-		
-		// Kirim Phase 1 (streaming) + Phase 2 (E3:09 → E3:21)
-		rh.sendFullGameEntrySequence(addr)
+		log.Printf("✅ Join request processed - sending join response sequence")
+		rh.sendJoinResponseSequence(session.Addr)
 		return
 	}
+	session.ConnectPhase = protocol.PhaseGameEntrySent
+	session.SetState(protocol.STATE_IN_GAME)
+	session.Mu.Unlock()
 	
-	// ============================================================
-	// BYPASS DECODER - Direct SA-MP packet detection
-	// ============================================================
-	
-	// ACK the packet first
-	if len(data) >= 4 {
-		seq := uint32(data[1]) | uint32(data[2])<<8 | uint32(data[3])<<16
-		session.Mu.Lock()
-		session.ACKQueue[seq] = struct{}{}
-		session.LastReceiveTime = time.Now()
-		session.Mu.Unlock()
-		log.Printf("✅ ACKed data packet seq=%d", seq)
-	}
+	log.Printf("🎯 [0x8A] Sending FULL game entry sequence immediately!")
+	rh.sendFullGameEntrySequence(session.Addr)
+}
+
+// handshakeSessionFor returns the session that ran the handshake for
+// session's client IP. A client may send the join request from a new port,
+// which has a fresh session of its own; the handshake session, the one with
+// non-zero counters, is then rebound to the new port.
+func (rh *RakNetHandler) handshakeSessionFor(session *protocol.Session) *protocol.Session {
+	clientIP := session.Addr.IP.String()
+	var handshake *protocol.Session
 	
-	// Scan entire payload for known SA-MP packet IDs
-	// Inner packet can be at offset 4 to 14
-	for offset := 4; offset < len(data)-1 && offset <= 14; offset++ {
-		id := data[offset]
-		switch id {
-		case 0x2A, 0xAA: // Auth response → send E5
-			session.Mu.RLock()
-			sentE5 := session.SentNWBitStream
-			session.Mu.RUnlock()
-			
-			if !sentE5 {
-				log.Printf("[Inner 0x%02X at offset %d] → sending E5 to %s", id, offset, addr)
-				session.Mu.Lock()
-				session.SentNWBitStream = true
-				session.Mu.Unlock()
-				rh.sendE5PlayerSync(session)
-			}
-			return
-		case 0x22: // Login data (can come wrapped)
-			session.Mu.RLock()
-			sentE3Phase1 := session.SentE3Phase1
-			session.Mu.RUnlock()
-			
-			if !sentE3Phase1 {
-				log.Printf("[Inner 0x22 at offset %d] → sending E3:01", offset)
-				rh.handleLoginData(data[offset:], addr, session)
-			}
-			return
+	rh.mu.RLock()
+	for _, sess := range rh.sessions {
+		if sess == session || sess.Addr.IP.String() != clientIP {
+			continue
+		}
+		sess.Mu.RLock()
+		used := sess.SequenceNumber > 0 || sess.MessageIndex > 0
+		sess.Mu.RUnlock()
+		if used {
+			handshake = sess
+			break
 		}
 	}
+	rh.mu.RUnlock()
 	
-	// Fallback: large packet after E3:01 sent = auth response
 	session.Mu.RLock()
-	sentE3Phase1 := session.SentE3Phase1
-	sentE5 := session.SentNWBitStream
+	fresh := session.SequenceNumber == 0 && session.MessageIndex == 0
 	session.Mu.RUnlock()
-	
-	if len(data) > 50 && sentE3Phase1 && !sentE5 {
-		log.Printf("[Heuristic] %dB packet after E3:01 → sending E5 to %s", len(data), addr)
-		session.Mu.Lock()
-		session.SentNWBitStream = true
-		session.Mu.Unlock()
-		rh.sendE5PlayerSync(session)
-		return
+	if handshake == nil || !fresh {
+		return session
 	}
 	
-	// CRITICAL: Trigger 0x04 streaming data on first keepalive after handshake
-	session.Mu.RLock()
-	state := session.State
-	gameEntrySent := session.GameEntrySent
-	session.Mu.RUnlock()
-	
-	if state == protocol.STATE_READY && !gameEntrySent {
-		log.Printf("🎯 First keepalive after handshake - triggering 0x04 streaming data!")
-		
-		// Set to IN_GAME state before streaming
-		session.Mu.Lock()
-		session.State = protocol.STATE_IN_GAME
-		session.Mu.Unlock()
-		
-		// All packets now sent in sendPostStreamingSequence
-		// rh.sendStreamingData(addr) - REMOVED
-		rh.sendPostStreamingSequence(addr)
-		
-		log.Printf("✅ Sent 0x04 streaming data, now waiting for 0x28 join request")
-	} else {
-		log.Printf("⏳ Client sending keepalive (state=%d, gameEntrySent=%v)", state, gameEntrySent)
-	}
+	log.Printf("🔁 Port changed from %s to %s - rebinding session", handshake.Addr, session.Addr)
+	rh.rebindSession(handshake, session.Addr)
+	return handshake
 }
 
 func (rh *RakNetHandler) handleInternalPacket(session *protocol.Session, packet *protocol.RakNetPacket) {
@@ -3440,6 +3297,8 @@ func (rh *RakNetHandler) handleInternalPacket(session *protocol.Session, packet
 		rh.handleDisconnection(session)
 	case protocol.ID_CONNECTED_PING:
 		rh.handleConnectedPingInternal(session, packet)
+	case protocol.ID_CONNECTED_PONG:
+		rh.handleConnectedPongInternal(session, packet, rh.clock.Now())
 	case 0x06:
 		// SA-MP Join Request
 		if len(packet.Payload) < 2 {
@@ -3454,6 +3313,10 @@ func (rh *RakNetHandler) handleInternalPacket(session *protocol.Session, packet
 		nickname := string(packet.Payload[2 : 2+nameLen])
 		log.Printf("🎮 Player joining: nickname=%s", nickname)
 		session.Nickname = nickname
+		session.ClientVersion = joinRequestVersion(packet.Payload)
+		if rh.rejectBannedName(session) {
+			return
+		}
 		rh.sendConnectionAccepted(session)
 	case 0x2A:
 		// SA-MP Auth Response - send 0xE5 player sync
@@ -3475,9 +3338,9 @@ func (rh *RakNetHandler) handleInternalPacket(session *protocol.Session, packet
 		} else if payloadLen >= 3 {
 			// 6 bytes total = 5 bytes payload
 			// First auth or keepalive
-			if !session.GetHandshakeSent() {
+			if !session.ReachedPhase(protocol.PhaseHandshakeSent) {
 				log.Printf("🎯 0x88 first auth - triggering full handshake")
-				session.SetHandshakeSent(true)
+				session.AdvancePhase(protocol.PhaseHandshakeSent)
 				rh.sendFullHandshakeSequence(session.Addr)
 			} else {
 				log.Printf("⏩ 0x88 keepalive during streaming")
@@ -3489,7 +3352,7 @@ func (rh *RakNetHandler) handleInternalPacket(session *protocol.Session, packet
 			log.Printf("✅ Received encapsulated 0x22 auth data")
 			
 			// Send response packets (raw UDP, not encapsulated)
-			rh.conn.WriteToUDP([]byte{0xe3, 0x01, 0x00}, session.Addr)
+			rh.writeToSession(session, []byte{0xe3, 0x01, 0x00})
 			
 			clientIP := session.Addr.IP.To4()
 			if clientIP == nil {
@@ -3502,22 +3365,21 @@ func (rh *RakNetHandler) handleInternalPacket(session *protocol.Session, packet
 				byte(port), byte(port >> 8),
 				0x00, 0x00, 0x27, 0x4c, 0x00, 0x00,
 			}
-			rh.conn.WriteToUDP(pkt00, session.Addr)
-			rh.conn.WriteToUDP([]byte{0xe5, 0x02, 0x00, 0x02, 0x00, 0x02, 0x80, 0x00}, session.Addr)
+			rh.writeToSession(session, pkt00)
+			rh.writeToSession(session, []byte{0xe5, 0x02, 0x00, 0x02, 0x00, 0x02, 0x80, 0x00})
 			
 			if session.State == protocol.STATE_CONNECTING {
-				session.State = protocol.STATE_CONNECTED
+				session.SetState(protocol.STATE_CONNECTED)
 				session.PlayerID = 0
 			}
-			session.State = protocol.STATE_READY
+			session.SetState(protocol.STATE_READY)
 		}
 	case 0x8A:
 		// SA-MP join/auth request
 		if len(packet.Payload) > 5 {
-			log.Printf("✅ Received encapsulated 0x8A join/auth request (%d bytes payload)")
-			
-			// FIXED: Don't send game entry here - wait for 0x28
-			log.Printf("   ⏳ 0x8A processed, waiting for 0x28 join request from client...")
+			log.Printf("✅ Received encapsulated 0x8A join/auth request (%d bytes payload)", len(packet.Payload))
+			rh.markMilestone(session, protocol.MilestoneAuth)
+			rh.handleJoinAuth(session)
 		}
 	case 0x77:
 		// SA-MP Request Class
@@ -3527,7 +3389,7 @@ func (rh *RakNetHandler) handleInternalPacket(session *protocol.Session, packet
 		// SA-MP Spawn Request
 		log.Printf("🎮 Received SA-MP 0x7B Spawn Request from player %d", session.PlayerID)
 		rh.sendPlayerSpawn(session)
-		session.State = protocol.STATE_READY
+		session.SetState(protocol.STATE_READY)
 		log.Printf("✅ Player %d spawned and ready!", session.PlayerID)
 	default:
 		// Log SA-MP packets for debugging
@@ -3545,19 +3407,54 @@ func (rh *RakNetHandler) handleInternalPacket(session *protocol.Session, packet
 
 func (rh *RakNetHandler) handleConnectionRequest(session *protocol.Session, packet *protocol.RakNetPacket) {
 	log.Printf("🔑 Received ID_CONNECTION_REQUEST (0x09) from %s", session.Addr.String())
+	rh.markMilestone(session, protocol.MilestoneConnectionRequest)
 	
-	bs := protocol.NewBitStream(packet.Payload)
-	clientGUID, _ := bs.ReadUint64()
-	requestTime, err := bs.ReadUint64()
+	req, err := protocol.ParseConnectionRequest(packet.Payload)
 	if err != nil {
-		log.Printf("Failed to read request time: %v", err)
-		requestTime = uint64(time.Now().UnixMilli())
+		log.Printf("❌ Dropped connection request from %s: %v", session.Addr.String(), err)
+		return
 	}
+	clientGUID, requestTime := req.GUID, req.Time
 	
 	log.Printf("   Client GUID: %d, Request Time: %d", clientGUID, requestTime)
 	
+	if !rh.checkPassword(req.Password) {
+		log.Printf("🔒 Rejected %s: wrong or missing server password", session.Addr.String())
+		rh.server.audit(AuditRejected, session.Addr, -1, "", "invalid password")
+		rh.rejectConnection(session, protocol.ID_INVALID_PASSWORD)
+		return
+	}
+	
+	// Half-open handshakes are not counted, so MaxPlayers is enforced again here
+	// before a new client becomes connected
+	rh.mu.RLock()
+	_, knownGUID := rh.sessionsByGUID[clientGUID]
+	rh.mu.RUnlock()
+	if !knownGUID && session.State < protocol.STATE_CONNECTED && rh.server != nil && rh.server.IsFull() {
+		log.Printf("🚫 Server full (%d/%d), rejecting %s", rh.server.GetPlayerCount(), rh.server.MaxPlayers, session.Addr)
+		rh.server.audit(AuditRejected, session.Addr, -1, "", "server full")
+		rh.rejectConnection(session, protocol.ID_NO_FREE_INCOMING_CONNECTIONS)
+		return
+	}
+	
 	// CRITICAL: Check for session migration (same GUID, different port)
+	if migrated := rh.migrateByGUID(session, clientGUID); migrated != nil {
+		// Send response to NEW address
+		rh.sendConnectionRequestAcceptedProper(migrated, requestTime)
+		return
+	}
+	
+	// Send proper ID_CONNECTION_REQUEST_ACCEPTED (0x10)
+	rh.sendConnectionRequestAcceptedProper(session, requestTime)
+}
+
+// migrateByGUID registers session under clientGUID. If the GUID already
+// belongs to a session on another address, that session is moved to
+// session's address and returned; otherwise it returns nil.
+func (rh *RakNetHandler) migrateByGUID(session *protocol.Session, clientGUID uint64) *protocol.Session {
 	rh.mu.Lock()
+	defer rh.mu.Unlock()
+	
 	if existingSession, exists := rh.sessionsByGUID[clientGUID]; exists {
 		oldAddr := existingSession.Addr.String()
 		newAddr := session.Addr.String()
@@ -3576,7 +3473,7 @@ func (rh *RakNetHandler) handleConnectionRequest(session *protocol.Session, pack
 			// Update existing session with new address
 			existingSession.Mu.Lock()
 			existingSession.Addr = session.Addr
-			existingSession.LastReceiveTime = time.Now()
+			existingSession.LastReceiveTime = rh.clock.Now()
 			existingSession.Mu.Unlock()
 			
 			// Add to new address in sessions map
@@ -3591,11 +3488,7 @@ func (rh *RakNetHandler) handleConnectionRequest(session *protocol.Session, pack
 				existingSession.MessageIndex, existingSession.OrderIndex, 
 				existingSession.SequenceNumber, existingSession.State)
 			
-			rh.mu.Unlock()
-			
-			// Send response to NEW address
-			rh.sendConnectionRequestAcceptedProper(existingSession, requestTime)
-			return
+			return existingSession
 		}
 		
 		log.Printf("   ✅ Same GUID, same address - continuing existing session")
@@ -3608,47 +3501,71 @@ func (rh *RakNetHandler) handleConnectionRequest(session *protocol.Session, pack
 		rh.sessionsByGUID[clientGUID] = session
 		log.Printf("   ✅ Stored new session with GUID: %d", clientGUID)
 	}
-	rh.mu.Unlock()
+	return nil
+}
+
+// checkPassword reports whether password matches the server password (if one is set)
+func (rh *RakNetHandler) checkPassword(password string) bool {
+	if rh.server == nil || rh.server.Password == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(password), []byte(rh.server.Password)) == 1
+}
+
+// rejectConnection queues a single-byte rejection reply (e.g. ID_INVALID_PASSWORD)
+// and does not accept the connection. The session is removed by the stale session cleanup.
+func (rh *RakNetHandler) rejectConnection(session *protocol.Session, reason byte) {
+	encap := &protocol.EncapsulatedPacket{
+		Reliability: protocol.RELIABLE,
+		Payload:     []byte{reason},
+	}
+	session.AddToQueue(encap)
 	
-	// Send proper ID_CONNECTION_REQUEST_ACCEPTED (0x10)
-	rh.sendConnectionRequestAcceptedProper(session, requestTime)
+	session.Mu.Lock()
+	session.SetState(protocol.STATE_UNCONNECTED)
+	session.Mu.Unlock()
 }
 
 func (rh *RakNetHandler) sendConnectionRequestAcceptedProper(session *protocol.Session, clientTime uint64) {
 	log.Printf("=== Sending ID_CONNECTION_REQUEST_ACCEPTED (0x10) ===")
 	
-	response := protocol.NewEmptyBitStream()
-	response.WriteByte(protocol.ID_CONNECTION_REQUEST_ACCEPTED)
-	
-	// Client external address
-	response.WriteAddress(session.Addr)
-	
-	// Client index (2 bytes)
-	response.WriteUint16(0)
-	
-	// Server internal address (use server's address)
-	serverAddr := &net.UDPAddr{
-		IP:   net.IPv4(127, 0, 0, 1),
-		Port: 7777,
-	}
-	response.WriteAddress(serverAddr)
-	
-	// Timestamp (8 bytes, big-endian) - echo client time
-	response.WriteUint64(clientTime)
+	session.Mu.RLock()
+	systemIndex := session.PlayerID
+	session.Mu.RUnlock()
 	
-	// Server timestamp (8 bytes, big-endian)
-	response.WriteUint64(uint64(time.Now().UnixMilli()))
+	payload := protocol.BuildConnectionRequestAccepted(
+		session.Addr,
+		systemIndex,
+		[]*net.UDPAddr{rh.localAddr(session)},
+		clientTime, // echo client time
+		uint64(rh.clock.Now().UnixMilli()),
+	)
 	
 	// Encapsulate in RELIABLE_ORDERED frame
 	encap := &protocol.EncapsulatedPacket{
 		Reliability: protocol.RELIABLE_ORDERED,
-		Payload:     response.GetData(),
+		Payload:     payload,
 	}
 	session.AddToQueue(encap)
 	
 	log.Printf("✅ Queued ID_CONNECTION_REQUEST_ACCEPTED to %s", session.Addr.String())
 }
 
+// localAddr is the server's own address as reported to a session's client
+func (rh *RakNetHandler) localAddr(session *protocol.Session) *net.UDPAddr {
+	if conn := rh.sessionConn(session); conn != nil {
+		if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok && addr.IP.To4() != nil && !addr.IP.IsUnspecified() {
+			return addr
+		}
+	}
+	
+	port := 7777
+	if rh.server != nil {
+		port = rh.server.Port
+	}
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+}
+
 func (rh *RakNetHandler) sendConnectionRequestAccepted(session *protocol.Session) {
 	log.Printf("=== Sending 0x10 ID_CONNECTION_REQUEST_ACCEPTED (RAW UDP) ===")
 	
@@ -3674,13 +3591,13 @@ func (rh *RakNetHandler) sendConnectionRequestAccepted(session *protocol.Session
 	payload = append(payload, byte(serverPort>>8), byte(serverPort))
 	
 	// Timestamp (8 bytes big-endian)
-	timestamp := uint64(time.Now().UnixMilli())
+	timestamp := uint64(rh.clock.Now().UnixMilli())
 	for i := 7; i >= 0; i-- {
 		payload = append(payload, byte(timestamp>>(uint(i)*8)))
 	}
 	
 	// Send RAW UDP
-	rh.conn.WriteToUDP(payload, session.Addr)
+	rh.writeToSession(session, payload)
 	log.Printf("✅ Sent raw 0x10 (%d bytes) to %s", len(payload), session.Addr)
 	log.Printf("   Payload hex: %x", payload)
 }
@@ -3769,6 +3686,10 @@ func (rh *RakNetHandler) sendRakNetDatagramSingleWithMTU(session *protocol.Sessi
 		return
 	}
 	
+	// This path builds its own datagrams, so it enciphers each one (split
+	// fragments separately) like Session.Update does
+	payload = session.EncipherPayload(payload)
+	
 	// Calculate header size
 	headerSize := 4 + 11 // Datagram + Encapsulation (no split)
 	if isSplit {
@@ -3787,9 +3708,9 @@ func (rh *RakNetHandler) sendRakNetDatagramSingleWithMTU(session *protocol.Sessi
 	// Get and increment sequence numbers atomically
 	session.Mu.Lock()
 	datagramSeq := session.SequenceNumber
-	session.SequenceNumber++
+	session.SequenceNumber = protocol.SeqNext(session.SequenceNumber)
 	messageIndex := session.MessageIndex
-	session.MessageIndex++
+	session.MessageIndex = protocol.SeqNext(session.MessageIndex)
 	
 	// Get ordering index - either use provided one (for split) or increment new one
 	var orderIndex uint32
@@ -3802,7 +3723,7 @@ func (rh *RakNetHandler) sendRakNetDatagramSingleWithMTU(session *protocol.Sessi
 			session.ChannelOrderIndex = make(map[uint8]uint32)
 		}
 		orderIndex = session.ChannelOrderIndex[channel]
-		session.ChannelOrderIndex[channel]++
+		session.ChannelOrderIndex[channel] = protocol.SeqNext(session.ChannelOrderIndex[channel])
 		
 		// DEBUG: Log orderIndex increment for channel 0
 		if channel == 0 {
@@ -3880,7 +3801,8 @@ func (rh *RakNetHandler) sendRakNetDatagramSingleWithMTU(session *protocol.Sessi
 	session.StorePendingACK(datagramSeq, copyBytes(packet))
 	
 	// Send packet
-	rh.conn.WriteToUDP(packet, session.Addr)
+	rh.writeToSession(session, packet)
+	session.Counters.AddSent(protocol.RELIABLE_ORDERED)
 	
 	if isSplit && splitInfo != nil {
 		log.Printf("✅ Sent SPLIT fragment seq=%d msg=%d order=%d ch=%d splitID=%d idx=%d/%d payloadLen=%d totalSize=%d MTU=%d", 
@@ -3963,16 +3885,13 @@ func (rh *RakNetHandler) sendRakNetDatagramSplit(session *protocol.Session, payl
 		session.ChannelOrderIndex = make(map[uint8]uint32)
 	}
 	sharedOrderIndex := session.ChannelOrderIndex[channel]
-	session.ChannelOrderIndex[channel]++
+	session.ChannelOrderIndex[channel] = protocol.SeqNext(session.ChannelOrderIndex[channel])
 	session.Mu.Unlock()
 	
 	log.Printf("🔒 MTU locked at %d for split packet transmission, orderIndex=%d (shared)", mtu, sharedOrderIndex)
 	
-	// Calculate max chunk size
-	// Datagram header: 4 bytes
-	// Encapsulation header with split: 11 + 8 = 19 bytes (FIXED: was 21!)
-	headerSize := 4 + 19
-	maxChunkSize := int(mtu) - protocol.MTU_SAFETY_MARGIN - headerSize
+	// Calculate max chunk size: datagram + ordered encapsulation + split header
+	maxChunkSize := protocol.GetSafeSplitPayloadSize(mtu, true)
 	
 	if maxChunkSize <= 0 {
 		log.Printf("❌ ERROR: MTU %d too small for split packets", mtu)
@@ -4053,9 +3972,9 @@ func (rh *RakNetHandler) sendRakNetReliable(session *protocol.Session, payload [
 	// Get sequence numbers (atomic operation via session mutex)
 	session.Mu.Lock()
 	seq := session.SequenceNumber
-	session.SequenceNumber++
+	session.SequenceNumber = protocol.SeqNext(session.SequenceNumber)
 	reliableSeq := session.MessageIndex
-	session.MessageIndex++
+	session.MessageIndex = protocol.SeqNext(session.MessageIndex)
 	session.Mu.Unlock()
 	
 	lengthBits := uint16(len(payload) * 8)
@@ -4104,31 +4023,75 @@ func (rh *RakNetHandler) sendRakNetReliable(session *protocol.Session, payload [
 	session.StorePendingACK(seq, copyBytes(packetBytes))
 	
 	// Send packet
-	rh.conn.WriteToUDP(packetBytes, session.Addr)
+	rh.writeToSession(session, packetBytes)
+	session.Counters.AddSent(protocol.RELIABLE)
 }
 
+// handleNewIncomingConnection finalizes the connection once the client confirms
+// ID_CONNECTION_REQUEST_ACCEPTED, then starts the player join
 func (rh *RakNetHandler) handleNewIncomingConnection(session *protocol.Session, packet *protocol.RakNetPacket) {
-	session.State = protocol.STATE_CONNECTED
-	log.Printf("Client connected: %s", session.Addr.String())
+	conn, err := protocol.ParseNewIncomingConnection(packet.Payload)
+	if err != nil {
+		log.Printf("❌ Dropped new incoming connection from %s: %v", session.Addr.String(), err)
+		return
+	}
+	
+	session.Mu.Lock()
+	if session.State >= protocol.STATE_CONNECTED {
+		session.Mu.Unlock()
+		return // Duplicate (retransmitted) 0x13
+	}
+	session.SetState(protocol.STATE_CONNECTED)
+	session.InternalAddrs = conn.InternalAddrs
+	session.Mu.Unlock()
+	
+	log.Printf("Client connected: %s (server address %s, %d internal addresses)",
+		session.Addr.String(), conn.ServerAddr, len(conn.InternalAddrs))
+	
+	if rh.server != nil {
+		rh.server.handlePlayerJoin(session, packet)
+	}
 }
 
 func (rh *RakNetHandler) handleDisconnection(session *protocol.Session) {
 	log.Printf("Client disconnected: %s", session.Addr.String())
-	
+	rh.forgetSession(session)
+	rh.server.disconnectSession(session, DisconnectQuit, "")
+}
+
+// forgetSession removes a session from every lookup map
+func (rh *RakNetHandler) forgetSession(session *protocol.Session) {
 	rh.mu.Lock()
-	delete(rh.sessions, session.Addr.String())
-	rh.mu.Unlock()
+	defer rh.mu.Unlock()
+	
+	key := session.Addr.String()
+	if rh.sessions[key] == session {
+		delete(rh.sessions, key)
+	}
+	if ipKey := session.Addr.IP.String(); rh.sessionsByIP[ipKey] == session {
+		delete(rh.sessionsByIP, ipKey)
+	}
+	if session.GUID != 0 && rh.sessionsByGUID[session.GUID] == session {
+		delete(rh.sessionsByGUID, session.GUID)
+	}
+	delete(rh.pendingDisconnects, session) // gone already, nothing left to close
+	session.ReleaseHalfOpen()
 }
 
+// handleConnectedPingInternal answers a client's ID_CONNECTED_PING with its
+// timestamp followed by ours
 func (rh *RakNetHandler) handleConnectedPingInternal(session *protocol.Session, packet *protocol.RakNetPacket) {
 	bs := protocol.NewBitStream(packet.Payload)
-	pingTime, _ := bs.ReadUint64()
+	pingTime, err := bs.ReadUint64()
+	if err != nil {
+		log.Printf("⚠️ Invalid ID_CONNECTED_PING from %v: %v", session.Addr, err)
+		return
+	}
 	
-	// Send pong
 	response := protocol.NewEmptyBitStream()
 	response.WriteByte(protocol.ID_CONNECTED_PONG)
 	response.WriteUint64(pingTime)
-	response.WriteUint64(uint64(time.Now().UnixNano() / int64(time.Millisecond)))
+	response.WriteUint64(uint64(rh.clock.Now().UnixMilli()))
 	
 	encap := &protocol.EncapsulatedPacket{
 		Reliability: protocol.UNRELIABLE,
@@ -4137,53 +4100,63 @@ func (rh *RakNetHandler) handleConnectedPingInternal(session *protocol.Session,
 	session.AddToQueue(encap)
 }
 
-func (rh *RakNetHandler) handleACK(data []byte, addr *net.UDPAddr) {
-	if len(data) < 4 {
+// sendConnectedPing queues an ID_CONNECTED_PING carrying our send time once a
+// connected session has been idle for KEEPALIVE_INTERVAL; the client echoes it
+// back in ID_CONNECTED_PONG so we can measure the round trip.
+func (rh *RakNetHandler) sendConnectedPing(session *protocol.Session, now time.Time) {
+	session.Mu.Lock()
+	if session.State < protocol.STATE_CONNECTED ||
+		now.Sub(session.LastSendTime) < protocol.KEEPALIVE_INTERVAL ||
+		now.Sub(session.LastPingSent) < protocol.KEEPALIVE_INTERVAL {
+		session.Mu.Unlock()
 		return
 	}
+	session.LastPingSent = now
+	session.Mu.Unlock()
 	
-	sessionKey := addr.String()
+	ping := protocol.NewEmptyBitStream()
+	ping.WriteByte(protocol.ID_CONNECTED_PING)
+	ping.WriteUint64(uint64(now.UnixMilli()))
+	
+	session.AddToQueue(&protocol.EncapsulatedPacket{
+		Reliability: protocol.UNRELIABLE,
+		Payload:     ping.GetData(),
+	})
+}
+
+func (rh *RakNetHandler) handleConnectedPongInternal(session *protocol.Session, packet *protocol.RakNetPacket, now time.Time) {
+	bs := protocol.NewBitStream(packet.Payload)
+	pingTime, err := bs.ReadUint64()
+	if err != nil {
+		log.Printf("⚠️ Invalid ID_CONNECTED_PONG from %v: %v", session.Addr, err)
+		return
+	}
 	
+	rtt := time.Duration(now.UnixMilli()-int64(pingTime)) * time.Millisecond
+	if rtt < 0 {
+		return
+	}
+	session.SetRTT(rtt)
+}
+
+func (rh *RakNetHandler) handleACK(data []byte, addr *net.UDPAddr) {
 	rh.mu.RLock()
-	session, exists := rh.sessions[sessionKey]
+	session, exists := rh.sessions[addr.String()]
 	rh.mu.RUnlock()
 	
 	if !exists {
 		return
 	}
 	
-	// Parse ACK packet format
-	count := binary.BigEndian.Uint16(data[1:3])
-	offset := 3
-	
-	for i := 0; i < int(count); i++ {
-		if offset+6 > len(data) {
-			break
-		}
-		
-		// Read sequence numbers (3 bytes LITTLE-endian each)
-		minSeq := uint32(data[offset]) | uint32(data[offset+1])<<8 | uint32(data[offset+2])<<16
-		maxSeq := uint32(data[offset+3]) | uint32(data[offset+4])<<8 | uint32(data[offset+5])<<16
-		
-		for seq := minSeq; seq <= maxSeq; seq++ {
-			session.DeletePendingACK(seq)
-		}
-		
-		offset += 6
-	}
+	// Both lookups are bounded for the whole ACK, however its ranges are drawn
+	session.HandleACK(data)
 	
 	// No response needed for ACK
 }
 
 func (rh *RakNetHandler) handleNACK(data []byte, addr *net.UDPAddr) {
-	if len(data) < 3 {
-		return
-	}
-	
-	sessionKey := addr.String()
-	
 	rh.mu.RLock()
-	session, exists := rh.sessions[sessionKey]
+	session, exists := rh.sessions[addr.String()]
 	rh.mu.RUnlock()
 	
 	if !exists {
@@ -4191,64 +4164,36 @@ func (rh *RakNetHandler) handleNACK(data []byte, addr *net.UDPAddr) {
 		return
 	}
 	
-	// Parse NACK packet format (CORRECT FORMAT)
-	// Format: 0xA0 + count(2 bytes LITTLE-endian) + records
-	// Each record: minSeq(3 bytes LE) + maxSeq(3 bytes LE) = 6 bytes
-	count := binary.LittleEndian.Uint16(data[1:3])
-	
-	// CRITICAL: Validate count
-	if count == 0 || count > 512 {
-		log.Printf("⚠️ NACK count invalid: %d (expected 1-512), ignoring packet", count)
-		return
+	// Datagrams in the recovery queue are requeued under a new sequence; raw
+	// handshake datagrams come back to be resent as they are
+	resends := session.HandleNACK(data)
+	for _, packetData := range resends {
+		rh.conn.WriteToUDP(packetData, addr)
 	}
 	
-	offset := 3
-	expectedLen := 3 + (int(count) * 6)
-	
-	if len(data) < expectedLen {
-		log.Printf("⚠️ NACK packet too short: %d bytes (expected %d for count=%d)", len(data), expectedLen, count)
-		return
+	log.Printf("✅ Retransmitted %d handshake packets in response to NACK from %s", len(resends), addr)
+}
+
+func (rh *RakNetHandler) SendPacket(session *protocol.Session, packet *protocol.RakNetPacket, reliability byte) {
+	encap := &protocol.EncapsulatedPacket{
+		Reliability: reliability,
+		Payload:     packet.Serialize(),
 	}
-	
-	log.Printf("⚠️ Received NACK from %s, count: %d", addr, count)
-	
-	retransmitCount := 0
-	
-	for i := 0; i < int(count); i++ {
-		if offset+6 > len(data) {
-			log.Printf("   ⚠️ NACK packet truncated at entry %d", i)
-			break
-		}
-		
-		// Read min and max sequence (each 3 bytes LITTLE-endian)
-		minSeq := uint32(data[offset]) | uint32(data[offset+1])<<8 | uint32(data[offset+2])<<16
-		maxSeq := uint32(data[offset+3]) | uint32(data[offset+4])<<8 | uint32(data[offset+5])<<16
-		
-		log.Printf("   📦 NACK range: %d-%d", minSeq, maxSeq)
-		
-		// Retransmit all packets in range
-		for seq := minSeq; seq <= maxSeq && seq < minSeq+100; seq++ {
-			if packetData, exists := session.GetPendingACK(seq); exists {
-				rh.conn.WriteToUDP(packetData, addr)
-				retransmitCount++
-				log.Printf("   ✅ Retransmitted packet seq=%d (%d bytes)", seq, len(packetData))
-			} else {
-				log.Printf("   ⚠️ Cannot retransmit seq=%d (not in PendingACK map)", seq)
-			}
-		}
-		
-		offset += 6
+	if err := session.AddToQueue(encap); err != nil {
+		log.Printf("❌ Dropped packet 0x%02X to %v: %v", packet.PacketID, session.Addr, err)
 	}
-	
-	log.Printf("✅ Retransmitted %d packets in response to NACK", retransmitCount)
 }
 
-func (rh *RakNetHandler) SendPacket(session *protocol.Session, packet *protocol.RakNetPacket, reliability byte) {
+// SendPacketWithAck queues a reliable packet and calls onAck once the client confirms it
+func (rh *RakNetHandler) SendPacketWithAck(session *protocol.Session, packet *protocol.RakNetPacket, reliability byte, onAck func()) {
 	encap := &protocol.EncapsulatedPacket{
 		Reliability: reliability,
 		Payload:     packet.Serialize(),
+		OnAck:       onAck,
+	}
+	if err := session.AddToQueue(encap); err != nil {
+		log.Printf("❌ Dropped packet 0x%02X to %v: %v", packet.PacketID, session.Addr, err)
 	}
-	session.AddToQueue(encap)
 }
 
 func (rh *RakNetHandler) Update() {
@@ -4261,10 +4206,35 @@ func (rh *RakNetHandler) Update() {
 	
 	// Just update sessions, don't check timeout here
 	// Timeout checking is done by CleanupStaleSessions() called every 5 seconds
+	now := rh.clock.Now()
 	for _, session := range sessions {
-		session.Update(rh.conn)
+		rh.sendConnectedPing(session, now)
+		session.Update(rh.sessionConn(session))
+		
+		// A datagram the client never ACKed after MaxResends: the link is gone
+		if session.ResendsExhausted() {
+			log.Printf("🧹 Closing unreachable session %s", session.Addr)
+			rh.closeSession(session, DisconnectTimeout, "")
+		}
+	}
+	rh.closeFinishedDisconnects(now)
+}
+// Session timeouts by state. Half-open handshakes get a much shorter timeout
+// (Server.HandshakeTimeout) so a scanner opening thousands of them cannot pile
+// up sessions.
+const (
+	sessionTimeout   = 30 * time.Second
+	inGameTimeout    = 300 * time.Second
+)
+
+// handshakeTimeout returns Server.HandshakeTimeout, or the default when unset
+func (rh *RakNetHandler) handshakeTimeout() time.Duration {
+	if timeout := rh.server.HandshakeTimeout; timeout > 0 {
+		return timeout
 	}
+	return DefaultHandshakeTimeout
 }
+
 // CleanupStaleSessions - Remove sessions that have timed out (REAL timeout only)
 // This is called periodically by the server's cleanup loop
 // CRITICAL: Only delete sessions on REAL timeout (>30s no traffic), NOT on packet anomalies
@@ -4276,20 +4246,25 @@ func (rh *RakNetHandler) CleanupStaleSessions() {
 	}
 	rh.mu.RUnlock()
 
-	now := time.Now()
+	now := rh.clock.Now()
+	halfOpenTimeout := rh.handshakeTimeout()
 
 	for addr, session := range sessions {
 		idleTime := now.Sub(session.LastReceiveTime)
 
 		// Timeout berbeda berdasarkan state
-		timeout := 30 * time.Second
+		timeout := sessionTimeout
 		session.Mu.RLock()
-		gameEntrySent := session.GameEntrySent
+		gameEntrySent := session.ConnectPhase >= protocol.PhaseGameEntrySent
+		halfOpen := session.State < protocol.STATE_CONNECTED
 		session.Mu.RUnlock()
 		
 		if gameEntrySent {
 			// Player sudah spawn — beri waktu lebih lama
-			timeout = 300 * time.Second
+			timeout = inGameTimeout
+		} else if halfOpen {
+			// Handshake never completed (scanner, dropped client) — reap quickly
+			timeout = halfOpenTimeout
 		}
 
 		// Only delete if REAL timeout occurred
@@ -4323,7 +4298,7 @@ func (rh *RakNetHandler) CleanupStaleSessions() {
 					Payload:     disconnectPacket.GetData(),
 				}
 				session.AddToQueue(encap)
-				session.Update(rh.conn)
+				session.Update(rh.sessionConn(session))
 			}
 
 			// Remove from all maps
@@ -4331,6 +4306,7 @@ func (rh *RakNetHandler) CleanupStaleSessions() {
 
 			// Remove from sessions map (by IP:Port)
 			delete(rh.sessions, addr)
+			session.ReleaseHalfOpen()
 
 			// Remove from sessionsByIP map (by IP only)
 			if session.Addr != nil {
@@ -4346,17 +4322,57 @@ func (rh *RakNetHandler) CleanupStaleSessions() {
 			rh.mu.Unlock()
 
 			log.Printf("   ✅ Session %s removed from all maps (IP, GUID, sessions)", addr)
+			rh.server.disconnectSession(session, DisconnectTimeout, "")
+		}
+	}
+
+	// Forget which socket long-idle addresses arrived on
+	rh.conn.prune(now.Add(-inGameTimeout))
+	
+	// MTU probes from handshakes that never reached OCR2
+	rh.mu.Lock()
+	for key, probe := range rh.mtuProbes {
+		if now.Sub(probe.at) > halfOpenTimeout {
+			delete(rh.mtuProbes, key)
 		}
 	}
+	rh.mu.Unlock()
+	
+	rh.recountHalfOpen()
+	
+	// Query sources that went quiet
+	rh.pruneQueryLimiter(now.Add(-time.Minute))
+	rh.pruneConnectLimiter(now.Add(-time.Minute))
 }
 
 
+// ActiveSessionCount returns the number of distinct sessions that have completed
+// the handshake. Half-open handshakes do not take a player slot.
+func (rh *RakNetHandler) ActiveSessionCount() int {
+	count := 0
+	for _, session := range rh.GetSessions() {
+		session.Mu.RLock()
+		if session.State >= protocol.STATE_CONNECTED {
+			count++
+		}
+		session.Mu.RUnlock()
+	}
+	return count
+}
+
 func (rh *RakNetHandler) GetSessions() []*protocol.Session {
 	rh.mu.RLock()
 	defer rh.mu.RUnlock()
 	
+	// A session can be registered under several keys after a port rebind,
+	// so only return each session once
+	seen := make(map[*protocol.Session]bool, len(rh.sessions))
 	sessions := make([]*protocol.Session, 0, len(rh.sessions))
 	for _, session := range rh.sessions {
+		if seen[session] {
+			continue
+		}
+		seen[session] = true
 		sessions = append(sessions, session)
 	}
 	return sessions
@@ -4405,7 +4421,7 @@ func (rh *RakNetHandler) handleOpenConnectionRequest2Proper(data []byte, addr *n
 		parsedMTU, err := bs.ReadUint16()
 		if err == nil {
 			// Sanitize MTU using helper function
-			mtu = sanitizeMTU(parsedMTU)
+			mtu = sanitizeMTU(parsedMTU, rh.maxMTU())
 		} else {
 			log.Printf("⚠️ Failed to parse MTU, using default %d", mtu)
 		}
@@ -4457,6 +4473,7 @@ func (rh *RakNetHandler) handleOpenConnectionRequest2Proper(data []byte, addr *n
 	// Only reset if session doesn't exist OR is in UNCONNECTED state
 	// DO NOT reset if session is active (CONNECTING, CONNECTED, READY)
 	rh.mu.Lock()
+	defer rh.mu.Unlock()
 	
 	session, exists := rh.sessions[sessionKey]
 	
@@ -4464,8 +4481,8 @@ func (rh *RakNetHandler) handleOpenConnectionRequest2Proper(data []byte, addr *n
 		// Session already exists - check state
 		session.Mu.RLock()
 		currentState := session.State
-		splitInProgress := session.SplitInProgress
-		gameEntrySent := session.GameEntrySent
+		splitInProgress := session.MTULocked()
+		gameEntrySent := session.ConnectPhase >= protocol.PhaseGameEntrySent
 		session.Mu.RUnlock()
 		
 		// CRITICAL: Don't reset if session is active OR has sent streaming data
@@ -4486,9 +4503,8 @@ func (rh *RakNetHandler) handleOpenConnectionRequest2Proper(data []byte, addr *n
 				log.Printf("✅ Active session for %s exists (state=%d, gameEntrySent=%v), preserving state", 
 					sessionKey, currentState, gameEntrySent)
 			}
-			session.LastReceiveTime = time.Now()
+			session.LastReceiveTime = rh.clock.Now()
 			session.Mu.Unlock()
-			rh.mu.Unlock()
 			return
 		}
 		
@@ -4497,19 +4513,18 @@ func (rh *RakNetHandler) handleOpenConnectionRequest2Proper(data []byte, addr *n
 		log.Printf("   Old state: MTU=%d, MsgIdx=%d, OrderIdx=%d, SeqNum=%d", 
 			session.MTU, session.MessageIndex, session.OrderIndex, session.SequenceNumber)
 		delete(rh.sessions, sessionKey)
+		session.ReleaseHalfOpen()
 		log.Printf("   ✅ Stale session deleted")
 	}
 	
 	// Create fresh session with validated MTU
-	session = protocol.NewSession(addr, mtu)
+	session = rh.newSession(addr, mtu)
 	rh.sessions[sessionKey] = session
-	session.State = protocol.STATE_CONNECTING
-	session.LastReceiveTime = time.Now()
+	session.SetState(protocol.STATE_CONNECTING)
+	session.LastReceiveTime = rh.clock.Now()
 	
 	log.Printf("✅ Created NEW session for %s with MTU %d (all indices start from 0)", sessionKey, mtu)
 	log.Printf("   Fresh state: MsgIdx=0, OrderIdx=0, SeqNum=0, SplitID=0")
-	
-	rh.mu.Unlock()
 }
 
 // handleOpenConnectionRequest1Short - Handle 22-byte variant as Request 1
@@ -4559,7 +4574,7 @@ func (rh *RakNetHandler) handleConnectedPing(data []byte, addr *net.UDPAddr) {
 	reply := make([]byte, 17)
 	reply[0] = 0x03 // Connected Pong
 	binary.BigEndian.PutUint64(reply[1:9], pingTime)
-	binary.BigEndian.PutUint64(reply[9:17], uint64(time.Now().UnixMilli()))
+	binary.BigEndian.PutUint64(reply[9:17], uint64(rh.clock.Now().UnixMilli()))
 	
 	rh.conn.WriteToUDP(reply, addr)
 	log.Printf("✅ Sent Connected Pong to %s", addr)
@@ -4695,6 +4710,7 @@ func (rh *RakNetHandler) sendRequestClassResponse(session *protocol.Session) {
 // sendPlayerSpawn - Send SA-MP 0x04 Player Spawn
 func (rh *RakNetHandler) sendPlayerSpawn(session *protocol.Session) {
 	log.Printf("=== Sending SA-MP 0x04 Player Spawn ===")
+	rh.markMilestone(session, protocol.MilestoneFirstSpawn)
 	
 	buf := new(bytes.Buffer)
 	buf.WriteByte(0x04) // SA-MP packet ID: PLAYER_SPAWN
@@ -4731,6 +4747,7 @@ func (rh *RakNetHandler) getOrMigrateSession(addr *net.UDPAddr) (*protocol.Sessi
 		
 		// Update port
 		sess.Addr = addr
+		sess.Conn = rh.conn.connFor(addr)
 		
 		// Register new port
 		rh.sessions[key] = sess
@@ -4747,7 +4764,7 @@ func (rh *RakNetHandler) createSession(addr *net.UDPAddr, mtu uint16) *protocol.
 	rh.mu.Lock()
 	defer rh.mu.Unlock()
 	
-	sess := protocol.NewSession(addr, mtu)
+	sess := rh.newSession(addr, mtu)
 	key := addr.String()
 	ip := addr.IP.String()
 	
@@ -4834,8 +4851,8 @@ func (rh *RakNetHandler) sendFullHandshakeSequence(addr *net.UDPAddr) {
 	
 	// CRITICAL: Set state to READY after handshake complete
 	session.Mu.Lock()
-	session.State = protocol.STATE_READY
-	session.HandshakeSent = true
+	session.SetState(protocol.STATE_READY)
+	session.ConnectPhase = max(session.ConnectPhase, protocol.PhaseHandshakeSent)
 	session.Mu.Unlock()
 	
 	log.Printf("✅ Full handshake sequence complete - state=READY, waiting for client keepalive to trigger 0x04")
@@ -4856,7 +4873,7 @@ func (rh *RakNetHandler) sendPostStreamingSequence(addr *net.UDPAddr) {
 	// Log state
 	session.Mu.RLock()
 	state := session.State
-	gameEntrySent := session.GameEntrySent
+	gameEntrySent := session.ConnectPhase >= protocol.PhaseGameEntrySent
 	session.Mu.RUnlock()
 	log.Printf("📊 [sendPostStreamingSequence] sessionKey='%s', state=%d, gameEntrySent=%v", sessionKey, state, gameEntrySent)
 	
@@ -4871,9 +4888,10 @@ func (rh *RakNetHandler) sendPostStreamingSequence(addr *net.UDPAddr) {
 	rh.conn.WriteToUDP(protocol.PacketE3_21, addr)
 	log.Printf("✅✅✅ E3:21 SENT — now sending InitGame and spawn RPCs!")
 	
-	// Step 2: Send spawn RPC sequence (InitGame → SetSpawnInfo → SpawnPlayer → TogglePlayerControllable)
+	// Step 2: Start the spawn RPC flow (InitGame → SetSpawnInfo → SpawnPlayer → TogglePlayerControllable)
+	// Each RPC is sent after the client ACKs the previous one.
 	// This MUST happen BEFORE world streaming packets
-	rh.sendSpawnSequence(session)
+	rh.startConnectFlow(session)
 	
 	// Get final orderIndex after spawn
 	session.Mu.RLock()
@@ -4882,7 +4900,7 @@ func (rh *RakNetHandler) sendPostStreamingSequence(addr *net.UDPAddr) {
 	
 	log.Printf("📊 RakNet counters after spawn → seq=%d msg=%d order[ch0]=%d", 
 		session.SequenceNumber, session.MessageIndex, finalOrderIndex)
-	log.Printf("✅ Spawn sequence complete! Waiting for client sync (0x28) before world streaming...")
+	log.Printf("✅ Spawn flow started! Waiting for client sync (0x28) before world streaming...")
 	log.Printf("🎮 Client should now exit 'Welcome to Grand Larceny' and prepare to spawn...")
 }
 
@@ -4984,199 +5002,6 @@ func (rh *RakNetHandler) sendWorldStreamingPackets(session *protocol.Session) {
 	log.Printf("🎮 World streaming complete! Player should see world objects now.")
 }
 
-// sendSpawnSequence - Send spawn RPC sequence after E3:21
-func (rh *RakNetHandler) sendSpawnSequence(session *protocol.Session) {
-	log.Printf("🎮 [sendSpawnSequence] Starting spawn RPC sequence...")
-	
-	// Get current orderIndex for channel 0 BEFORE sending
-	session.Mu.RLock()
-	if session.ChannelOrderIndex == nil {
-		session.ChannelOrderIndex = make(map[uint8]uint32)
-	}
-	currentOrderIndex := session.ChannelOrderIndex[0]
-	session.Mu.RUnlock()
-	
-	log.Printf("📊 RakNet counters at spawn start → seq=%d msg=%d order[ch0]=%d", 
-		session.SequenceNumber, session.MessageIndex, currentOrderIndex)
-	log.Printf("⚠️ ORDER DEBUG → orderIndex BEFORE spawn RPCs = %d", currentOrderIndex)
-	
-	// ============================================================
-	// CRITICAL SA-MP 0.3.7 SPAWN SEQUENCE:
-	// 1. InitGame (0x2B) - World configuration
-	// 2. SetGameModeText (0x3E) - Gamemode name
-	// 3. SetWorldTime (0x29) - Time of day
-	// 4. SetWeather (0x0B) - Weather
-	// 5. SetSpawnInfo (0x2C) - Spawn location
-	// 6. SpawnPlayer (0x34) - Trigger spawn
-	// 7. TogglePlayerControllable (0x15) - Enable controls
-	// ============================================================
-	
-	// 0️⃣ InitGame RPC (0x2B) - CRITICAL: Must be sent FIRST
-	rpcPayload0 := protocol.BuildInitGameRPC(
-		true,                    // zoneNames - enable zone names
-		false,                   // useCJWalk - use CJ walk style
-		true,                    // allowWeapons - allow weapons
-		false,                   // limitGlobalChatRadius - no chat radius limit
-		0.0,                     // globalChatRadius - unlimited
-		true,                    // stuntBonus - enable stunt bonus
-		70.0,                    // nameTagDrawDistance - 70 meters
-		false,                   // disableEnterExits - allow interior entry
-		true,                    // nameTagLOS - name tags require line of sight
-		false,                   // manualVehicleEngineAndLights - automatic
-		1,                       // spawnsAvailable - number of spawn classes
-		0,                       // playerID - will be set by server
-		true,                    // showNameTags - show player name tags
-		1,                       // showPlayerMarkers - show on radar (1=always)
-		uint8(rh.server.WorldTime), // worldTimeHour - from config
-		uint8(rh.server.Weather),   // weather - from config
-		0.008,                   // gravity - default SA-MP gravity
-		true,                    // lanMode - LAN mode enabled
-		0,                       // deathDropMoney - no money drop on death
-		false,                   // instagib - normal damage
-		40,                      // onFootRate - sync rate
-		40,                      // inCarRate - sync rate
-		40,                      // weaponRate - sync rate
-		1000,                    // multiplier - lag comp multiplier
-		1,                       // lagCompensation - enabled
-		rh.server.ServerName,    // hostname - from config (SA-MP 0.3.7-R2)
-		false,                   // vehicleFriendlyFire - disabled
-		false,                   // usePlayerPedAnims - use default anims
-		-20000.0,                // worldBoundsMinX
-		-20000.0,                // worldBoundsMinY
-		20000.0,                 // worldBoundsMaxX
-		20000.0,                 // worldBoundsMaxY
-		rh.server.GameMode,      // gamemodeText - from config
-		rh.server.MapName,       // mapName - from config
-	)
-	
-	packet0 := protocol.EncodeRPCPacket(rpcPayload0)
-	log.Printf("   📦 InitGame (0x2B): packet[0]=0x%02X packet[1]=0x%02X size=%d bytes", 
-		packet0[0], packet0[1], len(packet0))
-	log.Printf("   📦 InitGame payload size (without 0x7C wrapper): %d bytes", len(rpcPayload0))
-	log.Printf("   📦 InitGame TOTAL packet size (with 0x7C wrapper): %d bytes", len(packet0))
-	if len(packet0) < 90 {
-		log.Printf("   ❌❌❌ CRITICAL: InitGame packet TOO SMALL! Expected >90 bytes, got %d bytes", len(packet0))
-		log.Printf("   📦 InitGame full hex: %02X", packet0)
-	} else {
-		log.Printf("   ✅ InitGame packet size OK: %d bytes", len(packet0))
-		log.Printf("   📦 InitGame hex (first 64 bytes): %02X", packet0[:64])
-	}
-	rh.sendRakNetDatagram(session, packet0)
-	
-	session.Mu.RLock()
-	orderAfter0 := session.ChannelOrderIndex[0]
-	session.Mu.RUnlock()
-	log.Printf("✅ Sent RPC InitGame (0x2B) - %d bytes [order[ch0]=%d]", len(packet0), orderAfter0-1)
-	log.Printf("   ⚠️ InitGame config: weather=%d worldtime=%d:00 gravity=0.008", rh.server.Weather, rh.server.WorldTime)
-	
-	// 1️⃣ SetGameModeText RPC (0x3E) - MUST match config gamemode
-	rpcPayload1 := protocol.BuildSetGameModeTextRPC(rh.server.GameMode)
-	packet1 := protocol.EncodeRPCPacket(rpcPayload1)
-	log.Printf("   📦 SetGameModeText (0x3E): packet[0]=0x%02X packet[1]=0x%02X size=%d bytes", 
-		packet1[0], packet1[1], len(packet1))
-	rh.sendRakNetDatagram(session, packet1)
-	
-	session.Mu.RLock()
-	orderAfter1 := session.ChannelOrderIndex[0]
-	session.Mu.RUnlock()
-	log.Printf("✅ Sent RPC SetGameModeText (0x3E) = '%s' - %d bytes [order[ch0]=%d]", 
-		rh.server.GameMode, len(packet1), orderAfter1-1)
-	
-	// 2️⃣ SetWorldTime RPC (0x29) - MUST match config worldtime
-	rpcPayload2 := protocol.BuildSetWorldTimeRPC(uint8(rh.server.WorldTime))
-	packet2 := protocol.EncodeRPCPacket(rpcPayload2)
-	log.Printf("   📦 SetWorldTime (0x29): packet[0]=0x%02X packet[1]=0x%02X size=%d bytes", 
-		packet2[0], packet2[1], len(packet2))
-	rh.sendRakNetDatagram(session, packet2)
-	
-	session.Mu.RLock()
-	orderAfter2 := session.ChannelOrderIndex[0]
-	session.Mu.RUnlock()
-	log.Printf("✅ Sent RPC SetWorldTime (0x29) = %d:00 - %d bytes [order[ch0]=%d]", 
-		rh.server.WorldTime, len(packet2), orderAfter2-1)
-	
-	// 3️⃣ SetWeather RPC (0x0B) - MUST match config weather AND rules query
-	rpcPayload3 := protocol.BuildSetWeatherRPC(uint8(rh.server.Weather))
-	packet3 := protocol.EncodeRPCPacket(rpcPayload3)
-	log.Printf("   📦 SetWeather (0x0B): packet[0]=0x%02X packet[1]=0x%02X size=%d bytes", 
-		packet3[0], packet3[1], len(packet3))
-	rh.sendRakNetDatagram(session, packet3)
-	
-	session.Mu.RLock()
-	orderAfter3 := session.ChannelOrderIndex[0]
-	session.Mu.RUnlock()
-	log.Printf("✅ Sent RPC SetWeather (0x0B) = %d - %d bytes [order[ch0]=%d]", 
-		rh.server.Weather, len(packet3), orderAfter3-1)
-	log.Printf("   ⚠️ CRITICAL → Weather MUST match: InitGame=%d Rules=%d SetWeather=%d", 
-		rh.server.Weather, rh.server.Weather, rh.server.Weather)
-	
-	// 4️⃣ SetSpawnInfo RPC (0x2C) - Spawn location and weapons
-	rpcPayload4 := protocol.BuildSetSpawnInfoRPC(
-		0,        // team
-		0,        // skin (CJ)
-		1958.0,   // X
-		1343.0,   // Y
-		15.0,     // Z
-		270.0,    // rotation
-		24, 200,  // weapon 1: Desert Eagle + 200 ammo
-		31, 300,  // weapon 2: M4 + 300 ammo
-		34, 50,   // weapon 3: Sniper Rifle + 50 ammo
-	)
-	
-	packet4 := protocol.EncodeRPCPacket(rpcPayload4)
-	log.Printf("   📦 SetSpawnInfo (0x2C): packet[0]=0x%02X packet[1]=0x%02X size=%d bytes", 
-		packet4[0], packet4[1], len(packet4))
-	rh.sendRakNetDatagram(session, packet4)
-	
-	session.Mu.RLock()
-	orderAfter4 := session.ChannelOrderIndex[0]
-	session.Mu.RUnlock()
-	log.Printf("✅ Sent RPC SetSpawnInfo (0x2C) - %d bytes [order[ch0]=%d]", 
-		len(packet4), orderAfter4-1)
-	
-	// 5️⃣ SpawnPlayer RPC (0x34) - Trigger spawn
-	rpcPayload5 := protocol.BuildSpawnPlayerRPC()
-	packet5 := protocol.EncodeRPCPacket(rpcPayload5)
-	log.Printf("   📦 SpawnPlayer (0x34): packet[0]=0x%02X packet[1]=0x%02X size=%d bytes", 
-		packet5[0], packet5[1], len(packet5))
-	rh.sendRakNetDatagram(session, packet5)
-	
-	session.Mu.RLock()
-	orderAfter5 := session.ChannelOrderIndex[0]
-	session.Mu.RUnlock()
-	log.Printf("✅ Sent RPC SpawnPlayer (0x34) - %d bytes [order[ch0]=%d]", 
-		len(packet5), orderAfter5-1)
-	
-	// 6️⃣ TogglePlayerControllable RPC (0x15) - Enable controls
-	rpcPayload6 := protocol.BuildTogglePlayerControllableRPC(true)
-	packet6 := protocol.EncodeRPCPacket(rpcPayload6)
-	log.Printf("   📦 TogglePlayerControllable (0x15): packet[0]=0x%02X packet[1]=0x%02X size=%d bytes", 
-		packet6[0], packet6[1], len(packet6))
-	rh.sendRakNetDatagram(session, packet6)
-	
-	session.Mu.RLock()
-	orderAfter6 := session.ChannelOrderIndex[0]
-	session.Mu.RUnlock()
-	log.Printf("✅ Sent RPC TogglePlayerControllable (0x15) = true - %d bytes [order[ch0]=%d]", 
-		len(packet6), orderAfter6-1)
-	
-	// Final validation: Ensure orderIndex increased monotonically
-	session.Mu.RLock()
-	finalOrderIndex := session.ChannelOrderIndex[0]
-	session.Mu.RUnlock()
-	
-	if finalOrderIndex <= currentOrderIndex {
-		log.Printf("❌❌❌ CRITICAL ERROR: orderIndex DID NOT INCREASE! Before=%d After=%d", currentOrderIndex, finalOrderIndex)
-		log.Printf("❌ This will cause client to reject spawn RPCs!")
-	} else {
-		log.Printf("✅ ORDER VALIDATION PASSED: orderIndex increased from %d to %d (increment=%d)", 
-			currentOrderIndex, finalOrderIndex, finalOrderIndex-currentOrderIndex)
-	}
-	
-	log.Printf("🎮 [sendSpawnSequence] Complete! Sent 7 RPCs in correct order.")
-	log.Printf("🎮 Client should now exit 'Welcome to Grand Larceny' and spawn at position (1958, 1343, 15)!")
-}
-
 // sendFullGameEntrySequence: Kirim LENGKAP semua packet game entry.
 // Phase 1: Streaming raw packets (sebelum E3:09)
 // Phase 2: E3:09 → Packet3F → E3:17 → ... → E3:21
@@ -5250,21 +5075,17 @@ func min(a, b int) int {
 }
 
 // sanitizeMTU - Validate and sanitize MTU value to safe range
-func sanitizeMTU(mtu uint16) uint16 {
-	const (
-		MIN_MTU     = 400
-		MAX_MTU     = 1500
-		DEFAULT_MTU = 1492
-	)
+func sanitizeMTU(mtu uint16, maxMTU uint16) uint16 {
+	const MIN_MTU = 400
 	
 	if mtu < MIN_MTU {
-		log.Printf("⚠️ MTU %d too small (min %d), using default %d", mtu, MIN_MTU, DEFAULT_MTU)
-		return DEFAULT_MTU
+		log.Printf("⚠️ MTU %d too small (min %d), using default %d", mtu, MIN_MTU, maxMTU)
+		return maxMTU
 	}
 	
-	if mtu > MAX_MTU {
-		log.Printf("⚠️ MTU %d too large (max %d), using default %d", mtu, MAX_MTU, DEFAULT_MTU)
-		return DEFAULT_MTU
+	if mtu > maxMTU {
+		log.Printf("⚠️ MTU %d too large (max %d), using %d", mtu, maxMTU, maxMTU)
+		return maxMTU
 	}
 	
 	log.Printf("✅ Client MTU: %d (valid)", mtu)
@@ -5284,18 +5105,34 @@ func (rh *RakNetHandler) handleCookieRequest(data []byte, addr *net.UDPAddr, ses
 		return
 	}
 
-	rh.mu.Lock()
 	if session == nil {
-		session = protocol.NewSession(addr, 576)
-		rh.sessions[addr.String()] = session
+		rh.server.audit(AuditAttempt, addr, -1, "", "")
+	}
+	
+	if session == nil && rh.rejectBanned(addr) {
+		return
+	}
+	
+	// Enforce MaxPlayers before any per-client state is allocated
+	if session == nil && rh.server != nil && rh.server.IsFull() {
+		log.Printf("🚫 Server full (%d/%d), rejecting %s", rh.server.GetPlayerCount(), rh.server.MaxPlayers, addr)
+		rh.server.audit(AuditRejected, addr, -1, "", "server full")
+		if rh.conn != nil {
+			rh.conn.WriteToUDP([]byte{protocol.ID_NO_FREE_INCOMING_CONNECTIONS}, addr)
+		}
+		return
+	}
+
+	if session == nil {
+		session = rh.newSession(addr, 576)
+		rh.registerSession(addr.String(), session)
 	}
-	rh.mu.Unlock()
 
 	// Save cookie
 	cookie := []byte{data[1], data[2], data[3]}
 	session.Mu.Lock()
 	session.Cookie = cookie
-	session.State = protocol.STATE_HANDSHAKE_SENT
+	session.SetState(protocol.STATE_HANDSHAKE_SENT)
 	session.Mu.Unlock()
 
 	rh.send0x1A(addr, session)
@@ -5308,6 +5145,7 @@ func (rh *RakNetHandler) send0x1A(addr *net.UDPAddr, session *protocol.Session)
 	lo := byte(clientPort & 0xFF)
 	packet := []byte{0x1A, hi ^ 0x82, lo ^ 0x93}
 	rh.conn.WriteToUDP(packet, addr)
+	rh.markMilestone(session, protocol.MilestoneHandshakeReply)
 	log.Printf("[0x1A] Sent to %s port=%d encoded=[%02X,%02X]",
 		addr, clientPort, hi^0x82, lo^0x93)
 }
@@ -5319,11 +5157,12 @@ func (rh *RakNetHandler) sendOpenConnectionReply2(session *protocol.Session) {
 		session.Mu.Unlock()
 		return // Already sent, don't duplicate
 	}
-	session.State = protocol.STATE_CONNECTING
+	session.SetState(protocol.STATE_CONNECTING)
 	session.Mu.Unlock()
 
 	packet := []byte{0x19, 0x00}
-	rh.conn.WriteToUDP(packet, session.Addr)
+	rh.writeToSession(session, packet)
+	rh.markMilestone(session, protocol.MilestoneHandshakeReply)
 	log.Printf("[0x19] Sent to %s", session.Addr)
 }
 
@@ -5370,7 +5209,7 @@ func (rh *RakNetHandler) sendE3Challenge(session *protocol.Session) {
 		return
 	}
 
-	rh.conn.WriteToUDP(packet, session.Addr)
+	rh.writeToSession(session, packet)
 	log.Printf("🔍 E3:00 packet length: %d", len(packet))
 	log.Printf("   E3:00 hex: %s", hex.EncodeToString(packet))
 	log.Printf("[E3:00] Sent seq=%d (%d bytes) to %s", session.SendSeq, len(packet), session.Addr)
@@ -5405,7 +5244,7 @@ func (rh *RakNetHandler) sendE3Phase1(session *protocol.Session) {
 	packet := []byte{0xE3}
 	packet = append(packet, seq...)
 	packet = append(packet, 0x01, 0x00)
-	rh.conn.WriteToUDP(packet, session.Addr)
+	rh.writeToSession(session, packet)
 	log.Printf("[E3:01] Sent seq=%d to %s", session.SendSeq, session.Addr)
 }
 
@@ -5431,7 +5270,7 @@ func (rh *RakNetHandler) sendConnectionInfo(session *protocol.Session) {
 		byte(clientPort), byte(clientPort >> 8), // client port LE
 		0x00, 0x00,
 	}
-	rh.conn.WriteToUDP(packet, session.Addr)
+	rh.writeToSession(session, packet)
 	log.Printf("[0x00] Connection info sent to %s", session.Addr)
 }
 
@@ -0,0 +1,1184 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"samp-server-go/source/protocol"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sampQuery builds a SA-MP query packet for 127.0.0.1:7777
+func sampQuery(opcode byte) []byte {
+	return []byte{'S', 'A', 'M', 'P', 127, 0, 0, 1, 0x61, 0x1E, opcode}
+}
+
+func TestGetSessionsListsReboundSessionOnce(t *testing.T) {
+	rh := NewRakNetHandler(nil, NewServer("127.0.0.1", 7777, 10))
+	session := protocol.NewSession(nil, 576)
+	rh.sessions["127.0.0.1:50010"] = session
+	rh.sessions["127.0.0.1:50011"] = protocol.NewSession(nil, 576)
+	
+	// After a port rebind the session is also reachable from the new port
+	rh.sessions["127.0.0.1:50012"] = session
+	
+	if n := len(rh.GetSessions()); n != 2 {
+		t.Errorf("Expected 2 distinct sessions, got %d", n)
+	}
+}
+
+// connectionRequest builds an ID_CONNECTION_REQUEST payload with an optional password
+func connectionRequest(guid uint64, password string) []byte {
+	payload := make([]byte, 17, 17+len(password))
+	binary.BigEndian.PutUint64(payload[0:8], guid)
+	binary.BigEndian.PutUint64(payload[8:16], 12345)
+	payload[16] = 0 // no security
+	return append(payload, []byte(password)...)
+}
+
+// queuedPacketIDs returns the first byte of every packet queued on a session
+func queuedPacketIDs(session *protocol.Session) []byte {
+	session.Mu.RLock()
+	defer session.Mu.RUnlock()
+	
+	ids := make([]byte, 0, len(session.SendQueue))
+	for _, encap := range session.SendQueue {
+		if len(encap.Payload) > 0 {
+			ids = append(ids, encap.Payload[0])
+		}
+	}
+	return ids
+}
+
+func TestQueryInfoPasswordFlag(t *testing.T) {
+	srv := newTestServer()
+	
+	response := srv.raknet.buildSAMPInfoResponse(sampQuery('i'))
+	if response[11] != 0 {
+		t.Errorf("Expected password flag 0 without a password, got %d", response[11])
+	}
+	
+	srv.Password = "secret"
+	response = srv.raknet.buildSAMPInfoResponse(sampQuery('i'))
+	if response[11] != 1 {
+		t.Errorf("Expected password flag 1 with a password, got %d", response[11])
+	}
+}
+
+func TestQueryInfoFields(t *testing.T) {
+	srv := newTestServer()
+	addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	srv.ServerName = strings.Repeat("H", 300) // longer than a 1-byte length
+	srv.GameMode = "Freeroam"
+	srv.Language = "English"
+	
+	response := srv.raknet.buildSAMPInfoResponse(sampQuery('i'))
+	if !bytes.Equal(response[:11], sampQuery('i')) {
+		t.Fatalf("Expected the request header echoed, got % X", response[:11])
+	}
+	if players := binary.LittleEndian.Uint16(response[12:14]); players != 1 {
+		t.Errorf("Expected 1 player, got %d", players)
+	}
+	if max := binary.LittleEndian.Uint16(response[14:16]); int(max) != srv.MaxPlayers {
+		t.Errorf("Expected max players %d, got %d", srv.MaxPlayers, max)
+	}
+	
+	offset := 16
+	for _, want := range []string{srv.ServerName, "Freeroam", "English"} {
+		n := int(binary.LittleEndian.Uint32(response[offset:]))
+		got := string(response[offset+4 : offset+4+n])
+		offset += 4 + n
+		if got != want {
+			t.Errorf("Expected %.20q, got %.20q", want, got)
+		}
+	}
+	if offset != len(response) {
+		t.Errorf("Expected response to end after language, %d trailing bytes", len(response)-offset)
+	}
+}
+
+func TestQueryRulesSorted(t *testing.T) {
+	srv := newTestServer()
+	srv.MapName = "San Andreas"
+	
+	response := srv.raknet.buildSAMPRulesResponse(sampQuery('r'))
+	if !bytes.Equal(response, srv.raknet.buildSAMPRulesResponse(sampQuery('r'))) {
+		t.Fatal("Expected identical bytes for repeated rules responses")
+	}
+	
+	count := int(binary.LittleEndian.Uint16(response[11:13]))
+	offset := 13
+	rules := make(map[string]string)
+	prev := ""
+	for i := 0; i < count; i++ {
+		key := string(response[offset+1 : offset+1+int(response[offset])])
+		offset += 1 + len(key)
+		value := string(response[offset+1 : offset+1+int(response[offset])])
+		offset += 1 + len(value)
+		
+		if key <= prev {
+			t.Errorf("Expected rules sorted, got %q after %q", key, prev)
+		}
+		prev = key
+		rules[key] = value
+	}
+	
+	if rules["mapname"] != "San Andreas" || rules["version"] != "0.3.7-R2" {
+		t.Errorf("Unexpected rules: %v", rules)
+	}
+}
+
+func TestQueryPingEchoesChallenge(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open client socket: %v", err)
+	}
+	defer client.Close()
+	
+	query := append(sampQuery('p'), 0xDE, 0xAD, 0xBE, 0xEF)
+	srv.raknet.HandlePacket(query, client.LocalAddr().(*net.UDPAddr))
+	
+	buf := make([]byte, 64)
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := client.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("Expected a ping response, got %v", err)
+	}
+	if !bytes.Equal(buf[:n], query) {
+		t.Errorf("Expected the ping echoed with its 4 random bytes, got % X", buf[:n])
+	}
+}
+
+func TestConnectionRequestPassword(t *testing.T) {
+	srv := newTestServer()
+	srv.Password = "secret"
+	
+	tests := []struct {
+		name     string
+		password string
+		accepted bool
+	}{
+		{"missing", "", false},
+		{"wrong", "hunter2", false},
+		{"correct", "secret", true},
+	}
+	
+	for i, tt := range tests {
+		session := addTestSession(srv, 50001+i, protocol.STATE_CONNECTING)
+		srv.raknet.handleConnectionRequest(session, &protocol.RakNetPacket{
+			PacketID: protocol.ID_CONNECTION_REQUEST,
+			Payload:  connectionRequest(uint64(100+i), tt.password),
+		})
+		
+		ids := queuedPacketIDs(session)
+		if len(ids) != 1 {
+			t.Fatalf("%s: expected 1 reply, got %d", tt.name, len(ids))
+		}
+		
+		if tt.accepted && ids[0] != protocol.ID_CONNECTION_REQUEST_ACCEPTED {
+			t.Errorf("%s: expected connection accepted, got 0x%02X", tt.name, ids[0])
+		}
+		if !tt.accepted && ids[0] != protocol.ID_INVALID_PASSWORD {
+			t.Errorf("%s: expected ID_INVALID_PASSWORD, got 0x%02X", tt.name, ids[0])
+		}
+	}
+}
+
+// newTestServerWithConn creates a test server bound to a loopback socket,
+// for handlers that write raw UDP replies
+func newTestServerWithConn(t *testing.T) *Server {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open UDP socket: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	
+	srv := NewServer("127.0.0.1", 7777, 10)
+	srv.conn = conn
+	srv.raknet = NewRakNetHandler(conn, srv)
+	return srv
+}
+
+func TestMaxPlayersEnforcedAtHandshake(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	srv.MaxPlayers = 2
+	
+	for i := 0; i < 2; i++ {
+		addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50001 + i}
+		srv.raknet.HandlePacket([]byte{0x08, 0x01, 0x02, 0x03}, addr)
+	}
+	
+	// Half-open handshakes do not take a slot until they reach connected
+	if srv.IsFull() {
+		t.Fatal("Half-open handshakes should not count against MaxPlayers")
+	}
+	for _, session := range srv.raknet.GetSessions() {
+		session.State = protocol.STATE_CONNECTED
+	}
+	
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50003}
+	srv.raknet.HandlePacket([]byte{0x08, 0x01, 0x02, 0x03}, addr)
+	
+	if len(srv.raknet.GetSessions()) != 2 {
+		t.Errorf("Expected 2 sessions, got %d", len(srv.raknet.GetSessions()))
+	}
+	if _, exists := srv.raknet.sessions["127.0.0.1:50003"]; exists {
+		t.Error("Connection beyond MaxPlayers should be rejected at handshake")
+	}
+	
+	response := srv.raknet.buildSAMPInfoResponse(sampQuery('i'))
+	if players := binary.LittleEndian.Uint16(response[12:14]); players != 2 {
+		t.Errorf("Expected query to report 2 players, got %d", players)
+	}
+}
+
+func TestConnectedPongMeasuresRTT(t *testing.T) {
+	srv := newTestServer()
+	session := addTestSession(srv, 50001, protocol.STATE_IN_GAME)
+	
+	now := time.Now()
+	session.LastSendTime = now.Add(-protocol.KEEPALIVE_INTERVAL)
+	srv.raknet.sendConnectedPing(session, now)
+	if ids := queuedPacketIDs(session); len(ids) != 1 || ids[0] != protocol.ID_CONNECTED_PING {
+		t.Fatalf("Expected one ID_CONNECTED_PING queued, got %v", ids)
+	}
+	
+	// Echo the ping time back as the client would
+	pong := &protocol.RakNetPacket{PacketID: protocol.ID_CONNECTED_PONG, Payload: session.SendQueue[0].Payload[1:]}
+	srv.raknet.handleConnectedPongInternal(session, pong, now.Add(80*time.Millisecond))
+	
+	if rtt := session.GetRTT(); rtt != 80*time.Millisecond {
+		t.Errorf("Expected RTT 80ms, got %v", rtt)
+	}
+	
+	// Pings are rate limited
+	srv.raknet.sendConnectedPing(session, now.Add(time.Second))
+	if ids := queuedPacketIDs(session); len(ids) != 1 {
+		t.Errorf("Expected no extra ping within the interval, got %d queued", len(ids))
+	}
+}
+
+func TestKeepalivePingOnlyWhenIdle(t *testing.T) {
+	srv := newTestServer()
+	session := addTestSession(srv, 50001, protocol.STATE_CONNECTED)
+	handshaking := addTestSession(srv, 50002, protocol.STATE_HANDSHAKE_SENT)
+	
+	now := time.Now()
+	session.LastSendTime = now.Add(-time.Second)
+	handshaking.LastSendTime = now.Add(-time.Minute)
+	srv.raknet.sendConnectedPing(session, now)
+	srv.raknet.sendConnectedPing(handshaking, now)
+	if len(queuedPacketIDs(session)) != 0 || len(queuedPacketIDs(handshaking)) != 0 {
+		t.Fatal("Expected no ping for a busy or unconnected session")
+	}
+	
+	srv.raknet.sendConnectedPing(session, now.Add(protocol.KEEPALIVE_INTERVAL))
+	if ids := queuedPacketIDs(session); len(ids) != 1 || ids[0] != protocol.ID_CONNECTED_PING {
+		t.Errorf("Expected a keepalive ping once idle, got %v", ids)
+	}
+}
+
+func TestConnectedPingAnsweredWithPong(t *testing.T) {
+	srv := newTestServer()
+	clock := protocol.NewFakeClock(time.UnixMilli(1700000000123))
+	srv.raknet.SetClock(clock)
+	session := addTestSession(srv, 50001, protocol.STATE_IN_GAME)
+	
+	ping := make([]byte, 8)
+	binary.BigEndian.PutUint64(ping, 424242)
+	srv.raknet.handleConnectedPingInternal(session, &protocol.RakNetPacket{PacketID: protocol.ID_CONNECTED_PING, Payload: ping})
+	
+	if len(session.SendQueue) != 1 {
+		t.Fatalf("Expected one pong queued, got %d packets", len(session.SendQueue))
+	}
+	pong := session.SendQueue[0].Payload
+	if len(pong) != 17 || pong[0] != protocol.ID_CONNECTED_PONG {
+		t.Fatalf("Expected a 17-byte ID_CONNECTED_PONG, got % X", pong)
+	}
+	if echoed := binary.BigEndian.Uint64(pong[1:9]); echoed != 424242 {
+		t.Errorf("Expected the client timestamp echoed, got %d", echoed)
+	}
+	if serverTime := binary.BigEndian.Uint64(pong[9:17]); serverTime != 1700000000123 {
+		t.Errorf("Expected server time 1700000000123, got %d", serverTime)
+	}
+}
+
+// addQueryTestPlayers adds two in-game players with scores and pings, and one
+// still joining that queries must not list
+func addQueryTestPlayers(srv *Server) {
+	alice := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	alice.Name = "Alice"
+	alice.Score = 7
+	alice.Session.SetRTT(42 * time.Millisecond)
+	
+	bob := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	bob.Name = "Bob"
+	bob.Score = -3
+	bob.Session.SetRTT(2 * time.Minute) // clamped to 65535
+	
+	joining := addTestPlayer(srv, 2, protocol.STATE_HANDSHAKE_SENT)
+	joining.Name = "Joining"
+}
+
+func TestQueryPlayersStandardLayout(t *testing.T) {
+	srv := newTestServer()
+	addQueryTestPlayers(srv)
+	
+	response := srv.raknet.buildSAMPPlayersResponse(sampQuery('c'))
+	if string(response[0:4]) != "SAMP" || response[10] != 'c' {
+		t.Fatalf("Expected SAMP 'c' header, got %v", response[:11])
+	}
+	
+	if count := binary.LittleEndian.Uint16(response[11:13]); count != 2 {
+		t.Fatalf("Expected 2 players, got %d", count)
+	}
+	
+	expected := []struct {
+		name  string
+		score int32
+	}{
+		{"Alice", 7},
+		{"Bob", -3},
+	}
+	
+	offset := 13
+	for _, want := range expected {
+		nameLen := int(response[offset])
+		name := string(response[offset+1 : offset+1+nameLen])
+		offset += 1 + nameLen
+		score := int32(binary.LittleEndian.Uint32(response[offset:]))
+		offset += 4
+		
+		if name != want.name || score != want.score {
+			t.Errorf("Expected %s score=%d, got %s score=%d", want.name, want.score, name, score)
+		}
+	}
+	
+	if offset != len(response) {
+		t.Errorf("Expected response to end after players, %d trailing bytes", len(response)-offset)
+	}
+}
+
+func TestQueryDetailedIncludesPing(t *testing.T) {
+	srv := newTestServer()
+	addQueryTestPlayers(srv)
+	
+	response := srv.raknet.buildSAMPDetailedResponse(sampQuery('d'))
+	if string(response[0:4]) != "SAMP" || response[10] != 'd' {
+		t.Fatalf("Expected SAMP 'd' header, got %v", response[:11])
+	}
+	
+	if count := binary.LittleEndian.Uint16(response[11:13]); count != 2 {
+		t.Fatalf("Expected 2 players, got %d", count)
+	}
+	
+	expected := []struct {
+		id    byte
+		name  string
+		score int32
+		ping  uint32
+	}{
+		{0, "Alice", 7, 42},
+		{1, "Bob", -3, 65535},
+	}
+	
+	offset := 13
+	for _, want := range expected {
+		id := response[offset]
+		nameLen := int(response[offset+1])
+		name := string(response[offset+2 : offset+2+nameLen])
+		offset += 2 + nameLen
+		score := int32(binary.LittleEndian.Uint32(response[offset:]))
+		ping := binary.LittleEndian.Uint32(response[offset+4:])
+		offset += 8
+		
+		if id != want.id || name != want.name || score != want.score || ping != want.ping {
+			t.Errorf("Expected %d %s score=%d ping=%d, got %d %s score=%d ping=%d",
+				want.id, want.name, want.score, want.ping, id, name, score, ping)
+		}
+	}
+	
+	if offset != len(response) {
+		t.Errorf("Expected response to end after players, %d trailing bytes", len(response)-offset)
+	}
+}
+
+func TestStaleSessionTimesOutWithFakeClock(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	clock := protocol.NewFakeClock(time.Unix(1700000000, 0))
+	srv.raknet.SetClock(clock)
+	
+	addTestSession(srv, 50001, protocol.STATE_CONNECTED)
+	active := addTestSession(srv, 50002, protocol.STATE_CONNECTED)
+	
+	clock.Advance(20 * time.Second)
+	active.UpdateLastReceiveTime()
+	
+	clock.Advance(15 * time.Second)
+	srv.raknet.CleanupStaleSessions()
+	
+	sessions := srv.raknet.GetSessions()
+	if len(sessions) != 1 || sessions[0] != active {
+		t.Fatalf("Expected only the active session to survive, got %d sessions", len(sessions))
+	}
+	
+	clock.Advance(20 * time.Second)
+	srv.raknet.CleanupStaleSessions()
+	if sessions := srv.raknet.GetSessions(); len(sessions) != 0 {
+		t.Errorf("Expected all sessions to time out, got %d", len(sessions))
+	}
+}
+
+func TestHalfOpenHandshakeReapedQuickly(t *testing.T) {
+	srv := newTestServer()
+	clock := protocol.NewFakeClock(time.Unix(1700000000, 0))
+	srv.raknet.SetClock(clock)
+	
+	addTestSession(srv, 50001, protocol.STATE_HANDSHAKE_SENT)
+	inGame := addTestSession(srv, 50002, protocol.STATE_IN_GAME)
+	inGame.ConnectPhase = protocol.PhaseGameEntrySent
+	
+	clock.Advance(srv.HandshakeTimeout + time.Second)
+	srv.raknet.CleanupStaleSessions()
+	
+	sessions := srv.raknet.GetSessions()
+	if len(sessions) != 1 || sessions[0] != inGame {
+		t.Fatalf("Expected only the in-game session to survive, got %d sessions", len(sessions))
+	}
+}
+
+func TestHandshakeTimeoutConfigurable(t *testing.T) {
+	srv := newTestServer()
+	srv.HandshakeTimeout = 2 * time.Second
+	clock := protocol.NewFakeClock(time.Unix(1700000000, 0))
+	srv.raknet.SetClock(clock)
+	
+	addTestSession(srv, 50001, protocol.STATE_HANDSHAKE_SENT)
+	
+	clock.Advance(time.Second)
+	srv.raknet.CleanupStaleSessions()
+	if len(srv.raknet.GetSessions()) != 1 {
+		t.Fatal("Expected the handshake to survive within HandshakeTimeout")
+	}
+	
+	clock.Advance(2 * time.Second)
+	srv.raknet.CleanupStaleSessions()
+	if len(srv.raknet.GetSessions()) != 0 {
+		t.Fatal("Expected the handshake to be reaped after HandshakeTimeout")
+	}
+	
+	if interval := srv.sessionCleanupInterval(); interval != 500*time.Millisecond {
+		t.Errorf("Expected the cleanup to run every quarter of HandshakeTimeout, got %v", interval)
+	}
+	srv.HandshakeTimeout = time.Minute
+	if interval := srv.sessionCleanupInterval(); interval != 5*time.Second {
+		t.Errorf("Expected the cleanup interval to be capped at 5s, got %v", interval)
+	}
+}
+
+func TestConnectionRequestRejectedWhenFull(t *testing.T) {
+	srv := newTestServer()
+	srv.MaxPlayers = 1
+	addTestSession(srv, 50001, protocol.STATE_IN_GAME)
+	pending := addTestSession(srv, 50002, protocol.STATE_CONNECTING)
+	
+	payload := make([]byte, 17)
+	payload[0] = 0x42 // GUID
+	srv.raknet.handleConnectionRequest(pending, &protocol.RakNetPacket{PacketID: protocol.ID_CONNECTION_REQUEST, Payload: payload})
+	
+	if ids := queuedPacketIDs(pending); len(ids) != 1 || ids[0] != protocol.ID_NO_FREE_INCOMING_CONNECTIONS {
+		t.Errorf("Expected ID_NO_FREE_INCOMING_CONNECTIONS, got %02X", ids)
+	}
+	if srv.GetPlayerCount() != 1 {
+		t.Errorf("Expected the rejected client not to take a slot, got %d players", srv.GetPlayerCount())
+	}
+}
+
+func TestValidateSAMPQuery(t *testing.T) {
+	srv := newTestServer()
+	
+	ping := append(sampQuery('p'), 1, 2, 3, 4)
+	otherIP := sampQuery('i')
+	otherIP[4] = 10
+	
+	tests := []struct {
+		name  string
+		data  []byte
+		valid bool
+	}{
+		{"info", sampQuery('i'), true},
+		{"ping", ping, true},
+		{"truncated header", sampQuery('i')[:9], false},
+		{"wrong magic", append([]byte("SAMQ"), sampQuery('i')[4:]...), false},
+		{"unknown opcode", sampQuery('x'), false},
+		{"trailing garbage", append(sampQuery('r'), 0xFF), false},
+		{"truncated ping", ping[:13], false},
+		{"wrong address", otherIP, false},
+	}
+	
+	for _, tt := range tests {
+		err := srv.raknet.validateSAMPQuery(tt.data)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid=%v, got err=%v", tt.name, tt.valid, err)
+		}
+	}
+	
+	// Listening on all interfaces accepts any address
+	srv.Host = "0.0.0.0"
+	if err := srv.raknet.validateSAMPQuery(otherIP); err != nil {
+		t.Errorf("Expected wildcard bind to accept any address, got %v", err)
+	}
+}
+
+func TestMalformedSAMPQueryGetsNoResponse(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open client socket: %v", err)
+	}
+	defer client.Close()
+	clientAddr := client.LocalAddr().(*net.UDPAddr)
+	
+	srv.raknet.HandlePacket(sampQuery('i')[:8], clientAddr)
+	srv.raknet.HandlePacket(append([]byte("SAMP"), 0xFF), clientAddr)
+	
+	buf := make([]byte, 512)
+	client.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if n, _, err := client.ReadFromUDP(buf); err == nil {
+		t.Errorf("Expected no response to malformed queries, got %d bytes", n)
+	}
+	
+	// A well-formed query still gets answered
+	srv.raknet.HandlePacket(sampQuery('i'), clientAddr)
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := client.ReadFromUDP(buf); err != nil {
+		t.Errorf("Expected a response to a valid query, got %v", err)
+	}
+}
+
+func TestClassifyPacket(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		kind packetKind
+	}{
+		{"empty", nil, packetEmpty},
+		{"query", sampQuery('i'), packetQuery},
+		{"magic only", []byte("SAMP"), packetQuery},
+		{"0x53 game packet", []byte{0x53, 0x01, 0x02, 0x03, 0x04}, packetGame},
+		{"short S", []byte("SAM"), packetGame},
+		{"near miss", []byte("SAMQ\x7f\x00\x00\x01"), packetGame},
+		{"data datagram", []byte{protocol.ID_DATA_PACKET, 0x00, 0x00, 0x00}, packetGame},
+	}
+	
+	for _, tt := range tests {
+		if kind := classifyPacket(tt.data); kind != tt.kind {
+			t.Errorf("%s: expected kind %d, got %d", tt.name, tt.kind, kind)
+		}
+	}
+}
+
+func TestConnectionRequestAcceptedReply(t *testing.T) {
+	srv := newTestServer()
+	clock := protocol.NewFakeClock(time.Unix(1700000000, 0))
+	srv.raknet.SetClock(clock)
+	session := addTestSession(srv, 50001, protocol.STATE_CONNECTING)
+	
+	srv.raknet.handleConnectionRequest(session, &protocol.RakNetPacket{
+		PacketID: protocol.ID_CONNECTION_REQUEST,
+		Payload:  connectionRequest(0xABCD, ""),
+	})
+	
+	if len(session.SendQueue) != 1 {
+		t.Fatalf("Expected one reply, got %d", len(session.SendQueue))
+	}
+	bs := protocol.NewBitStream(session.SendQueue[0].Payload)
+	if id, _ := bs.ReadByte(); id != protocol.ID_CONNECTION_REQUEST_ACCEPTED {
+		t.Fatalf("Expected ID_CONNECTION_REQUEST_ACCEPTED, got 0x%02X", id)
+	}
+	
+	clientAddr, err := bs.ReadAddress()
+	if err != nil || clientAddr.String() != session.Addr.String() {
+		t.Errorf("Expected client address %s, got %v (%v)", session.Addr, clientAddr, err)
+	}
+	bs.ReadUint16() // system index
+	for i := 0; i < protocol.MaxInternalIDs; i++ {
+		if _, err := bs.ReadAddress(); err != nil {
+			t.Fatalf("Internal address %d: %v", i, err)
+		}
+	}
+	
+	if requestTime, _ := bs.ReadUint64(); requestTime != 12345 {
+		t.Errorf("Expected echoed client time 12345, got %d", requestTime)
+	}
+	if serverTime, _ := bs.ReadUint64(); serverTime != uint64(clock.Now().UnixMilli()) {
+		t.Errorf("Expected server time %d, got %d", clock.Now().UnixMilli(), serverTime)
+	}
+	if bs.Remaining() != 0 {
+		t.Errorf("Expected reply to end after timestamps, %d trailing bytes", bs.Remaining())
+	}
+}
+
+func TestNewIncomingConnectionFinalizesSession(t *testing.T) {
+	srv := newTestServer()
+	session := addTestSession(srv, 50001, protocol.STATE_CONNECTING)
+	session.Nickname = "Tester"
+	
+	joined := make([]*Player, 0)
+	srv.SetPlayerConnectHandler(func(player *Player) { joined = append(joined, player) })
+	
+	// Server address plus a short list of two client addresses, no timestamps
+	bs := protocol.NewEmptyBitStream()
+	bs.WriteAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 7777})
+	bs.WriteAddress(&net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 50001})
+	bs.WriteAddress(&net.UDPAddr{IP: net.IPv4(10, 0, 0, 5), Port: 50001})
+	packet := &protocol.RakNetPacket{PacketID: protocol.ID_NEW_INCOMING_CONNECTION, Payload: bs.GetData()}
+	
+	srv.raknet.handleInternalPacket(session, packet)
+	srv.raknet.handleInternalPacket(session, packet) // retransmission
+	
+	if session.State != protocol.STATE_CONNECTED {
+		t.Errorf("Expected session to be connected, got state %d", session.State)
+	}
+	if len(session.InternalAddrs) != 2 || session.InternalAddrs[0].String() != "192.168.1.10:50001" {
+		t.Errorf("Unexpected internal addresses: %v", session.InternalAddrs)
+	}
+	if len(joined) != 1 || joined[0].Name != "Tester" {
+		t.Errorf("Expected one player join for Tester, got %d", len(joined))
+	}
+}
+
+func TestNewIncomingConnectionRejectsEmptyPayload(t *testing.T) {
+	srv := newTestServer()
+	session := addTestSession(srv, 50001, protocol.STATE_CONNECTING)
+	
+	srv.raknet.handleInternalPacket(session, &protocol.RakNetPacket{PacketID: protocol.ID_NEW_INCOMING_CONNECTION})
+	
+	if session.State != protocol.STATE_CONNECTING {
+		t.Errorf("Expected a malformed 0x13 to be ignored, got state %d", session.State)
+	}
+}
+
+// openConnectionRequest1 builds an OCR1 padded to probe mtu
+func openConnectionRequest1(mtu int) []byte {
+	data := append([]byte{protocol.ID_OPEN_CONNECTION_REQUEST_1}, protocol.OfflineMessageDataID...)
+	data = append(data, protocol.RAKNET_PROTOCOL_VERSION)
+	return append(data, make([]byte, mtu-28-len(data))...)
+}
+
+func TestRepeatedOpenConnectionRequest1SettlesMTU(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open client socket: %v", err)
+	}
+	defer client.Close()
+	clientAddr := client.LocalAddr().(*net.UDPAddr)
+	
+	// replyMTU sends an OCR1 probe and returns the MTU from OPEN_CONNECTION_REPLY_1
+	replyMTU := func(probe int) uint16 {
+		srv.raknet.HandlePacket(openConnectionRequest1(probe), clientAddr)
+		client.SetReadDeadline(time.Now().Add(2 * time.Second))
+		buf := make([]byte, 2048)
+		n, _, err := client.ReadFromUDP(buf)
+		if err != nil || n < 28 || buf[0] != protocol.ID_OPEN_CONNECTION_REPLY_1 {
+			t.Fatalf("Expected OPEN_CONNECTION_REPLY_1 to the %d-byte probe, got % X (%v)", probe, buf[:n], err)
+		}
+		return binary.BigEndian.Uint16(buf[26:28])
+	}
+	
+	if mtu := replyMTU(1492); mtu != 1492 {
+		t.Errorf("Expected first probe to be answered with 1492, got %d", mtu)
+	}
+	if mtu := replyMTU(1200); mtu != 1200 {
+		t.Errorf("Expected smaller probe to lower the MTU to 1200, got %d", mtu)
+	}
+	if mtu := replyMTU(1400); mtu != 1200 {
+		t.Errorf("Expected a later larger probe to keep MTU 1200, got %d", mtu)
+	}
+	
+	// OCR2 asking for more than was probed settles on the probed MTU
+	ocr2 := protocol.NewEmptyBitStream()
+	ocr2.WriteByte(protocol.ID_OPEN_CONNECTION_REQUEST_2)
+	ocr2.WriteBytes(protocol.OfflineMessageDataID)
+	ocr2.WriteAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 7777})
+	ocr2.WriteUint16(1492)
+	ocr2.WriteUint64(0xABCDEF)
+	srv.raknet.HandlePacket(ocr2.GetData(), clientAddr)
+	
+	srv.raknet.mu.RLock()
+	session, exists := srv.raknet.sessions[clientAddr.String()]
+	_, probePending := srv.raknet.mtuProbes[clientAddr.String()]
+	srv.raknet.mu.RUnlock()
+	if !exists {
+		t.Fatal("Expected OCR2 to create a session")
+	}
+	if session.MTU != 1200 {
+		t.Errorf("Expected session MTU 1200, got %d", session.MTU)
+	}
+	if probePending {
+		t.Errorf("Expected the MTU probe to be consumed by OCR2")
+	}
+}
+
+func TestConfiguredMaxMTUClampsHandshake(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	srv.MaxMTU = 1200
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open client socket: %v", err)
+	}
+	defer client.Close()
+	clientAddr := client.LocalAddr().(*net.UDPAddr)
+	
+	srv.raknet.HandlePacket(openConnectionRequest1(1492), clientAddr)
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 2048)
+	n, _, err := client.ReadFromUDP(buf)
+	if err != nil || n < 28 || buf[0] != protocol.ID_OPEN_CONNECTION_REPLY_1 {
+		t.Fatalf("Expected OPEN_CONNECTION_REPLY_1, got % X (%v)", buf[:n], err)
+	}
+	if mtu := binary.BigEndian.Uint16(buf[26:28]); mtu != 1200 {
+		t.Errorf("Expected reply 1 MTU clamped to 1200, got %d", mtu)
+	}
+	
+	ocr2 := protocol.NewEmptyBitStream()
+	ocr2.WriteByte(protocol.ID_OPEN_CONNECTION_REQUEST_2)
+	ocr2.WriteBytes(protocol.OfflineMessageDataID)
+	ocr2.WriteAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 7777})
+	ocr2.WriteUint16(1492)
+	ocr2.WriteUint64(0xABCDEF)
+	srv.raknet.HandlePacket(ocr2.GetData(), clientAddr)
+	
+	srv.raknet.mu.RLock()
+	session, exists := srv.raknet.sessions[clientAddr.String()]
+	srv.raknet.mu.RUnlock()
+	if !exists {
+		t.Fatal("Expected OCR2 to create a session")
+	}
+	if session.MTU != 1200 {
+		t.Errorf("Expected session MTU clamped to 1200, got %d", session.MTU)
+	}
+	if got, want := protocol.GetSafePayloadSize(session.MTU, true), protocol.GetSafePayloadSize(1200, true); got != want {
+		t.Errorf("Expected safe payload %d for the clamped MTU, got %d", want, got)
+	}
+}
+
+// openConnectionRequest2 builds an OCR2 proposing mtu
+func openConnectionRequest2(mtu uint16) []byte {
+	ocr2 := protocol.NewEmptyBitStream()
+	ocr2.WriteByte(protocol.ID_OPEN_CONNECTION_REQUEST_2)
+	ocr2.WriteBytes(protocol.OfflineMessageDataID)
+	ocr2.WriteAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 7777})
+	ocr2.WriteUint16(mtu)
+	ocr2.WriteUint64(0xABCDEF)
+	return ocr2.GetData()
+}
+
+func TestOversizedMTUClampedTo1492(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open client socket: %v", err)
+	}
+	defer client.Close()
+	clientAddr := client.LocalAddr().(*net.UDPAddr)
+	buf := make([]byte, 2048)
+	
+	srv.raknet.HandlePacket(openConnectionRequest1(1600), clientAddr)
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := client.ReadFromUDP(buf)
+	if err != nil || n < 28 || buf[0] != protocol.ID_OPEN_CONNECTION_REPLY_1 {
+		t.Fatalf("Expected OPEN_CONNECTION_REPLY_1, got % X (%v)", buf[:n], err)
+	}
+	if mtu := binary.BigEndian.Uint16(buf[26:28]); mtu != protocol.MAX_MTU_SIZE {
+		t.Errorf("Expected reply 1 MTU clamped to 1492, got %d", mtu)
+	}
+	
+	srv.raknet.HandlePacket(openConnectionRequest2(9000), clientAddr)
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err = client.ReadFromUDP(buf)
+	if err != nil || n != 35 || buf[0] != protocol.ID_OPEN_CONNECTION_REPLY_2 {
+		t.Fatalf("Expected OPEN_CONNECTION_REPLY_2, got % X (%v)", buf[:n], err)
+	}
+	if mtu := binary.BigEndian.Uint16(buf[32:34]); mtu != protocol.MAX_MTU_SIZE {
+		t.Errorf("Expected reply 2 to echo MTU 1492, got %d", mtu)
+	}
+	
+	srv.raknet.mu.RLock()
+	session, exists := srv.raknet.sessions[clientAddr.String()]
+	srv.raknet.mu.RUnlock()
+	if !exists || session.MTU != protocol.MAX_MTU_SIZE {
+		t.Fatalf("Expected a session with MTU 1492, got %v", session)
+	}
+}
+
+func TestUndersizedMTURaisedToDefault(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50010}
+	
+	srv.raknet.HandlePacket(openConnectionRequest2(400), addr)
+	
+	session, exists := srv.raknet.sessions[addr.String()]
+	if !exists || session.MTU != protocol.DEFAULT_MTU_SIZE {
+		t.Fatalf("Expected a session with MTU %d, got %v", protocol.DEFAULT_MTU_SIZE, session)
+	}
+}
+
+func TestWrongLengthOpenConnectionRequestsIgnored(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50011}
+	
+	// Shorter than a 576-byte probe
+	srv.raknet.HandlePacket(openConnectionRequest1(400), addr)
+	if _, probed := srv.raknet.mtuProbes[addr.String()]; probed {
+		t.Error("Expected a short OCR1 to be ignored")
+	}
+	
+	srv.raknet.HandlePacket(append(openConnectionRequest2(1492), 0x00), addr)
+	srv.raknet.HandlePacket(openConnectionRequest2(1492)[:30], addr)
+	if _, exists := srv.raknet.sessions[addr.String()]; exists {
+		t.Error("Expected OCR2 with the wrong length to be ignored")
+	}
+}
+
+func TestMaxMTUValidation(t *testing.T) {
+	srv := NewServer("127.0.0.1", 0, 10)
+	srv.MaxMTU = protocol.DEFAULT_MTU_SIZE - 1
+	if err := srv.Start(); err == nil {
+		t.Fatal("Expected Start to reject a max MTU below DEFAULT_MTU_SIZE")
+	}
+	
+	srv.MaxMTU = 9000
+	if size := srv.receiveBufferSize(); size < 9000 {
+		t.Errorf("Expected receive buffer to fit a 9000-byte MTU, got %d", size)
+	}
+}
+
+// Queued RELIABLE_ORDERED packets draw from the same per-channel order counter
+// as the handler's direct datagrams; with separate counters the client would
+// hold back whichever path fell behind
+func TestQueuedAndDirectPacketsShareOrderIndex(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	player, client := addClientPlayer(t, srv, 6)
+	session := player.Session
+	
+	orderIndexes := func() []uint32 {
+		t.Helper()
+		buf := make([]byte, protocol.MAX_MTU_SIZE)
+		client.SetReadDeadline(time.Now().Add(time.Second))
+		for {
+			n, _, err := client.ReadFromUDP(buf)
+			if err != nil {
+				t.Fatalf("Expected a data packet, got %v", err)
+			}
+			dp, err := protocol.DecodeDataPacket(buf[:n])
+			if err != nil || buf[0] == protocol.ID_ACK || len(dp.Packets) == 0 {
+				continue
+			}
+			indexes := make([]uint32, len(dp.Packets))
+			for i, packet := range dp.Packets {
+				indexes[i] = packet.OrderIndex
+			}
+			return indexes
+		}
+	}
+	
+	srv.raknet.sendRakNetDatagram(session, []byte{protocol.ID_RPC, 0x01})
+	if got := orderIndexes(); len(got) != 1 || got[0] != 0 {
+		t.Fatalf("Expected the direct datagram at order index 0, got %v", got)
+	}
+	
+	session.AddToQueue(&protocol.EncapsulatedPacket{Reliability: protocol.RELIABLE_ORDERED, Payload: []byte{protocol.ID_RPC, 0x02}})
+	session.AddToQueue(&protocol.EncapsulatedPacket{Reliability: protocol.RELIABLE_ORDERED, OrderChannel: 1, Payload: []byte{protocol.ID_RPC, 0x03}})
+	session.Update(srv.conn)
+	if got := orderIndexes(); len(got) != 2 || got[0] != 1 || got[1] != 0 {
+		t.Fatalf("Expected channel 0 to continue at 1 and channel 1 to start at 0, got %v", got)
+	}
+	
+	srv.raknet.sendRakNetDatagram(session, []byte{protocol.ID_RPC, 0x04})
+	if got := orderIndexes(); len(got) != 1 || got[0] != 2 {
+		t.Errorf("Expected the next direct datagram at order index 2, got %v", got)
+	}
+}
+
+// clientDatagram encodes packets as a client datagram with sequence seq
+func clientDatagram(seq uint32, packets ...*protocol.EncapsulatedPacket) []byte {
+	dp := protocol.NewDataPacket()
+	dp.SequenceNumber = seq
+	dp.Packets = packets
+	return dp.Encode()
+}
+
+func TestEncapsulatedConnectionRequestAnswered(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open client socket: %v", err)
+	}
+	defer client.Close()
+	addr := client.LocalAddr().(*net.UDPAddr)
+	session := addTestSession(srv, addr.Port, protocol.STATE_CONNECTING)
+	
+	srv.raknet.HandlePacket(clientDatagram(0, &protocol.EncapsulatedPacket{
+		Reliability: protocol.RELIABLE_ORDERED,
+		Payload:     append([]byte{protocol.ID_CONNECTION_REQUEST}, connectionRequest(0xABCD, "")...),
+	}), addr)
+	
+	if _, acked := session.ACKQueue[0]; !acked {
+		t.Error("Expected the datagram queued for ACK")
+	}
+	session.Update(srv.conn)
+	payloads := readDataPayloads(t, client)
+	if len(payloads) != 1 || payloads[0][0] != protocol.ID_CONNECTION_REQUEST_ACCEPTED {
+		t.Fatalf("Expected ID_CONNECTION_REQUEST_ACCEPTED on the wire, got %d payloads", len(payloads))
+	}
+}
+
+// splitRequest splits an encapsulated ID_CONNECTION_REQUEST into three
+// RELIABLE_ORDERED fragments of split id, all with order index orderIndex
+func splitRequest(id uint16, orderIndex uint32) []*protocol.EncapsulatedPacket {
+	payload := append([]byte{protocol.ID_CONNECTION_REQUEST}, connectionRequest(0xABCD, "")...)
+	size := (len(payload) + 2) / 3
+	fragments := make([]*protocol.EncapsulatedPacket, 0, 3)
+	for i := 0; i < 3; i++ {
+		chunk := payload[i*size : min((i+1)*size, len(payload))]
+		fragments = append(fragments, &protocol.EncapsulatedPacket{
+			Reliability:  protocol.RELIABLE_ORDERED,
+			MessageIndex: uint32(id)<<4 | uint32(i),
+			OrderIndex:   orderIndex,
+			Split:        true,
+			SplitCount:   3,
+			SplitID:      id,
+			SplitIndex:   uint32(i),
+			Payload:      chunk,
+		})
+	}
+	return fragments
+}
+
+func TestSplitDatagramsReassembledThroughHandlePacket(t *testing.T) {
+	srv := newTestServer()
+	clock := protocol.NewFakeClock(time.Unix(1700000000, 0))
+	srv.raknet.SetClock(clock)
+	session := addTestSession(srv, 50001, protocol.STATE_CONNECTING)
+	addr := session.Addr
+	
+	// Out of order with a gap: nothing is handled and nothing panics
+	fragments := splitRequest(4, 0)
+	srv.raknet.HandlePacket(clientDatagram(0, fragments[2]), addr)
+	srv.raknet.HandlePacket(clientDatagram(1, fragments[0]), addr)
+	if ids := queuedPacketIDs(session); len(ids) != 0 {
+		t.Fatalf("Expected no reply while a fragment is missing, got %02X", ids)
+	}
+	
+	// The gap fills and the reassembled request is answered
+	srv.raknet.HandlePacket(clientDatagram(2, fragments[1]), addr)
+	if ids := queuedPacketIDs(session); len(ids) != 1 || ids[0] != protocol.ID_CONNECTION_REQUEST_ACCEPTED {
+		t.Fatalf("Expected ID_CONNECTION_REQUEST_ACCEPTED once reassembled, got %02X", ids)
+	}
+	
+	// A split whose middle fragment never arrives is discarded after the timeout
+	fragments = splitRequest(5, 1)
+	srv.raknet.HandlePacket(clientDatagram(3, fragments[0]), addr)
+	srv.raknet.HandlePacket(clientDatagram(4, fragments[2]), addr)
+	clock.Advance(protocol.SPLIT_REASSEMBLY_TIMEOUT)
+	srv.raknet.HandlePacket(clientDatagram(5, fragments[1]), addr)
+	if n := len(session.SplitPackets[5]); n != 1 {
+		t.Errorf("Expected the stale fragments discarded, got %d buffered", n)
+	}
+	if ids := queuedPacketIDs(session); len(ids) != 1 {
+		t.Errorf("Expected no reply from a discarded split, got %02X", ids)
+	}
+}
+
+func TestSplitCapsEnforcedThroughHandlePacket(t *testing.T) {
+	srv := newTestServer()
+	session := addTestSession(srv, 50001, protocol.STATE_CONNECTING)
+	addr := session.Addr
+	
+	srv.raknet.HandlePacket(clientDatagram(0, &protocol.EncapsulatedPacket{
+		Reliability: protocol.RELIABLE,
+		Split:       true,
+		SplitCount:  1000,
+		SplitID:     1,
+		Payload:     []byte{0x01},
+	}), addr)
+	if len(session.SplitPackets) != 0 {
+		t.Fatalf("Expected a SplitCount of 1000 rejected before buffering, got %d splits", len(session.SplitPackets))
+	}
+	
+	// Splits that never complete stop being buffered at MAX_SPLIT_BUFFER_BYTES
+	chunk := make([]byte, 1200)
+	for i := uint32(1); i <= uint32(protocol.MAX_SPLIT_BUFFER_BYTES/len(chunk)+10); i++ {
+		srv.raknet.HandlePacket(clientDatagram(i, &protocol.EncapsulatedPacket{
+			Reliability:  protocol.RELIABLE,
+			MessageIndex: i,
+			Split:        true,
+			SplitCount:   2,
+			SplitID:      uint16(i),
+			Payload:      chunk,
+		}), addr)
+	}
+	buffered := 0
+	for _, fragments := range session.SplitPackets {
+		for _, fragment := range fragments {
+			buffered += len(fragment.Payload)
+		}
+	}
+	if buffered > protocol.MAX_SPLIT_BUFFER_BYTES || buffered <= protocol.MAX_SPLIT_BUFFER_BYTES-len(chunk) {
+		t.Errorf("Expected buffering to stop at %d bytes, got %d", protocol.MAX_SPLIT_BUFFER_BYTES, buffered)
+	}
+}
+
+func TestOrderedMessagesReachGameLayerInOrder(t *testing.T) {
+	srv := newTestServer()
+	session := addTestSession(srv, 50001, protocol.STATE_IN_GAME)
+	
+	delivered := make([]byte, 0)
+	srv.raknet.SetPacketHandler(func(session *protocol.Session, packet *protocol.RakNetPacket) {
+		delivered = append(delivered, packet.PacketID)
+	})
+	ordered := func(orderIndex uint32) *protocol.EncapsulatedPacket {
+		return &protocol.EncapsulatedPacket{
+			Reliability:  protocol.RELIABLE_ORDERED,
+			MessageIndex: orderIndex,
+			OrderIndex:   orderIndex,
+			Payload:      []byte{0xC8 + byte(orderIndex)},
+		}
+	}
+	
+	srv.raknet.HandlePacket(clientDatagram(0, ordered(2)), session.Addr)
+	srv.raknet.HandlePacket(clientDatagram(1, ordered(1)), session.Addr)
+	if len(delivered) != 0 {
+		t.Fatalf("Expected messages held while order 0 is missing, got %02X", delivered)
+	}
+	
+	srv.raknet.HandlePacket(clientDatagram(2, ordered(0)), session.Addr)
+	srv.raknet.HandlePacket(clientDatagram(3, ordered(1)), session.Addr) // resent
+	if !bytes.Equal(delivered, []byte{0xC8, 0xC9, 0xCA}) {
+		t.Errorf("Expected C8 C9 CA in order once, got %02X", delivered)
+	}
+}
+
+func TestACKNotHandledAsDatagram(t *testing.T) {
+	srv := newTestServer()
+	session := addTestSession(srv, 50001, protocol.STATE_IN_GAME)
+	
+	ack := protocol.NewACK()
+	ack.Packets = []uint32{0, 1, 2}
+	srv.raknet.HandlePacket(ack.Encode(), session.Addr)
+	
+	if len(session.ACKQueue) != 0 {
+		t.Errorf("Expected an ACK not to be decoded and ACKed as a datagram, got %d queued", len(session.ACKQueue))
+	}
+}
+
+// sentSeqs returns the sequences of the datagrams waiting for an ACK
+func sentSeqs(session *protocol.Session) []uint32 {
+	session.Mu.RLock()
+	defer session.Mu.RUnlock()
+	seqs := make([]uint32, 0, len(session.RecoveryQueue))
+	for seq := range session.RecoveryQueue {
+		seqs = append(seqs, seq)
+	}
+	return seqs
+}
+
+func TestACKThroughHandlePacketAcknowledgesDatagrams(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	player, _ := addClientPlayer(t, srv, 1)
+	session := player.Session
+	
+	acked := false
+	session.AddToQueue(&protocol.EncapsulatedPacket{
+		Reliability: protocol.RELIABLE,
+		Payload:     []byte{protocol.ID_CONNECTED_PING, 0x01},
+		OnAck:       func() { acked = true },
+	})
+	session.Update(srv.conn)
+	session.StorePendingACK(500, []byte{0x84, 0xF4, 0x01, 0x00})
+	
+	ack := protocol.NewACK()
+	ack.Packets = append(sentSeqs(session), 500)
+	srv.raknet.HandlePacket(ack.Encode(), session.Addr)
+	
+	if seqs := sentSeqs(session); len(seqs) != 0 {
+		t.Errorf("Expected the ACKed datagram removed from RecoveryQueue, got %v", seqs)
+	}
+	if !acked {
+		t.Error("Expected the OnAck callback to run")
+	}
+	if _, exists := session.GetPendingACK(500); exists {
+		t.Error("Expected the ACKed handshake datagram forgotten")
+	}
+}
+
+func TestNACKThroughHandlePacketResendsDatagrams(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	player, client := addClientPlayer(t, srv, 1)
+	session := player.Session
+	
+	session.AddToQueue(&protocol.EncapsulatedPacket{
+		Reliability: protocol.RELIABLE,
+		Payload:     []byte{protocol.ID_CONNECTED_PING, 0x01},
+	})
+	session.Update(srv.conn)
+	sent := readDataPayloads(t, client)
+	handshake := []byte{0x84, 0xF4, 0x01, 0x00, 0x00}
+	session.StorePendingACK(500, handshake)
+	
+	nack := protocol.NewNACK()
+	nack.Packets = append(sentSeqs(session), 500)
+	srv.raknet.HandlePacket(nack.Encode(), session.Addr)
+	
+	// The handshake datagram goes out again as it was
+	buf := make([]byte, protocol.MAX_MTU_SIZE)
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := client.ReadFromUDP(buf)
+	if err != nil || !bytes.Equal(buf[:n], handshake) {
+		t.Fatalf("Expected the NACKed handshake datagram resent, got %02X (%v)", buf[:n], err)
+	}
+	
+	// The data datagram goes out again with the next update
+	srv.raknet.Update()
+	if resent := readDataPayloads(t, client); !bytes.Equal(resent[0], sent[0]) {
+		t.Errorf("Expected the NACKed datagram resent, got %02X", resent[0])
+	}
+	
+	// A repeated NACK within one RTO doesn't resend the handshake datagram again
+	srv.raknet.HandlePacket(nack.Encode(), session.Addr)
+	client.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if n, _, err := client.ReadFromUDP(buf); err == nil {
+		t.Errorf("Expected no second resend within one RTO, got %02X", buf[:n])
+	}
+}
+
+func TestUpdateClosesSessionAfterMaxResends(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	clock := protocol.NewFakeClock(time.Now())
+	srv.raknet.clock = clock
+	player, _ := addClientPlayer(t, srv, 1)
+	
+	player.Session.AddToQueue(&protocol.EncapsulatedPacket{
+		Reliability: protocol.RELIABLE,
+		Payload:     []byte{protocol.ID_CONNECTED_PING, 0x01},
+	})
+	for i := 0; i <= protocol.MaxResends+1; i++ {
+		srv.raknet.Update()
+		clock.Advance(protocol.MaxRTO)
+	}
+	
+	if _, exists := srv.GetPlayer(1); exists {
+		t.Error("Expected the player removed once the client stopped ACKing")
+	}
+	if len(srv.raknet.GetSessions()) != 0 {
+		t.Error("Expected the unreachable session closed")
+	}
+}
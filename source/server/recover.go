@@ -0,0 +1,43 @@
+package server
+
+import (
+	"log"
+	"net"
+	"runtime/debug"
+)
+
+// callback runs a gamemode callback or event handler. These are only ever
+// called with no server or session lock held, so a panic in one is logged and
+// the callback skipped, unless PanicThrough is set.
+func (s *Server) callback(name string, fn func()) {
+	defer func() {
+		if s.PanicThrough {
+			return
+		}
+		if r := recover(); r != nil {
+			s.panics.Add(1)
+			log.Printf("💥 Recovered panic in %s callback: %v\n%s", name, r, debug.Stack())
+		}
+	}()
+	fn()
+}
+
+// handleDatagram hands one datagram to the RakNet handler. A panic while
+// handling it is logged and the datagram dropped, unless PanicThrough is set,
+// so one malformed packet can't take down the worker serving its address.
+func (s *Server) handleDatagram(data []byte, addr *net.UDPAddr) {
+	defer func() {
+		if s.PanicThrough {
+			return
+		}
+		if r := recover(); r != nil {
+			var packetID byte
+			if len(data) > 0 {
+				packetID = data[0]
+			}
+			s.panics.Add(1)
+			log.Printf("💥 Recovered panic handling packet 0x%02X from %s: %v\n%s", packetID, addr, r, debug.Stack())
+		}
+	}()
+	s.raknet.HandlePacket(data, addr)
+}
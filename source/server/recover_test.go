@@ -0,0 +1,117 @@
+package server
+
+import (
+	"net"
+	"samp-server-go/source/protocol"
+	"testing"
+	"time"
+)
+
+// panicOnceWriter panics on its first write, then writes through
+type panicOnceWriter struct {
+	panicked bool
+	writes   int
+}
+
+func (w *panicOnceWriter) Write(p []byte) (int, error) {
+	if !w.panicked {
+		w.panicked = true
+		panic("broken audit sink")
+	}
+	w.writes++
+	return len(p), nil
+}
+
+func TestCallbackPanicIsRecovered(t *testing.T) {
+	srv := newTestServer()
+	calls := 0
+	srv.Events.Register(EventPlayerConnect, func(e Event) {
+		calls++
+		if calls == 1 {
+			panic("broken gamemode")
+		}
+	})
+	
+	first := addTestSession(srv, 50001, protocol.STATE_CONNECTED)
+	second := addTestSession(srv, 50002, protocol.STATE_CONNECTED)
+	srv.handlePlayerJoin(first, &protocol.RakNetPacket{PacketID: protocol.ID_PLAYER_JOIN})
+	
+	// No lock may be left held by the panic
+	if !srv.mu.TryLock() {
+		t.Fatal("Expected s.mu to be free after a recovered callback panic")
+	}
+	srv.mu.Unlock()
+	if !first.Mu.TryLock() {
+		t.Fatal("Expected the session lock to be free after a recovered callback panic")
+	}
+	first.Mu.Unlock()
+	
+	srv.handlePlayerJoin(second, &protocol.RakNetPacket{PacketID: protocol.ID_PLAYER_JOIN})
+	if calls != 2 || srv.GetPlayerCount() != 2 {
+		t.Errorf("Expected the server to keep serving joins, got %d calls and %d players", calls, srv.GetPlayerCount())
+	}
+	if panics := srv.Stats().Panics; panics != 1 {
+		t.Errorf("Expected 1 recovered panic, got %d", panics)
+	}
+}
+
+func TestPacketPanicIsRecovered(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	sink := &panicOnceWriter{}
+	srv.AuditLog = NewAuditLog(sink)
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50001}
+	
+	// The first handshake panics in the audit sink; the datagram is dropped
+	srv.handleDatagram([]byte{0x08, 0x01, 0x02, 0x03}, addr)
+	if !srv.raknet.mu.TryLock() {
+		t.Fatal("Expected the handler lock to be free after a recovered packet panic")
+	}
+	srv.raknet.mu.Unlock()
+	
+	// The next packet from the same address is still handled
+	srv.handleDatagram([]byte{0x08, 0x01, 0x02, 0x03}, addr)
+	if _, ok := srv.raknet.sessions[addr.String()]; !ok {
+		t.Error("Expected the next packet to open a session")
+	}
+	if sink.writes != 1 {
+		t.Errorf("Expected the next packet to be audited, got %d writes", sink.writes)
+	}
+	if panics := srv.Stats().Panics; panics != 1 {
+		t.Errorf("Expected 1 recovered panic, got %d", panics)
+	}
+}
+
+func TestTickPanicIsRecovered(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	ticks := 0
+	srv.RegisterTick(func(dt time.Duration) {
+		ticks++
+		if ticks == 1 {
+			panic("broken tick")
+		}
+	})
+	
+	now := time.Now()
+	srv.tick(now)
+	if !srv.mu.TryLock() {
+		t.Fatal("Expected s.mu to be free after a recovered tick panic")
+	}
+	srv.mu.Unlock()
+	srv.tick(now.Add(activeTickInterval))
+	if ticks != 2 {
+		t.Errorf("Expected the update loop to keep ticking after a panic, got %d ticks", ticks)
+	}
+}
+
+func TestPanicThrough(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	srv.PanicThrough = true
+	srv.RegisterTick(func(dt time.Duration) { panic("broken tick") })
+	
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected the panic to propagate with PanicThrough set")
+		}
+	}()
+	srv.tick(time.Now())
+}
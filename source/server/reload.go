@@ -0,0 +1,129 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"samp-server-go/source/protocol"
+)
+
+// Config holds the settings Reload can change while players are connected.
+// Host, Port and MaxPlayers are fixed for the life of the server.
+type Config struct {
+	ServerName string
+	GameMode   string
+	Language   string
+	MapName    string
+	WebURL     string
+	Weather    int
+	WorldTime  int
+	Gravity    float32
+}
+
+// MaxGravity is the largest gravity magnitude Reload accepts
+const MaxGravity = 50.0
+
+// SetFileConfig records cfg as the settings last read from the config file.
+// Reload only applies the fields that differ from it, so changes made at
+// runtime (weather rotation, the time cycle) survive a reload of an untouched file.
+func (s *Server) SetFileConfig(cfg Config) {
+	s.mu.Lock()
+	s.fileConfig = &cfg
+	s.mu.Unlock()
+}
+
+// currentConfig returns the live settings as a Config. Callers hold s.mu.
+func (s *Server) currentConfig() Config {
+	return Config{
+		ServerName: s.ServerName,
+		GameMode:   s.GameMode,
+		Language:   s.Language,
+		MapName:    s.MapName,
+		WebURL:     s.WebURL,
+		Weather:    s.Weather,
+		WorldTime:  s.WorldTime,
+		Gravity:    s.Gravity,
+	}
+}
+
+// configSnapshot is currentConfig for callers not holding s.mu. Reload and the
+// weather and time cycles write these fields while queries and the connect
+// flow read them.
+func (s *Server) configSnapshot() Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.currentConfig()
+}
+
+// Reload applies the fields of cfg that changed since the config file was
+// last read, without dropping sessions. Settings the file did not change keep
+// their runtime values. Changed weather, time, gravity and gamemode text are
+// broadcast to players in game; the query responses pick up the new values on
+// the next request.
+func (s *Server) Reload(cfg Config) error {
+	if cfg.Weather < 0 || cfg.Weather > MaxWeatherID {
+		return fmt.Errorf("invalid weather id %d (must be 0-%d)", cfg.Weather, MaxWeatherID)
+	}
+	if cfg.WorldTime < 0 || cfg.WorldTime > 23 {
+		return fmt.Errorf("invalid world time %d (must be 0-23)", cfg.WorldTime)
+	}
+	if math.IsNaN(float64(cfg.Gravity)) || math.Abs(float64(cfg.Gravity)) > MaxGravity {
+		return fmt.Errorf("invalid gravity %v (must be between -%.0f and %.0f)", cfg.Gravity, MaxGravity, MaxGravity)
+	}
+	
+	s.mu.Lock()
+	prev := s.currentConfig()
+	if s.fileConfig != nil {
+		prev = *s.fileConfig
+	}
+	
+	weatherChanged := cfg.Weather != prev.Weather && cfg.Weather != s.Weather
+	timeChanged := cfg.WorldTime != prev.WorldTime && cfg.WorldTime != s.WorldTime
+	gravityChanged := cfg.Gravity != prev.Gravity && cfg.Gravity != s.Gravity
+	gameModeChanged := cfg.GameMode != prev.GameMode && cfg.GameMode != s.GameMode
+	
+	if cfg.ServerName != prev.ServerName {
+		s.ServerName = cfg.ServerName
+	}
+	if gameModeChanged {
+		s.GameMode = cfg.GameMode
+	}
+	if cfg.Language != prev.Language {
+		s.Language = cfg.Language
+	}
+	if cfg.MapName != prev.MapName {
+		s.MapName = cfg.MapName
+	}
+	if cfg.WebURL != prev.WebURL {
+		s.WebURL = cfg.WebURL
+	}
+	if weatherChanged {
+		s.Weather = cfg.Weather
+	}
+	if timeChanged {
+		s.WorldTime = cfg.WorldTime
+	}
+	if gravityChanged {
+		s.Gravity = cfg.Gravity
+	}
+	s.fileConfig = &cfg
+	s.invalidateQueryCache()
+	weather, worldTime, gameMode := s.Weather, s.WorldTime, s.GameMode
+	s.mu.Unlock()
+	
+	if weatherChanged {
+		s.BroadcastRPC(protocol.BuildSetWeatherRPC(uint8(cfg.Weather)))
+	}
+	if timeChanged {
+		s.BroadcastRPC(protocol.BuildSetWorldTimeRPC(uint8(cfg.WorldTime)))
+	}
+	if gravityChanged {
+		s.BroadcastRPC(protocol.BuildSetGravityRPC(cfg.Gravity))
+	}
+	if gameModeChanged {
+		s.BroadcastRPC(protocol.BuildSetGameModeTextRPC(cfg.GameMode))
+	}
+	
+	log.Printf("🔄 Config reloaded (weather=%d, time=%d:00, gamemode=%s)", weather, worldTime, gameMode)
+	return nil
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"samp-server-go/source/protocol"
+	"sync"
+	"testing"
+)
+
+// sampRules parses an 'r' query response into a map
+func sampRules(t *testing.T, response []byte) map[string]string {
+	t.Helper()
+	
+	rules := make(map[string]string)
+	count := int(response[11]) | int(response[12])<<8
+	offset := 13
+	for i := 0; i < count; i++ {
+		keyLen := int(response[offset])
+		key := string(response[offset+1 : offset+1+keyLen])
+		offset += 1 + keyLen
+		valueLen := int(response[offset])
+		rules[key] = string(response[offset+1 : offset+1+valueLen])
+		offset += 1 + valueLen
+	}
+	return rules
+}
+
+func TestReloadBroadcastsWeatherAndUpdatesQuery(t *testing.T) {
+	srv := newTestServer()
+	session := addTestSession(srv, 50001, protocol.STATE_IN_GAME)
+	
+	cfg := Config{
+		ServerName: srv.ServerName,
+		GameMode:   srv.GameMode,
+		Language:   srv.Language,
+		MapName:    srv.MapName,
+		WebURL:     srv.WebURL,
+		Weather:    3,
+		WorldTime:  srv.WorldTime,
+		Gravity:    srv.Gravity,
+	}
+	if err := srv.Reload(cfg); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	
+	// Only the changed setting is re-sent
+	rpcs := queuedRPCs(session)
+	expected := protocol.BuildSetWeatherRPC(3)
+	if len(rpcs) != 1 || string(rpcs[0]) != string(expected) {
+		t.Errorf("Expected a single SetWeather(3) broadcast, got %02X", rpcs)
+	}
+	
+	rules := sampRules(t, srv.raknet.buildSAMPRulesResponse(sampQuery('r')))
+	if rules["weather"] != "3" {
+		t.Errorf("Expected rules query weather=3, got %q", rules["weather"])
+	}
+	
+	if len(srv.raknet.GetSessions()) != 1 {
+		t.Errorf("Expected sessions to survive a reload")
+	}
+}
+
+func TestReloadRejectsInvalidValues(t *testing.T) {
+	srv := newTestServer()
+	
+	if err := srv.Reload(Config{Weather: 46}); err == nil {
+		t.Error("Expected error for weather 46")
+	}
+	if err := srv.Reload(Config{WorldTime: 24}); err == nil {
+		t.Error("Expected error for world time 24")
+	}
+	if srv.Weather != 10 || srv.GameMode != "Freeroam" {
+		t.Errorf("Expected a rejected reload to leave settings unchanged")
+	}
+}
+
+func TestReloadKeepsRuntimeChanges(t *testing.T) {
+	srv := newTestServer()
+	file := Config{
+		ServerName: srv.ServerName,
+		GameMode:   srv.GameMode,
+		Language:   srv.Language,
+		MapName:    srv.MapName,
+		WebURL:     srv.WebURL,
+		Weather:    srv.Weather,
+		WorldTime:  srv.WorldTime,
+		Gravity:    srv.Gravity,
+	}
+	srv.SetFileConfig(file)
+	
+	// Weather and time move on at runtime
+	srv.Weather = 4
+	srv.WorldTime = 20
+	
+	file.ServerName = "Renamed"
+	if err := srv.Reload(file); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if srv.ServerName != "Renamed" {
+		t.Errorf("Expected the edited server name to apply, got %q", srv.ServerName)
+	}
+	if srv.Weather != 4 || srv.WorldTime != 20 {
+		t.Errorf("Expected runtime weather/time 4/20 to survive, got %d/%d", srv.Weather, srv.WorldTime)
+	}
+	
+	// Editing the file's weather still applies it
+	file.Weather = 7
+	if err := srv.Reload(file); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if srv.Weather != 7 || srv.WorldTime != 20 {
+		t.Errorf("Expected weather 7 and time 20, got %d/%d", srv.Weather, srv.WorldTime)
+	}
+}
+
+func TestReloadRejectsInvalidGravity(t *testing.T) {
+	srv := newTestServer()
+	
+	for _, gravity := range []float32{float32(math.NaN()), 51, -51} {
+		cfg := Config{Weather: srv.Weather, WorldTime: srv.WorldTime, Gravity: gravity}
+		if err := srv.Reload(cfg); err == nil {
+			t.Errorf("Expected error for gravity %v", gravity)
+		}
+	}
+	if srv.Gravity != DefaultGravity {
+		t.Errorf("Expected gravity to stay %v, got %v", DefaultGravity, srv.Gravity)
+	}
+}
+
+func TestReloadWhileQueriedAndConnecting(t *testing.T) {
+	srv := newTestServer()
+	
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			srv.Reload(Config{ServerName: fmt.Sprintf("Server %d", i), MapName: "Map", Weather: i % 20, WorldTime: i % 24, Gravity: 0.008})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			srv.raknet.buildSAMPInfoResponse(sampQuery('i'))
+			srv.raknet.buildSAMPRulesResponse(sampQuery('r'))
+			for _, step := range srv.raknet.connectSteps() {
+				step.build()
+			}
+		}
+	}()
+	wg.Wait()
+	
+	info := srv.raknet.buildSAMPInfoResponse(sampQuery('i'))
+	if hostname := string(info[20 : 20+len("Server 99")]); hostname != "Server 99" {
+		t.Errorf("Expected the info query to report the last reload, got %q", hostname)
+	}
+}
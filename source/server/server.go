@@ -1,17 +1,25 @@
 package server
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"log"
+	"math/rand"
 	"net"
 	"samp-server-go/source/protocol"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
 type Server struct {
 	Host          string
 	Port          int
+	ListenAddrs   []string // "host:port" sockets to bind instead of Host:Port (e.g. IPv4 and IPv6)
 	MaxPlayers    int
 	ServerName    string
 	GameMode      string
@@ -20,14 +28,141 @@ type Server struct {
 	WorldTime     int
 	MapName       string
 	WebURL        string
-	Players       map[int]*Player
+	Password      string // empty = no password
+	SupportedVersions []string // client versions allowed to join (empty = any)
+	MaxMTU        uint16 // handshake MTU ceiling, at least protocol.DEFAULT_MTU_SIZE
+	QueryCacheTTL time.Duration // how long 'i' and 'r' query responses are reused (0 = no cache)
+	QueryRateLimit       int // queries per second from one IP before the rest are dropped (0 = unlimited)
+	QueryBudget          int // queries per second answered from all IPs together (0 = unlimited)
+	MaxLimiterSources    int // IPs the query and connect limiters track; new ones are dropped when full (0 = no cap)
+	MaxQueryResponseSize int // query responses above this many bytes are not sent (0 = no cap)
+	ConnectRateLimit     int           // new connection attempts per ConnectRateWindow from one IP (0 = unlimited)
+	ConnectRateWindow    time.Duration
+	MaxHalfOpenSessions  int // handshakes in flight before new attempts are dropped (0 = unlimited)
+	HandshakeTimeout     time.Duration // half-open handshakes idle this long are dropped
+	DisconnectAckTimeout time.Duration // wait for a kicked client to ACK its disconnection notification (0 = don't wait)
+	PacketWorkers        int // goroutines handling inbound datagrams; each client sticks to one
+	PacketQueueSize      int // datagrams queued per worker before new ones are dropped
+	MOTD          []string // lines sent after a player's first spawn (empty = "Welcome to <ServerName>!")
+	AuditLog      *AuditLog // connection audit trail (nil = disabled)
+	Bans          *BanList  // IPs refused at handshake
+	
+	// Persistence (see Save): bans and player stats are written to these files
+	// every AutosaveInterval and on Stop (empty path = not saved, 0 = only on Stop)
+	BanFile          string
+	StatsFile        string
+	AutosaveInterval time.Duration
+	stats            map[string]PlayerStats // by name: loaded from StatsFile, updated on leave and save
+	
+	Players       map[uint16]*Player
+	
+	// Applied on spawn; world bounds confine players to a rectangle (see SetWorldBounds)
+	Gravity       float32
+	worldBounds   [4]float32 // minX, minY, maxX, maxY
+	fileConfig    *Config    // settings last read from the config file (see SetFileConfig)
+	
+	// Name tags and radar markers, sent in InitGame (see SetNameTags, SetPlayerMarkers)
+	ShowNameTags        bool
+	NameTagDrawDistance float32 // meters
+	NameTagsIgnoreLOS   bool    // draw name tags through walls
+	PlayerMarkers       int     // protocol.PlayerMarkersOff, PlayerMarkersGlobal or PlayerMarkersStreamed
+	
+	// Day/night cycle: advance WorldTime by one hour every TimeCycleInterval.
+	// Guarded by mu.
+	TimeCycleEnabled  bool
+	TimeCycleInterval time.Duration
+	timeCycleLast     time.Time
+	
+	// Weather rotation: cycle through weatherRotation every WeatherInterval
+	WeatherInterval time.Duration
+	WeatherRandom   bool
+	weatherRotation []int
+	weatherIndex    int
+	weatherLast     time.Time
+	rng             *rand.Rand // random weather picks (see SetRand), guarded by mu
+	
+	// Per-player tick: onPlayerUpdate runs for in-game players every PlayerUpdateInterval
+	PlayerUpdateInterval time.Duration
+	onPlayerUpdate       func(*Player)
+	onPlayerConnect      func(*Player)
+	onPlayerDisconnect   func(*Player, DisconnectReason, string)
+	onPlayerWeaponShot   func(*Player, *protocol.BulletSync)
+	playerUpdateLast     time.Time
+	
+	// Regeneration in points per second (0 = disabled), applied on the player tick
+	ArmourRegenRate float32
+	HealthRegenRate float32
+	onPlayerDeath   func(*Player)
+	
+	// Players are marked AFK after AFKTimeout without input (0 = disabled)
+	// and sent AFKMessage if it is set
+	AFKTimeout  time.Duration
+	AFKMessage  string
+	
+	// Gamemode events (see events.go); set by NewServer
+	Events *EventManager
+	
+	// Custom per-tick logic (see RegisterTick)
+	tickHandlers []func(dt time.Duration)
+	lastTick     time.Time
+	
+	// Apply weapon damage server-side for bullet sync hits on players
+	ApplyBulletDamage bool
+	
+	// Let gamemode callback and packet handling panics crash the server
+	// instead of recovering them (for debugging)
+	PanicThrough bool
+	
+	// Damage is ignored for this long after a spawn (0 = disabled)
+	SpawnProtection time.Duration
+	
+	// Player sync is relayed to players within StreamDistance, at most SyncRate
+	// updates per second per observed player (0 = no limit)
+	StreamDistance float32
+	SyncRate       int
+	syncSlots      map[syncKey]*syncSlot
+	syncMu         sync.Mutex
+	vehicleExists  func(vehicleID uint16) bool // see SetVehicleLookup
+	
+	// Objects are streamed to players within their draw distance
+	MaxStreamedObjects int
+	objects            map[uint16]*Object
+	objectGrid         *objectGrid // objects by the cells they can be seen from
+	nextObjectID       uint16
+	
+	// Players and vehicles within StreamDistance are streamed in (see streaming.go)
+	vehiclePositions   func() []VehiclePosition
+	vehicleCreateRPC   func(vehicleID uint16) []byte // see SetVehicleCreator
+	onPlayerStreamIn   func(forPlayer, streamed *Player)
+	onPlayerStreamOut  func(forPlayer, streamed *Player)
+	onVehicleStreamIn  func(forPlayer *Player, vehicleID uint16)
+	onVehicleStreamOut func(forPlayer *Player, vehicleID uint16)
+	
+	// Lifetime counters (see Stats)
+	startedAt     time.Time
+	peakPlayers   int
+	totalJoins    atomic.Uint64
+	datagramsIn   atomic.Uint64
+	workers       *packetWorkers // started by listen
+	panics        atomic.Uint64 // recovered callback panics
+	
 	conn          *net.UDPConn
 	raknet        *RakNetHandler
 	mu            sync.RWMutex
-	running       bool
-	nextPlayerID  int
+	wake          chan struct{} // wakes the update loop early when traffic arrives while idle
+	done          chan struct{} // closed by Stop to end the background loops
+	loops         sync.WaitGroup
+	activeLoops   atomic.Int32  // background loops still running (for tests)
+	stopOnce      sync.Once
+	idle          atomic.Bool   // update loop is running at idleTickInterval
 }
 
+// Update loop rates: full rate with sessions, backed off when nobody is connected
+const (
+	activeTickInterval = 50 * time.Millisecond
+	idleTickInterval   = 1 * time.Second
+)
+
 func NewServer(host string, port int, maxPlayers int) *Server {
 	return &Server{
 		Host:         host,
@@ -40,90 +175,337 @@ func NewServer(host string, port int, maxPlayers int) *Server {
 		WorldTime:    12,
 		MapName:      "San Andreas",
 		WebURL:       "www.sa-mp.com",
-		Players:      make(map[int]*Player),
-		running:      false,
-		nextPlayerID: 0,
+		Gravity:      DefaultGravity,
+		SupportedVersions: append([]string(nil), DefaultSupportedVersions...),
+		MaxMTU:       protocol.MAX_MTU_SIZE,
+		QueryCacheTTL: DefaultQueryCacheTTL,
+		QueryRateLimit: DefaultQueryRateLimit,
+		QueryBudget:    DefaultQueryBudget,
+		MaxLimiterSources: DefaultMaxLimiterSources,
+		ConnectRateLimit:    DefaultConnectRateLimit,
+		ConnectRateWindow:   DefaultConnectRateWindow,
+		MaxHalfOpenSessions: DefaultMaxHalfOpenSessions,
+		HandshakeTimeout:    DefaultHandshakeTimeout,
+		DisconnectAckTimeout: DefaultDisconnectAckTimeout,
+		PacketWorkers:        DefaultPacketWorkers,
+		PacketQueueSize:      DefaultPacketQueueSize,
+		Bans:         NewBanList(),
+		AutosaveInterval: DefaultAutosaveInterval,
+		worldBounds:  [4]float32{-MaxWorldBound, -MaxWorldBound, MaxWorldBound, MaxWorldBound},
+		ShowNameTags:        true,
+		NameTagDrawDistance: DefaultNameTagDrawDistance,
+		PlayerMarkers:       protocol.PlayerMarkersGlobal,
+		Players:      make(map[uint16]*Player),
+		stats:        make(map[string]PlayerStats),
+		Events:       NewEventManager(),
+		TimeCycleInterval: time.Minute,
+		WeatherInterval:   10 * time.Minute,
+		rng:               rand.New(rand.NewSource(time.Now().UnixNano())),
+		PlayerUpdateInterval: 100 * time.Millisecond,
+		MaxStreamedObjects:   DefaultMaxStreamedObjects,
+		StreamDistance:       DefaultStreamDistance,
+		SyncRate:             DefaultSyncRate,
+		syncSlots:            make(map[syncKey]*syncSlot),
+		objects:              make(map[uint16]*Object),
+		objectGrid:           newObjectGrid(),
+		wake:         make(chan struct{}, 1),
+		done:         make(chan struct{}),
+		startedAt:    time.Now(),
 	}
 }
 
 func (s *Server) Start() error {
-	addr := &net.UDPAddr{
-		IP:   net.ParseIP(s.Host),
-		Port: s.Port,
+	if s.MaxMTU < protocol.DEFAULT_MTU_SIZE {
+		return fmt.Errorf("max MTU %d is below the minimum %d", s.MaxMTU, protocol.DEFAULT_MTU_SIZE)
 	}
 	
-	conn, err := net.ListenUDP("udp", addr)
+	if s.BanFile != "" {
+		if err := s.Bans.Load(s.BanFile); err != nil {
+			return err
+		}
+	}
+	if s.StatsFile != "" {
+		if err := s.loadStats(s.StatsFile); err != nil {
+			return err
+		}
+	}
+	
+	conns, err := s.bindSockets()
 	if err != nil {
-		return fmt.Errorf("failed to bind UDP socket: %w", err)
+		return err
 	}
 	
-	s.conn = conn
-	s.raknet = NewRakNetHandler(conn, s)
-	s.running = true
+	s.conn = conns[0]
+	s.mu.Lock()
+	s.startedAt = time.Now()
+	s.mu.Unlock()
+	s.raknet = NewRakNetHandler(s.conn, s)
+	s.raknet.conn = newSocketSet(conns...)
 	
 	// Set packet handler
 	s.raknet.SetPacketHandler(s.handleGamePacket)
 	
-	log.Printf("Server started on %s:%d", s.Host, s.Port)
+	log.Printf("Server started on %s", strings.Join(s.listenAddrs(), ", "))
 	log.Printf("Server Name: %s", s.ServerName)
 	log.Printf("Game Mode: %s", s.GameMode)
 	log.Printf("Max Players: %d", s.MaxPlayers)
 	
 	// Start update ticker
-	go s.updateLoop()
+	s.goLoop(s.updateLoop)
 	
 	// Start session cleanup ticker (every 5 seconds)
-	go s.sessionCleanupLoop()
+	s.goLoop(s.sessionCleanupLoop)
+	
+	// Periodically persist bans and player stats
+	s.goLoop(s.autosaveLoop)
 	
+	s.loops.Add(1)
+	defer s.loops.Done()
 	return s.listen()
 }
 
+// stopping reports whether Stop has been called
+func (s *Server) stopping() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// goLoop runs a background loop that Stop waits for
+func (s *Server) goLoop(loop func()) {
+	s.loops.Add(1)
+	s.activeLoops.Add(1)
+	go func() {
+		defer s.loops.Done()
+		defer s.activeLoops.Add(-1)
+		loop()
+	}()
+}
+
+// udpReader is the part of *net.UDPConn the listen loop reads from
+type udpReader interface {
+	ReadFromUDP(b []byte) (int, *net.UDPAddr, error)
+}
+
+// Pause after a transient read error so a burst of them doesn't spin the loop
+const readErrorBackoff = 10 * time.Millisecond
+
 func (s *Server) listen() error {
-	buffer := make([]byte, 2048)
+	conns := []*net.UDPConn{s.conn}
+	if s.raknet != nil && s.raknet.conn != nil {
+		conns = s.raknet.conn.conns
+	}
+	
+	s.startPacketWorkers()
+	
+	// Extra sockets get their own read loop; the primary one runs here
+	for _, conn := range conns[1:] {
+		conn := conn
+		s.goLoop(func() {
+			log.Printf("Listening for packets on %s...", conn.LocalAddr())
+			if err := s.readLoop(conn); err != nil {
+				log.Printf("❌ Listener on %s stopped: %v", conn.LocalAddr(), err)
+			}
+		})
+	}
 	
-	log.Printf("Listening for packets on %s:%d...", s.Host, s.Port)
+	log.Printf("Listening for packets on %s...", conns[0].LocalAddr())
+	return s.readLoop(conns[0])
+}
+
+// receiveBufferSize fits the largest datagram a client may send at MaxMTU
+func (s *Server) receiveBufferSize() int {
+	const minSize = 2048
+	if int(s.MaxMTU) > minSize {
+		return int(s.MaxMTU)
+	}
+	return minSize
+}
+
+// readBuffer takes a buffer for one datagram from the pool, or allocates one
+// when MaxMTU is beyond the pooled size
+func (s *Server) readBuffer() *[]byte {
+	if size := s.receiveBufferSize(); size > protocol.PooledBufferSize {
+		buf := make([]byte, size)
+		return &buf
+	}
+	return protocol.GetBuffer()
+}
+
+// readLoop hands packets to the RakNet handler until the server stops or the
+// socket fails. Transient errors are retried; anything else ends the loop.
+// Each datagram is read into its own pooled buffer, which the worker hands
+// back once the packet is handled.
+func (s *Server) readLoop(conn udpReader) error {
+	var buf *[]byte
+	defer func() { protocol.PutBuffer(buf) }()
 	
-	for s.running {
-		n, addr, err := s.conn.ReadFromUDP(buffer)
+	for !s.stopping() {
+		if buf == nil {
+			buf = s.readBuffer()
+		}
+		n, addr, err := conn.ReadFromUDP(*buf)
 		if err != nil {
-			if s.running {
-				log.Printf("Error reading UDP packet: %v", err)
+			if s.stopping() && errors.Is(err, net.ErrClosed) {
+				// Stop closed the socket under us
+				return nil
 			}
-			continue
+			if isTransientReadError(err) {
+				log.Printf("⚠️ Transient UDP read error: %v", err)
+				time.Sleep(readErrorBackoff)
+				continue
+			}
+			log.Printf("❌ UDP read failed, stopping listener: %v", err)
+			return fmt.Errorf("failed to read UDP packet: %w", err)
 		}
 		
-		// Make a copy of the data
-		data := make([]byte, n)
-		copy(data, buffer[:n])
+		s.datagramsIn.Add(1)
 		
-		// Log first byte of every packet for debugging
-		if len(data) > 0 && data[0] != 'S' { // Don't log SAMP queries
+		data := (*buf)[:n]
+		
+		// Log first byte of every game packet for debugging (queries are too frequent)
+		if classifyPacket(data) == packetGame {
 			log.Printf("Raw packet: 0x%02X (%d bytes) from %s", data[0], n, addr.String())
 		}
 		
-		go s.raknet.HandlePacket(data, addr)
+		// Remember the socket so replies to addr leave the way it came in
+		if udpConn, ok := conn.(*net.UDPConn); ok {
+			s.raknet.conn.route(addr, udpConn, s.raknet.clock.Now())
+		}
+		
+		s.workers.dispatch(datagram{data: data, addr: addr, buf: buf})
+		buf = nil
+		s.wakeUpdateLoop()
 	}
 	
 	return nil
 }
 
+// isTransientReadError reports whether a read can simply be retried: timeouts
+// and the ICMP-induced refused/reset errors a UDP socket reports after a
+// client goes away.
+func isTransientReadError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+}
+
 func (s *Server) updateLoop() {
-	ticker := time.NewTicker(50 * time.Millisecond)
-	defer ticker.Stop()
+	interval := s.tickInterval()
+	s.idle.Store(interval == idleTickInterval)
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-timer.C:
+		case <-s.wake:
+			// Traffic while idle: tick now instead of waiting out the idle interval
+			if !timer.Stop() {
+				<-timer.C
+			}
+		}
+		
+		s.tick(time.Now())
+		
+		next := s.tickInterval()
+		if next != interval {
+			log.Printf("⏱️  Update loop tick interval: %s", next)
+			interval = next
+			s.idle.Store(interval == idleTickInterval)
+		}
+		timer.Reset(interval)
+	}
+}
+
+// tick runs one update loop iteration
+func (s *Server) tick(now time.Time) {
+	s.raknet.Update()
+	s.updateTimeCycle(now)
+	s.updateWeather(now)
+	s.updatePlayers(now)
+	s.flushSyncRelay(now)
+	s.runTickHandlers(now)
+}
+
+// RegisterTick adds a handler run once per update loop iteration, after session
+// updates, with the time elapsed since the previous tick. Handlers run in
+// registration order.
+func (s *Server) RegisterTick(handler func(dt time.Duration)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tickHandlers = append(s.tickHandlers, handler)
+}
+
+// runTickHandlers calls the registered tick handlers; dt is 0 on the first tick
+func (s *Server) runTickHandlers(now time.Time) {
+	s.mu.Lock()
+	handlers := s.tickHandlers
+	var dt time.Duration
+	if !s.lastTick.IsZero() {
+		dt = now.Sub(s.lastTick)
+	}
+	s.lastTick = now
+	s.mu.Unlock()
 	
-	for s.running {
-		<-ticker.C
-		s.raknet.Update()
+	for _, handler := range handlers {
+		s.callback("tick", func() { handler(dt) })
+	}
+}
+
+// tickInterval returns the update loop rate: full speed while any session exists,
+// slowed down on an idle server
+func (s *Server) tickInterval() time.Duration {
+	if s.raknet == nil || len(s.raknet.GetSessions()) == 0 {
+		return idleTickInterval
 	}
+	return activeTickInterval
+}
+
+// wakeUpdateLoop makes an idle update loop tick immediately
+func (s *Server) wakeUpdateLoop() {
+	if !s.idle.Load() {
+		return
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// sessionCleanupInterval is how often sessionCleanupLoop runs: every 5s, or
+// more often so a half-open handshake outlives HandshakeTimeout by at most a
+// quarter of it
+func (s *Server) sessionCleanupInterval() time.Duration {
+	interval := 5 * time.Second
+	if quarter := s.raknet.handshakeTimeout() / 4; quarter < interval {
+		interval = quarter
+	}
+	return interval
 }
 
 // sessionCleanupLoop - Clean up stale sessions based on REAL timeout
 func (s *Server) sessionCleanupLoop() {
-	ticker := time.NewTicker(5 * time.Second)
+	ticker := time.NewTicker(s.sessionCleanupInterval())
 	defer ticker.Stop()
 	
-	for s.running {
-		<-ticker.C
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+		
+		if len(s.raknet.GetSessions()) == 0 {
+			continue // Nothing to clean up on an idle server
+		}
 		s.raknet.CleanupStaleSessions()
 	}
 }
@@ -132,14 +514,24 @@ func (s *Server) handleGamePacket(session *protocol.Session, packet *protocol.Ra
 	switch packet.PacketID {
 	case 0x25: // ID_AUTH_KEY - SA-MP client authentication
 		s.handleAuthKey(session, packet)
-	case ID_PLAYER_JOIN:
+	case protocol.ID_PLAYER_JOIN:
 		s.handlePlayerJoin(session, packet)
-	case ID_PLAYER_SYNC:
+	case protocol.ID_PLAYER_SYNC:
 		s.handlePlayerSync(session, packet)
-	case ID_VEHICLE_SYNC:
+	case protocol.ID_VEHICLE_SYNC:
 		s.handleVehicleSync(session, packet)
-	case ID_SPAWN_PLAYER:
+	case protocol.ID_AIM_SYNC:
+		s.handleAimSync(session, packet)
+	case protocol.ID_PASSENGER_SYNC:
+		s.handlePassengerSync(session, packet)
+	case protocol.ID_TRAILER_SYNC:
+		s.handleTrailerSync(session, packet)
+	case protocol.ID_SPAWN_PLAYER:
 		s.handleSpawnPlayer(session, packet)
+	case protocol.ID_BULLET_SYNC:
+		s.handleBulletSync(session, packet)
+	case protocol.ID_RPC:
+		s.handleRPC(session, packet)
 	default:
 		log.Printf("Unhandled game packet: 0x%02X from %s", packet.PacketID, session.Addr.String())
 	}
@@ -151,81 +543,579 @@ func (s *Server) handleAuthKey(session *protocol.Session, packet *protocol.RakNe
 	
 	// SA-MP client sends auth key after connection established
 	// Server should acknowledge and allow client to proceed
-	session.State = protocol.STATE_READY
+	session.SetState(protocol.STATE_READY)
+	s.raknet.markMilestone(session, protocol.MilestoneAuth)
 	log.Printf("Client %s authenticated and ready", session.Addr.String())
 }
 
 func (s *Server) handlePlayerJoin(session *protocol.Session, packet *protocol.RakNetPacket) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	if _, joined := s.playerForSession(session); joined {
+		return // Already joined via ID_NEW_INCOMING_CONNECTION
+	}
+	if s.raknet != nil && s.raknet.rejectBannedName(session) {
+		return
+	}
 	
-	if len(s.Players) >= s.MaxPlayers {
+	// MaxPlayers is enforced at handshake; the joining session is already counted here
+	if s.GetPlayerCount() > s.MaxPlayers {
 		log.Printf("Server full, rejecting player from %s", session.Addr.String())
+		s.audit(AuditRejected, session.Addr, -1, session.Nickname, "server full")
 		return
 	}
 	
-	playerID := s.nextPlayerID
-	s.nextPlayerID++
+	player := s.AddPlayer(session)
+	if player == nil {
+		log.Printf("No free player ID, rejecting player from %s", session.Addr.String())
+		s.audit(AuditRejected, session.Addr, -1, session.Nickname, "no free player id")
+		return
+	}
+	s.audit(AuditAccepted, session.Addr, int(player.ID), player.Name, "")
+	log.Printf("Player %d joined from %s", player.ID, session.Addr.String())
+	s.announcePlayerJoin(player)
+	
+	if s.onPlayerConnect != nil {
+		s.callback("player connect", func() { s.onPlayerConnect(player) })
+	}
+	s.mu.RLock()
+	connect := ConnectData{Name: player.Name, Addr: session.Addr.String(), Score: player.Score}
+	s.mu.RUnlock()
+	s.trigger(EventPlayerConnect, player.ID, connect)
+}
+
+// SetPlayerConnectHandler sets the callback run when a player joins
+func (s *Server) SetPlayerConnectHandler(handler func(*Player)) {
+	s.onPlayerConnect = handler
+}
+
+// AddPlayer registers a connected player for a session under the lowest free
+// player ID. It returns nil when all MaxPlayerID+1 IDs are taken.
+func (s *Server) AddPlayer(session *protocol.Session) *Player {
+	s.mu.Lock()
+	playerID, ok := s.allocatePlayerID()
+	if !ok {
+		s.mu.Unlock()
+		return nil
+	}
 	
 	player := NewPlayer(playerID, session.Addr)
 	player.Connected = true
+	player.Name = session.Nickname
+	player.Session = session
+	player.Color = defaultPlayerColor(playerID)
+	s.restoreStats(player)
 	s.Players[playerID] = player
+	s.invalidateQueryCache()
+	if len(s.Players) > s.peakPlayers {
+		s.peakPlayers = len(s.Players)
+	}
+	s.mu.Unlock()
+	s.totalJoins.Add(1)
+	
+	session.Mu.Lock()
+	session.PlayerID = playerID
+	session.Mu.Unlock()
+	return player
+}
+
+// allocatePlayerID returns the lowest player ID not in use, like SA-MP does.
+// The caller holds s.mu, so allocation and registration happen atomically.
+func (s *Server) allocatePlayerID() (uint16, bool) {
+	for id := uint16(0); id <= MaxPlayerID; id++ {
+		if _, taken := s.Players[id]; !taken {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// RemovePlayer forgets a player. It returns false if the ID is unknown.
+func (s *Server) RemovePlayer(playerID uint16) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	player, exists := s.Players[playerID]
+	if !exists {
+		return false
+	}
+	delete(s.Players, playerID)
+	s.forgetSyncSlots(playerID)
+	s.rememberStats(player, time.Now())
+	
+	// Forget what was streamed either way, so a player who later reuses the
+	// ID is streamed in afresh
+	player.StreamedPlayers = make(map[uint16]bool)
+	player.StreamedVehicles = make(map[uint16]bool)
+	for _, other := range s.Players {
+		delete(other.StreamedPlayers, playerID)
+	}
+	s.invalidateQueryCache()
+	return true
+}
+
+// GetPlayer returns a player by ID
+func (s *Server) GetPlayer(playerID uint16) (*Player, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	player, exists := s.Players[playerID]
+	return player, exists
+}
+
+// ForEachPlayer calls fn for every player in ID order. fn runs without s.mu
+// held, so it may call back into the server.
+func (s *Server) ForEachPlayer(fn func(*Player)) {
+	s.mu.RLock()
+	players := make([]*Player, 0, len(s.Players))
+	for _, player := range s.Players {
+		players = append(players, player)
+	}
+	s.mu.RUnlock()
+	
+	sort.Slice(players, func(i, j int) bool { return players[i].ID < players[j].ID })
+	for _, player := range players {
+		fn(player)
+	}
+}
+
+// SetPlayerUpdateHandler sets the callback run for every in-game player on each player tick
+func (s *Server) SetPlayerUpdateHandler(handler func(*Player)) {
+	s.onPlayerUpdate = handler
+}
+
+// SetPlayerDeathHandler sets the callback run when a player's health drops to zero
+func (s *Server) SetPlayerDeathHandler(handler func(*Player)) {
+	s.onPlayerDeath = handler
+}
+
+// updatePlayers runs the player tick at most once per PlayerUpdateInterval:
+// regeneration, death detection and the player update callback.
+// Players whose session is still handshaking are skipped.
+func (s *Server) updatePlayers(now time.Time) {
+	if now.Sub(s.playerUpdateLast) < s.PlayerUpdateInterval {
+		return
+	}
+	
+	var elapsed float32
+	if !s.playerUpdateLast.IsZero() {
+		elapsed = float32(now.Sub(s.playerUpdateLast).Seconds())
+	}
+	s.playerUpdateLast = now
+	
+	s.mu.Lock()
+	alive := make([]*Player, 0, len(s.Players))
+	dead := make([]*Player, 0)
+	for _, player := range s.Players {
+		if !player.Connected || !player.IsInGame() {
+			continue
+		}
+		if !player.IsAlive() {
+			dead = append(dead, player)
+			continue
+		}
+		s.regeneratePlayer(player, elapsed)
+		alive = append(alive, player)
+	}
+	s.mu.Unlock()
 	
-	log.Printf("Player %d joined from %s", playerID, session.Addr.String())
+	// Run callbacks without holding the lock so they can call back into the server
+	for _, player := range dead {
+		s.handlePlayerDeath(player)
+	}
+	s.updateAFK(now)
+	for _, player := range alive {
+		s.streamObjects(player)
+		s.streamEntities(player)
+	}
+	for _, player := range alive {
+		if s.onPlayerUpdate != nil {
+			s.callback("player update", func() { s.onPlayerUpdate(player) })
+		}
+		s.trigger(EventPlayerUpdate, player.ID, nil)
+	}
+}
+
+// regeneratePlayer applies armour/health regen for elapsed seconds and syncs it to the client
+func (s *Server) regeneratePlayer(player *Player, elapsed float32) {
+	if elapsed <= 0 {
+		return
+	}
+	
+	armourChanged, healthChanged := player.Regenerate(s.ArmourRegenRate*elapsed, s.HealthRegenRate*elapsed)
+	if armourChanged {
+		s.sendRPC(player.Session, protocol.BuildSetPlayerArmourRPC(player.Armour))
+	}
+	if healthChanged {
+		s.sendRPC(player.Session, protocol.BuildSetPlayerHealthRPC(player.Health))
+	}
+}
+
+// handlePlayerDeath fires the death callback, resets the player and respawns them
+func (s *Server) handlePlayerDeath(player *Player) {
+	log.Printf("💀 Player %d died", player.ID)
+	
+	if s.onPlayerDeath != nil {
+		s.callback("player death", func() { s.onPlayerDeath(player) })
+	}
+	s.trigger(EventPlayerDeath, player.ID, nil)
+	
+	s.mu.Lock()
+	player.Health = 100.0
+	player.Armour = 0.0
+	s.mu.Unlock()
+	
+	s.sendRPC(player.Session, protocol.BuildSpawnPlayerRPC())
+	s.reapplyFreeze(player)
+	s.startSpawnProtection(player, time.Now())
+}
+
+// startSpawnProtection makes a freshly spawned player ignore damage for SpawnProtection
+func (s *Server) startSpawnProtection(player *Player, now time.Time) {
+	if s.SpawnProtection <= 0 {
+		return
+	}
+	
+	s.mu.Lock()
+	player.SpawnProtectedUntil = now.Add(s.SpawnProtection)
+	player.spawnOriginSet = false
+	s.mu.Unlock()
+}
+
+// DamagePlayer applies damage to a player, honouring spawn protection.
+// It returns false if the damage was ignored.
+func (s *Server) DamagePlayer(playerID uint16, amount float32) bool {
+	player, exists := s.GetPlayer(playerID)
+	if !exists {
+		return false
+	}
+	
+	return s.damagePlayer(player, amount, time.Now())
+}
+
+func (s *Server) damagePlayer(player *Player, amount float32, now time.Time) bool {
+	s.mu.Lock()
+	if player.IsSpawnProtected(now) {
+		health := player.Health
+		armour := player.Armour
+		s.mu.Unlock()
+		
+		// The client already applied the hit locally, so restore its values
+		s.sendRPC(player.Session, protocol.BuildSetPlayerHealthRPC(health))
+		s.sendRPC(player.Session, protocol.BuildSetPlayerArmourRPC(armour))
+		return false
+	}
 	
-	// Send welcome message
-	s.sendServerMessage(session, fmt.Sprintf("Welcome to %s!", s.ServerName))
+	player.TakeDamage(amount)
+	s.mu.Unlock()
+	return true
 }
 
-func (s *Server) handlePlayerSync(session *protocol.Session, packet *protocol.RakNetPacket) {
-	// Handle player position sync
-	// This would parse position data and update player state
+// PlayerPosition returns a player's last synced position and facing angle
+func (s *Server) PlayerPosition(playerID uint16) (x, y, z, angle float32, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	player, exists := s.Players[playerID]
+	if !exists {
+		return 0, 0, 0, 0, fmt.Errorf("player %d not found", playerID)
+	}
+	x, y, z = player.GetPosition()
+	return x, y, z, player.Angle, nil
+}
+
+// TeleportPlayer moves a player and faces them along angle. Velocity is zeroed
+// and the camera put back behind the player so they don't carry momentum or a
+// stale camera over to the new position.
+func (s *Server) TeleportPlayer(playerID uint16, x, y, z, angle float32) error {
+	player, exists := s.GetPlayer(playerID)
+	if !exists {
+		return fmt.Errorf("player %d not found", playerID)
+	}
+	if !player.IsInGame() {
+		return fmt.Errorf("player %d is not in game", playerID)
+	}
+	
+	s.mu.Lock()
+	player.SetPosition(x, y, z)
+	player.Angle = angle
+	s.mu.Unlock()
+	
+	s.sendRPC(player.Session, protocol.BuildSetPlayerPosRPC(x, y, z))
+	s.sendRPC(player.Session, protocol.BuildSetPlayerFacingAngleRPC(angle))
+	s.sendRPC(player.Session, protocol.BuildSetPlayerVelocityRPC(0, 0, 0))
+	s.sendRPC(player.Session, protocol.BuildSetCameraBehindPlayerRPC())
+	return nil
+}
+
+// SetPlayerName renames a player and broadcasts the change.
+// It returns NameChangeSuccess, NameChangeTaken or NameChangeInvalid.
+func (s *Server) SetPlayerName(playerID uint16, name string) int {
+	if !IsValidPlayerName(name) {
+		return NameChangeInvalid
+	}
+	
+	s.mu.Lock()
+	player, exists := s.Players[playerID]
+	if !exists {
+		s.mu.Unlock()
+		return NameChangeInvalid
+	}
+	
+	// Names are case-insensitive, but a player may change the case of their own name
+	for id, other := range s.Players {
+		if strings.EqualFold(other.Name, name) && (id != playerID || other.Name == name) {
+			s.mu.Unlock()
+			return NameChangeTaken
+		}
+	}
+	
+	oldName := player.Name
+	player.Name = name
+	s.mu.Unlock()
+	
+	log.Printf("✏️  Player %d renamed: %s -> %s", playerID, oldName, name)
+	s.BroadcastRPC(protocol.BuildSetPlayerNameRPC(playerID, name))
+	
+	return NameChangeSuccess
+}
+
+// SetPlayerScore sets the score shown on the scoreboard and saved with the
+// player's stats
+func (s *Server) SetPlayerScore(playerID uint16, score int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	player, exists := s.Players[playerID]
+	if !exists {
+		return fmt.Errorf("player %d not found", playerID)
+	}
+	player.Score = score
+	s.invalidateQueryCache()
+	return nil
+}
+
+// playerForSession returns the player bound to a session, if any
+func (s *Server) playerForSession(session *protocol.Session) (*Player, bool) {
+	session.Mu.RLock()
+	playerID := session.PlayerID
+	session.Mu.RUnlock()
+	
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	player, exists := s.Players[playerID]
+	if !exists || player.Session != session {
+		return nil, false
+	}
+	return player, true
 }
 
 func (s *Server) handleVehicleSync(session *protocol.Session, packet *protocol.RakNetPacket) {
-	// Handle vehicle sync
+	// Driver sync starts with the vehicle ID; remember it so trailer sync
+	// can be tied to the towing vehicle
+	player, ok := s.playerForSession(session)
+	if !ok || len(packet.Payload) < 2 {
+		return
+	}
+	
+	s.setPlayerVehicle(player, binary.LittleEndian.Uint16(packet.Payload[0:2]), 0)
 }
 
 func (s *Server) handleSpawnPlayer(session *protocol.Session, packet *protocol.RakNetPacket) {
 	// Handle player spawn
 	log.Printf("Player spawned from %s", session.Addr.String())
+	
+	if player, ok := s.playerForSession(session); ok {
+		s.startSpawnProtection(player, time.Now())
+		s.sendMOTD(player)
+		s.trigger(EventPlayerSpawn, player.ID, nil)
+	}
 }
 
+func (s *Server) handleBulletSync(session *protocol.Session, packet *protocol.RakNetPacket) {
+	player, ok := s.playerForSession(session)
+	if !ok {
+		return
+	}
+	
+	// Firing a weapon gives up spawn protection
+	s.mu.Lock()
+	player.ClearSpawnProtection()
+	s.mu.Unlock()
+	
+	shot, err := protocol.DecodeBulletSync(packet.Payload)
+	if err != nil {
+		log.Printf("⚠️ Dropped bullet sync from player %d: %v", player.ID, err)
+		return
+	}
+	
+	if s.onPlayerWeaponShot != nil {
+		s.callback("weapon shot", func() { s.onPlayerWeaponShot(player, shot) })
+	}
+	
+	if s.ApplyBulletDamage && shot.HitType == protocol.BulletHitPlayer && shot.HitID != player.ID {
+		if target, exists := s.GetPlayer(shot.HitID); exists && target.IsInGame() {
+			if damage, known := weaponDamage[int(shot.WeaponID)]; known {
+				s.damagePlayer(target, damage, time.Now())
+			}
+		}
+	}
+}
+
+// SetPlayerWeaponShotHandler sets the callback run for every decoded bullet sync
+func (s *Server) SetPlayerWeaponShotHandler(handler func(*Player, *protocol.BulletSync)) {
+	s.onPlayerWeaponShot = handler
+}
+
+// weaponDamage is the health a single hit takes for bullet weapons (SA-MP defaults)
+var weaponDamage = map[int]float32{
+	22: 8.25,  // 9mm
+	23: 13.2,  // silenced 9mm
+	24: 46.2,  // Desert Eagle
+	25: 49.5,  // shotgun (all pellets)
+	26: 49.5,  // sawnoff shotgun
+	27: 39.6,  // combat shotgun
+	28: 6.6,   // Uzi
+	29: 8.25,  // MP5
+	30: 9.9,   // AK-47
+	31: 9.9,   // M4
+	32: 6.6,   // Tec-9
+	33: 24.75, // country rifle
+	34: 41.25, // sniper rifle
+	38: 46.2,  // minigun
+}
+
+// Color of server messages
+const ServerMessageColor = protocol.ColorWhite
+
+// sendServerMessage shows a chat line to one player
 func (s *Server) sendServerMessage(session *protocol.Session, message string) {
-	response := protocol.NewEmptyBitStream()
-	response.WriteByte(ID_SERVER_MESSAGE)
-	response.WriteString(message)
+	s.sendRPC(session, protocol.BuildSendClientMessageRPC(ServerMessageColor, message))
+}
+
+// SendClientMessage shows a chat line to a player with the given reliability.
+// Use protocol.RELIABLE_ORDERED unless losing the line is acceptable.
+func (s *Server) SendClientMessage(playerID uint16, color uint32, message string, reliability byte) error {
+	return s.SendRPCToPlayer(playerID, protocol.BuildSendClientMessageRPC(color, message), reliability)
+}
+
+// sendRPC queues an RPC payload to a single session
+func (s *Server) sendRPC(session *protocol.Session, rpcPayload []byte) {
+	s.queueRPC(session, rpcPayload, protocol.RELIABLE_ORDERED)
+}
+
+func (s *Server) queueRPC(session *protocol.Session, rpcPayload []byte, reliability byte) {
+	if s.raknet == nil || session == nil {
+		return
+	}
 	
+	data := protocol.EncodeRPCPacket(rpcPayload)
 	packet := &protocol.RakNetPacket{
-		PacketID: ID_SERVER_MESSAGE,
-		Payload:  response.GetData()[1:],
+		PacketID: data[0],
+		Payload:  data[1:],
 	}
 	
-	s.raknet.SendPacket(session, packet, protocol.RELIABLE_ORDERED)
+	s.raknet.SendPacket(session, packet, reliability)
 }
 
-func (s *Server) GetPlayerCount() int {
+// SendRPCToPlayer sends an RPC built with one of the protocol.Build*RPC helpers to a player
+func (s *Server) SendRPCToPlayer(playerID uint16, rpcPayload []byte, reliability byte) error {
+	if len(rpcPayload) == 0 {
+		return fmt.Errorf("empty RPC payload")
+	}
+	
+	player, exists := s.GetPlayer(playerID)
+	if !exists {
+		return fmt.Errorf("player %d not found", playerID)
+	}
+	if !player.IsInGame() {
+		return fmt.Errorf("player %d is not in game", playerID)
+	}
+	
+	s.queueRPC(player.Session, rpcPayload, reliability)
+	return nil
+}
+
+// SendRPCToAll sends an RPC to every session that has entered the game
+func (s *Server) SendRPCToAll(rpcPayload []byte, reliability byte) error {
+	if len(rpcPayload) == 0 {
+		return fmt.Errorf("empty RPC payload")
+	}
+	if s.raknet == nil {
+		return fmt.Errorf("server not started")
+	}
+	
+	for _, session := range s.raknet.GetSessions() {
+		if session.CanStream() {
+			s.queueRPC(session, rpcPayload, reliability)
+		}
+	}
+	return nil
+}
+
+// inGamePlayers returns connected, spawned players ordered by ID
+func (s *Server) inGamePlayers() []*Player {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return len(s.Players)
+	
+	players := make([]*Player, 0, len(s.Players))
+	for _, player := range s.Players {
+		if player.Connected && player.IsInGame() {
+			players = append(players, player)
+		}
+	}
+	sort.Slice(players, func(i, j int) bool { return players[i].ID < players[j].ID })
+	return players
 }
 
+// GetPlayerCount returns the number of connected clients, including those still joining
+// the game (half-open handshakes are not counted).
+// It is the single source of truth for the query response and the MaxPlayers check.
+func (s *Server) GetPlayerCount() int {
+	if s.raknet == nil {
+		return 0
+	}
+	return s.raknet.ActiveSessionCount()
+}
+
+// IsFull reports whether accepting another connection would exceed MaxPlayers
+func (s *Server) IsFull() bool {
+	return s.GetPlayerCount() >= s.MaxPlayers
+}
+
+// BroadcastMessage shows a chat line to every in-game player
 func (s *Server) BroadcastMessage(message string) {
-	sessions := s.raknet.GetSessions()
-	for _, session := range sessions {
-		if session.State == protocol.STATE_CONNECTED {
-			s.sendServerMessage(session, message)
+	s.ForEachPlayer(func(player *Player) {
+		if player.Connected && player.IsInGame() {
+			s.sendServerMessage(player.Session, message)
 		}
-	}
+	})
 }
 
+// Stop shuts the server down and returns once the listen loop, the background
+// loops and any in-flight packet handlers have exited. Connected clients are
+// sent a disconnection notification first. It is safe to call twice.
 func (s *Server) Stop() {
-	log.Println("Stopping server...")
-	s.running = false
-	
-	if s.conn != nil {
-		s.conn.Close()
-	}
-	
-	log.Println("Server stopped")
+	s.stopOnce.Do(func() {
+		log.Println("Stopping server...")
+		
+		// Save while players are still connected so their stats are kept,
+		// then tell clients we're going while the sockets can still carry ACKs
+		if err := s.Save(); err != nil {
+			log.Printf("❌ Failed to save state on shutdown: %v", err)
+		}
+		if s.raknet != nil && s.conn != nil {
+			s.raknet.DisconnectAll(DisconnectShutdown, "server shutting down")
+		}
+		
+		close(s.done)
+		
+		if s.conn != nil {
+			s.conn.Close()
+		}
+		if s.raknet != nil {
+			s.raknet.conn.Close() // any extra listen sockets
+		}
+		
+		s.loops.Wait()
+		log.Println("Server stopped")
+	})
 }
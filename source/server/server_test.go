@@ -0,0 +1,716 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"samp-server-go/source/protocol"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// newTestServer creates a server with a RakNet handler but no socket
+func newTestServer() *Server {
+	srv := NewServer("127.0.0.1", 7777, 10)
+	srv.raknet = NewRakNetHandler(nil, srv)
+	return srv
+}
+
+// addTestSession registers a session for 127.0.0.1:port in the given state
+func addTestSession(srv *Server, port int, state int) *protocol.Session {
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+	session := srv.raknet.newSession(addr, 576)
+	session.State = state
+	srv.raknet.sessions[addr.String()] = session
+	return session
+}
+
+// queuedRPCs returns the RPC payloads (without the 0x7C wrapper) queued on a session
+func queuedRPCs(session *protocol.Session) [][]byte {
+	session.Mu.RLock()
+	defer session.Mu.RUnlock()
+	
+	rpcs := make([][]byte, 0)
+	for _, encap := range session.SendQueue {
+		if len(encap.Payload) > 1 && encap.Payload[0] == protocol.ID_RPC {
+			rpcs = append(rpcs, encap.Payload[1:])
+		}
+	}
+	return rpcs
+}
+
+// addTestPlayer registers a connected player bound to a new session in the given state
+func addTestPlayer(srv *Server, id uint16, state int) *Player {
+	session := addTestSession(srv, 50000+int(id), state)
+	player := NewPlayer(id, session.Addr)
+	player.Connected = true
+	player.Session = session
+	session.PlayerID = id
+	srv.Players[id] = player
+	return player
+}
+
+func TestPlayerUpdateSkipsHandshakingPlayers(t *testing.T) {
+	srv := newTestServer()
+	addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	addTestPlayer(srv, 1, protocol.STATE_HANDSHAKE_SENT)
+	addTestPlayer(srv, 2, protocol.STATE_IN_GAME)
+	
+	calls := make(map[uint16]int)
+	srv.SetPlayerUpdateHandler(func(player *Player) {
+		calls[player.ID]++
+	})
+	
+	srv.updatePlayers(time.Unix(1000, 0))
+	
+	if calls[0] != 1 || calls[2] != 1 {
+		t.Errorf("Expected one update for in-game players, got %v", calls)
+	}
+	if calls[1] != 0 {
+		t.Errorf("Expected no update for handshaking player, got %d", calls[1])
+	}
+}
+
+func TestPlayerUpdateIsThrottled(t *testing.T) {
+	srv := newTestServer()
+	srv.PlayerUpdateInterval = 100 * time.Millisecond
+	addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	
+	calls := 0
+	srv.SetPlayerUpdateHandler(func(player *Player) {
+		calls++
+	})
+	
+	start := time.Unix(1000, 0)
+	srv.updatePlayers(start)
+	srv.updatePlayers(start.Add(50 * time.Millisecond))
+	srv.updatePlayers(start.Add(100 * time.Millisecond))
+	
+	if calls != 2 {
+		t.Errorf("Expected 2 updates, got %d", calls)
+	}
+}
+
+func TestRegenArmourThenHealth(t *testing.T) {
+	srv := newTestServer()
+	srv.PlayerUpdateInterval = time.Second
+	srv.ArmourRegenRate = 10
+	srv.HealthRegenRate = 5
+	player := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	player.Health = 50
+	player.Armour = 85
+	
+	start := time.Unix(1000, 0)
+	srv.updatePlayers(start)
+	srv.updatePlayers(start.Add(1 * time.Second))
+	if player.Armour != 95 || player.Health != 50 {
+		t.Errorf("Expected armour 95 / health 50, got %.1f / %.1f", player.Armour, player.Health)
+	}
+	
+	srv.updatePlayers(start.Add(2 * time.Second))
+	if player.Armour != 100 {
+		t.Errorf("Expected armour capped at 100, got %.1f", player.Armour)
+	}
+	
+	srv.updatePlayers(start.Add(3 * time.Second))
+	srv.updatePlayers(start.Add(4 * time.Second))
+	if player.Health != 60 {
+		t.Errorf("Expected health 60, got %.1f", player.Health)
+	}
+	
+	rpcs := queuedRPCs(player.Session)
+	if len(rpcs) != 4 {
+		t.Fatalf("Expected 4 regen RPCs, got %d", len(rpcs))
+	}
+	if rpcs[3][0] != protocol.RPC_SetPlayerHealth {
+		t.Errorf("Expected SetPlayerHealth RPC, got 0x%02X", rpcs[3][0])
+	}
+}
+
+func TestRegenDisabledByDefault(t *testing.T) {
+	srv := newTestServer()
+	player := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	player.Health = 50
+	
+	start := time.Unix(1000, 0)
+	srv.updatePlayers(start)
+	srv.updatePlayers(start.Add(10 * time.Second))
+	
+	if player.Health != 50 {
+		t.Errorf("Expected health to stay 50, got %.1f", player.Health)
+	}
+}
+
+func TestZeroHealthTriggersDeath(t *testing.T) {
+	srv := newTestServer()
+	player := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	player.Health = 0
+	player.Armour = 20
+	
+	deaths := 0
+	srv.SetPlayerDeathHandler(func(p *Player) {
+		deaths++
+	})
+	
+	start := time.Unix(1000, 0)
+	srv.updatePlayers(start)
+	srv.updatePlayers(start.Add(time.Second))
+	
+	if deaths != 1 {
+		t.Errorf("Expected 1 death, got %d", deaths)
+	}
+	if player.Health != 100 || player.Armour != 0 {
+		t.Errorf("Expected state reset after death, got health %.1f armour %.1f", player.Health, player.Armour)
+	}
+	
+	rpcs := queuedRPCs(player.Session)
+	if len(rpcs) != 1 || rpcs[0][0] != protocol.RPC_SpawnPlayer {
+		t.Errorf("Expected a SpawnPlayer RPC after death, got %v", rpcs)
+	}
+}
+
+func TestSpawnProtectionNegatesDamage(t *testing.T) {
+	srv := newTestServer()
+	srv.SpawnProtection = 3 * time.Second
+	player := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	
+	spawned := time.Unix(1000, 0)
+	srv.startSpawnProtection(player, spawned)
+	
+	if srv.damagePlayer(player, 40, spawned.Add(time.Second)) {
+		t.Error("Expected damage to be ignored during spawn protection")
+	}
+	if player.Health != 100 {
+		t.Errorf("Expected health 100 during protection, got %.1f", player.Health)
+	}
+	if len(queuedRPCs(player.Session)) != 2 {
+		t.Errorf("Expected health and armour to be restored on the client")
+	}
+	
+	if !srv.damagePlayer(player, 40, spawned.Add(3*time.Second)) {
+		t.Error("Expected damage to apply after spawn protection")
+	}
+	if player.Health != 60 {
+		t.Errorf("Expected health 60 after protection, got %.1f", player.Health)
+	}
+}
+
+func TestBulletSyncClearsSpawnProtection(t *testing.T) {
+	srv := newTestServer()
+	srv.SpawnProtection = time.Minute
+	player := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	
+	now := time.Now()
+	srv.startSpawnProtection(player, now)
+	srv.handleGamePacket(player.Session, &protocol.RakNetPacket{PacketID: protocol.ID_BULLET_SYNC})
+	
+	if player.IsSpawnProtected(now) {
+		t.Error("Expected shooting to clear spawn protection")
+	}
+}
+
+func TestMovingClearsSpawnProtection(t *testing.T) {
+	srv := newTestServer()
+	srv.SpawnProtection = time.Minute
+	player := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	
+	syncAt := func(x, y, z float32) {
+		sync := make([]byte, protocol.PlayerSyncSize)
+		for i, v := range []float32{x, y, z} {
+			binary.LittleEndian.PutUint32(sync[6+i*4:], math.Float32bits(v))
+		}
+		sync[34] = 100 // health
+		srv.handleGamePacket(player.Session, &protocol.RakNetPacket{PacketID: protocol.ID_PLAYER_SYNC, Payload: sync})
+	}
+	
+	srv.startSpawnProtection(player, time.Now())
+	
+	// Standing at the spawn point, with a little jitter, keeps protection
+	syncAt(100, 200, 10)
+	syncAt(100.5, 200, 10)
+	if !player.IsSpawnProtected(time.Now()) {
+		t.Fatal("Expected spawn protection to last while the player stands still")
+	}
+	
+	syncAt(110, 200, 10)
+	if player.IsSpawnProtected(time.Now()) {
+		t.Error("Expected moving away from the spawn point to clear spawn protection")
+	}
+}
+
+func TestBulletSyncFiresShotAndAppliesDamage(t *testing.T) {
+	srv := newTestServer()
+	srv.ApplyBulletDamage = true
+	shooter := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	target := addTestPlayer(srv, 3, protocol.STATE_IN_GAME)
+	
+	shots := make([]*protocol.BulletSync, 0)
+	srv.SetPlayerWeaponShotHandler(func(player *Player, shot *protocol.BulletSync) {
+		if player == shooter {
+			shots = append(shots, shot)
+		}
+	})
+	
+	// M4 (31) hitting player 3
+	payload := make([]byte, protocol.BulletSyncSize)
+	payload[0] = protocol.BulletHitPlayer
+	payload[1] = 3
+	payload[39] = 31
+	srv.handleGamePacket(shooter.Session, &protocol.RakNetPacket{PacketID: protocol.ID_BULLET_SYNC, Payload: payload})
+	
+	if len(shots) != 1 || shots[0].HitID != 3 || shots[0].WeaponID != 31 {
+		t.Fatalf("Expected one shot event for the hit on player 3, got %d", len(shots))
+	}
+	if target.Health != 100-weaponDamage[31] {
+		t.Errorf("Expected target health %.2f, got %.2f", 100-weaponDamage[31], target.Health)
+	}
+}
+
+func TestSetPlayerName(t *testing.T) {
+	srv := newTestServer()
+	alice := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	alice.Name = "Alice"
+	bob := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	bob.Name = "Bob"
+	
+	if result := srv.SetPlayerName(0, "Alice_2"); result != NameChangeSuccess {
+		t.Fatalf("Expected NameChangeSuccess, got %d", result)
+	}
+	if alice.Name != "Alice_2" {
+		t.Errorf("Expected name Alice_2, got %s", alice.Name)
+	}
+	
+	rpcs := queuedRPCs(bob.Session)
+	if len(rpcs) != 1 {
+		t.Fatalf("Expected rename to be broadcast, got %d RPCs", len(rpcs))
+	}
+	want := protocol.BuildSetPlayerNameRPC(0, "Alice_2")
+	if string(rpcs[0]) != string(want) {
+		t.Errorf("Expected %02X, got %02X", want, rpcs[0])
+	}
+}
+
+func TestSetPlayerNameRejectsDuplicateAndInvalid(t *testing.T) {
+	srv := newTestServer()
+	alice := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	alice.Name = "Alice"
+	bob := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	bob.Name = "Bob"
+	
+	if result := srv.SetPlayerName(1, "alice"); result != NameChangeTaken {
+		t.Errorf("Expected NameChangeTaken, got %d", result)
+	}
+	if result := srv.SetPlayerName(1, "Bob"); result != NameChangeTaken {
+		t.Errorf("Expected NameChangeTaken for unchanged name, got %d", result)
+	}
+	if result := srv.SetPlayerName(1, "B!"); result != NameChangeInvalid {
+		t.Errorf("Expected NameChangeInvalid, got %d", result)
+	}
+	if bob.Name != "Bob" {
+		t.Errorf("Expected name to stay Bob, got %s", bob.Name)
+	}
+	if len(queuedRPCs(alice.Session)) != 0 {
+		t.Error("Rejected renames should not be broadcast")
+	}
+}
+
+func TestSendRPCToPlayer(t *testing.T) {
+	srv := newTestServer()
+	target := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	other := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	
+	rpc := protocol.BuildSetPlayerPosRPC(1, 2, 3)
+	if err := srv.SendRPCToPlayer(0, rpc, protocol.RELIABLE); err != nil {
+		t.Fatalf("SendRPCToPlayer failed: %v", err)
+	}
+	
+	target.Session.Mu.RLock()
+	queue := target.Session.SendQueue
+	target.Session.Mu.RUnlock()
+	if len(queue) != 1 {
+		t.Fatalf("Expected 1 queued packet, got %d", len(queue))
+	}
+	if queue[0].Payload[0] != protocol.ID_RPC || string(queue[0].Payload[1:]) != string(rpc) {
+		t.Errorf("Expected 0x7C-wrapped RPC, got %02X", queue[0].Payload)
+	}
+	if queue[0].Reliability != protocol.RELIABLE {
+		t.Errorf("Expected reliability %d, got %d", protocol.RELIABLE, queue[0].Reliability)
+	}
+	if len(queuedRPCs(other.Session)) != 0 {
+		t.Error("RPC was sent to the wrong player")
+	}
+	
+	if err := srv.SendRPCToPlayer(5, rpc, protocol.RELIABLE); err == nil {
+		t.Error("Expected error for unknown player")
+	}
+}
+
+func TestSendRPCToAll(t *testing.T) {
+	srv := newTestServer()
+	a := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	b := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	joining := addTestPlayer(srv, 2, protocol.STATE_CONNECTING)
+	
+	if err := srv.SendRPCToAll(protocol.BuildSetWeatherRPC(5), protocol.RELIABLE_ORDERED); err != nil {
+		t.Fatalf("SendRPCToAll failed: %v", err)
+	}
+	
+	if len(queuedRPCs(a.Session)) != 1 || len(queuedRPCs(b.Session)) != 1 {
+		t.Error("Expected every in-game player to receive the RPC")
+	}
+	if len(queuedRPCs(joining.Session)) != 0 {
+		t.Error("Joining player should not receive the RPC")
+	}
+}
+
+func TestTickIntervalBacksOffWhenIdle(t *testing.T) {
+	srv := newTestServer()
+	if srv.tickInterval() != idleTickInterval {
+		t.Errorf("Expected idle interval with no sessions, got %s", srv.tickInterval())
+	}
+	
+	session := addTestSession(srv, 50001, protocol.STATE_HANDSHAKE_SENT)
+	if srv.tickInterval() != activeTickInterval {
+		t.Errorf("Expected active interval on connect, got %s", srv.tickInterval())
+	}
+	
+	delete(srv.raknet.sessions, session.Addr.String())
+	if srv.tickInterval() != idleTickInterval {
+		t.Errorf("Expected idle interval after sessions drop to zero, got %s", srv.tickInterval())
+	}
+}
+
+func TestWakeUpdateLoopOnlyWhenIdle(t *testing.T) {
+	srv := newTestServer()
+	
+	srv.idle.Store(true)
+	srv.wakeUpdateLoop()
+	select {
+	case <-srv.wake:
+	default:
+		t.Error("Expected idle update loop to be woken")
+	}
+	
+	srv.idle.Store(false)
+	srv.wakeUpdateLoop()
+	select {
+	case <-srv.wake:
+		t.Error("Active update loop should not be woken")
+	default:
+	}
+}
+
+// timeoutError is a net.Error that reports a read timeout
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// mockUDPReader returns the queued errors in order, then calls onEmpty
+type mockUDPReader struct {
+	errs    []error
+	reads   int
+	onEmpty func() error
+}
+
+func (m *mockUDPReader) ReadFromUDP(b []byte) (int, *net.UDPAddr, error) {
+	m.reads++
+	if len(m.errs) == 0 {
+		return 0, nil, m.onEmpty()
+	}
+	err := m.errs[0]
+	m.errs = m.errs[1:]
+	return 0, nil, err
+}
+
+func TestReadLoopRetriesTransientErrors(t *testing.T) {
+	srv := newTestServer()
+	
+	fatal := errors.New("socket exploded")
+	conn := &mockUDPReader{
+		errs: []error{
+			timeoutError{},
+			&net.OpError{Op: "read", Net: "udp", Err: syscall.ECONNREFUSED},
+		},
+		onEmpty: func() error { return fatal },
+	}
+	
+	err := srv.readLoop(conn)
+	if !errors.Is(err, fatal) {
+		t.Errorf("Expected fatal error to stop the loop, got %v", err)
+	}
+	if conn.reads != 3 {
+		t.Errorf("Expected 3 reads (2 transient + fatal), got %d", conn.reads)
+	}
+}
+
+func TestReadLoopExitsCleanlyOnShutdown(t *testing.T) {
+	srv := newTestServer()
+	
+	conn := &mockUDPReader{
+		onEmpty: func() error {
+			close(srv.done) // Stop closes the socket
+			return &net.OpError{Op: "read", Net: "udp", Err: net.ErrClosed}
+		},
+	}
+	
+	if err := srv.readLoop(conn); err != nil {
+		t.Errorf("Expected clean exit on shutdown, got %v", err)
+	}
+}
+
+func TestStopWaitsForLoops(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	
+	srv.goLoop(srv.updateLoop)
+	srv.goLoop(srv.sessionCleanupLoop)
+	if n := srv.activeLoops.Load(); n != 2 {
+		t.Fatalf("Expected 2 running loops, got %d", n)
+	}
+	
+	listenDone := make(chan error, 1)
+	srv.loops.Add(1)
+	go func() {
+		defer srv.loops.Done()
+		listenDone <- srv.listen()
+	}()
+	
+	srv.Stop()
+	
+	if n := srv.activeLoops.Load(); n != 0 {
+		t.Errorf("Expected all loops to have exited when Stop returns, got %d running", n)
+	}
+	select {
+	case err := <-listenDone:
+		if err != nil {
+			t.Errorf("Expected listen to exit cleanly, got %v", err)
+		}
+	default:
+		t.Errorf("Expected listen to have returned before Stop")
+	}
+	
+	srv.Stop() // second call is a no-op
+}
+
+func TestServerMessageIsClientMessageRPC(t *testing.T) {
+	srv := newTestServer()
+	session := addTestSession(srv, 50001, protocol.STATE_IN_GAME)
+	
+	srv.sendServerMessage(session, "Welcome")
+	
+	session.Mu.RLock()
+	raw := session.SendQueue[0].Payload
+	session.Mu.RUnlock()
+	if raw[0] != 0x7C {
+		t.Fatalf("Expected an ID_RPC (0x7C) packet, got 0x%02X", raw[0])
+	}
+	
+	expected := protocol.BuildSendClientMessageRPC(ServerMessageColor, "Welcome")
+	if rpcs := queuedRPCs(session); len(rpcs) != 1 || string(rpcs[0]) != string(expected) {
+		t.Errorf("Expected client message RPC %02X, got %02X", expected, rpcs)
+	}
+}
+
+func TestSendClientMessageUnreliable(t *testing.T) {
+	srv := newTestServer()
+	player := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	session := player.Session
+	
+	if err := srv.SendClientMessage(0, protocol.ColorWhite, "+25", protocol.UNRELIABLE); err != nil {
+		t.Fatalf("SendClientMessage failed: %v", err)
+	}
+	
+	session.Mu.RLock()
+	queued := session.SendQueue[0]
+	messageIndex := session.MessageIndex
+	session.Mu.RUnlock()
+	if queued.Reliability != protocol.UNRELIABLE {
+		t.Errorf("Expected UNRELIABLE, got %d", queued.Reliability)
+	}
+	if messageIndex != 0 {
+		t.Errorf("Expected no message index used, got %d", messageIndex)
+	}
+	
+	srv.SendClientMessage(0, protocol.ColorWhite, "Welcome", protocol.RELIABLE_ORDERED)
+	session.Mu.RLock()
+	defer session.Mu.RUnlock()
+	if session.SendQueue[1].Reliability != protocol.RELIABLE_ORDERED || session.MessageIndex != 1 {
+		t.Errorf("Expected a reliable ordered message with index 0, got %+v", session.SendQueue[1])
+	}
+}
+
+func TestRegisterTickRunsInOrderWithElapsedTime(t *testing.T) {
+	srv := newTestServer()
+	
+	calls := make([]string, 0)
+	dts := make([]time.Duration, 0)
+	srv.RegisterTick(func(dt time.Duration) {
+		calls = append(calls, "first")
+		dts = append(dts, dt)
+	})
+	srv.RegisterTick(func(dt time.Duration) {
+		calls = append(calls, "second")
+	})
+	
+	start := time.Unix(1700000000, 0)
+	for i := 0; i < 3; i++ {
+		srv.tick(start.Add(time.Duration(i) * activeTickInterval))
+	}
+	
+	expectedCalls := []string{"first", "second", "first", "second", "first", "second"}
+	if len(calls) != len(expectedCalls) {
+		t.Fatalf("Expected %d handler calls, got %v", len(expectedCalls), calls)
+	}
+	for i := range expectedCalls {
+		if calls[i] != expectedCalls[i] {
+			t.Errorf("Call %d: expected %s, got %s", i, expectedCalls[i], calls[i])
+		}
+	}
+	
+	expectedDts := []time.Duration{0, activeTickInterval, activeTickInterval}
+	for i := range expectedDts {
+		if dts[i] != expectedDts[i] {
+			t.Errorf("Tick %d: expected dt %s, got %s", i, expectedDts[i], dts[i])
+		}
+	}
+}
+
+func TestConcurrentJoinAndBroadcast(t *testing.T) {
+	srv := newTestServer()
+	srv.MaxPlayers = 100
+	
+	const joins = 20
+	sessions := make([]*protocol.Session, joins)
+	for i := range sessions {
+		sessions[i] = addTestSession(srv, 51000+i, protocol.STATE_IN_GAME)
+	}
+	
+	var wg sync.WaitGroup
+	for i := range sessions {
+		wg.Add(2)
+		go func(session *protocol.Session) {
+			defer wg.Done()
+			srv.handlePlayerJoin(session, &protocol.RakNetPacket{PacketID: protocol.ID_PLAYER_JOIN})
+		}(sessions[i])
+		go func() {
+			defer wg.Done()
+			srv.BroadcastMessage("hello")
+			srv.ForEachPlayer(func(player *Player) { _ = player.Name })
+		}()
+	}
+	wg.Wait()
+	
+	count := 0
+	ids := make(map[uint16]bool)
+	srv.ForEachPlayer(func(player *Player) {
+		count++
+		ids[player.ID] = true
+	})
+	if count != joins || len(ids) != joins {
+		t.Errorf("Expected %d players with unique IDs, got %d (%d unique)", joins, count, len(ids))
+	}
+	
+	if !srv.RemovePlayer(0) || srv.RemovePlayer(0) {
+		t.Error("Expected RemovePlayer to remove player 0 exactly once")
+	}
+	if _, exists := srv.GetPlayer(0); exists {
+		t.Error("Expected player 0 to be gone")
+	}
+}
+
+func TestPlayerIDAllocation(t *testing.T) {
+	srv := newTestServer()
+	
+	// Concurrent joins must never hand out the same ID twice
+	const joins = 50
+	players := make([]*Player, joins)
+	var wg sync.WaitGroup
+	for i := 0; i < joins; i++ {
+		session := addTestSession(srv, 52000+i, protocol.STATE_CONNECTED)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			players[i] = srv.AddPlayer(session)
+		}(i)
+	}
+	wg.Wait()
+	
+	seen := make(map[uint16]bool)
+	for _, player := range players {
+		if player == nil || player.ID > MaxPlayerID || seen[player.ID] {
+			t.Fatalf("Expected unique IDs within 0-%d, got %+v", MaxPlayerID, player)
+		}
+		seen[player.ID] = true
+		if player.Session.PlayerID != player.ID {
+			t.Errorf("Session bound to ID %d, player has %d", player.Session.PlayerID, player.ID)
+		}
+	}
+	
+	// A freed ID is reused before higher ones
+	srv.RemovePlayer(7)
+	if player := srv.AddPlayer(addTestSession(srv, 52100, protocol.STATE_CONNECTED)); player == nil || player.ID != 7 {
+		t.Errorf("Expected the freed ID 7 to be reused, got %+v", player)
+	}
+	
+	// Allocation stops at the SA-MP limit
+	for id := uint16(0); id <= MaxPlayerID; id++ {
+		if _, exists := srv.Players[id]; !exists {
+			srv.Players[id] = NewPlayer(id, nil)
+		}
+	}
+	if player := srv.AddPlayer(addTestSession(srv, 52101, protocol.STATE_CONNECTED)); player != nil {
+		t.Errorf("Expected no ID once all %d are taken, got %d", MaxPlayerID+1, player.ID)
+	}
+}
+
+func TestPlayerPositionReadsSyncedPosition(t *testing.T) {
+	srv := newTestServer()
+	player := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	player.SetPosition(1958.3, 1343.1, 15.3)
+	player.Angle = 270
+	
+	x, y, z, angle, err := srv.PlayerPosition(0)
+	if err != nil || x != 1958.3 || y != 1343.1 || z != 15.3 || angle != 270 {
+		t.Errorf("Expected 1958.3 1343.1 15.3 facing 270, got %v %v %v facing %v (%v)", x, y, z, angle, err)
+	}
+	if _, _, _, _, err := srv.PlayerPosition(5); err == nil {
+		t.Error("Expected error for unknown player")
+	}
+}
+
+func TestTeleportPlayerResetsVelocityAndCamera(t *testing.T) {
+	srv := newTestServer()
+	player := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	
+	if err := srv.TeleportPlayer(0, 1958.3, 1343.1, 15.3, 270); err != nil {
+		t.Fatalf("TeleportPlayer failed: %v", err)
+	}
+	
+	expected := [][]byte{
+		protocol.BuildSetPlayerPosRPC(1958.3, 1343.1, 15.3),
+		protocol.BuildSetPlayerFacingAngleRPC(270),
+		protocol.BuildSetPlayerVelocityRPC(0, 0, 0),
+		protocol.BuildSetCameraBehindPlayerRPC(),
+	}
+	rpcs := queuedRPCs(player.Session)
+	if len(rpcs) != len(expected) {
+		t.Fatalf("Expected %d RPCs, got %d", len(expected), len(rpcs))
+	}
+	for i := range expected {
+		if string(rpcs[i]) != string(expected[i]) {
+			t.Errorf("RPC %d: expected %02X, got %02X", i, expected[i], rpcs[i])
+		}
+	}
+	
+	if x, y, z := player.GetPosition(); x != 1958.3 || y != 1343.1 || z != 15.3 {
+		t.Errorf("Expected tracked position to follow the teleport, got %v %v %v", x, y, z)
+	}
+	
+	if err := srv.TeleportPlayer(5, 0, 0, 0, 0); err == nil {
+		t.Error("Expected error for unknown player")
+	}
+}
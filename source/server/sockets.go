@@ -0,0 +1,163 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// socketSet is the set of local UDP sockets the server listens on. Replies go
+// out on the socket the remote address last arrived on, falling back to the
+// first (primary) socket for addresses it hasn't heard from.
+type socketSet struct {
+	conns  []*net.UDPConn
+	mu     sync.RWMutex
+	routes map[string]socketRoute // key: remote "ip:port"
+	sent   atomic.Uint64          // datagrams written
+}
+
+// socketRoute records which local socket a remote address arrived on
+type socketRoute struct {
+	conn     *net.UDPConn
+	lastSeen time.Time
+}
+
+// newSocketSet groups sockets; it returns nil when there are none so that
+// nil checks on the handler's conn keep working
+func newSocketSet(conns ...*net.UDPConn) *socketSet {
+	set := &socketSet{routes: make(map[string]socketRoute)}
+	for _, conn := range conns {
+		if conn != nil {
+			set.conns = append(set.conns, conn)
+		}
+	}
+	if len(set.conns) == 0 {
+		return nil
+	}
+	return set
+}
+
+// route remembers that addr reached us on conn. With a single socket there is
+// nothing to choose between, so nothing is recorded.
+func (ss *socketSet) route(addr *net.UDPAddr, conn *net.UDPConn, now time.Time) {
+	if ss == nil || len(ss.conns) < 2 || addr == nil || conn == nil {
+		return
+	}
+	ss.mu.Lock()
+	ss.routes[addr.String()] = socketRoute{conn: conn, lastSeen: now}
+	ss.mu.Unlock()
+}
+
+// connFor returns the socket replies to addr should be written on
+func (ss *socketSet) connFor(addr *net.UDPAddr) *net.UDPConn {
+	if ss == nil {
+		return nil
+	}
+	if len(ss.conns) > 1 && addr != nil {
+		ss.mu.RLock()
+		route, ok := ss.routes[addr.String()]
+		ss.mu.RUnlock()
+		if ok {
+			return route.conn
+		}
+	}
+	return ss.conns[0]
+}
+
+// prune forgets remote addresses not heard from since cutoff
+func (ss *socketSet) prune(cutoff time.Time) {
+	if ss == nil {
+		return
+	}
+	ss.mu.Lock()
+	for key, route := range ss.routes {
+		if route.lastSeen.Before(cutoff) {
+			delete(ss.routes, key)
+		}
+	}
+	ss.mu.Unlock()
+}
+
+// WriteToUDP writes b to addr on the socket addr arrived on
+func (ss *socketSet) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	return ss.writeOn(ss.connFor(addr), b, addr)
+}
+
+// writeOn writes b to addr on conn, counting it
+func (ss *socketSet) writeOn(conn *net.UDPConn, b []byte, addr *net.UDPAddr) (int, error) {
+	if conn == nil {
+		return 0, errors.New("no socket to write on")
+	}
+	n, err := conn.WriteToUDP(b, addr)
+	if err == nil && ss != nil {
+		ss.sent.Add(1)
+	}
+	return n, err
+}
+
+// datagramsSent returns how many datagrams were written
+func (ss *socketSet) datagramsSent() uint64 {
+	if ss == nil {
+		return 0
+	}
+	return ss.sent.Load()
+}
+
+// LocalAddr returns the primary socket's address
+func (ss *socketSet) LocalAddr() net.Addr {
+	if ss == nil {
+		return nil
+	}
+	return ss.conns[0].LocalAddr()
+}
+
+// Close closes every socket
+func (ss *socketSet) Close() error {
+	if ss == nil {
+		return nil
+	}
+	var errs []error
+	for _, conn := range ss.conns {
+		if err := conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// listenAddrs returns the addresses to bind: ListenAddrs when set, otherwise Host:Port
+func (s *Server) listenAddrs() []string {
+	if len(s.ListenAddrs) > 0 {
+		return s.ListenAddrs
+	}
+	return []string{net.JoinHostPort(s.Host, strconv.Itoa(s.Port))}
+}
+
+// bindSockets opens a UDP socket for every listen address. On failure the
+// sockets opened so far are closed again.
+func (s *Server) bindSockets() ([]*net.UDPConn, error) {
+	conns := make([]*net.UDPConn, 0, len(s.listenAddrs()))
+	for _, address := range s.listenAddrs() {
+		conn, err := listenUDP(address)
+		if err != nil {
+			for _, opened := range conns {
+				opened.Close()
+			}
+			return nil, fmt.Errorf("failed to bind UDP socket %s: %w", address, err)
+		}
+		conns = append(conns, conn)
+	}
+	return conns, nil
+}
+
+func listenUDP(address string) (*net.UDPConn, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	return net.ListenUDP("udp", addr)
+}
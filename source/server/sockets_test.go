@@ -0,0 +1,133 @@
+package server
+
+import (
+	"net"
+	"samp-server-go/source/protocol"
+	"testing"
+	"time"
+)
+
+func TestMultipleListenSocketsReplyOnArrivalSocket(t *testing.T) {
+	srv := NewServer("127.0.0.1", 0, 10)
+	srv.ListenAddrs = []string{"127.0.0.1:0", "127.0.0.1:0"}
+	
+	conns, err := srv.bindSockets()
+	if err != nil {
+		t.Fatalf("bindSockets failed: %v", err)
+	}
+	srv.conn = conns[0]
+	srv.raknet = NewRakNetHandler(conns[0], srv)
+	srv.raknet.conn = newSocketSet(conns...)
+	
+	listenDone := make(chan error, 1)
+	srv.loops.Add(1)
+	go func() {
+		defer srv.loops.Done()
+		listenDone <- srv.listen()
+	}()
+	defer func() {
+		srv.Stop()
+		if err := <-listenDone; err != nil {
+			t.Errorf("Expected listen to exit cleanly, got %v", err)
+		}
+	}()
+	
+	for i, conn := range conns {
+		serverAddr := conn.LocalAddr().(*net.UDPAddr)
+		client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatalf("Failed to open client socket: %v", err)
+		}
+		defer client.Close()
+		
+		if _, err := client.WriteToUDP(sampQuery('i'), serverAddr); err != nil {
+			t.Fatalf("Failed to send query: %v", err)
+		}
+		
+		client.SetReadDeadline(time.Now().Add(2 * time.Second))
+		buf := make([]byte, 2048)
+		n, from, err := client.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatalf("Socket %d: no reply to query: %v", i, err)
+		}
+		if n < 11 || string(buf[:4]) != "SAMP" {
+			t.Errorf("Socket %d: expected a SAMP query reply, got % X", i, buf[:n])
+		}
+		if from.Port != serverAddr.Port {
+			t.Errorf("Socket %d: reply came from port %d, expected %d", i, from.Port, serverAddr.Port)
+		}
+	}
+}
+
+func TestSingleListenAddressIsDefault(t *testing.T) {
+	srv := NewServer("127.0.0.1", 7777, 10)
+	if addrs := srv.listenAddrs(); len(addrs) != 1 || addrs[0] != "127.0.0.1:7777" {
+		t.Errorf("Expected [127.0.0.1:7777], got %v", addrs)
+	}
+}
+
+func TestSessionRepliesStayOnOriginatingSocket(t *testing.T) {
+	srv := NewServer("127.0.0.1", 0, 10)
+	srv.ListenAddrs = []string{"127.0.0.1:0", "127.0.0.1:0"}
+	conns, err := srv.bindSockets()
+	if err != nil {
+		t.Fatalf("bindSockets failed: %v", err)
+	}
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	srv.conn = conns[0]
+	srv.raknet = NewRakNetHandler(conns[0], srv)
+	srv.raknet.conn = newSocketSet(conns...)
+	socketB := conns[1].LocalAddr().(*net.UDPAddr)
+	
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to open client socket: %v", err)
+	}
+	defer client.Close()
+	clientAddr := client.LocalAddr().(*net.UDPAddr)
+	
+	// The client's cookie request arrives on socket B
+	srv.raknet.conn.route(clientAddr, conns[1], time.Now())
+	srv.raknet.HandlePacket([]byte{0x08, 0x01, 0x02, 0x03}, clientAddr)
+	
+	srv.raknet.mu.RLock()
+	session, exists := srv.raknet.sessions[clientAddr.String()]
+	srv.raknet.mu.RUnlock()
+	if !exists {
+		t.Fatal("Expected a session for the client")
+	}
+	if session.Conn != conns[1] {
+		t.Fatalf("Expected session bound to socket B")
+	}
+	
+	// Even once the arrival route is forgotten, and with socket A handed to
+	// Update, everything for this session leaves via socket B
+	srv.raknet.conn.prune(time.Now().Add(time.Hour))
+	srv.raknet.writeToSession(session, []byte{0xAA})
+	srv.raknet.SendPacket(session, &protocol.RakNetPacket{PacketID: 0xBB}, protocol.RELIABLE)
+	session.Update(conns[0])
+	
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 2048)
+	received := 0
+	for {
+		n, from, err := client.ReadFromUDP(buf)
+		if err != nil {
+			break // drained
+		}
+		received++
+		if from.Port != socketB.Port {
+			t.Fatalf("Got % X from port %d, expected everything from socket B (%d)", buf[:n], from.Port, socketB.Port)
+		}
+		client.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	}
+	
+	// Cookie reply, raw write and the flushed datagram
+	if received < 3 {
+		t.Errorf("Expected at least 3 datagrams on socket B, got %d", received)
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"fmt"
+	"samp-server-go/source/protocol"
+	"time"
+)
+
+// SpawnWeapon is a weapon and its ammo given on spawn (ID 0 = none)
+type SpawnWeapon struct {
+	ID   int32
+	Ammo int32
+}
+
+// SpawnInfo describes where and how a player spawns
+type SpawnInfo struct {
+	Team     uint8
+	Skin     int32
+	X, Y, Z  float32
+	Rotation float32
+	Weapons  [3]SpawnWeapon
+}
+
+// Spawn spawns a player at info. The client needs InitGame before
+// SetSpawnInfo and SetSpawnInfo before SpawnPlayer, so Spawn queues them in
+// that order on the ordered channel, sending InitGame only if the session
+// hasn't had it yet. It fails while the connect flow is still running.
+func (s *Server) Spawn(playerID uint16, info SpawnInfo) error {
+	player, exists := s.GetPlayer(playerID)
+	if !exists {
+		return fmt.Errorf("player %d not found", playerID)
+	}
+	session := player.Session
+	if session == nil || s.raknet == nil {
+		return fmt.Errorf("player %d has no session", playerID)
+	}
+	session.Mu.RLock()
+	state := session.State
+	session.Mu.RUnlock()
+	if state < protocol.STATE_CONNECTED {
+		return fmt.Errorf("player %d is not connected", playerID)
+	}
+	if session.GetConnectPhase() == protocol.PhaseSpawning {
+		return fmt.Errorf("player %d is still in the connect flow", playerID)
+	}
+	
+	if !session.ReachedMilestone(protocol.MilestoneInitGame) {
+		s.sendRPC(session, s.raknet.buildInitGameRPC())
+		s.raknet.markMilestone(session, protocol.MilestoneInitGame)
+	}
+	
+	w := info.Weapons
+	s.sendRPC(session, protocol.BuildSetSpawnInfoRPC(
+		info.Team, info.Skin, info.X, info.Y, info.Z, info.Rotation,
+		w[0].ID, w[0].Ammo, w[1].ID, w[1].Ammo, w[2].ID, w[2].Ammo,
+	))
+	s.sendRPC(session, protocol.BuildSpawnPlayerRPC())
+	s.raknet.markMilestone(session, protocol.MilestoneFirstSpawn)
+	s.reapplyFreeze(player)
+	
+	s.mu.Lock()
+	player.PosX, player.PosY, player.PosZ = info.X, info.Y, info.Z
+	player.Angle = info.Rotation
+	player.Skin = int(info.Skin)
+	s.mu.Unlock()
+	
+	s.startSpawnProtection(player, time.Now())
+	return nil
+}
+
+// TogglePlayerControllable freezes (controllable = false) or unfreezes a player.
+// The state is kept on the player so it survives a respawn.
+func (s *Server) TogglePlayerControllable(playerID uint16, controllable bool) error {
+	player, exists := s.GetPlayer(playerID)
+	if !exists {
+		return fmt.Errorf("player %d not found", playerID)
+	}
+	if player.Session == nil {
+		return fmt.Errorf("player %d has no session", playerID)
+	}
+	
+	s.mu.Lock()
+	player.Frozen = !controllable
+	s.mu.Unlock()
+	
+	s.sendRPC(player.Session, protocol.BuildTogglePlayerControllableRPC(controllable))
+	return nil
+}
+
+// reapplyFreeze re-sends the freeze after SpawnPlayer, which makes the client controllable again
+func (s *Server) reapplyFreeze(player *Player) {
+	s.mu.RLock()
+	frozen := player.Frozen
+	s.mu.RUnlock()
+	
+	if frozen {
+		s.sendRPC(player.Session, protocol.BuildTogglePlayerControllableRPC(false))
+	}
+}
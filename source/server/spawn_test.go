@@ -0,0 +1,102 @@
+package server
+
+import (
+	"bytes"
+	"samp-server-go/source/protocol"
+	"testing"
+)
+
+func TestSpawnQueuesInitGameSpawnInfoSpawnPlayerInOrder(t *testing.T) {
+	srv := newTestServer()
+	player := addTestPlayer(srv, 0, protocol.STATE_CONNECTED)
+	
+	info := SpawnInfo{Skin: 7, X: 1958, Y: 1343, Z: 15, Rotation: 270, Weapons: [3]SpawnWeapon{{ID: 24, Ammo: 200}}}
+	if err := srv.Spawn(0, info); err != nil {
+		t.Fatalf("Spawn failed: %v", err)
+	}
+	
+	want := []byte{protocol.RPC_InitGame, protocol.RPC_SetSpawnInfo, protocol.RPC_SpawnPlayer}
+	rpcs := queuedRPCs(player.Session)
+	if len(rpcs) != len(want) {
+		t.Fatalf("Expected %d RPCs, got %d", len(want), len(rpcs))
+	}
+	for i, id := range want {
+		if rpcs[i][0] != id {
+			t.Errorf("RPC %d: expected 0x%02X, got 0x%02X", i, id, rpcs[i][0])
+		}
+	}
+	
+	player.Session.Mu.RLock()
+	for i, encap := range player.Session.SendQueue {
+		if encap.Reliability != protocol.RELIABLE_ORDERED {
+			t.Errorf("RPC %d: expected RELIABLE_ORDERED, got %d", i, encap.Reliability)
+		}
+	}
+	player.Session.Mu.RUnlock()
+	
+	// InitGame is only needed once per session
+	if err := srv.Spawn(0, info); err != nil {
+		t.Fatalf("Respawn failed: %v", err)
+	}
+	rpcs = queuedRPCs(player.Session)
+	if len(rpcs) != 5 || rpcs[3][0] != protocol.RPC_SetSpawnInfo || rpcs[4][0] != protocol.RPC_SpawnPlayer {
+		t.Errorf("Expected respawn to queue only SetSpawnInfo and SpawnPlayer, got %d RPCs", len(rpcs))
+	}
+}
+
+func TestSpawnRequiresConnectedPlayer(t *testing.T) {
+	srv := newTestServer()
+	
+	if err := srv.Spawn(5, SpawnInfo{}); err == nil {
+		t.Error("Expected an error spawning an unknown player")
+	}
+	
+	player := addTestPlayer(srv, 1, protocol.STATE_UNCONNECTED)
+	if err := srv.Spawn(1, SpawnInfo{}); err == nil {
+		t.Error("Expected an error spawning a player that is not connected")
+	}
+	if n := len(queuedRPCs(player.Session)); n != 0 {
+		t.Errorf("Expected nothing queued, got %d RPCs", n)
+	}
+}
+
+func TestFreezePersistsAcrossRespawn(t *testing.T) {
+	srv := newTestServer()
+	player := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	
+	if err := srv.TogglePlayerControllable(0, false); err != nil {
+		t.Fatalf("Freeze failed: %v", err)
+	}
+	if !player.Frozen {
+		t.Fatal("Expected player to be frozen")
+	}
+	
+	srv.handlePlayerDeath(player)
+	
+	rpcs := queuedRPCs(player.Session)
+	last := rpcs[len(rpcs)-2:]
+	if !bytes.Equal(last[0], protocol.BuildSpawnPlayerRPC()) ||
+		!bytes.Equal(last[1], protocol.BuildTogglePlayerControllableRPC(false)) {
+		t.Errorf("Expected SpawnPlayer followed by a freeze, got % X", last)
+	}
+}
+
+func TestUnfreezeClearsFrozenState(t *testing.T) {
+	srv := newTestServer()
+	player := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	
+	srv.TogglePlayerControllable(0, false)
+	if err := srv.TogglePlayerControllable(0, true); err != nil {
+		t.Fatalf("Unfreeze failed: %v", err)
+	}
+	if player.Frozen {
+		t.Fatal("Expected player to be unfrozen")
+	}
+	
+	srv.handlePlayerDeath(player)
+	
+	rpcs := queuedRPCs(player.Session)
+	if !bytes.Equal(rpcs[len(rpcs)-1], protocol.BuildSpawnPlayerRPC()) {
+		t.Errorf("Expected no freeze after respawn, last RPC % X", rpcs[len(rpcs)-1])
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"fmt"
+	"samp-server-go/source/protocol"
+	"time"
+)
+
+// ServerStats is a snapshot of the server's runtime counters
+type ServerStats struct {
+	Uptime       time.Duration
+	Players      int // currently connected
+	PeakPlayers  int // most connected at once
+	TotalJoins   uint64
+	DatagramsIn  uint64
+	DatagramsOut uint64
+	DatagramsDropped uint64 // inbound datagrams dropped because their worker was backed up
+	Panics       uint64 // gamemode callback panics recovered (see PanicThrough)
+	Reliability  protocol.ReliabilityCounts // encapsulated packets per reliability type
+}
+
+func (st ServerStats) String() string {
+	return fmt.Sprintf("uptime %s, players %d (peak %d), joins %d, datagrams in %d / out %d",
+		st.Uptime.Truncate(time.Second), st.Players, st.PeakPlayers, st.TotalJoins, st.DatagramsIn, st.DatagramsOut)
+}
+
+// Stats returns the server's uptime and lifetime counters
+func (s *Server) Stats() ServerStats {
+	s.mu.RLock()
+	stats := ServerStats{
+		Uptime:      time.Since(s.startedAt),
+		Players:     len(s.Players),
+		PeakPlayers: s.peakPlayers,
+	}
+	s.mu.RUnlock()
+	
+	stats.TotalJoins = s.totalJoins.Load()
+	stats.DatagramsIn = s.datagramsIn.Load()
+	if s.workers != nil {
+		stats.DatagramsDropped = s.workers.dropped.Load()
+	}
+	stats.Panics = s.panics.Load()
+	if s.raknet != nil {
+		stats.DatagramsOut = s.raknet.conn.datagramsSent() + s.raknet.sessionDatagrams.Load()
+		stats.Reliability = s.raknet.reliability.Snapshot()
+	}
+	return stats
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"samp-server-go/source/protocol"
+	"testing"
+	"time"
+)
+
+func TestStatsUptimeAndPeakPlayers(t *testing.T) {
+	srv := newTestServer()
+	
+	first := srv.Stats()
+	time.Sleep(5 * time.Millisecond)
+	if second := srv.Stats(); second.Uptime <= first.Uptime {
+		t.Errorf("Expected uptime to increase, got %s then %s", first.Uptime, second.Uptime)
+	}
+	
+	players := make([]*Player, 0, 3)
+	for i := 0; i < 3; i++ {
+		players = append(players, srv.AddPlayer(addTestSession(srv, 53000+i, protocol.STATE_CONNECTED)))
+	}
+	srv.RemovePlayer(players[0].ID)
+	srv.RemovePlayer(players[1].ID)
+	srv.AddPlayer(addTestSession(srv, 53010, protocol.STATE_CONNECTED))
+	
+	stats := srv.Stats()
+	if stats.Players != 2 || stats.PeakPlayers != 3 || stats.TotalJoins != 4 {
+		t.Errorf("Expected 2 players, peak 3, 4 joins, got %+v", stats)
+	}
+}
+
+func TestStatsCountsDatagrams(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	session := addTestSession(srv, 53100, protocol.STATE_CONNECTED)
+	
+	srv.raknet.writeToSession(session, []byte{protocol.ID_CONNECTED_PONG})
+	if out := srv.Stats().DatagramsOut; out != 1 {
+		t.Errorf("Expected 1 datagram out, got %d", out)
+	}
+	
+	// Session.Update writes ACKs, NACKs and data on its own
+	session.RetransmitTimeout = time.Millisecond
+	session.Mu.Lock()
+	session.ACKQueue[0] = struct{}{}
+	session.NACKQueue = append(session.NACKQueue, 1)
+	session.Mu.Unlock()
+	srv.raknet.SendPacket(session, protocol.NewRakNetPacket(protocol.ID_CONNECTED_PONG), protocol.RELIABLE)
+	session.Update(srv.raknet.sessionConn(session))
+	if out := srv.Stats().DatagramsOut; out != 4 {
+		t.Errorf("Expected the ACK, NACK and data datagram counted, got %d out", out)
+	}
+	
+	// and so are its timer resends
+	time.Sleep(5 * time.Millisecond)
+	session.Update(srv.raknet.sessionConn(session))
+	if out := srv.Stats().DatagramsOut; out != 5 {
+		t.Errorf("Expected the resend counted, got %d out", out)
+	}
+}
+
+func TestStatsCountsPacketsPerReliability(t *testing.T) {
+	srv := newTestServerWithConn(t)
+	session := addTestSession(srv, 53200, protocol.STATE_CONNECTED)
+	
+	for _, reliability := range []byte{protocol.UNRELIABLE_SEQUENCED, protocol.RELIABLE_ORDERED, protocol.RELIABLE_ORDERED} {
+		srv.raknet.SendPacket(session, protocol.NewRakNetPacket(protocol.ID_CONNECTED_PONG), reliability)
+	}
+	session.Update(srv.raknet.sessionConn(session))
+	
+	dp := protocol.NewDataPacket()
+	for _, reliability := range []byte{protocol.UNRELIABLE, protocol.RELIABLE, protocol.RELIABLE} {
+		dp.Packets = append(dp.Packets, &protocol.EncapsulatedPacket{
+			Reliability: reliability,
+			Payload:     []byte{protocol.ID_CONNECTED_PING},
+		})
+	}
+	session.HandleDataPacket(dp)
+	
+	counts := srv.Stats().Reliability
+	if counts.Sent[protocol.UNRELIABLE_SEQUENCED] != 1 || counts.Sent[protocol.RELIABLE_ORDERED] != 2 || counts.Sent[protocol.RELIABLE] != 0 {
+		t.Errorf("Unexpected sent counts: %v", counts.Sent)
+	}
+	if counts.Received[protocol.UNRELIABLE] != 1 || counts.Received[protocol.RELIABLE] != 2 || counts.Received[protocol.RELIABLE_ORDERED] != 0 {
+		t.Errorf("Unexpected received counts: %v", counts.Received)
+	}
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"log"
+	"samp-server-go/source/protocol"
+)
+
+// VehiclePosition is where the streamer sees a vehicle
+type VehiclePosition struct {
+	ID      uint16
+	X, Y, Z float32
+}
+
+// SetVehiclePositionSource sets the function the streamer gets vehicle
+// positions from. Without one no vehicles are streamed.
+func (s *Server) SetVehiclePositionSource(source func() []VehiclePosition) {
+	s.vehiclePositions = source
+}
+
+// SetVehicleCreator sets the function that builds a vehicle's CreateVehicle
+// RPC. With one set, vehicles are created on a client when they stream in
+// and removed when they stream out; nil from it means nothing is sent.
+func (s *Server) SetVehicleCreator(build func(vehicleID uint16) []byte) {
+	s.vehicleCreateRPC = build
+}
+
+// SetPlayerStreamInHandler sets the callback run when streamed comes into forPlayer's stream range
+func (s *Server) SetPlayerStreamInHandler(handler func(forPlayer, streamed *Player)) {
+	s.onPlayerStreamIn = handler
+}
+
+// SetPlayerStreamOutHandler sets the callback run when streamed leaves forPlayer's stream range
+func (s *Server) SetPlayerStreamOutHandler(handler func(forPlayer, streamed *Player)) {
+	s.onPlayerStreamOut = handler
+}
+
+// SetVehicleStreamInHandler sets the callback run when a vehicle comes into forPlayer's stream range
+func (s *Server) SetVehicleStreamInHandler(handler func(forPlayer *Player, vehicleID uint16)) {
+	s.onVehicleStreamIn = handler
+}
+
+// SetVehicleStreamOutHandler sets the callback run when a vehicle leaves forPlayer's stream range
+func (s *Server) SetVehicleStreamOutHandler(handler func(forPlayer *Player, vehicleID uint16)) {
+	s.onVehicleStreamOut = handler
+}
+
+// AddVehicle streams a new vehicle in for the players in range straight away
+// instead of on their next streamer pass. As with the streamer, nothing is
+// sent without a vehicle creator.
+func (s *Server) AddVehicle(vehicleID uint16, x, y, z float32) {
+	if s.vehicleCreateRPC == nil {
+		return
+	}
+	
+	s.mu.Lock()
+	streamed := make([]*Player, 0)
+	for _, player := range s.Players {
+		if !player.Connected || !player.IsInGame() || !player.IsAlive() {
+			continue
+		}
+		if s.inStreamRange(player, x, y, z) && !player.StreamedVehicles[vehicleID] {
+			player.StreamedVehicles[vehicleID] = true
+			streamed = append(streamed, player)
+		}
+	}
+	s.mu.Unlock()
+	
+	if len(streamed) == 0 {
+		return
+	}
+	var rpc []byte
+	s.callback("vehicle create", func() { rpc = s.vehicleCreateRPC(vehicleID) })
+	for _, player := range streamed {
+		if rpc != nil {
+			s.sendRPC(player.Session, rpc)
+		}
+		if s.onVehicleStreamIn != nil {
+			s.callback("vehicle stream in", func() { s.onVehicleStreamIn(player, vehicleID) })
+		}
+		s.trigger(EventVehicleStreamIn, player.ID, vehicleID)
+	}
+}
+
+// SendVehicleRPC sends a vehicle's RPC to the players that have it streamed
+// in. Everyone else gets the vehicle's current state in CreateVehicle when it
+// streams in for them.
+func (s *Server) SendVehicleRPC(vehicleID uint16, rpcPayload []byte) {
+	s.mu.RLock()
+	streamed := make([]*protocol.Session, 0)
+	for _, player := range s.Players {
+		if player.StreamedVehicles[vehicleID] {
+			streamed = append(streamed, player.Session)
+		}
+	}
+	s.mu.RUnlock()
+	
+	for _, session := range streamed {
+		s.sendRPC(session, rpcPayload)
+	}
+}
+
+// RemoveVehicle removes a destroyed vehicle from every client that had it
+// streamed in. Players inside it are ejected first, and trailers referring to
+// it are cleared.
+func (s *Server) RemoveVehicle(vehicleID uint16) {
+	s.mu.Lock()
+	streamed := make([]*protocol.Session, 0)
+	ejected := make([]*Player, 0)
+	for _, player := range s.Players {
+		if player.VehicleID == vehicleID {
+			player.VehicleID = 0
+			player.Seat = 0
+			player.TrailerID = 0
+			ejected = append(ejected, player)
+		}
+		if player.TrailerID == vehicleID {
+			player.TrailerID = 0
+		}
+		if player.StreamedVehicles[vehicleID] {
+			delete(player.StreamedVehicles, vehicleID)
+			streamed = append(streamed, player.Session)
+		}
+	}
+	s.mu.Unlock()
+	
+	for _, player := range ejected {
+		s.sendRPC(player.Session, protocol.BuildRemovePlayerFromVehicleRPC())
+		s.trigger(EventPlayerVehicleChange, player.ID, VehicleChangeData{})
+	}
+	for _, session := range streamed {
+		s.sendRPC(session, protocol.BuildDestroyVehicleRPC(vehicleID))
+	}
+	
+	log.Printf("🚗 Vehicle %d removed from %d clients (%d ejected)", vehicleID, len(streamed), len(ejected))
+}
+
+// inStreamRange reports whether a point is within StreamDistance of player
+// (0 = unlimited). The caller holds s.mu.
+func (s *Server) inStreamRange(player *Player, x, y, z float32) bool {
+	if s.StreamDistance <= 0 {
+		return true
+	}
+	dx := x - player.PosX
+	dy := y - player.PosY
+	dz := z - player.PosZ
+	return dx*dx+dy*dy+dz*dz <= s.StreamDistance*s.StreamDistance
+}
+
+// streamEntities updates which players and vehicles are in player's stream
+// range and runs the stream in/out callbacks and events, once per crossing.
+// Players who left the server are dropped without a stream-out; the
+// disconnect handler covers them.
+func (s *Server) streamEntities(player *Player) {
+	var vehicles []VehiclePosition
+	if s.vehiclePositions != nil {
+		s.callback("vehicle positions", func() { vehicles = s.vehiclePositions() })
+	}
+	
+	s.mu.Lock()
+	
+	playersIn := make([]*Player, 0)
+	playersOut := make([]*Player, 0)
+	for id, other := range s.Players {
+		if id == player.ID {
+			continue
+		}
+		visible := other.Connected && other.IsInGame() &&
+			other.VirtualWorld == player.VirtualWorld &&
+			s.inStreamRange(player, other.PosX, other.PosY, other.PosZ)
+		if visible && !player.StreamedPlayers[id] {
+			player.StreamedPlayers[id] = true
+			playersIn = append(playersIn, other)
+		} else if !visible && player.StreamedPlayers[id] {
+			delete(player.StreamedPlayers, id)
+			playersOut = append(playersOut, other)
+		}
+	}
+	for id := range player.StreamedPlayers {
+		if _, exists := s.Players[id]; !exists {
+			delete(player.StreamedPlayers, id)
+		}
+	}
+	
+	wanted := make(map[uint16]bool, len(vehicles))
+	vehiclesIn := make([]uint16, 0)
+	for _, vehicle := range vehicles {
+		if !s.inStreamRange(player, vehicle.X, vehicle.Y, vehicle.Z) {
+			continue
+		}
+		wanted[vehicle.ID] = true
+		if !player.StreamedVehicles[vehicle.ID] {
+			player.StreamedVehicles[vehicle.ID] = true
+			vehiclesIn = append(vehiclesIn, vehicle.ID)
+		}
+	}
+	vehiclesOut := make([]uint16, 0)
+	for vehicleID := range player.StreamedVehicles {
+		if !wanted[vehicleID] {
+			delete(player.StreamedVehicles, vehicleID)
+			vehiclesOut = append(vehiclesOut, vehicleID)
+		}
+	}
+	
+	s.mu.Unlock()
+	
+	// Run callbacks without holding the lock so they can call back into the server
+	for _, other := range playersOut {
+		if s.onPlayerStreamOut != nil {
+			s.callback("stream out", func() { s.onPlayerStreamOut(player, other) })
+		}
+		s.trigger(EventPlayerStreamOut, player.ID, other.ID)
+	}
+	for _, other := range playersIn {
+		if s.onPlayerStreamIn != nil {
+			s.callback("stream in", func() { s.onPlayerStreamIn(player, other) })
+		}
+		s.trigger(EventPlayerStreamIn, player.ID, other.ID)
+	}
+	if s.vehicleCreateRPC != nil {
+		for _, vehicleID := range vehiclesOut {
+			s.sendRPC(player.Session, protocol.BuildDestroyVehicleRPC(vehicleID))
+		}
+		for _, vehicleID := range vehiclesIn {
+			var rpc []byte
+			s.callback("vehicle create", func() { rpc = s.vehicleCreateRPC(vehicleID) })
+			if rpc != nil {
+				s.sendRPC(player.Session, rpc)
+			}
+		}
+	}
+	for _, vehicleID := range vehiclesOut {
+		if s.onVehicleStreamOut != nil {
+			s.callback("vehicle stream out", func() { s.onVehicleStreamOut(player, vehicleID) })
+		}
+		s.trigger(EventVehicleStreamOut, player.ID, vehicleID)
+	}
+	for _, vehicleID := range vehiclesIn {
+		if s.onVehicleStreamIn != nil {
+			s.callback("vehicle stream in", func() { s.onVehicleStreamIn(player, vehicleID) })
+		}
+		s.trigger(EventVehicleStreamIn, player.ID, vehicleID)
+	}
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"samp-server-go/source/protocol"
+	"testing"
+)
+
+func TestPlayerStreamInOutFiresOncePerCrossing(t *testing.T) {
+	srv := newTestServer()
+	srv.StreamDistance = 200
+	observer := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	other := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	other.PosX = 500
+	
+	events := make([]string, 0)
+	srv.SetPlayerStreamInHandler(func(forPlayer, streamed *Player) {
+		if forPlayer == observer && streamed == other {
+			events = append(events, "in")
+		}
+	})
+	srv.SetPlayerStreamOutHandler(func(forPlayer, streamed *Player) {
+		if forPlayer == observer && streamed == other {
+			events = append(events, "out")
+		}
+	})
+	
+	// Walk into range, stay a tick, walk out, stay a tick
+	for _, x := range []float32{500, 100, 50, 300, 400} {
+		other.PosX = x
+		srv.streamEntities(observer)
+	}
+	
+	if len(events) != 2 || events[0] != "in" || events[1] != "out" {
+		t.Errorf("Expected [in out], got %v", events)
+	}
+}
+
+func TestVehicleStreamInOutFiresOncePerCrossing(t *testing.T) {
+	srv := newTestServer()
+	srv.StreamDistance = 200
+	observer := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	
+	vehicle := VehiclePosition{ID: 7, X: 100}
+	srv.SetVehiclePositionSource(func() []VehiclePosition {
+		return []VehiclePosition{vehicle}
+	})
+	
+	events := make([]string, 0)
+	srv.SetVehicleStreamInHandler(func(forPlayer *Player, vehicleID uint16) {
+		events = append(events, "in")
+	})
+	srv.SetVehicleStreamOutHandler(func(forPlayer *Player, vehicleID uint16) {
+		events = append(events, "out")
+	})
+	
+	for _, x := range []float32{0, 0, 400, 400} {
+		observer.PosX = x
+		srv.streamEntities(observer)
+	}
+	
+	if len(events) != 2 || events[0] != "in" || events[1] != "out" {
+		t.Errorf("Expected [in out], got %v", events)
+	}
+}
+
+func TestSpawnedVehicleCreatedForNearbyPlayer(t *testing.T) {
+	srv := newTestServer()
+	srv.StreamDistance = 200
+	near := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	far := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	far.PosX = 1000
+	
+	vehicles := make([]VehiclePosition, 0)
+	srv.SetVehiclePositionSource(func() []VehiclePosition { return vehicles })
+	create := protocol.BuildCreateVehicleRPC(5, 411, 10, 0, 0, 90, 1, 3, 1000, 0)
+	srv.SetVehicleCreator(func(vehicleID uint16) []byte {
+		if vehicleID == 5 {
+			return create
+		}
+		return nil
+	})
+	
+	// Spawn a vehicle next to the first player
+	vehicles = append(vehicles, VehiclePosition{ID: 5, X: 10})
+	srv.streamEntities(near)
+	srv.streamEntities(far)
+	
+	rpcs := queuedRPCs(near.Session)
+	if len(rpcs) != 1 || !bytes.Equal(rpcs[0], create) {
+		t.Fatalf("Expected the CreateVehicle RPC queued for the nearby player, got %d RPCs", len(rpcs))
+	}
+	if n := len(queuedRPCs(far.Session)); n != 0 {
+		t.Errorf("Expected nothing for the far player, got %d RPCs", n)
+	}
+	
+	// Leaving range removes it again
+	near.PosX = 500
+	srv.streamEntities(near)
+	rpcs = queuedRPCs(near.Session)
+	if len(rpcs) != 2 || !bytes.Equal(rpcs[1], protocol.BuildDestroyVehicleRPC(5)) {
+		t.Errorf("Expected a DestroyVehicle RPC after leaving range, got %d RPCs", len(rpcs))
+	}
+}
+
+func TestAddVehicleStreamsInForNearbyPlayers(t *testing.T) {
+	srv := newTestServer()
+	srv.StreamDistance = 200
+	near := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	far := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	far.PosX = 1000
+	
+	vehicles := []VehiclePosition{{ID: 5, X: 10}}
+	srv.SetVehiclePositionSource(func() []VehiclePosition { return vehicles })
+	create := protocol.BuildCreateVehicleRPC(5, 411, 10, 0, 0, 90, 1, 3, 1000, 0)
+	srv.SetVehicleCreator(func(vehicleID uint16) []byte { return create })
+	streamedIn := make([]uint16, 0)
+	srv.SetVehicleStreamInHandler(func(forPlayer *Player, vehicleID uint16) {
+		streamedIn = append(streamedIn, forPlayer.ID)
+	})
+	
+	// Created on spawn, without waiting for the streamer
+	srv.AddVehicle(5, 10, 0, 0)
+	if rpcs := queuedRPCs(near.Session); len(rpcs) != 1 || !bytes.Equal(rpcs[0], create) {
+		t.Fatalf("Expected the CreateVehicle RPC queued for the nearby player, got %d RPCs", len(rpcs))
+	}
+	if n := len(queuedRPCs(far.Session)); n != 0 {
+		t.Errorf("Expected nothing for the far player, got %d RPCs", n)
+	}
+	if len(streamedIn) != 1 || streamedIn[0] != 0 {
+		t.Errorf("Expected one stream-in for player 0, got %v", streamedIn)
+	}
+	
+	// The next streamer pass does not create it again
+	srv.streamEntities(near)
+	if n := len(queuedRPCs(near.Session)); n != 1 {
+		t.Errorf("Expected no second CreateVehicle, got %d RPCs", n)
+	}
+}
+
+func TestSendVehicleRPCReachesStreamedPlayersOnly(t *testing.T) {
+	srv := newTestServer()
+	watcher := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	other := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	watcher.StreamedVehicles[9] = true
+	other.StreamedVehicles[4] = true
+	
+	paintjob := protocol.BuildSetVehiclePaintjobRPC(9, 2)
+	srv.SendVehicleRPC(9, paintjob)
+	
+	if rpcs := queuedRPCs(watcher.Session); len(rpcs) != 1 || !bytes.Equal(rpcs[0], paintjob) {
+		t.Errorf("Expected the paintjob RPC for the player that has the vehicle, got %02X", rpcs)
+	}
+	if n := len(queuedRPCs(other.Session)); n != 0 {
+		t.Errorf("Expected nothing for a player without the vehicle, got %d RPCs", n)
+	}
+}
+
+func TestRemoveVehicleDestroysForStreamedPlayersOnly(t *testing.T) {
+	srv := newTestServer()
+	driver := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	watcher := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	other := addTestPlayer(srv, 2, protocol.STATE_IN_GAME)
+	driver.StreamedVehicles[9] = true
+	driver.VehicleID = 9
+	watcher.StreamedVehicles[9] = true
+	other.StreamedVehicles[4] = true
+	
+	srv.RemoveVehicle(9)
+	
+	destroy := protocol.BuildDestroyVehicleRPC(9)
+	rpcs := queuedRPCs(driver.Session)
+	if len(rpcs) != 2 || !bytes.Equal(rpcs[0], protocol.BuildRemovePlayerFromVehicleRPC()) || !bytes.Equal(rpcs[1], destroy) {
+		t.Errorf("Expected the driver to be ejected, then the vehicle destroyed, got % X", rpcs)
+	}
+	if driver.VehicleID != 0 {
+		t.Errorf("Expected the driver to be on foot, still in vehicle %d", driver.VehicleID)
+	}
+	if rpcs := queuedRPCs(watcher.Session); len(rpcs) != 1 || !bytes.Equal(rpcs[0], destroy) {
+		t.Errorf("Expected the watcher to get the destroy RPC, got % X", rpcs)
+	}
+	if n := len(queuedRPCs(other.Session)); n != 0 {
+		t.Errorf("Expected nothing for a player without the vehicle streamed, got %d RPCs", n)
+	}
+	if driver.StreamedVehicles[9] || watcher.StreamedVehicles[9] || !other.StreamedVehicles[4] {
+		t.Error("Expected only vehicle 9 to be cleared from streamed sets")
+	}
+}
+
+func TestStreamEventsFireAndReusedIDStreamsIn(t *testing.T) {
+	srv := newTestServer()
+	srv.StreamDistance = 200
+	observer := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	srv.SetVehiclePositionSource(func() []VehiclePosition {
+		return []VehiclePosition{{ID: 7, X: 100}}
+	})
+	
+	var got []string
+	record := func(kind string) EventHandler {
+		return func(e Event) {
+			if e.PlayerID == 0 {
+				got = append(got, fmt.Sprintf("%s %d", kind, e.Data.(uint16)))
+			}
+		}
+	}
+	srv.Events.Register(EventPlayerStreamIn, record("player in"))
+	srv.Events.Register(EventPlayerStreamOut, record("player out"))
+	srv.Events.Register(EventVehicleStreamIn, record("vehicle in"))
+	srv.Events.Register(EventVehicleStreamOut, record("vehicle out"))
+	
+	srv.streamEntities(observer)
+	observer.PosX = 1000
+	srv.streamEntities(observer)
+	
+	want := []string{"player in 1", "vehicle in 7", "player out 1", "vehicle out 7"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	
+	// Player 1 leaves while streamed in and someone else takes the ID before
+	// the next stream pass
+	observer.PosX = 0
+	srv.streamEntities(observer)
+	srv.RemovePlayer(1)
+	addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	
+	got = nil
+	srv.streamEntities(observer)
+	if len(got) != 1 || got[0] != "player in 1" {
+		t.Errorf("Expected the new player 1 streamed in, got %v", got)
+	}
+}
@@ -0,0 +1,276 @@
+package server
+
+import (
+	"log"
+	"samp-server-go/source/protocol"
+	"time"
+)
+
+// Defaults for relaying sync between players
+const (
+	DefaultSyncRate       = 20    // updates per second about each observed player
+	DefaultStreamDistance = 200.0 // SA-MP's default stream_distance
+)
+
+// syncKey identifies one recipient watching one kind of sync from another player
+type syncKey struct {
+	recipient uint16
+	subject   uint16
+	packetID  byte
+}
+
+// syncSlot tracks when a recipient last got an update about a subject. Updates
+// arriving faster than SyncRate replace pending, so only the latest is sent.
+type syncSlot struct {
+	last    time.Time
+	pending []byte
+}
+
+func (s *Server) handlePlayerSync(session *protocol.Session, packet *protocol.RakNetPacket) {
+	player, ok := s.playerForSession(session)
+	if !ok {
+		return
+	}
+	
+	sync, err := protocol.ParsePlayerSync(protocol.NewBitStream(packet.Payload))
+	if err != nil {
+		log.Printf("⚠️ Dropped player sync from player %d: %v", player.ID, err)
+		return
+	}
+	
+	now := time.Now()
+	s.mu.Lock()
+	player.SetPosition(sync.Position[0], sync.Position[1], sync.Position[2])
+	// Walking away from the spawn point gives up spawn protection
+	if player.IsSpawnProtected(now) && player.movedFromSpawn(sync.Position[0], sync.Position[1], sync.Position[2]) {
+		player.ClearSpawnProtection()
+	}
+	mismatch := !syncedStatMatches(sync.Health, player.Health) || !syncedStatMatches(sync.Armour, player.Armour)
+	flagged := mismatch && !player.StatsMismatch
+	player.StatsMismatch = mismatch
+	health, armour := player.Health, player.Armour
+	s.mu.Unlock()
+	if flagged {
+		log.Printf("🛡️ Player %d synced health %d armour %d, server has %.0f and %.0f",
+			player.ID, sync.Health, sync.Armour, health, armour)
+	}
+	s.setPlayerVehicle(player, 0, 0) // on-foot sync means out of any vehicle
+	
+	s.notePlayerInput(player, packet.Payload, now)
+	s.relayPlayerSync(player, packet.Payload[:protocol.PlayerSyncSize], now)
+}
+
+// syncedStatMatches reports whether a health or armour byte from a sync
+// agrees with the server's value, allowing for the byte's rounding
+func syncedStatMatches(synced uint8, server float32) bool {
+	diff := float32(synced) - server
+	return diff > -1 && diff < 1
+}
+
+func (s *Server) handleAimSync(session *protocol.Session, packet *protocol.RakNetPacket) {
+	player, ok := s.playerForSession(session)
+	if !ok {
+		return
+	}
+	
+	aim, err := protocol.DecodeAimSync(packet.Payload)
+	if err != nil {
+		log.Printf("⚠️ Dropped aim sync from player %d: %v", player.ID, err)
+		return
+	}
+	
+	s.mu.Lock()
+	player.Aim = aim
+	s.mu.Unlock()
+	
+	s.relaySync(protocol.ID_AIM_SYNC, player, packet.Payload[:protocol.AimSyncSize], time.Now())
+}
+
+func (s *Server) handlePassengerSync(session *protocol.Session, packet *protocol.RakNetPacket) {
+	player, ok := s.playerForSession(session)
+	if !ok {
+		return
+	}
+	
+	ps, err := protocol.DecodePassengerSync(packet.Payload)
+	if err != nil {
+		log.Printf("⚠️ Dropped passenger sync from player %d: %v", player.ID, err)
+		return
+	}
+	if !s.vehicleKnown(ps.VehicleID) {
+		log.Printf("⚠️ Dropped passenger sync from player %d: unknown vehicle %d", player.ID, ps.VehicleID)
+		return
+	}
+	
+	s.mu.Lock()
+	player.SetPosition(ps.Position[0], ps.Position[1], ps.Position[2])
+	s.mu.Unlock()
+	s.setPlayerVehicle(player, ps.VehicleID, ps.Seat)
+	
+	s.relaySync(protocol.ID_PASSENGER_SYNC, player, packet.Payload[:protocol.PassengerSyncSize], time.Now())
+}
+
+func (s *Server) handleTrailerSync(session *protocol.Session, packet *protocol.RakNetPacket) {
+	player, ok := s.playerForSession(session)
+	if !ok {
+		return
+	}
+	
+	ts, err := protocol.DecodeTrailerSync(packet.Payload)
+	if err != nil {
+		log.Printf("⚠️ Dropped trailer sync from player %d: %v", player.ID, err)
+		return
+	}
+	
+	// Trailers are synced by the driver of the towing vehicle
+	s.mu.Lock()
+	driving := player.VehicleID != 0 && player.Seat == 0
+	s.mu.Unlock()
+	if !driving || !s.vehicleKnown(ts.TrailerID) {
+		log.Printf("⚠️ Dropped trailer sync from player %d: trailer %d not towed by a known vehicle", player.ID, ts.TrailerID)
+		return
+	}
+	
+	s.mu.Lock()
+	player.TrailerID = ts.TrailerID
+	s.mu.Unlock()
+	
+	s.relaySync(protocol.ID_TRAILER_SYNC, player, packet.Payload[:protocol.TrailerSyncSize], time.Now())
+}
+
+// setPlayerVehicle records the vehicle and seat a player is in (vehicle 0 =
+// on foot) and fires EventPlayerVehicleChange when the vehicle changed
+func (s *Server) setPlayerVehicle(player *Player, vehicleID uint16, seat uint8) {
+	s.mu.Lock()
+	changed := player.VehicleID != vehicleID
+	if changed {
+		player.TrailerID = 0
+	}
+	player.VehicleID = vehicleID
+	player.Seat = seat
+	s.mu.Unlock()
+	
+	if changed {
+		s.trigger(EventPlayerVehicleChange, player.ID, VehicleChangeData{VehicleID: vehicleID, Seat: seat})
+	}
+}
+
+// SetVehicleLookup sets the function used to check that a synced vehicle exists.
+// Without one every vehicle ID except 0 and 0xFFFF is accepted.
+func (s *Server) SetVehicleLookup(exists func(vehicleID uint16) bool) {
+	s.vehicleExists = exists
+}
+
+// vehicleKnown reports whether vehicleID refers to an existing vehicle
+func (s *Server) vehicleKnown(vehicleID uint16) bool {
+	if vehicleID == 0 || vehicleID == protocol.InvalidVehicleID {
+		return false
+	}
+	return s.vehicleExists == nil || s.vehicleExists(vehicleID)
+}
+
+// relayPlayerSync forwards a player's on-foot sync to nearby players
+func (s *Server) relayPlayerSync(from *Player, payload []byte, now time.Time) {
+	s.relaySync(protocol.ID_PLAYER_SYNC, from, payload, now)
+}
+
+// relaySync forwards a player's sync packet to every in-game player within
+// StreamDistance, at most SyncRate times per second per recipient and packet.
+func (s *Server) relaySync(packetID byte, from *Player, payload []byte, now time.Time) {
+	s.mu.RLock()
+	recipients := make([]*Player, 0, len(s.Players))
+	for _, player := range s.Players {
+		if player == from || !player.Connected || !player.IsInGame() {
+			continue
+		}
+		if s.StreamDistance > 0 {
+			dx, dy, dz := player.PosX-from.PosX, player.PosY-from.PosY, player.PosZ-from.PosZ
+			if dx*dx+dy*dy+dz*dz > s.StreamDistance*s.StreamDistance {
+				continue
+			}
+		}
+		recipients = append(recipients, player)
+	}
+	s.mu.RUnlock()
+	
+	data := append([]byte{byte(from.ID), byte(from.ID >> 8)}, payload...)
+	
+	for _, recipient := range recipients {
+		s.syncMu.Lock()
+		key := syncKey{recipient: recipient.ID, subject: from.ID, packetID: packetID}
+		slot, exists := s.syncSlots[key]
+		if !exists {
+			slot = &syncSlot{}
+			s.syncSlots[key] = slot
+		}
+		send := s.syncDue(slot, now)
+		if send {
+			slot.last = now
+			slot.pending = nil
+		} else {
+			slot.pending = data
+		}
+		s.syncMu.Unlock()
+		
+		if send {
+			s.sendSync(recipient, packetID, data)
+		}
+	}
+}
+
+// flushSyncRelay sends held-back updates whose rate window has passed
+func (s *Server) flushSyncRelay(now time.Time) {
+	type delivery struct {
+		recipient uint16
+		packetID  byte
+		data      []byte
+	}
+	
+	s.syncMu.Lock()
+	deliveries := make([]delivery, 0)
+	for key, slot := range s.syncSlots {
+		if slot.pending == nil || !s.syncDue(slot, now) {
+			continue
+		}
+		deliveries = append(deliveries, delivery{key.recipient, key.packetID, slot.pending})
+		slot.last = now
+		slot.pending = nil
+	}
+	s.syncMu.Unlock()
+	
+	for _, d := range deliveries {
+		recipient, exists := s.GetPlayer(d.recipient)
+		if exists && recipient.IsInGame() {
+			s.sendSync(recipient, d.packetID, d.data)
+		}
+	}
+}
+
+// forgetSyncSlots drops every slot a player is in, as sender or recipient, so
+// a departed player's held-back sync can't reach whoever reuses the ID
+func (s *Server) forgetSyncSlots(playerID uint16) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	
+	for key := range s.syncSlots {
+		if key.recipient == playerID || key.subject == playerID {
+			delete(s.syncSlots, key)
+		}
+	}
+}
+
+// syncDue reports whether a slot may send again. Caller holds s.syncMu.
+func (s *Server) syncDue(slot *syncSlot, now time.Time) bool {
+	if s.SyncRate <= 0 || slot.last.IsZero() {
+		return true
+	}
+	return now.Sub(slot.last) >= time.Second/time.Duration(s.SyncRate)
+}
+
+func (s *Server) sendSync(recipient *Player, packetID byte, data []byte) {
+	packet := &protocol.RakNetPacket{
+		PacketID: packetID,
+		Payload:  data,
+	}
+	s.raknet.SendPacket(recipient.Session, packet, protocol.UNRELIABLE_SEQUENCED)
+}
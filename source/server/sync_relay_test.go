@@ -0,0 +1,299 @@
+package server
+
+import (
+	"encoding/binary"
+	"math"
+	"samp-server-go/source/protocol"
+	"testing"
+	"time"
+)
+
+// queuedSyncs returns the on-foot sync payloads queued on a session
+func queuedSyncs(session *protocol.Session) [][]byte {
+	return queuedSyncsOf(session, protocol.ID_PLAYER_SYNC)
+}
+
+// queuedSyncsOf returns the payloads of one sync packet type queued on a session
+func queuedSyncsOf(session *protocol.Session, packetID byte) [][]byte {
+	session.Mu.RLock()
+	defer session.Mu.RUnlock()
+	
+	syncs := make([][]byte, 0)
+	for _, encap := range session.SendQueue {
+		if len(encap.Payload) > 0 && encap.Payload[0] == packetID {
+			syncs = append(syncs, encap.Payload[1:])
+		}
+	}
+	return syncs
+}
+
+func TestSyncRelayCapsRatePerObservedPlayer(t *testing.T) {
+	srv := newTestServer()
+	srv.SyncRate = 10
+	sender := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	observer := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	
+	// 100 updates/sec for one second
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		now := start.Add(time.Duration(i) * 10 * time.Millisecond)
+		srv.relayPlayerSync(sender, []byte{byte(i)}, now)
+		srv.flushSyncRelay(now)
+	}
+	
+	syncs := queuedSyncs(observer.Session)
+	if len(syncs) != 10 {
+		t.Errorf("Expected 10 relayed updates at 10/sec, got %d", len(syncs))
+	}
+	if len(queuedSyncs(sender.Session)) != 0 {
+		t.Errorf("Expected no sync echoed back to the sender")
+	}
+	
+	// The held-back update is the latest one, not the oldest
+	srv.flushSyncRelay(start.Add(2 * time.Second))
+	syncs = queuedSyncs(observer.Session)
+	last := syncs[len(syncs)-1]
+	if last[0] != 0 || last[1] != 0 || last[2] != 99 {
+		t.Errorf("Expected latest update (player 0, seq 99), got %v", last)
+	}
+}
+
+func TestRemovePlayerDropsSyncSlots(t *testing.T) {
+	srv := newTestServer()
+	srv.SyncRate = 10
+	sender := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	addTestPlayer(srv, 2, protocol.STATE_IN_GAME)
+	
+	// Player 0's second update is held back for players 1 and 2
+	start := time.Now()
+	srv.relayPlayerSync(sender, []byte{0x01}, start)
+	srv.relayPlayerSync(sender, []byte{0x02}, start.Add(10*time.Millisecond))
+	srv.relayPlayerSync(srv.Players[1], []byte{0x03}, start)
+	
+	srv.RemovePlayer(0)
+	srv.RemovePlayer(2)
+	srv.syncMu.Lock()
+	slots := len(srv.syncSlots)
+	srv.syncMu.Unlock()
+	if slots != 0 {
+		t.Fatalf("Expected the departed players' slots dropped, %d left", slots)
+	}
+	
+	// Whoever reuses ID 0 doesn't get the old sender's held-back update relayed for it
+	reused := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	srv.flushSyncRelay(start.Add(time.Second))
+	if syncs := queuedSyncs(srv.Players[1].Session); len(syncs) != 1 {
+		t.Errorf("Expected only the update sent before the disconnect, got %d", len(syncs))
+	}
+	if syncs := queuedSyncs(reused.Session); len(syncs) != 0 {
+		t.Errorf("Expected nothing queued for the reused ID, got %d", len(syncs))
+	}
+}
+
+func TestSyncRelaySkipsDistantPlayers(t *testing.T) {
+	srv := newTestServer()
+	sender := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	near := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	far := addTestPlayer(srv, 2, protocol.STATE_IN_GAME)
+	near.SetPosition(50, 0, 0)
+	far.SetPosition(5000, 0, 0)
+	
+	srv.relayPlayerSync(sender, []byte{1}, time.Now())
+	
+	if len(queuedSyncs(near.Session)) != 1 {
+		t.Errorf("Expected nearby player to receive the sync")
+	}
+	if len(queuedSyncs(far.Session)) != 0 {
+		t.Errorf("Expected distant player not to receive the sync")
+	}
+}
+
+func TestPlayerSyncUpdatesPlayerState(t *testing.T) {
+	srv := newTestServer()
+	player := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	observer := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	player.Health, player.Armour = 75, 20
+	
+	sync := make([]byte, protocol.PlayerSyncSize)
+	for i, v := range []float32{12, 34, 5} {
+		binary.LittleEndian.PutUint32(sync[6+i*4:], math.Float32bits(v))
+	}
+	sync[34] = 75 // health
+	sync[35] = 20 // armour
+	
+	srv.handleGamePacket(player.Session, &protocol.RakNetPacket{PacketID: protocol.ID_PLAYER_SYNC, Payload: sync})
+	
+	if x, y, z := player.GetPosition(); x != 12 || y != 34 || z != 5 {
+		t.Errorf("Expected position (12, 34, 5), got (%v, %v, %v)", x, y, z)
+	}
+	if player.StatsMismatch {
+		t.Errorf("Expected matching health and armour not to be flagged")
+	}
+	if len(queuedSyncs(observer.Session)) != 1 {
+		t.Errorf("Expected the sync relayed to the nearby player")
+	}
+	
+	// A truncated sync is dropped rather than half-applied
+	binary.LittleEndian.PutUint32(sync[6:], math.Float32bits(99))
+	srv.handleGamePacket(player.Session, &protocol.RakNetPacket{PacketID: protocol.ID_PLAYER_SYNC, Payload: sync[:18]})
+	if x, _, _ := player.GetPosition(); x != 12 {
+		t.Errorf("Expected short sync to be ignored, x is %v", x)
+	}
+}
+
+func TestPlayerSyncKeepsServerHealth(t *testing.T) {
+	srv := newTestServer()
+	player := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	player.Health, player.Armour = 40, 0
+	
+	// A client claiming full health and armour
+	sync := make([]byte, protocol.PlayerSyncSize)
+	sync[34] = 100
+	sync[35] = 100
+	srv.handleGamePacket(player.Session, &protocol.RakNetPacket{PacketID: protocol.ID_PLAYER_SYNC, Payload: sync})
+	
+	if player.Health != 40 || player.Armour != 0 {
+		t.Errorf("Expected the server's health 40 and armour 0 kept, got %v and %v", player.Health, player.Armour)
+	}
+	if !player.StatsMismatch {
+		t.Error("Expected the mismatch to be flagged")
+	}
+	
+	// Back in line with the server
+	sync[34], sync[35] = 40, 0
+	srv.handleGamePacket(player.Session, &protocol.RakNetPacket{PacketID: protocol.ID_PLAYER_SYNC, Payload: sync})
+	if player.StatsMismatch {
+		t.Error("Expected the flag cleared once the sync matches")
+	}
+}
+
+func TestAimSyncDecodedAndRelayed(t *testing.T) {
+	srv := newTestServer()
+	shooter := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	observer := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	
+	aim := make([]byte, protocol.AimSyncSize)
+	aim[0] = 53
+	for i, v := range []float32{0.5, -0.25, 0.125, 10, 20, 30} {
+		binary.LittleEndian.PutUint32(aim[1+i*4:], math.Float32bits(v))
+	}
+	
+	// An on-foot update first, so the aim sync must not share its rate slot
+	srv.relayPlayerSync(shooter, []byte{1}, time.Now())
+	srv.handleGamePacket(shooter.Session, &protocol.RakNetPacket{PacketID: protocol.ID_AIM_SYNC, Payload: aim})
+	
+	if shooter.Aim == nil || shooter.Aim.CamFront != [3]float32{0.5, -0.25, 0.125} {
+		t.Fatalf("Expected decoded camera front on the shooter, got %+v", shooter.Aim)
+	}
+	if shooter.Aim.CamPos != [3]float32{10, 20, 30} {
+		t.Errorf("Unexpected camera position: %v", shooter.Aim.CamPos)
+	}
+	
+	relayed := queuedSyncsOf(observer.Session, protocol.ID_AIM_SYNC)
+	if len(relayed) != 1 {
+		t.Fatalf("Expected 1 relayed aim sync, got %d", len(relayed))
+	}
+	if relayed[0][0] != 0 || relayed[0][1] != 0 || relayed[0][2] != 53 {
+		t.Errorf("Expected aim sync prefixed with player 0, got %v", relayed[0][:3])
+	}
+	if len(queuedSyncsOf(shooter.Session, protocol.ID_AIM_SYNC)) != 0 {
+		t.Errorf("Expected no aim sync echoed back to the shooter")
+	}
+}
+
+func TestPassengerSyncRelayedWithVehicleAndSeat(t *testing.T) {
+	srv := newTestServer()
+	srv.SetVehicleLookup(func(vehicleID uint16) bool { return vehicleID == 42 })
+	passenger := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	observer := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	
+	payload := make([]byte, protocol.PassengerSyncSize)
+	binary.LittleEndian.PutUint16(payload[0:2], 42)
+	payload[2] = 1
+	for i, v := range []float32{30, 40, 5} {
+		binary.LittleEndian.PutUint32(payload[12+i*4:], math.Float32bits(v))
+	}
+	srv.handleGamePacket(passenger.Session, &protocol.RakNetPacket{PacketID: protocol.ID_PASSENGER_SYNC, Payload: payload})
+	
+	if passenger.VehicleID != 42 || passenger.Seat != 1 {
+		t.Errorf("Expected passenger in vehicle 42 seat 1, got vehicle %d seat %d", passenger.VehicleID, passenger.Seat)
+	}
+	if x, y, z := passenger.GetPosition(); x != 30 || y != 40 || z != 5 {
+		t.Errorf("Expected position (30, 40, 5), got (%f, %f, %f)", x, y, z)
+	}
+	if relayed := queuedSyncsOf(observer.Session, protocol.ID_PASSENGER_SYNC); len(relayed) != 1 {
+		t.Fatalf("Expected 1 relayed passenger sync, got %d", len(relayed))
+	}
+	
+	// A passenger sync for a vehicle that doesn't exist is dropped
+	binary.LittleEndian.PutUint16(payload[0:2], 99)
+	srv.handleGamePacket(passenger.Session, &protocol.RakNetPacket{PacketID: protocol.ID_PASSENGER_SYNC, Payload: payload})
+	if passenger.VehicleID != 42 {
+		t.Errorf("Expected unknown vehicle to be ignored, got vehicle %d", passenger.VehicleID)
+	}
+	if relayed := queuedSyncsOf(observer.Session, protocol.ID_PASSENGER_SYNC); len(relayed) != 1 {
+		t.Errorf("Expected unknown vehicle not to relay, got %d syncs", len(relayed))
+	}
+}
+
+func TestTrailerSyncTiedToTowingVehicle(t *testing.T) {
+	srv := newTestServer()
+	srv.SetVehicleLookup(func(vehicleID uint16) bool { return vehicleID == 5 || vehicleID == 7 })
+	driver := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	observer := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	
+	trailer := make([]byte, protocol.TrailerSyncSize)
+	binary.LittleEndian.PutUint16(trailer[0:2], 7)
+	
+	// Not driving anything yet, so there is no towing vehicle
+	srv.handleGamePacket(driver.Session, &protocol.RakNetPacket{PacketID: protocol.ID_TRAILER_SYNC, Payload: trailer})
+	if len(queuedSyncsOf(observer.Session, protocol.ID_TRAILER_SYNC)) != 0 {
+		t.Fatalf("Expected trailer sync without a towing vehicle to be dropped")
+	}
+	
+	srv.handleGamePacket(driver.Session, &protocol.RakNetPacket{PacketID: protocol.ID_VEHICLE_SYNC, Payload: []byte{5, 0}})
+	srv.handleGamePacket(driver.Session, &protocol.RakNetPacket{PacketID: protocol.ID_TRAILER_SYNC, Payload: trailer})
+	
+	if driver.VehicleID != 5 || driver.TrailerID != 7 {
+		t.Errorf("Expected vehicle 5 towing trailer 7, got vehicle %d trailer %d", driver.VehicleID, driver.TrailerID)
+	}
+	relayed := queuedSyncsOf(observer.Session, protocol.ID_TRAILER_SYNC)
+	if len(relayed) != 1 || relayed[0][2] != 7 {
+		t.Fatalf("Expected trailer 7 sync relayed once, got %v", relayed)
+	}
+	
+	// Unknown trailer
+	binary.LittleEndian.PutUint16(trailer[0:2], 8)
+	srv.handleGamePacket(driver.Session, &protocol.RakNetPacket{PacketID: protocol.ID_TRAILER_SYNC, Payload: trailer})
+	if driver.TrailerID != 7 || len(queuedSyncsOf(observer.Session, protocol.ID_TRAILER_SYNC)) != 1 {
+		t.Errorf("Expected unknown trailer to be dropped")
+	}
+}
+
+func TestVehicleChangeTrackedFromSync(t *testing.T) {
+	srv := newTestServer()
+	player := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	
+	var changes []VehicleChangeData
+	srv.Events.Register(EventPlayerVehicleChange, func(e Event) {
+		changes = append(changes, e.Data.(VehicleChangeData))
+	})
+	
+	srv.handleGamePacket(player.Session, &protocol.RakNetPacket{PacketID: protocol.ID_VEHICLE_SYNC, Payload: []byte{5, 0}})
+	srv.handleGamePacket(player.Session, &protocol.RakNetPacket{PacketID: protocol.ID_VEHICLE_SYNC, Payload: []byte{5, 0}})
+	if player.VehicleID != 5 {
+		t.Fatalf("Expected driver in vehicle 5, got %d", player.VehicleID)
+	}
+	
+	// Back on foot
+	srv.handleGamePacket(player.Session, &protocol.RakNetPacket{PacketID: protocol.ID_PLAYER_SYNC, Payload: make([]byte, protocol.PlayerSyncSize)})
+	if player.VehicleID != 0 || player.Seat != 0 {
+		t.Errorf("Expected on-foot sync to clear the vehicle, got vehicle %d seat %d", player.VehicleID, player.Seat)
+	}
+	
+	want := []VehicleChangeData{{VehicleID: 5}, {VehicleID: 0}}
+	if len(changes) != len(want) || changes[0] != want[0] || changes[1] != want[1] {
+		t.Errorf("Expected vehicle change events %v, got %v", want, changes)
+	}
+}
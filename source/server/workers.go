@@ -0,0 +1,92 @@
+package server
+
+import (
+	"net"
+	"samp-server-go/source/protocol"
+	"sync/atomic"
+)
+
+// Inbound datagrams are handled by a fixed pool of workers instead of a
+// goroutine each, so a flood cannot pile up goroutines
+const (
+	DefaultPacketWorkers   = 8
+	DefaultPacketQueueSize = 256 // datagrams waiting per worker before new ones are dropped
+)
+
+// datagram is one packet read from a socket
+type datagram struct {
+	data []byte
+	addr *net.UDPAddr
+	buf  *[]byte // pooled buffer backing data, returned once handled (nil = not pooled)
+}
+
+// packetWorkers queues datagrams for a fixed set of workers. Every source
+// address always lands on the same worker, so one client's packets are
+// handled in the order they arrived.
+type packetWorkers struct {
+	queues  []chan datagram
+	dropped atomic.Uint64
+}
+
+func newPacketWorkers(workers, queueSize int) *packetWorkers {
+	if workers < 1 {
+		workers = 1
+	}
+	w := &packetWorkers{queues: make([]chan datagram, workers)}
+	for i := range w.queues {
+		w.queues[i] = make(chan datagram, queueSize)
+	}
+	return w
+}
+
+// dispatch queues a datagram for its address's worker without blocking.
+// It returns false, dropping the datagram, when that worker is backed up.
+func (w *packetWorkers) dispatch(d datagram) bool {
+	select {
+	case w.queues[workerIndex(d.addr, len(w.queues))] <- d:
+		return true
+	default:
+		w.dropped.Add(1)
+		protocol.PutBuffer(d.buf)
+		return false
+	}
+}
+
+// run handles worker i's datagrams until done is closed
+func (w *packetWorkers) run(i int, done <-chan struct{}, handle func([]byte, *net.UDPAddr)) {
+	queue := w.queues[i]
+	for {
+		select {
+		case <-done:
+			return
+		case d := <-queue:
+			handle(d.data, d.addr)
+			protocol.PutBuffer(d.buf)
+		}
+	}
+}
+
+// workerIndex hashes an address (FNV-1a over IP and port) to one of n workers
+func workerIndex(addr *net.UDPAddr, n int) int {
+	h := uint32(2166136261)
+	for _, b := range addr.IP.To16() {
+		h ^= uint32(b)
+		h *= 16777619
+	}
+	h ^= uint32(addr.Port)
+	h *= 16777619
+	return int(h % uint32(n))
+}
+
+// startPacketWorkers starts the pool listen hands datagrams to
+func (s *Server) startPacketWorkers() {
+	queueSize := s.PacketQueueSize
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	s.workers = newPacketWorkers(s.PacketWorkers, queueSize)
+	for i := range s.workers.queues {
+		i := i
+		s.goLoop(func() { s.workers.run(i, s.done, s.handleDatagram) })
+	}
+}
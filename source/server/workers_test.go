@@ -0,0 +1,149 @@
+package server
+
+import (
+	"net"
+	"runtime"
+	"samp-server-go/source/protocol"
+	"sync"
+	"testing"
+)
+
+func TestPacketWorkersKeepPerAddressOrder(t *testing.T) {
+	workers := newPacketWorkers(4, 1000)
+	done := make(chan struct{})
+	defer close(done)
+	
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	seen := make(map[string][]byte)
+	handle := func(data []byte, addr *net.UDPAddr) {
+		mu.Lock()
+		seen[addr.String()] = append(seen[addr.String()], data[0])
+		mu.Unlock()
+		wg.Done()
+	}
+	for i := range workers.queues {
+		go workers.run(i, done, handle)
+	}
+	
+	addrs := make([]*net.UDPAddr, 6)
+	for i := range addrs {
+		addrs[i] = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50000 + i}
+	}
+	for n := 0; n < 100; n++ {
+		for _, addr := range addrs {
+			wg.Add(1)
+			if !workers.dispatch(datagram{data: []byte{byte(n)}, addr: addr}) {
+				t.Fatalf("Expected datagram %d from %v to be queued", n, addr)
+			}
+		}
+	}
+	wg.Wait()
+	
+	for _, addr := range addrs {
+		got := seen[addr.String()]
+		if len(got) != 100 {
+			t.Fatalf("Expected 100 datagrams from %v, got %d", addr, len(got))
+		}
+		for n, b := range got {
+			if b != byte(n) {
+				t.Fatalf("Expected datagrams from %v in arrival order, got %v", addr, got)
+			}
+		}
+	}
+}
+
+func TestPacketWorkersDropWhenBackedUp(t *testing.T) {
+	workers := newPacketWorkers(1, 2)
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50000}
+	
+	for i := 0; i < 2; i++ {
+		if !workers.dispatch(datagram{data: []byte{0x01}, addr: addr}) {
+			t.Fatalf("Expected datagram %d to fit in the queue", i)
+		}
+	}
+	if workers.dispatch(datagram{data: []byte{0x01}, addr: addr}) {
+		t.Error("Expected a datagram beyond the queue size to be dropped")
+	}
+	if n := workers.dropped.Load(); n != 1 {
+		t.Errorf("Expected 1 dropped datagram, got %d", n)
+	}
+}
+
+func TestWorkerIndexIsStablePerAddress(t *testing.T) {
+	used := make(map[int]bool)
+	for port := 50000; port < 50100; port++ {
+		addr := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: port}
+		index := workerIndex(addr, 8)
+		if index < 0 || index >= 8 {
+			t.Fatalf("Expected an index below 8, got %d", index)
+		}
+		same := &net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: port}
+		if workerIndex(same, 8) != index {
+			t.Fatalf("Expected %v to always map to worker %d", addr, index)
+		}
+		used[index] = true
+	}
+	if len(used) != 8 {
+		t.Errorf("Expected 100 clients spread over all 8 workers, used %d", len(used))
+	}
+}
+
+// benchmarkDatagram is a small game datagram; handling it decodes the frame
+func benchmarkDatagram() []byte {
+	dp := protocol.NewDataPacket()
+	dp.Packets = []*protocol.EncapsulatedPacket{{Reliability: protocol.RELIABLE_ORDERED, Payload: make([]byte, 64)}}
+	return dp.Encode()
+}
+
+func benchmarkAddrs() []*net.UDPAddr {
+	addrs := make([]*net.UDPAddr, 64)
+	for i := range addrs {
+		addrs[i] = &net.UDPAddr{IP: net.IPv4(10, 0, byte(i>>8), byte(i)), Port: 7000 + i}
+	}
+	return addrs
+}
+
+// BenchmarkDatagramGoroutinePerPacket is the old listen path, for comparison
+func BenchmarkDatagramGoroutinePerPacket(b *testing.B) {
+	data := benchmarkDatagram()
+	addrs := benchmarkAddrs()
+	var wg sync.WaitGroup
+	b.ResetTimer()
+	
+	for i := 0; i < b.N; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			protocol.DecodeDataPacket(data)
+		}()
+		_ = addrs[i%len(addrs)]
+	}
+	wg.Wait()
+}
+
+func BenchmarkDatagramWorkerPool(b *testing.B) {
+	data := benchmarkDatagram()
+	addrs := benchmarkAddrs()
+	workers := newPacketWorkers(DefaultPacketWorkers, DefaultPacketQueueSize)
+	done := make(chan struct{})
+	defer close(done)
+	
+	var wg sync.WaitGroup
+	handle := func(data []byte, addr *net.UDPAddr) {
+		defer wg.Done()
+		protocol.DecodeDataPacket(data)
+	}
+	for i := range workers.queues {
+		go workers.run(i, done, handle)
+	}
+	b.ResetTimer()
+	
+	for i := 0; i < b.N; i++ {
+		wg.Add(1)
+		for !workers.dispatch(datagram{data: data, addr: addrs[i%len(addrs)]}) {
+			runtime.Gosched() // the benchmark outpaces the workers; a real socket would drop here
+		}
+	}
+	wg.Wait()
+}
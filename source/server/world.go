@@ -0,0 +1,194 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"samp-server-go/source/protocol"
+	"time"
+)
+
+// updateTimeCycle advances WorldTime by one hour for every TimeCycleInterval
+// of real time that has passed and broadcasts the new hour to all players.
+// It is driven by the update loop; now is passed in so tests can control time.
+func (s *Server) updateTimeCycle(now time.Time) {
+	s.mu.Lock()
+	if !s.TimeCycleEnabled || s.TimeCycleInterval <= 0 {
+		s.mu.Unlock()
+		return
+	}
+	
+	// First tick only starts the cycle
+	if s.timeCycleLast.IsZero() {
+		s.timeCycleLast = now
+		s.mu.Unlock()
+		return
+	}
+	
+	hours := 0
+	for now.Sub(s.timeCycleLast) >= s.TimeCycleInterval {
+		s.timeCycleLast = s.timeCycleLast.Add(s.TimeCycleInterval)
+		hours++
+	}
+	if hours == 0 {
+		s.mu.Unlock()
+		return
+	}
+	
+	s.WorldTime = (s.WorldTime + hours) % 24
+	hour := s.WorldTime
+	s.mu.Unlock()
+	
+	log.Printf("🕐 World time advanced to %d:00", hour)
+	s.BroadcastRPC(protocol.BuildSetWorldTimeRPC(uint8(hour)))
+}
+
+// Default SA-MP gravity and the largest world bounds the client accepts
+const (
+	DefaultGravity = 0.008
+	MaxWorldBound  = 20000.0
+)
+
+// SetWorldBounds confines all players to the given rectangle and syncs it
+// to everyone in game. New players receive it through InitGame.
+func (s *Server) SetWorldBounds(minX, minY, maxX, maxY float32) error {
+	if minX >= maxX || minY >= maxY {
+		return fmt.Errorf("invalid world bounds: min (%.2f, %.2f) must be below max (%.2f, %.2f)", minX, minY, maxX, maxY)
+	}
+	
+	s.mu.Lock()
+	s.worldBounds = [4]float32{minX, minY, maxX, maxY}
+	s.mu.Unlock()
+	
+	log.Printf("🗺️  World bounds set to (%.2f, %.2f) - (%.2f, %.2f)", minX, minY, maxX, maxY)
+	s.BroadcastRPC(protocol.BuildSetWorldBoundsRPC(minX, minY, maxX, maxY))
+	return nil
+}
+
+// GetWorldBounds returns minX, minY, maxX, maxY
+func (s *Server) GetWorldBounds() (float32, float32, float32, float32) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b := s.worldBounds
+	return b[0], b[1], b[2], b[3]
+}
+
+// DefaultNameTagDrawDistance is SA-MP's default name tag range in meters
+const DefaultNameTagDrawDistance = 70.0
+
+// SetNameTags shows or hides every player's name tag. Players in game get a
+// ShowPlayerNameTagForPlayer for each other player; new players receive the
+// setting through InitGame.
+func (s *Server) SetNameTags(show bool) {
+	s.mu.Lock()
+	s.ShowNameTags = show
+	s.mu.Unlock()
+	
+	log.Printf("🏷️  Name tags shown: %v", show)
+	players := s.inGamePlayers()
+	for _, viewer := range players {
+		for _, other := range players {
+			if other != viewer {
+				s.sendRPC(viewer.Session, protocol.BuildShowPlayerNameTagForPlayerRPC(other.ID, show))
+			}
+		}
+	}
+}
+
+// SetPlayerMarkers sets the radar marker mode. The client only reads the mode
+// from InitGame, so players in game are sent each other's color with the
+// marker alpha cleared (PlayerMarkersOff) or restored; the difference between
+// global and streamed markers applies to players who join afterwards.
+func (s *Server) SetPlayerMarkers(mode int) error {
+	if mode < protocol.PlayerMarkersOff || mode > protocol.PlayerMarkersStreamed {
+		return fmt.Errorf("invalid player marker mode %d (must be %d-%d)", mode, protocol.PlayerMarkersOff, protocol.PlayerMarkersStreamed)
+	}
+	
+	s.mu.Lock()
+	s.PlayerMarkers = mode
+	s.mu.Unlock()
+	
+	log.Printf("📍 Player markers set to mode %d", mode)
+	players := s.inGamePlayers()
+	for _, viewer := range players {
+		for _, other := range players {
+			if other == viewer {
+				continue
+			}
+			s.mu.RLock()
+			color := s.markerColor(other.Color)
+			s.mu.RUnlock()
+			s.sendRPC(viewer.Session, protocol.BuildSetPlayerColorRPC(other.ID, color))
+		}
+	}
+	return nil
+}
+
+// MaxWeatherID is the highest weather id the SA-MP client supports
+const MaxWeatherID = protocol.MaxWeatherID
+
+// SetWeatherRotation sets the list of weather ids to cycle through.
+// An empty list disables the rotation.
+func (s *Server) SetWeatherRotation(ids []int) error {
+	for _, id := range ids {
+		if id < 0 || id > MaxWeatherID {
+			return fmt.Errorf("invalid weather id %d (must be 0-%d)", id, MaxWeatherID)
+		}
+	}
+	
+	s.mu.Lock()
+	s.weatherRotation = append([]int(nil), ids...)
+	s.weatherIndex = -1 // first change picks ids[0]
+	s.weatherLast = time.Time{}
+	s.mu.Unlock()
+	
+	return nil
+}
+
+// updateWeather switches to the next weather in the rotation every
+// WeatherInterval and broadcasts it to all players.
+func (s *Server) updateWeather(now time.Time) {
+	s.mu.Lock()
+	if len(s.weatherRotation) == 0 || s.WeatherInterval <= 0 {
+		s.mu.Unlock()
+		return
+	}
+	
+	// First tick only starts the rotation
+	if s.weatherLast.IsZero() {
+		s.weatherLast = now
+		s.mu.Unlock()
+		return
+	}
+	
+	if now.Sub(s.weatherLast) < s.WeatherInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.weatherLast = now
+	
+	if s.WeatherRandom {
+		s.weatherIndex = s.rng.Intn(len(s.weatherRotation))
+	} else {
+		s.weatherIndex = (s.weatherIndex + 1) % len(s.weatherRotation)
+	}
+	s.Weather = s.weatherRotation[s.weatherIndex]
+	weather := s.Weather
+	s.mu.Unlock()
+	
+	log.Printf("🌦️  Weather changed to %d", weather)
+	s.BroadcastRPC(protocol.BuildSetWeatherRPC(uint8(weather)))
+}
+
+// SetRand replaces the random source used for random weather. It is only
+// drawn from under s.mu, since *rand.Rand is not safe for concurrent use.
+func (s *Server) SetRand(rng *rand.Rand) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rng = rng
+}
+
+// BroadcastRPC queues an RPC (RELIABLE_ORDERED) to every session that has entered the game
+func (s *Server) BroadcastRPC(rpcPayload []byte) {
+	s.SendRPCToAll(rpcPayload, protocol.RELIABLE_ORDERED)
+}
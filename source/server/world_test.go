@@ -0,0 +1,299 @@
+package server
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"samp-server-go/source/protocol"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTimeCycleAdvancesAndWraps(t *testing.T) {
+	srv := newTestServer()
+	srv.TimeCycleEnabled = true
+	srv.TimeCycleInterval = time.Minute
+	srv.WorldTime = 22
+	
+	inGame := addTestSession(srv, 50001, protocol.STATE_IN_GAME)
+	handshaking := addTestSession(srv, 50002, protocol.STATE_HANDSHAKE_SENT)
+	
+	start := time.Unix(1000, 0)
+	srv.updateTimeCycle(start)
+	if srv.WorldTime != 22 {
+		t.Fatalf("Expected world time 22 after first tick, got %d", srv.WorldTime)
+	}
+	
+	// Not enough time has passed yet
+	srv.updateTimeCycle(start.Add(30 * time.Second))
+	if srv.WorldTime != 22 {
+		t.Errorf("Expected world time 22 before interval, got %d", srv.WorldTime)
+	}
+	
+	srv.updateTimeCycle(start.Add(1 * time.Minute))
+	if srv.WorldTime != 23 {
+		t.Errorf("Expected world time 23, got %d", srv.WorldTime)
+	}
+	
+	srv.updateTimeCycle(start.Add(2 * time.Minute))
+	if srv.WorldTime != 0 {
+		t.Errorf("Expected world time to wrap to 0, got %d", srv.WorldTime)
+	}
+	
+	rpcs := queuedRPCs(inGame)
+	if len(rpcs) != 2 {
+		t.Fatalf("Expected 2 SetWorldTime RPCs, got %d", len(rpcs))
+	}
+	for i, want := range []byte{23, 0} {
+		if rpcs[i][0] != protocol.RPC_SetWorldTime || rpcs[i][1] != want {
+			t.Errorf("RPC %d = %02X, want SetWorldTime(%d)", i, rpcs[i], want)
+		}
+	}
+	
+	if len(queuedRPCs(handshaking)) != 0 {
+		t.Errorf("Handshaking session should not receive world time RPCs")
+	}
+}
+
+func TestTimeCycleWhileConnecting(t *testing.T) {
+	srv := newTestServer()
+	srv.TimeCycleEnabled = true
+	srv.TimeCycleInterval = time.Minute
+	start := time.Unix(1000, 0)
+	srv.updateTimeCycle(start)
+	
+	// The connect flow reads the hour while the update loop advances it
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= 100; i++ {
+			srv.updateTimeCycle(start.Add(time.Duration(i) * time.Minute))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			srv.raknet.buildInitGameRPC()
+			srv.raknet.connectSteps()[2].build()
+		}
+	}()
+	wg.Wait()
+	
+	if rpc := srv.raknet.connectSteps()[2].build(); rpc[1] != byte((12+100)%24) {
+		t.Errorf("Expected SetWorldTime(%d) after the cycle, got %02X", (12+100)%24, rpc)
+	}
+}
+
+func TestTimeCycleDisabled(t *testing.T) {
+	srv := newTestServer()
+	srv.TimeCycleEnabled = false
+	srv.WorldTime = 12
+	
+	start := time.Unix(1000, 0)
+	srv.updateTimeCycle(start)
+	srv.updateTimeCycle(start.Add(time.Hour))
+	
+	if srv.WorldTime != 12 {
+		t.Errorf("Expected world time to stay 12 when disabled, got %d", srv.WorldTime)
+	}
+}
+
+func TestWeatherRotationSequential(t *testing.T) {
+	srv := newTestServer()
+	srv.WeatherInterval = time.Minute
+	if err := srv.SetWeatherRotation([]int{1, 8, 19}); err != nil {
+		t.Fatalf("SetWeatherRotation failed: %v", err)
+	}
+	
+	session := addTestSession(srv, 50001, protocol.STATE_IN_GAME)
+	
+	start := time.Unix(1000, 0)
+	srv.updateWeather(start)
+	for i := 1; i <= 4; i++ {
+		srv.updateWeather(start.Add(time.Duration(i) * time.Minute))
+	}
+	
+	expected := []byte{1, 8, 19, 1}
+	if srv.Weather != int(expected[len(expected)-1]) {
+		t.Errorf("Expected weather %d, got %d", expected[len(expected)-1], srv.Weather)
+	}
+	
+	rpcs := queuedRPCs(session)
+	if len(rpcs) != len(expected) {
+		t.Fatalf("Expected %d SetWeather RPCs, got %d", len(expected), len(rpcs))
+	}
+	for i, want := range expected {
+		if rpcs[i][0] != protocol.RPC_SetWeather || rpcs[i][1] != want {
+			t.Errorf("RPC %d = %02X, want SetWeather(%d)", i, rpcs[i], want)
+		}
+	}
+}
+
+func TestWeatherRotationRandomStaysInList(t *testing.T) {
+	srv := newTestServer()
+	srv.WeatherInterval = time.Minute
+	srv.WeatherRandom = true
+	srv.SetWeatherRotation([]int{3, 7})
+	
+	start := time.Unix(1000, 0)
+	srv.updateWeather(start)
+	for i := 1; i <= 10; i++ {
+		srv.updateWeather(start.Add(time.Duration(i) * time.Minute))
+		if srv.Weather != 3 && srv.Weather != 7 {
+			t.Fatalf("Weather %d is not in the rotation", srv.Weather)
+		}
+	}
+}
+
+func TestWeatherRotationRandomUsesSeed(t *testing.T) {
+	picks := func() []int {
+		srv := newTestServer()
+		srv.WeatherInterval = time.Minute
+		srv.WeatherRandom = true
+		srv.SetWeatherRotation([]int{0, 5, 10, 15, 20, 25, 30, 35})
+		srv.SetRand(rand.New(rand.NewSource(42)))
+		
+		start := time.Unix(1000, 0)
+		srv.updateWeather(start)
+		weathers := make([]int, 0)
+		for i := 1; i <= 8; i++ {
+			srv.updateWeather(start.Add(time.Duration(i) * time.Minute))
+			weathers = append(weathers, srv.Weather)
+		}
+		return weathers
+	}
+	
+	first, second := picks(), picks()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("Expected the same seed to pick the same weathers, got %v and %v", first, second)
+		}
+	}
+}
+
+func TestWeatherRotationWhileQueriedAndConnecting(t *testing.T) {
+	srv := newTestServer()
+	srv.WeatherInterval = time.Minute
+	srv.SetWeatherRotation([]int{1, 8, 19})
+	start := time.Unix(1000, 0)
+	srv.updateWeather(start)
+	
+	// Queries and the connect flow read the weather while the update loop rotates it
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= 100; i++ {
+			srv.updateWeather(start.Add(time.Duration(i) * time.Minute))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			srv.raknet.buildSAMPRulesResponse(sampQuery('r'))
+			srv.raknet.buildInitGameRPC()
+			srv.raknet.connectSteps()[3].build()
+		}
+	}()
+	wg.Wait()
+	
+	if rpc := srv.raknet.connectSteps()[3].build(); rpc[1] != 1 {
+		t.Errorf("Expected SetWeather(1) after 100 rotations, got %02X", rpc)
+	}
+}
+
+func TestWeatherRotationRejectsInvalidIDs(t *testing.T) {
+	srv := newTestServer()
+	
+	if err := srv.SetWeatherRotation([]int{1, 46}); err == nil {
+		t.Error("Expected error for weather id 46")
+	}
+	if err := srv.SetWeatherRotation([]int{-1}); err == nil {
+		t.Error("Expected error for weather id -1")
+	}
+	if err := srv.SetWeatherRotation([]int{0, 45}); err != nil {
+		t.Errorf("Expected 0 and 45 to be valid, got %v", err)
+	}
+}
+
+func TestSetWorldBoundsBroadcasts(t *testing.T) {
+	srv := newTestServer()
+	session := addTestSession(srv, 50001, protocol.STATE_IN_GAME)
+	
+	if err := srv.SetWorldBounds(-500, -500, 500, 500); err != nil {
+		t.Fatalf("Expected valid bounds, got %v", err)
+	}
+	
+	rpcs := queuedRPCs(session)
+	expected := protocol.BuildSetWorldBoundsRPC(-500, -500, 500, 500)
+	if len(rpcs) != 1 || string(rpcs[0]) != string(expected) {
+		t.Errorf("Expected one SetWorldBounds RPC, got %02X", rpcs)
+	}
+	
+	if minX, minY, maxX, maxY := srv.GetWorldBounds(); minX != -500 || minY != -500 || maxX != 500 || maxY != 500 {
+		t.Errorf("Expected stored bounds, got %f %f %f %f", minX, minY, maxX, maxY)
+	}
+	
+	if err := srv.SetWorldBounds(10, 0, -10, 5); err == nil {
+		t.Error("Expected error when min is above max")
+	}
+}
+
+func TestInitGameCarriesNameTagAndMarkerConfig(t *testing.T) {
+	srv := newTestServer()
+	srv.ShowNameTags = false
+	srv.NameTagDrawDistance = 25
+	srv.NameTagsIgnoreLOS = true
+	srv.PlayerMarkers = protocol.PlayerMarkersStreamed
+	
+	rpc := srv.raknet.buildInitGameRPC()
+	
+	// [id][zone][cjwalk][weapons][limit chat][radius f32][stunt] then name tag distance at 10
+	if distance := math.Float32frombits(binary.LittleEndian.Uint32(rpc[10:14])); distance != 25 {
+		t.Errorf("Expected name tag distance 25, got %f", distance)
+	}
+	if rpc[15] != 0 {
+		t.Errorf("Expected name tag LOS off, got %d", rpc[15])
+	}
+	if rpc[23] != 0 {
+		t.Errorf("Expected name tags hidden, got %d", rpc[23])
+	}
+	if markers := binary.LittleEndian.Uint32(rpc[24:28]); markers != protocol.PlayerMarkersStreamed {
+		t.Errorf("Expected streamed markers, got %d", markers)
+	}
+	
+	srv.PlayerMarkers = protocol.PlayerMarkersOff
+	if markers := binary.LittleEndian.Uint32(srv.raknet.buildInitGameRPC()[24:28]); markers != protocol.PlayerMarkersOff {
+		t.Errorf("Expected markers off, got %d", markers)
+	}
+}
+
+func TestSetNameTagsAndMarkersResendToPlayersInGame(t *testing.T) {
+	srv := newTestServer()
+	alice := addTestPlayer(srv, 0, protocol.STATE_IN_GAME)
+	bob := addTestPlayer(srv, 1, protocol.STATE_IN_GAME)
+	bob.Color = 0x11223344
+	
+	srv.SetNameTags(false)
+	rpcs := queuedRPCs(alice.Session)
+	if expected := protocol.BuildShowPlayerNameTagForPlayerRPC(bob.ID, false); len(rpcs) != 1 || string(rpcs[0]) != string(expected) {
+		t.Errorf("Expected Alice to hide Bob's name tag, got %02X", rpcs)
+	}
+	
+	if err := srv.SetPlayerMarkers(protocol.PlayerMarkersOff); err != nil {
+		t.Fatalf("Expected valid mode, got %v", err)
+	}
+	rpcs = queuedRPCs(alice.Session)
+	if expected := protocol.BuildSetPlayerColorRPC(bob.ID, 0x11223300); len(rpcs) != 2 || string(rpcs[1]) != string(expected) {
+		t.Errorf("Expected Bob's marker alpha cleared, got %02X", rpcs)
+	}
+	
+	if srv.ShowNameTags || srv.PlayerMarkers != protocol.PlayerMarkersOff {
+		t.Errorf("Expected config updated, got tags %v markers %d", srv.ShowNameTags, srv.PlayerMarkers)
+	}
+	if err := srv.SetPlayerMarkers(3); err == nil {
+		t.Error("Expected error for marker mode 3")
+	}
+}